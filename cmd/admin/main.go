@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+)
+
+const usage = `Usage:
+  go run ./cmd/admin export -node <ip:port> [-o <file>]
+  go run ./cmd/admin import -node <ip:port> [-i <file>] [-overwrite]
+  go run ./cmd/admin keys -node <ip:port> [-prefix <hex>] [-limit <n>]
+  go run ./cmd/admin keyspace -node <ip:port> [-bins <n>] [-format json|csv] [-o <file>]
+  go run ./cmd/admin jobs -node <ip:port>
+  go run ./cmd/admin pause -node <ip:port> [-job <name>]
+  go run ./cmd/admin resume -node <ip:port> [-job <name>]
+
+The admin token is read from KADEMLIA_ADMIN_TOKEN, and the network's
+auth token, if any, from KADEMLIA_AUTH_TOKEN.`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	node := flags.String("node", "", "Address (ip:port) of the node to administer")
+	output := flags.String("o", "", "File to write the export or histogram to (default: stdout)")
+	input := flags.String("i", "", "Export file to import (default: stdin)")
+	overwrite := flags.Bool("overwrite", false, "Replace keys the node already stores")
+	prefix := flags.String("prefix", "", "Hex prefix of the keys to list")
+	limit := flags.Int("limit", 0, "Maximum number of keys to list (0 lists all)")
+	bins := flags.Int("bins", kademlia.DefaultKeyspaceBins, "Number of equal keyspace ranges to count keys and peers in")
+	format := flags.String("format", "json", "Keyspace histogram format: json or csv")
+	job := flags.String("job", "", "Scheduled job to pause or resume (default: every job)")
+	flags.Parse(os.Args[2:])
+	if *node == "" {
+		log.Fatal(usage)
+	}
+
+	network.SetAuthToken(os.Getenv("KADEMLIA_AUTH_TOKEN"))
+	c := client.NewClient(*node)
+	c.AdminToken = os.Getenv("KADEMLIA_ADMIN_TOKEN")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch os.Args[1] {
+	case "export":
+		w, closeOutput := create(*output)
+		defer closeOutput()
+		if err := c.Export(ctx, w); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+
+	case "import":
+		var r io.Reader = os.Stdin
+		if *input != "" {
+			file, err := os.Open(*input)
+			if err != nil {
+				log.Fatalf("Failed to open %s: %v", *input, err)
+			}
+			defer file.Close()
+			r = file
+		}
+		result, err := c.Import(ctx, r, *overwrite)
+		if err != nil {
+			log.Fatalf("Import failed after %d records: %v", result.Imported, err)
+		}
+		fmt.Fprintf(os.Stderr, "Imported %d records, skipped %d existing\n", result.Imported, result.Skipped)
+
+	case "keys":
+		listed, token := 0, ""
+		for {
+			list, err := c.ListKeys(ctx, *prefix, 0, token)
+			if err != nil {
+				log.Fatalf("Listing keys failed: %v", err)
+			}
+			for _, key := range list.Keys {
+				if *limit > 0 && listed == *limit {
+					return
+				}
+				fmt.Printf("%s\t%d\t%s\n", key.Key, key.SizeBytes, key.StoredAt.Format(time.RFC3339))
+				listed++
+			}
+			if list.NextToken == "" {
+				return
+			}
+			token = list.NextToken
+		}
+
+	case "keyspace":
+		if *format != "json" && *format != "csv" {
+			log.Fatal(usage)
+		}
+		h, err := c.Keyspace(ctx, *bins)
+		if err != nil {
+			log.Fatalf("Keyspace histogram failed: %v", err)
+		}
+		w, closeOutput := create(*output)
+		defer closeOutput()
+		if *format == "csv" {
+			err = h.WriteCSV(w)
+		} else {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(h)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write histogram: %v", err)
+		}
+
+	case "jobs", "pause", "resume":
+		list := c.Jobs
+		switch os.Args[1] {
+		case "pause":
+			list = func(ctx context.Context) ([]kademlia.JobStatus, error) { return c.PauseJobs(ctx, *job) }
+		case "resume":
+			list = func(ctx context.Context) ([]kademlia.JobStatus, error) { return c.ResumeJobs(ctx, *job) }
+		}
+		jobs, err := list(ctx)
+		if err != nil {
+			log.Fatalf("Scheduling request failed: %v", err)
+		}
+		for _, j := range jobs {
+			state := "waiting"
+			switch {
+			case j.Running:
+				state = "running"
+			case j.Paused:
+				state = "paused"
+			}
+			fmt.Printf("%s\t%s\t%d runs\tnext %s\n", j.Name, state, j.Runs, j.NextRun.Format(time.RFC3339))
+		}
+
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// create opens the output file at path, or stdout if path is empty, and
+// returns it with the function closing it
+func create(path string) (io.Writer, func()) {
+	if path == "" {
+		return os.Stdout, func() {}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", path, err)
+	}
+	return file, func() { file.Close() }
+}
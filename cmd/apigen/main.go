@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/openapi"
+)
+
+func main() {
+	log.SetFlags(0)
+	out := flag.String("o", "", "Write the generated Go client to this file instead of stdout")
+	pkg := flag.String("pkg", "apiclient", "Package name of the generated client")
+	spec := flag.String("spec", "", "Also write the OpenAPI document as JSON to this file")
+	flag.Parse()
+
+	doc := kademlia.OpenAPI()
+	if *spec != "" {
+		data, err := doc.JSON()
+		if err != nil {
+			log.Fatalf("Failed to encode OpenAPI document: %v", err)
+		}
+		if err := os.WriteFile(*spec, append(data, '\n'), 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", *spec, err)
+		}
+	}
+
+	src, err := openapi.GenerateClient(doc, *pkg, "cmd/apigen")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+}
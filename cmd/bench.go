@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/Aradhya2708/kademlia/internals/bench"
+)
+
+// benchmark drives a put/get workload against the network through a
+// short-lived client-only node and reports latencies and throughput
+func benchmark(ctx context.Context, args []string) error {
+	w := bench.DefaultWorkload()
+	flags := newFlagSet("bench", "--bootstrap <address> [flags]", os.Stderr)
+	bootstrap := flags.String("bootstrap", "", "`address` of a node to join the network through (required)")
+	flags.IntVar(&w.Keys, "keys", w.Keys, "distinct keys, stored once before the timed run")
+	flags.IntVar(&w.ValueSize, "value-size", w.ValueSize, "bytes per value")
+	flags.IntVar(&w.Concurrency, "concurrency", w.Concurrency, "operations in flight at once")
+	flags.Float64Var(&w.ReadRatio, "reads", w.ReadRatio, "share of operations that are gets, from 0 to 1")
+	flags.IntVar(&w.Ops, "ops", w.Ops, "operations in the timed run")
+	flags.DurationVar(&w.Duration, "duration", 0, "run for this long instead of --ops operations")
+	flags.StringVar(&w.Prefix, "prefix", w.Prefix, "hashed into the keys, so runs can use separate keys")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	verbose := flags.Bool("v", false, "log the RPCs made")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	out, restore := quietOutput(*verbose)
+	defer restore()
+
+	node, err := joinAsClient(ctx, *bootstrap)
+	if err != nil {
+		return err
+	}
+	defer node.Stop()
+
+	report, err := bench.Run(ctx, node, w)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(out)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Command is a subcommand of the kademlia CLI. Run is given the arguments
+// after the command name and a context cancelled on SIGINT or SIGTERM.
+type Command struct {
+	Name    string
+	Summary string
+	Run     func(ctx context.Context, args []string) error
+}
+
+// Commands lists the subcommands of the kademlia CLI
+var Commands = []Command{
+	{Name: "serve", Summary: "Run a node (the default when no command is given)", Run: serve},
+	{Name: "put", Summary: "Store a value on the network through a bootstrap node", Run: put},
+	{Name: "get", Summary: "Look a value up on the network through a bootstrap node", Run: get},
+	{Name: "crawl", Summary: "Map the network reachable from seed nodes", Run: crawl},
+	{Name: "bench", Summary: "Measure put and get latency and throughput against the network", Run: benchmark},
+	{Name: "doctor", Summary: "Check ports, bootstrap nodes, clock and data directory before serving", Run: diagnose},
+}
+
+// Main runs the kademlia CLI with args, the command line without the
+// program name, and returns the exit status. Arguments that do not start
+// with a command name are those of serve, so the flags and positional
+// arguments of the original single-command CLI keep working.
+func Main(args []string) int {
+	command, args, err := lookupCommand(args)
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(os.Stdout)
+		return 0
+	}
+	if err != nil {
+		log.Print(err)
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = command.Run(ctx, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if errors.Is(err, errUsage) {
+		return 2
+	}
+	if err != nil {
+		log.Printf("%s: %v", command.Name, err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage is returned by commands whose arguments were rejected after
+// their usage was printed
+var errUsage = errors.New("invalid usage")
+
+// usageError returns the error of a command whose flags failed to parse:
+// flag.ErrHelp for --help, otherwise errUsage, as the flag set has
+// already printed the problem and the usage
+func usageError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return errUsage
+}
+
+// lookupCommand splits args into the command they name and its arguments
+func lookupCommand(args []string) (Command, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelp(args[0]) || isNumber(args[0]) {
+		return Commands[0], args, nil
+	}
+	if isHelp(args[0]) {
+		return Command{}, nil, flag.ErrHelp
+	}
+	for _, command := range Commands {
+		if command.Name == args[0] {
+			return command, args[1:], nil
+		}
+	}
+	return Command{}, nil, fmt.Errorf("unknown command %q", args[0])
+}
+
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func isNumber(arg string) bool {
+	return arg != "" && strings.Trim(arg, "0123456789") == ""
+}
+
+// printUsage lists the commands to w
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: kademlia <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, command := range Commands {
+		fmt.Fprintf(w, "  %-6s %s\n", command.Name, command.Summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "kademlia <command> --help" for the flags of a command.`)
+}
+
+// newFlagSet returns a flag set for the command name whose usage shows
+// synopsis followed by its flags
+func newFlagSet(name, synopsis string, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintf(output, "Usage: kademlia %s %s\n\nFlags:\n", name, synopsis)
+		flags.PrintDefaults()
+	}
+	return flags
+}
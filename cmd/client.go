@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// put stores a value through a short-lived client-only node
+func put(ctx context.Context, args []string) error {
+	flags := newFlagSet("put", "--bootstrap <address> [--hash] [-v] <key> <value>", os.Stderr)
+	bootstrap := flags.String("bootstrap", "", "`address` of a node to join the network through (required)")
+	hash := flags.Bool("hash", false, "hash the key, so any string can be used, instead of requiring a hex ID")
+	verbose := flags.Bool("v", false, "log the RPCs made")
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+	out, restore := quietOutput(*verbose)
+	defer restore()
+
+	node, err := joinAsClient(ctx, *bootstrap)
+	if err != nil {
+		return err
+	}
+	defer node.Stop()
+
+	key := commandKey(flags.Arg(0), *hash)
+	ack, err := node.Put(ctx, key, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ack)
+}
+
+// get looks a value up through a short-lived client-only node and prints
+// it
+func get(ctx context.Context, args []string) error {
+	flags := newFlagSet("get", "--bootstrap <address> [--hash] [-v] <key>", os.Stderr)
+	bootstrap := flags.String("bootstrap", "", "`address` of a node to join the network through (required)")
+	hash := flags.Bool("hash", false, "hash the key, as given to put --hash")
+	verbose := flags.Bool("v", false, "log the RPCs made")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	out, restore := quietOutput(*verbose)
+	defer restore()
+
+	node, err := joinAsClient(ctx, *bootstrap)
+	if err != nil {
+		return err
+	}
+	defer node.Stop()
+
+	key := commandKey(flags.Arg(0), *hash)
+	value, found, err := node.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("key %s not found", key)
+	}
+	fmt.Fprintln(out, value)
+	return nil
+}
+
+// quietOutput silences logging and the progress messages nodes print to
+// standard output unless verbose. It returns the real standard output, for
+// the command's result, and a function restoring both.
+func quietOutput(verbose bool) (io.Writer, func()) {
+	stdout := os.Stdout
+	if verbose {
+		return stdout, func() {}
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return stdout, func() {}
+	}
+	log.SetOutput(io.Discard)
+	os.Stdout = devNull
+	return stdout, func() {
+		os.Stdout = stdout
+		log.SetOutput(os.Stderr)
+		devNull.Close()
+	}
+}
+
+// parseFlags parses args into flags, requiring a bootstrap address and
+// exactly positional arguments after the flags
+func parseFlags(flags *flag.FlagSet, args []string, positional int) error {
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+	if flags.Lookup("bootstrap").Value.String() == "" || flags.NArg() != positional {
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// joinAsClient starts a client-only node on a free port and joins the
+// network through bootstrap, authenticating with KADEMLIA_AUTH_TOKEN
+func joinAsClient(ctx context.Context, bootstrap string) (*kademlia.Node, error) {
+	cfg := config.Default()
+	cfg.Bootstrap = bootstrap
+	cfg.ClientOnly = true
+	cfg.Server.AuthToken = os.Getenv("KADEMLIA_AUTH_TOKEN")
+	network.SetAuthToken(cfg.Server.AuthToken)
+
+	node := kademlia.NewNode(cfg)
+	if err := node.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to join the network through %s: %v", bootstrap, err)
+	}
+	return node, nil
+}
+
+// commandKey returns key, hashed into a key ID if hash is set
+func commandKey(key string, hash bool) string {
+	if hash {
+		return kademlia.KeyFromString(key)
+	}
+	return key
+}
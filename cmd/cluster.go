@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// StartCluster starts size nodes in this process on sequential ports from
+// cfg.Port. Only the first node joins cfg.Bootstrap, if set; every later
+// node joins through each node started before it, so all nodes know each
+// other. Each node keeps persistent storage at cfg.Storage.Path suffixed
+// with its index. On error the nodes already started are stopped.
+func StartCluster(ctx context.Context, cfg *config.Config, size int) ([]*kademlia.Node, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("cluster size must be positive, got %d", size)
+	}
+
+	nodes := make([]*kademlia.Node, 0, size)
+	stopAll := func() {
+		for _, n := range nodes {
+			n.Stop()
+		}
+	}
+
+	for i := 0; i < size; i++ {
+		nodeCfg := *cfg
+		nodeCfg.NodeID = ""
+		if cfg.Port != 0 {
+			nodeCfg.Port = cfg.Port + i
+		}
+		if i > 0 {
+			nodeCfg.Bootstrap = ""
+		}
+		nodeCfg.Mainline.Port = 0
+		if cfg.Storage.Path != "" {
+			nodeCfg.Storage.Path = fmt.Sprintf("%s.%d", cfg.Storage.Path, i)
+		}
+
+		node := kademlia.NewNode(&nodeCfg)
+		if err := node.Start(ctx); err != nil {
+			stopAll()
+			return nil, fmt.Errorf("failed to start node %d: %v", i, err)
+		}
+		nodes = append(nodes, node)
+
+		for j, peer := range nodes[:i] {
+			if err := kademlia.JoinNetwork(ctx, node.Self, node.RoutingTable, peer.Addr()); err != nil {
+				stopAll()
+				return nil, fmt.Errorf("node %d failed to join node %d: %v", i, j, err)
+			}
+		}
+		log.Printf("Cluster node %d started: ID=%s, Addr=%s\n", i, node.Self.ID, node.Addr())
+	}
+
+	return nodes, nil
+}
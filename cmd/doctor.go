@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Aradhya2708/kademlia/internals/doctor"
+)
+
+const doctorUsageHeader = `Usage: kademlia doctor [flags]
+
+Checks that a node given the same flags and environment as serve could
+start and join the network: its ports are free, its data directory is
+writable, the clock is sane and the bootstrap nodes are reachable and
+compatible. Exits 1 if a check fails.
+
+Flags:
+`
+
+// diagnose runs the startup checks of doctor for the node serve would run
+// with args and prints them with hints for what failed
+func diagnose(ctx context.Context, args []string) error {
+	opts, err := parseOptions("doctor", doctorUsageHeader, args, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	report := &doctor.Report{}
+	cfg, err := opts.Config()
+	if err != nil {
+		report.Add(doctor.Result{Check: "config", Status: doctor.Fail, Detail: err.Error(),
+			Hint: "fix the flag, KADEMLIA_* variable or config file line named"})
+	} else {
+		configureProcess(cfg)
+		// Bind the addresses serve binds
+		cfg.Host = ""
+		report = doctor.Run(ctx, cfg)
+	}
+
+	if err := report.Write(os.Stdout); err != nil {
+		return err
+	}
+	if report.Failed() {
+		return errors.New("some checks failed")
+	}
+	fmt.Println("The node should start and join the network.")
+	return nil
+}
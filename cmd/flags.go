@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// Options are the node settings given on the command line. Settings not
+// given keep the value from the environment or the config file.
+type Options struct {
+	Port       int
+	Bootstrap  string
+	Network    string
+	DataDir    string
+	ConfigFile string
+	LogLevel   string
+	K          int
+	Alpha      int
+
+	Cluster int
+	Chaos   bool
+	Client  bool
+	Pprof   bool
+
+	// Deprecated is set when the port or bootstrap address was given as a
+	// positional argument, the CLI's original form
+	Deprecated bool
+
+	set map[string]bool
+}
+
+const usageHeader = `Usage: kademlia serve [flags]
+
+Runs a Kademlia DHT node. Flags override KADEMLIA_* environment variables,
+which override the config file.
+
+Flags:
+`
+
+// ParseArgs parses the arguments of serve, writing usage and errors to
+// output. It returns flag.ErrHelp for --help. The original form
+// "<port> [<bootstrap>]" is still accepted, with Deprecated set.
+func ParseArgs(args []string, output io.Writer) (*Options, error) {
+	return parseOptions("kademlia", usageHeader, args, output)
+}
+
+// parseOptions parses the node flags of the command name, whose usage
+// starts with header
+func parseOptions(name, header string, args []string, output io.Writer) (*Options, error) {
+	opts := &Options{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprint(output, header)
+		flags.PrintDefaults()
+	}
+
+	flags.IntVar(&opts.Port, "port", 0, "port to serve RPCs on (required)")
+	flags.StringVar(&opts.Bootstrap, "bootstrap", "", "`address` to join through: ip:port, host:port, a DNS seed name or a comma separated list of them")
+	flags.StringVar(&opts.Network, "network", "", "`ID` of the network to join, e.g. testnet; peers of other networks are refused (default the default network)")
+	flags.StringVar(&opts.DataDir, "data-dir", "", "`directory` to keep stored values in, switching the memory backend to the file backend")
+	flags.StringVar(&opts.ConfigFile, "config", "", "`file` of KADEMLIA_<NAME>=<value> lines read before the environment")
+	flags.StringVar(&opts.LogLevel, "log-level", "", "debug, info or warn (default info)")
+	flags.IntVar(&opts.K, "k", 0, "bucket size and number of replicas per key (default 20)")
+	flags.IntVar(&opts.Alpha, "alpha", 0, "contacts queried in parallel per lookup round (default 3)")
+	flags.IntVar(&opts.Cluster, "cluster", 0, "run N nodes in this process on sequential ports starting at --port")
+	flags.BoolVar(&opts.Chaos, "chaos", false, "inject faults configured by KADEMLIA_CHAOS_* into RPC handling (testing only)")
+	flags.BoolVar(&opts.Client, "client", false, "look up, get and put values without storing records for other nodes")
+	flags.BoolVar(&opts.Pprof, "pprof", false, "serve net/http/pprof profiles under /debug/pprof/ (admin only)")
+	if err := flags.Parse(args); err != nil {
+		return nil, usageError(err)
+	}
+
+	opts.set = make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { opts.set[f.Name] = true })
+
+	positional := flags.Args()
+	if len(positional) > 2 {
+		return nil, fmt.Errorf("unexpected arguments: %v", positional[2:])
+	}
+	if len(positional) > 0 {
+		if opts.set["port"] {
+			return nil, fmt.Errorf("port given both as --port and as argument %q", positional[0])
+		}
+		port, err := strconv.Atoi(positional[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %v", positional[0])
+		}
+		opts.Port = port
+		opts.set["port"] = true
+		opts.Deprecated = true
+	}
+	if len(positional) > 1 {
+		if opts.set["bootstrap"] {
+			return nil, fmt.Errorf("bootstrap given both as --bootstrap and as argument %q", positional[1])
+		}
+		opts.Bootstrap = positional[1]
+		opts.set["bootstrap"] = true
+	}
+	return opts, nil
+}
+
+// Apply overrides cfg with the settings given on the command line,
+// creating the data directory if one was given, and validates the result
+func (o *Options) Apply(cfg *config.Config) error {
+	if o.set["port"] {
+		cfg.Port = o.Port
+	}
+	if o.set["bootstrap"] {
+		cfg.Bootstrap = o.Bootstrap
+	}
+	if o.set["network"] {
+		cfg.NetworkID = o.Network
+	}
+	if o.set["log-level"] {
+		cfg.LogLevel = o.LogLevel
+	}
+	if o.set["k"] {
+		cfg.K = o.K
+	}
+	if o.set["alpha"] {
+		cfg.Alpha = o.Alpha
+	}
+	if o.Client {
+		cfg.ClientOnly = true
+	}
+	if o.Pprof {
+		cfg.Server.Pprof = true
+	}
+	cfg.Chaos.Enabled = o.Chaos
+
+	if o.DataDir != "" {
+		if err := os.MkdirAll(o.DataDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create data directory: %v", err)
+		}
+		name := "kademlia.log"
+		switch cfg.Storage.Backend {
+		case "", "memory":
+			cfg.Storage.Backend = "file"
+		case "sqlite":
+			name = "kademlia.db"
+		}
+		cfg.Storage.Path = filepath.Join(o.DataDir, name)
+	}
+	return cfg.Validate()
+}
+
+// Config loads the node configuration: the config file given by
+// --config, then KADEMLIA_* environment variables, then the flags
+func (o *Options) Config() (*config.Config, error) {
+	if o.ConfigFile != "" {
+		if err := config.LoadEnvFile(o.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := config.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Apply(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SetLogLevel configures the process's output for level, as described at
+// config.Config.LogLevel
+func SetLogLevel(level string) error {
+	switch level {
+	case "debug":
+		log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	case "", "info":
+	case "warn":
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		os.Stdout = devNull
+	default:
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	return nil
+}
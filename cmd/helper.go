@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"syscall"
 
+	"github.com/Aradhya2708/kademlia/internals/chaos"
 	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/config"
 	"github.com/Aradhya2708/kademlia/pkg/models"
 )
 
@@ -20,25 +28,127 @@ func InitializeNode(port int) *models.Node {
 		ID:   nodeID,
 		IP:   "127.0.0.1", // Assuming localhost for now
 		Port: port,
+
+		Flags:    models.DefaultFlags,
+		Protocol: models.ProtocolVersion,
 	}
 
 	log.Printf("Initialized node: ID=%s, IP=%s, Port=%d\n", node.ID, node.IP, node.Port)
 	return node
 }
 
-func StartServer(node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, port int) {
-	http.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
-		kademlia.PingHandler(w, r, node, storage, routingTable)
-	})
-	http.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
-		kademlia.FindNodeHandler(w, r, node, routingTable)
-	})
-	http.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
-		kademlia.StoreHandler(w, r, node, storage, routingTable)
-	})
-	http.HandleFunc("/find_value", func(w http.ResponseWriter, r *http.Request) {
-		kademlia.FindValueHandler(w, r, node, storage, routingTable)
-	})
-
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+// StartServer starts serving Kademlia RPCs on port using a dedicated
+// http.Server and mux. It returns once the port is bound; the caller stops
+// the server with Shutdown. Every RPC runs through the middleware chain
+// configured by cfg.Server followed by mws, and faults are injected into
+// every RPC when cfg.Chaos is enabled. The handler sets of
+// cfg.Server.Listeners, and the admin endpoints when cfg.Server.AdminAddr
+// is set, are served by servers of their own on their addresses and shut
+// down along with the returned server.
+func StartServer(node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, port int, cfg *config.Config, mws ...middleware.Middleware) (*http.Server, error) {
+	pubsub := kademlia.NewPubSub()
+	watches := kademlia.NewWatches(storage)
+	pubsub.Webhooks = kademlia.NewWebhooks(cfg.WebhookPrivate)
+	watches.Webhooks = pubsub.Webhooks
+	providers := kademlia.NewProviderStore()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, fmt.Errorf("port %d is already in use", port)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %d: %v", port, err)
+	}
+
+	metrics := middleware.NewMetrics()
+	extra := mws
+	mws = append(middleware.Default(cfg.Server, metrics), extra...)
+	// Gateway clients authenticate with API keys, not the network's token
+	public := cfg.Server
+	public.AuthToken = ""
+	gatewayMws := append(middleware.Default(public, metrics), extra...)
+	var audit *middleware.AuditLog
+	if cfg.Server.AuditLog != "" {
+		audit, err = middleware.OpenAuditLog(cfg.Server.AuditLog, cfg.Server.AuditMaxBytes, cfg.Server.AuditBackups)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		mws = append([]middleware.Middleware{audit.Middleware()}, mws...)
+		gatewayMws = append([]middleware.Middleware{audit.Middleware()}, gatewayMws...)
+	}
+	var puncher *kademlia.HolePuncher
+	if cfg.PunchPort > 0 {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: cfg.PunchPort})
+		if err != nil {
+			listener.Close()
+			if audit != nil {
+				audit.Close()
+			}
+			return nil, fmt.Errorf("failed to listen for hole punching on port %d: %v", cfg.PunchPort, err)
+		}
+		puncher = kademlia.NewHolePuncher(node.ID, conn)
+	}
+
+	relay := kademlia.NewRelay()
+	scheduler := kademlia.NewScheduler(kademlia.SchedulerConfig{Jitter: cfg.Scheduler.Jitter, MaxConcurrent: cfg.Scheduler.MaxConcurrent})
+	scheduler.Add(kademlia.RuntimeSamplerJob(metrics))
+	scheduler.Add(kademlia.ProviderSweepJob(providers))
+	sets := kademlia.HandlerSets{
+		config.HandlersRPC: func(mux *http.ServeMux) {
+			kademlia.RegisterRPC(mux, node, routingTable, storage, providers, pubsub, watches, relay, puncher, mws...)
+		},
+		config.HandlersAdmin: func(mux *http.ServeMux) {
+			mux.HandleFunc("/rpc_stats", tracing.Middleware("rpc_stats", node.ID, middleware.Chain(mws...)("rpc_stats", metrics.Handler)))
+			mux.HandleFunc("/runtime_stats", tracing.Middleware("runtime_stats", node.ID, middleware.Chain(mws...)("runtime_stats", metrics.RuntimeHandler)))
+			kademlia.RegisterStats(mux, node, routingTable, storage, mws...)
+			if cfg.Server.Pprof {
+				middleware.RegisterProfiling(mux, mws...)
+			}
+			if cfg.Server.AdminToken != "" {
+				kademlia.RegisterAdmin(mux, node, routingTable, storage, scheduler, cfg.Server.AdminToken, cfg.GC.TTL, mws...)
+			}
+		},
+	}
+	if cfg.Server.Gateway {
+		sets[config.HandlersGateway] = func(mux *http.ServeMux) {
+			kademlia.RegisterGateway(mux, node, routingTable, storage, cfg.Server.GatewayAPIKeys, gatewayMws...)
+		}
+	}
+	wrap := func(h http.Handler) http.Handler {
+		return middleware.CORS(cfg.Server, middleware.Limits(cfg.Server, middleware.Compress(cfg.Server, chaos.Middleware(cfg.Chaos, h))))
+	}
+	listeners, err := kademlia.ServeListeners(cfg.Server.AllListeners(), sets, wrap)
+	if err != nil {
+		listener.Close()
+		if audit != nil {
+			audit.Close()
+		}
+		if puncher != nil {
+			puncher.Close()
+		}
+		return nil, err
+	}
+	background, stopBackground := context.WithCancel(context.Background())
+	go scheduler.Start(background)
+
+	server := &http.Server{Handler: wrap(sets.Mux(cfg.Server.PortHandlers()))}
+	for _, l := range listeners {
+		server.RegisterOnShutdown(func() { l.Shutdown(context.Background()) })
+	}
+	server.RegisterOnShutdown(relay.Close)
+	server.RegisterOnShutdown(stopBackground)
+	if audit != nil {
+		server.RegisterOnShutdown(func() { audit.Close() })
+	}
+	if puncher != nil {
+		server.RegisterOnShutdown(func() { puncher.Close() })
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Server stopped: %v", err)
+		}
+	}()
+
+	return server, nil
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Aradhya2708/kademlia/internals/krpc"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// StartMainline starts the BitTorrent Mainline DHT transport for nodeID on
+// cfg.Port and joins the Mainline DHT through cfg.Routers in the
+// background. The caller stops it with Close.
+func StartMainline(nodeID string, cfg config.MainlineConfig) (*krpc.Server, error) {
+	server := krpc.NewServer(nodeID)
+	if err := server.Listen(fmt.Sprintf(":%d", cfg.Port)); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := server.Bootstrap(context.Background(), cfg.Routers); err != nil {
+			log.Printf("Mainline DHT: %v", err)
+			return
+		}
+		log.Printf("Joined the Mainline DHT on UDP port %d\n", cfg.Port)
+	}()
+
+	return server, nil
+}
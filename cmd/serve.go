@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+)
+
+// serve runs a node, or a local cluster with --cluster, until ctx is
+// cancelled
+func serve(ctx context.Context, args []string) error {
+	opts, err := ParseArgs(args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if opts.Deprecated {
+		log.Println("WARNING: positional <port> [<bootstrap>] arguments are deprecated, use --port and --bootstrap")
+	}
+
+	fmt.Println("Welcome to Kademlia Distributed Hash Table (DHT) Node!")
+
+	cfg, err := opts.Config()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	if cfg.Port <= 0 {
+		return errors.New("--port is required, see kademlia serve --help")
+	}
+	if err := SetLogLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	if cfg.Server.Pprof && cfg.Server.AuthToken == "" {
+		log.Println("WARNING: pprof endpoints enabled without KADEMLIA_AUTH_TOKEN, anyone who can reach the node can profile it")
+	}
+	if cfg.Chaos.Enabled {
+		log.Println("WARNING: chaos mode enabled, RPCs will be delayed, dropped and corrupted")
+	}
+
+	configureProcess(cfg)
+
+	// Set up OpenTelemetry tracing of RPCs
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if opts.Cluster > 0 {
+		return runCluster(ctx, cfg, opts.Cluster)
+	}
+
+	// Listen on every interface and advertise the configured host, join
+	// in the background while serving
+	if cfg.Advertise == "" {
+		cfg.Advertise = cfg.Host
+	}
+	cfg.Host = ""
+	cfg.Join.Background = true
+
+	log.Printf("Starting Kademlia node on port %d...\n", cfg.Port)
+	node := kademlia.NewNode(cfg)
+	defer node.Storage.Close()
+	if err := node.Start(ctx); err != nil {
+		return err
+	}
+	log.Printf("Node initialized: ID=%s, IP=%s, Port=%d\n", node.Self.ID, node.Self.IP, node.Self.Port)
+
+	// Optionally speak KRPC so the node also joins the BitTorrent DHT
+	if cfg.Mainline.Port > 0 {
+		dht, err := StartMainline(node.Self.ID, cfg.Mainline)
+		if err != nil {
+			node.Stop()
+			return fmt.Errorf("failed to start Mainline DHT transport: %v", err)
+		}
+		defer dht.Close()
+	}
+
+	if cfg.Bootstrap == "" {
+		log.Println("No bootstrap address provided. Running in standalone mode.")
+		log.Println("This node is the starting point of a new network.")
+	}
+
+	// Serve until interrupted, then let in-flight RPCs finish
+	<-ctx.Done()
+
+	log.Println("Shutting down...")
+	if err := node.Stop(); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %v", err)
+	}
+	return nil
+}
+
+// configureProcess applies the settings of cfg that are shared by every
+// node in the process
+func configureProcess(cfg *config.Config) {
+	if cfg.Seed != 0 {
+		log.Printf("Deterministic mode: node IDs and lookups seeded with %d", cfg.Seed)
+		kademlia.SetSeed(cfg.Seed)
+	}
+	constants.SetK(cfg.K)
+	constants.SetAlpha(cfg.Alpha)
+	constants.SetRPCTimeout(cfg.RPCTimeout)
+	network.Configure(cfg.Pool)
+	retry.Configure(cfg.Retry)
+	network.SetAuthToken(cfg.Server.AuthToken)
+}
+
+// runCluster starts a local test network of size nodes and serves until
+// ctx is cancelled
+func runCluster(ctx context.Context, cfg *config.Config, size int) error {
+	if cfg.Port+size-1 > 65535 {
+		return fmt.Errorf("cluster of %d nodes does not fit above port %d", size, cfg.Port)
+	}
+
+	nodes, err := StartCluster(ctx, cfg, size)
+	if err != nil {
+		return fmt.Errorf("failed to start cluster: %v", err)
+	}
+	log.Printf("Cluster of %d nodes running on ports %d-%d\n", size, cfg.Port, cfg.Port+size-1)
+
+	<-ctx.Done()
+
+	log.Println("Shutting down cluster...")
+	for _, n := range nodes {
+		if err := n.Stop(); err != nil {
+			log.Printf("Failed to stop node %s: %v", n.Self.ID, err)
+		}
+	}
+	return nil
+}
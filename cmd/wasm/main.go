@@ -0,0 +1,112 @@
+//go:build js && wasm
+
+// Command wasm exposes pkg/client to JavaScript when built for WebAssembly:
+//
+//	GOOS=js GOARCH=wasm go build -o kademlia.wasm ./cmd/wasm
+//
+// Once loaded with wasm_exec.js it defines kademlia.newClient(addr), whose
+// put, get and findNode methods return Promises. Requests go through the
+// browser's Fetch API to addr, a gateway node started with
+// KADEMLIA_CORS_ORIGINS allowing the page's origin.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/Aradhya2708/kademlia/pkg/client"
+)
+
+func main() {
+	js.Global().Set("kademlia", js.ValueOf(map[string]interface{}{
+		"newClient": js.FuncOf(newClient),
+	}))
+	select {}
+}
+
+// newClient implements kademlia.newClient(addr), where addr is the gateway
+// as ip:port or as a URL such as "https://gateway.example.com"
+func newClient(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		return throw("newClient: addr must be a string")
+	}
+	c := client.NewClient(args[0].String())
+
+	return js.ValueOf(map[string]interface{}{
+		// put(key, value, {hash}) resolves to the store ack
+		"put": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 2 {
+				return throw("put: key and value are required")
+			}
+			key, value, hash := args[0].String(), args[1].String(), hashOption(args, 2)
+			return promise(func(ctx context.Context) (interface{}, error) {
+				return c.Store(ctx, key, value, hash)
+			})
+		}),
+		// get(key, {hash}) resolves to {found, value} or {found, closest}
+		"get": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 1 {
+				return throw("get: key is required")
+			}
+			key, hash := args[0].String(), hashOption(args, 1)
+			return promise(func(ctx context.Context) (interface{}, error) {
+				value, found, closest, err := c.Get(ctx, key, hash)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					return map[string]interface{}{"found": true, "value": value}, nil
+				}
+				return map[string]interface{}{"found": false, "closest": closest}, nil
+			})
+		}),
+		// findNode(id) resolves to the closest contacts the gateway knows
+		"findNode": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 1 {
+				return throw("findNode: id is required")
+			}
+			id := args[0].String()
+			return promise(func(ctx context.Context) (interface{}, error) {
+				return c.FindNode(ctx, id)
+			})
+		}),
+	})
+}
+
+// hashOption reads the hash field of the options object at args[i], if any
+func hashOption(args []js.Value, i int) bool {
+	if len(args) <= i || args[i].Type() != js.TypeObject {
+		return false
+	}
+	return args[i].Get("hash").Truthy()
+}
+
+// promise runs call on its own goroutine, since blocking in a callback
+// would deadlock the event loop the Fetch API needs, and returns a Promise
+// resolved with its result as a plain JS object or rejected with an Error
+func promise(call func(ctx context.Context) (interface{}, error)) js.Value {
+	var executor js.Func
+	executor = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			defer executor.Release()
+			result, err := call(context.Background())
+			if err == nil {
+				var data []byte
+				if data, err = json.Marshal(result); err == nil {
+					resolve.Invoke(js.Global().Get("JSON").Call("parse", string(data)))
+					return
+				}
+			}
+			reject.Invoke(js.Global().Get("Error").New(err.Error()))
+		}()
+		return nil
+	})
+	return js.Global().Get("Promise").New(executor)
+}
+
+// throw returns a Promise rejected with an Error carrying msg
+func throw(msg string) js.Value {
+	return js.Global().Get("Promise").Call("reject", js.Global().Get("Error").New(msg))
+}
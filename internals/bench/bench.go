@@ -0,0 +1,270 @@
+package bench
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+)
+
+// Client is the DHT a benchmark drives; *kademlia.Node implements it
+type Client interface {
+	Put(ctx context.Context, key, value string) (kademlia.StoreAck, error)
+	Get(ctx context.Context, key string) (string, bool, error)
+}
+
+// Workload describes the operations of a benchmark run
+type Workload struct {
+	Keys        int           `json:"keys"`        // Distinct keys, all stored before the timed run
+	ValueSize   int           `json:"value_size"`  // Bytes per value
+	Concurrency int           `json:"concurrency"` // Operations in flight at once
+	ReadRatio   float64       `json:"read_ratio"`  // Share of operations that are gets, from 0 to 1
+	Ops         int           `json:"ops"`         // Operations in the timed run, unless Duration is set
+	Duration    time.Duration `json:"duration"`    // Length of the timed run, 0 to run Ops operations
+	Prefix      string        `json:"prefix"`      // Hashed with the key index into each key
+}
+
+// DefaultWorkload returns a read-heavy workload of 1000 operations over 100
+// keys
+func DefaultWorkload() Workload {
+	return Workload{
+		Keys:        100,
+		ValueSize:   128,
+		Concurrency: 8,
+		ReadRatio:   0.8,
+		Ops:         1000,
+		Prefix:      "bench",
+	}
+}
+
+// Validate reports settings that cannot be run
+func (w Workload) Validate() error {
+	switch {
+	case w.Keys <= 0:
+		return fmt.Errorf("keys must be positive, got %d", w.Keys)
+	case w.ValueSize < 0:
+		return fmt.Errorf("value size must not be negative, got %d", w.ValueSize)
+	case w.Concurrency <= 0:
+		return fmt.Errorf("concurrency must be positive, got %d", w.Concurrency)
+	case w.ReadRatio < 0 || w.ReadRatio > 1:
+		return fmt.Errorf("read ratio must be between 0 and 1, got %v", w.ReadRatio)
+	case w.Duration < 0:
+		return fmt.Errorf("duration must not be negative, got %v", w.Duration)
+	case w.Duration == 0 && w.Ops <= 0:
+		return fmt.Errorf("ops must be positive without a duration, got %d", w.Ops)
+	}
+	return nil
+}
+
+// Stats summarizes the latencies of one kind of operation
+type Stats struct {
+	Count  int           `json:"count"`
+	Errors int           `json:"errors"`
+	Misses int           `json:"misses,omitempty"` // Gets that found no value
+	Mean   time.Duration `json:"mean"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// Report is the result of a benchmark run. Load covers storing the keys
+// before the timed run, Put and Get the operations of the run itself.
+type Report struct {
+	Workload   Workload      `json:"workload"`
+	Load       Stats         `json:"load"`
+	Put        Stats         `json:"put"`
+	Get        Stats         `json:"get"`
+	Ops        int           `json:"ops"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Throughput float64       `json:"throughput"` // Operations per second of the timed run
+}
+
+// Run stores the workload's keys through client, then runs its mix of puts
+// and gets against them and reports their latencies. It stops early, with
+// the operations done so far, if ctx is cancelled.
+func Run(ctx context.Context, client Client, w Workload) (*Report, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	keys := make([]string, w.Keys)
+	for i := range keys {
+		keys[i] = kademlia.KeyFromString(fmt.Sprintf("%s-%d", w.Prefix, i))
+	}
+	report := &Report{Workload: w}
+
+	// Store every key once so gets have something to find
+	var next int64 = -1
+	load := &recorder{}
+	parallel(w.Concurrency, func(worker int) {
+		for ctx.Err() == nil {
+			i := atomic.AddInt64(&next, 1)
+			if i >= int64(len(keys)) {
+				return
+			}
+			load.put(ctx, client, keys[i], value(w.ValueSize))
+		}
+	})
+	report.Load = load.puts.stats()
+
+	var issued int64
+	var deadline time.Time
+	if w.Duration > 0 {
+		deadline = time.Now().Add(w.Duration)
+	}
+	run := &recorder{}
+	start := time.Now()
+	parallel(w.Concurrency, func(worker int) {
+		rng := mathrand.New(mathrand.NewSource(time.Now().UnixNano() + int64(worker)))
+		for ctx.Err() == nil {
+			if w.Duration > 0 {
+				if !time.Now().Before(deadline) {
+					return
+				}
+			} else if atomic.AddInt64(&issued, 1) > int64(w.Ops) {
+				return
+			}
+			key := keys[rng.Intn(len(keys))]
+			if rng.Float64() < w.ReadRatio {
+				run.get(ctx, client, key)
+			} else {
+				run.put(ctx, client, key, value(w.ValueSize))
+			}
+		}
+	})
+	report.Elapsed = time.Since(start)
+
+	report.Put = run.puts.stats()
+	report.Get = run.gets.stats()
+	report.Ops = report.Put.Count + report.Get.Count
+	if report.Elapsed > 0 {
+		report.Throughput = float64(report.Ops) / report.Elapsed.Seconds()
+	}
+	return report, nil
+}
+
+// parallel runs fn on n goroutines and waits for them
+func parallel(n int, fn func(worker int)) {
+	var wg sync.WaitGroup
+	for worker := 0; worker < n; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			fn(worker)
+		}(worker)
+	}
+	wg.Wait()
+}
+
+// value returns size random bytes, hex encoded and truncated to size
+func value(size int) string {
+	raw := make([]byte, (size+1)/2)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)[:size]
+}
+
+// recorder collects the latencies of puts and gets from several workers
+type recorder struct {
+	puts, gets samples
+}
+
+func (r *recorder) put(ctx context.Context, client Client, key, value string) {
+	start := time.Now()
+	_, err := client.Put(ctx, key, value)
+	r.puts.add(time.Since(start), err != nil, false)
+}
+
+func (r *recorder) get(ctx context.Context, client Client, key string) {
+	start := time.Now()
+	_, found, err := client.Get(ctx, key)
+	r.gets.add(time.Since(start), err != nil, err == nil && !found)
+}
+
+// samples are the latencies of one kind of operation
+type samples struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	misses    int
+}
+
+func (s *samples) add(latency time.Duration, failed, missed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, latency)
+	if failed {
+		s.errors++
+	}
+	if missed {
+		s.misses++
+	}
+}
+
+// stats summarizes the samples; failed operations count towards the
+// latencies too, as a caller waits for them all the same
+func (s *samples) stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{Count: len(s.latencies), Errors: s.errors, Misses: s.misses}
+	if stats.Count == 0 {
+		return stats
+	}
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	stats.Mean = total / time.Duration(len(sorted))
+	stats.P50 = Percentile(sorted, 0.50)
+	stats.P90 = Percentile(sorted, 0.90)
+	stats.P99 = Percentile(sorted, 0.99)
+	stats.Max = sorted[len(sorted)-1]
+	return stats
+}
+
+// Percentile returns the latency at or below which the fraction p of the
+// sorted latencies lie, by the nearest-rank method
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// WriteText writes the report as a table
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%d keys of %d bytes, %d workers, %.0f%% reads\n",
+		r.Workload.Keys, r.Workload.ValueSize, r.Workload.Concurrency, r.Workload.ReadRatio*100)
+	fmt.Fprintf(w, "%-5s %7s %6s %6s %10s %10s %10s %10s %10s\n", "op", "count", "errors", "misses", "mean", "p50", "p90", "p99", "max")
+	for _, row := range []struct {
+		name  string
+		stats Stats
+	}{{"load", r.Load}, {"put", r.Put}, {"get", r.Get}} {
+		s := row.stats
+		fmt.Fprintf(w, "%-5s %7d %6d %6d %10s %10s %10s %10s %10s\n", row.name, s.Count, s.Errors, s.Misses,
+			round(s.Mean), round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	_, err := fmt.Fprintf(w, "%d operations in %s: %.1f ops/s\n", r.Ops, round(r.Elapsed), r.Throughput)
+	return err
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
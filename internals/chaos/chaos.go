@@ -0,0 +1,137 @@
+package chaos
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// Injector injects latency, dropped replies and corrupted replies into RPC
+// handling so lookup retry logic can be exercised against a misbehaving
+// network
+type Injector struct {
+	cfg config.ChaosConfig
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewInjector creates an Injector using a random source seeded with seed
+func NewInjector(cfg config.ChaosConfig, seed int64) *Injector {
+	return &Injector{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Middleware wraps next with fault injection. When cfg is not enabled next
+// is returned unchanged.
+func Middleware(cfg config.ChaosConfig, next http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+	return NewInjector(cfg, time.Now().UnixNano()).Wrap(next)
+}
+
+// Wrap returns next with faults injected according to the injector's config
+func (in *Injector) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := in.delay(); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if in.roll(in.cfg.DropRate) {
+			drop(w)
+			return
+		}
+
+		if !in.roll(in.cfg.CorruptRate) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedWriter{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+		in.corrupt(buf.body.Bytes())
+
+		for k, v := range buf.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buf.body.Len()))
+		w.WriteHeader(buf.status)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+func (in *Injector) delay() time.Duration {
+	d := in.cfg.Latency
+	if in.cfg.Jitter > 0 {
+		in.mu.Lock()
+		d += time.Duration(in.rng.Int63n(int64(in.cfg.Jitter) + 1))
+		in.mu.Unlock()
+	}
+	return d
+}
+
+func (in *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.rng.Float64() < rate
+}
+
+// corrupt flips bits in roughly one of every eight bytes, and at least one
+func (in *Injector) corrupt(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	flips := len(body)/8 + 1
+	for i := 0; i < flips; i++ {
+		body[in.rng.Intn(len(body))] ^= byte(in.rng.Intn(255) + 1)
+	}
+}
+
+// drop closes the client connection without writing a response, as if the
+// reply had been lost
+func drop(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+// bufferedWriter captures a handler's response so it can be altered
+// before being sent
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
@@ -0,0 +1,236 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Crawler walks a Kademlia network by issuing FIND_NODE queries for random
+// targets to every peer it discovers.
+type Crawler struct {
+	QueriesPerNode int          // Number of random FIND_NODE targets sent to each peer
+	MaxNodes       int          // Stop discovering new peers after this many (0 means unlimited)
+	HTTPClient     *http.Client // Client used for all queries
+}
+
+// PeerInfo describes one peer in the crawl result
+type PeerInfo struct {
+	ID        string `json:"id"`
+	Addr      string `json:"addr"`
+	Reachable bool   `json:"reachable"`
+	OutDegree int    `json:"out_degree"`
+	InDegree  int    `json:"in_degree"`
+
+	// Reported by the peer's /node_info, empty for peers that predate it
+	Version  string `json:"version,omitempty"`
+	Protocol int    `json:"protocol,omitempty"`
+}
+
+// Edge records that From returned To in one of its FIND_NODE responses
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the network map produced by a crawl
+type Graph struct {
+	NetworkSize        int                  `json:"network_size"`
+	Reachable          int                  `json:"reachable"`
+	Unreachable        []string             `json:"unreachable"`
+	DegreeDistribution map[int]int          `json:"degree_distribution"` // out-degree -> number of peers
+	Peers              map[string]*PeerInfo `json:"peers"`               // keyed by address
+	Edges              []Edge               `json:"edges"`
+}
+
+// NewCrawler creates a crawler with sensible defaults
+func NewCrawler() *Crawler {
+	return &Crawler{
+		QueriesPerNode: 3,
+		HTTPClient:     &http.Client{Transport: network.Client().Transport, Timeout: 5 * time.Second},
+	}
+}
+
+// Crawl maps the network reachable from the given seed addresses (ip:port)
+func (c *Crawler) Crawl(ctx context.Context, seeds []string) *Graph {
+	g := &Graph{
+		Unreachable:        []string{},
+		DegreeDistribution: make(map[int]int),
+		Peers:              make(map[string]*PeerInfo),
+		Edges:              []Edge{},
+	}
+
+	queue := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		if _, seen := g.Peers[seed]; !seen {
+			g.Peers[seed] = &PeerInfo{Addr: seed}
+			queue = append(queue, seed)
+		}
+	}
+
+	seenEdges := make(map[Edge]bool)
+	for len(queue) > 0 && ctx.Err() == nil {
+		addr := queue[0]
+		queue = queue[1:]
+		peer := g.Peers[addr]
+
+		if peer.ID == "" {
+			id, err := c.ping(ctx, addr)
+			if err != nil {
+				continue
+			}
+			peer.ID = id
+		}
+		if info, err := c.nodeInfo(ctx, addr); err == nil {
+			peer.Version, peer.Protocol = info.Version, info.Protocol
+		}
+
+		for i := 0; i < c.QueriesPerNode; i++ {
+			nodes, err := c.findNode(ctx, addr, kademlia.GenerateNodeID())
+			if err != nil {
+				continue
+			}
+			peer.Reachable = true
+
+			for _, n := range nodes {
+				to := fmt.Sprintf("%s:%d", n.IP, n.Port)
+				if to == addr {
+					continue
+				}
+				if _, seen := g.Peers[to]; !seen {
+					if c.MaxNodes > 0 && len(g.Peers) >= c.MaxNodes {
+						continue
+					}
+					g.Peers[to] = &PeerInfo{ID: n.ID, Addr: to}
+					queue = append(queue, to)
+				}
+				edge := Edge{From: addr, To: to}
+				if !seenEdges[edge] {
+					seenEdges[edge] = true
+					g.Edges = append(g.Edges, edge)
+				}
+			}
+		}
+	}
+
+	for _, e := range g.Edges {
+		g.Peers[e.From].OutDegree++
+		g.Peers[e.To].InDegree++
+	}
+	for addr, peer := range g.Peers {
+		if peer.Reachable {
+			g.Reachable++
+		} else {
+			g.Unreachable = append(g.Unreachable, addr)
+		}
+		g.DegreeDistribution[peer.OutDegree]++
+	}
+	sort.Strings(g.Unreachable)
+	g.NetworkSize = len(g.Peers)
+
+	return g
+}
+
+// WriteDOT renders the graph in GraphViz DOT format, drawing unreachable
+// peers dashed.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph kademlia {\n")
+
+	addrs := make([]string, 0, len(g.Peers))
+	for addr := range g.Peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		peer := g.Peers[addr]
+		label := addr
+		if len(peer.ID) >= 8 {
+			label = fmt.Sprintf("%s\\n%s", peer.ID[:8], addr)
+		}
+		style := "solid"
+		if !peer.Reachable {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q, style=%s];\n", addr, label, style)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q;\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (c *Crawler) ping(ctx context.Context, addr string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/ping", addr), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		NodeID string `json:"node_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	return response.NodeID, nil
+}
+
+func (c *Crawler) nodeInfo(ctx context.Context, addr string) (kademlia.NodeInfo, error) {
+	var info kademlia.NodeInfo
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/node_info", addr), nil)
+	if err != nil {
+		return info, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	return info, err
+}
+
+func (c *Crawler) findNode(ctx context.Context, addr, target string) ([]*models.Node, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/find_node?id=%s", addr, target), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var nodes []*models.Node
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
@@ -0,0 +1,363 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// Diagnostic thresholds
+const (
+	// DialTimeout bounds each connection attempt to a bootstrap node
+	DialTimeout = 5 * time.Second
+
+	// SkewWarning is the clock difference to a bootstrap node from which
+	// the clock check warns. From clock.MaxSkew it fails, as nodes then
+	// reject each other's records.
+	SkewWarning = 30 * time.Second
+
+	// minYear is the earliest plausible year of the local clock; an
+	// earlier one is taken to be unset
+	minYear = 2024
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	OK Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return " OK "
+	case Warn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Result is the outcome of one check, with a hint on how to fix it when it
+// did not pass
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// Report is the outcome of every check run
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Add appends results to the report
+func (r *Report) Add(results ...Result) {
+	r.Results = append(r.Results, results...)
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints one line per check, followed by its hint, and a summary
+func (r *Report) Write(w io.Writer) error {
+	warnings, failures := 0, 0
+	for _, result := range r.Results {
+		switch result.Status {
+		case Warn:
+			warnings++
+		case Fail:
+			failures++
+		}
+		if _, err := fmt.Fprintf(w, "[%s] %-9s %s\n", result.Status, result.Check, result.Detail); err != nil {
+			return err
+		}
+		if result.Hint != "" {
+			if _, err := fmt.Fprintf(w, "%17s %s\n", "->", result.Hint); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d checks, %d warnings, %d failures\n", len(r.Results), warnings, failures)
+	return err
+}
+
+// Run checks that a node configured by cfg could start and join the
+// network: its ports are free, its data directory is writable, the local
+// clock is sane and the bootstrap nodes are reachable and compatible
+func Run(ctx context.Context, cfg *config.Config) *Report {
+	report := &Report{}
+	report.Add(CheckPorts(cfg)...)
+	report.Add(CheckDataDir(cfg.Storage))
+	report.Add(CheckClock(clock.Now()))
+	report.Add(CheckBootstrap(ctx, cfg)...)
+	return report
+}
+
+// CheckPorts tries to bind every address a node configured by cfg listens
+// on, releasing each straight away
+func CheckPorts(cfg *config.Config) []Result {
+	if cfg.Port <= 0 {
+		return []Result{{Check: "port", Status: Fail, Detail: "no RPC port configured", Hint: "pass --port or set KADEMLIA_PORT"}}
+	}
+	results := []Result{CheckPort("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))}
+	for _, l := range cfg.Server.AllListeners() {
+		results = append(results, CheckPort("tcp", l.Addr))
+	}
+	if cfg.Mainline.Port > 0 {
+		results = append(results, CheckPort("udp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Mainline.Port))))
+	}
+	if cfg.PunchPort > 0 {
+		results = append(results, CheckPort("udp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.PunchPort))))
+	}
+	return results
+}
+
+// CheckPort reports whether addr can be bound on network, "tcp" or "udp"
+func CheckPort(network, addr string) Result {
+	result := Result{Check: "port"}
+	var err error
+	if network == "udp" {
+		var conn net.PacketConn
+		if conn, err = net.ListenPacket(network, addr); err == nil {
+			conn.Close()
+		}
+	} else {
+		var listener net.Listener
+		if listener, err = net.Listen(network, addr); err == nil {
+			listener.Close()
+		}
+	}
+	if err == nil {
+		result.Detail = fmt.Sprintf("%s %s is free", network, addr)
+		return result
+	}
+	result.Status = Fail
+	result.Detail = fmt.Sprintf("cannot bind %s %s: %v", network, addr, err)
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		result.Hint = "another process is using the port, stop it (see lsof -i or ss -lnp) or choose another port"
+	case errors.Is(err, syscall.EACCES):
+		result.Hint = "ports below 1024 need root or CAP_NET_BIND_SERVICE, choose a higher port"
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		result.Hint = "the host is not an address of this machine, check KADEMLIA_HOST and use KADEMLIA_ADVERTISE for a public IP"
+	}
+	return result
+}
+
+// CheckDataDir checks that the storage backend can create, write and sync
+// files next to its data file, and open the data file if it exists
+func CheckDataDir(storage config.StorageConfig) Result {
+	result := Result{Check: "data dir"}
+	if storage.Backend == "" || storage.Backend == "memory" {
+		result.Detail = "memory backend, values are not persisted"
+		return result
+	}
+	dir := filepath.Dir(storage.Path)
+	fail := func(detail, hint string) Result {
+		result.Status = Fail
+		result.Detail = detail
+		result.Hint = hint
+		return result
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fail(fmt.Sprintf("%s: %v", dir, err), "create the directory, or pass --data-dir, which creates it")
+	}
+	if !info.IsDir() {
+		return fail(fmt.Sprintf("%s is not a directory", dir), "point KADEMLIA_STORAGE_PATH or --data-dir at a directory")
+	}
+	probe, err := os.CreateTemp(dir, ".kademlia-doctor-*")
+	if err != nil {
+		return fail(fmt.Sprintf("cannot create files in %s: %v", dir, err), "make the directory writable by this user, or choose another --data-dir")
+	}
+	defer os.Remove(probe.Name())
+	_, err = probe.Write([]byte("kademlia"))
+	if err == nil {
+		err = probe.Sync()
+	}
+	probe.Close()
+	if err != nil {
+		return fail(fmt.Sprintf("cannot write to %s: %v", dir, err), "check the disk is not full or mounted read-only")
+	}
+	if file, err := os.OpenFile(storage.Path, os.O_RDWR, 0); err == nil {
+		file.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fail(fmt.Sprintf("cannot open %s: %v", storage.Path, err), "make the data file readable and writable by this user")
+	}
+	result.Detail = fmt.Sprintf("%s backend can write to %s", storage.Backend, dir)
+	return result
+}
+
+// CheckClock checks that the local clock, reading now, has been set
+func CheckClock(now time.Time) Result {
+	if now.Year() < minYear {
+		return Result{Check: "clock", Status: Fail, Detail: fmt.Sprintf("local clock reads %s", now.UTC().Format(time.RFC3339)),
+			Hint: "set the clock, such as by enabling NTP, as records with timestamps this old are rejected"}
+	}
+	return Result{Check: "clock", Detail: fmt.Sprintf("local clock reads %s", now.UTC().Format(time.RFC3339))}
+}
+
+// CheckSkew compares the local clock, reading now, with the time peer sent
+// in its reply
+func CheckSkew(peer string, remote, now time.Time) Result {
+	result := Result{Check: "clock"}
+	skew := now.Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+	// HTTP dates have whole seconds
+	skew = skew.Truncate(time.Second)
+	result.Detail = fmt.Sprintf("%s clock is %s apart", peer, skew)
+	switch {
+	case skew > clock.MaxSkew:
+		result.Status = Fail
+		result.Hint = fmt.Sprintf("nodes more than %s apart reject each other's records, sync both clocks with NTP", clock.MaxSkew)
+	case skew > SkewWarning:
+		result.Status = Warn
+		result.Hint = "sync the clocks with NTP before they drift further apart"
+	}
+	return result
+}
+
+// CheckBootstrap resolves the bootstrap address of cfg and checks that up
+// to kademlia.MaxSeedAttempts of its nodes accept connections and are
+// compatible with a node configured by cfg
+func CheckBootstrap(ctx context.Context, cfg *config.Config) []Result {
+	if cfg.Bootstrap == "" {
+		return []Result{{Check: "bootstrap", Detail: "no bootstrap given, the node will start a new network"}}
+	}
+	addrs, err := kademlia.ResolveBootstrap(ctx, kademlia.SeedResolver, cfg.Bootstrap)
+	if err != nil {
+		return []Result{{Check: "bootstrap", Status: Fail, Detail: fmt.Sprintf("cannot resolve %s: %v", cfg.Bootstrap, err),
+			Hint: "check the seed name and that DNS works from this machine"}}
+	}
+	if len(addrs) > kademlia.MaxSeedAttempts {
+		addrs = addrs[:kademlia.MaxSeedAttempts]
+	}
+	var results []Result
+	for _, addr := range addrs {
+		results = append(results, checkSeed(ctx, cfg, addr)...)
+	}
+	return results
+}
+
+// checkSeed checks the bootstrap node at addr: it accepts connections,
+// answers /node_info and runs a compatible protocol with a clock close to
+// the local one
+func checkSeed(ctx context.Context, cfg *config.Config, addr string) []Result {
+	result := Result{Check: "bootstrap"}
+	dialer := net.Dialer{Timeout: DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		result.Status = Fail
+		result.Detail = fmt.Sprintf("cannot connect to %s: %v", addr, err)
+		var netErr net.Error
+		switch {
+		case errors.Is(err, syscall.ECONNREFUSED):
+			result.Hint = "nothing listens there, check the seed node is running and its port"
+		case errors.As(err, &netErr) && netErr.Timeout():
+			result.Hint = "no reply, a firewall or NAT may drop traffic to the seed"
+		case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+			result.Hint = "no route to the seed, check this machine's network"
+		}
+		return []Result{result}
+	}
+	conn.Close()
+
+	info, date, err := fetchNodeInfo(ctx, addr)
+	var status *kademlia.StatusError
+	switch {
+	case errors.As(err, &status) && (status.Code == http.StatusUnauthorized || status.Code == http.StatusForbidden):
+		result.Status = Fail
+		result.Detail = fmt.Sprintf("%s refused the request: %v", addr, err)
+		result.Hint = "the network requires a token, set KADEMLIA_AUTH_TOKEN to it"
+		return []Result{result}
+	case errors.As(err, &status) && status.Code == http.StatusNotFound:
+		result.Status = Warn
+		result.Detail = fmt.Sprintf("%s does not serve /node_info", addr)
+		result.Hint = "the seed predates /node_info, its protocol could not be checked"
+	case err != nil:
+		result.Status = Fail
+		result.Detail = fmt.Sprintf("%s accepts connections but did not answer: %v", addr, err)
+		result.Hint = "check the address is a Kademlia node's RPC port rather than another service"
+		return []Result{result}
+	default:
+		result.Detail = fmt.Sprintf("%s is node %s running %s", addr, info.NodeID, info.Version)
+		idBits := kademlia.IDBytes * 8
+		switch {
+		case info.Protocol != 0 && info.Protocol < kademlia.MinProtocolVersion:
+			result.Status = Fail
+			result.Detail = fmt.Sprintf("%s speaks protocol %d, older than the minimum %d", addr, info.Protocol, kademlia.MinProtocolVersion)
+			result.Hint = "upgrade the seed node or bootstrap through another"
+		case info.IDBits != 0 && info.IDBits != idBits:
+			result.Status = Fail
+			result.Detail = fmt.Sprintf("%s uses %d-bit IDs, this node %d-bit IDs", addr, info.IDBits, idBits)
+			result.Hint = "the seed belongs to another network, bootstrap through one of this network's nodes"
+		case info.K != 0 && info.K != cfg.K:
+			result.Status = Warn
+			result.Detail = fmt.Sprintf("%s uses k=%d, this node k=%d", addr, info.K, cfg.K)
+			result.Hint = "lookups still work, but set KADEMLIA_K or --k to match the network"
+		}
+	}
+
+	results := []Result{result}
+	if !date.IsZero() {
+		results = append(results, CheckSkew(addr, date, clock.Now()))
+	}
+	return results
+}
+
+// fetchNodeInfo asks the node at addr for its NodeInfo and returns it with
+// the time the reply was sent, zero if it carried no Date
+func fetchNodeInfo(ctx context.Context, addr string) (kademlia.NodeInfo, time.Time, error) {
+	var info kademlia.NodeInfo
+	ctx, cancel := context.WithTimeout(ctx, DialTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/node_info", addr), nil)
+	if err != nil {
+		return info, time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := network.Client().Do(req)
+	if err != nil {
+		return info, time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return info, time.Time{}, &kademlia.StatusError{Code: resp.StatusCode, Addr: addr}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, time.Time{}, fmt.Errorf("failed to decode response from %s: %v", addr, err)
+	}
+	date, _ := http.ParseTime(resp.Header.Get("Date"))
+	return info, date, nil
+}
@@ -0,0 +1,200 @@
+package kademlia
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// AnnounceParallelism bounds the pings an AddressMonitor has in flight
+// while announcing a new address
+const AnnounceParallelism = 8
+
+// AddressConfig configures detection of changes to the node's address
+type AddressConfig struct {
+	Interval time.Duration // Time between checks
+	Sample   int           // Contacts asked per check for the IP they see the node at, most recently seen first
+	Quorum   int           // Contacts that must see the node at the same new IP, and be most of those answering, to adopt it
+
+	// Resign, if set, signs a new node record once the IP changed, so
+	// peers replace the record naming the old one
+	Resign func() error
+}
+
+// AddressReport is the outcome of one check
+type AddressReport struct {
+	Answered  int            // Sampled contacts that answered
+	Observed  map[string]int // IP -> contacts that saw the node at it
+	Previous  string         // The node's IP before the check
+	Current   string         // The node's IP after the check
+	Interface bool           // The local IP the host reaches contacts from changed since the last check
+	Announced int            // Contacts that answered the announcement of the change, 0 if there was none
+}
+
+// AddressMonitor keeps the address the node advertises current. Every
+// interval it pings a sample of contacts, whose PONGs report the IP they
+// saw the ping come from, and checks the local IP the host reaches them
+// from. When a quorum of contacts sees the node at a new IP, or the
+// interface whose IP it advertised went away, it adopts the new IP, signs
+// a new record and pings every contact with it, so the network converges
+// on the new address instead of waiting for the old one to fail. A node
+// advertising an unspecified IP, which peers replace with the IP of each
+// connection, keeps it and only announces itself again when its interface
+// changes.
+type AddressMonitor struct {
+	node         *models.Node
+	routingTable *models.RoutingTable
+	cfg          AddressConfig
+	localIP      string // Local IP contacts were reached from at the last check
+}
+
+// NewAddressMonitor creates a monitor for the address of node
+func NewAddressMonitor(node *models.Node, routingTable *models.RoutingTable, cfg AddressConfig) *AddressMonitor {
+	return &AddressMonitor{node: node, routingTable: routingTable, cfg: cfg}
+}
+
+// Check runs one check of the node's address and, if it changed, adopts
+// and announces the new one
+func (am *AddressMonitor) Check(ctx context.Context) AddressReport {
+	report := AddressReport{Observed: make(map[string]int), Previous: am.node.IP, Current: am.node.IP}
+	contacts := am.contacts()
+	sample := contacts
+	if am.cfg.Sample > 0 && len(sample) > am.cfg.Sample {
+		sample = sample[:am.cfg.Sample]
+	}
+	for _, peer := range sample {
+		if ctx.Err() != nil {
+			return report
+		}
+		reply, err := announce(ctx, am.node, peerAddr(peer), false)
+		am.routingTable.Peers.RecordRPC(peer, err)
+		if err != nil || reply.NodeID != peer.ID {
+			continue
+		}
+		report.Answered++
+		if ip := net.ParseIP(reply.ObservedIP); ip != nil && !ip.IsUnspecified() {
+			report.Observed[ip.String()]++
+		}
+	}
+
+	next := am.agreedIP(report)
+	if local := routeIP(contacts); local != "" {
+		report.Interface = am.localIP != "" && local != am.localIP
+		if report.Interface && next == "" && am.node.IP == am.localIP {
+			next = local
+		}
+		am.localIP = local
+	}
+
+	if ip := net.ParseIP(am.node.IP); ip != nil && !ip.IsUnspecified() {
+		if next == "" || next == am.node.IP {
+			return report
+		}
+		am.adopt(next)
+		report.Current = next
+		log.Printf("Address changed from %s to %s, announcing it to %d contacts", report.Previous, next, len(contacts))
+	} else if !report.Interface {
+		return report
+	}
+
+	report.Announced = am.Announce(ctx)
+	if report.Current != report.Previous {
+		self := *am.node
+		am.routingTable.Events.Emit(models.Event{Type: models.AddressChanged, Peer: &self, Value: report.Previous})
+	}
+	fmt.Printf("Announced the node's address to %d contacts\n", report.Announced)
+	return report
+}
+
+// Announce pings every contact with the node's contact details and record,
+// and returns how many answered
+func (am *AddressMonitor) Announce(ctx context.Context) int {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		announced int
+	)
+	slots := make(chan struct{}, AnnounceParallelism)
+	for _, peer := range am.contacts() {
+		if ctx.Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(peer *models.Node) {
+			defer func() { <-slots; wg.Done() }()
+			_, err := announce(ctx, am.node, peerAddr(peer), false)
+			am.routingTable.Peers.RecordRPC(peer, err)
+			if err == nil {
+				mu.Lock()
+				announced++
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	return announced
+}
+
+// agreedIP returns the IP a quorum of the contacts that answered, and most
+// of them, saw the node at, or "" if they do not agree
+func (am *AddressMonitor) agreedIP(report AddressReport) string {
+	quorum := max(am.cfg.Quorum, 1)
+	for ip, seen := range report.Observed {
+		if seen >= quorum && 2*seen > report.Answered {
+			return ip
+		}
+	}
+	return ""
+}
+
+// adopt makes ip the node's advertised IP, in its contact in the routing
+// table too, and signs a new record
+func (am *AddressMonitor) adopt(ip string) {
+	am.node.IP = ip
+	AddNodeToRoutingTable(am.routingTable, &models.Node{ID: am.node.ID, IP: ip, Port: am.node.Port}, am.node.ID)
+	if am.cfg.Resign != nil {
+		if err := am.cfg.Resign(); err != nil {
+			log.Printf("Failed to sign a node record for the new address %s: %v", ip, err)
+		}
+	}
+}
+
+// contacts returns the routing table's contacts other than the node, most
+// recently seen first
+func (am *AddressMonitor) contacts() []*models.Node {
+	var contacts []*models.Node
+	for _, n := range am.routingTable.Contacts() {
+		if n.ID != am.node.ID {
+			contacts = append(contacts, n)
+		}
+	}
+	sort.SliceStable(contacts, func(i, j int) bool { return contacts[i].SeenAt().After(contacts[j].SeenAt()) })
+	return contacts
+}
+
+// routeIP returns the local IP the host reaches the first directly
+// reachable of contacts from, without sending anything, or "" if there is
+// none
+func routeIP(contacts []*models.Node) string {
+	for _, peer := range contacts {
+		if peer.IP == "" || peer.Port == 0 {
+			continue
+		}
+		conn, err := net.Dial("udp", net.JoinHostPort(peer.IP, strconv.Itoa(peer.Port)))
+		if err != nil {
+			continue
+		}
+		local := conn.LocalAddr().(*net.UDPAddr).IP.String()
+		conn.Close()
+		return local
+	}
+	return ""
+}
@@ -0,0 +1,281 @@
+package kademlia
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Export file format. An export is JSON lines: an ExportHeader followed by
+// one models.StoredRecord per line, sorted by key.
+const (
+	ExportFormat        = "kademlia-export"
+	ExportFormatVersion = 1
+)
+
+// ErrInvalidExport is returned when importing data that is not an export
+// this node understands
+var ErrInvalidExport = errors.New("invalid export")
+
+// ExportHeader is the first line of an export
+type ExportHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	NodeID     string    `json:"node_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	Records    int       `json:"records"` // Keys stored when the export began; those deleted during it are left out
+}
+
+// ImportResult counts the records of an import
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"` // Existing keys left alone without overwrite, and expired records
+}
+
+// RegisterAdmin serves the admin endpoints under /admin/ on mux, each run
+// through mws and then middleware.Admin(token). ttl is the garbage
+// collection TTL reported as each exported record's expiry, 0 if values
+// never expire. The jobs of scheduler, if not nil, are served under
+// /admin/jobs.
+func RegisterAdmin(mux *http.ServeMux, node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, scheduler *Scheduler, token string, ttl time.Duration, mws ...middleware.Middleware) {
+	chain := middleware.Chain(append(mws, middleware.Admin(token))...)
+
+	mux.HandleFunc("/admin/export", tracing.Middleware("admin_export", node.ID, chain("admin_export", func(w http.ResponseWriter, r *http.Request) {
+		AdminExportHandler(w, r, node, storage, ttl)
+	})))
+	mux.HandleFunc("/admin/keys", tracing.Middleware("admin_keys", node.ID, chain("admin_keys", func(w http.ResponseWriter, r *http.Request) {
+		AdminKeysHandler(w, r, storage)
+	})))
+	mux.HandleFunc("/admin/import", tracing.Middleware("admin_import", node.ID, chain("admin_import", func(w http.ResponseWriter, r *http.Request) {
+		AdminImportHandler(w, r, storage)
+	})))
+	mux.HandleFunc("/admin/keyspace", tracing.Middleware("admin_keyspace", node.ID, chain("admin_keyspace", func(w http.ResponseWriter, r *http.Request) {
+		AdminKeyspaceHandler(w, r, node, storage, routingTable)
+	})))
+	if scheduler == nil {
+		return
+	}
+	mux.HandleFunc("/admin/jobs", tracing.Middleware("admin_jobs", node.ID, chain("admin_jobs", func(w http.ResponseWriter, r *http.Request) {
+		AdminJobsHandler(w, r, scheduler)
+	})))
+	mux.HandleFunc("/admin/jobs/pause", tracing.Middleware("admin_jobs_pause", node.ID, chain("admin_jobs_pause", func(w http.ResponseWriter, r *http.Request) {
+		AdminPauseJobsHandler(w, r, scheduler, true)
+	})))
+	mux.HandleFunc("/admin/jobs/resume", tracing.Middleware("admin_jobs_resume", node.ID, chain("admin_jobs_resume", func(w http.ResponseWriter, r *http.Request) {
+		AdminPauseJobsHandler(w, r, scheduler, false)
+	})))
+}
+
+// AdminKeysHandler handles /admin/keys, listing one page of the stored keys
+// whose raw key starts with the hex "prefix" parameter. "namespace"
+// restricts the listing to one namespace, and "limit" and "token" page
+// through it as in /iterate_keys.
+func AdminKeysHandler(w http.ResponseWriter, r *http.Request, storage *models.KeyValueStore) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	req := AdminKeysRequest{Limit: DefaultIterateLimit}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	prefix := strings.ToLower(req.Prefix)
+	if _, ok := parseID(prefix); !ok {
+		http.Error(w, "Invalid 'prefix' parameter, expected up to 40 hex digits", http.StatusBadRequest)
+		return
+	}
+	_, namespaced := r.URL.Query()["namespace"]
+	if err := models.ValidateNamespace(req.Namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := ListKeys(storage, prefix, req.Namespace, namespaced, req.Limit, req.Token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// KeyInfo describes a stored key without its value
+type KeyInfo struct {
+	Key       string    `json:"key"` // Including the namespace prefix, if any
+	SizeBytes int       `json:"size_bytes"`
+	StoredAt  time.Time `json:"stored_at"`
+}
+
+// KeyList is one page of /admin/keys results. NextToken is empty on the
+// last page.
+type KeyList struct {
+	Keys      []KeyInfo `json:"keys"`
+	NextToken string    `json:"next_token,omitempty"`
+}
+
+// ListKeys returns up to limit stored keys, sorted, whose raw key starts
+// with the lowercase hex prefix. If namespaced is set only keys of
+// namespace are listed. Passing the previous page's NextToken as token
+// resumes after its last key.
+func ListKeys(storage *models.KeyValueStore, prefix, namespace string, namespaced bool, limit int, token string) (KeyList, error) {
+	var after string
+	if token != "" {
+		key, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return KeyList{}, ErrInvalidIterationToken
+		}
+		after = string(key)
+	}
+
+	var matches []KeyInfo
+	for _, entry := range storage.Entries() {
+		ns, raw := models.SplitNamespacedKey(entry.Key)
+		if namespaced && ns != namespace {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(raw), prefix) || (token != "" && entry.Key <= after) {
+			continue
+		}
+		matches = append(matches, KeyInfo{Key: entry.Key, SizeBytes: entry.Size, StoredAt: entry.StoredAt})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Key < matches[j].Key })
+
+	list := KeyList{Keys: []KeyInfo{}}
+	if len(matches) > limit {
+		matches = matches[:limit]
+		list.NextToken = base64.RawURLEncoding.EncodeToString([]byte(matches[limit-1].Key))
+	}
+	list.Keys = append(list.Keys, matches...)
+	return list, nil
+}
+
+// AdminExportHandler handles /admin/export, streaming every stored record
+func AdminExportHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, ttl time.Duration) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if err := ExportRecords(w, storage, node.ID, ttl); err != nil {
+		fmt.Printf("Export failed: %v\n", err)
+	}
+}
+
+// AdminImportHandler handles /admin/import, storing the records of an
+// export POSTed as the body. Existing keys are kept unless the
+// "overwrite" query parameter is "true".
+func AdminImportHandler(w http.ResponseWriter, r *http.Request, storage *models.KeyValueStore) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	var req AdminImportRequest
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	result, err := ImportRecords(r.Body, storage, req.Overwrite)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed after %d records: %v", result.Imported, err), http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("Imported %d records, skipped %d\n", result.Imported, result.Skipped)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportRecords writes an export of storage to w, in key order. Each
+// record's own TTL, or else ttl if positive, sets its ExpiresAt. The store is read with Scan, so
+// writes go on during a large export.
+func ExportRecords(w io.Writer, storage *models.KeyValueStore, nodeID string, ttl time.Duration) error {
+	encoder := json.NewEncoder(w)
+	header := ExportHeader{
+		Format:     ExportFormat,
+		Version:    ExportFormatVersion,
+		NodeID:     nodeID,
+		ExportedAt: clock.Now().UTC(),
+		Records:    storage.Len(),
+	}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	var err error
+	storage.Scan(func(rec models.StoredRecord) bool {
+		expiry := ttl
+		if rec.TTL > 0 {
+			expiry = models.Seconds(rec.TTL)
+		}
+		if expiry > 0 {
+			expires := rec.StoredAt.Add(expiry)
+			rec.ExpiresAt = &expires
+		}
+		err = encoder.Encode(rec)
+		return err == nil
+	})
+	return err
+}
+
+// ImportRecords stores the records of the export read from r, keeping
+// their publisher and age. Records past their ExpiresAt by more than
+// clock.MaxSkew are skipped. Existing keys are only replaced if overwrite is
+// set. Headers may appear anywhere, so several exports, or an export split
+// into batches, can be concatenated.
+func ImportRecords(r io.Reader, storage *models.KeyValueStore, overwrite bool) (ImportResult, error) {
+	var result ImportResult
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			return result, fmt.Errorf("%w: line %d: %v", ErrInvalidExport, line, err)
+		}
+		if _, isHeader := fields["format"]; isHeader {
+			var header ExportHeader
+			json.Unmarshal(scanner.Bytes(), &header)
+			if header.Format != ExportFormat || header.Version < 1 || header.Version > ExportFormatVersion {
+				return result, fmt.Errorf("%w: line %d: unsupported format %q version %d", ErrInvalidExport, line, header.Format, header.Version)
+			}
+			continue
+		}
+
+		var rec models.StoredRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Key == "" {
+			return result, fmt.Errorf("%w: line %d: not a record", ErrInvalidExport, line)
+		}
+
+		// Times come from the source node's clock. Records it expected to
+		// expire are dropped, and none can have been stored in our future.
+		now := clock.Now()
+		if rec.ExpiresAt != nil && clock.Expired(*rec.ExpiresAt, now) {
+			result.Skipped++
+			continue
+		}
+		rec.StoredAt, _ = clock.FromPeer(rec.StoredAt, now)
+
+		stored, err := storage.Restore(rec, overwrite)
+		if err != nil {
+			return result, err
+		}
+		if stored {
+			result.Imported++
+		} else {
+			result.Skipped++
+		}
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,95 @@
+package kademlia
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// ErrNotAllowlisted is returned when a peer missing from the allowlist of
+// a private network asks to join it or to write to a node's storage
+var ErrNotAllowlisted = errors.New("peer is not on the allowlist")
+
+// allowedWriter reports whether the peer sending r may write to this
+// node's storage. With an allowlist, the peer must name itself in the
+// RequesterIDHeader, which nodes send with every write they replicate,
+// and write from the address of a confirmed allowlisted contact the
+// routing table holds for that ID; the header alone proves nothing.
+func allowedWriter(routingTable *models.RoutingTable, r *http.Request) bool {
+	if routingTable.Allowlist == nil {
+		return true
+	}
+	id := r.Header.Get(RequesterIDHeader)
+	if id == "" {
+		return false
+	}
+	return provenWriter(routingTable, id, nil, remoteIP(r))
+}
+
+// provenWriter reports whether the peer claiming the ID id, writing from
+// ip, is allowlisted by what it proved rather than by what it claims.
+// signed is the record whose key signed the write, nil for an unsigned
+// one. A signature by a listed key suffices. Otherwise the routing table
+// must hold a confirmed contact with that ID at ip, or with a record of
+// the same key as signed. Any peer can claim a listed ID and be added
+// under it, so a contact is only confirmed if its record's key is listed
+// or it is pinned, found at an address the operator gave; a contact
+// admitted by its ID alone fails closed.
+func provenWriter(routingTable *models.RoutingTable, id string, signed *models.NodeRecord, ip string) bool {
+	allowlist := routingTable.Allowlist
+	if allowlist == nil {
+		return true
+	}
+	if signed != nil && allowlist.Allows(&models.Node{Record: signed}) {
+		return true
+	}
+
+	routingTable.RLock()
+	defer routingTable.RUnlock()
+	for _, bucket := range routingTable.Buckets {
+		for _, contact := range bucket.Nodes {
+			if contact.ID != id {
+				continue
+			}
+			if !allowlist.Allows(contact) {
+				return false
+			}
+			if !routingTable.Pinned[contact.ID] && !allowlist.Allows(&models.Node{Record: contact.Record}) {
+				return false
+			}
+			if signed != nil && contact.Record != nil && contact.Record.PublicKey == signed.PublicKey {
+				return true
+			}
+			return contact.IP == ip
+		}
+	}
+	return false
+}
+
+// contactOf returns the contact the routing table holds for id, or a bare
+// node with that ID if it holds none
+func contactOf(rt *models.RoutingTable, id string) *models.Node {
+	for _, n := range rt.Contacts() {
+		if n.ID == id {
+			return n
+		}
+	}
+	return &models.Node{ID: id}
+}
+
+// requesterHeader returns the headers naming requester as the sender of a
+// replicated write, with the namespace it writes to
+func requesterHeader(requester, namespace, namespaceToken string) http.Header {
+	header := http.Header{}
+	if requester != "" {
+		header.Set(RequesterIDHeader, requester)
+	}
+	if namespace != "" {
+		header.Set(NamespaceHeader, namespace)
+	}
+	if namespaceToken != "" {
+		header.Set(NamespaceTokenHeader, namespaceToken)
+	}
+	return header
+}
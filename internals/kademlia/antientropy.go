@@ -0,0 +1,243 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// AntiEntropy periodically reconciles storage with the closest contacts,
+// which share responsibility for the keys around the local ID. Each round
+// compares per-bucket hashes of the shared key range, exchanges the records
+// of buckets that differ, and copies each side's missing records to the
+// other. Records present on both sides with different values are left
+// alone. Tombstones are exchanged like records and win over the values
+// stored before them, so a replica that missed a DELETE does not copy the
+// value back.
+type AntiEntropy struct {
+	storage      *models.KeyValueStore
+	routingTable *models.RoutingTable
+	localID      string
+}
+
+// SyncDigest maps a bucket, the first byte of a key's SHA-1 hash in hex,
+// to a hash of the bucket's records
+type SyncDigest map[string]string
+
+// NewAntiEntropy creates a reconciler for the node localID
+func NewAntiEntropy(storage *models.KeyValueStore, routingTable *models.RoutingTable, localID string) *AntiEntropy {
+	return &AntiEntropy{storage: storage, routingTable: routingTable, localID: localID}
+}
+
+// SyncClosest syncs with each of the closest contacts in turn
+func (ae *AntiEntropy) SyncClosest(ctx context.Context) {
+	for _, peer := range FindClosestNodes(ae.routingTable, ae.localID, ae.localID, 0) {
+		if peer.ID == ae.localID {
+			continue
+		}
+		pulled, pushed, err := ae.SyncWith(ctx, peer)
+		if err != nil {
+			log.Printf("Anti-entropy sync with %s failed: %v", peer.ID, err)
+		} else if pulled+pushed > 0 {
+			fmt.Printf("Anti-entropy sync with %s: pulled %d, pushed %d records\n", peer.ID, pulled, pushed)
+		}
+	}
+}
+
+// SyncWith reconciles the key range shared with peer: the keys around the
+// local ID no farther than peer itself. It returns the number of records
+// copied from and to peer.
+func (ae *AntiEntropy) SyncWith(ctx context.Context, peer *models.Node) (pulled, pushed int, err error) {
+	radius := distanceBitLen(ae.localID, peer.ID)
+	addr := peerAddr(peer)
+
+	query := url.Values{}
+	query.Set("target", ae.localID)
+	query.Set("radius", strconv.Itoa(radius))
+
+	var remote SyncDigest
+	header, body, err := rpcGetRaw(ctx, addr, "/sync_digest?"+query.Encode(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := decodeBody(header.Get("Content-Type"), body, &remote); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode response from %s: %v", addr, err)
+	}
+	writeToken := header.Get(WriteTokenHeader)
+
+	local := Digest(ae.storage, ae.localID, radius)
+	var missing []KeyRecord
+	for bucket := range unionKeys(local, remote) {
+		if local[bucket] == remote[bucket] {
+			continue
+		}
+
+		query.Set("bucket", bucket)
+		var theirs []KeyRecord
+		if err := rpcGet(ctx, addr, "/sync_digest?"+query.Encode(), &theirs); err != nil {
+			return pulled, pushed, err
+		}
+
+		have := make(map[string]KeyRecord, len(theirs))
+		for _, rec := range theirs {
+			have[rec.Key] = rec
+			// The peer cannot show a namespace's token in its reply, so
+			// records of namespaces that have one wait for its push
+			ns, _ := models.SplitNamespacedKey(rec.Key)
+			if models.ValidateNamespace(ns) != nil || ae.storage.NamespacePolicy(ns).Token != "" {
+				continue
+			}
+			if admitSynced(ae.storage, ae.routingTable, ae.localID, rec, addr) {
+				pulled++
+			}
+		}
+		for _, rec := range DigestBucket(ae.storage, ae.localID, radius, bucket) {
+			remote, ok := have[rec.Key]
+			if !ok || (rec.DeletedAt != nil && remote.DeletedAt == nil) {
+				missing = append(missing, rec)
+			}
+		}
+	}
+
+	// Push each namespace's records with its token, as the peer admits
+	// them like STOREs
+	byNamespace := make(map[string][]KeyRecord)
+	for _, rec := range missing {
+		ns, _ := models.SplitNamespacedKey(rec.Key)
+		byNamespace[ns] = append(byNamespace[ns], rec)
+	}
+	for ns, records := range byNamespace {
+		header := requesterHeader(ae.localID, ns, ae.storage.NamespacePolicy(ns).Token)
+		if writeToken != "" {
+			header.Set(WriteTokenHeader, writeToken)
+		}
+		if err := rpcPostWithHeader(ctx, addr, "/sync_push", header, records, nil); err != nil {
+			return pulled, pushed, err
+		}
+		pushed += len(records)
+	}
+	return pulled, pushed, nil
+}
+
+// admitSynced stores rec, a record or tombstone a replica sent the node
+// localID during anti-entropy, pushed or pulled, as a STORE or /delete of
+// it would be, and reports whether storage changed. Keys that are not
+// IDs, that lie outside the node's responsibility when storage rejects
+// distant keys, or whose value exceeds MaxValueSize or the namespace's
+// quota are skipped. Records already present are not overwritten, but
+// typed records are merged into the record of the same type, tombstoned
+// keys are not resurrected, and records keep the publisher they were
+// stored with. Tombstones delete the values they are newer than if the
+// values' publishers signed them.
+func admitSynced(storage *models.KeyValueStore, routingTable *models.RoutingTable, localID string, rec KeyRecord, sender string) bool {
+	_, raw := models.SplitNamespacedKey(rec.Key)
+	if err := validators.ValidateID(raw, validators.HexadecimalValidator); err != nil {
+		return false
+	}
+	if storage.RejectDistant && !InResponsibility(routingTable, localID, raw) {
+		notePlacementOutside(storage, localID, raw, sender)
+		return false
+	}
+	if rec.DeletedAt != nil {
+		recorded, _ := tombstoneLocal(storage, syncedDelete(rec))
+		return recorded
+	}
+	if _, exists := storage.Get(rec.Key); exists {
+		return mergePushed(storage, rec.Key, rec.Value)
+	}
+	if _, deleted := storage.DeletedAt(rec.Key); deleted || len(rec.Value) > MaxValueSize {
+		return false
+	}
+	_, err := storage.Put(rec.Key, rec.Value, rec.Publisher, models.OverwriteRejectExists, "")
+	return err == nil
+}
+
+// Digest hashes the records within XOR distance 2^radius of target, one
+// hash per bucket
+func Digest(storage *models.KeyValueStore, target string, radius int) SyncDigest {
+	buckets := make(map[string][]KeyRecord)
+	for _, rec := range recordsInRange(storage, target, radius) {
+		b := bucketOf(rec.Key)
+		buckets[b] = append(buckets[b], rec)
+	}
+
+	digest := make(SyncDigest, len(buckets))
+	for b, records := range buckets {
+		h := sha1.New()
+		for _, rec := range records {
+			if rec.DeletedAt != nil {
+				fmt.Fprintf(h, "%s:deleted\n", rec.Key)
+				continue
+			}
+			valueHash := sha1.Sum([]byte(rec.Value))
+			fmt.Fprintf(h, "%s:%x\n", rec.Key, valueHash)
+		}
+		digest[b] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digest
+}
+
+// DigestBucket returns the records of one bucket within XOR distance
+// 2^radius of target, sorted by key
+func DigestBucket(storage *models.KeyValueStore, target string, radius int, bucket string) []KeyRecord {
+	records := []KeyRecord{}
+	for _, rec := range recordsInRange(storage, target, radius) {
+		if bucketOf(rec.Key) == bucket {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// recordsInRange returns the records and tombstones within XOR distance
+// 2^radius of target, sorted by key
+func recordsInRange(storage *models.KeyValueStore, target string, radius int) []KeyRecord {
+	var records []KeyRecord
+	storage.Scan(func(rec models.StoredRecord) bool {
+		_, raw := models.SplitNamespacedKey(rec.Key)
+		if distanceBitLen(target, raw) <= radius {
+			records = append(records, KeyRecord{Key: rec.Key, Value: rec.Value, Publisher: rec.Publisher})
+		}
+		return true
+	})
+	for key, deletedAt := range storage.Tombstones() {
+		_, raw := models.SplitNamespacedKey(key)
+		if distanceBitLen(target, raw) <= radius {
+			deletedAt := deletedAt
+			rec := KeyRecord{Key: key, DeletedAt: &deletedAt}
+			if proof, signed := storage.DeleteProofOf(key); signed {
+				rec.Publisher, rec.PublicKey, rec.Signature = proof.Publisher, proof.PublicKey, proof.Signature
+				rec.DeletedAt = &proof.DeletedAt
+			}
+			records = append(records, rec)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	return records
+}
+
+// bucketOf returns the digest bucket of a storage key: the first byte of
+// its SHA-1 hash, which spreads the neighbouring keys of a range evenly
+func bucketOf(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:1])
+}
+
+func unionKeys(a, b SyncDigest) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
@@ -0,0 +1,198 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/internals/openapi"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// OpenAPIPath is where nodes serve their OpenAPI document
+const OpenAPIPath = "/openapi.json"
+
+// Security schemes of the API
+const (
+	securityNetwork = "networkToken"
+	securityAdmin   = "adminToken"
+	securityGateway = "apiKey"
+)
+
+// Endpoints annotates every RPC, admin and gateway endpoint for the
+// OpenAPI document. The relay endpoints, which hijack the connection, and
+// the pprof profiles are left out. Add an entry here with every new
+// handler.
+var Endpoints = []openapi.Endpoint{
+	{Method: http.MethodGet, Path: "/ping", OperationID: "ping", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Check the node is alive; a pinger sending its ID and port is added to the routing table",
+		Query:   PingQuery{}, Response: PingReply{}},
+	{Method: http.MethodPost, Path: "/ping", OperationID: "ping_contact", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Ping with the sender's contact details, whose advertised IP is used instead of the connection's",
+		Body:    models.Node{}, Response: PingReply{}},
+	{Method: http.MethodPost, Path: "/rpc", OperationID: "rpc", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Send any RPC in a message envelope, answered with one",
+		Body:    models.Message{}, Response: models.Message{}},
+	{Method: http.MethodGet, Path: "/find_node", OperationID: "find_node", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Find the contacts closest to an ID",
+		Query:   FindNodeRequest{}, Response: []models.Node{}},
+	{Method: http.MethodGet, Path: "/find_value", OperationID: "find_value", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Get the value of a key, with its provenance when meta is set, or the contacts closest to it if this node does not hold it; the value's ETag in If-None-Match answers 304 if it is unchanged",
+		Query:   FindValueRequest{}, Response: openapi.OneOf{"", FindValueReply{}, []models.Node{}}},
+	{Method: http.MethodHead, Path: "/find_value", OperationID: "find_value_head", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Check whether this node holds a key, answering 404 if not, with the value's ETag and its provenance in the X-Kademlia-Meta header but not the value",
+		Query:   FindValueRequest{}},
+	{Method: http.MethodPost, Path: "/find_values", OperationID: "find_values", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Get the values of up to 64 keys at once, with the contacts closest to each key this node does not hold",
+		Query:   StoreQuery{}, Body: FindValuesRequest{}, Response: FindValuesReply{}},
+	{Method: http.MethodPost, Path: "/store", OperationID: "store", Tag: "rpc", Security: []string{securityNetwork}, Status: http.StatusCreated,
+		Summary: "Store a value, or with replicate set store it on the k closest nodes; a node that is not among the closest answers 200 with closer contacts",
+		Query:   StoreQuery{}, Body: StoreRequest{}, Response: openapi.OneOf{StoreAck{}, []models.Node{}}},
+	{Method: http.MethodPost, Path: "/delete", OperationID: "delete", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Replace a value by a tombstone that replicas keep until garbage collected, or with replicate set do so on the k closest nodes",
+		Body:    DeleteRequest{}, Response: StoreAck{}},
+	{Method: http.MethodPost, Path: "/lease", OperationID: "lease", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Acquire, renew or release the lease on a key, or with replicate set do so on a majority of the k closest nodes",
+		Query:   StoreQuery{}, Body: LeaseRequest{}, Response: LeaseAck{}},
+	{Method: http.MethodGet, Path: "/peers", OperationID: "peers", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Sample known peers by a sampling policy, optionally within an XOR radius of a key",
+		Query:   PeersRequest{}, Defaults: PeersRequest{Radius: 160}, Response: []models.Node{}},
+	{Method: http.MethodGet, Path: "/routing_table", OperationID: "routing_table", Tag: "rpc", Security: []string{securityNetwork},
+		Summary:  "Snapshot of up to 1000 routing table contacts",
+		Response: []models.Node{}},
+	{Method: http.MethodGet, Path: "/iterate_keys", OperationID: "iterate_keys", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Page through the stored records near a target",
+		Query:   IterateKeysRequest{}, Defaults: IterateKeysRequest{Radius: 160, Limit: DefaultIterateLimit}, Response: KeyPage{}},
+	{Method: http.MethodGet, Path: "/sync_digest", OperationID: "sync_digest", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Per-bucket hashes of the records near a target, or the records of one bucket",
+		Query:   SyncDigestRequest{}, Defaults: SyncDigestRequest{Radius: 160}, Response: openapi.OneOf{SyncDigest{}, []KeyRecord{}}},
+	{Method: http.MethodPost, Path: "/sync_push", OperationID: "sync_push", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Store records a replica found missing; existing keys are kept",
+		Body:    []KeyRecord{}, Response: SyncPushReply{}},
+	{Method: http.MethodPost, Path: "/add_provider", OperationID: "add_provider", Tag: "rpc", Security: []string{securityNetwork}, Status: http.StatusCreated,
+		Summary: "Announce a provider of a content key",
+		Body:    AddProviderRequest{}, RawResponse: "text/plain"},
+	{Method: http.MethodGet, Path: "/get_providers", OperationID: "get_providers", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "List the providers of a content key and the contacts closest to it",
+		Query:   GetProvidersRequest{}, Response: ProvidersReply{}},
+	{Method: http.MethodGet, Path: "/node_info", OperationID: "node_info", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary: "Software and protocol versions, uptime, k and alpha, storage counts and capability flags, and optionally a Bloom filter of the stored keys",
+		Query:   NodeInfoRequest{}, Response: NodeInfo{}},
+	{Method: http.MethodGet, Path: "/ownership", OperationID: "ownership", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "Estimated share of the keyspace this node stores and the network size it implies",
+		Response: Ownership{}},
+	{Method: http.MethodGet, Path: "/buckets", OperationID: "buckets", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "Contacts, capacity and last update of every bucket in use",
+		Response: []BucketInfo{}},
+	{Method: http.MethodGet, Path: "/pool_stats", OperationID: "pool_stats", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "Outbound connection pool metrics",
+		Response: network.PoolStats{}},
+	{Method: http.MethodGet, Path: "/store_stats", OperationID: "store_stats", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "How many STOREs were accepted, answered with closer contacts or refused outside the responsibility radius",
+		Response: models.PlacementSnapshot{}},
+	{Method: http.MethodGet, Path: "/churn_stats", OperationID: "churn_stats", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary: "Session counts, drop rate and mean session length of the peers seen, or the session history and trust of one peer",
+		Query:   ChurnStatsRequest{}, Response: openapi.OneOf{models.ChurnStats{}, PeerChurn{}}},
+	{Method: http.MethodGet, Path: "/peer_store", OperationID: "peer_store", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary: "Addresses, RTT, trust, capabilities and last error of the peers seen, most recently seen first, or of one peer",
+		Query:   PeerStoreRequest{}, Defaults: PeerStoreRequest{Limit: DefaultIterateLimit}, Response: openapi.OneOf{PeerStoreList{}, models.PeerInfo{}}},
+	{Method: http.MethodGet, Path: "/rpc_stats", OperationID: "rpc_stats", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "Request, error, panic and latency counts of inbound RPCs, by RPC",
+		Response: map[string]middleware.RPCStats{}},
+	{Method: http.MethodGet, Path: "/runtime_stats", OperationID: "runtime_stats", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "Latest sample of goroutine, heap and GC counters",
+		Response: middleware.RuntimeStats{}},
+	{Method: http.MethodGet, Path: "/forward_stats", OperationID: "forward_stats", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "Replica STOREs queued for unreachable peers, and how many were delivered, expired, refused or dropped",
+		Response: ForwardStats{}},
+	{Method: http.MethodPost, Path: "/subscribe", OperationID: "subscribe", Tag: "pubsub", Security: []string{securityNetwork}, Status: http.StatusCreated,
+		Summary: "Subscribe to a topic on its rendezvous node",
+		Body:    SubscribeRequest{}, Response: SubscribeReply{}},
+	{Method: http.MethodPost, Path: "/publish", OperationID: "publish", Tag: "pubsub", Security: []string{securityNetwork},
+		Summary: "Publish a message to a topic's subscribers",
+		Body:    PublishRequest{}, Response: PublishReply{}},
+	{Method: http.MethodGet, Path: "/poll", OperationID: "poll", Tag: "pubsub", Security: []string{securityNetwork},
+		Summary: "Long-poll a subscriber's pending messages",
+		Query:   PollRequest{}, Defaults: PollRequest{Timeout: 30}, Response: []models.TopicMessage{}},
+	{Method: http.MethodPost, Path: "/watch", OperationID: "watch", Tag: "watch", Security: []string{securityNetwork}, Status: http.StatusCreated,
+		Summary: "Watch a stored key for changes for the next 10 minutes, or cancel the watch",
+		Query:   StoreQuery{}, Body: WatchRequest{}, Response: WatchReply{}},
+	{Method: http.MethodGet, Path: "/watch_poll", OperationID: "watch_poll", Tag: "watch", Security: []string{securityNetwork},
+		Summary: "Long-poll a watched key for a version above since; 204 if none came in time, 404 if the watch lapsed",
+		Query:   WatchPollRequest{}, Defaults: WatchPollRequest{Timeout: DefaultWatchTimeout}, Response: KeyChange{}},
+	{Method: http.MethodPost, Path: "/punch", OperationID: "punch", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Ask this node to introduce you to a contact for UDP hole punching",
+		Body:    PunchRequest{}, Response: PunchReply{}},
+	{Method: http.MethodPost, Path: "/punch_notify", OperationID: "punch_notify", Tag: "rpc", Security: []string{securityNetwork},
+		Summary: "Sent by a coordinator to the target of a punch, which starts probing the initiator",
+		Body:    PunchRequest{}, Response: PunchReply{}},
+	{Method: http.MethodGet, Path: "/admin/export", OperationID: "admin_export", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary:     "Every stored record as JSON lines after a versioned header",
+		RawResponse: "application/x-ndjson"},
+	{Method: http.MethodGet, Path: "/admin/keys", OperationID: "admin_keys", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary: "One page of the stored keys whose raw key starts with a hex prefix",
+		Query:   AdminKeysRequest{}, Defaults: AdminKeysRequest{Limit: DefaultIterateLimit}, Response: KeyList{}},
+	{Method: http.MethodPost, Path: "/admin/import", OperationID: "admin_import", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary: "Store the records of an export; existing keys are skipped unless overwriting",
+		Query:   AdminImportRequest{}, BodyType: "application/x-ndjson", Response: ImportResult{}},
+	{Method: http.MethodGet, Path: "/admin/keyspace", OperationID: "admin_keyspace", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary: "Counts of stored keys and known peers in equal ranges of the keyspace; format=csv returns CSV instead",
+		Query:   AdminKeyspaceRequest{}, Defaults: AdminKeyspaceRequest{Bins: DefaultKeyspaceBins, Format: "json"}, Response: KeyspaceHistogram{}},
+	{Method: http.MethodGet, Path: "/admin/jobs", OperationID: "admin_jobs", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary:  "The node's periodic jobs, such as refresh, republish and expiry, with their runs and whether they are paused",
+		Response: []JobStatus{}},
+	{Method: http.MethodPost, Path: "/admin/jobs/pause", OperationID: "admin_jobs_pause", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary: "Stop starting runs of a periodic job, or of every job without one, letting runs in progress end",
+		Query:   AdminJobsRequest{}, Response: []JobStatus{}},
+	{Method: http.MethodPost, Path: "/admin/jobs/resume", OperationID: "admin_jobs_resume", Tag: "admin", Security: []string{securityNetwork, securityAdmin},
+		Summary: "Resume a paused periodic job, or every job without one; runs that fell due meanwhile start at once",
+		Query:   AdminJobsRequest{}, Response: []JobStatus{}},
+	{Method: http.MethodGet, Path: "/v1/keys/{key}", OperationID: "gateway_get", Tag: "gateway", Security: []string{securityGateway},
+		Summary: "Look a value up across the network",
+		Query:   GatewayKeyRequest{}, Defaults: GatewayKeyRequest{Hash: true}, RawResponse: "text/plain"},
+	{Method: http.MethodPut, Path: "/v1/keys/{key}", OperationID: "gateway_put", Tag: "gateway", Security: []string{securityGateway}, Status: http.StatusCreated,
+		Summary: "Store the body as the value on the k closest nodes",
+		Query:   GatewayKeyRequest{}, Defaults: GatewayKeyRequest{Hash: true}, BodyType: "text/plain", Response: StoreAck{}},
+	{Method: http.MethodPost, Path: GatewayBatchPath, OperationID: "gateway_get_many", Tag: "gateway", Security: []string{securityGateway},
+		Summary: "Look the values of up to 64 keys up across the network in one batched lookup",
+		Query:   GatewayKeyRequest{}, Defaults: GatewayKeyRequest{Hash: true}, Body: GatewayBatchRequest{}, Response: GatewayBatchReply{}},
+	{Method: http.MethodGet, Path: OpenAPIPath, OperationID: "openapi", Tag: "diagnostics", Security: []string{securityNetwork},
+		Summary:  "This OpenAPI document",
+		Response: json.RawMessage{}},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *openapi.Document
+)
+
+// OpenAPI returns the OpenAPI document of Endpoints
+func OpenAPI() *openapi.Document {
+	openAPIOnce.Do(func() {
+		openAPIDoc = openapi.New(
+			openapi.Info{
+				Title:       "Kademlia node",
+				Version:     strconv.Itoa(models.ProtocolVersion),
+				Description: "RPC, admin and gateway endpoints of a Kademlia DHT node",
+			},
+			[]openapi.Tag{
+				{Name: "rpc", Description: "Node-to-node RPCs"},
+				{Name: "pubsub", Description: "Topics hosted on their rendezvous node"},
+				{Name: "watch", Description: "Change notifications for the keys a node stores"},
+				{Name: "diagnostics", Description: "Metrics and state for operators"},
+				{Name: "admin", Description: "Served with an admin token configured"},
+				{Name: "gateway", Description: "REST access for clients outside the DHT, served in gateway mode"},
+			},
+			map[string]openapi.SecurityScheme{
+				securityNetwork: {Type: "http", Scheme: "bearer", Description: "The network's auth token, when nodes are configured with one"},
+				securityAdmin:   {Type: "apiKey", In: "header", Name: middleware.AdminTokenHeader},
+				securityGateway: {Type: "apiKey", In: "header", Name: middleware.APIKeyHeader, Description: "Required when the gateway is configured with API keys"},
+			},
+			Endpoints,
+		)
+	})
+	return openAPIDoc
+}
@@ -0,0 +1,51 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// BucketInfo describes one routing table bucket as served by /buckets
+type BucketInfo struct {
+	Index       int       `json:"index"`
+	Contacts    int       `json:"contacts"`
+	MaxSize     int       `json:"max_size"`
+	LastUpdated time.Time `json:"last_updated"` // When a contact in range was last seen or looked up
+	IdleSeconds float64   `json:"idle_seconds"` // Time since LastUpdated
+
+	SubnetRefused uint64 `json:"subnet_refused"` // Contacts refused for coming from a subnet at its limit
+}
+
+// BucketStats describes every bucket that holds contacts or has been used,
+// in index order
+func BucketStats(routingTable *models.RoutingTable) []BucketInfo {
+	now := clock.Now()
+	stats := []BucketInfo{}
+	routingTable.RLock()
+	defer routingTable.RUnlock()
+	for i, bucket := range routingTable.Buckets {
+		if len(bucket.Nodes) == 0 && bucket.LastUpdated.IsZero() {
+			continue
+		}
+		stats = append(stats, BucketInfo{
+			Index:       i,
+			Contacts:    len(bucket.Nodes),
+			MaxSize:     bucket.MaxSize,
+			LastUpdated: bucket.LastUpdated,
+			IdleSeconds: now.Sub(bucket.LastUpdated).Seconds(),
+
+			SubnetRefused: bucket.SubnetRefused,
+		})
+	}
+	return stats
+}
+
+// BucketsHandler serves BucketStats for the node's routing table
+func BucketsHandler(w http.ResponseWriter, r *http.Request, routingTable *models.RoutingTable) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BucketStats(routingTable))
+}
@@ -0,0 +1,36 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// PeerChurn is the session history of one peer, with the churn and trust
+// derived from it
+type PeerChurn struct {
+	ID    string           `json:"id"`
+	Score models.PeerScore `json:"score"`
+	Churn float64          `json:"churn"` // Recent drops, decayed by models.ChurnHalfLife
+	Trust float64          `json:"trust"` // Success rate discounted by churn
+}
+
+// ChurnStatsHandler handles /churn_stats requests, reporting the session
+// history of the peers this node has seen, or of the peer id if given
+func ChurnStatsHandler(w http.ResponseWriter, r *http.Request, routingTable *models.RoutingTable) {
+	var req ChurnStatsRequest
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.ID == "" {
+		json.NewEncoder(w).Encode(routingTable.Reputation.Churn())
+		return
+	}
+	now := clock.Now()
+	score := routingTable.Reputation.Score(req.ID)
+	json.NewEncoder(w).Encode(PeerChurn{ID: req.ID, Score: score, Churn: score.Churn(now), Trust: score.Trust(now)})
+}
@@ -0,0 +1,144 @@
+package kademlia
+
+import (
+	"container/heap"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// ParallelScanThreshold is the number of contacts from which
+// FindClosestNodes scans the buckets on one goroutine per CPU. Smaller
+// tables are scanned faster by one goroutine than the others take to
+// start.
+var ParallelScanThreshold = 4096
+
+// candidate is a contact and its distance to the ID being looked up
+type candidate struct {
+	node     *models.Node
+	distance Distance
+}
+
+// closestHeap keeps the limit closest candidates offered to it, with the
+// farthest at the root so it is the one displaced by a closer candidate
+type closestHeap struct {
+	items []candidate
+	limit int
+}
+
+func newClosestHeap(limit int) *closestHeap {
+	return &closestHeap{items: make([]candidate, 0, limit), limit: limit}
+}
+
+func (h *closestHeap) Len() int           { return len(h.items) }
+func (h *closestHeap) Less(i, j int) bool { return h.items[i].distance.Cmp(h.items[j].distance) > 0 }
+func (h *closestHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *closestHeap) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+func (h *closestHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// offer adds c if the heap is not full or c is closer than its farthest
+func (h *closestHeap) offer(c candidate) {
+	if len(h.items) < h.limit {
+		heap.Push(h, c)
+		return
+	}
+	if h.limit > 0 && c.distance.Cmp(h.items[0].distance) < 0 {
+		h.items[0] = c
+		heap.Fix(h, 0)
+	}
+}
+
+// nodes returns the kept contacts, closest first
+func (h *closestHeap) nodes() []*models.Node {
+	sort.Slice(h.items, func(i, j int) bool { return h.items[i].distance.Cmp(h.items[j].distance) < 0 })
+	nodes := make([]*models.Node, len(h.items))
+	for i, c := range h.items {
+		nodes[i] = c.node
+	}
+	return nodes
+}
+
+// scanBuckets returns the k contacts in buckets closest to queryID,
+// leaving out those in exclude. ok is false if an ID does not fit a
+// Distance.
+func scanBuckets(buckets []*models.Bucket, queryID string, k int, exclude map[string]bool) (*closestHeap, bool) {
+	query, ok := parseID(queryID)
+	if !ok {
+		return nil, false
+	}
+	closest := newClosestHeap(k)
+	for _, bucket := range buckets {
+		if !scanNodes(closest, bucket.Nodes, query, exclude) {
+			return nil, false
+		}
+	}
+	return closest, true
+}
+
+// scanBucketsParallel is scanBuckets with the contacts split evenly
+// between one worker per CPU, each keeping its own k closest, which are
+// then merged. Contacts are split rather than buckets, as the buckets
+// farthest from the local ID hold most of them.
+func scanBucketsParallel(buckets []*models.Bucket, queryID string, k int, exclude map[string]bool) (*closestHeap, bool) {
+	query, ok := parseID(queryID)
+	if !ok {
+		return nil, false
+	}
+	var contacts []*models.Node
+	for _, bucket := range buckets {
+		contacts = append(contacts, bucket.Nodes...)
+	}
+	workers := max(1, min(runtime.GOMAXPROCS(0), len(contacts)))
+	shard := (len(contacts) + workers - 1) / workers
+
+	results := make([]*closestHeap, workers)
+	fits := make([]bool, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := min(w*shard, len(contacts)), min((w+1)*shard, len(contacts))
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			results[w] = newClosestHeap(k)
+			fits[w] = scanNodes(results[w], contacts[start:end], query, exclude)
+		}(w)
+	}
+	wg.Wait()
+
+	closest := newClosestHeap(k)
+	for w, result := range results {
+		if !fits[w] {
+			return nil, false
+		}
+		for _, c := range result.items {
+			closest.offer(c)
+		}
+	}
+	return closest, true
+}
+
+// scanNodes offers each of nodes not in exclude to closest, by its
+// distance to query. It returns false if an ID does not fit a Distance.
+func scanNodes(closest *closestHeap, nodes []*models.Node, query [IDBytes]byte, exclude map[string]bool) bool {
+	for _, node := range nodes {
+		if exclude[node.ID] {
+			continue
+		}
+		id, ok := parseID(node.ID)
+		if !ok {
+			return false
+		}
+		var distance Distance
+		for i := range distance {
+			distance[i] = query[i] ^ id[i]
+		}
+		closest.offer(candidate{node: node, distance: distance})
+	}
+	return true
+}
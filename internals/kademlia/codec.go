@@ -0,0 +1,99 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Aradhya2708/kademlia/pkg/bencode"
+)
+
+// Content types of RPC replies. Peers ask for bencode by listing it in
+// their Accept header; replies to everyone else stay JSON, so old and new
+// nodes interoperate. FIND_VALUE replies carry a found value as its raw
+// bytes to peers accepting ContentTypeOctetStream.
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeBencode     = "application/x-bencode"
+	ContentTypeOctetStream = "application/octet-stream"
+)
+
+type wireFormatKey struct{}
+
+// WithWireFormat returns ctx asking peers for replies in format, "json" or
+// "bencode", in the RPCs made under it; without it replies are JSON
+func WithWireFormat(ctx context.Context, format string) context.Context {
+	return context.WithValue(ctx, wireFormatKey{}, format)
+}
+
+func wireFormatFrom(ctx context.Context) string {
+	format, _ := ctx.Value(wireFormatKey{}).(string)
+	return format
+}
+
+// withWireFormat runs h with format in every request's context, for
+// handlers that make RPCs
+func withWireFormat(format string, h http.Handler) http.Handler {
+	if format == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(WithWireFormat(r.Context(), format)))
+	})
+}
+
+// acceptHeader returns the Accept header sent with outbound RPCs for the
+// wire format ctx carries
+func acceptHeader(ctx context.Context) string {
+	if wireFormatFrom(ctx) == "bencode" {
+		return ContentTypeBencode + ", " + ContentTypeJSON + ";q=0.5"
+	}
+	return ContentTypeJSON
+}
+
+// acceptsBencode reports whether the peer that sent r can read bencode
+func acceptsBencode(r *http.Request) bool {
+	return accepts(r, ContentTypeBencode)
+}
+
+// accepts reports whether the Accept header of r lists contentType
+func accepts(r *http.Request, contentType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEncoded writes v in the most compact encoding the requesting peer
+// accepts
+func writeEncoded(w http.ResponseWriter, r *http.Request, v interface{}) {
+	writeEncodedStatus(w, r, http.StatusOK, v)
+}
+
+// writeEncodedStatus is writeEncoded with an explicit status code
+func writeEncodedStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if acceptsBencode(r) {
+		if data, err := bencode.Marshal(v); err == nil {
+			w.Header().Set("Content-Type", ContentTypeBencode)
+			w.WriteHeader(status)
+			w.Write(data)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decodeBody decodes an RPC reply according to its Content-Type
+func decodeBody(contentType string, data []byte, out interface{}) error {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == ContentTypeBencode {
+		return bencode.Unmarshal(data, out)
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,63 @@
+package kademlia
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// MetaHeader carries the provenance of the value found by HEAD
+// /find_value, which has no body to carry it
+const MetaHeader = "X-Kademlia-Meta"
+
+// EncodeMetaHeader encodes meta for MetaHeader
+func EncodeMetaHeader(meta models.RecordMeta) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeMetaHeader decodes the provenance sent in MetaHeader
+func DecodeMetaHeader(value string) (models.RecordMeta, error) {
+	var meta models.RecordMeta
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return meta, fmt.Errorf("invalid %s header: %v", MetaHeader, err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("invalid %s header: %v", MetaHeader, err)
+	}
+	return meta, nil
+}
+
+// writeMetaHeader sets MetaHeader to meta
+func writeMetaHeader(w http.ResponseWriter, meta models.RecordMeta) {
+	if value, err := EncodeMetaHeader(meta); err == nil {
+		w.Header().Set(MetaHeader, value)
+	}
+}
+
+// valueETag returns the entity tag of a stored value, derived from its
+// hash so it changes exactly when the value does. It is weak because the
+// body carrying the value depends on the encoding negotiated.
+func valueETag(meta models.RecordMeta) string {
+	return `W/"` + meta.Hash + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, or is
+// "*", comparing tags weakly as conditional GETs do
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (tag != "" && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
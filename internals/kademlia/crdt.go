@@ -0,0 +1,52 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// errMergeUnchanged aborts a merge that would leave the stored record as
+// it is
+var errMergeUnchanged = errors.New("record unchanged")
+
+// storeTyped merges the record of a typed STORE into the record of the
+// same type stored under key, failing with models.ErrRecordType if key
+// holds a value of another kind. Policies and idempotency keys do not
+// apply: merging a record again changes nothing.
+func storeTyped(storage *models.KeyValueStore, key string, req StoreRequest) error {
+	rec, err := models.ParseCRDTRecord(req.Value, req.Type)
+	if err != nil {
+		return err
+	}
+	return storage.Update(key, req.Publisher, func(current string, exists bool) (string, error) {
+		return models.MergeCRDTRecord(current, exists, rec)
+	})
+}
+
+// mergePushed merges value, pushed by a replica during anti-entropy, into
+// the value stored under key if both are records of the same type,
+// reporting whether that changed the stored record. Replicas holding
+// typed records thus converge instead of each keeping its own.
+func mergePushed(storage *models.KeyValueStore, key, value string) bool {
+	var head struct {
+		Type models.RecordType `json:"type"`
+	}
+	if json.Unmarshal([]byte(value), &head) != nil || head.Type == models.RecordValue || !models.ValidRecordType(head.Type) {
+		return false
+	}
+	rec, err := models.ParseCRDTRecord(value, head.Type)
+	if err != nil {
+		return false
+	}
+	meta, _ := storage.Meta(key)
+	err = storage.Update(key, meta.Publisher, func(current string, exists bool) (string, error) {
+		merged, err := models.MergeCRDTRecord(current, exists, rec)
+		if err == nil && merged == current {
+			return "", errMergeUnchanged
+		}
+		return merged, err
+	})
+	return err == nil
+}
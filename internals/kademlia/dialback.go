@@ -0,0 +1,78 @@
+package kademlia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// MaxConcurrentDialBacks bounds the pings back in flight at once, so a
+// flood of pings from new IDs cannot make the node ping arbitrary
+// addresses without limit. Pingers arriving while every slot is taken are
+// answered but not added.
+const MaxConcurrentDialBacks = 32
+
+var dialBackSlots = make(chan struct{}, MaxConcurrentDialBacks)
+
+// errDialBackBusy is returned by DialBack when MaxConcurrentDialBacks pings
+// back are already in flight
+var errDialBackBusy = errors.New("too many dial-backs in flight")
+
+// admitPinger adds pinger to the routing table. With the table's DialBack
+// set, a pinger not already known at its address is first pinged back
+// there and left out unless it answers as the same node. It reports whether pinger
+// was added.
+func admitPinger(ctx context.Context, routingTable *models.RoutingTable, pinger *models.Node, localID string) bool {
+	if routingTable.DialBack && pinger.ID != localID && !knownAt(routingTable, pinger, localID) {
+		if err := DialBack(ctx, pinger); err != nil {
+			fmt.Printf("Not adding node %s at %s: %v\n", pinger.ID, peerAddr(pinger), err)
+			return false
+		}
+	}
+	AddNodeToRoutingTable(routingTable, pinger, localID)
+	return true
+}
+
+// knownAt reports whether the routing table holds contact at the address
+// it advertises, so pinging it back would prove nothing new
+func knownAt(rt *models.RoutingTable, contact *models.Node, localID string) bool {
+	bucketIndex := bucketIndexOf(localID, contact.ID)
+	if bucketIndex >= len(rt.Buckets) {
+		return false
+	}
+	rt.RLock()
+	defer rt.RUnlock()
+	for _, n := range rt.Buckets[bucketIndex].Nodes {
+		if n.ID == contact.ID {
+			return n.IP == contact.IP && n.Port == contact.Port && n.Relay == contact.Relay
+		}
+	}
+	return false
+}
+
+// DialBack pings contact at the address it advertised and checks the PONG
+// comes from the same node ID and echoes a fresh token, proving the
+// contact is reachable there rather than spoofed or behind a closed port
+func DialBack(ctx context.Context, contact *models.Node) error {
+	select {
+	case dialBackSlots <- struct{}{}:
+		defer func() { <-dialBackSlots }()
+	default:
+		return errDialBackBusy
+	}
+
+	token := newPingToken()
+	var reply PingReply
+	if err := rpcGet(ctx, peerAddr(contact), "/ping?token="+token, &reply); err != nil {
+		return fmt.Errorf("dial-back failed: %v", err)
+	}
+	if reply.NodeID != contact.ID {
+		return fmt.Errorf("dial-back answered by %q", reply.NodeID)
+	}
+	if reply.Token != token {
+		return errors.New("dial-back PONG did not echo the token")
+	}
+	return nil
+}
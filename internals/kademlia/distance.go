@@ -0,0 +1,104 @@
+package kademlia
+
+import (
+	"bytes"
+	"math/big"
+	"math/bits"
+)
+
+// IDBytes is the length in bytes of a node ID or key
+const IDBytes = 20
+
+// Distance is the XOR distance between two IDs as a big-endian 160-bit
+// number. Unlike calculateXORDistance it lives on the stack, so the routing
+// table's hot paths compare and bucket contacts without allocating.
+type Distance [IDBytes]byte
+
+// XORDistance returns the distance between two hex IDs. ok is false when
+// either ID is not hex or is longer than 160 bits, in which case callers
+// fall back to calculateXORDistance.
+func XORDistance(id1, id2 string) (d Distance, ok bool) {
+	a, ok := parseID(id1)
+	if !ok {
+		return d, false
+	}
+	b, ok := parseID(id2)
+	if !ok {
+		return d, false
+	}
+	for i := range d {
+		d[i] = a[i] ^ b[i]
+	}
+	return d, true
+}
+
+// BitLen returns the position of the highest set bit, as big.Int.BitLen
+func (d Distance) BitLen() int {
+	for i, b := range d {
+		if b != 0 {
+			return (IDBytes-i)*8 - bits.LeadingZeros8(b)
+		}
+	}
+	return 0
+}
+
+// Cmp compares d and other as big.Int.Cmp does
+func (d Distance) Cmp(other Distance) int {
+	return bytes.Compare(d[:], other[:])
+}
+
+// Big returns d as a big.Int
+func (d Distance) Big() *big.Int {
+	return new(big.Int).SetBytes(d[:])
+}
+
+// parseID decodes a hex ID of up to 40 digits, right-aligned so that
+// shorter IDs compare as the numbers they spell
+func parseID(id string) (out [IDBytes]byte, ok bool) {
+	if len(id) > 2*IDBytes {
+		return out, false
+	}
+	for i, j := len(id), IDBytes-1; i > 0; i, j = i-2, j-1 {
+		lo, ok := unhex(id[i-1])
+		if !ok {
+			return out, false
+		}
+		var hi byte
+		if i > 1 {
+			if hi, ok = unhex(id[i-2]); !ok {
+				return out, false
+			}
+		}
+		out[j] = hi<<4 | lo
+	}
+	return out, true
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// distanceBitLen returns calculateXORDistance(id1, id2).BitLen(), without
+// allocating for well-formed IDs
+func distanceBitLen(id1, id2 string) int {
+	if d, ok := XORDistance(id1, id2); ok {
+		return d.BitLen()
+	}
+	return calculateXORDistance(id1, id2).BitLen()
+}
+
+// bucketIndexOf returns the bucket of id in the routing table of localID
+func bucketIndexOf(localID, id string) int {
+	if n := distanceBitLen(localID, id); n > 0 {
+		return n - 1
+	}
+	return 0
+}
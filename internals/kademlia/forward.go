@@ -0,0 +1,317 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// MaxForwardRecords bounds the replica STOREs a ForwardQueue holds across
+// all peers; writes queued beyond it are dropped
+const MaxForwardRecords = 4096
+
+// MaxForwardPerPeer bounds the replica STOREs queued for one peer, so a
+// peer gone for good cannot take the whole queue
+const MaxForwardPerPeer = 256
+
+// ForwardQueue stores and forwards replica STOREs whose target could not
+// be reached. Queued writes are delivered when the peer re-enters the
+// routing table, and retried every interval meanwhile, until they are
+// older than the TTL. Only the latest write of each key is kept per peer.
+// A nil *ForwardQueue queues nothing.
+type ForwardQueue struct {
+	routingTable *models.RoutingTable
+	ttl          time.Duration
+	interval     time.Duration
+
+	mu        sync.Mutex
+	peers     map[string]*forwardPeer // Peer ID -> writes queued for it
+	count     int                     // Writes queued across peers
+	seq       uint64
+	delivered uint64
+	expired   uint64
+	rejected  uint64
+	dropped   uint64
+}
+
+type forwardPeer struct {
+	node   *models.Node
+	writes map[string]*forwardedStore // Namespaced key -> latest write
+}
+
+type forwardedStore struct {
+	req      StoreRequest
+	queued   time.Time
+	attempts int
+	seq      uint64 // Tells a write from one that replaced it while delivering
+}
+
+// NewForwardQueue creates a queue for replica STOREs to contacts of
+// routingTable, holding each for up to ttl and retrying every interval
+func NewForwardQueue(routingTable *models.RoutingTable, ttl, interval time.Duration) *ForwardQueue {
+	return &ForwardQueue{routingTable: routingTable, ttl: ttl, interval: interval, peers: make(map[string]*forwardPeer)}
+}
+
+type forwardQueueKey struct{}
+
+// WithForwardQueue returns a context under which IterativeStore queues the
+// replica STOREs it cannot deliver on q
+func WithForwardQueue(ctx context.Context, q *ForwardQueue) context.Context {
+	return context.WithValue(ctx, forwardQueueKey{}, q)
+}
+
+func forwardQueueFrom(ctx context.Context) *ForwardQueue {
+	q, _ := ctx.Value(forwardQueueKey{}).(*ForwardQueue)
+	return q
+}
+
+// withForwardQueue runs h with q in every request's context, for handlers
+// that replicate writes
+func withForwardQueue(q *ForwardQueue, h http.Handler) http.Handler {
+	if q == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(WithForwardQueue(r.Context(), q)))
+	})
+}
+
+// Enqueue queues req for delivery to peer, replacing a write of the same
+// key queued before, and reports whether it was queued
+func (q *ForwardQueue) Enqueue(peer *models.Node, req StoreRequest) bool {
+	if q == nil || q.ttl <= 0 {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := clock.Now()
+	q.expire(now)
+	p := q.peers[peer.ID]
+	if p == nil {
+		p = &forwardPeer{node: peer, writes: make(map[string]*forwardedStore)}
+		q.peers[peer.ID] = p
+	}
+	key := models.NamespacedKey(req.Namespace, req.Key)
+	if p.writes[key] == nil {
+		if q.count >= MaxForwardRecords || len(p.writes) >= MaxForwardPerPeer {
+			q.dropped++
+			if len(p.writes) == 0 {
+				delete(q.peers, peer.ID)
+			}
+			return false
+		}
+		q.count++
+	}
+	q.seq++
+	p.node = peer
+	p.writes[key] = &forwardedStore{req: req, queued: now, seq: q.seq}
+	return true
+}
+
+// Watch delivers the writes queued for every peer that re-enters the
+// routing table until ctx is cancelled. Retrying them every interval is
+// left to the caller, such as a Scheduler calling Flush.
+func (q *ForwardQueue) Watch(ctx context.Context) {
+	var added <-chan models.Event
+	if events := q.routingTable.Events; events != nil {
+		ch, cancel := events.Subscribe(64, models.PeerAdded)
+		defer cancel()
+		added = ch
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-added:
+			if e.Peer != nil && q.Pending(e.Peer.ID) > 0 {
+				if n := q.Deliver(ctx, e.Peer); n > 0 {
+					log.Printf("Forwarded %d queued replica writes to %s on its return", n, e.Peer.ID)
+				}
+			}
+		}
+	}
+}
+
+// Pending returns the number of writes queued for the peer id
+func (q *ForwardQueue) Pending(id string) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if p := q.peers[id]; p != nil {
+		return len(p.writes)
+	}
+	return 0
+}
+
+// Flush tries to deliver every queued write, each to the peer's contact
+// in the routing table if it is there, and returns how many were delivered
+func (q *ForwardQueue) Flush(ctx context.Context) int {
+	q.mu.Lock()
+	q.expire(clock.Now())
+	peers := make([]*models.Node, 0, len(q.peers))
+	for _, p := range q.peers {
+		peers = append(peers, p.node)
+	}
+	q.mu.Unlock()
+
+	delivered := 0
+	for _, peer := range peers {
+		if ctx.Err() != nil {
+			break
+		}
+		if contact := contactOf(q.routingTable, peer.ID); contact.Port != 0 || contact.Relay != "" {
+			peer = contact
+		}
+		delivered += q.Deliver(ctx, peer)
+	}
+	return delivered
+}
+
+// Deliver sends the writes queued for peer to it, oldest first, and
+// returns how many it stored. Writes the peer refuses are dropped; the
+// rest stay queued once the peer stops answering.
+func (q *ForwardQueue) Deliver(ctx context.Context, peer *models.Node) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	p := q.peers[peer.ID]
+	if p == nil {
+		q.mu.Unlock()
+		return 0
+	}
+	p.node = peer
+	writes := make([]forwardedStore, 0, len(p.writes))
+	for _, w := range p.writes {
+		writes = append(writes, *w)
+	}
+	q.mu.Unlock()
+	sort.Slice(writes, func(i, j int) bool { return writes[i].seq < writes[j].seq })
+
+	delivered := 0
+	for _, w := range writes {
+		if ctx.Err() != nil {
+			break
+		}
+		err := SendStoreRequest(ctx, peer, w.req)
+		q.routingTable.Peers.RecordRPC(peer, err)
+		refused := undeliverable(err)
+
+		q.mu.Lock()
+		key := models.NamespacedKey(w.req.Namespace, w.req.Key)
+		if current := p.writes[key]; current != nil && current.seq == w.seq {
+			switch {
+			case err == nil:
+				q.delivered++
+				delivered++
+				q.remove(p, peer.ID, key)
+			case refused:
+				q.rejected++
+				q.remove(p, peer.ID, key)
+			default:
+				current.attempts++
+			}
+		}
+		q.mu.Unlock()
+		if err != nil && !refused {
+			// The peer is still unreachable; its other writes wait for the next try
+			break
+		}
+	}
+	return delivered
+}
+
+// undeliverable reports whether err is a refusal by a reachable peer, which
+// retrying the same write will not change
+func undeliverable(err error) bool {
+	var status *StatusError
+	return errors.As(err, &status) && !status.Retryable()
+}
+
+// remove drops the write of key queued for p; q.mu must be held
+func (q *ForwardQueue) remove(p *forwardPeer, id, key string) {
+	delete(p.writes, key)
+	q.count--
+	if len(p.writes) == 0 && q.peers[id] == p {
+		delete(q.peers, id)
+	}
+}
+
+// expire drops the writes queued for longer than the TTL; q.mu must be held
+func (q *ForwardQueue) expire(now time.Time) {
+	for id, p := range q.peers {
+		for key, w := range p.writes {
+			if now.Sub(w.queued) > q.ttl {
+				q.expired++
+				q.remove(p, id, key)
+			}
+		}
+	}
+}
+
+// ForwardPeer lists the writes queued for one peer
+type ForwardPeer struct {
+	ID       string    `json:"id"`
+	IP       string    `json:"ip"`
+	Port     int       `json:"port"`
+	Writes   int       `json:"writes"`
+	Attempts int       `json:"attempts"` // Failed delivery attempts across the writes
+	Oldest   time.Time `json:"oldest"`   // When the oldest write was queued
+}
+
+// ForwardStats summarises a ForwardQueue
+type ForwardStats struct {
+	Queued    int           `json:"queued"`    // Writes waiting for delivery
+	Delivered uint64        `json:"delivered"` // Writes delivered after being queued, ever
+	Expired   uint64        `json:"expired"`   // Writes dropped after the TTL, ever
+	Rejected  uint64        `json:"rejected"`  // Writes the returning peer refused, ever
+	Dropped   uint64        `json:"dropped"`   // Writes not queued for lack of room, ever
+	Peers     []ForwardPeer `json:"peers"`     // Peers with writes queued, most writes first
+}
+
+// Stats returns the queue's counters and the peers writes wait for
+func (q *ForwardQueue) Stats() ForwardStats {
+	if q == nil {
+		return ForwardStats{Peers: []ForwardPeer{}}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire(clock.Now())
+	stats := ForwardStats{Queued: q.count, Delivered: q.delivered, Expired: q.expired, Rejected: q.rejected, Dropped: q.dropped, Peers: []ForwardPeer{}}
+	for id, p := range q.peers {
+		peer := ForwardPeer{ID: id, IP: p.node.IP, Port: p.node.Port, Writes: len(p.writes)}
+		for _, w := range p.writes {
+			peer.Attempts += w.attempts
+			if peer.Oldest.IsZero() || w.queued.Before(peer.Oldest) {
+				peer.Oldest = w.queued
+			}
+		}
+		stats.Peers = append(stats.Peers, peer)
+	}
+	sort.Slice(stats.Peers, func(i, j int) bool {
+		if stats.Peers[i].Writes != stats.Peers[j].Writes {
+			return stats.Peers[i].Writes > stats.Peers[j].Writes
+		}
+		return stats.Peers[i].ID < stats.Peers[j].ID
+	})
+	return stats
+}
+
+// Handler serves Stats
+func (q *ForwardQueue) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(q.Stats())
+}
@@ -0,0 +1,177 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// GatewayKeysPath is the prefix of the gateway's key resource,
+// /v1/keys/{key}
+const GatewayKeysPath = "/v1/keys/"
+
+// GatewayBatchPath is the gateway's multi-get resource
+const GatewayBatchPath = "/v1/keys"
+
+// GatewayKeyRequest holds the query parameters of a gateway key request.
+// The key in the path is an application key hashed with KeyFromString
+// unless Hash is false, when it must already be a 160-bit hex key.
+type GatewayKeyRequest struct {
+	Hash bool `param:"hash"`
+}
+
+// RegisterGateway serves the REST gateway on mux, for clients that do not
+// speak the DHT. Each endpoint runs through mws and then
+// middleware.APIKey(apiKeys), and performs a full iterative lookup or
+// store across the network on the caller's behalf.
+func RegisterGateway(mux *http.ServeMux, node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, apiKeys []string, mws ...middleware.Middleware) {
+	chain := middleware.Chain(append(mws, middleware.APIKey(apiKeys))...)
+
+	mux.HandleFunc(GatewayKeysPath, tracing.Middleware("gateway_keys", node.ID, chain("gateway_keys", func(w http.ResponseWriter, r *http.Request) {
+		GatewayKeyHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc(GatewayBatchPath, tracing.Middleware("gateway_get_many", node.ID, chain("gateway_get_many", func(w http.ResponseWriter, r *http.Request) {
+		GatewayBatchHandler(w, r, node, storage, routingTable)
+	})))
+}
+
+// GatewayBatchRequest is the body of a POST /v1/keys, the keys whose
+// values to look up, hashed as path keys are unless hash is false
+type GatewayBatchRequest struct {
+	Keys []string `json:"keys" validate:"required,max=64"` // Up to MaxFindValuesKeys
+}
+
+// GatewayBatchReply holds the values found by a POST /v1/keys under the
+// keys as given, and the keys no node holds
+type GatewayBatchReply struct {
+	Values  []FoundValue `json:"values"`
+	Missing []string     `json:"missing"`
+}
+
+// GatewayBatchHandler handles POST /v1/keys, looking the values of several
+// keys up across the network at once with IterativeFindValues
+func GatewayBatchHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	query := GatewayKeyRequest{Hash: true}
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	var req GatewayBatchRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, MaxValueSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !validateBody(w, &req) {
+		return
+	}
+
+	keys := make([]string, len(req.Keys))
+	for i, key := range req.Keys {
+		if query.Hash {
+			keys[i] = KeyFromString(key)
+		} else if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+			http.Error(w, (&validators.FieldError{Field: "keys", Reason: err.Error()}).Error(), http.StatusBadRequest)
+			return
+		} else {
+			keys[i] = key
+		}
+	}
+
+	values := IterativeFindValues(r.Context(), routingTable, node.ID, storage, keys, LookupOptions{})
+	reply := GatewayBatchReply{Values: []FoundValue{}, Missing: []string{}}
+	for i, key := range req.Keys {
+		value, found := values[keys[i]]
+		if !found {
+			reply.Missing = append(reply.Missing, key)
+			continue
+		}
+		encoded, encoding := models.EncodeValue(value)
+		reply.Values = append(reply.Values, FoundValue{Key: key, Value: encoded, Encoding: encoding})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// GatewayKeyHandler handles /v1/keys/{key}. GET looks the value up across
+// the network and returns it as is, or 404 if no node holds it. PUT stores
+// the request body as the value on the k nodes closest to the key and
+// answers with the StoreAck, 201 if any replica stored it and 502 if none
+// did. The key actually used is returned in the X-Kademlia-Key header.
+func GatewayKeyHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Keys may contain slashes when escaped as %2F
+	escaped := strings.TrimPrefix(r.URL.EscapedPath(), GatewayKeysPath)
+	key, err := url.PathUnescape(escaped)
+	if err != nil || key == "" || strings.Contains(escaped, "/") {
+		http.Error(w, "Expected "+GatewayKeysPath+"{key}", http.StatusNotFound)
+		return
+	}
+	query := GatewayKeyRequest{Hash: true}
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	if query.Hash {
+		key = KeyFromString(key)
+	} else if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+		http.Error(w, (&validators.FieldError{Field: "key", Reason: err.Error()}).Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		value, found := IterativeFindValue(r.Context(), routingTable, node.ID, storage, key, LookupOptions{})
+		if !found {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Kademlia-Key", key)
+		if utf8.ValidString(value) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", ContentTypeOctetStream)
+		}
+		io.WriteString(w, value)
+		return
+	}
+
+	// Read one byte past the limit so oversized values are refused whole
+	value, err := io.ReadAll(io.LimitReader(r.Body, MaxValueSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(value) > MaxValueSize {
+		http.Error(w, "Value too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req := StoreRequest{Key: key, Value: string(value), Policy: models.OverwriteAlways, IdempotencyKey: r.Header.Get("Idempotency-Key")}
+	if !validateBody(w, &req) {
+		return
+	}
+
+	ack := IterativeStore(r.Context(), routingTable, node, storage, req)
+	status := http.StatusCreated
+	if ack.ReplicationFactor == 0 {
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("X-Kademlia-Key", key)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ack)
+}
@@ -0,0 +1,145 @@
+package kademlia
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// EvictionStrategy selects which entries are dropped first when storage
+// exceeds its budget
+type EvictionStrategy string
+
+const (
+	EvictOldest   EvictionStrategy = "ttl"      // Entries stored longest ago go first
+	EvictLRU      EvictionStrategy = "lru"      // Least recently read or written entries go first
+	EvictDistance EvictionStrategy = "distance" // Entries farthest from the local node ID go first
+)
+
+// Eviction reasons passed to OnEvict
+const (
+	EvictReasonExpired  = "expired"
+	EvictReasonPressure = "pressure"
+)
+
+// GCConfig configures the storage garbage collector
+type GCConfig struct {
+	Strategy EvictionStrategy
+	MaxBytes int64         // Storage budget in bytes of keys plus values, 0 means unlimited
+	TTL      time.Duration // Entries older than this always expire, 0 means never
+	Interval time.Duration // How often the node's scheduler runs a collection
+
+	// TombstoneTTL is how long deleted keys keep the tombstone that stops
+	// replicas resurrecting them, 0 means forever. It should exceed the
+	// anti-entropy interval so every replica learns of the delete.
+	TombstoneTTL time.Duration
+
+	// OnEvict, if set, is called for every evicted entry so applications
+	// can archive the value elsewhere. It runs on the collector goroutine.
+	OnEvict func(key, value, reason string)
+}
+
+// GarbageCollector evicts entries from a KeyValueStore by age and budget
+type GarbageCollector struct {
+	storage *models.KeyValueStore
+	localID string
+	cfg     GCConfig
+}
+
+// NewGarbageCollector creates a collector for storage on the node localID
+func NewGarbageCollector(storage *models.KeyValueStore, localID string, cfg GCConfig) *GarbageCollector {
+	return &GarbageCollector{storage: storage, localID: localID, cfg: cfg}
+}
+
+// ValidEvictionStrategy reports whether s is a known strategy
+func ValidEvictionStrategy(s EvictionStrategy) bool {
+	switch s {
+	case EvictOldest, EvictLRU, EvictDistance:
+		return true
+	}
+	return false
+}
+
+// Collect drops tombstones older than the TombstoneTTL and idempotency
+// keys past their window, expires entries
+// older than their own TTL, or else the collector's, then evicts entries in strategy order until storage
+// fits the budget. It returns the number of entries evicted.
+func (gc *GarbageCollector) Collect() int {
+	if gc.cfg.TombstoneTTL > 0 {
+		gc.storage.PurgeTombstones(clock.Now().Add(-gc.cfg.TombstoneTTL))
+	}
+	gc.storage.PruneIdempotencyKeys(clock.Now())
+
+	entries := gc.storage.Entries()
+	evicted := 0
+
+	now := clock.Now()
+	remaining := entries[:0]
+	for _, e := range entries {
+		ttl := gc.cfg.TTL
+		if e.TTL > 0 {
+			ttl = e.TTL
+		}
+		if ttl > 0 && e.StoredAt.Before(now.Add(-ttl)) && gc.evict(e, EvictReasonExpired) {
+			evicted++
+			continue
+		}
+		remaining = append(remaining, e)
+	}
+	entries = remaining
+
+	if gc.cfg.MaxBytes <= 0 || gc.storage.SizeBytes() <= gc.cfg.MaxBytes {
+		return evicted
+	}
+
+	gc.sortForEviction(entries)
+	for _, e := range entries {
+		if gc.storage.SizeBytes() <= gc.cfg.MaxBytes {
+			break
+		}
+		if gc.evict(e, EvictReasonPressure) {
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// sortForEviction orders entries so the first one should be evicted first
+func (gc *GarbageCollector) sortForEviction(entries []models.EntryInfo) {
+	switch gc.cfg.Strategy {
+	case EvictLRU:
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].LastAccess.Before(entries[j].LastAccess)
+		})
+	case EvictDistance:
+		sort.Slice(entries, func(i, j int) bool {
+			_, ki := models.SplitNamespacedKey(entries[i].Key)
+			_, kj := models.SplitNamespacedKey(entries[j].Key)
+			return calculateXORDistance(gc.localID, ki).Cmp(calculateXORDistance(gc.localID, kj)) > 0
+		})
+	default:
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].StoredAt.Before(entries[j].StoredAt)
+		})
+	}
+}
+
+func (gc *GarbageCollector) evict(e models.EntryInfo, reason string) bool {
+	value, ok := gc.storage.Evict(e.Key, e.StoredAt)
+	if !ok {
+		return false
+	}
+
+	eventType := models.ValueEvicted
+	if reason == EvictReasonExpired {
+		eventType = models.ValueExpired
+	}
+	gc.storage.Events.Emit(models.Event{Type: eventType, Key: e.Key, Value: value})
+
+	if gc.cfg.OnEvict != nil {
+		gc.cfg.OnEvict(e.Key, value, reason)
+	}
+	return true
+}
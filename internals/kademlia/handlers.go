@@ -1,103 +1,208 @@
 package kademlia
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"strconv"
+	"time"
 
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
 	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
 	"github.com/Aradhya2708/kademlia/pkg/models"
 )
 
-// PingHandler handles /ping requests
+// MaxPingTokenLength bounds the "token" a PING may ask to have echoed
+const MaxPingTokenLength = 64
+
+// PingHandler handles /ping requests. A node pinging us identifies itself
+// either with a POST whose JSON body is its models.Node, advertising the IP
+// it is reachable at, or with the "id" and "port" query parameters, in
+// which case its IP is taken from the connection. The "token" query
+// parameter is echoed in the PONG, proving to the pinger that the reply
+// comes from the address it pinged.
 func PingHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
 	fmt.Println("Received ping request from:", r.RemoteAddr)
 
-	// Extract pinger details from query parameters
-	pingerID := r.URL.Query().Get("id")
-	pingerPort := r.URL.Query().Get("port")
+	var query PingQuery
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	var pingerNode *models.Node
+	var err error
+	if r.Method == http.MethodPost {
+		pingerNode, err = pingerFromBody(r)
+	} else {
+		pingerNode, err = pingerFromQuery(r, query)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if pingerID != "" && pingerPort != "" {
-		// Pinger is a node, attempt to parse the port
-		pingerUDPPort, err := strconv.Atoi(pingerPort)
-		if err != nil || pingerUDPPort <= 0 || pingerUDPPort > 65535 {
-			http.Error(w, "Invalid UDP port provided", http.StatusBadRequest)
+	if pingerNode != nil {
+		// Attach the pinger's signed record, if it sent one in the header
+		if header := r.Header.Get(RecordHeader); header != "" && pingerNode.Record == nil {
+			record, err := DecodeRecordHeader(header, pingerNode.ID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			pingerNode.Record = record
+		}
+		if err := checkNetwork(node, pingerNode); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
-
-		// Extract the IP address from the RemoteAddr
-		pingerIP, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			http.Error(w, "Failed to extract IP address", http.StatusInternalServerError)
+		if !routingTable.Allowlist.Allows(pingerNode) {
+			http.Error(w, ErrNotAllowlisted.Error(), http.StatusForbidden)
 			return
 		}
 
-		// Add the pinger node to the routing table
-		pingerNode := &models.Node{
-			ID:   pingerID,
-			IP:   pingerIP,
-			Port: pingerUDPPort,
+		pingerNode.MarkSeen(clock.Now())
+		if admitPinger(r.Context(), routingTable, pingerNode, node.ID) {
+			if pingerNode.Record != nil {
+				updateRecord(routingTable, pingerNode.Record)
+			}
+			fmt.Printf("Added node to routing table: ID: %s, IP: %s, Port: %d\n", pingerNode.ID, pingerNode.IP, pingerNode.Port)
 		}
-		AddNodeToRoutingTable(routingTable, pingerNode, node.ID)
-		fmt.Printf("Added node to routing table: ID: %s, IP: %s, Port: %d\n", pingerID, pingerIP, pingerUDPPort)
 	}
 
-	// Debug: Print Current Node Details
-	fmt.Println("Current Node Details:")
-	fmt.Printf("ID: %s, IP: %s, Port: %d\n", node.ID, node.IP, node.Port)
+	// Respond to the pinger
+	response := PingReply{Message: "pong", NodeID: node.ID, Record: node.Record, Network: node.Network}
+	if node.Flags != 0 {
+		response.Flags = node.Flags
+		response.Protocol = node.Protocol
+	}
+	if len(query.Token) <= MaxPingTokenLength {
+		response.Token = query.Token
+	}
+	if query.Filter {
+		response.Filter = keyFilterOf(node, storage)
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		response.ObservedIP = ip
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PingReply is the PONG answering a ping. Flags and Protocol are left out
+// by nodes that do not advertise capabilities. A pinger announcing another
+// network than the node's is refused with 403 instead.
+type PingReply struct {
+	Message  string                 `json:"message"` // Always "pong"
+	NodeID   string                 `json:"node_id"`
+	Record   *models.NodeRecord     `json:"record,omitempty"`
+	Flags    models.CapabilityFlags `json:"flags,omitempty"`
+	Protocol int                    `json:"protocol,omitempty"`
+	Token    string                 `json:"token,omitempty"`   // The pinger's token, echoed
+	Network  string                 `json:"network,omitempty"` // Network the node belongs to, empty for the default network
+
+	// ObservedIP is the IP the ping came from as the node saw it, which
+	// tells a pinger behind NAT or with a new address the IP peers reach it at
+	ObservedIP string `json:"observed_ip,omitempty"`
+
+	// Filter holds the keys the node stores, sent when the ping asks for
+	// it and the node stores values
+	Filter *models.BloomFilter `json:"filter,omitempty"`
+}
 
-	// Debug: Print Routing Table
-	fmt.Println("Routing Table Details:")
-	for i, bucket := range routingTable.Buckets {
-		fmt.Printf("Bucket %d: ", i)
-		for _, n := range bucket.Nodes {
-			fmt.Printf("NodeID: %s, IP: %s, Port: %d | ", n.ID, n.IP, n.Port)
-		}
-		fmt.Println()
+// pingerFromQuery returns the node described by the "id" and "port" query
+// parameters, or nil if they are absent
+func pingerFromQuery(r *http.Request, query PingQuery) (*models.Node, error) {
+	if query.ID == "" {
+		return nil, nil
 	}
 
-	// Debug: Print Key-Value Store
-	fmt.Println("Key-Value Store Contents:")
-	for key, value := range storage.GetAll() {
-		fmt.Printf("Key: %s, Value: %s\n", key, value)
+	// Extract the IP address from the RemoteAddr
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return nil, errors.New("Failed to extract IP address")
 	}
+	return &models.Node{ID: query.ID, IP: ip, Port: query.Port, Network: query.Network}, nil
+}
 
-	// Respond to the pinger
-	response := map[string]interface{}{
-		"message": "pong",
-		"node_id": node.ID,
+// pingerFromBody decodes and validates the node sent as a JSON body
+func pingerFromBody(r *http.Request) (*models.Node, error) {
+	var pinger models.Node
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&pinger); err != nil {
+		return nil, errors.New("Invalid JSON format")
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if err := validateSender(&pinger, r); err != nil {
+		return nil, err
+	}
+	return &pinger, nil
+}
+
+// validateSender checks the node a request says it comes from. An empty or
+// unspecified IP is replaced with the connection's IP.
+func validateSender(sender *models.Node, r *http.Request) error {
+	if err := validators.ValidateID(sender.ID, validators.HexadecimalValidator); err != nil {
+		return fmt.Errorf("Invalid node ID: %v", err)
+	}
+	if sender.Port <= 0 || sender.Port > 65535 {
+		return errors.New("Invalid port provided")
+	}
+
+	ip := net.ParseIP(sender.IP)
+	switch {
+	case sender.IP == "" || (ip != nil && ip.IsUnspecified()):
+		remote, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return errors.New("Failed to extract IP address")
+		}
+		sender.IP = remote
+	case ip == nil || ip.IsMulticast():
+		return fmt.Errorf("Invalid IP address: %q", sender.IP)
+	default:
+		sender.IP = ip.String()
+	}
+
+	if sender.Relay != "" {
+		if host, port, err := net.SplitHostPort(sender.Relay); err != nil || host == "" || port == "" {
+			return fmt.Errorf("Invalid relay address: %q", sender.Relay)
+		}
+	}
+	if sender.Record != nil {
+		if err := verifyRecordFor(sender.Record, sender.ID); err != nil {
+			return err
+		}
+	}
+	sender.LastSeen = 0
+	return nil
 }
 
 // FindNodeHandler handles /find_node requests
 func FindNodeHandler(w http.ResponseWriter, r *http.Request, node *models.Node, routingTable *models.RoutingTable) {
 	fmt.Println("Received ping find node req from:", r.RemoteAddr)
 
-	queryID := r.URL.Query().Get("id")
-
-	err := validators.ValidateID(queryID, validators.HexadecimalValidator)
-
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid ID format: %v", err), http.StatusBadRequest)
+	k := routingTable.BucketSize()
+	req := FindNodeRequest{Count: k, Requester: r.Header.Get(RequesterIDHeader)}
+	if !decodeQuery(w, r, &req) {
 		return
 	}
 
-	if queryID == "" {
-		http.Error(w, "Missing 'id' parameter", http.StatusBadRequest)
-		return
+	// A node looking up contacts already knows itself and us, so both are
+	// left out to make room for k others. Plain clients get the responder
+	// too.
+	var exclude map[string]bool
+	if req.Requester != "" {
+		exclude = map[string]bool{req.Requester: true, node.ID: true}
 	}
 
 	// Find the closest nodes to the query ID
-	closestNodes := FindClosestNodes(routingTable, queryID, node.ID)
+	closestNodes := findClosestNodesExcept(routingTable, req.ID, min(req.Count, k), exclude)
 
-	// Respond with the closest nodes
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(closestNodes)
+	// Respond with the closest nodes, identifying ourselves in the headers
+	writeResponder(w, node)
+	writeWriteToken(w, r, node)
+	writeEncoded(w, r, closestNodes)
 }
 
 // StoreHandler handles /store requests
@@ -107,12 +212,6 @@ func StoreHandler(w http.ResponseWriter, r *http.Request, node *models.Node, sto
 		return
 	}
 
-	// Define a struct to parse incoming JSON
-	var kv struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	}
-
 	// Read and parse the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -121,21 +220,92 @@ func StoreHandler(w http.ResponseWriter, r *http.Request, node *models.Node, sto
 	}
 	defer r.Body.Close()
 
-	err = json.Unmarshal(body, &kv)
-	if err != nil || kv.Key == "" || kv.Value == "" {
+	var kv StoreRequest
+	if err := json.Unmarshal(body, &kv); err != nil {
 		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
+	var query StoreQuery
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	if query.Hash && kv.Key != "" {
+		kv.Key = KeyFromString(kv.Key)
+	}
+	// Validate the size of binary values sent as base64 by their bytes
+	if kv.Value, err = models.DecodeValue(kv.Value, kv.Encoding); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	kv.Encoding = ""
+	if !validateBody(w, &kv) {
+		return
+	}
+	kv.Hops++
 
-	err = validators.ValidateID(kv.Key, validators.HexadecimalValidator)
+	if kv.Policy == "" {
+		kv.Policy = models.OverwriteAlways
+	}
+	if !models.ValidOverwritePolicy(kv.Policy) {
+		http.Error(w, fmt.Sprintf("Invalid overwrite policy: %s", kv.Policy), http.StatusBadRequest)
+		return
+	}
+	if !models.ValidRecordType(kv.Type) {
+		http.Error(w, fmt.Sprintf("Invalid record type: %s", kv.Type), http.StatusBadRequest)
+		return
+	}
+	if kv.Type != models.RecordValue {
+		if _, err := models.ParseCRDTRecord(kv.Value, kv.Type); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if kv.IdempotencyKey == "" {
+		kv.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
 
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid Key format: %v", err), http.StatusBadRequest)
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+	kv.Namespace = namespace
+	kv.NamespaceToken = r.Header.Get(NamespaceTokenHeader)
+
+	// Store on the k closest nodes on behalf of the client
+	if kv.Replicate {
+		ack := IterativeStore(r.Context(), routingTable, node, storage, kv)
+		status := http.StatusCreated
+		if ack.ReplicationFactor == 0 {
+			status = http.StatusBadGateway
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ack)
+		return
+	}
+
+	// Client-only nodes keep nothing on behalf of others
+	if !node.Supports(models.FlagStorage) {
+		http.Error(w, "This node does not accept STOREs", http.StatusForbidden)
+		return
+	}
+
+	if !allowedWriter(routingTable, r) {
+		writeStoreConflict(w, ErrNotAllowlisted)
+		return
+	}
+	if !checkWriteToken(r, node, storage, kv.Token) {
+		writeStoreConflict(w, ErrInvalidWriteToken)
+		return
+	}
+	if storage.RejectDistant && !InResponsibility(routingTable, node.ID, kv.Key) {
+		notePlacementOutside(storage, node.ID, kv.Key, r.RemoteAddr)
+		writeStoreConflict(w, ErrOutsideResponsibility)
 		return
 	}
 
 	// Find the k closest nodes to the key
-	closestNodes := FindClosestNodes(routingTable, kv.Key, node.ID)
+	closestNodes := FindClosestNodes(routingTable, kv.Key, node.ID, 0)
 
 	// // Calculate the XOR distance of this node to the key
 	// ownDistance := calculateXORDistance(node.ID, kv.Key) ? why
@@ -152,47 +322,470 @@ func StoreHandler(w http.ResponseWriter, r *http.Request, node *models.Node, sto
 	// If not among the closest, respond with the k closest nodes
 	if !isClosest {
 		fmt.Println("Node is not among the closest nodes, returning closest nodes")
+		notePlacementMiss(storage, node, kv.Key, r.RemoteAddr, closestNodes)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(closestNodes)
 		return
 	}
 
 	// Store the key-value pair if the node is among the closest
-	storage.Set(kv.Key, kv.Value)
-	fmt.Println("Stored key-value pair:", kv.Key, kv.Value)
+	storageKey := models.NamespacedKey(namespace, kv.Key)
+	idempotencyKey := kv.IdempotencyKey
+	if idempotencyKey != "" {
+		idempotencyKey = models.NamespacedKey(namespace, idempotencyKey)
+	}
+	var replayed bool
+	if kv.PublishedAt != nil {
+		err = storeBackup(storage, storageKey, kv)
+	} else if kv.Type != models.RecordValue {
+		err = storeTyped(storage, storageKey, kv)
+	} else {
+		replayed, err = storage.PutWithHops(storageKey, kv.Value, kv.Publisher, kv.Hops, kv.Policy, idempotencyKey)
+	}
+	if err != nil {
+		writeStoreConflict(w, err)
+		return
+	}
+	if kv.PublishedAt == nil {
+		setIntervals(storage, kv)
+	}
+	storage.Placement.Accept()
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		fmt.Println("Stored key-value pair:", kv.Key, kv.Value)
+	}
 
-	// Respond with success
+	// Acknowledge with this node as the only replica
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintf(w, "Stored key: %s, value: %s", kv.Key, kv.Value)
+	json.NewEncoder(w).Encode(StoreAck{
+		Key:               kv.Key,
+		Replicas:          []ReplicaAck{newReplicaAck(node, nil)},
+		ReplicationFactor: 1,
+	})
+}
+
+// writeStoreConflict responds with a typed error describing which
+// overwrite, quota or responsibility rule the STORE violated, or that the
+// storage backend failed, tagged with the request ID
+func writeStoreConflict(w http.ResponseWriter, err error) {
+	status := http.StatusConflict
+	code := "conflict"
+	switch err {
+	case models.ErrQuotaExceeded:
+		status = http.StatusInsufficientStorage
+		code = "quota_exceeded"
+	case models.ErrKeyExists:
+		code = "key_exists"
+	case models.ErrPublisherMismatch:
+		code = "publisher_mismatch"
+	case models.ErrIdempotencyKeyReused:
+		code = "idempotency_key_reused"
+	case models.ErrDeleted:
+		code = "deleted"
+	case ErrNotLease:
+		code = "not_lease"
+	case models.ErrRecordType:
+		code = "record_type_mismatch"
+	case ErrOutsideResponsibility:
+		status = http.StatusForbidden
+		code = "outside_responsibility"
+	case ErrInvalidWriteToken:
+		status = http.StatusForbidden
+		code = writeTokenErrorCode
+	case ErrNotAllowlisted:
+		status = http.StatusForbidden
+		code = "not_allowlisted"
+	default:
+		// The storage backend failed
+		status = http.StatusInternalServerError
+		code = "storage_error"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorReply{
+		Error:     code,
+		Message:   err.Error(),
+		RequestID: w.Header().Get(tracing.RequestIDHeader),
+	})
+}
+
+// ErrorReply is the typed error body of a refused STORE
+type ErrorReply struct {
+	Error     string `json:"error"` // Machine-readable code, such as "key_exists"
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// Headers selecting and authorizing the namespace of a STORE or FIND_VALUE.
+// The namespace may also be passed as the "namespace" query parameter.
+const (
+	NamespaceHeader      = "X-Kademlia-Namespace"
+	NamespaceTokenHeader = "X-Kademlia-Namespace-Token"
+)
+
+// resolveNamespace returns the request's namespace after checking its name
+// and token, writing an error response and returning false on failure
+func resolveNamespace(w http.ResponseWriter, r *http.Request, storage *models.KeyValueStore) (string, bool) {
+	namespace := r.Header.Get(NamespaceHeader)
+	if namespace == "" {
+		namespace = r.URL.Query().Get("namespace")
+	}
+	if err := models.ValidateNamespace(namespace); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid namespace: %v", err), http.StatusBadRequest)
+		return "", false
+	}
+
+	if want := storage.NamespacePolicy(namespace).Token; want != "" {
+		got := r.Header.Get(NamespaceTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			http.Error(w, "Invalid or missing namespace token", http.StatusUnauthorized)
+			return "", false
+		}
+	}
+	return namespace, true
+}
+
+// FindValueReply is the reply to a FIND_VALUE with meta=true that found
+// the value, and to one for a binary value from a peer that does not
+// accept ContentTypeOctetStream
+type FindValueReply struct {
+	Value    string            `json:"value"`
+	Encoding string            `json:"encoding,omitempty"` // models.ValueEncodingBase64 if Value is the base64 of a binary value
+	Meta     models.RecordMeta `json:"meta"`
+}
+
+// Decoded returns the bytes of the reply's value
+func (r FindValueReply) Decoded() (string, error) {
+	return models.DecodeValue(r.Value, r.Encoding)
 }
 
 // FindValueHandler handles /find_value requests
 func FindValueHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
-	queryKey := r.URL.Query().Get("key")
-	if queryKey == "" {
-		http.Error(w, "Missing 'key' parameter", http.StatusBadRequest)
+	k := routingTable.BucketSize()
+	req := FindValueRequest{Count: k}
+	if !decodeQuery(w, r, &req) {
 		return
 	}
+	queryKey := req.Key
 
-	err := validators.ValidateID(queryKey, validators.HexadecimalValidator)
-
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid Key format: %v", err), http.StatusBadRequest)
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
 		return
 	}
 
 	// Look up the value in storage
-	if value, exists := storage.Store[queryKey]; exists {
-		// Respond with the value
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(value)
+	writeWriteToken(w, r, node)
+	storageKey := models.NamespacedKey(namespace, queryKey)
+	if value, meta, exists := storage.GetWithMeta(storageKey); exists {
+		// Tag the value, so polling clients are only sent a changed one,
+		// and answer HEAD with its provenance alone
+		etag := valueETag(meta)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", meta.StoredAt.UTC().Format(http.TimeFormat))
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == http.MethodHead {
+			writeMetaHeader(w, meta)
+			return
+		}
+
+		// Respond with the value, and its provenance if asked for
+		encoded, encoding := models.EncodeValue(value)
+		if req.Meta {
+			writeEncoded(w, r, FindValueReply{Value: encoded, Encoding: encoding, Meta: meta})
+			return
+		}
+		switch {
+		case accepts(r, ContentTypeOctetStream):
+			w.Header().Set("Content-Type", ContentTypeOctetStream)
+			io.WriteString(w, value)
+		case encoding != "":
+			writeEncoded(w, r, FindValueReply{Value: encoded, Encoding: encoding})
+		default:
+			writeEncoded(w, r, value)
+		}
 	} else {
 		// Key not found, respond with a 404
 		// http.Error(w, fmt.Sprintf("Key '%s' not found", queryKey), http.StatusNotFound)
 
-		// key not found, respond as FIND_NODE res
-		closestNodes := FindClosestNodes(routingTable, queryKey, node.ID)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(closestNodes)
+		// key not found, respond as FIND_NODE res; HEAD has no body
+		// to carry the contacts
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		closestNodes := FindClosestNodes(routingTable, queryKey, node.ID, min(req.Count, k))
+		writeEncoded(w, r, closestNodes)
+	}
+}
+
+// PeersHandler handles /peers requests
+func PeersHandler(w http.ResponseWriter, r *http.Request, node *models.Node, routingTable *models.RoutingTable) {
+	req := PeersRequest{Count: routingTable.BucketSize(), Radius: validators.HexadecimalValidator.Length * 4}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	policy := routingTable.PeerSampling
+	if req.Sample != "" {
+		policy = req.Sample
+	}
+	sampler := PeerSamplerNamed(policy)
+	if sampler == nil {
+		http.Error(w, fmt.Sprintf("Unknown sampling policy %q", policy), http.StatusBadRequest)
+		return
+	}
+	count := req.Count
+	if routingTable.MaxExchangePeers > 0 {
+		count = min(count, routingTable.MaxExchangePeers)
+	}
+
+	peers := SamplePeersWith(sampler, routingTable, req.Key, req.Radius, count, node.ID)
+	writeEncoded(w, r, peers)
+}
+
+// IterateKeysHandler handles /iterate_keys requests, paging through the
+// stored records near a target ID so joining nodes can pull the records
+// they are responsible for
+func IterateKeysHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore) {
+	req := IterateKeysRequest{Target: node.ID, Radius: validators.HexadecimalValidator.Length * 4, Limit: DefaultIterateLimit}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	page, err := IterateKeys(storage, req.Target, req.Radius, req.Limit, req.Token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeEncoded(w, r, page)
+}
+
+// SyncDigestHandler handles /sync_digest requests. Without a bucket it
+// returns the per-bucket hashes of the records near target; with one it
+// returns that bucket's records. Either carries the write token for the
+// /sync_push that may follow.
+func SyncDigestHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore) {
+	req := SyncDigestRequest{Target: node.ID, Radius: validators.HexadecimalValidator.Length * 4}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	writeWriteToken(w, r, node)
+	if req.Bucket != "" {
+		writeEncoded(w, r, DigestBucket(storage, req.Target, req.Radius, req.Bucket))
+		return
+	}
+	writeEncoded(w, r, Digest(storage, req.Target, req.Radius))
+}
+
+// SyncPushHandler handles /sync_push requests, storing the records a
+// replica found missing here. A push carries the records of one namespace
+// and is admitted like a STORE: it must present the namespace's token and,
+// where required, a write token, and each record is then admitted by
+// admitSynced.
+func SyncPushHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if !node.Supports(models.FlagStorage) {
+		http.Error(w, "This node does not accept STOREs", http.StatusForbidden)
+		return
 	}
+	if !allowedWriter(routingTable, r) {
+		writeStoreConflict(w, ErrNotAllowlisted)
+		return
+	}
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+	if !checkWriteToken(r, node, storage, r.Header.Get(WriteTokenHeader)) {
+		writeStoreConflict(w, ErrInvalidWriteToken)
+		return
+	}
+
+	var records []KeyRecord
+	if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	stored := 0
+	for _, rec := range records {
+		if ns, _ := models.SplitNamespacedKey(rec.Key); ns == namespace && admitSynced(storage, routingTable, node.ID, rec, r.RemoteAddr) {
+			stored++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SyncPushReply{Stored: stored})
+}
+
+// SyncPushReply counts the records a /sync_push stored
+type SyncPushReply struct {
+	Stored int `json:"stored"`
+}
+
+// AddProviderHandler handles /add_provider requests
+func AddProviderHandler(w http.ResponseWriter, r *http.Request, node *models.Node, providers *models.ProviderStore) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AddProviderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if !validateBody(w, &req) {
+		return
+	}
+
+	// The provider is reached at the address the announcement came from,
+	// so no one can point others at a host of their choosing
+	if err := providers.Add(req.Key, models.Node{ID: req.ID, IP: remoteIP(r), Port: req.Port}); err != nil {
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	fmt.Printf("Added provider %s for key %s\n", req.ID, req.Key)
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "Added provider: %s, key: %s", req.ID, req.Key)
+}
+
+// GetProvidersHandler handles /get_providers requests
+func GetProvidersHandler(w http.ResponseWriter, r *http.Request, node *models.Node, providers *models.ProviderStore, routingTable *models.RoutingTable) {
+	var req GetProvidersRequest
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	queryKey := req.Key
+
+	// Always include closer nodes so the caller can continue the lookup
+	writeEncoded(w, r, ProvidersReply{
+		Providers:    providers.Get(queryKey),
+		ClosestNodes: FindClosestNodes(routingTable, queryKey, node.ID, 0),
+	})
+}
+
+// ProvidersReply lists the known providers of a key, with the closest
+// nodes to it for continuing the lookup
+type ProvidersReply struct {
+	Providers    []models.Node  `json:"providers"`
+	ClosestNodes []*models.Node `json:"closest_nodes"`
+}
+
+// PoolStatsHandler handles /pool_stats requests
+func PoolStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(network.Stats())
+}
+
+// SubscribeHandler handles /subscribe requests
+func SubscribeHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable, pubsub *PubSub) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Topic == "" || req.SubscriberID == "" {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	rec, err := pubsub.Subscribe(storage, req.Topic, models.Subscriber{ID: req.SubscriberID, Webhook: req.Webhook})
+	switch {
+	case errors.Is(err, ErrWebhookTarget):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Printf("Subscriber %s subscribed to topic %s\n", req.SubscriberID, req.Topic)
+
+	// Replicas merge the list into theirs, so any of them can stand in as
+	// the rendezvous
+	ack := IterativeStore(r.Context(), routingTable, node, storage, StoreRequest{Key: TopicKey(req.Topic), Value: rec.Encode(), Type: models.RecordORSet, Publisher: node.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SubscribeReply{Topic: req.Topic, Key: TopicKey(req.Topic), NodeID: node.ID, Replicas: ack.ReplicationFactor})
+}
+
+// SubscribeRequest subscribes SubscriberID to Topic. With a Webhook the
+// rendezvous node POSTs every message to it instead of queueing it for
+// /poll.
+type SubscribeRequest struct {
+	Topic        string `json:"topic" validate:"required"`
+	SubscriberID string `json:"subscriber_id" validate:"required"`
+	Webhook      string `json:"webhook,omitempty"`
+}
+
+// SubscribeReply confirms a subscription on the rendezvous node NodeID
+type SubscribeReply struct {
+	Topic    string `json:"topic"`
+	Key      string `json:"key"` // TopicKey of Topic
+	NodeID   string `json:"node_id"`
+	Replicas int    `json:"replicas"` // Nodes holding the subscriber list, the rendezvous included
+}
+
+// PublishHandler handles /publish requests
+func PublishHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, pubsub *PubSub) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PublishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Topic == "" {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	delivered := pubsub.Publish(storage, req.Topic, req.Payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PublishReply{Topic: req.Topic, Delivered: delivered, NodeID: node.ID})
+}
+
+// PublishRequest publishes Payload to the subscribers of Topic
+type PublishRequest struct {
+	Topic   string `json:"topic" validate:"required"`
+	Payload string `json:"payload"`
+}
+
+// PublishReply counts the subscribers a message was delivered to
+type PublishReply struct {
+	Topic     string `json:"topic"`
+	Delivered int    `json:"delivered"`
+	NodeID    string `json:"node_id"`
+}
+
+// PollHandler handles /poll long-poll requests
+func PollHandler(w http.ResponseWriter, r *http.Request, pubsub *PubSub) {
+	req := PollRequest{Timeout: 30}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	messages := pubsub.Poll(r.Context(), req.Topic, req.SubscriberID, time.Duration(req.Timeout)*time.Second)
+	if messages == nil {
+		messages = []models.TopicMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
 }
@@ -10,8 +10,26 @@ import (
 
 // TODO: use IP:PORT to generate a unique ID
 func GenerateNodeID() string {
+	if Deterministic() {
+		id := make([]byte, sha1.Size)
+		randReader().Read(id)
+		return hex.EncodeToString(id)
+	}
 	rand.Seed(time.Now().UnixNano())
 	randomData := fmt.Sprintf("%d-%d", rand.Int63(), time.Now().UnixNano())
 	hash := sha1.Sum([]byte(randomData))
 	return hex.EncodeToString(hash[:])
 }
+
+// KeyFromString hashes an arbitrary application key, such as "user:42",
+// into a 160-bit key in the DHT keyspace
+func KeyFromString(s string) string {
+	return KeyFromBytes([]byte(s))
+}
+
+// KeyFromBytes hashes arbitrary bytes into a 160-bit key in the DHT
+// keyspace
+func KeyFromBytes(b []byte) string {
+	hash := sha1.Sum(b)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,125 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Version is the software version reported by /node_info. Release builds
+// set it with -ldflags "-X github.com/Aradhya2708/kademlia/internals/kademlia.Version=v1.2.3".
+var Version = "dev"
+
+// MinProtocolVersion is the oldest RPC protocol version this
+// implementation can join a network through
+const MinProtocolVersion = 1
+
+// NodeInfo describes a node's software, parameters and load as served by
+// /node_info, for crawlers, operators and joining nodes
+type NodeInfo struct {
+	NodeID         string                 `json:"node_id"`
+	Version        string                 `json:"version"`         // Software version
+	Protocol       int                    `json:"protocol"`        // RPC protocol version
+	MessageVersion int                    `json:"message_version"` // Message envelope version
+	UptimeSeconds  float64                `json:"uptime_seconds"`  // Time since the node started serving RPCs
+	IDBits         int                    `json:"id_bits"`         // Size of node IDs and keys
+	K              int                    `json:"k"`
+	Alpha          int                    `json:"alpha"`
+	Keys           int                    `json:"keys"`              // Stored keys
+	Bytes          int64                  `json:"bytes"`             // Size of the stored values
+	Contacts       int                    `json:"contacts"`          // Routing table contacts, excluding the node itself
+	Flags          models.CapabilityFlags `json:"flags"`             // Services the node offers
+	Network        string                 `json:"network,omitempty"` // Network the node belongs to, empty for the default network
+
+	// LookupCache counts the node lookups answered from the cache, unset
+	// when lookups are not cached
+	LookupCache *models.LookupCacheStats `json:"lookup_cache,omitempty"`
+
+	// KeyFilter holds the stored keys, sent when asked for with filter=true
+	KeyFilter *models.BloomFilter `json:"key_filter,omitempty"`
+}
+
+// DescribeNode returns the NodeInfo of node, which started serving at
+// started
+func DescribeNode(node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, started time.Time) NodeInfo {
+	contacts := 0
+	for _, n := range routingTable.Contacts() {
+		if n.ID != node.ID {
+			contacts++
+		}
+	}
+	info := NodeInfo{
+		NodeID:         node.ID,
+		Version:        Version,
+		Protocol:       models.ProtocolVersion,
+		MessageVersion: models.MessageVersion,
+		UptimeSeconds:  clock.Now().Sub(started).Seconds(),
+		IDBits:         len(node.ID) * 4,
+		K:              routingTable.BucketSize(),
+		Alpha:          constants.GetAlpha(),
+		Keys:           storage.Len(),
+		Bytes:          storage.SizeBytes(),
+		Contacts:       contacts,
+		Flags:          node.Flags,
+		Network:        node.Network,
+	}
+	if routingTable.Lookups != nil {
+		stats := routingTable.Lookups.Stats()
+		info.LookupCache = &stats
+	}
+	return info
+}
+
+// NodeInfoHandler handles /node_info requests
+func NodeInfoHandler(w http.ResponseWriter, r *http.Request, node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, started time.Time) {
+	var query NodeInfoRequest
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	info := DescribeNode(node, routingTable, storage, started)
+	if query.Filter {
+		info.KeyFilter = keyFilterOf(node, storage)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// SendNodeInfo asks the node at addr for its NodeInfo
+func SendNodeInfo(ctx context.Context, addr string) (NodeInfo, error) {
+	var info NodeInfo
+	err := rpcGet(ctx, addr, "/node_info", &info)
+	return info, err
+}
+
+// checkCompatibility asks the node at addr for its NodeInfo and fails if it
+// speaks a protocol node cannot or uses IDs of another size. Nodes that predate /node_info are
+// taken to be compatible, as their PING already succeeded; a differing k
+// is only logged, since lookups still converge.
+func checkCompatibility(ctx context.Context, node *models.Node, routingTable *models.RoutingTable, addr string) error {
+	info, err := SendNodeInfo(ctx, addr)
+	var status *StatusError
+	if errors.As(err, &status) && status.Code == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get node info: %v", err)
+	}
+	if info.Protocol != 0 && info.Protocol < MinProtocolVersion {
+		return fmt.Errorf("node %s speaks protocol %d, older than the minimum %d", info.NodeID, info.Protocol, MinProtocolVersion)
+	}
+	if info.IDBits != 0 && info.IDBits != len(node.ID)*4 {
+		return fmt.Errorf("node %s uses %d-bit IDs, this node %d-bit IDs", info.NodeID, info.IDBits, len(node.ID)*4)
+	}
+	if info.K != 0 && info.K != routingTable.BucketSize() {
+		log.Printf("Node %s uses k=%d, this node k=%d", info.NodeID, info.K, routingTable.BucketSize())
+	}
+	return nil
+}
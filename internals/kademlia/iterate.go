@@ -0,0 +1,184 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Paging limits of /iterate_keys
+const (
+	DefaultIterateLimit = 100
+	MaxIterateLimit     = 1000
+)
+
+// ErrInvalidIterationToken is returned for a pagination token this node did
+// not issue
+var ErrInvalidIterationToken = errors.New("invalid iteration token")
+
+// KeyRecord is a stored key-value pair as returned by /iterate_keys. Key
+// includes the namespace prefix, if any. Anti-entropy also exchanges
+// tombstones as records with DeletedAt set and an empty Value; one whose
+// delete the publisher signed carries the publisher, its PublicKey and
+// the Signature of SignDelete, dated DeletedAt.
+type KeyRecord struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	Publisher string     `json:"publisher,omitempty"` // Empty for values stored without a publisher
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	PublicKey string     `json:"public_key,omitempty"`
+	Signature string     `json:"signature,omitempty"`
+}
+
+// MarshalJSON writes the record with its value encoded by
+// models.EncodeValue, so binary values survive the JSON
+func (r KeyRecord) MarshalJSON() ([]byte, error) {
+	type plain KeyRecord
+	value, encoding := models.EncodeValue(r.Value)
+	r.Value = value
+	return json.Marshal(struct {
+		plain
+		Encoding string `json:"encoding,omitempty"`
+	}{plain(r), encoding})
+}
+
+// UnmarshalJSON reads a record written by MarshalJSON
+func (r *KeyRecord) UnmarshalJSON(data []byte) error {
+	type plain KeyRecord
+	var rec struct {
+		plain
+		Encoding string `json:"encoding,omitempty"`
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	value, err := models.DecodeValue(rec.Value, rec.Encoding)
+	if err != nil {
+		return err
+	}
+	rec.Value = value
+	*r = KeyRecord(rec.plain)
+	return nil
+}
+
+// KeyPage is one page of /iterate_keys results. NextToken is empty on the
+// last page.
+type KeyPage struct {
+	Records   []KeyRecord `json:"records"`
+	NextToken string      `json:"next_token,omitempty"`
+}
+
+// IterateKeys returns up to limit records whose key lies within XOR
+// distance 2^radius of target, ordered by distance and then key. Passing
+// the previous page's NextToken as token resumes after its last record;
+// records written in the meantime are included if they sort later.
+func IterateKeys(storage *models.KeyValueStore, target string, radius, limit int, token string) (KeyPage, error) {
+	if limit <= 0 {
+		limit = DefaultIterateLimit
+	}
+
+	var after *iterPos
+	if token != "" {
+		key, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return KeyPage{}, ErrInvalidIterationToken
+		}
+		after = newIterPos(target, string(key))
+	}
+
+	var positions []*iterPos
+	values := make(map[string]string)
+	storage.Scan(func(rec models.StoredRecord) bool {
+		pos := newIterPos(target, rec.Key)
+		if pos.distance.BitLen() > radius {
+			return true
+		}
+		if after != nil && !after.less(pos) {
+			return true
+		}
+		positions = append(positions, pos)
+		values[rec.Key] = rec.Value
+		return true
+	})
+	sort.Slice(positions, func(i, j int) bool { return positions[i].less(positions[j]) })
+
+	page := KeyPage{Records: make([]KeyRecord, 0, limit)}
+	for i, pos := range positions {
+		if i == limit {
+			last := page.Records[len(page.Records)-1].Key
+			page.NextToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			break
+		}
+		page.Records = append(page.Records, KeyRecord{Key: pos.key, Value: values[pos.key]})
+	}
+	return page, nil
+}
+
+// iterPos orders storage keys for iteration
+type iterPos struct {
+	key      string
+	distance *big.Int
+}
+
+func newIterPos(target, key string) *iterPos {
+	_, raw := models.SplitNamespacedKey(key)
+	return &iterPos{key: key, distance: calculateXORDistance(target, raw)}
+}
+
+func (p *iterPos) less(other *iterPos) bool {
+	if c := p.distance.Cmp(other.distance); c != 0 {
+		return c < 0
+	}
+	return p.key < other.key
+}
+
+// SendIterateKeys fetches one page of peer's records near target
+func SendIterateKeys(ctx context.Context, peer *models.Node, target string, radius, limit int, token string) (KeyPage, error) {
+	query := url.Values{}
+	query.Set("target", target)
+	query.Set("radius", strconv.Itoa(radius))
+	query.Set("limit", strconv.Itoa(limit))
+	if token != "" {
+		query.Set("token", token)
+	}
+
+	var page KeyPage
+	err := rpcGet(ctx, peerAddr(peer), "/iterate_keys?"+query.Encode(), &page)
+	return page, err
+}
+
+// PullRecords copies into storage every record peer holds that lies closer
+// to localID than to peer, the records a newly joined node becomes
+// responsible for. It returns the number of records copied.
+func PullRecords(ctx context.Context, localID string, storage *models.KeyValueStore, peer *models.Node) (int, error) {
+	radius := distanceBitLen(localID, peer.ID) - 1
+	if radius < 0 {
+		return 0, nil
+	}
+
+	pulled := 0
+	token := ""
+	for {
+		page, err := SendIterateKeys(ctx, peer, localID, radius, MaxIterateLimit, token)
+		if err != nil {
+			return pulled, err
+		}
+		for _, rec := range page.Records {
+			if storage.Set(rec.Key, rec.Value) == nil {
+				pulled++
+			}
+		}
+		if page.NextToken == "" {
+			return pulled, nil
+		}
+		token = page.NextToken
+	}
+}
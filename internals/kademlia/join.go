@@ -1,59 +1,178 @@
 package kademlia
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
-	"strings"
+	"time"
 
+	"github.com/Aradhya2708/kademlia/internals/retry"
 	"github.com/Aradhya2708/kademlia/pkg/models"
 )
 
-func JoinNetwork(node *models.Node, routingTable *models.RoutingTable, bootstrapAddr string) error {
+func JoinNetwork(ctx context.Context, node *models.Node, routingTable *models.RoutingTable, bootstrapAddr string) error {
+	_, err := joinNetwork(ctx, node, routingTable, bootstrapAddr)
+	return err
+}
+
+// joinNetwork pings bootstrapAddr, adding the node there to the routing
+// table, and returns it
+func joinNetwork(ctx context.Context, node *models.Node, routingTable *models.RoutingTable, bootstrapAddr string) (*models.Node, error) {
 	// Parse IP and port from bootstrapAddr
-	parts := strings.Split(bootstrapAddr, ":")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid bootstrap address format, expected <ip>:<port>")
-	}
-	ip := parts[0]
-	port, err := strconv.Atoi(parts[1])
+	ip, portStr, err := net.SplitHostPort(bootstrapAddr)
 	if err != nil {
-		return fmt.Errorf("invalid port in bootstrap address: %v", err)
+		return nil, fmt.Errorf("invalid bootstrap address format, expected <ip>:<port>")
 	}
-
-	// Construct the ping request URL with query parameters
-	url := fmt.Sprintf("http://%s/ping?id=%s&port=%d", bootstrapAddr, node.ID, node.Port)
-
-	// Send a GET request to the bootstrap node
-	resp, err := http.Get(url)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to join network: %v", err)
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in bootstrap address: %v", err)
 	}
-	defer resp.Body.Close()
 
-	// Parse the response to get the bootstrap node's ID
-	var response struct {
-		Message string `json:"message"` // Expected to be "pong"
-		NodeID  string `json:"node_id"`
+	// Ping the bootstrap node, announcing our contact details and record
+	response, err := announce(ctx, node, bootstrapAddr, true)
+	if errors.Is(err, errPingToken) {
+		return nil, fmt.Errorf("invalid response from bootstrap node: %v", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("failed to decode response from bootstrap node: %v", err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join network: %v", err)
 	}
 
 	// Ensure the response contains a valid NodeID
 	if response.NodeID == "" {
-		return fmt.Errorf("invalid response from bootstrap node: missing node ID")
+		return nil, fmt.Errorf("invalid response from bootstrap node: missing node ID")
+	}
+	if response.Record != nil {
+		if err := verifyRecordFor(response.Record, response.NodeID); err != nil {
+			return nil, fmt.Errorf("invalid response from bootstrap node: %v", err)
+		}
+	}
+	// A bootstrap node of another network may predate network IDs and
+	// have answered anyway
+	if err := checkNetwork(node, &models.Node{ID: response.NodeID, Network: response.Network}); err != nil {
+		return nil, fmt.Errorf("incompatible bootstrap node: %v", err)
+	}
+
+	if err := checkCompatibility(ctx, node, routingTable, bootstrapAddr); err != nil {
+		return nil, fmt.Errorf("incompatible bootstrap node: %v", err)
 	}
 
 	// Add bootstrap node to the routing table
 	bootstrapNode := &models.Node{
-		ID:   response.NodeID,
-		IP:   ip,
-		Port: port,
+		ID:       response.NodeID,
+		IP:       ip,
+		Port:     port,
+		Flags:    response.Flags,
+		Protocol: response.Protocol,
+		Record:   response.Record,
+		Network:  response.Network,
+	}
+	if !routingTable.Allowlist.Allows(bootstrapNode) {
+		return nil, fmt.Errorf("bootstrap node %s: %v", response.NodeID, ErrNotAllowlisted)
 	}
 	AddNodeToRoutingTable(routingTable, bootstrapNode, node.ID)
+	routingTable.Filters.Set(response.NodeID, response.Filter)
+	if response.Record != nil {
+		updateRecord(routingTable, response.Record)
+	}
 	fmt.Printf("Successfully joined network via bootstrap node: ID=%s, IP=%s, Port=%d\n", response.NodeID, ip, port)
 
-	return nil
+	return bootstrapNode, nil
+}
+
+// errPingToken is returned by announce for a PONG that did not echo the
+// ping's token
+var errPingToken = errors.New("PONG did not echo the PING token")
+
+// announce pings addr, announcing node's contact details and record, and
+// returns the PONG, with the stored keys' filter if filter is set. The
+// query parameters let nodes that predate POST pings add node too. Only a
+// PONG echoing a random token is trusted, so a reply forged from another
+// address cannot plant a contact.
+func announce(ctx context.Context, node *models.Node, addr string, filter bool) (*PingReply, error) {
+	header := make(http.Header)
+	if node.Record != nil {
+		encoded, err := EncodeRecordHeader(node.Record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode node record: %v", err)
+		}
+		header.Set(RecordHeader, encoded)
+	}
+	self := models.Node{ID: node.ID, IP: node.IP, Port: node.Port, Flags: node.Flags, Protocol: node.Protocol, Relay: node.Relay, Record: node.Record, Network: node.Network}
+	token := newPingToken()
+	path := fmt.Sprintf("/ping?id=%s&port=%d&token=%s", node.ID, node.Port, token)
+	if filter {
+		path += "&filter=true"
+	}
+	if node.Network != "" {
+		path += "&network=" + url.QueryEscape(node.Network)
+	}
+	var reply PingReply
+	err := retry.Do(ctx, retry.For(ctx), func(ctx context.Context) error {
+		return rpcPostWithHeader(ctx, addr, path, header, self, &reply)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reply.Token != token {
+		return nil, errPingToken
+	}
+	return &reply, nil
+}
+
+// JoinRetry bounds the attempts of JoinWithRetry. The delay between
+// attempts starts at Backoff and doubles up to MaxBackoff, with Jitter of
+// it randomized; Attempts of 0 retries until the context is cancelled.
+type JoinRetry struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Jitter     float64
+}
+
+// JoinWithRetry calls JoinBootstrap until it succeeds, the attempts are used
+// up or ctx is cancelled, returning the last error in the latter cases.
+// Each attempt pings the bootstrap nodes once; JoinWithRetry replaces the
+// per-RPC retries rather than multiplying them.
+func JoinWithRetry(ctx context.Context, node *models.Node, routingTable *models.RoutingTable, bootstrapAddr string, join JoinRetry) error {
+	policy := retry.Policy{
+		Attempts:   join.Attempts,
+		Backoff:    join.Backoff,
+		MaxBackoff: join.MaxBackoff,
+		Jitter:     join.Jitter,
+		Retryable:  func(error) bool { return true },
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			log.Printf("Join attempt %d via %s failed, retrying in %v: %v", attempt, bootstrapAddr, delay, err)
+		},
+	}
+	if policy.Attempts == 0 {
+		policy.Attempts = math.MaxInt
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = time.Second
+	}
+
+	attempts := 0
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		attempts++
+		return JoinBootstrap(retry.WithPolicy(ctx, retry.Policy{Attempts: 1}), node, routingTable, bootstrapAddr)
+	})
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("giving up after %d attempts: %v", attempts, err)
+	}
+	return err
+}
+
+// newPingToken returns a random token for a PING to echo, drawn from the
+// seeded sequence in deterministic mode
+func newPingToken() string {
+	token := make([]byte, 8)
+	randReader().Read(token)
+	return hex.EncodeToString(token)
 }
@@ -0,0 +1,94 @@
+package kademlia
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// KeyFilterMaxAge is how long a contact's key filter is trusted unless the
+// node configures a refresh interval
+const KeyFilterMaxAge = 10 * time.Minute
+
+// keyFilterOf returns the Bloom filter of the keys node stores, nil if it
+// does not store values for others
+func keyFilterOf(node *models.Node, storage *models.KeyValueStore) *models.BloomFilter {
+	if storage == nil || !node.Supports(models.FlagStorage) {
+		return nil
+	}
+	return storage.KeyFilter()
+}
+
+// SendKeyFilter asks peer for the Bloom filter of the keys it stores. The
+// filter is nil if the peer does not store values or predates filters.
+func SendKeyFilter(ctx context.Context, peer *models.Node) (*models.BloomFilter, error) {
+	var reply PingReply
+	if err := rpcGet(ctx, peerAddr(peer), "/ping?filter=true", &reply); err != nil {
+		return nil, err
+	}
+	if reply.NodeID != peer.ID {
+		return nil, fmt.Errorf("ping of %s answered by %s", peer.ID, reply.NodeID)
+	}
+	return reply.Filter, nil
+}
+
+// preferHolders reorders peers, keeping their order otherwise, so those
+// whose key filter rules key out come last. They are still asked, as a
+// filter misses the keys a peer stored after advertising it.
+func preferHolders(peers []*models.Node, key string, filters *models.KeyFilters) []*models.Node {
+	ordered := make([]*models.Node, 0, len(peers))
+	var lacking []*models.Node
+	for _, peer := range peers {
+		if filters.Lacks(peer.ID, key) {
+			lacking = append(lacking, peer)
+		} else {
+			ordered = append(ordered, peer)
+		}
+	}
+	return append(ordered, lacking...)
+}
+
+// KeyFilterRefresher periodically fetches the key filters of the storing
+// contacts in the routing table, for FIND_VALUE to ask the contacts that
+// may hold a key first
+type KeyFilterRefresher struct {
+	routingTable *models.RoutingTable
+	localID      string
+	interval     time.Duration
+}
+
+// NewKeyFilterRefresher creates a refresher for the routing table of
+// localID, trusting the filters it fetches for two intervals
+func NewKeyFilterRefresher(routingTable *models.RoutingTable, localID string, interval time.Duration) *KeyFilterRefresher {
+	if routingTable.Filters != nil {
+		routingTable.Filters.MaxAge = 2 * interval
+	}
+	return &KeyFilterRefresher{routingTable: routingTable, localID: localID, interval: interval}
+}
+
+// Refresh fetches the filter of every contact offering storage and
+// returns the number fetched
+func (r *KeyFilterRefresher) Refresh(ctx context.Context) int {
+	var peers []*models.Node
+	for _, n := range r.routingTable.Contacts() {
+		if n.ID != r.localID && n.Supports(models.FlagStorage) {
+			peers = append(peers, n)
+		}
+	}
+
+	fetched := 0
+	for _, peer := range peers {
+		if ctx.Err() != nil {
+			break
+		}
+		filter, err := SendKeyFilter(ctx, peer)
+		if err != nil || filter == nil {
+			continue
+		}
+		r.routingTable.Filters.Set(peer.ID, filter)
+		fetched++
+	}
+	return fetched
+}
@@ -0,0 +1,121 @@
+package kademlia
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"net/http"
+	"strconv"
+
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// DefaultKeyspaceBins is the number of ranges a keyspace histogram splits
+// the keyspace into unless asked otherwise
+const DefaultKeyspaceBins = 16
+
+// KeyspaceHistogram counts the keys a node stores and the peers it knows
+// in equal ranges of the 160-bit keyspace. With IDs drawn uniformly every
+// bin should hold about the same share; bins far above it point at
+// clustered IDs or a node taking more than its share of the load.
+type KeyspaceHistogram struct {
+	NodeID  string        `json:"node_id"`
+	SelfBin int           `json:"self_bin"` // Bin of the node's own ID
+	Keys    int           `json:"keys"`     // Stored keys counted, leaving out those that are not 160-bit hex
+	Peers   int           `json:"peers"`    // Routing table contacts counted
+	Bins    []KeyspaceBin `json:"bins"`
+}
+
+// KeyspaceBin is one range of a KeyspaceHistogram, from Start up to the
+// next bin's Start
+type KeyspaceBin struct {
+	Start string `json:"start"` // First ID of the range, 40 hex digits
+	Keys  int    `json:"keys"`
+	Peers int    `json:"peers"`
+}
+
+// BuildKeyspaceHistogram counts the keys in storage and the contacts in
+// routingTable in n equal ranges of the keyspace. Namespaced keys are
+// counted by their raw key.
+func BuildKeyspaceHistogram(storage *models.KeyValueStore, routingTable *models.RoutingTable, localID string, n int) KeyspaceHistogram {
+	h := KeyspaceHistogram{NodeID: localID, SelfBin: keyspaceBin(localID, n), Bins: make([]KeyspaceBin, n)}
+
+	// Bin i starts at the first 64-bit prefix p with p*n >= i*2^64
+	low := uint(validators.HexadecimalValidator.Length*4 - 64)
+	for i := range h.Bins {
+		start := new(big.Int).Lsh(big.NewInt(int64(i)), 64)
+		start.Add(start, big.NewInt(int64(n-1))).Div(start, big.NewInt(int64(n)))
+		h.Bins[i].Start = fmt.Sprintf("%0*x", validators.HexadecimalValidator.Length, start.Lsh(start, low))
+	}
+
+	for _, entry := range storage.Entries() {
+		_, raw := models.SplitNamespacedKey(entry.Key)
+		if bin := keyspaceBin(raw, n); bin >= 0 {
+			h.Bins[bin].Keys++
+			h.Keys++
+		}
+	}
+	for _, contact := range routingTable.Contacts() {
+		if bin := keyspaceBin(contact.ID, n); bin >= 0 && contact.ID != localID {
+			h.Bins[bin].Peers++
+			h.Peers++
+		}
+	}
+	return h
+}
+
+// keyspaceBin returns which of n equal ranges of the keyspace id falls
+// in, or -1 if id is not a 160-bit hex ID. The range is decided by the top
+// 64 bits, which is exact for any n up to 2^32.
+func keyspaceBin(id string, n int) int {
+	if validators.ValidateID(id, validators.HexadecimalValidator) != nil {
+		return -1
+	}
+	prefix, err := strconv.ParseUint(id[:16], 16, 64)
+	if err != nil {
+		return -1
+	}
+	bin, _ := bits.Mul64(prefix, uint64(n))
+	return int(bin)
+}
+
+// WriteCSV writes the histogram as CSV, one row per bin under a header row
+func (h KeyspaceHistogram) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"bin", "start", "keys", "peers", "self"})
+	for i, bin := range h.Bins {
+		out.Write([]string{strconv.Itoa(i), bin.Start, strconv.Itoa(bin.Keys), strconv.Itoa(bin.Peers), strconv.FormatBool(i == h.SelfBin)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// AdminKeyspaceHandler handles /admin/keyspace, returning the keyspace
+// histogram of the node as JSON, or as CSV with "format=csv"
+func AdminKeyspaceHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	req := AdminKeyspaceRequest{Bins: DefaultKeyspaceBins, Format: "json"}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if req.Format != "json" && req.Format != "csv" {
+		http.Error(w, "Invalid 'format' parameter, expected json or csv", http.StatusBadRequest)
+		return
+	}
+
+	h := BuildKeyspaceHistogram(storage, routingTable, node.ID, req.Bins)
+	if req.Format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		h.WriteCSV(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,298 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Limits of leases, in seconds
+const (
+	DefaultLeaseTTL = 30
+	MaxLeaseTTL     = 3600
+)
+
+var (
+	// ErrLeaseHeld is returned when acquiring or releasing a lease that
+	// another holder holds
+	ErrLeaseHeld = errors.New("lease is held by another holder")
+	// ErrLeaseLost is returned when renewing a lease that lapsed since it
+	// was acquired, even if it was acquired again since
+	ErrLeaseLost = errors.New("lease lapsed before it was renewed")
+	// ErrNoLeaseQuorum is returned when too few of the replicas of a lease
+	// answered to grant or refuse it
+	ErrNoLeaseQuorum = errors.New("too few replicas granted the lease")
+	// ErrNotLease is returned when the key holds a value that is not a
+	// lease
+	ErrNotLease = errors.New("key holds a value that is not a lease")
+)
+
+// Lease is a lock on a key, stored as the key's value on the nodes closest
+// to it. Token is a fencing token that grows whenever the lease passes to
+// a new holder or lapses, so a resource guarded by the lock can refuse
+// requests carrying a token older than one it has seen.
+type Lease struct {
+	Key       string    `json:"key"`
+	Holder    string    `json:"holder,omitempty"` // Empty once released
+	Token     uint64    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HeldAt reports whether the lease is held at t
+func (l Lease) HeldAt(t time.Time) bool {
+	return l.Holder != "" && t.Before(l.ExpiresAt)
+}
+
+// LeaseRequest is the body of a /lease request, acquiring or renewing the
+// lease on Key for Holder for TTL seconds, or with Release set giving it
+// up. With Replicate set the receiving node does so on the k nodes closest
+// to the key and grants the lease if a majority of them do.
+type LeaseRequest struct {
+	Key       string `json:"key" validate:"required,id"`
+	Holder    string `json:"holder" validate:"required,max=128"`
+	TTL       int    `json:"ttl,omitempty" validate:"min=0,max=3600"` // DefaultLeaseTTL if 0
+	Release   bool   `json:"release,omitempty"`
+	Replicate bool   `json:"replicate,omitempty"`
+
+	// Requester, the node replicating the lease, Namespace and
+	// NamespaceToken are sent as headers, as is the write token nodes
+	// requiring write tokens need
+	Requester      string `json:"-"`
+	Namespace      string `json:"-"`
+	NamespaceToken string `json:"-"`
+}
+
+// LeaseAck answers a /lease request. If Granted, Lease is the lease now
+// held, or released, by the requester; otherwise it is the lease that
+// stood in the way. Replicas lists the answer of each replica asked,
+// Stored meaning it granted the request.
+type LeaseAck struct {
+	Key      string       `json:"key"`
+	Granted  bool         `json:"granted"`
+	Lease    Lease        `json:"lease"`
+	Replicas []ReplicaAck `json:"replicas"`
+}
+
+// LeaseHandler handles /lease requests
+func LeaseHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	var query StoreQuery
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	if query.Hash && req.Key != "" {
+		req.Key = KeyFromString(req.Key)
+	}
+	if !validateBody(w, &req) {
+		return
+	}
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+	req.Namespace = namespace
+	req.NamespaceToken = r.Header.Get(NamespaceTokenHeader)
+
+	// Take the lease on the k closest nodes on behalf of the client
+	if req.Replicate {
+		ack := IterativeLease(r.Context(), routingTable, node, storage, req)
+		status := http.StatusOK
+		if len(ack.Replicas) == 0 {
+			status = http.StatusBadGateway
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ack)
+		return
+	}
+
+	if !node.Supports(models.FlagStorage) {
+		http.Error(w, "This node does not accept STOREs", http.StatusForbidden)
+		return
+	}
+	if !allowedWriter(routingTable, r) {
+		writeStoreConflict(w, ErrNotAllowlisted)
+		return
+	}
+	if !checkWriteToken(r, node, storage, r.Header.Get(WriteTokenHeader)) {
+		writeStoreConflict(w, ErrInvalidWriteToken)
+		return
+	}
+	lease, err := leaseLocal(storage, req)
+	if err != nil && err != ErrLeaseHeld {
+		writeStoreConflict(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LeaseAck{
+		Key:      req.Key,
+		Granted:  err == nil,
+		Lease:    lease,
+		Replicas: []ReplicaAck{newReplicaAck(node, err)},
+	})
+}
+
+// IterativeLease asks the k nodes closest to req.Key, this node included
+// when it is among them, to grant req, and grants it if a majority do. An
+// acquisition a majority refused is released again on the replicas that
+// granted it. The granted lease carries the highest token and earliest
+// expiry among the replicas that granted it.
+func IterativeLease(ctx context.Context, routingTable *models.RoutingTable, self *models.Node, storage *models.KeyValueStore, req LeaseRequest) LeaseAck {
+	req.Replicate = false
+	req.Requester = self.ID
+	closest := IterativeFindNodeWithOptions(ctx, routingTable, self.ID, req.Key, LookupOptions{Require: models.FlagStorage})
+
+	ack := LeaseAck{Key: req.Key}
+	var leases []Lease
+	ack.Replicas, leases = askLease(ctx, self, storage, closest, req)
+
+	var granted []*models.Node
+	for i, replica := range ack.Replicas {
+		if !replica.Stored {
+			if leases[i].Holder != "" && leases[i].Token >= ack.Lease.Token {
+				ack.Lease = leases[i]
+			}
+			continue
+		}
+		granted = append(granted, closest[i])
+	}
+	if len(closest) == 0 || len(granted) <= len(closest)/2 {
+		if !req.Release && len(granted) > 0 {
+			release := req
+			release.Release = true
+			askLease(ctx, self, storage, granted, release)
+		}
+		return ack
+	}
+
+	ack.Granted = true
+	ack.Lease = Lease{}
+	for i, replica := range ack.Replicas {
+		if !replica.Stored {
+			continue
+		}
+		l := leases[i]
+		if ack.Lease.ExpiresAt.IsZero() || l.ExpiresAt.Before(ack.Lease.ExpiresAt) {
+			ack.Lease.ExpiresAt = l.ExpiresAt
+		}
+		ack.Lease.Token = max(ack.Lease.Token, l.Token)
+	}
+	ack.Lease.Key = req.Key
+	if !req.Release {
+		ack.Lease.Holder = req.Holder
+	}
+	return ack
+}
+
+// askLease asks each of peers to grant req in parallel, returning their
+// answers and the leases they hold for the key in the order of peers
+func askLease(ctx context.Context, self *models.Node, storage *models.KeyValueStore, peers []*models.Node, req LeaseRequest) ([]ReplicaAck, []Lease) {
+	acks := make([]ReplicaAck, len(peers))
+	leases := make([]Lease, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer *models.Node) {
+			defer wg.Done()
+
+			var err error
+			if peer.ID == self.ID {
+				leases[i], err = leaseLocal(storage, req)
+			} else {
+				// A holder acquiring or releasing its own lease again
+				// changes nothing, so every request may be retried
+				err = retry.Do(ctx, retry.For(ctx), func(ctx context.Context) (err error) {
+					leases[i], err = SendLease(ctx, peer, req)
+					return err
+				})
+			}
+			acks[i] = newReplicaAck(peer, err)
+		}(i, peer)
+	}
+	wg.Wait()
+	return acks, leases
+}
+
+// SendLease asks peer to grant req, returning the lease it holds for the
+// key: the requester's if granted, otherwise the one in the way with
+// ErrLeaseHeld. The write token peer last issued is presented as
+// SendStoreRequest does.
+func SendLease(ctx context.Context, peer *models.Node, req LeaseRequest) (Lease, error) {
+	var ack LeaseAck
+	err := withWriteToken(ctx, peer, req.Key, "", func(token string) error {
+		header := requesterHeader(req.Requester, req.Namespace, req.NamespaceToken)
+		if token != "" {
+			header.Set(WriteTokenHeader, token)
+		}
+		return rpcPostWithHeader(ctx, peerAddr(peer), "/lease", header, req, &ack)
+	})
+	if err != nil {
+		return Lease{}, err
+	}
+	if !ack.Granted {
+		return ack.Lease, ErrLeaseHeld
+	}
+	return ack.Lease, nil
+}
+
+// leaseLocal grants req on the lease record stored under req.Key in its
+// namespace. A lease held by another holder is returned with ErrLeaseHeld.
+// Releasing a lease nobody holds succeeds without writing.
+func leaseLocal(storage *models.KeyValueStore, req LeaseRequest) (Lease, error) {
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = DefaultLeaseTTL
+	}
+	now := clock.Now()
+
+	var lease Lease
+	errUnchanged := errors.New("unchanged")
+	err := storage.Update(models.NamespacedKey(req.Namespace, req.Key), req.Holder, func(current string, exists bool) (string, error) {
+		var cur Lease
+		if exists {
+			if err := json.Unmarshal([]byte(current), &cur); err != nil || cur.Key != req.Key {
+				return "", ErrNotLease
+			}
+		}
+		held := cur.HeldAt(now)
+		if held && cur.Holder != req.Holder {
+			lease = cur
+			return "", ErrLeaseHeld
+		}
+
+		switch {
+		case req.Release && !held:
+			lease = cur
+			return "", errUnchanged
+		case req.Release:
+			lease = Lease{Key: req.Key, Token: cur.Token, ExpiresAt: now}
+		case held:
+			lease = Lease{Key: req.Key, Holder: req.Holder, Token: cur.Token, ExpiresAt: now.Add(time.Duration(ttl) * time.Second)}
+		default:
+			lease = Lease{Key: req.Key, Holder: req.Holder, Token: cur.Token + 1, ExpiresAt: now.Add(time.Duration(ttl) * time.Second)}
+		}
+		data, err := json.Marshal(lease)
+		return string(data), err
+	})
+	if err == errUnchanged {
+		err = nil
+	}
+	return lease, err
+}
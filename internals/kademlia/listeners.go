@@ -0,0 +1,61 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// HandlerSets registers the handlers of each set, keyed by the
+// config.Handlers* names, on a mux. Sets the node does not serve, such as
+// the gateway when it is disabled, are left out.
+type HandlerSets map[string]func(mux *http.ServeMux)
+
+// Mux returns a mux serving sets, or every set when sets is empty
+func (h HandlerSets) Mux(sets []string) *http.ServeMux {
+	if len(sets) == 0 {
+		sets = config.HandlerSets
+	}
+	mux := http.NewServeMux()
+	for _, set := range sets {
+		if register := h[set]; register != nil {
+			register(mux)
+		}
+	}
+	return mux
+}
+
+// ServeListeners binds every listener and serves its handler sets, wrapped
+// by wrap, on a server of its own. It returns once every address is bound;
+// the caller stops the servers with Shutdown. On error the servers already
+// started are closed.
+func ServeListeners(listeners []config.ListenerConfig, sets HandlerSets, wrap func(http.Handler) http.Handler) ([]*http.Server, error) {
+	var servers []*http.Server
+	for _, l := range listeners {
+		listener, err := net.Listen("tcp", l.Addr)
+		if err != nil {
+			for _, server := range servers {
+				server.Close()
+			}
+			if errors.Is(err, syscall.EADDRINUSE) {
+				return nil, fmt.Errorf("address %s is already in use", l.Addr)
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %v", l.Addr, err)
+		}
+
+		server := &http.Server{Handler: wrap(sets.Mux(l.Handlers))}
+		go func(addr string) {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Listener %s stopped: %v", addr, err)
+			}
+		}(l.Addr)
+		log.Printf("Serving %v on %s\n", l.Handlers, listener.Addr())
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
@@ -0,0 +1,195 @@
+package kademlia
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// LookupOptions tunes an iterative lookup. Zero fields fall back to the
+// routing table's k and constants.GetAlpha().
+type LookupOptions struct {
+	K     int // Number of closest contacts to find
+	Alpha int // Number of contacts queried in parallel per round
+
+	// Require restricts the lookup to contacts offering these services;
+	// others are neither queried nor returned
+	Require models.CapabilityFlags
+
+	// Strategy orders the contacts queried each round, ByReputation with
+	// DefaultReputationPenalty if nil
+	Strategy LookupStrategy
+}
+
+// LookupStrategy decides the order in which a lookup queries its
+// candidates. Weight is given a candidate's rank by XOR distance among the
+// contacts not yet queried, 0 for the closest, and its success rate from
+// the routing table's reputation; the lowest weights are queried first.
+// The contacts a lookup returns are always the closest, whatever the order.
+type LookupStrategy interface {
+	Weight(rank int, successRate float64) float64
+}
+
+// ByDistance queries candidates purely by XOR distance
+type ByDistance struct{}
+
+func (ByDistance) Weight(rank int, _ float64) float64 { return float64(rank) }
+
+// ByReputation queries candidates by XOR distance, moving those likely to
+// fail back: a contact that never answers is tried as if it were Penalty
+// places further away, one without history Penalty/2 places.
+type ByReputation struct {
+	Penalty float64
+}
+
+func (s ByReputation) Weight(rank int, successRate float64) float64 {
+	return float64(rank) + s.Penalty*(1-successRate)
+}
+
+// DefaultReputationPenalty is the ByReputation penalty lookups use unless
+// given a Strategy
+const DefaultReputationPenalty = 4
+
+// IterativeFindNode runs a Kademlia node lookup for target starting from
+// the local routing table. Each round queries up to Alpha of the closest
+// contacts not yet asked, and the lookup ends once a round yields nothing
+// closer than the current k closest. The local node is never queried.
+func IterativeFindNode(ctx context.Context, routingTable *models.RoutingTable, localID, target string) []*models.Node {
+	return IterativeFindNodeWithOptions(ctx, routingTable, localID, target, LookupOptions{})
+}
+
+// IterativeFindNodeWithOptions is IterativeFindNode with an explicit k,
+// alpha and required capabilities. Results are taken from, and kept in,
+// the routing table's lookup cache if it has one.
+func IterativeFindNodeWithOptions(ctx context.Context, routingTable *models.RoutingTable, localID, target string, opts LookupOptions) []*models.Node {
+	k := opts.K
+	if k <= 0 {
+		k = routingTable.BucketSize()
+	}
+	if cached, ok := routingTable.Lookups.Get(target, k, opts.Require); ok {
+		return cached
+	}
+	alpha := opts.Alpha
+	if alpha <= 0 {
+		alpha = constants.GetAlpha()
+	}
+	strategy := opts.Strategy
+	if strategy == nil {
+		strategy = ByReputation{Penalty: DefaultReputationPenalty}
+	}
+	queried := map[string]bool{localID: true}
+	known := make(map[string]bool)
+
+	var shortlist []*models.Node
+	merge := func(nodes []*models.Node) {
+		for _, n := range nodes {
+			if n == nil || known[n.ID] || !n.Supports(opts.Require) {
+				continue
+			}
+			known[n.ID] = true
+			shortlist = append(shortlist, n)
+		}
+		sortByDistance(shortlist, target)
+		if len(shortlist) > k {
+			shortlist = shortlist[:k]
+		}
+	}
+	touchBucket(routingTable, localID, target)
+	merge(FindClosestNodes(routingTable, target, localID, 0))
+
+	for ctx.Err() == nil {
+		var candidates []*models.Node
+		for _, n := range shortlist {
+			if !queried[n.ID] {
+				candidates = append(candidates, n)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		batch := orderCandidates(candidates, strategy, routingTable.Reputation)[:min(alpha, len(candidates))]
+		for _, n := range batch {
+			queried[n.ID] = true
+		}
+		merge(fanOutFindNode(ctx, batch, target, localID, k, routingTable.Reputation, routingTable.Peers, !routingTable.FixedTimeouts))
+	}
+
+	// A lookup cut short may have missed closer contacts
+	if ctx.Err() == nil {
+		routingTable.Lookups.Put(target, k, opts.Require, shortlist)
+	}
+	return shortlist
+}
+
+// IterativeFindValue returns the value stored under key, checking storage
+// before asking the contacts closest to key found by a node lookup, in
+// order of distance, except that contacts whose key filter rules key out
+// are asked last. Queries that fail transiently are retried under the
+// context's retry policy.
+func IterativeFindValue(ctx context.Context, routingTable *models.RoutingTable, localID string, storage *models.KeyValueStore, key string, opts LookupOptions) (string, bool) {
+	if value, ok := storage.Get(key); ok {
+		return value, true
+	}
+
+	peers := IterativeFindNodeWithOptions(ctx, routingTable, localID, key, opts)
+	for _, peer := range preferHolders(peers, key, routingTable.Filters) {
+		if peer.ID == localID {
+			continue
+		}
+		var value string
+		var found bool
+		err := retry.Do(ctx, retry.For(ctx), func(ctx context.Context) (err error) {
+			value, _, found, err = SendFindValue(ctx, peer, key)
+			return err
+		})
+		if err == nil && found {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// orderCandidates sorts candidates, given closest first, into the order
+// strategy queries them in
+func orderCandidates(candidates []*models.Node, strategy LookupStrategy, reputation *models.PeerReputation) []*models.Node {
+	weights := make(map[string]float64, len(candidates))
+	for rank, n := range candidates {
+		weights[n.ID] = strategy.Weight(rank, reputation.SuccessRate(n.ID))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return weights[candidates[i].ID] < weights[candidates[j].ID]
+	})
+	return candidates
+}
+
+// sortByDistance orders nodes by XOR distance to target, closest first
+func sortByDistance(nodes []*models.Node, target string) {
+	distances := make([]Distance, len(nodes))
+	for i, n := range nodes {
+		d, ok := XORDistance(target, n.ID)
+		if !ok {
+			sort.SliceStable(nodes, func(i, j int) bool {
+				return calculateXORDistance(target, nodes[i].ID).Cmp(calculateXORDistance(target, nodes[j].ID)) < 0
+			})
+			return
+		}
+		distances[i] = d
+	}
+	sort.Stable(byDistance{nodes, distances})
+}
+
+// byDistance sorts nodes by their precomputed distances
+type byDistance struct {
+	nodes     []*models.Node
+	distances []Distance
+}
+
+func (s byDistance) Len() int           { return len(s.nodes) }
+func (s byDistance) Less(i, j int) bool { return s.distances[i].Cmp(s.distances[j]) < 0 }
+func (s byDistance) Swap(i, j int) {
+	s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i]
+	s.distances[i], s.distances[j] = s.distances[j], s.distances[i]
+}
@@ -0,0 +1,284 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// MaxMessageSize bounds the body of a /rpc request
+const MaxMessageSize = 1 << 20
+
+// NewMessage returns an envelope of type typ from sender with a fresh
+// nonce, drawn from the seeded sequence in deterministic mode
+func NewMessage(typ models.MessageType, sender *models.Node) models.Message {
+	nonce := make([]byte, 16)
+	randReader().Read(nonce)
+	return models.Message{
+		Type:    typ,
+		Sender:  *sender,
+		Version: models.MessageVersion,
+		Nonce:   hex.EncodeToString(nonce),
+	}
+}
+
+// SendMessage sends msg to peer's /rpc endpoint and returns the reply. A
+// reply that does not echo msg's nonce, comes from a node other than peer
+// or carries a bad signature is rejected, and an ERROR reply or one from
+// another network is returned as an error. Otherwise peer's LastSeen, and
+// its record if the reply carries a newer one, are updated.
+func SendMessage(ctx context.Context, peer *models.Node, msg models.Message) (models.Message, error) {
+	addr := peerAddr(peer)
+	header := http.Header{"Accept": {acceptHeader(ctx)}}
+
+	msg.EncodeValue()
+	var reply models.Message
+	if err := rpcPostWithHeader(ctx, addr, "/rpc", header, msg, &reply); err != nil {
+		return reply, err
+	}
+	if reply.Nonce != msg.Nonce {
+		return reply, fmt.Errorf("invalid %s reply from %s: nonce mismatch", msg.Type, addr)
+	}
+	if err := checkMessageSender(&reply, false); err != nil {
+		return reply, fmt.Errorf("invalid %s reply from %s: %v", msg.Type, addr, err)
+	}
+	if peer.ID != "" && reply.Sender.ID != peer.ID {
+		return reply, fmt.Errorf("invalid %s reply from %s: expected a reply from %s but %s answered", msg.Type, addr, peer.ID, reply.Sender.ID)
+	}
+	if err := checkNetwork(&msg.Sender, &reply.Sender); err != nil {
+		return reply, fmt.Errorf("%s refused by %s: %v", msg.Type, addr, err)
+	}
+	if reply.Type == models.Error {
+		return reply, fmt.Errorf("%s refused by %s: %s", msg.Type, addr, reply.Error)
+	}
+	if err := reply.DecodeValue(); err != nil {
+		return reply, fmt.Errorf("invalid %s reply from %s: %v", msg.Type, addr, err)
+	}
+
+	peer.MarkSeen(clock.Now())
+	if reply.Sender.Flags != 0 {
+		peer.Flags, peer.Protocol = reply.Sender.Flags, reply.Sender.Protocol
+	}
+	if reply.Sender.Record != nil && reply.Sender.Record.Supersedes(peer.Record) {
+		peer.Record = reply.Sender.Record
+	}
+	dropInvalidRecords(reply.Nodes)
+	return reply, nil
+}
+
+// checkMessageSender verifies the sender's record and, if the message is
+// signed, its signature. Anyone can copy a node's record into a message,
+// so a request carrying one must also be signed with its key; a reply is
+// tied to the peer asked by its nonce instead.
+func checkMessageSender(msg *models.Message, request bool) error {
+	if msg.Version <= 0 || msg.Version > models.MessageVersion {
+		return fmt.Errorf("unsupported message version %d", msg.Version)
+	}
+	if msg.Sender.Record != nil {
+		if err := verifyRecordFor(msg.Sender.Record, msg.Sender.ID); err != nil {
+			return err
+		}
+		if request && msg.Signature == "" {
+			return fmt.Errorf("%w: carries a record but no signature", models.ErrInvalidMessage)
+		}
+	}
+	if msg.Signature != "" {
+		return msg.Verify()
+	}
+	return nil
+}
+
+// MessageHandler handles /rpc requests, which carry any RPC in a
+// models.Message envelope and are answered with one. The sender is added
+// to the routing table, or refused if it belongs to another network, as
+// it would be by a PING.
+func MessageHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, providers *models.ProviderStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg models.Message
+	if err := json.NewDecoder(io.LimitReader(r.Body, MaxMessageSize)).Decode(&msg); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+
+	reply := models.Message{Type: models.Error, Sender: *node, Version: models.MessageVersion, Nonce: msg.Nonce}
+	refuse := func(status int, err error) {
+		reply.Type = models.Error
+		reply.Error = err.Error()
+		writeEncodedStatus(w, r, status, reply)
+	}
+
+	// Verify the signature before validateSender normalizes the sender
+	if err := checkMessageSender(&msg, true); err != nil {
+		refuse(http.StatusBadRequest, err)
+		return
+	}
+	if err := validateSender(&msg.Sender, r); err != nil {
+		refuse(http.StatusBadRequest, err)
+		return
+	}
+	if err := checkNetwork(node, &msg.Sender); err != nil {
+		refuse(http.StatusForbidden, err)
+		return
+	}
+	if err := msg.DecodeValue(); err != nil {
+		refuse(http.StatusBadRequest, err)
+		return
+	}
+	// Judge a writer before admitting it, so an unknown sender cannot
+	// prove its address by the contact it just added
+	var signed *models.NodeRecord
+	if msg.Signature != "" {
+		signed = msg.Sender.Record
+	}
+	allowedWriter := msg.Type != models.Store || provenWriter(routingTable, msg.Sender.ID, signed, remoteIP(r))
+	if admitPinger(r.Context(), routingTable, &msg.Sender, node.ID) && msg.Sender.Record != nil {
+		updateRecord(routingTable, msg.Sender.Record)
+	}
+
+	switch msg.Type {
+	case models.Ping:
+		reply.Type = models.Pong
+
+	case models.FindNode:
+		req := FindNodeRequest{ID: msg.Target, Requester: msg.Sender.ID, Count: messageCount(routingTable, msg.Count)}
+		if err := validators.Struct(req); err != nil {
+			refuse(http.StatusBadRequest, err)
+			return
+		}
+		reply.Type = models.FoundNodes
+		reply.Nodes = findClosestNodesExcept(routingTable, req.ID, req.Count, map[string]bool{req.Requester: true, node.ID: true})
+		reply.Token = IssueWriteToken(node.ID, remoteIP(r))
+
+	case models.FindValue:
+		req := FindValueRequest{Key: msg.Key, Count: messageCount(routingTable, msg.Count)}
+		if err := validators.Struct(req); err != nil {
+			refuse(http.StatusBadRequest, err)
+			return
+		}
+		if value, found := storage.Get(msg.Key); found {
+			reply.Type = models.FoundValue
+			reply.Key, reply.Value = msg.Key, value
+		} else {
+			reply.Type = models.FoundNodes
+			reply.Nodes = FindClosestNodes(routingTable, msg.Key, node.ID, req.Count)
+		}
+		reply.Token = IssueWriteToken(node.ID, remoteIP(r))
+
+	case models.Store:
+		store := StoreRequest{Key: msg.Key, Value: msg.Value, Type: msg.RecordType, Hops: 1}
+		if err := validators.Struct(store); err != nil {
+			refuse(http.StatusBadRequest, err)
+			return
+		}
+		if store.Type != models.RecordValue {
+			if _, err := models.ParseCRDTRecord(store.Value, store.Type); err != nil {
+				refuse(http.StatusBadRequest, err)
+				return
+			}
+		}
+		if !node.Supports(models.FlagStorage) {
+			refuse(http.StatusForbidden, errors.New("This node does not accept STOREs"))
+			return
+		}
+		if !allowedWriter {
+			refuse(http.StatusForbidden, ErrNotAllowlisted)
+			return
+		}
+		if !checkWriteToken(r, node, storage, msg.Token) {
+			refuse(http.StatusForbidden, ErrInvalidWriteToken)
+			return
+		}
+		if storage.RejectDistant && !InResponsibility(routingTable, node.ID, msg.Key) {
+			notePlacementOutside(storage, node.ID, msg.Key, msg.Sender.ID)
+			refuse(http.StatusForbidden, ErrOutsideResponsibility)
+			return
+		}
+		if closest := FindClosestNodes(routingTable, msg.Key, node.ID, 0); !containsID(closest, node.ID) {
+			notePlacementMiss(storage, node, msg.Key, msg.Sender.ID, closest)
+			reply.Type = models.FoundNodes
+			reply.Nodes = closest
+			break
+		}
+		if err := storeLocal(storage, store); err != nil {
+			status := http.StatusConflict
+			if err != models.ErrQuotaExceeded && err != models.ErrRecordType {
+				// The storage backend failed
+				status = http.StatusInternalServerError
+			}
+			refuse(status, err)
+			return
+		}
+		storage.Placement.Accept()
+		reply.Type = models.Stored
+		reply.Key = msg.Key
+
+	case models.AddProvider:
+		if err := validators.Struct(AddProviderRequest{Key: msg.Key, ID: msg.Sender.ID, Port: msg.Sender.Port}); err != nil {
+			refuse(http.StatusBadRequest, err)
+			return
+		}
+		provider := msg.Sender
+		provider.Record = nil
+		provider.IP = remoteIP(r)
+		if err := providers.Add(msg.Key, provider); err != nil {
+			refuse(http.StatusInsufficientStorage, err)
+			return
+		}
+		reply.Type = models.Stored
+		reply.Key = msg.Key
+
+	case models.GetProviders:
+		if err := validators.Struct(GetProvidersRequest{Key: msg.Key}); err != nil {
+			refuse(http.StatusBadRequest, err)
+			return
+		}
+		if found := providers.Get(msg.Key); len(found) > 0 {
+			reply.Type = models.FoundProviders
+			for i := range found {
+				reply.Nodes = append(reply.Nodes, &found[i])
+			}
+		} else {
+			reply.Type = models.FoundNodes
+			reply.Nodes = FindClosestNodes(routingTable, msg.Key, node.ID, 0)
+		}
+
+	default:
+		refuse(http.StatusBadRequest, fmt.Errorf("Unknown message type %q", msg.Type))
+		return
+	}
+
+	reply.EncodeValue()
+	writeEncoded(w, r, reply)
+}
+
+func containsID(nodes []*models.Node, id string) bool {
+	for _, n := range nodes {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// messageCount is the number of contacts to return for a message's Count:
+// the table's k if Count is unset, at most k otherwise. A negative Count is
+// passed through for validation to refuse.
+func messageCount(routingTable *models.RoutingTable, count int) int {
+	k := routingTable.BucketSize()
+	if count == 0 {
+		return k
+	}
+	return min(count, k)
+}
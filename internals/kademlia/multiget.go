@@ -0,0 +1,295 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// MaxFindValuesKeys bounds the keys of one FIND_VALUES request. Longer
+// key lists are sent in several.
+const MaxFindValuesKeys = 64
+
+// FoundValue is a value held under Key
+type FoundValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // models.ValueEncodingBase64 if Value is the base64 of a binary value
+}
+
+// FindValuesReply answers a FIND_VALUES with the values the responder
+// holds and, for each key it does not, the contacts closest to it. Keys
+// close together usually share their closest contacts, so each contact is
+// listed once in Nodes and Closest gives the indices of a key's contacts,
+// closest first.
+type FindValuesReply struct {
+	Values  []FoundValue     `json:"values"`
+	Nodes   []*models.Node   `json:"nodes,omitempty"`
+	Closest map[string][]int `json:"closest,omitempty"`
+}
+
+// ClosestTo returns the contacts the reply gives as closest to key,
+// skipping indices out of range
+func (r FindValuesReply) ClosestTo(key string) []*models.Node {
+	var nodes []*models.Node
+	for _, i := range r.Closest[key] {
+		if i >= 0 && i < len(r.Nodes) {
+			nodes = append(nodes, r.Nodes[i])
+		}
+	}
+	return nodes
+}
+
+// FindValuesHandler handles /find_values requests, answering a
+// FIND_VALUE for each of up to MaxFindValuesKeys keys in one reply
+func FindValuesHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req FindValuesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	var query StoreQuery
+	if !decodeQuery(w, r, &query) || !validateBody(w, &req) {
+		return
+	}
+	for i, key := range req.Keys {
+		if query.Hash {
+			req.Keys[i] = KeyFromString(key)
+		} else if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+			http.Error(w, (&validators.FieldError{Field: "keys", Reason: err.Error()}).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+
+	k := routingTable.BucketSize()
+	count := req.Count
+	if count == 0 || count > k {
+		count = k
+	}
+	reply := FindValuesReply{Values: []FoundValue{}, Closest: make(map[string][]int)}
+	index := make(map[string]int)
+	done := make(map[string]bool)
+	for _, key := range req.Keys {
+		if done[key] {
+			continue
+		}
+		done[key] = true
+		if value, exists := storage.Get(models.NamespacedKey(namespace, key)); exists {
+			encoded, encoding := models.EncodeValue(value)
+			reply.Values = append(reply.Values, FoundValue{Key: key, Value: encoded, Encoding: encoding})
+			continue
+		}
+		indices := []int{}
+		for _, n := range FindClosestNodes(routingTable, key, node.ID, count) {
+			i, seen := index[n.ID]
+			if !seen {
+				i = len(reply.Nodes)
+				index[n.ID] = i
+				reply.Nodes = append(reply.Nodes, n)
+			}
+			indices = append(indices, i)
+		}
+		reply.Closest[key] = indices
+	}
+
+	writeWriteToken(w, r, node)
+	writeEncoded(w, r, reply)
+}
+
+// SendFindValues sends a FIND_VALUES RPC for keys to peer, in requests of
+// up to MaxFindValuesKeys keys, and returns the values it holds by key and
+// its closest contacts to each key it does not. A peer that predates
+// FIND_VALUES is sent one FIND_VALUE per key instead.
+func SendFindValues(ctx context.Context, peer *models.Node, keys []string) (map[string]string, map[string][]*models.Node, error) {
+	values := make(map[string]string)
+	closest := make(map[string][]*models.Node)
+	addr := peerAddr(peer)
+	for start := 0; start < len(keys); start += MaxFindValuesKeys {
+		chunk := keys[start:min(start+MaxFindValuesKeys, len(keys))]
+		var reply FindValuesReply
+		err := rpcPostWithHeader(ctx, addr, "/find_values", nil, FindValuesRequest{Keys: chunk}, &reply)
+		var status *StatusError
+		if errors.As(err, &status) && status.Code == http.StatusNotFound {
+			return sendFindValueEach(ctx, peer, keys)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, found := range reply.Values {
+			value, err := models.DecodeValue(found.Value, found.Encoding)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid FIND_VALUES response from %s: %v", peer.IP, err)
+			}
+			values[found.Key] = value
+		}
+		for key := range reply.Closest {
+			nodes := reply.ClosestTo(key)
+			dropInvalidRecords(nodes)
+			closest[key] = nodes
+		}
+	}
+	return values, closest, nil
+}
+
+// sendFindValueEach is SendFindValues for a peer without FIND_VALUES
+func sendFindValueEach(ctx context.Context, peer *models.Node, keys []string) (map[string]string, map[string][]*models.Node, error) {
+	values := make(map[string]string)
+	closest := make(map[string][]*models.Node)
+	for _, key := range keys {
+		value, nodes, found, err := SendFindValue(ctx, peer, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			values[key] = value
+		} else {
+			closest[key] = nodes
+		}
+	}
+	return values, closest, nil
+}
+
+// valueLookup is the state of one key's lookup within IterativeFindValues
+type valueLookup struct {
+	key       string
+	shortlist []*models.Node
+	known     map[string]bool
+	queried   map[string]bool
+}
+
+// merge adds the contacts in nodes offering require to the shortlist,
+// keeping the k closest to the key
+func (l *valueLookup) merge(nodes []*models.Node, k int, require models.CapabilityFlags) {
+	for _, n := range nodes {
+		if n == nil || l.known[n.ID] || !n.Supports(require) {
+			continue
+		}
+		l.known[n.ID] = true
+		l.shortlist = append(l.shortlist, n)
+	}
+	sortByDistance(l.shortlist, l.key)
+	if len(l.shortlist) > k {
+		l.shortlist = l.shortlist[:k]
+	}
+}
+
+// IterativeFindValues returns, by key, the values stored under those of
+// keys that storage or some node holds. It runs a value lookup per key
+// missing from storage in lockstep: each round picks up to Alpha of the
+// closest contacts not yet asked for each key, and a contact picked for
+// several keys is sent one FIND_VALUES for all of them, so keys whose
+// closest contacts overlap share their RPCs. A key's lookup ends when a
+// contact returns its value or no closer contact is left to ask. Queries
+// that fail transiently are retried under the context's retry policy.
+func IterativeFindValues(ctx context.Context, routingTable *models.RoutingTable, localID string, storage *models.KeyValueStore, keys []string, opts LookupOptions) map[string]string {
+	k := opts.K
+	if k <= 0 {
+		k = routingTable.BucketSize()
+	}
+	alpha := opts.Alpha
+	if alpha <= 0 {
+		alpha = constants.GetAlpha()
+	}
+	strategy := opts.Strategy
+	if strategy == nil {
+		strategy = ByReputation{Penalty: DefaultReputationPenalty}
+	}
+
+	found := make(map[string]string)
+	pending := make(map[string]*valueLookup)
+	for _, key := range keys {
+		if _, seen := found[key]; seen || pending[key] != nil {
+			continue
+		}
+		if value, ok := storage.Get(key); ok {
+			found[key] = value
+			continue
+		}
+		l := &valueLookup{key: key, known: make(map[string]bool), queried: map[string]bool{localID: true}}
+		touchBucket(routingTable, localID, key)
+		l.merge(FindClosestNodes(routingTable, key, localID, 0), k, opts.Require)
+		pending[key] = l
+	}
+
+	for ctx.Err() == nil && len(pending) > 0 {
+		// Group the keys of this round by the contact to ask
+		batches := make(map[string][]string)
+		peers := make(map[string]*models.Node)
+		for key, l := range pending {
+			var candidates []*models.Node
+			for _, n := range l.shortlist {
+				if !l.queried[n.ID] {
+					candidates = append(candidates, n)
+				}
+			}
+			if len(candidates) == 0 {
+				delete(pending, key)
+				continue
+			}
+			for _, n := range orderCandidates(candidates, strategy, routingTable.Reputation)[:min(alpha, len(candidates))] {
+				l.queried[n.ID] = true
+				batches[n.ID] = append(batches[n.ID], key)
+				peers[n.ID] = n
+			}
+		}
+
+		type result struct {
+			values  map[string]string
+			closest map[string][]*models.Node
+		}
+		results := make(chan result, len(batches))
+		var wg sync.WaitGroup
+		for id, batch := range batches {
+			wg.Add(1)
+			go func(peer *models.Node, batch []string) {
+				defer wg.Done()
+				sort.Strings(batch)
+				var res result
+				err := retry.Do(ctx, retry.For(ctx), func(ctx context.Context) (err error) {
+					res.values, res.closest, err = SendFindValues(ctx, peer, batch)
+					return err
+				})
+				routingTable.Peers.RecordRPC(peer, err)
+				if err == nil {
+					results <- res
+				}
+			}(peers[id], batch)
+		}
+		wg.Wait()
+		close(results)
+
+		for res := range results {
+			for key, value := range res.values {
+				if pending[key] != nil {
+					found[key] = value
+					delete(pending, key)
+				}
+			}
+			for key, nodes := range res.closest {
+				if l := pending[key]; l != nil {
+					l.merge(nodes, k, opts.Require)
+				}
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,31 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// ErrNetworkMismatch is returned when a peer belongs to another network
+// than this node, e.g. a testnet node reached through a mainnet bootstrap
+// address
+var ErrNetworkMismatch = errors.New("peer belongs to another network")
+
+// checkNetwork fails with ErrNetworkMismatch if peer announced another
+// network than node's. Nodes predating network IDs announce none and
+// belong to the default network.
+func checkNetwork(node, peer *models.Node) error {
+	if peer.Network != node.Network {
+		return fmt.Errorf("%w: %s is on %s, this node on %s", ErrNetworkMismatch, peer.ID, networkName(peer.Network), networkName(node.Network))
+	}
+	return nil
+}
+
+// networkName returns the network ID for messages, naming the empty one
+func networkName(id string) string {
+	if id == "" {
+		return "the default network"
+	}
+	return fmt.Sprintf("network %q", id)
+}
@@ -0,0 +1,662 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/chaos"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// shutdownTimeout bounds how long Stop waits for in-flight RPCs, such as
+// long polls, before closing their connections
+const shutdownTimeout = 5 * time.Second
+
+// Node is a Kademlia node that can be embedded in another program. It owns
+// its routing table, storage and RPC server, so several nodes can run in
+// one process.
+type Node struct {
+	Self         *models.Node
+	RoutingTable *models.RoutingTable
+	Storage      *models.KeyValueStore
+	Providers    *models.ProviderStore
+	PubSub       *PubSub
+	Watches      *Watches
+	Forward      *ForwardQueue // Replica STOREs waiting for unreachable peers, nil if disabled
+	WriteTokens  *WriteTokens  // Write tokens peers issued to the node, presented on writes to them
+	Events       *models.EventBus
+	Metrics      *middleware.Metrics // Per-RPC request, error and latency counts, served at /rpc_stats
+
+	cfg             *config.Config
+	extraMiddleware []middleware.Middleware // Added by Use, run after the configured chain
+	relay           *Relay
+	puncher         *HolePuncher         // Set while running with hole punching enabled
+	audit           *middleware.AuditLog // Set while running with an audit log configured
+	scheduler       *Scheduler           // Set while running, runs the periodic jobs
+	key             ed25519.PrivateKey   // Signs Self.Record
+	storageErr      error                // Failure to open the configured storage, returned by Start
+
+	mu             sync.Mutex
+	server         *http.Server
+	listeners      []*http.Server // Serving cfg.Server.AllListeners()
+	stopBackground context.CancelFunc
+	stopped        chan struct{}
+}
+
+// NewNode creates a node from cfg; a nil cfg uses config.Default(). The
+// node does not listen or join the network until Start is called, which
+// also reports a failure to open the configured storage backend.
+func NewNode(cfg *config.Config) *Node {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
+	id := cfg.NodeID
+	if id == "" {
+		id = GenerateNodeID()
+	}
+	ip := cfg.Host
+	if cfg.Advertise != "" {
+		ip = cfg.Advertise
+	}
+	self := &models.Node{ID: id, IP: ip, Port: cfg.Port, Flags: cfg.Flags(), Protocol: models.ProtocolVersion, Relay: cfg.Relay.Via, Network: cfg.NetworkID}
+
+	events := models.NewEventBus()
+	routingTable := NewRoutingTableWithK(id, cfg.K)
+	routingTable.Events = events
+	if len(cfg.Allowlist) > 0 {
+		// Validate refuses a malformed allowlist; one that slips through
+		// admits nobody rather than everybody
+		allowlist, err := models.NewAllowlist(cfg.Allowlist)
+		if err != nil {
+			allowlist = &models.Allowlist{}
+		}
+		routingTable.Allowlist = allowlist
+	}
+	routingTable.Peers.SetInsertLimit(cfg.MaxIDsPerIP, cfg.IDsPerIPWindow)
+	routingTable.SubnetLimit = models.SubnetLimit{PerBucket: cfg.MaxPerSubnetBucket, PerTable: cfg.MaxPerSubnetTable}
+	routingTable.PeerSampling = cfg.PeerSampling
+	routingTable.DialBack = cfg.DialBack
+	routingTable.FixedTimeouts = !cfg.AdaptiveTimeout
+	routingTable.MaxExchangePeers = cfg.MaxExchangePeers
+	if cfg.LookupCacheTTL > 0 {
+		routingTable.Lookups = models.NewLookupCache(cfg.LookupCacheTTL)
+	}
+	storage, storageErr := OpenKeyValueStore(cfg.Storage)
+	if storageErr != nil {
+		storage = NewKeyValueStore()
+	}
+	storage.Events = events
+	storage.RejectDistant = cfg.RejectDistantStores
+	storage.RequireWriteToken = cfg.RequireWriteTokens
+	storage.LogPlacement = cfg.LogStorePlacement
+	storage.Intervals = cfg.RecordIntervals
+	for name, policy := range cfg.Namespaces {
+		storage.SetNamespacePolicy(name, policy)
+	}
+
+	key, err := GenerateRecordKey()
+	if err != nil {
+		panic(fmt.Sprintf("failed to generate node record key: %v", err))
+	}
+
+	pubsub, watches := NewPubSub(), NewWatches(storage)
+	pubsub.Webhooks = NewWebhooks(cfg.WebhookPrivate)
+	watches.Webhooks = pubsub.Webhooks
+
+	var forward *ForwardQueue
+	if cfg.ForwardTTL > 0 {
+		forward = NewForwardQueue(routingTable, cfg.ForwardTTL, cfg.ForwardRetryInterval)
+	}
+
+	return &Node{
+		key:          key,
+		Self:         self,
+		RoutingTable: routingTable,
+		Storage:      storage,
+		Providers:    NewProviderStore(),
+		PubSub:       pubsub,
+		Watches:      watches,
+		Forward:      forward,
+		WriteTokens:  NewWriteTokens(),
+		Events:       events,
+		Metrics:      middleware.NewMetrics(),
+		relay:        NewRelay(),
+		cfg:          cfg,
+		storageErr:   storageErr,
+	}
+}
+
+// signRecord signs a new record for Self advertising its current address
+func (n *Node) signRecord() error {
+	// The Mainline transport is run by the CLI, see cmd.StartMainline
+	endpoints := []string{"http://" + n.Addr()}
+	capabilities := []string{models.CapKademliaHTTP, models.CapBencode}
+	if n.cfg.Mainline.Port > 0 {
+		endpoints = append(endpoints, fmt.Sprintf("udp://%s:%d", n.Self.IP, n.cfg.Mainline.Port))
+		capabilities = append(capabilities, models.CapMainline)
+	}
+	return SignNodeRecord(n.Self, n.key, endpoints, capabilities)
+}
+
+// Use appends mws to the middleware chain every RPC runs through, after
+// the recovery, logging, metrics, rate limiting and auth middleware
+// configured by Server. It must be called before Start.
+func (n *Node) Use(mws ...middleware.Middleware) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.extraMiddleware = append(n.extraMiddleware, mws...)
+}
+
+// Start listens for RPCs, starts garbage collection and, if a bootstrap
+// address is configured, joins the network through it: before returning,
+// failing if the join fails, or with Join.Background set in the background,
+// retrying with backoff while the node serves. When the configured port is
+// 0 the chosen port is written back to Self.Port.
+func (n *Node) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.server != nil {
+		return errors.New("node already started")
+	}
+	if err := n.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	if n.storageErr != nil {
+		return fmt.Errorf("failed to open storage: %v", n.storageErr)
+	}
+	if PeerSamplerNamed(n.cfg.PeerSampling) == nil {
+		return fmt.Errorf("invalid configuration: unknown peer sampling policy %q", n.cfg.PeerSampling)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port)))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return fmt.Errorf("port %d is already in use", n.cfg.Port)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+	n.Self.Port = listener.Addr().(*net.TCPAddr).Port
+
+	if err := n.signRecord(); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to sign node record: %v", err)
+	}
+
+	if n.cfg.PunchPort > 0 {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(n.cfg.Host), Port: n.cfg.PunchPort})
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen for hole punching: %v", err)
+		}
+		n.puncher = NewHolePuncher(n.Self.ID, conn)
+	}
+
+	AddNodeToRoutingTable(n.RoutingTable, n.Self, n.Self.ID)
+
+	n.scheduler = NewScheduler(SchedulerConfig{Jitter: n.cfg.Scheduler.Jitter, MaxConcurrent: n.cfg.Scheduler.MaxConcurrent})
+	mws := append(middleware.Default(n.cfg.Server, n.Metrics), n.extraMiddleware...)
+	if n.cfg.Server.AuditLog != "" {
+		n.audit, err = middleware.OpenAuditLog(n.cfg.Server.AuditLog, n.cfg.Server.AuditMaxBytes, n.cfg.Server.AuditBackups)
+		if err != nil {
+			listener.Close()
+			if n.puncher != nil {
+				n.puncher.Close()
+				n.puncher = nil
+			}
+			return err
+		}
+		mws = append([]middleware.Middleware{n.audit.Middleware()}, mws...)
+	}
+	sets := HandlerSets{
+		config.HandlersRPC: func(mux *http.ServeMux) {
+			RegisterRPC(mux, n.Self, n.RoutingTable, n.Storage, n.Providers, n.PubSub, n.Watches, n.relay, n.puncher, mws...)
+		},
+		config.HandlersAdmin: func(mux *http.ServeMux) {
+			mux.HandleFunc("/rpc_stats", tracing.Middleware("rpc_stats", n.Self.ID, middleware.Chain(mws...)("rpc_stats", n.Metrics.Handler)))
+			mux.HandleFunc("/runtime_stats", tracing.Middleware("runtime_stats", n.Self.ID, middleware.Chain(mws...)("runtime_stats", n.Metrics.RuntimeHandler)))
+			mux.HandleFunc("/forward_stats", tracing.Middleware("forward_stats", n.Self.ID, middleware.Chain(mws...)("forward_stats", n.Forward.Handler)))
+			RegisterStats(mux, n.Self, n.RoutingTable, n.Storage, mws...)
+			if n.cfg.Server.Pprof {
+				middleware.RegisterProfiling(mux, mws...)
+			}
+			if n.cfg.Server.AdminToken != "" {
+				RegisterAdmin(mux, n.Self, n.RoutingTable, n.Storage, n.scheduler, n.cfg.Server.AdminToken, n.cfg.GC.TTL, mws...)
+			}
+		},
+	}
+	if n.cfg.Server.Gateway {
+		// Gateway clients authenticate with API keys, not the network's token
+		public := n.cfg.Server
+		public.AuthToken = ""
+		gatewayMws := append(middleware.Default(public, n.Metrics), n.extraMiddleware...)
+		if n.audit != nil {
+			gatewayMws = append([]middleware.Middleware{n.audit.Middleware()}, gatewayMws...)
+		}
+		sets[config.HandlersGateway] = func(mux *http.ServeMux) {
+			RegisterGateway(mux, n.Self, n.RoutingTable, n.Storage, n.cfg.Server.GatewayAPIKeys, gatewayMws...)
+		}
+	}
+	wrap := func(h http.Handler) http.Handler {
+		return middleware.CORS(n.cfg.Server, middleware.Limits(n.cfg.Server, middleware.Compress(n.cfg.Server, chaos.Middleware(n.cfg.Chaos, withWireFormat(n.cfg.WireFormat, withWriteTokens(n.WriteTokens, withForwardQueue(n.Forward, h)))))))
+	}
+	n.listeners, err = ServeListeners(n.cfg.Server.AllListeners(), sets, wrap)
+	if err != nil {
+		listener.Close()
+		if n.puncher != nil {
+			n.puncher.Close()
+			n.puncher = nil
+		}
+		if n.audit != nil {
+			n.audit.Close()
+			n.audit = nil
+		}
+		return err
+	}
+	n.server = &http.Server{Handler: wrap(sets.Mux(n.cfg.Server.PortHandlers()))}
+	n.server.RegisterOnShutdown(n.relay.Close)
+	n.stopped = make(chan struct{})
+	go func(server *http.Server, stopped chan struct{}) {
+		defer close(stopped)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Kademlia node %s stopped serving: %v", n.Self.ID, err)
+		}
+	}(n.server, n.stopped)
+
+	background, cancel := context.WithCancel(WithWireFormat(WithWriteTokens(WithForwardQueue(retry.WithPolicy(context.Background(), retry.FromConfig(n.cfg.Retry)), n.Forward), n.WriteTokens), n.cfg.WireFormat))
+	n.stopBackground = cancel
+	if n.Forward != nil {
+		go n.Forward.Watch(background)
+	}
+
+	// Behind NAT, receive RPCs through the relay before announcing it
+	if n.cfg.Relay.Via != "" {
+		handler := n.server.Handler
+		done, err := RegisterWithRelay(background, n.cfg.Relay.Via, n.Self.Record, n.key, handler)
+		if err != nil {
+			n.stop()
+			return err
+		}
+		go func() {
+			<-done
+			ServeViaRelay(background, n.cfg.Relay.Via, n.Self.Record, n.key, handler)
+		}()
+	}
+	n.scheduleJobs()
+	go n.scheduler.Start(background)
+
+	if n.cfg.Bootstrap == "" {
+		return nil
+	}
+	if n.cfg.Join.Background {
+		go n.joinInBackground(background)
+		return nil
+	}
+	ctx = retry.WithPolicy(ctx, retry.FromConfig(n.cfg.Retry))
+	if err := JoinBootstrap(ctx, n.Self, n.RoutingTable, n.cfg.Bootstrap); err != nil {
+		n.stop()
+		return err
+	}
+	n.populate(ctx)
+	return nil
+}
+
+// RuntimeSamplerJob returns the job sampling the runtime stats served at
+// /runtime_stats into metrics every RuntimeSampleInterval
+func RuntimeSamplerJob(metrics *middleware.Metrics) Job {
+	return Job{Name: "runtime_sampler", Interval: middleware.RuntimeSampleInterval, Immediate: true, Run: func(context.Context) time.Duration {
+		metrics.SampleRuntime()
+		return 0
+	}}
+}
+
+// ProviderSweepJob returns the job dropping the expired provider records of
+// every key in providers every ProviderSweepInterval, including keys that
+// are never looked up again
+func ProviderSweepJob(providers *models.ProviderStore) Job {
+	return Job{Name: "provider_sweep", Interval: constants.ProviderSweepInterval, Run: func(context.Context) time.Duration {
+		providers.Sweep()
+		return 0
+	}}
+}
+
+// scheduleJobs adds the node's periodic jobs, as configured, to its
+// scheduler
+func (n *Node) scheduleJobs() {
+	add := func(job Job) {
+		if err := n.scheduler.Add(job); err != nil {
+			log.Printf("Failed to schedule %s: %v", job.Name, err)
+		}
+	}
+
+	add(RuntimeSamplerJob(n.Metrics))
+	add(ProviderSweepJob(n.Providers))
+	if n.Forward != nil {
+		add(Job{Name: "forward", Interval: n.Forward.interval, Run: func(ctx context.Context) time.Duration {
+			if delivered := n.Forward.Flush(ctx); delivered > 0 {
+				log.Printf("Forwarded %d queued replica writes", delivered)
+			}
+			return 0
+		}})
+	}
+	if n.cfg.GC.Interval > 0 {
+		gc := NewGarbageCollector(n.Storage, n.Self.ID, GCConfig{
+			Strategy: EvictionStrategy(n.cfg.GC.Strategy),
+			MaxBytes: n.cfg.GC.MaxBytes,
+			TTL:      n.cfg.GC.TTL,
+			Interval: n.cfg.GC.Interval,
+
+			TombstoneTTL: n.cfg.GC.TombstoneTTL,
+		})
+		add(Job{Name: "expire", Interval: n.cfg.GC.Interval, Run: func(context.Context) time.Duration {
+			if evicted := gc.Collect(); evicted > 0 {
+				fmt.Printf("Garbage collection evicted %d entries\n", evicted)
+			}
+			return 0
+		}})
+	}
+	if n.cfg.AntiEntropyInterval > 0 && !n.cfg.ClientOnly {
+		ae := NewAntiEntropy(n.Storage, n.RoutingTable, n.Self.ID)
+		add(Job{Name: "anti_entropy", Interval: n.cfg.AntiEntropyInterval, Run: func(ctx context.Context) time.Duration {
+			ae.SyncClosest(ctx)
+			return 0
+		}})
+	}
+	if n.cfg.RepublishInterval > 0 && !n.cfg.ClientOnly {
+		republisher := NewBackupRepublisher(n.Self, n.RoutingTable, n.Storage, n.cfg.RepublishInterval)
+		add(Job{Name: "republish", Interval: republisher.period(), Run: func(ctx context.Context) time.Duration {
+			if republished := republisher.Republish(ctx); republished > 0 {
+				fmt.Printf("Republished %d records on behalf of their publishers\n", republished)
+			}
+			return 0
+		}})
+	}
+	if n.cfg.KeyFilterInterval > 0 {
+		refresher := NewKeyFilterRefresher(n.RoutingTable, n.Self.ID, n.cfg.KeyFilterInterval)
+		add(Job{Name: "key_filters", Interval: n.cfg.KeyFilterInterval, Run: func(ctx context.Context) time.Duration {
+			refresher.Refresh(ctx)
+			return 0
+		}})
+	}
+	if n.cfg.RefreshInterval > 0 {
+		refresher := NewBucketRefresher(n.RoutingTable, n.Self.ID, n.cfg.RefreshInterval)
+		add(Job{Name: "refresh", Interval: n.cfg.RefreshInterval, Run: func(ctx context.Context) time.Duration {
+			if added := refresher.Refresh(ctx); added > 0 {
+				fmt.Printf("Bucket refresh added %d contacts\n", added)
+			}
+			return 0
+		}})
+	}
+	if n.cfg.Partition.Interval > 0 {
+		detector := NewPartitionDetector(n.Self, n.RoutingTable, PartitionConfig{
+			Interval:  n.cfg.Partition.Interval,
+			Sample:    n.cfg.Partition.Sample,
+			Threshold: n.cfg.Partition.Threshold,
+			Seeds:     n.cfg.RejoinSeeds(),
+		})
+		add(Job{Name: "partition", Interval: n.cfg.Partition.Interval, Run: func(ctx context.Context) time.Duration {
+			return detector.nextProbe(detector.Probe(ctx))
+		}})
+	}
+	if n.cfg.Address.Interval > 0 && !n.cfg.ClientOnly && n.cfg.Relay.Via == "" {
+		monitor := NewAddressMonitor(n.Self, n.RoutingTable, AddressConfig{
+			Interval: n.cfg.Address.Interval,
+			Sample:   n.cfg.Address.Sample,
+			Quorum:   n.cfg.Address.Quorum,
+			Resign:   n.signRecord,
+		})
+		add(Job{Name: "address", Interval: n.cfg.Address.Interval, Run: func(ctx context.Context) time.Duration {
+			monitor.Check(ctx)
+			return 0
+		}})
+	}
+	if len(n.cfg.Peers) > 0 {
+		pinned := NewPinnedPeers(n.Self, n.RoutingTable, n.cfg.Peers)
+		add(Job{Name: "pinned_peers", Interval: n.cfg.PeerRedialInterval, Immediate: true, Run: func(ctx context.Context) time.Duration {
+			if added := pinned.Dial(ctx); added > 0 {
+				fmt.Printf("Re-dialed %d pinned peers\n", added)
+			}
+			return 0
+		}})
+	}
+}
+
+// joinInBackground joins through the bootstrap address, retrying as
+// configured by Join, then populates the node. A node that cannot join
+// keeps serving on its own.
+func (n *Node) joinInBackground(ctx context.Context) {
+	log.Printf("Attempting to join the network via bootstrap node: %s\n", n.cfg.Bootstrap)
+	err := JoinWithRetry(ctx, n.Self, n.RoutingTable, n.cfg.Bootstrap, JoinRetry{
+		Attempts:   n.cfg.Join.Attempts,
+		Backoff:    n.cfg.Join.Backoff,
+		MaxBackoff: n.cfg.Join.MaxBackoff,
+		Jitter:     n.cfg.Retry.Jitter,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to join network, running standalone: %v", err)
+		}
+		return
+	}
+	log.Println("Successfully joined the network.")
+	n.populate(ctx)
+}
+
+// populate fills the routing table from the tables of the node's first
+// contacts and, unless the node is client-only, pulls the records it is
+// now closer to than they are
+func (n *Node) populate(ctx context.Context) {
+	contacts := SamplePeers(n.RoutingTable, "", 0, -1, n.Self.ID)
+	for _, peer := range contacts {
+		if count, err := ImportRoutingTable(ctx, n.RoutingTable, peer, n.Self.ID); err != nil {
+			log.Printf("Failed to import routing table from %s: %v", peer.ID, err)
+		} else if count > 0 {
+			log.Printf("Imported %d contacts from %s\n", count, peer.ID)
+		}
+	}
+	if n.cfg.ClientOnly {
+		return
+	}
+
+	// Take over the records we are now closer to than our contacts
+	for _, peer := range contacts {
+		if count, err := PullRecords(ctx, n.Self.ID, n.Storage, peer); err != nil {
+			log.Printf("Failed to pull records from %s: %v", peer.ID, err)
+		} else if count > 0 {
+			log.Printf("Pulled %d records from %s\n", count, peer.ID)
+		}
+	}
+}
+
+// Stop shuts the RPC server down, waiting briefly for in-flight requests,
+// and stops garbage collection and anti-entropy. Stopping a node that is not running is a
+// no-op. Storage stays open so the node can be restarted; close it with
+// Storage.Close once the node is no longer needed.
+func (n *Node) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.stop()
+}
+
+func (n *Node) stop() error {
+	if n.server == nil {
+		return nil
+	}
+	if n.stopBackground != nil {
+		n.stopBackground()
+		n.stopBackground = nil
+	}
+	n.scheduler = nil
+	if n.puncher != nil {
+		n.puncher.Close()
+		n.puncher = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, listener := range n.listeners {
+		if err := listener.Shutdown(ctx); err != nil {
+			listener.Close()
+		}
+	}
+	n.listeners = nil
+	err := n.server.Shutdown(ctx)
+	if err != nil {
+		n.server.Close()
+	}
+	<-n.stopped
+	n.server = nil
+	if n.audit != nil {
+		n.audit.Close()
+		n.audit = nil
+	}
+	return err
+}
+
+// Jobs returns the status of the node's periodic jobs while it runs
+func (n *Node) Jobs() []JobStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.scheduler == nil {
+		return nil
+	}
+	return n.scheduler.Jobs()
+}
+
+// Addr returns the <ip>:<port> the node serves RPCs on
+func (n *Node) Addr() string {
+	return net.JoinHostPort(n.Self.IP, strconv.Itoa(n.Self.Port))
+}
+
+// FindNode looks up the k contacts closest to id across the network
+func (n *Node) FindNode(ctx context.Context, id string) ([]*models.Node, error) {
+	if err := validators.ValidateID(id, validators.HexadecimalValidator); err != nil {
+		return nil, fmt.Errorf("invalid node ID: %v", err)
+	}
+	return IterativeFindNodeWithOptions(n.rpcContext(ctx), n.RoutingTable, n.Self.ID, id, n.lookupOptions()), nil
+}
+
+// Put stores value under key on the k nodes closest to key, including this
+// node when it is among them, and reports which replicas acknowledged. It
+// fails only if no node accepted the value.
+func (n *Node) Put(ctx context.Context, key, value string) (StoreAck, error) {
+	if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+		return StoreAck{}, fmt.Errorf("invalid key: %v", err)
+	}
+
+	ack := IterativeStore(n.rpcContext(ctx), n.RoutingTable, n.Self, n.Storage, StoreRequest{Key: key, Value: value})
+	if ack.ReplicationFactor == 0 {
+		reason := "no nodes available"
+		if len(ack.Replicas) > 0 {
+			reason = ack.Replicas[len(ack.Replicas)-1].Error
+		}
+		return ack, fmt.Errorf("failed to store key %s: %s", key, reason)
+	}
+	return ack, nil
+}
+
+// Delete replaces the value of key on the k nodes closest to key with a
+// tombstone, so replicas that miss the delete do not copy the value back.
+// It fails only if no node recorded the tombstone.
+func (n *Node) Delete(ctx context.Context, key string) (StoreAck, error) {
+	if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+		return StoreAck{}, fmt.Errorf("invalid key: %v", err)
+	}
+
+	ack := IterativeDelete(n.rpcContext(ctx), n.RoutingTable, n.Self, n.Storage, DeleteRequest{Key: key})
+	if ack.ReplicationFactor == 0 {
+		reason := "no nodes available"
+		if len(ack.Replicas) > 0 {
+			reason = ack.Replicas[len(ack.Replicas)-1].Error
+		}
+		return ack, fmt.Errorf("failed to delete key %s: %s", key, reason)
+	}
+	return ack, nil
+}
+
+// lookupOptions returns the options of the node's own lookups
+func (n *Node) lookupOptions() LookupOptions {
+	return LookupOptions{Alpha: n.cfg.Alpha}
+}
+
+// rpcContext returns ctx carrying the node's event bus, forward queue,
+// write tokens, wire format and retry policy for the RPCs made under it
+func (n *Node) rpcContext(ctx context.Context) context.Context {
+	return retry.WithPolicy(WithWireFormat(WithWriteTokens(WithForwardQueue(WithEvents(ctx, n.Events), n.Forward), n.WriteTokens), n.cfg.WireFormat), retry.FromConfig(n.cfg.Retry))
+}
+
+// Ownership estimates the share of the keyspace this node is responsible
+// for from its routing table
+func (n *Node) Ownership() Ownership {
+	return EstimateOwnership(n.RoutingTable, n.Self.ID)
+}
+
+// Send sends msg to peer in a models.Message envelope from this node,
+// filling in the sender, version and nonce, and signs it with the node's
+// record key
+func (n *Node) Send(ctx context.Context, peer *models.Node, msg models.Message) (models.Message, error) {
+	envelope := NewMessage(msg.Type, n.Self)
+	envelope.Key, envelope.Value, envelope.Target = msg.Key, msg.Value, msg.Target
+	envelope.Encoding = msg.Encoding
+	envelope.EncodeValue()
+	if n.Self.Record != nil {
+		if err := envelope.Sign(n.key); err != nil {
+			return models.Message{}, err
+		}
+	}
+	return SendMessage(ctx, peer, envelope)
+}
+
+// Punch opens a direct UDP path to the node to, introduced by
+// coordinator, a node both sides are known to. It requires a PunchPort and
+// returns the address to answered from.
+func (n *Node) Punch(ctx context.Context, coordinator *models.Node, to string) (*net.UDPAddr, error) {
+	n.mu.Lock()
+	puncher := n.puncher
+	n.mu.Unlock()
+	if puncher == nil {
+		return nil, ErrHolePunchingDisabled
+	}
+	return puncher.Punch(ctx, coordinator, to)
+}
+
+// Get returns the value stored under key, checking local storage before
+// asking the nodes closest to key
+func (n *Node) Get(ctx context.Context, key string) (string, bool, error) {
+	if value, ok := n.Storage.Get(key); ok {
+		return value, true, nil
+	}
+	if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+		return "", false, fmt.Errorf("invalid key: %v", err)
+	}
+
+	value, found := IterativeFindValue(n.rpcContext(ctx), n.RoutingTable, n.Self.ID, n.Storage, key, n.lookupOptions())
+	return value, found, nil
+}
+
+// GetMany returns the values stored under those of keys any node holds, by
+// key. Lookups for keys sharing their closest nodes share their RPCs.
+func (n *Node) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	for _, key := range keys {
+		if err := validators.ValidateID(key, validators.HexadecimalValidator); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %v", key, err)
+		}
+	}
+	return IterativeFindValues(n.rpcContext(ctx), n.RoutingTable, n.Self.ID, n.Storage, keys, n.lookupOptions()), nil
+}
@@ -0,0 +1,91 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Ownership estimates the share of the keyspace a node is responsible for.
+// A node is among the k closest to every key nearer to it than its k-th
+// closest neighbour, so that distance over the size of the keyspace
+// approximates the fraction of keys it stores, and k over that fraction
+// approximates the size of the network.
+type Ownership struct {
+	NodeID               string  `json:"node_id"`
+	K                    int     `json:"k"`
+	Contacts             int     `json:"contacts"`               // Known contacts, excluding the node itself
+	KthDistanceBits      int     `json:"kth_distance_bits"`      // Bit length of the XOR distance to the k-th closest contact
+	Fraction             float64 `json:"fraction"`               // Estimated share of the keyspace, between 0 and 1
+	EstimatedNetworkSize float64 `json:"estimated_network_size"` // Estimated number of nodes in the network
+	// UnderPopulated is set when fewer than k contacts are known, so the
+	// node would hold a replica of every key: the network is small or this
+	// node is cut off from it
+	UnderPopulated bool `json:"under_populated"`
+}
+
+// ErrOutsideResponsibility is returned for a STORE of a key farther from
+// the node than its k-th closest contact
+var ErrOutsideResponsibility = errors.New("key is outside this node's responsibility radius")
+
+// InResponsibility reports whether key lies within the responsibility
+// radius of localID: no farther from it than its k-th closest contact.
+// Every key does while fewer than k contacts are known.
+func InResponsibility(routingTable *models.RoutingTable, localID, key string) bool {
+	distances := contactDistances(routingTable, localID)
+	k := routingTable.BucketSize()
+	if len(distances) < k {
+		return true
+	}
+	return calculateXORDistance(localID, key).Cmp(distances[k-1]) <= 0
+}
+
+// EstimateOwnership estimates the keyspace share of localID from its
+// routing table
+func EstimateOwnership(routingTable *models.RoutingTable, localID string) Ownership {
+	distances := contactDistances(routingTable, localID)
+	k := routingTable.BucketSize()
+	o := Ownership{NodeID: localID, K: k, Contacts: len(distances)}
+	if len(distances) < k {
+		o.Fraction = 1
+		o.EstimatedNetworkSize = float64(len(distances) + 1)
+		o.UnderPopulated = true
+		if len(distances) > 0 {
+			o.KthDistanceBits = distances[len(distances)-1].BitLen()
+		}
+		return o
+	}
+
+	kth := distances[k-1]
+	o.KthDistanceBits = kth.BitLen()
+	keyspace := new(big.Int).Lsh(big.NewInt(1), uint(len(localID)*4))
+	o.Fraction, _ = new(big.Float).Quo(new(big.Float).SetInt(kth), new(big.Float).SetInt(keyspace)).Float64()
+	o.EstimatedNetworkSize = float64(len(distances) + 1)
+	if o.Fraction > 0 {
+		o.EstimatedNetworkSize = max(o.EstimatedNetworkSize, float64(k)/o.Fraction)
+	}
+	return o
+}
+
+// OwnershipHandler handles /ownership requests
+func OwnershipHandler(w http.ResponseWriter, r *http.Request, node *models.Node, routingTable *models.RoutingTable) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(EstimateOwnership(routingTable, node.ID))
+}
+
+// contactDistances returns the distances from localID to every other
+// contact, closest first
+func contactDistances(routingTable *models.RoutingTable, localID string) []*big.Int {
+	var distances []*big.Int
+	for _, n := range routingTable.Contacts() {
+		if n.ID != localID {
+			distances = append(distances, calculateXORDistance(localID, n.ID))
+		}
+	}
+	sort.Slice(distances, func(i, j int) bool { return distances[i].Cmp(distances[j]) < 0 })
+	return distances
+}
@@ -0,0 +1,108 @@
+package kademlia
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// PartitionConfig configures partition detection
+type PartitionConfig struct {
+	Interval  time.Duration // Time between probes; while partitioned, rejoining is retried ten times as often
+	Sample    int           // Contacts probed per round, longest unseen first
+	Threshold float64       // Fraction of probed contacts that must fail to declare a partition
+	Seeds     []string      // Bootstrap addresses to rejoin through, as accepted by JoinBootstrap
+}
+
+// PartitionReport is the outcome of one probe round
+type PartitionReport struct {
+	Probed      int
+	Unreachable []*models.Node
+	Partitioned bool // At least Threshold of the probed contacts failed
+	Healed      bool // A seed answered and the node rejoined through it
+}
+
+// PartitionDetector watches for the node being cut off from the network.
+// Each round it sends FIND_NODE for a distant random key to the contacts
+// it has heard from least recently; if too many fail it emits
+// PartitionDetected and rejoins through the seeds, emitting
+// PartitionHealed once one answers.
+type PartitionDetector struct {
+	node         *models.Node
+	routingTable *models.RoutingTable
+	cfg          PartitionConfig
+}
+
+// NewPartitionDetector creates a detector for node and its routing table
+func NewPartitionDetector(node *models.Node, routingTable *models.RoutingTable, cfg PartitionConfig) *PartitionDetector {
+	return &PartitionDetector{node: node, routingTable: routingTable, cfg: cfg}
+}
+
+// nextProbe returns the wait before the probe after one that reported
+// report: a tenth of the interval while the node stays partitioned
+func (pd *PartitionDetector) nextProbe(report PartitionReport) time.Duration {
+	if report.Partitioned && !report.Healed {
+		return pd.cfg.Interval / 10
+	}
+	return pd.cfg.Interval
+}
+
+// Probe runs one round of probing and, if the node looks partitioned,
+// rejoining
+func (pd *PartitionDetector) Probe(ctx context.Context) PartitionReport {
+	var contacts []*models.Node
+	for _, n := range pd.routingTable.Contacts() {
+		if n.ID != pd.node.ID {
+			contacts = append(contacts, n)
+		}
+	}
+	sort.SliceStable(contacts, func(i, j int) bool { return contacts[i].SeenAt().Before(contacts[j].SeenAt()) })
+	if pd.cfg.Sample > 0 && len(contacts) > pd.cfg.Sample {
+		contacts = contacts[:pd.cfg.Sample]
+	}
+
+	var report PartitionReport
+	farthest := len(pd.node.ID)*4 - 1
+	for _, peer := range contacts {
+		if ctx.Err() != nil {
+			return report
+		}
+		report.Probed++
+		_, err := SendFindNode(ctx, peer, RandomIDInBucket(pd.node.ID, farthest))
+		pd.routingTable.Peers.RecordRPC(peer, err)
+		if err != nil {
+			report.Unreachable = append(report.Unreachable, peer)
+		}
+	}
+	if report.Probed == 0 || float64(len(report.Unreachable)) < pd.cfg.Threshold*float64(report.Probed) {
+		return report
+	}
+
+	report.Partitioned = true
+	log.Printf("Possible network partition: %d of %d probed contacts unreachable", len(report.Unreachable), report.Probed)
+	pd.routingTable.Events.Emit(models.Event{Type: models.PartitionDetected, Nodes: report.Unreachable})
+
+	for _, seed := range pd.cfg.Seeds {
+		if err := JoinBootstrap(ctx, pd.node, pd.routingTable, seed); err != nil {
+			log.Printf("Failed to rejoin through %s: %v", seed, err)
+			continue
+		}
+		report.Healed = true
+		break
+	}
+	if report.Healed {
+		// Look ourselves up to refill the buckets around our ID
+		for _, n := range IterativeFindNode(ctx, pd.routingTable, pd.node.ID, pd.node.ID) {
+			if n.ID != pd.node.ID {
+				AddNodeToRoutingTable(pd.routingTable, n, pd.node.ID)
+			}
+		}
+		fmt.Println("Rejoined the network after a partition")
+		pd.routingTable.Events.Emit(models.Event{Type: models.PartitionHealed})
+	}
+	return report
+}
@@ -0,0 +1,107 @@
+package kademlia
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Built-in peer sampling policies
+const (
+	SampleRandom   = "random"   // Peers chosen uniformly at random
+	SampleClosest  = "closest"  // Peers closest to the key, or to the node when there is none
+	SampleFreshest = "freshest" // Peers seen most recently
+)
+
+// PeerSampler chooses up to count of candidates for a peer list. target is
+// the key the list was asked about, or the local node's ID when there is
+// none. Samplers may reorder candidates, which is a copy owned by the
+// caller, and return a subslice of it.
+type PeerSampler func(candidates []*models.Node, target string, count int) []*models.Node
+
+var (
+	samplersMu sync.RWMutex
+	samplers   = map[string]PeerSampler{
+		SampleRandom:   sampleRandom,
+		SampleClosest:  sampleClosest,
+		SampleFreshest: sampleFreshest,
+	}
+)
+
+// RegisterPeerSampler makes sampler available under name, to nodes
+// configured with it and to /peers requests naming it, replacing any
+// sampler registered under that name. Registration should happen before
+// nodes start.
+func RegisterPeerSampler(name string, sampler PeerSampler) {
+	samplersMu.Lock()
+	defer samplersMu.Unlock()
+	samplers[name] = sampler
+}
+
+// PeerSamplerNamed returns the sampler registered under name, or nil if
+// there is none. "" names the random sampler.
+func PeerSamplerNamed(name string) PeerSampler {
+	if name == "" {
+		name = SampleRandom
+	}
+	samplersMu.RLock()
+	defer samplersMu.RUnlock()
+	return samplers[name]
+}
+
+// SamplePeers returns up to count peers from the routing table, chosen at
+// random. When key is non-empty only peers whose XOR distance to key is
+// below 2^radius are considered, so applications can discover participants
+// clustered around an info-hash. The local node is never included.
+func SamplePeers(routingTable *models.RoutingTable, key string, radius, count int, localID string) []*models.Node {
+	return SamplePeersWith(sampleRandom, routingTable, key, radius, count, localID)
+}
+
+// SamplePeersWith is SamplePeers choosing among the candidates with
+// sampler. A negative count returns every candidate, in the sampler's
+// order; otherwise the list is cut to count whatever sampler returns.
+func SamplePeersWith(sampler PeerSampler, routingTable *models.RoutingTable, key string, radius, count int, localID string) []*models.Node {
+	var candidates []*models.Node
+	for _, n := range routingTable.Contacts() {
+		if n.ID == localID {
+			continue
+		}
+		if key != "" && distanceBitLen(key, n.ID) > radius {
+			continue
+		}
+		candidates = append(candidates, n)
+	}
+
+	target := key
+	if target == "" {
+		target = localID
+	}
+	limit := count
+	if limit < 0 {
+		limit = len(candidates)
+	}
+	candidates = sampler(candidates, target, limit)
+
+	if count >= 0 && len(candidates) > count {
+		candidates = candidates[:count]
+	}
+	return candidates
+}
+
+func sampleRandom(candidates []*models.Node, target string, count int) []*models.Node {
+	shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return candidates
+}
+
+func sampleClosest(candidates []*models.Node, target string, count int) []*models.Node {
+	sortByDistance(candidates, target)
+	return candidates
+}
+
+func sampleFreshest(candidates []*models.Node, target string, count int) []*models.Node {
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].SeenAt().After(candidates[j].SeenAt()) })
+	return candidates
+}
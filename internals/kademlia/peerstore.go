@@ -0,0 +1,56 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// PeerStoreList summarises the peer store and lists the most recently
+// seen peers
+type PeerStoreList struct {
+	Peers   int               `json:"peers"`    // Peers stored
+	InTable int               `json:"in_table"` // Of which the routing table holds
+	Failing int               `json:"failing"`  // Of which the last RPC failed
+	List    []models.PeerInfo `json:"list"`     // Up to the requested limit, most recently seen first
+
+	Inserts models.InsertStats `json:"inserts"` // New IDs added to the routing table per IP, against the insert limit
+}
+
+// PeerStoreHandler handles /peer_store requests, listing what the node
+// knows about the peers it has seen, or about the peer id if given
+func PeerStoreHandler(w http.ResponseWriter, r *http.Request, routingTable *models.RoutingTable) {
+	req := PeerStoreRequest{Limit: DefaultIterateLimit}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	if req.ID != "" {
+		info, ok := routingTable.Peers.Peer(req.ID)
+		if !ok {
+			http.Error(w, "Peer not seen", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+
+	peers := routingTable.Peers.Peers()
+	list := PeerStoreList{Peers: len(peers), List: []models.PeerInfo{}, Inserts: routingTable.Peers.InsertStats()}
+	for _, p := range peers {
+		if p.InTable {
+			list.InTable++
+		}
+		if p.Failing() {
+			list.Failing++
+		}
+	}
+	if len(peers) > req.Limit {
+		peers = peers[:req.Limit]
+	}
+	list.List = append(list.List, peers...)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,88 @@
+package kademlia
+
+import (
+	"context"
+	"log"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// PinnedPeers keeps a fixed set of peers, such as stable infrastructure
+// nodes, in the routing table. Pinned contacts are never evicted, and
+// each Dial, which the node's scheduler runs every redial interval, pings
+// every peer again, re-adding any that were lost and following peers that
+// restarted with a new ID.
+type PinnedPeers struct {
+	node         *models.Node
+	routingTable *models.RoutingTable
+	addrs        []string
+	ids          map[string]string // Last ID seen at each address
+}
+
+// NewPinnedPeers creates a keeper for the peers at addrs, each a
+// <host>:<port>, in the routing table of node
+func NewPinnedPeers(node *models.Node, routingTable *models.RoutingTable, addrs []string) *PinnedPeers {
+	return &PinnedPeers{
+		node:         node,
+		routingTable: routingTable,
+		addrs:        addrs,
+		ids:          make(map[string]string),
+	}
+}
+
+// Dial pings every pinned peer, pinning the contact that answers, and
+// returns the number of peers that were missing from the routing table
+func (pp *PinnedPeers) Dial(ctx context.Context) int {
+	added := 0
+	for _, addr := range pp.addrs {
+		if ctx.Err() != nil {
+			break
+		}
+		previous := pp.ids[addr]
+		missing := previous == "" || !containsNode(pp.routingTable, previous)
+
+		peer, err := pp.dial(ctx, addr)
+		if err != nil {
+			log.Printf("Failed to dial pinned peer %s: %v", addr, err)
+			continue
+		}
+		if previous != "" && previous != peer.ID {
+			pp.routingTable.Lock()
+			delete(pp.routingTable.Pinned, previous)
+			pp.routingTable.Unlock()
+			removeFromRoutingTable(pp.routingTable, previous)
+		}
+		PinNode(pp.routingTable, peer.ID)
+		pp.ids[addr] = peer.ID
+		if missing {
+			added++
+		}
+	}
+	return added
+}
+
+// dial resolves addr and pings the first address that answers
+func (pp *PinnedPeers) dial(ctx context.Context, addr string) (*models.Node, error) {
+	candidates, err := ResolveBootstrap(ctx, SeedResolver, addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		var peer *models.Node
+		peer, err = joinNetwork(ctx, pp.node, pp.routingTable, candidate)
+		if err == nil {
+			return peer, nil
+		}
+	}
+	return nil, err
+}
+
+// PinNode exempts the contact id from eviction
+func PinNode(routingTable *models.RoutingTable, id string) {
+	routingTable.Lock()
+	defer routingTable.Unlock()
+	if routingTable.Pinned == nil {
+		routingTable.Pinned = make(map[string]bool)
+	}
+	routingTable.Pinned[id] = true
+}
@@ -0,0 +1,41 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// notePlacementMiss counts a STORE of key from sender that node answered
+// with closest, the k contacts nearest key it knows, and, if storage logs
+// placement, logs how much farther node is from key, in log2 distance,
+// than the farthest of them
+func notePlacementMiss(storage *models.KeyValueStore, node *models.Node, key, sender string, closest []*models.Node) {
+	own, kth := distanceBitLen(node.ID, key), 0
+	if len(closest) > 0 {
+		kth = distanceBitLen(closest[len(closest)-1].ID, key)
+	}
+	storage.Placement.NotClosest(own - kth)
+	if storage.LogPlacement {
+		log.Printf("STORE of %s from %s missed the k closest by %d bits (distance 2^%d, k-th closest 2^%d)", key, sender, own-kth, own, kth)
+	}
+}
+
+// notePlacementOutside counts a STORE of key from sender that the node
+// localID refused for lying outside its responsibility radius and, if
+// storage logs placement, logs the distance
+func notePlacementOutside(storage *models.KeyValueStore, localID, key, sender string) {
+	storage.Placement.Outside()
+	if storage.LogPlacement {
+		log.Printf("STORE of %s from %s refused outside the responsibility radius at distance 2^%d", key, sender, distanceBitLen(localID, key))
+	}
+}
+
+// StoreStatsHandler handles /store_stats requests, reporting how the
+// node answered the STOREs sent to it
+func StoreStatsHandler(w http.ResponseWriter, r *http.Request, storage *models.KeyValueStore) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(storage.Placement.Snapshot())
+}
@@ -0,0 +1,209 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Limits of pub/sub on a rendezvous node
+const (
+	MaxSubscribers = 1000  // Subscribers a topic's list holds
+	MaxMailboxes   = 10000 // Mailboxes a node keeps across all topics
+	MaxMailbox     = 1000  // Messages a mailbox keeps, the oldest being dropped first
+)
+
+// ErrTooManySubscribers is returned when a topic already has MaxSubscribers
+// subscribers, or the node keeps MaxMailboxes mailboxes
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// PubSub keeps the per-subscriber mailboxes of a rendezvous node.
+// Subscriber lists themselves live in the node's KeyValueStore under the
+// topic key as OR-set records, which the subscribe handler replicates to
+// the k closest nodes, so they are visible to FIND_VALUE like any other
+// record and any holder can take over as the topic's rendezvous.
+type PubSub struct {
+	Webhooks *Webhooks // Notifies webhook subscribers
+
+	mu        sync.Mutex
+	mailboxes map[string]map[string]*mailbox // topic key -> subscriber ID -> mailbox
+	count     int                            // Mailboxes across all topics
+}
+
+type mailbox struct {
+	messages []models.TopicMessage
+	signal   chan struct{} // closed and replaced whenever a message arrives
+}
+
+// NewPubSub creates an empty PubSub notifying only public webhooks
+func NewPubSub() *PubSub {
+	return &PubSub{Webhooks: NewWebhooks(false), mailboxes: make(map[string]map[string]*mailbox)}
+}
+
+// TopicKey hashes a topic name into a 160-bit key in the DHT keyspace.
+func TopicKey(topic string) string {
+	return KeyFromString(topic)
+}
+
+// Subscribers returns the subscriber list stored under the topic's key.
+func Subscribers(storage *models.KeyValueStore, topic string) []models.Subscriber {
+	return subscribersOf(subscriberRecord(storage, TopicKey(topic)))
+}
+
+// subscriberRecord returns the subscriber list stored under key, or an
+// empty one
+func subscriberRecord(storage *models.KeyValueStore, key string) models.CRDTRecord {
+	if raw, exists := storage.Get(key); exists {
+		if rec, err := models.ParseCRDTRecord(raw, models.RecordORSet); err == nil {
+			return rec
+		}
+	}
+	return models.NewCRDTRecord(models.RecordORSet)
+}
+
+// subscribersOf decodes the subscribers of a list. Replicas that took
+// concurrent subscriptions of one ID may both hold it; the one sorting
+// last is kept, so every holder picks the same.
+func subscribersOf(rec models.CRDTRecord) []models.Subscriber {
+	byID := make(map[string]models.Subscriber)
+	for _, element := range rec.Elements() {
+		var sub models.Subscriber
+		if json.Unmarshal([]byte(element), &sub) == nil && sub.ID != "" {
+			byID[sub.ID] = sub
+		}
+	}
+	subscribers := make([]models.Subscriber, 0, len(byID))
+	for _, sub := range byID {
+		subscribers = append(subscribers, sub)
+	}
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i].ID < subscribers[j].ID })
+	return subscribers
+}
+
+// Subscribe adds (or updates) a subscriber in the topic's record and
+// prepares a mailbox for it. It returns the record, for the caller to
+// replicate, and fails with ErrTooManySubscribers when the topic or the
+// node is full, or with ErrWebhookTarget for a webhook it may not notify.
+func (ps *PubSub) Subscribe(storage *models.KeyValueStore, topic string, sub models.Subscriber) (models.CRDTRecord, error) {
+	if sub.Webhook != "" {
+		if err := ps.Webhooks.Check(context.Background(), sub.Webhook); err != nil {
+			return models.CRDTRecord{}, err
+		}
+	}
+	key := TopicKey(topic)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	rec := subscriberRecord(storage, key)
+	subscribers := subscribersOf(rec)
+	replaced := false
+	for _, element := range rec.Elements() {
+		var have models.Subscriber
+		if json.Unmarshal([]byte(element), &have) == nil && have.ID == sub.ID {
+			rec.Remove(element)
+			replaced = true
+		}
+	}
+	if !replaced && len(subscribers) >= MaxSubscribers {
+		return models.CRDTRecord{}, ErrTooManySubscribers
+	}
+	if ps.mailboxes[key][sub.ID] == nil && ps.count >= MaxMailboxes {
+		return models.CRDTRecord{}, ErrTooManySubscribers
+	}
+	element, _ := json.Marshal(sub)
+	rec.Add(string(element), subscriptionTag())
+	storage.Set(key, rec.Encode())
+
+	ps.mailboxFor(key, sub.ID)
+	return rec, nil
+}
+
+// subscriptionTag returns a unique tag for an addition to a subscriber list
+func subscriptionTag() string {
+	tag := make([]byte, 16)
+	randReader().Read(tag)
+	return hex.EncodeToString(tag)
+}
+
+// mailboxFor returns the mailbox of a subscriber, creating it unless the
+// node keeps MaxMailboxes already; ps.mu must be held
+func (ps *PubSub) mailboxFor(key, subscriberID string) *mailbox {
+	if box := ps.mailboxes[key][subscriberID]; box != nil {
+		return box
+	}
+	if ps.count >= MaxMailboxes {
+		return nil
+	}
+	if ps.mailboxes[key] == nil {
+		ps.mailboxes[key] = make(map[string]*mailbox)
+	}
+	box := &mailbox{signal: make(chan struct{})}
+	ps.mailboxes[key][subscriberID] = box
+	ps.count++
+	return box
+}
+
+// Publish delivers a message to every subscriber of the topic and returns
+// the number of subscribers it was delivered to.
+func (ps *PubSub) Publish(storage *models.KeyValueStore, topic, payload string) int {
+	key := TopicKey(topic)
+	msg := models.TopicMessage{Topic: topic, Payload: payload, Timestamp: time.Now().Unix()}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	subscribers := Subscribers(storage, topic)
+	for _, sub := range subscribers {
+		if box := ps.mailboxFor(key, sub.ID); box != nil {
+			if len(box.messages) >= MaxMailbox {
+				box.messages = box.messages[1:]
+			}
+			box.messages = append(box.messages, msg)
+			close(box.signal)
+			box.signal = make(chan struct{})
+		}
+
+		if sub.Webhook != "" {
+			ps.Webhooks.Notify(sub.Webhook, msg)
+		}
+	}
+	return len(subscribers)
+}
+
+// Poll returns the pending messages of a subscriber, waiting up to timeout
+// for one to arrive if the mailbox is empty.
+func (ps *PubSub) Poll(ctx context.Context, topic, subscriberID string, timeout time.Duration) []models.TopicMessage {
+	key := TopicKey(topic)
+
+	ps.mu.Lock()
+	box := ps.mailboxes[key][subscriberID]
+	if box == nil {
+		ps.mu.Unlock()
+		return nil
+	}
+	if len(box.messages) == 0 {
+		signal := box.signal
+		ps.mu.Unlock()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-signal:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		ps.mu.Lock()
+	}
+	defer ps.mu.Unlock()
+
+	messages := box.messages
+	box.messages = nil
+	return messages
+}
@@ -0,0 +1,294 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Hole punching probes. A PUNCH names its sender and is answered with a
+// PUNCH-ACK; receiving either from a peer proves the path is open.
+const (
+	punchProbe = "PUNCH "
+	punchAck   = "PUNCH-ACK "
+
+	punchInterval = 100 * time.Millisecond
+)
+
+// ErrHolePunchingDisabled is returned by nodes without a UDP socket for
+// hole punching
+var ErrHolePunchingDisabled = errors.New("hole punching is disabled")
+
+// PunchRequest asks a coordinator to introduce From to To. Endpoint is
+// From's UDP <ip>:<port>; an empty or unspecified IP is replaced with the
+// address the coordinator sees the request come from.
+type PunchRequest struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Endpoint string `json:"endpoint"`
+}
+
+// PunchReply carries the UDP endpoint of the other side of a punch
+type PunchReply struct {
+	Endpoint string `json:"endpoint"`
+}
+
+// HolePuncher opens direct UDP paths to peers behind NAT. Two peers that
+// both know a coordinator exchange their UDP endpoints through it and then
+// probe each other at the same time, so each NAT sees outbound traffic to
+// the other before the other's probes arrive. This works with NATs that
+// keep the same external port for every destination, which most home
+// routers do.
+type HolePuncher struct {
+	localID string
+	conn    *net.UDPConn
+
+	mu          sync.Mutex
+	established map[string]*net.UDPAddr
+	waiting     map[string]chan *net.UDPAddr
+}
+
+// NewHolePuncher creates a puncher for localID sending probes from conn,
+// and starts answering the probes it receives
+func NewHolePuncher(localID string, conn *net.UDPConn) *HolePuncher {
+	hp := &HolePuncher{
+		localID:     localID,
+		conn:        conn,
+		established: make(map[string]*net.UDPAddr),
+		waiting:     make(map[string]chan *net.UDPAddr),
+	}
+	go hp.serve()
+	return hp
+}
+
+// LocalEndpoint returns the local <ip>:<port> probes are sent from
+func (hp *HolePuncher) LocalEndpoint() string {
+	return hp.conn.LocalAddr().String()
+}
+
+// Established returns the address a direct path to id was opened to, or
+// nil if there is none
+func (hp *HolePuncher) Established(id string) *net.UDPAddr {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	return hp.established[id]
+}
+
+// Close stops the puncher and closes its socket
+func (hp *HolePuncher) Close() error {
+	return hp.conn.Close()
+}
+
+func (hp *HolePuncher) serve() {
+	buf := make([]byte, 256)
+	for {
+		n, addr, err := hp.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Hole punching socket failed: %v", err)
+			}
+			return
+		}
+
+		msg := string(buf[:n])
+		var from string
+		switch {
+		case strings.HasPrefix(msg, punchAck):
+			from = strings.TrimPrefix(msg, punchAck)
+		case strings.HasPrefix(msg, punchProbe):
+			from = strings.TrimPrefix(msg, punchProbe)
+			hp.conn.WriteToUDP([]byte(punchAck+hp.localID), addr)
+		default:
+			continue
+		}
+		if validators.ValidateID(from, validators.HexadecimalValidator) != nil {
+			continue
+		}
+
+		hp.mu.Lock()
+		hp.established[from] = addr
+		if ch, ok := hp.waiting[from]; ok {
+			delete(hp.waiting, from)
+			ch <- addr
+		}
+		hp.mu.Unlock()
+	}
+}
+
+// probe sends PUNCH to endpoint until id answers or ctx is done, returning
+// the address id answered from
+func (hp *HolePuncher) probe(ctx context.Context, id, endpoint string) (*net.UDPAddr, error) {
+	addr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %v", endpoint, err)
+	}
+
+	hp.mu.Lock()
+	if existing := hp.established[id]; existing != nil {
+		hp.mu.Unlock()
+		return existing, nil
+	}
+	done := make(chan *net.UDPAddr, 1)
+	hp.waiting[id] = done
+	hp.mu.Unlock()
+	defer func() {
+		hp.mu.Lock()
+		if hp.waiting[id] == done {
+			delete(hp.waiting, id)
+		}
+		hp.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(punchInterval)
+	defer ticker.Stop()
+	for {
+		hp.conn.WriteToUDP([]byte(punchProbe+hp.localID), addr)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no reply from %s at %s: %v", id, endpoint, ctx.Err())
+		case answered := <-done:
+			return answered, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Punch opens a direct UDP path to the node to, introduced by coordinator,
+// which both sides must be known to. It returns the address to reaches
+// this node from.
+func (hp *HolePuncher) Punch(ctx context.Context, coordinator *models.Node, to string) (*net.UDPAddr, error) {
+	var reply PunchReply
+	req := PunchRequest{From: hp.localID, To: to, Endpoint: hp.LocalEndpoint()}
+	if err := rpcPostWithHeader(ctx, peerAddr(coordinator), "/punch", nil, req, &reply); err != nil {
+		return nil, fmt.Errorf("coordinator %s could not introduce %s: %v", coordinator.ID, to, err)
+	}
+
+	ctx, cancel := withRPCTimeout(ctx)
+	defer cancel()
+	return hp.probe(ctx, to, reply.Endpoint)
+}
+
+// PunchHandler handles /punch on the coordinator: it passes the caller's
+// endpoint on to the target, which starts probing it, and answers with the
+// target's endpoint. A target that does not know its public IP gets the
+// one its relay connection comes from, or else its contact's IP.
+func PunchHandler(w http.ResponseWriter, r *http.Request, node *models.Node, routingTable *models.RoutingTable, relay *Relay) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := decodePunchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if validators.ValidateID(req.To, validators.HexadecimalValidator) != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	closest := FindClosestNodes(routingTable, req.To, node.ID, 0)
+	if len(closest) == 0 || closest[0].ID != req.To {
+		http.Error(w, "Target is not a known contact", http.StatusNotFound)
+		return
+	}
+
+	var reply PunchReply
+	notify := PunchRequest{From: req.From, To: req.To, Endpoint: req.Endpoint}
+	if err := rpcPostWithHeader(r.Context(), peerAddr(closest[0]), "/punch_notify", nil, notify, &reply); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reach target: %v", err), http.StatusBadGateway)
+		return
+	}
+	ip := closest[0].IP
+	if relayed := relay.RemoteIP(req.To); relayed != "" {
+		ip = relayed
+	}
+	reply.Endpoint = publicEndpoint(reply.Endpoint, ip)
+	fmt.Printf("Introduced %s at %s to %s at %s\n", req.From, req.Endpoint, req.To, reply.Endpoint)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// PunchNotifyHandler handles /punch_notify on the target of a punch: it
+// starts probing the initiator's endpoint and answers with its own, leaving
+// the IP unspecified for the coordinator to fill in when the socket is not
+// bound to one
+func PunchNotifyHandler(w http.ResponseWriter, r *http.Request, node *models.Node, puncher *HolePuncher) {
+	if puncher == nil {
+		http.Error(w, ErrHolePunchingDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	var req PunchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if req.To != node.ID || validators.ValidateID(req.From, validators.HexadecimalValidator) != nil {
+		http.Error(w, "Invalid punch request", http.StatusBadRequest)
+		return
+	}
+
+	// Probe in the background, the initiator starts once we answer
+	go func() {
+		ctx, cancel := withRPCTimeout(context.Background())
+		defer cancel()
+		if _, err := puncher.probe(ctx, req.From, req.Endpoint); err != nil {
+			log.Printf("Hole punch from %s failed: %v", req.From, err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PunchReply{Endpoint: puncher.LocalEndpoint()})
+}
+
+// decodePunchRequest decodes a /punch body, filling in the endpoint's IP
+// from the connection when the caller left it unspecified
+func decodePunchRequest(r *http.Request) (PunchRequest, error) {
+	var req PunchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, errors.New("Invalid JSON format")
+	}
+	if validators.ValidateID(req.From, validators.HexadecimalValidator) != nil {
+		return req, errors.New("Invalid sender ID")
+	}
+
+	host, port, err := net.SplitHostPort(req.Endpoint)
+	if err != nil {
+		return req, fmt.Errorf("Invalid endpoint: %q", req.Endpoint)
+	}
+	if ip := net.ParseIP(host); host == "" || ip == nil || ip.IsUnspecified() {
+		remote, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return req, errors.New("Failed to extract IP address")
+		}
+		req.Endpoint = net.JoinHostPort(remote, port)
+	}
+	return req, nil
+}
+
+// publicEndpoint replaces a missing or unspecified IP in endpoint with ip
+func publicEndpoint(endpoint, ip string) string {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if parsed := net.ParseIP(host); parsed == nil || parsed.IsUnspecified() {
+		return net.JoinHostPort(ip, port)
+	}
+	return endpoint
+}
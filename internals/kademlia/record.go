@@ -0,0 +1,89 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// RecordHeader carries the sender's signed node record on PING requests
+const RecordHeader = "X-Kademlia-Record"
+
+// GenerateRecordKey generates a key for signing node records, drawn from
+// the seeded sequence in deterministic mode
+func GenerateRecordKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(randReader())
+	return key, err
+}
+
+// SignNodeRecord signs a record for node advertising endpoints and
+// capabilities and attaches it to node. Each call bumps the sequence number
+// so peers replace the previous record.
+func SignNodeRecord(node *models.Node, key ed25519.PrivateKey, endpoints, capabilities []string) error {
+	seq := uint64(1)
+	if node.Record != nil {
+		seq = node.Record.Seq + 1
+	}
+	record := &models.NodeRecord{
+		Seq:          seq,
+		ID:           node.ID,
+		Endpoints:    endpoints,
+		Capabilities: capabilities,
+	}
+	if err := record.Sign(key); err != nil {
+		return err
+	}
+	node.Record = record
+	return nil
+}
+
+// EncodeRecordHeader encodes a record for RecordHeader
+func EncodeRecordHeader(record *models.NodeRecord) (string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeRecordHeader decodes and verifies a record sent in RecordHeader
+// by the node nodeID
+func DecodeRecordHeader(value, nodeID string) (*models.NodeRecord, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidRecord, err)
+	}
+	var record models.NodeRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidRecord, err)
+	}
+	if err := verifyRecordFor(&record, nodeID); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// verifyRecordFor checks that record is validly signed and describes nodeID
+func verifyRecordFor(record *models.NodeRecord, nodeID string) error {
+	if record.ID != nodeID {
+		return fmt.Errorf("%w: record is for node %s, not %s", models.ErrInvalidRecord, record.ID, nodeID)
+	}
+	return record.Verify()
+}
+
+// updateRecord attaches record to the routing table contact with the same
+// ID if it supersedes the record the contact already has
+func updateRecord(routingTable *models.RoutingTable, record *models.NodeRecord) {
+	routingTable.Lock()
+	defer routingTable.Unlock()
+	for _, bucket := range routingTable.Buckets {
+		for _, n := range bucket.Nodes {
+			if n.ID == record.ID && record.Supersedes(n.Record) {
+				n.Record = record
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// BucketRefresher periodically looks up a random ID in the range of every
+// stale bucket and adds the contacts found, so buckets keep filling even in
+// parts of the ID space the node's own traffic never touches. A bucket is
+// stale when no contact in its range was seen or looked up for an interval.
+type BucketRefresher struct {
+	routingTable *models.RoutingTable
+	localID      string
+	interval     time.Duration
+}
+
+// NewBucketRefresher creates a refresher for the routing table of localID
+func NewBucketRefresher(routingTable *models.RoutingTable, localID string, interval time.Duration) *BucketRefresher {
+	return &BucketRefresher{routingTable: routingTable, localID: localID, interval: interval}
+}
+
+// Refresh runs one lookup per non-empty stale bucket and returns the
+// number of contacts added to the routing table
+func (br *BucketRefresher) Refresh(ctx context.Context) int {
+	var targets []string
+	now := clock.Now()
+	known := make(map[string]bool)
+	br.routingTable.RLock()
+	for i, bucket := range br.routingTable.Buckets {
+		if len(bucket.Nodes) > 0 && now.Sub(bucket.LastUpdated) >= br.interval {
+			targets = append(targets, RandomIDInBucket(br.localID, i))
+		}
+		for _, n := range bucket.Nodes {
+			known[n.ID] = true
+		}
+	}
+	br.routingTable.RUnlock()
+
+	added := 0
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		for _, n := range IterativeFindNode(ctx, br.routingTable, br.localID, target) {
+			if n.ID == br.localID || known[n.ID] {
+				continue
+			}
+			known[n.ID] = true
+			AddNodeToRoutingTable(br.routingTable, n, br.localID)
+			added++
+		}
+	}
+	if ctx.Err() != nil {
+		log.Printf("Bucket refresh interrupted: %v", ctx.Err())
+	}
+	return added
+}
+
+// RandomIDInBucket returns a random ID whose XOR distance to localID falls
+// in bucket index, i.e. has bit length index+1
+func RandomIDInBucket(localID string, index int) string {
+	low, _ := rand.Int(randReader(), new(big.Int).Lsh(big.NewInt(1), uint(index)))
+	distance := new(big.Int).SetBit(low, index, 1)
+
+	local, _ := new(big.Int).SetString(localID, 16)
+	if local == nil {
+		local = new(big.Int)
+	}
+	return fmt.Sprintf("%0*x", len(localID), new(big.Int).Xor(local, distance))
+}
@@ -0,0 +1,451 @@
+package kademlia
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/network"
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// RelayProtocol is the Upgrade token of a relay registration. After the
+// relay answers 101 Switching Protocols, the connection carries plain
+// HTTP/1.1 in reverse: the relay sends requests and the registered peer
+// answers them.
+const RelayProtocol = "kademlia-relay"
+
+// RelayProofHeader carries a registering peer's proof that it holds the
+// key of the record sent in RecordHeader: "<unix time>.<hex signature>",
+// the signature covering the relay's address, the peer's ID and the time
+const RelayProofHeader = "X-Kademlia-Relay-Proof"
+
+// relayProofMaxAge bounds how far the time in a registration proof may be
+// from the relay's clock, so a captured registration is soon useless
+const relayProofMaxAge = time.Minute
+
+// DefaultMaxRelayPeers caps the peers registered with a relay at once
+const DefaultMaxRelayPeers = 1024
+
+var (
+	// errRelayTaken is returned when a live registration for the ID was
+	// made with another record key
+	errRelayTaken = errors.New("ID is registered by another key")
+	// errRelayFull is returned when the relay holds MaxPeers registrations
+	errRelayFull = errors.New("relay is full")
+)
+
+// Relay forwards RPCs to peers behind NAT. Each peer keeps one connection
+// to the relay open, registered through /relay/register, and advertises
+// the relay's address in its contact's Relay field. Other nodes then send
+// its RPCs to /relay/<id>/<rpc> on the relay, which passes them down the
+// peer's connection one at a time.
+type Relay struct {
+	MaxPeers int // Registrations held at once, 0 for no limit
+
+	mu    sync.Mutex
+	peers map[string]*relayConn
+}
+
+type relayConn struct {
+	mu     sync.Mutex // Serializes requests, the connection carries one at a time
+	conn   net.Conn
+	reader *bufio.Reader
+	key    string // Hex public key of the record the peer registered with
+}
+
+// NewRelay creates a relay with no registered peers, holding at most
+// DefaultMaxRelayPeers
+func NewRelay() *Relay {
+	return &Relay{MaxPeers: DefaultMaxRelayPeers, peers: make(map[string]*relayConn)}
+}
+
+// Registered reports whether the peer id currently has a connection to
+// the relay
+func (rl *Relay) Registered(id string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.peers[id] != nil
+}
+
+// RemoteIP returns the IP the connection of peer id comes from, the
+// public address of its NAT, or "" if id is not registered
+func (rl *Relay) RemoteIP(id string) string {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rc := rl.peers[id]
+	if rc == nil {
+		return ""
+	}
+	host, _, _ := net.SplitHostPort(rc.conn.RemoteAddr().String())
+	return host
+}
+
+// Close drops every registered connection
+func (rl *Relay) Close() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for id, rc := range rl.peers {
+		rc.conn.Close()
+		delete(rl.peers, id)
+	}
+}
+
+// admit reports whether a peer registering id with the record key key
+// would be accepted: a live registration of id is only replaced by one
+// with the same key, and a new ID needs a free slot
+func (rl *Relay) admit(id, key string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.admitLocked(id, key)
+}
+
+func (rl *Relay) admitLocked(id, key string) error {
+	if old := rl.peers[id]; old != nil {
+		if old.key != key {
+			return errRelayTaken
+		}
+		return nil
+	}
+	if rl.MaxPeers > 0 && len(rl.peers) >= rl.MaxPeers {
+		return errRelayFull
+	}
+	return nil
+}
+
+// register replaces any registration of id with rc, or closes rc if it is
+// no longer admitted
+func (rl *Relay) register(id string, rc *relayConn) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if err := rl.admitLocked(id, rc.key); err != nil {
+		rc.conn.Close()
+		return err
+	}
+	if old := rl.peers[id]; old != nil {
+		old.conn.Close()
+	}
+	rl.peers[id] = rc
+	return nil
+}
+
+func (rl *Relay) unregister(id string, rc *relayConn) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.peers[id] == rc {
+		delete(rl.peers, id)
+	}
+	rc.conn.Close()
+}
+
+// forward sends r down the connection of peer id with the /relay/<id>
+// prefix stripped from its path and copies the reply to w
+func (rl *Relay) forward(w http.ResponseWriter, r *http.Request, id, path string) {
+	rl.mu.Lock()
+	rc := rl.peers[id]
+	rl.mu.Unlock()
+	if rc == nil {
+		http.Error(w, "Peer is not registered with this relay", http.StatusNotFound)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.URL.Path = path
+	out.URL.RawPath = ""
+	out.RequestURI = ""
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.conn.SetDeadline(time.Now().Add(constants.GetRPCTimeout()))
+	defer rc.conn.SetDeadline(time.Time{})
+	if err := out.Write(rc.conn); err != nil {
+		rl.unregister(id, rc)
+		http.Error(w, fmt.Sprintf("Failed to forward to peer: %v", err), http.StatusBadGateway)
+		return
+	}
+	resp, err := http.ReadResponse(rc.reader, out)
+	if err != nil {
+		rl.unregister(id, rc)
+		http.Error(w, fmt.Sprintf("Failed to read reply from peer: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		rl.unregister(id, rc)
+	}
+}
+
+// relayProofBytes returns the bytes a peer signs to register id with the
+// relay at host at unix time at
+func relayProofBytes(host, id string, at int64) []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%s\n%d", RelayProtocol, host, id, at))
+}
+
+// signRelayProof returns the RelayProofHeader value registering id with
+// the relay at host, signed with key
+func signRelayProof(key ed25519.PrivateKey, host, id string) string {
+	at := clock.Now().Unix()
+	return fmt.Sprintf("%d.%s", at, hex.EncodeToString(ed25519.Sign(key, relayProofBytes(host, id, at))))
+}
+
+// verifyRelayProof checks that proof registers record's node with the
+// relay at host, recently and signed by the record's key
+func verifyRelayProof(record *models.NodeRecord, host, proof string) error {
+	unix, sig, ok := strings.Cut(proof, ".")
+	at, err := strconv.ParseInt(unix, 10, 64)
+	if !ok || err != nil {
+		return fmt.Errorf("malformed %s", RelayProofHeader)
+	}
+	if age := clock.Now().Sub(time.Unix(at, 0)); age > relayProofMaxAge || age < -relayProofMaxAge {
+		return fmt.Errorf("%s is stale", RelayProofHeader)
+	}
+	pub, _ := hex.DecodeString(record.PublicKey)
+	signature, err := hex.DecodeString(sig)
+	if err != nil || len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, relayProofBytes(host, record.ID, at), signature) {
+		return fmt.Errorf("%s is not signed by the record's key", RelayProofHeader)
+	}
+	return nil
+}
+
+// RelayRegisterHandler handles /relay/register, taking over the connection
+// of a peer behind NAT so RPCs can be forwarded to it. The peer proves its
+// ID with a signed record and a fresh signature by the record's key; a
+// live registration is only replaced by the same key.
+func RelayRegisterHandler(w http.ResponseWriter, r *http.Request, node *models.Node, relay *Relay) {
+	if !node.Supports(models.FlagRelay) {
+		http.Error(w, "This node is not a relay", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), RelayProtocol) {
+		http.Error(w, fmt.Sprintf("Expected Upgrade: %s", RelayProtocol), http.StatusUpgradeRequired)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if err := validators.ValidateID(id, validators.HexadecimalValidator); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid ID format: %v", err), http.StatusBadRequest)
+		return
+	}
+	record, err := DecodeRecordHeader(r.Header.Get(RecordHeader), id)
+	if err == nil {
+		err = verifyRelayProof(record, r.Host, r.Header.Get(RelayProofHeader))
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Registration not proven: %v", err), http.StatusUnauthorized)
+		return
+	}
+	if err := relay.admit(id, record.PublicKey); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, errRelayFull) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, fmt.Sprintf("Registration refused: %v", err), status)
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "Connection cannot be relayed", http.StatusInternalServerError)
+		return
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: " + RelayProtocol + "\r\nConnection: Upgrade\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	if err := relay.register(id, &relayConn{conn: conn, reader: rw.Reader, key: record.PublicKey}); err != nil {
+		return
+	}
+	fmt.Printf("Relaying RPCs for %s from %s\n", id, r.RemoteAddr)
+}
+
+// RelayForwardHandler handles /relay/<id>/<rpc>, forwarding the RPC to
+// the registered peer id
+func RelayForwardHandler(w http.ResponseWriter, r *http.Request, node *models.Node, relay *Relay) {
+	if !node.Supports(models.FlagRelay) {
+		http.Error(w, "This node is not a relay", http.StatusForbidden)
+		return
+	}
+	id, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/relay/"), "/")
+	if !ok || validators.ValidateID(id, validators.HexadecimalValidator) != nil {
+		http.Error(w, "Expected /relay/<id>/<rpc>", http.StatusBadRequest)
+		return
+	}
+	relay.forward(w, r, id, "/"+path)
+}
+
+// RegisterWithRelay connects to the relay at relayAddr and registers as
+// the node of record, proving it with key, then serves the RPCs the relay
+// forwards with handler until the connection breaks or ctx is cancelled.
+// The returned channel is closed when serving stops.
+func RegisterWithRelay(ctx context.Context, relayAddr string, record *models.NodeRecord, key ed25519.PrivateKey, handler http.Handler) (<-chan struct{}, error) {
+	encoded, err := EncodeRecordHeader(record)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := withRPCTimeout(ctx)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(dialCtx, "tcp", relayAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach relay %s: %v", relayAddr, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/relay/register?id=%s", relayAddr, record.ID), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", RelayProtocol)
+	req.Header.Set(RecordHeader, encoded)
+	req.Header.Set(RelayProofHeader, signRelayProof(key, req.Host, record.ID))
+	if token := network.AuthToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	conn.SetDeadline(time.Now().Add(constants.GetRPCTimeout()))
+	reader := bufio.NewReader(conn)
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to register with relay %s: %v", relayAddr, err)
+	}
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to register with relay %s: %v", relayAddr, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("relay %s refused registration with status %d", relayAddr, resp.StatusCode)
+	}
+	conn.SetDeadline(time.Time{})
+
+	listener := newSingleConnListener(&bufferedConn{Conn: conn, reader: reader})
+	server := &http.Server{Handler: handler}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Serve(listener)
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Close()
+		case <-listener.closed:
+		}
+	}()
+	return done, nil
+}
+
+// ServeViaRelay keeps the node of record registered with the relay at
+// relayAddr until ctx is cancelled, reconnecting with exponential backoff
+// whenever the connection breaks
+func ServeViaRelay(ctx context.Context, relayAddr string, record *models.NodeRecord, key ed25519.PrivateKey, handler http.Handler) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		done, err := RegisterWithRelay(ctx, relayAddr, record, key, handler)
+		if err != nil {
+			log.Printf("Relay registration failed, retrying in %v: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, time.Minute)
+			continue
+		}
+		backoff = time.Second
+		<-done
+	}
+}
+
+// bufferedConn reads through the reader that consumed the registration
+// reply, so requests the relay sent right behind it are not lost
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// singleConnListener hands out one connection and then blocks until that
+// connection is closed, so http.Server.Serve returns when it breaks
+type singleConnListener struct {
+	conn   chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+	addr   net.Addr
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	l := &singleConnListener{conn: make(chan net.Conn, 1), closed: make(chan struct{}), addr: conn.LocalAddr()}
+	l.conn <- &closeNotifyConn{Conn: conn, listener: l}
+	return l
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conn:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *singleConnListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.addr
+}
+
+// closeNotifyConn closes its listener along with itself
+type closeNotifyConn struct {
+	net.Conn
+	listener *singleConnListener
+}
+
+func (c *closeNotifyConn) Close() error {
+	c.listener.Close()
+	return c.Conn.Close()
+}
+
+// peerAddr returns the base address of RPCs to peer: its own <ip>:<port>,
+// or for a peer behind a relay the relay's address followed by the
+// forwarding path, which the RPC helpers prefix to every RPC path
+func peerAddr(peer *models.Node) string {
+	if peer.Relay != "" {
+		return peer.Relay + "/relay/" + peer.ID
+	}
+	return fmt.Sprintf("%s:%d", peer.IP, peer.Port)
+}
@@ -0,0 +1,273 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// ErrNotClosest is returned by SendStoreRequest when the peer declined the
+// value because it is not among the k nodes closest to the key
+var ErrNotClosest = errors.New("peer is not among the closest nodes")
+
+// MaxValueSize bounds the value of a STORE, whichever transport carries it
+const MaxValueSize = 1 << 20
+
+// StoreRequest is the body of a /store request. With Replicate set the
+// receiving node stores the value on the k nodes closest to the key,
+// itself included only if it is one of them, instead of storing locally.
+type StoreRequest struct {
+	Key            string                 `json:"key" validate:"required,id"`
+	Value          string                 `json:"value" validate:"required,max=1048576"` // Up to MaxValueSize bytes, once decoded
+	Encoding       string                 `json:"encoding,omitempty"`                    // models.ValueEncodingBase64 if Value is the base64 of a binary value
+	Publisher      string                 `json:"publisher,omitempty"`
+	Policy         models.OverwritePolicy `json:"policy,omitempty"`
+	Type           models.RecordType      `json:"type,omitempty"` // Merges Value, a models.CRDTRecord, into the stored record instead of replacing it
+	IdempotencyKey string                 `json:"idempotency_key,omitempty"`
+	Replicate      bool                   `json:"replicate,omitempty"`
+	Hops           int                    `json:"hops,omitempty" validate:"min=0"` // STORE RPCs the value travelled before this one
+	Token          string                 `json:"token,omitempty"`                 // Write token issued by the receiver to the sender's address
+
+	// TTL and Republish override, in seconds, how long the record lives and
+	// how often its publisher stores it again. Each node clamps them to its
+	// models.RecordIntervals; 0 keeps the node's default.
+	TTL       int `json:"ttl,omitempty" validate:"min=0"`
+	Republish int `json:"republish,omitempty" validate:"min=0"`
+
+	// PublishedAt marks a backup republish: when the publisher last stored
+	// the value, kept as its store time so it expires with the original
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+
+	// Requester, the node replicating the write, Namespace and
+	// NamespaceToken are sent as headers
+	Requester      string `json:"-"`
+	Namespace      string `json:"-"`
+	NamespaceToken string `json:"-"`
+}
+
+// ReplicaAck reports whether one replica stored the value
+type ReplicaAck struct {
+	ID     string `json:"id"`
+	IP     string `json:"ip"`
+	Port   int    `json:"port"`
+	Stored bool   `json:"stored"`
+	Error  string `json:"error,omitempty"`
+	Queued bool   `json:"queued,omitempty"` // Not stored yet, but queued for when the peer returns
+}
+
+// StoreAck is the acknowledgement of a STORE. ReplicationFactor is the
+// number of replicas that stored the value and Failed the number that did
+// not, so a client can retry when too few replicas acknowledged.
+type StoreAck struct {
+	Key               string       `json:"key"`
+	Replicas          []ReplicaAck `json:"replicas"`
+	Failed            int          `json:"failed"`
+	Queued            int          `json:"queued,omitempty"` // Of the failed replicas, those queued for when the peer returns
+	ReplicationFactor int          `json:"replication_factor"`
+}
+
+// ReplicaSpares is how many storage nodes beyond the k closest a STORE
+// looks up, to stand in for close peers that keep dropping out
+const ReplicaSpares = 2
+
+// ReplicaChurnPenalty is how many places further from the key a replica
+// candidate that dropped just now is treated as, so a peer that churns
+// gives way to a spare
+const ReplicaChurnPenalty = 3
+
+// IterativeStore looks up the k storage nodes closest to req.Key and
+// stores the value on each of them in parallel, writing to storage
+// directly when self is among them. Peers that recently dropped out are
+// passed over for the next closest, as chosen by pickReplicas. Writes that
+// fail transiently are retried under the context's retry policy, and those
+// still failing are queued on the context's ForwardQueue, if any, for when
+// the peer returns. Replicas are listed in order of distance to the key.
+func IterativeStore(ctx context.Context, routingTable *models.RoutingTable, self *models.Node, storage *models.KeyValueStore, req StoreRequest) StoreAck {
+	req.Replicate = false
+	req.Requester = self.ID
+	k := routingTable.BucketSize()
+	candidates := IterativeFindNodeWithOptions(ctx, routingTable, self.ID, req.Key, LookupOptions{K: k + ReplicaSpares, Require: models.FlagStorage})
+	closest := pickReplicas(candidates, k, self.ID, routingTable.Reputation)
+
+	// A retried write whose first attempt was stored but not acknowledged
+	// would be refused by reject_existing, so such writes are retried only
+	// when an idempotency key makes the retry recognisable
+	policy := retry.For(ctx)
+	if req.Policy == models.OverwriteRejectExists && req.IdempotencyKey == "" {
+		policy.Attempts = 1
+	}
+
+	ack := StoreAck{Key: req.Key, Replicas: make([]ReplicaAck, len(closest))}
+	var wg sync.WaitGroup
+	for i, peer := range closest {
+		wg.Add(1)
+		go func(i int, peer *models.Node) {
+			defer wg.Done()
+
+			var err error
+			if peer.ID == self.ID {
+				err = storeLocal(storage, req)
+			} else {
+				err = retry.Do(ctx, policy, func(ctx context.Context) error {
+					return SendStoreRequest(ctx, peer, req)
+				})
+			}
+			ack.Replicas[i] = newReplicaAck(peer, err)
+			if err != nil && peer.ID != self.ID && !undeliverable(err) {
+				ack.Replicas[i].Queued = forwardQueueFrom(ctx).Enqueue(peer, req)
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+
+	for _, replica := range ack.Replicas {
+		if replica.Stored {
+			ack.ReplicationFactor++
+		} else {
+			ack.Failed++
+		}
+		if replica.Queued {
+			ack.Queued++
+		}
+	}
+	return ack
+}
+
+// storeLocal writes req to storage under its namespace
+func storeLocal(storage *models.KeyValueStore, req StoreRequest) error {
+	if req.PublishedAt != nil {
+		return storeBackup(storage, models.NamespacedKey(req.Namespace, req.Key), req)
+	}
+	if req.Type != models.RecordValue {
+		err := storeTyped(storage, models.NamespacedKey(req.Namespace, req.Key), req)
+		if err == nil {
+			setIntervals(storage, req)
+		}
+		return err
+	}
+	policy := req.Policy
+	if policy == "" {
+		policy = models.OverwriteAlways
+	}
+	idempotencyKey := req.IdempotencyKey
+	if idempotencyKey != "" {
+		idempotencyKey = models.NamespacedKey(req.Namespace, idempotencyKey)
+	}
+	_, err := storage.PutWithHops(models.NamespacedKey(req.Namespace, req.Key), req.Value, req.Publisher, req.Hops, policy, idempotencyKey)
+	if err == nil {
+		setIntervals(storage, req)
+	}
+	return err
+}
+
+// setIntervals applies the expire and republish intervals req asks for to
+// the value it stored
+func setIntervals(storage *models.KeyValueStore, req StoreRequest) {
+	if req.TTL > 0 || req.Republish > 0 {
+		storage.SetIntervals(models.NamespacedKey(req.Namespace, req.Key), models.Seconds(req.TTL), models.Seconds(req.Republish))
+	}
+}
+
+func newReplicaAck(peer *models.Node, err error) ReplicaAck {
+	ack := ReplicaAck{ID: peer.ID, IP: peer.IP, Port: peer.Port, Stored: err == nil}
+	if err != nil {
+		ack.Error = err.Error()
+	}
+	return ack
+}
+
+// pickReplicas chooses k of candidates, given closest first, ranking each
+// ReplicaChurnPenalty places further for every recent drop in reputation,
+// and returns them closest first. self never churns.
+func pickReplicas(candidates []*models.Node, k int, self string, reputation *models.PeerReputation) []*models.Node {
+	if len(candidates) <= k {
+		return candidates
+	}
+	now := clock.Now()
+	weights := make(map[string]float64, len(candidates))
+	for rank, n := range candidates {
+		weights[n.ID] = float64(rank)
+		if n.ID != self {
+			weights[n.ID] += ReplicaChurnPenalty * reputation.Score(n.ID).Churn(now)
+		}
+	}
+	picked := append([]*models.Node(nil), candidates...)
+	sort.SliceStable(picked, func(i, j int) bool {
+		return weights[picked[i].ID] < weights[picked[j].ID]
+	})
+	picked = picked[:k]
+
+	// Restore distance order
+	rank := make(map[string]int, len(candidates))
+	for i, n := range candidates {
+		rank[n.ID] = i
+	}
+	sort.Slice(picked, func(i, j int) bool { return rank[picked[i].ID] < rank[picked[j].ID] })
+	return picked
+}
+
+// SendStoreRequest sends a STORE RPC to peer. Only a 201 Created reply
+// counts as stored; a peer answering with closer nodes yields
+// ErrNotClosest. Unless req carries a token, the write token peer last
+// issued is presented, and a fresh one fetched if peer refuses it.
+func SendStoreRequest(ctx context.Context, peer *models.Node, req StoreRequest) error {
+	return withWriteToken(ctx, peer, req.Key, req.Token, func(token string) error {
+		return sendStore(ctx, peerAddr(peer), req, token)
+	})
+}
+
+// sendStore sends req to addr presenting token, returning an error
+// wrapping ErrInvalidWriteToken if addr refused the token
+func sendStore(ctx context.Context, addr string, req StoreRequest, token string) error {
+	ctx, cancel := withRPCTimeout(ctx)
+	defer cancel()
+
+	req.Token = token
+	if req.Encoding == "" {
+		req.Value, req.Encoding = models.EncodeValue(req.Value)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/store", addr), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header = requesterHeader(req.Requester, req.Namespace, req.NamespaceToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := network.Client().Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusOK:
+		return ErrNotClosest
+	}
+
+	var storeErr ErrorReply
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	json.Unmarshal(data, &storeErr)
+	statusErr := newStatusError(resp, addr, storeErr.Message)
+	if storeErr.Error == writeTokenErrorCode {
+		return fmt.Errorf("%w: %v", ErrInvalidWriteToken, statusErr)
+	}
+	return statusErr
+}
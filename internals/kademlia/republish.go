@@ -0,0 +1,138 @@
+package kademlia
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// BackupRepublisher takes over republishing the records whose publisher
+// stopped refreshing them. Publishers are expected to store their records
+// again every interval; a record not refreshed for one and a half
+// intervals is republished every interval by the closest node holding it,
+// so replicas lost to churn are replaced until the record expires.
+// Backup republishes carry the time the publisher last stored the value,
+// which the replicas keep as the value's store time, so the record still
+// expires a TTL after its publisher's last refresh. Records whose STORE set
+// their own republish interval are held to it instead.
+type BackupRepublisher struct {
+	self         *models.Node
+	routingTable *models.RoutingTable
+	storage      *models.KeyValueStore
+	interval     time.Duration
+}
+
+// NewBackupRepublisher creates a backup republisher for the node self
+// expecting publishers to refresh their records every interval
+func NewBackupRepublisher(self *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, interval time.Duration) *BackupRepublisher {
+	return &BackupRepublisher{self: self, routingTable: routingTable, storage: storage, interval: interval}
+}
+
+// period returns the time between checks: the interval, or the shortest
+// republish interval a record may ask for if that is shorter
+func (b *BackupRepublisher) period() time.Duration {
+	if least := b.storage.Intervals.MinRepublish; least > 0 && least < b.interval {
+		return least
+	}
+	return b.interval
+}
+
+// Republish republishes every record whose publisher missed its refresh
+// and that no node closer to the key holds, and returns the number
+// republished. Records this node published are left to it.
+func (b *BackupRepublisher) Republish(ctx context.Context) int {
+	now := clock.Now()
+	republished := 0
+	var candidates []models.StoredRecord
+	b.storage.Scan(func(rec models.StoredRecord) bool {
+		interval := b.interval
+		if rec.Republish > 0 {
+			interval = models.Seconds(rec.Republish)
+		}
+		if rec.Publisher != b.self.ID && rec.StoredAt.Before(now.Add(-interval-interval/2)) {
+			candidates = append(candidates, rec)
+		}
+		return true
+	})
+	for _, rec := range candidates {
+		namespace, key := models.SplitNamespacedKey(rec.Key)
+		if !b.closestHolder(ctx, namespace, key) {
+			continue
+		}
+
+		publishedAt := rec.StoredAt
+		ack := IterativeStore(ctx, b.routingTable, b.self, b.storage, StoreRequest{
+			Key:         key,
+			Value:       rec.Value,
+			Publisher:   rec.Publisher,
+			Hops:        rec.Hops,
+			PublishedAt: &publishedAt,
+			TTL:         rec.TTL,
+			Republish:   rec.Republish,
+			Namespace:   namespace,
+		})
+		if ack.ReplicationFactor == 0 {
+			log.Printf("Backup republish of %s reached no replica", rec.Key)
+			continue
+		}
+		republished++
+	}
+	return republished
+}
+
+// closestHolder reports whether this node is among the k storage nodes
+// closest to key and none of those closer holds its value
+func (b *BackupRepublisher) closestHolder(ctx context.Context, namespace, key string) bool {
+	closest := IterativeFindNodeWithOptions(ctx, b.routingTable, b.self.ID, key, LookupOptions{Require: models.FlagStorage})
+	for _, peer := range closest {
+		if peer.ID == b.self.ID {
+			return true
+		}
+		if holds, err := holdsValue(ctx, peer, namespace, key); err == nil && holds {
+			return false
+		}
+	}
+	return false
+}
+
+// holdsValue asks peer with a FIND_VALUE whether it holds key in namespace
+func holdsValue(ctx context.Context, peer *models.Node, namespace, key string) (bool, error) {
+	header := http.Header{}
+	if namespace != "" {
+		header.Set(NamespaceHeader, namespace)
+	}
+	replyHeader, body, err := rpcGetRaw(ctx, peerAddr(peer), "/find_value?key="+key, header)
+	if err != nil {
+		return false, err
+	}
+	contentType := replyHeader.Get("Content-Type")
+	var value string
+	if decodeBody(contentType, body, &value) == nil {
+		return true, nil
+	}
+	// Binary values come base64 encoded in a FindValueReply
+	var reply FindValueReply
+	return decodeBody(contentType, body, &reply) == nil && reply.Encoding != "", nil
+}
+
+// storeBackup writes a backup republish of req to storage under key,
+// dated when the publisher last stored it. A copy already held is kept, as
+// in anti-entropy, a value deleted since is not brought back, and a new
+// key counts against its namespace's quota like any STORE.
+func storeBackup(storage *models.KeyValueStore, key string, req StoreRequest) error {
+	publishedAt, _ := clock.FromPeer(*req.PublishedAt, clock.Now())
+	_, err := storage.RestoreMissing(models.StoredRecord{
+		Key:       key,
+		Value:     req.Value,
+		Publisher: req.Publisher,
+		StoredAt:  publishedAt,
+		Hops:      req.Hops,
+		TTL:       req.TTL,
+		Republish: req.Republish,
+	})
+	return err
+}
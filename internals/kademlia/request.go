@@ -0,0 +1,211 @@
+package kademlia
+
+import (
+	"net/http"
+
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+)
+
+// The requests below are decoded from query parameters by decodeQuery, or
+// built from an /rpc envelope, and checked with validators.Struct, so an
+// RPC is validated the same way whichever transport carries it. See
+// package validators for the tags.
+
+// PingQuery identifies the node sending a GET /ping. A ping without an ID
+// is answered without adding anyone to the routing table.
+type PingQuery struct {
+	ID    string `param:"id" validate:"id"`
+	Port  int    `param:"port" validate:"port,required_with=id"`
+	Token string `param:"token"` // Echoed in the PONG if at most MaxPingTokenLength long
+
+	// Network is the network the pinger belongs to, empty for the default
+	// network
+	Network string `param:"network" validate:"max=64"`
+
+	// Filter asks for a Bloom filter of the keys the node stores
+	Filter bool `param:"filter"`
+}
+
+// NodeInfoRequest asks for a node's NodeInfo, with a Bloom filter of the
+// keys it stores if Filter is set
+type NodeInfoRequest struct {
+	Filter bool `param:"filter"`
+}
+
+// FindNodeRequest asks for the contacts closest to ID
+type FindNodeRequest struct {
+	ID        string `param:"id" validate:"required,id"`
+	Requester string `param:"requester" validate:"id"` // Left out of the reply with the responder
+	Count     int    `param:"count" validate:"min=1"`  // Contacts wanted, capped at k
+}
+
+// FindValueRequest asks for the value of Key, or the contacts closest to
+// it if the responder does not hold it
+type FindValueRequest struct {
+	Key   string `param:"key" validate:"required,id"`
+	Count int    `param:"count" validate:"min=1"` // Contacts wanted on a miss, capped at k
+	Hash  bool   `param:"hash"`                   // Key is an application key to hash with KeyFromString
+	Meta  bool   `param:"meta"`                   // Reply with a FindValueReply carrying the value's provenance
+}
+
+func (req *FindValueRequest) normalize() {
+	if req.Hash && req.Key != "" {
+		req.Key = KeyFromString(req.Key)
+	}
+}
+
+// FindValuesRequest asks for the values of several keys at once, and the
+// contacts closest to each key the responder does not hold. It is the body
+// of a POST /find_values.
+type FindValuesRequest struct {
+	Keys  []string `json:"keys" validate:"required,max=64"`  // Up to MaxFindValuesKeys
+	Count int      `json:"count,omitempty" validate:"min=0"` // Contacts wanted per missed key, capped at k; k if 0
+}
+
+// GetProvidersRequest asks for the providers of Key
+type GetProvidersRequest struct {
+	Key string `param:"key" validate:"required,id"`
+}
+
+// AddProviderRequest announces the node ID at IP and Port as a provider of
+// Key. An empty IP stands for the address the request came from.
+type AddProviderRequest struct {
+	Key  string `json:"key" validate:"required,id"`
+	ID   string `json:"id" validate:"required,id"`
+	Port int    `json:"port" validate:"required,port"`
+}
+
+// PeersRequest asks for a sample of Count peers within XOR distance
+// 2^Radius of Key, or of any peers if Key is empty, chosen by the sampling
+// policy named by Sample or else the node's
+type PeersRequest struct {
+	Key    string `param:"key" validate:"id"`
+	Count  int    `param:"count" validate:"min=1"` // Cut to the node's MaxExchangePeers
+	Radius int    `param:"radius" validate:"min=0,max=160"`
+	Sample string `param:"sample"`
+}
+
+// IterateKeysRequest asks for a page of the records within XOR distance
+// 2^Radius of Target
+type IterateKeysRequest struct {
+	Target string `param:"target" validate:"required,id"`
+	Radius int    `param:"radius" validate:"min=0,max=160"`
+	Limit  int    `param:"limit" validate:"min=1,max=1000"` // Up to MaxIterateLimit
+	Token  string `param:"token"`
+}
+
+// SyncDigestRequest asks for the per-bucket digest of the records within
+// XOR distance 2^Radius of Target, or for the records of one bucket
+type SyncDigestRequest struct {
+	Target string `param:"target" validate:"required,id"`
+	Radius int    `param:"radius" validate:"min=0,max=160"`
+	Bucket string `param:"bucket"`
+}
+
+// PollRequest waits up to Timeout seconds for messages published to Topic
+type PollRequest struct {
+	Topic        string `param:"topic" validate:"required"`
+	SubscriberID string `param:"subscriber_id" validate:"required"`
+	Timeout      int    `param:"timeout" validate:"min=0"`
+}
+
+// WatchRequest registers WatcherID's interest in Key on a node storing
+// it, or with Cancel set drops it. With a Webhook the node POSTs every
+// KeyChange to it. It is the body of a POST /watch.
+type WatchRequest struct {
+	Key       string `json:"key" validate:"required,id"`
+	WatcherID string `json:"watcher_id" validate:"required,max=128"`
+	Webhook   string `json:"webhook,omitempty"`
+	Cancel    bool   `json:"cancel,omitempty"`
+}
+
+// WatchPollRequest waits up to Timeout seconds for Key, watched by
+// WatcherID, to reach a Seq above Since
+type WatchPollRequest struct {
+	Key       string `param:"key" validate:"required,id"`
+	WatcherID string `param:"watcher_id" validate:"required"`
+	Since     int64  `param:"since" validate:"min=0"`
+	Timeout   int    `param:"timeout" validate:"min=0,max=300"`
+}
+
+// ChurnStatsRequest asks for the session history of the peer ID, or a
+// summary over all peers if ID is empty
+type ChurnStatsRequest struct {
+	ID string `param:"id" validate:"id"`
+}
+
+// PeerStoreRequest asks for what is known about the peer ID, or for up to
+// Limit of the most recently seen peers if ID is empty
+type PeerStoreRequest struct {
+	ID    string `param:"id" validate:"id"`
+	Limit int    `param:"limit" validate:"min=1,max=1000"` // Up to MaxIterateLimit
+}
+
+// StoreQuery holds the query parameters of a /store or /find_values,
+// whose request is the StoreRequest or FindValuesRequest body
+type StoreQuery struct {
+	Hash bool `param:"hash"` // Key is an application key to hash with KeyFromString
+}
+
+// AdminKeysRequest asks for a page of the stored keys whose raw key starts
+// with the hex Prefix. Namespace, when the parameter is present even if
+// empty, restricts the listing to one namespace.
+type AdminKeysRequest struct {
+	Prefix    string `param:"prefix" validate:"max=40"`
+	Namespace string `param:"namespace"`
+	Limit     int    `param:"limit" validate:"min=1,max=1000"` // Up to MaxIterateLimit
+	Token     string `param:"token"`
+}
+
+// AdminImportRequest holds the query parameters of an /admin/import,
+// whose body is an export
+type AdminImportRequest struct {
+	Overwrite bool `param:"overwrite"` // Replace keys already stored
+}
+
+// normalizer is implemented by requests that rewrite fields between
+// decoding and validation
+type normalizer interface {
+	normalize()
+}
+
+// decodeQuery fills req, a pointer to one of the request structs holding
+// its defaults, from r's query parameters and validates it. A request that
+// fails is answered with a 400 and false is returned.
+func decodeQuery(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	err := validators.DecodeQuery(r.URL.Query(), req)
+	if err == nil {
+		if n, ok := req.(normalizer); ok {
+			n.normalize()
+		}
+		err = validators.Struct(req)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// validateBody validates req, decoded from a request body, answering a
+// 400 and returning false if it fails
+func validateBody(w http.ResponseWriter, req interface{}) bool {
+	if err := validators.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// AdminKeyspaceRequest holds the query parameters of an /admin/keyspace
+// request
+type AdminKeyspaceRequest struct {
+	Bins   int    `param:"bins" validate:"min=1,max=4096"`
+	Format string `param:"format"` // json or csv
+}
+
+// AdminJobsRequest names the scheduled job an /admin/jobs/pause or
+// /admin/jobs/resume request applies to
+type AdminJobsRequest struct {
+	Job string `param:"job"` // Empty for every job
+}
@@ -0,0 +1,122 @@
+package kademlia
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Headers identifying the node that answered a FIND_NODE. The responder's
+// signed record, if it has one, is sent in RecordHeader.
+const (
+	ResponderIDHeader       = "X-Kademlia-Node-ID"
+	ResponderAddrHeader     = "X-Kademlia-Node-Addr"
+	ResponderTimeHeader     = "X-Kademlia-Timestamp"
+	ResponderFlagsHeader    = "X-Kademlia-Node-Flags" // Decimal models.CapabilityFlags
+	ResponderProtocolHeader = "X-Kademlia-Protocol"
+)
+
+// RequesterIDHeader carries the ID of the node sending a FIND_NODE, which
+// the responder leaves out of its reply along with itself. Callers that
+// cannot set headers may pass the ID in the "requester" query parameter.
+// Nodes send it with the writes they replicate too, which nodes with an
+// allowlist accept only from allowlisted peers.
+const RequesterIDHeader = "X-Kademlia-Requester-ID"
+
+// Responder is the node that answered an RPC, as reported in the response
+// headers
+type Responder struct {
+	ID     string
+	Addr   string    // <ip>:<port> the responder advertises
+	Time   time.Time // Responder's clock when it answered
+	Record *models.NodeRecord
+
+	Flags    models.CapabilityFlags // Services the responder offers, 0 if not sent
+	Protocol int                    // Responder's protocol version, 0 if not sent
+}
+
+// writeResponder sets the headers identifying node as the responder
+func writeResponder(w http.ResponseWriter, node *models.Node) {
+	w.Header().Set(ResponderIDHeader, node.ID)
+	w.Header().Set(ResponderAddrHeader, net.JoinHostPort(node.IP, strconv.Itoa(node.Port)))
+	w.Header().Set(ResponderTimeHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	if node.Flags != 0 {
+		w.Header().Set(ResponderFlagsHeader, strconv.FormatUint(uint64(node.Flags), 10))
+	}
+	if node.Protocol != 0 {
+		w.Header().Set(ResponderProtocolHeader, strconv.Itoa(node.Protocol))
+	}
+	if node.Record != nil {
+		if encoded, err := EncodeRecordHeader(node.Record); err == nil {
+			w.Header().Set(RecordHeader, encoded)
+		}
+	}
+}
+
+// ResponderFrom parses the responder headers, returning nil if the
+// response carries none. A record that does not verify for the responder
+// is an error.
+func ResponderFrom(header http.Header) (*Responder, error) {
+	id := header.Get(ResponderIDHeader)
+	if id == "" {
+		return nil, nil
+	}
+
+	responder := &Responder{ID: id, Addr: header.Get(ResponderAddrHeader)}
+	if raw := header.Get(ResponderTimeHeader); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %v", ResponderTimeHeader, err)
+		}
+		responder.Time = t
+	}
+	if raw := header.Get(ResponderFlagsHeader); raw != "" {
+		flags, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %v", ResponderFlagsHeader, err)
+		}
+		responder.Flags = models.CapabilityFlags(flags)
+	}
+	if raw := header.Get(ResponderProtocolHeader); raw != "" {
+		protocol, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %v", ResponderProtocolHeader, err)
+		}
+		responder.Protocol = protocol
+	}
+	if raw := header.Get(RecordHeader); raw != "" {
+		record, err := DecodeRecordHeader(raw, id)
+		if err != nil {
+			return nil, err
+		}
+		responder.Record = record
+	}
+	return responder, nil
+}
+
+// checkResponder verifies that peer answered a request sent to it and
+// records that it was seen, along with the capabilities it advertised. Responses without responder headers, from
+// nodes predating them, are accepted.
+func checkResponder(peer *models.Node, header http.Header) error {
+	responder, err := ResponderFrom(header)
+	if err != nil {
+		return err
+	}
+	if responder != nil && peer.ID != "" && responder.ID != peer.ID {
+		return fmt.Errorf("expected a reply from %s but %s answered", peer.ID, responder.ID)
+	}
+
+	peer.MarkSeen(clock.Now())
+	if responder != nil && responder.Flags != 0 {
+		peer.Flags, peer.Protocol = responder.Flags, responder.Protocol
+	}
+	if responder != nil && responder.Record != nil && responder.Record.Supersedes(peer.Record) {
+		peer.Record = responder.Record
+	}
+	return nil
+}
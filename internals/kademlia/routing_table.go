@@ -1,10 +1,17 @@
 package kademlia
 
 import (
+	"context"
+	"errors"
 	"math/big"
+	"net"
+	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/Aradhya2708/kademlia/pkg/clock"
 	"github.com/Aradhya2708/kademlia/pkg/constants"
 	"github.com/Aradhya2708/kademlia/pkg/models"
 )
@@ -15,52 +22,418 @@ type NodeDistance struct {
 	Distance *big.Int
 }
 
+// NewRoutingTable creates a routing table using the default k
 func NewRoutingTable(nodeID string) *models.RoutingTable {
+	return NewRoutingTableWithK(nodeID, constants.GetK())
+}
+
+// NewRoutingTableWithK creates a routing table whose buckets hold up to k
+// contacts and whose lookups return up to k contacts
+func NewRoutingTableWithK(nodeID string, k int) *models.RoutingTable {
 	// Create a routing table with buckets for each bit of the node ID
 	buckets := make([]*models.Bucket, len(nodeID)*4) // Assuming hex (4 bits per char) // TODO: Check if this is correct
 
-	k := constants.GetK() // Get the default bucket size (k)
-
 	for i := range buckets {
-		buckets[i] = &models.Bucket{MaxSize: k} // Default bucket size (k)
+		buckets[i] = &models.Bucket{MaxSize: k}
 	}
-	return &models.RoutingTable{Buckets: buckets}
+	reputation := models.NewPeerReputation()
+	return &models.RoutingTable{Buckets: buckets, K: k, Reputation: reputation, Filters: models.NewKeyFilters(KeyFilterMaxAge), Peers: models.NewPeerStore(reputation)}
 }
 
 func AddNodeToRoutingTable(rt *models.RoutingTable, target *models.Node, localID string) {
-	distance := calculateXORDistance(localID, target.ID)
-	bucketIndex := getBucketIndex(distance)
-	bucket := rt.Buckets[bucketIndex]
+	// A private network admits only the peers on its allowlist
+	if target.ID != localID && !rt.Allowlist.Allows(target) {
+		return
+	}
+	bucketIndex := bucketIndexOf(localID, target.ID)
+	if bucketIndex >= len(rt.Buckets) {
+		// An ID longer than ours would index past the last bucket
+		return
+	}
+
+	rt.Lock()
+	events, unconfirmed := addNode(rt, rt.Buckets[bucketIndex], target, localID)
+	rt.Unlock()
+
+	// Emit after releasing the lock so subscribers may read the table
+	for _, e := range events {
+		rt.Events.Emit(e)
+	}
+	if unconfirmed != nil {
+		go confirmMove(rt, unconfirmed, localID)
+	}
+}
+
+// addNode implements AddNodeToRoutingTable for the bucket covering
+// target, returning the events to emit and, if target is a known contact
+// seen at a new address it could not prove, the contact as last seen
+// there; callers must hold rt's lock
+func addNode(rt *models.RoutingTable, bucket *models.Bucket, target *models.Node, localID string) (events []models.Event, unconfirmed *models.Node) {
+	known := contactIn(bucket, target.ID)
+
+	// A host may add only so many new IDs per window, so one machine cannot
+	// flood the table with Sybil identities
+	if target.ID != localID && !rt.Pinned[target.ID] && known == nil && !rt.Peers.AllowInsert(insertSource(target), target.ID) {
+		return nil, nil
+	}
+
+	now := clock.Now()
+	bucket.LastUpdated = now
+
+	// Contacts learned from peers carry LastSeen by the peer's clock, which
+	// must not make them look fresher than contacts this node saw itself
+	if seen, _ := clock.FromPeer(target.SeenAt(), now); !seen.Equal(target.SeenAt()) {
+		target.MarkSeen(seen)
+	}
+
+	// Any message can name a known ID at a new address, so a contact is
+	// only followed there if it signed the move with its key, and then
+	// only as far as the table's diversity limits allow; other moves are
+	// held back until the old address stops answering
+	if known != nil && target.ID != localID && moved(known, target) {
+		if !signedMove(known, target) {
+			unconfirmed = target
+			target = atAddressOf(target, known)
+		} else if !moveAllowed(rt, bucket, known, target) {
+			target = atAddressOf(target, known)
+		}
+	}
+
+	// Hold the peer store's contact for the ID, so every reference to the
+	// peer sees the same address and capabilities
+	target = rt.Peers.Observe(target)
 
 	// Ensure no duplicate entries
 	//TODO: Can Make this more efficient by using a HashMap or Set.
-	for _, n := range bucket.Nodes {
+	for i, n := range bucket.Nodes {
 		if n.ID == target.ID {
-			return
+			// Follow a contact that changed address
+			if target.IP != "" && target.Port != 0 {
+				n.IP, n.Port = target.IP, target.Port
+			}
+			if seen := target.SeenAt(); seen.After(n.SeenAt()) {
+				n.MarkSeen(seen)
+			}
+			// Keep the capabilities the contact advertised most recently
+			if target.Flags != 0 {
+				n.Flags, n.Protocol, n.Relay = target.Flags, target.Protocol, target.Relay
+			}
+			// Move it to the tail, where the most recently seen contacts
+			// live and eviction reaches last
+			bucket.Nodes = append(append(bucket.Nodes[:i:i], bucket.Nodes[i+1:]...), n)
+			return nil, unconfirmed
 		}
 	}
 
 	// TODO: Torrentium, Add a Trust Score.
 
+	// Keep the table diverse, so an attacker on one subnet cannot fill the
+	// buckets and eclipse the node
+	if target.ID != localID && !rt.Pinned[target.ID] && subnetFull(rt, bucket, target) {
+		bucket.SubnetRefused++
+		rt.Peers.Left(target.ID)
+		return nil, nil
+	}
+
 	// Add node if bucket is not full
 	if len(bucket.Nodes) < bucket.MaxSize {
 		bucket.Nodes = append(bucket.Nodes, target)
 	} else {
 		//TODO: Handle full bucket correctly, if the least recently used node is alive then ignore the new node, else evict it.
 
-		bucket.Nodes = bucket.Nodes[1:] // Simplified eviction (FIFO)
+		// Evict a contact whose last RPC failed, else the oldest (FIFO),
+		// skipping pinned contacts
+		i := evictionCandidate(rt, bucket)
+		if i < 0 {
+			rt.Peers.Left(target.ID)
+			return nil, nil
+		}
+		evicted := bucket.Nodes[i]
+		bucket.Nodes = append(bucket.Nodes[:i:i], bucket.Nodes[i+1:]...)
 		bucket.Nodes = append(bucket.Nodes, target)
+		rt.Reputation.Left(evicted.ID)
+		rt.Filters.Forget(evicted.ID)
+		rt.Peers.Left(evicted.ID)
+		events = append(events, models.Event{Type: models.PeerEvicted, Peer: evicted})
+		invalidateLookups(rt, nil, evicted.ID)
+	}
+	invalidateLookups(rt, target, "")
+	if target.ID != localID {
+		rt.Reputation.Joined(target.ID)
+	}
+	return append(events, models.Event{Type: models.PeerAdded, Peer: target}), nil
+}
+
+// contactIn returns the contact bucket holds for id, or nil
+func contactIn(bucket *models.Bucket, id string) *models.Node {
+	for _, n := range bucket.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// moved reports whether target names an address other than known's
+func moved(known, target *models.Node) bool {
+	return target.IP != "" && target.Port != 0 && (target.IP != known.IP || target.Port != known.Port)
+}
+
+// signedMove reports whether target proves its new address: it carries a
+// valid record, signed with the key of the record known holds, that lists
+// the address as an endpoint
+func signedMove(known, target *models.Node) bool {
+	if known.Record == nil || target.Record == nil || target.Record.PublicKey != known.Record.PublicKey {
+		return false
+	}
+	if verifyRecordFor(target.Record, target.ID) != nil {
+		return false
+	}
+	return slices.Contains(target.Record.Endpoints, "http://"+net.JoinHostPort(target.IP, strconv.Itoa(target.Port)))
+}
+
+// moveAllowed reports whether known may move to target's address under
+// the insert and subnet limits a new contact from there would face;
+// callers must hold rt's lock
+func moveAllowed(rt *models.RoutingTable, bucket *models.Bucket, known, target *models.Node) bool {
+	if rt.Pinned[target.ID] || insertSource(target) == insertSource(known) {
+		return true
+	}
+	if !rt.Peers.AllowInsert(insertSource(target), target.ID) {
+		return false
+	}
+	if models.Subnet(insertSource(target)) != models.Subnet(insertSource(known)) && subnetFull(rt, bucket, target) {
+		bucket.SubnetRefused++
+		return false
+	}
+	return true
+}
+
+// atAddressOf returns a copy of target at known's address
+func atAddressOf(target, known *models.Node) *models.Node {
+	kept := *target
+	kept.IP, kept.Port = known.IP, known.Port
+	return &kept
+}
+
+// confirmMove follows a contact to the address it was last seen at, one
+// it could not prove with a signed record, if it no longer answers at its
+// old address and does at the new one, within the table's limits. Both
+// are pinged with rt.Confirm, or over HTTP if the table sets none.
+func confirmMove(rt *models.RoutingTable, seen *models.Node, localID string) {
+	bucketIndex := bucketIndexOf(localID, seen.ID)
+	rt.RLock()
+	known := contactIn(rt.Buckets[bucketIndex], seen.ID)
+	var old models.Node
+	if known != nil {
+		old = models.Node{ID: known.ID, IP: known.IP, Port: known.Port, Relay: known.Relay}
+	}
+	rt.RUnlock()
+	if known == nil {
+		return
+	}
+
+	confirm := rt.Confirm
+	if confirm == nil {
+		confirm = DialBack
+	}
+	ctx := context.Background()
+	if err := confirm(ctx, &old); err == nil || errors.Is(err, errDialBackBusy) {
+		return
+	}
+	if err := confirm(ctx, &models.Node{ID: seen.ID, IP: seen.IP, Port: seen.Port, Relay: seen.Relay}); err != nil {
+		return
+	}
+
+	rt.Lock()
+	defer rt.Unlock()
+	bucket := rt.Buckets[bucketIndex]
+	n := contactIn(bucket, seen.ID)
+	if n == nil || n.IP != old.IP || n.Port != old.Port || !moveAllowed(rt, bucket, n, seen) {
+		return
+	}
+	n.IP, n.Port = seen.IP, seen.Port
+	rt.Peers.Observe(n)
+}
+
+// insertSource returns the host a contact is counted against by the
+// insert and subnet limits: its IP, or its relay's for a node behind NAT
+func insertSource(n *models.Node) string {
+	if n.IP != "" || n.Relay == "" {
+		return n.IP
+	}
+	if host, _, err := net.SplitHostPort(n.Relay); err == nil {
+		return host
+	}
+	return n.Relay
+}
+
+// subnetFull reports whether bucket, or the whole table, already holds as
+// many contacts from target's subnet as rt.SubnetLimit allows
+func subnetFull(rt *models.RoutingTable, bucket *models.Bucket, target *models.Node) bool {
+	limit := rt.SubnetLimit
+	if !limit.Enabled() {
+		return false
+	}
+	subnet := models.Subnet(insertSource(target))
+	if subnet == "" {
+		return false
+	}
+	inBucket, inTable := 0, 0
+	for _, b := range rt.Buckets {
+		for _, n := range b.Nodes {
+			if models.Subnet(insertSource(n)) != subnet {
+				continue
+			}
+			inTable++
+			if b == bucket {
+				inBucket++
+			}
+		}
+	}
+	return (limit.PerBucket > 0 && inBucket >= limit.PerBucket) || (limit.PerTable > 0 && inTable >= limit.PerTable)
+}
+
+// invalidateLookups drops the cached lookups a change of contacts could
+// alter: those added would join, being closer to the target than the
+// farthest contact found or filling a short result, and those listing
+// removed
+func invalidateLookups(rt *models.RoutingTable, added *models.Node, removed string) {
+	if rt.Lookups == nil {
+		return
+	}
+	rt.Lookups.Invalidate(func(e *models.LookupEntry) bool {
+		if removed != "" {
+			return containsID(e.Nodes, removed)
+		}
+		if containsID(e.Nodes, added.ID) || !added.Supports(e.Require) {
+			return false
+		}
+		if len(e.Nodes) < e.K {
+			return true
+		}
+		farthest := e.Nodes[len(e.Nodes)-1]
+		return calculateXORDistance(added.ID, e.Target).Cmp(calculateXORDistance(farthest.ID, e.Target)) < 0
+	})
+}
+
+// evictionCandidate returns the index in bucket of the contact to evict
+// for a new one, -1 if every contact is pinned: the least recently seen
+// contact whose last RPC failed, or else the least recently seen
+func evictionCandidate(rt *models.RoutingTable, bucket *models.Bucket) int {
+	candidate := -1
+	for i, n := range bucket.Nodes {
+		if rt.Pinned[n.ID] {
+			continue
+		}
+		if rt.Peers.Failing(n.ID) {
+			return i
+		}
+		if candidate < 0 {
+			candidate = i
+		}
+	}
+	return candidate
+}
+
+// removeFromRoutingTable drops the contact id, if present
+func removeFromRoutingTable(rt *models.RoutingTable, id string) {
+	rt.Lock()
+	removed := removeNode(rt, id)
+	rt.Unlock()
+
+	// Emit after releasing the lock so subscribers may read the table
+	if removed != nil {
+		rt.Events.Emit(models.Event{Type: models.PeerEvicted, Peer: removed})
+	}
+}
+
+// removeNode implements removeFromRoutingTable, returning the contact
+// removed; callers must hold rt's lock
+func removeNode(rt *models.RoutingTable, id string) *models.Node {
+	for _, bucket := range rt.Buckets {
+		for i, n := range bucket.Nodes {
+			if n.ID == id {
+				bucket.Nodes = append(bucket.Nodes[:i:i], bucket.Nodes[i+1:]...)
+				bucket.LastUpdated = clock.Now()
+				rt.Reputation.Left(n.ID)
+				rt.Filters.Forget(n.ID)
+				rt.Peers.Left(n.ID)
+				invalidateLookups(rt, nil, n.ID)
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// touchBucket marks the bucket covering id as just used
+func touchBucket(rt *models.RoutingTable, localID, id string) {
+	if i := bucketIndexOf(localID, id); i < len(rt.Buckets) {
+		rt.Lock()
+		rt.Buckets[i].LastUpdated = clock.Now()
+		rt.Unlock()
 	}
 }
 
+// containsNode reports whether the contact id is in the routing table
+func containsNode(rt *models.RoutingTable, id string) bool {
+	rt.RLock()
+	defer rt.RUnlock()
+	for _, bucket := range rt.Buckets {
+		for _, n := range bucket.Nodes {
+			if n.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // TODO: Make the rounting table global instead of passing it in each function.
-// FindClosestNodes retrieves the closest nodes to the given queryID.
-func FindClosestNodes(routingTable *models.RoutingTable, queryID, localID string) []*models.Node {
-	// Calculate the XOR distance and collect all nodes.
+// FindClosestNodes retrieves up to count nodes closest to the given
+// queryID, or up to the table's k when count is 0 or less.
+func FindClosestNodes(routingTable *models.RoutingTable, queryID, localID string, count int) []*models.Node {
+	return findClosestNodesExcept(routingTable, queryID, count, nil)
+}
+
+// findClosestNodesExcept is FindClosestNodes leaving out the contacts in
+// exclude, so up to count others are returned in their place
+func findClosestNodesExcept(routingTable *models.RoutingTable, queryID string, count int, exclude map[string]bool) []*models.Node {
+	k := count
+	if k <= 0 {
+		k = routingTable.BucketSize()
+	}
+
+	routingTable.RLock()
+	defer routingTable.RUnlock()
+	contacts := 0
+	for _, bucket := range routingTable.Buckets {
+		contacts += len(bucket.Nodes)
+	}
+	var closest *closestHeap
+	var ok bool
+	if contacts >= ParallelScanThreshold && runtime.GOMAXPROCS(0) > 1 {
+		closest, ok = scanBucketsParallel(routingTable.Buckets, queryID, k, exclude)
+	} else {
+		closest, ok = scanBuckets(routingTable.Buckets, queryID, k, exclude)
+	}
+	if !ok {
+		return findClosestNodesBig(routingTable, queryID, count, exclude)
+	}
+	return closest.nodes()
+}
+
+// findClosestNodesBig is findClosestNodesExcept for IDs that do not fit a
+// Distance; callers must hold the table's read lock
+func findClosestNodesBig(routingTable *models.RoutingTable, queryID string, count int, exclude map[string]bool) []*models.Node {
 	var distances []NodeDistance
 
 	for _, bucket := range routingTable.Buckets {
 		for _, node := range bucket.Nodes {
+			if exclude[node.ID] {
+				continue
+			}
 			distance := calculateXORDistance(queryID, node.ID)
 			distances = append(distances, NodeDistance{
 				Node:     node,
@@ -69,13 +442,14 @@ func FindClosestNodes(routingTable *models.RoutingTable, queryID, localID string
 		}
 	}
 
-	// Sort nodes by distance.
 	sort.Slice(distances, func(i, j int) bool {
 		return distances[i].Distance.Cmp(distances[j].Distance) < 0
 	})
 
-	// Return up to k closest nodes.
-	k := constants.GetK()
+	k := count
+	if k <= 0 {
+		k = routingTable.BucketSize()
+	}
 
 	closestNodes := make([]*models.Node, 0, k)
 	for i := 0; i < len(distances) && i < k; i++ {
@@ -92,13 +466,6 @@ func calculateXORDistance(id1, id2 string) *big.Int {
 	return xor
 }
 
-func getBucketIndex(distance *big.Int) int {
-	if distance.Sign() == 0 { // Special case for distance 0
-		return 0
-	}
-	return distance.BitLen() - 1
-}
-
 func decodeHex(s string) []byte {
 	result, ok := new(big.Int).SetString(strings.ToUpper(s), 16)
 	if !ok || result == nil {
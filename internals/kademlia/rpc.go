@@ -0,0 +1,383 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+type eventsKey struct{}
+
+// WithEvents returns a context under which lookups report LookupCompleted
+// events to bus
+func WithEvents(ctx context.Context, bus *models.EventBus) context.Context {
+	return context.WithValue(ctx, eventsKey{}, bus)
+}
+
+func eventsFrom(ctx context.Context) *models.EventBus {
+	bus, _ := ctx.Value(eventsKey{}).(*models.EventBus)
+	return bus
+}
+
+// withRPCTimeout bounds ctx by the configured per-RPC timeout
+func withRPCTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, constants.GetRPCTimeout())
+}
+
+// StatusError is returned by RPCs a peer answered with an unexpected HTTP
+// status
+type StatusError struct {
+	Code      int
+	Addr      string
+	RequestID string
+	Message   string // Error message carried by the reply, if any
+}
+
+func newStatusError(resp *http.Response, addr, message string) *StatusError {
+	return &StatusError{Code: resp.StatusCode, Addr: addr, RequestID: resp.Header.Get(tracing.RequestIDHeader), Message: message}
+}
+
+// plainErrorMessage returns the start of a plain text error reply, such as
+// one written by http.Error, or "" for other replies
+func plainErrorMessage(resp *http.Response) string {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/plain" {
+		return ""
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return string(bytes.TrimSpace(data))
+}
+
+// replyError returns the error of a reply with a non-2xx status, wrapping
+// ErrInvalidWriteToken if the peer refused the write token presented
+func replyError(resp *http.Response, addr string) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusForbidden || mediaType != "application/json" {
+		return newStatusError(resp, addr, plainErrorMessage(resp))
+	}
+	var reply ErrorReply
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	json.Unmarshal(data, &reply)
+	statusErr := newStatusError(resp, addr, reply.Message)
+	if reply.Error == writeTokenErrorCode {
+		return fmt.Errorf("%w: %v", ErrInvalidWriteToken, statusErr)
+	}
+	return statusErr
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("status %d from %s (request %s): %s", e.Code, e.Addr, e.RequestID, e.Message)
+	}
+	return fmt.Sprintf("unexpected status %d from %s (request %s)", e.Code, e.Addr, e.RequestID)
+}
+
+// Retryable reports whether the status is likely transient: a timeout,
+// rate limit or unavailable peer rather than a rejected request
+func (e *StatusError) Retryable() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// rpcGet issues a GET to addr+path bounded by the per-RPC timeout and
+// decodes the response into out.
+func rpcGet(ctx context.Context, addr, path string, out interface{}) error {
+	return rpcGetWithHeader(ctx, addr, path, nil, out)
+}
+
+// rpcGetWithHeader is rpcGet with extra request headers
+func rpcGetWithHeader(ctx context.Context, addr, path string, header http.Header, out interface{}) error {
+	respHeader, body, err := rpcGetRaw(ctx, addr, path, header)
+	if err != nil {
+		return err
+	}
+	if err := decodeBody(respHeader.Get("Content-Type"), body, out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", addr, err)
+	}
+	return nil
+}
+
+// rpcGetRaw issues a GET to addr+path with the given extra headers, asking
+// for the configured wire format, and returns the reply's headers and body
+func rpcGetRaw(ctx context.Context, addr, path string, header http.Header) (http.Header, []byte, error) {
+	ctx, cancel := withRPCTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", addr, path), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", acceptHeader(ctx))
+	}
+	resp, err := network.Client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, newStatusError(resp, addr, plainErrorMessage(resp))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response from %s: %v", addr, err)
+	}
+	return resp.Header, body, nil
+}
+
+// rpcPost issues a POST of in as JSON to addr+path bounded by the per-RPC
+// timeout. Any 2xx status is treated as success.
+func rpcPost(ctx context.Context, addr, path string, in interface{}) error {
+	return rpcPostWithHeader(ctx, addr, path, nil, in, nil)
+}
+
+// rpcPostWithHeader is rpcPost with extra request headers, decoding the
+// response into out unless out is nil
+func rpcPostWithHeader(ctx context.Context, addr, path string, header http.Header, in, out interface{}) error {
+	ctx, cancel := withRPCTimeout(ctx)
+	defer cancel()
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s%s", addr, path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := network.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return replyError(resp, addr)
+	}
+	if out == nil {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from %s: %v", addr, err)
+	}
+	if err := decodeBody(resp.Header.Get("Content-Type"), data, out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", addr, err)
+	}
+	return nil
+}
+
+// SendFindNode sends a FIND_NODE RPC for target to peer. A reply from a
+// node other than peer is rejected; otherwise peer's LastSeen, and its
+// record if the reply carries a newer one, are updated.
+func SendFindNode(ctx context.Context, peer *models.Node, target string) ([]*models.Node, error) {
+	return sendFindNode(ctx, peer, target, "", 0)
+}
+
+// sendFindNode is SendFindNode on behalf of the node requester, which peer
+// leaves out of its reply together with itself, asking for count contacts.
+// An empty requester and a count of 0 or less are not sent.
+func sendFindNode(ctx context.Context, peer *models.Node, target, requester string, count int) ([]*models.Node, error) {
+	var header http.Header
+	if requester != "" {
+		header = http.Header{RequesterIDHeader: {requester}}
+	}
+	path := "/find_node?id=" + target
+	if count > 0 {
+		path += "&count=" + strconv.Itoa(count)
+	}
+	addr := peerAddr(peer)
+	header, body, err := rpcGetRaw(ctx, addr, path, header)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponder(peer, header); err != nil {
+		return nil, fmt.Errorf("invalid FIND_NODE reply from %s: %v", addr, err)
+	}
+	writeTokensFrom(ctx).Remember(addr, header)
+
+	var nodes []*models.Node
+	if err := decodeBody(header.Get("Content-Type"), body, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %v", addr, err)
+	}
+	dropInvalidRecords(nodes)
+	return nodes, nil
+}
+
+// dropInvalidRecords strips records that fail verification from contacts
+// reported by a peer, so forged records are never stored or relayed
+func dropInvalidRecords(nodes []*models.Node) {
+	for _, n := range nodes {
+		if n != nil && n.Record != nil && verifyRecordFor(n.Record, n.ID) != nil {
+			n.Record = nil
+		}
+	}
+}
+
+// SendStore sends a STORE RPC for key and value to peer
+func SendStore(ctx context.Context, peer *models.Node, key, value string) error {
+	return SendStoreRequest(ctx, peer, StoreRequest{Key: key, Value: value})
+}
+
+// SendFindValue sends a FIND_VALUE RPC for key to peer. If the peer holds
+// the value it is returned with found set; otherwise the peer's closest
+// contacts are returned.
+func SendFindValue(ctx context.Context, peer *models.Node, key string) (string, []*models.Node, bool, error) {
+	addr := peerAddr(peer)
+	// Ask for a found value as its raw bytes, so binary values need no
+	// base64; peers that predate this reply as they always did
+	accept := http.Header{"Accept": {ContentTypeOctetStream + ", " + acceptHeader(ctx)}}
+	header, body, err := rpcGetRaw(ctx, addr, "/find_value?key="+key, accept)
+	if err != nil {
+		return "", nil, false, err
+	}
+	writeTokensFrom(ctx).Remember(addr, header)
+	contentType := header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == ContentTypeOctetStream {
+		return string(body), nil, true, nil
+	}
+
+	var value string
+	if err := decodeBody(contentType, body, &value); err == nil {
+		return value, nil, true, nil
+	}
+	var nodes []*models.Node
+	if err := decodeBody(contentType, body, &nodes); err == nil {
+		return "", nodes, false, nil
+	}
+	var reply FindValueReply
+	if err := decodeBody(contentType, body, &reply); err != nil {
+		return "", nil, false, fmt.Errorf("failed to decode FIND_VALUE response from %s: %v", peer.IP, err)
+	}
+	value, err = reply.Decoded()
+	if err != nil {
+		return "", nil, false, fmt.Errorf("invalid FIND_VALUE response from %s: %v", peer.IP, err)
+	}
+	return value, nil, true, nil
+}
+
+// timedFindNode sends FIND_NODE to peer and records the round-trip time
+// in reputation. With adaptive set it waits only as long as the peer's
+// RTT history suggests, so a stalled peer holds up a lookup round
+// for little more than its usual reply time; a reply cut off that way is
+// recorded as taking the whole timeout, so the next one is longer.
+func timedFindNode(ctx context.Context, peer *models.Node, target, requester string, k int, reputation *models.PeerReputation, adaptive bool) ([]*models.Node, error) {
+	limit := constants.GetRPCTimeout()
+	if adaptive {
+		limit = reputation.Timeout(peer.ID, limit)
+	}
+	attempt, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	start := time.Now()
+	nodes, err := sendFindNode(attempt, peer, target, requester, k)
+	switch {
+	case err == nil:
+		reputation.RecordRTT(peer.ID, time.Since(start))
+	case ctx.Err() == nil && attempt.Err() == context.DeadlineExceeded:
+		reputation.RecordRTT(peer.ID, limit)
+	}
+	return nodes, err
+}
+
+// FanOutFindNode sends FIND_NODE for target to all peers concurrently and
+// returns the distinct contacts they report. As soon as k contacts have
+// been gathered the remaining in-flight requests are cancelled.
+func FanOutFindNode(ctx context.Context, peers []*models.Node, target string) []*models.Node {
+	return fanOutFindNode(ctx, peers, target, "", constants.GetK(), nil, nil, false)
+}
+
+// fanOutFindNode is FanOutFindNode on behalf of the node requester, if not
+// empty, recording each peer's outcome in reputation and peerStore, which
+// may be nil, and timing peers out by their RTT history if adaptive is
+// set. Requests cancelled once k contacts were found count neither
+// way.
+func fanOutFindNode(ctx context.Context, peers []*models.Node, target, requester string, k int, reputation *models.PeerReputation, peerStore *models.PeerStore, adaptive bool) []*models.Node {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan []*models.Node, len(peers))
+	replies := make([][]*models.Node, len(peers))
+
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer *models.Node) {
+			defer wg.Done()
+			var nodes []*models.Node
+			err := retry.Do(ctx, retry.For(ctx), func(ctx context.Context) (err error) {
+				nodes, err = timedFindNode(ctx, peer, target, requester, k, reputation, adaptive)
+				return err
+			})
+			if err == nil || ctx.Err() == nil {
+				reputation.Record(peer.ID, err == nil)
+				peerStore.RecordRPC(peer, err)
+			}
+			if err != nil {
+				return
+			}
+			replies[i] = nodes
+			results <- nodes
+		}(i, peer)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// In deterministic mode wait for every reply and merge them in query
+	// order, so the lookup path does not depend on network timing
+	merged := results
+	if Deterministic() {
+		wg.Wait()
+		merged = make(chan []*models.Node, len(peers))
+		for _, nodes := range replies {
+			merged <- nodes
+		}
+		close(merged)
+	}
+
+	seen := make(map[string]bool)
+	var found []*models.Node
+	for nodes := range merged {
+		for _, n := range nodes {
+			if seen[n.ID] {
+				continue
+			}
+			seen[n.ID] = true
+			found = append(found, n)
+		}
+		if len(found) >= k {
+			cancel()
+			break
+		}
+	}
+
+	eventsFrom(ctx).Emit(models.Event{Type: models.LookupCompleted, Key: target, Nodes: found})
+	return found
+}
@@ -0,0 +1,269 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// ErrUnknownJob is returned for a job name no job is scheduled under
+var ErrUnknownJob = errors.New("unknown job")
+
+// Job is a periodic background task run by a Scheduler
+type Job struct {
+	Name      string
+	Interval  time.Duration // Time from the end of one run to the start of the next
+	Immediate bool          // Run as soon as the scheduler starts instead of after the first interval
+
+	// Run does one run of the job and returns the delay before the next,
+	// or 0 for Interval
+	Run func(ctx context.Context) time.Duration
+}
+
+// SchedulerConfig configures how a Scheduler runs its jobs
+type SchedulerConfig struct {
+	Jitter        float64 // Fraction, from 0 to 1, of each delay that is randomized, so jobs and nodes do not run in lockstep
+	MaxConcurrent int     // Jobs running at once, 0 for no cap
+}
+
+// JobStatus describes a scheduled job, as served at /admin/jobs
+type JobStatus struct {
+	Name            string    `json:"name"`
+	IntervalSeconds float64   `json:"interval_seconds"`
+	Paused          bool      `json:"paused"`
+	Running         bool      `json:"running"`
+	Runs            uint64    `json:"runs"`
+	LastRun         time.Time `json:"last_run"`    // When the last run started, zero if none did
+	LastRunMs       float64   `json:"last_run_ms"` // How long the last run took
+	NextRun         time.Time `json:"next_run"`    // When the next run is due, zero until the scheduler starts
+}
+
+// Scheduler runs the node's periodic jobs from one loop, which sleeps
+// until the next job is due instead of every job keeping its own ticker.
+// A job is never run again before its run ends, runs beyond
+// MaxConcurrent wait for a slot, and each delay is jittered. Jobs can be
+// paused and resumed while the scheduler runs.
+type Scheduler struct {
+	cfg   SchedulerConfig
+	slots chan struct{} // Held by running jobs, nil without a cap
+	wake  chan struct{} // Signals the loop to look at the jobs again
+
+	mu   sync.Mutex
+	jobs []*scheduledJob // In the order added
+}
+
+type scheduledJob struct {
+	job      Job
+	paused   bool
+	running  bool
+	runs     uint64
+	lastRun  time.Time
+	lastTook time.Duration
+	next     time.Time // Zero until the loop first sees the job
+}
+
+// NewScheduler creates a scheduler without jobs
+func NewScheduler(cfg SchedulerConfig) *Scheduler {
+	s := &Scheduler{cfg: cfg, wake: make(chan struct{}, 1)}
+	if cfg.MaxConcurrent > 0 {
+		s.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return s
+}
+
+// Add schedules job, before or after the scheduler starts
+func (s *Scheduler) Add(job Job) error {
+	if job.Interval <= 0 || job.Run == nil {
+		return fmt.Errorf("job %q needs a positive interval and a run function", job.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.job.Name == job.Name {
+			return fmt.Errorf("job %q is already scheduled", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{job: job})
+	s.poke()
+	return nil
+}
+
+// Start runs the jobs until ctx is cancelled, then waits for the runs in
+// progress to end
+func (s *Scheduler) Start(ctx context.Context) {
+	var runs sync.WaitGroup
+	defer runs.Wait()
+
+	for {
+		s.mu.Lock()
+		now := clock.Now()
+		wait := time.Duration(-1)
+		for _, j := range s.jobs {
+			if j.next.IsZero() {
+				j.next = now
+				if !j.job.Immediate {
+					j.next = now.Add(s.jitter(j.job.Interval))
+				}
+			}
+			if j.paused || j.running {
+				continue
+			}
+			if !j.next.After(now) {
+				j.running = true
+				runs.Add(1)
+				go s.run(ctx, j, &runs)
+				continue
+			}
+			if d := j.next.Sub(now); wait < 0 || d < wait {
+				wait = d
+			}
+		}
+		s.mu.Unlock()
+
+		var due <-chan time.Time
+		if wait >= 0 {
+			due = clock.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-due:
+		}
+	}
+}
+
+// run runs j once it holds a slot and schedules its next run
+func (s *Scheduler) run(ctx context.Context, j *scheduledJob, runs *sync.WaitGroup) {
+	defer runs.Done()
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	started := clock.Now()
+	delay := j.job.Run(ctx)
+	if delay <= 0 {
+		delay = j.job.Interval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock.Now()
+	j.running = false
+	j.runs++
+	j.lastRun, j.lastTook = started, now.Sub(started)
+	j.next = now.Add(s.jitter(delay))
+	s.poke()
+}
+
+// Pause stops starting runs of the job called name, or of every job if
+// name is empty; a run in progress is left to end
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume starts running the job called name again, or every job if name
+// is empty. A job whose run fell due while paused runs at once.
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, j := range s.jobs {
+		if name == "" || j.job.Name == name {
+			j.paused = paused
+			found = true
+		}
+	}
+	if !found && name != "" {
+		return fmt.Errorf("%w %q", ErrUnknownJob, name)
+	}
+	s.poke()
+	return nil
+}
+
+// Jobs returns the status of every job, in the order added
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, JobStatus{
+			Name:            j.job.Name,
+			IntervalSeconds: j.job.Interval.Seconds(),
+			Paused:          j.paused,
+			Running:         j.running,
+			Runs:            j.runs,
+			LastRun:         j.lastRun,
+			LastRunMs:       float64(j.lastTook) / float64(time.Millisecond),
+			NextRun:         j.next,
+		})
+	}
+	return statuses
+}
+
+// poke wakes the loop without blocking
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// jitter returns d with up to Jitter of it randomized around it
+func (s *Scheduler) jitter(d time.Duration) time.Duration {
+	if s.cfg.Jitter <= 0 || d <= 0 {
+		return d
+	}
+	spread := time.Duration(s.cfg.Jitter * float64(d))
+	return d + time.Duration(randInt63n(int64(spread)+1)) - spread/2
+}
+
+// AdminJobsHandler handles /admin/jobs, listing the scheduled jobs
+func AdminJobsHandler(w http.ResponseWriter, r *http.Request, scheduler *Scheduler) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduler.Jobs())
+}
+
+// AdminPauseJobsHandler handles /admin/jobs/pause, or with pause false
+// /admin/jobs/resume, for the job named by the "job" parameter or every
+// job, and lists the jobs
+func AdminPauseJobsHandler(w http.ResponseWriter, r *http.Request, scheduler *Scheduler, pause bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	var req AdminJobsRequest
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	setPaused := scheduler.Resume
+	if pause {
+		setPaused = scheduler.Pause
+	}
+	if err := setPaused(req.Job); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduler.Jobs())
+}
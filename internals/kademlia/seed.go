@@ -0,0 +1,190 @@
+package kademlia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// MaxSeedAttempts bounds the resolved addresses JoinBootstrap probes
+const MaxSeedAttempts = 5
+
+// BootstrapJoins is how many of the fastest responding bootstrap nodes
+// JoinBootstrap joins through
+const BootstrapJoins = 3
+
+// Resolver looks up the records of a DNS seed. *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// SeedResolver resolves bootstrap names for JoinBootstrap
+var SeedResolver Resolver = net.DefaultResolver
+
+// ResolveBootstrap expands a bootstrap address into candidate <ip>:<port>
+// addresses in random order:
+//
+//   - a comma separated list yields the addresses of each entry, entries
+//     that fail to resolve being skipped
+//   - an <ip>:<port> is returned as is
+//   - a <host>:<port> yields every A and AAAA record of host on port
+//   - a <host> without a port is read as a TXT seed list: each TXT record
+//     holds <host>:<port> entries separated by spaces or commas, which are
+//     resolved in turn
+func ResolveBootstrap(ctx context.Context, resolver Resolver, bootstrap string) ([]string, error) {
+	var addrs []string
+	if strings.Contains(bootstrap, ",") {
+		var errs []error
+		for _, entry := range strings.Split(bootstrap, ",") {
+			resolved, err := ResolveBootstrap(ctx, resolver, strings.TrimSpace(entry))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			addrs = append(addrs, resolved...)
+		}
+		if len(addrs) == 0 {
+			return nil, errors.Join(errs...)
+		}
+	} else if _, _, err := net.SplitHostPort(bootstrap); err == nil {
+		resolved, err := resolveHostPort(ctx, resolver, bootstrap)
+		if err != nil {
+			return nil, err
+		}
+		addrs = resolved
+	} else {
+		if bootstrap == "" || strings.ContainsAny(bootstrap, ":/ ") {
+			return nil, fmt.Errorf("invalid bootstrap address %q, expected <ip>:<port>, <host>:<port> or <host>", bootstrap)
+		}
+		records, err := resolver.LookupTXT(ctx, bootstrap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up seed list %s: %v", bootstrap, err)
+		}
+		for _, record := range records {
+			for _, entry := range strings.FieldsFunc(record, func(r rune) bool { return r == ',' || r == ' ' }) {
+				resolved, err := resolveHostPort(ctx, resolver, entry)
+				if err != nil {
+					continue
+				}
+				addrs = append(addrs, resolved...)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("bootstrap address %s resolved to no seeds", bootstrap)
+	}
+
+	shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	return addrs, nil
+}
+
+// resolveHostPort resolves the host of a <host>:<port> entry
+func resolveHostPort(ctx context.Context, resolver Resolver, entry string) ([]string, error) {
+	host, port, err := net.SplitHostPort(entry)
+	if err != nil {
+		return nil, err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return nil, fmt.Errorf("invalid port in bootstrap address %s", entry)
+	}
+	if net.ParseIP(host) != nil {
+		return []string{entry}, nil
+	}
+	if host == "" {
+		return nil, fmt.Errorf("missing host in bootstrap address %s", entry)
+	}
+
+	ips, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip, port))
+	}
+	return addrs, nil
+}
+
+// JoinBootstrap resolves bootstrap with SeedResolver and, when it yields
+// several candidates, pings up to MaxSeedAttempts of them in parallel and
+// joins through the BootstrapJoins that answer fastest, so nodes in
+// different regions join near their own. The measured round-trip times
+// seed the routing table's peer reputation. It returns the errors of every
+// candidate if none could be joined through.
+func JoinBootstrap(ctx context.Context, node *models.Node, routingTable *models.RoutingTable, bootstrap string) error {
+	addrs, err := ResolveBootstrap(ctx, SeedResolver, bootstrap)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 1 {
+		return JoinNetwork(ctx, node, routingTable, addrs[0])
+	}
+
+	var errs []error
+	joined := 0
+	for _, probe := range ProbeBootstraps(ctx, addrs[:min(len(addrs), MaxSeedAttempts)]) {
+		if probe.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", probe.Addr, probe.Err))
+			continue
+		}
+		routingTable.Reputation.RecordRTT(probe.NodeID, probe.RTT)
+		if joined == BootstrapJoins {
+			continue
+		}
+		if err := JoinNetwork(ctx, node, routingTable, probe.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", probe.Addr, err))
+			continue
+		}
+		joined++
+	}
+	if joined > 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// BootstrapProbe is the outcome of pinging a bootstrap candidate
+type BootstrapProbe struct {
+	Addr   string
+	NodeID string        // ID the candidate answered with
+	RTT    time.Duration // Round-trip time of the ping
+	Err    error         // Why the candidate did not answer, nil if it did
+}
+
+// ProbeBootstraps pings every address in addrs at once and returns the
+// responders ordered by round-trip time, followed by the failures
+func ProbeBootstraps(ctx context.Context, addrs []string) []BootstrapProbe {
+	probes := make([]BootstrapProbe, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			var reply PingReply
+			start := time.Now()
+			err := rpcGet(ctx, addr, "/ping", &reply)
+			probes[i] = BootstrapProbe{Addr: addr, NodeID: reply.NodeID, RTT: time.Since(start), Err: err}
+			if err == nil && reply.NodeID == "" {
+				probes[i].Err = errors.New("PONG without a node ID")
+			}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	sort.SliceStable(probes, func(i, j int) bool {
+		if (probes[i].Err == nil) != (probes[j].Err == nil) {
+			return probes[i].Err == nil
+		}
+		return probes[i].Err == nil && probes[i].RTT < probes[j].RTT
+	})
+	return probes
+}
@@ -0,0 +1,132 @@
+package kademlia
+
+import (
+	"net/http"
+
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// NewServeMux returns a mux serving every Kademlia RPC for node. Each
+// handler is wrapped in a tracing span named after the RPC, inside which
+// mws run in order, the first outermost. puncher is nil when hole punching
+// is disabled.
+func NewServeMux(node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, providers *models.ProviderStore, pubsub *PubSub, watches *Watches, relay *Relay, puncher *HolePuncher, mws ...middleware.Middleware) *http.ServeMux {
+	mux := http.NewServeMux()
+	RegisterRPC(mux, node, routingTable, storage, providers, pubsub, watches, relay, puncher, mws...)
+	return mux
+}
+
+// RegisterRPC registers every Kademlia RPC for node on mux, as served by
+// NewServeMux
+func RegisterRPC(mux *http.ServeMux, node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, providers *models.ProviderStore, pubsub *PubSub, watches *Watches, relay *Relay, puncher *HolePuncher, mws ...middleware.Middleware) {
+	chain := middleware.Chain(mws...)
+	started := clock.Now()
+	snapshotLimit := middleware.RateLimit(SnapshotRate, SnapshotBurst)
+
+	mux.HandleFunc("/ping", tracing.Middleware("ping", node.ID, chain("ping", func(w http.ResponseWriter, r *http.Request) {
+		PingHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/rpc", tracing.Middleware("rpc", node.ID, chain("rpc", func(w http.ResponseWriter, r *http.Request) {
+		MessageHandler(w, r, node, storage, providers, routingTable)
+	})))
+	mux.HandleFunc("/find_node", tracing.Middleware("find_node", node.ID, chain("find_node", func(w http.ResponseWriter, r *http.Request) {
+		FindNodeHandler(w, r, node, routingTable)
+	})))
+	mux.HandleFunc("/store", tracing.Middleware("store", node.ID, chain("store", func(w http.ResponseWriter, r *http.Request) {
+		StoreHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/delete", tracing.Middleware("delete", node.ID, chain("delete", func(w http.ResponseWriter, r *http.Request) {
+		DeleteHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/lease", tracing.Middleware("lease", node.ID, chain("lease", func(w http.ResponseWriter, r *http.Request) {
+		LeaseHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/find_value", tracing.Middleware("find_value", node.ID, chain("find_value", func(w http.ResponseWriter, r *http.Request) {
+		FindValueHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/find_values", tracing.Middleware("find_values", node.ID, chain("find_values", func(w http.ResponseWriter, r *http.Request) {
+		FindValuesHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/peers", tracing.Middleware("peers", node.ID, chain("peers", func(w http.ResponseWriter, r *http.Request) {
+		PeersHandler(w, r, node, routingTable)
+	})))
+	mux.HandleFunc("/routing_table", tracing.Middleware("routing_table", node.ID, chain("routing_table", snapshotLimit("routing_table", func(w http.ResponseWriter, r *http.Request) {
+		RoutingTableHandler(w, r, node, routingTable)
+	}))))
+	mux.HandleFunc("/iterate_keys", tracing.Middleware("iterate_keys", node.ID, chain("iterate_keys", func(w http.ResponseWriter, r *http.Request) {
+		IterateKeysHandler(w, r, node, storage)
+	})))
+	mux.HandleFunc("/sync_digest", tracing.Middleware("sync_digest", node.ID, chain("sync_digest", func(w http.ResponseWriter, r *http.Request) {
+		SyncDigestHandler(w, r, node, storage)
+	})))
+	mux.HandleFunc("/sync_push", tracing.Middleware("sync_push", node.ID, chain("sync_push", func(w http.ResponseWriter, r *http.Request) {
+		SyncPushHandler(w, r, node, storage, routingTable)
+	})))
+	mux.HandleFunc("/add_provider", tracing.Middleware("add_provider", node.ID, chain("add_provider", func(w http.ResponseWriter, r *http.Request) {
+		AddProviderHandler(w, r, node, providers)
+	})))
+	mux.HandleFunc("/get_providers", tracing.Middleware("get_providers", node.ID, chain("get_providers", func(w http.ResponseWriter, r *http.Request) {
+		GetProvidersHandler(w, r, node, providers, routingTable)
+	})))
+	mux.HandleFunc("/node_info", tracing.Middleware("node_info", node.ID, chain("node_info", func(w http.ResponseWriter, r *http.Request) {
+		NodeInfoHandler(w, r, node, routingTable, storage, started)
+	})))
+	mux.HandleFunc("/ownership", tracing.Middleware("ownership", node.ID, chain("ownership", func(w http.ResponseWriter, r *http.Request) {
+		OwnershipHandler(w, r, node, routingTable)
+	})))
+	mux.HandleFunc("/buckets", tracing.Middleware("buckets", node.ID, chain("buckets", func(w http.ResponseWriter, r *http.Request) {
+		BucketsHandler(w, r, routingTable)
+	})))
+	mux.HandleFunc("/subscribe", tracing.Middleware("subscribe", node.ID, chain("subscribe", func(w http.ResponseWriter, r *http.Request) {
+		SubscribeHandler(w, r, node, storage, routingTable, pubsub)
+	})))
+	mux.HandleFunc("/publish", tracing.Middleware("publish", node.ID, chain("publish", func(w http.ResponseWriter, r *http.Request) {
+		PublishHandler(w, r, node, storage, pubsub)
+	})))
+	mux.HandleFunc("/poll", tracing.Middleware("poll", node.ID, chain("poll", func(w http.ResponseWriter, r *http.Request) {
+		PollHandler(w, r, pubsub)
+	})))
+	mux.HandleFunc("/watch", tracing.Middleware("watch", node.ID, chain("watch", func(w http.ResponseWriter, r *http.Request) {
+		WatchHandler(w, r, node, storage, watches)
+	})))
+	mux.HandleFunc("/watch_poll", tracing.Middleware("watch_poll", node.ID, chain("watch_poll", func(w http.ResponseWriter, r *http.Request) {
+		WatchPollHandler(w, r, storage, watches)
+	})))
+	mux.HandleFunc("/relay/register", tracing.Middleware("relay_register", node.ID, chain("relay_register", func(w http.ResponseWriter, r *http.Request) {
+		RelayRegisterHandler(w, r, node, relay)
+	})))
+	mux.HandleFunc("/relay/", tracing.Middleware("relay_forward", node.ID, chain("relay_forward", func(w http.ResponseWriter, r *http.Request) {
+		RelayForwardHandler(w, r, node, relay)
+	})))
+	mux.HandleFunc("/punch", tracing.Middleware("punch", node.ID, chain("punch", func(w http.ResponseWriter, r *http.Request) {
+		PunchHandler(w, r, node, routingTable, relay)
+	})))
+	mux.HandleFunc("/punch_notify", tracing.Middleware("punch_notify", node.ID, chain("punch_notify", func(w http.ResponseWriter, r *http.Request) {
+		PunchNotifyHandler(w, r, node, puncher)
+	})))
+	mux.HandleFunc(OpenAPIPath, tracing.Middleware("openapi", node.ID, chain("openapi", OpenAPI().Handler())))
+}
+
+// RegisterStats registers the node's diagnostics endpoints on mux, run
+// through mws. They describe the node's peers and load rather than serve
+// the network, so they belong to the admin handler set and are only
+// reachable on the admin listener when one is configured.
+func RegisterStats(mux *http.ServeMux, node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore, mws ...middleware.Middleware) {
+	chain := middleware.Chain(mws...)
+
+	mux.HandleFunc("/pool_stats", tracing.Middleware("pool_stats", node.ID, chain("pool_stats", func(w http.ResponseWriter, r *http.Request) {
+		PoolStatsHandler(w, r)
+	})))
+	mux.HandleFunc("/store_stats", tracing.Middleware("store_stats", node.ID, chain("store_stats", func(w http.ResponseWriter, r *http.Request) {
+		StoreStatsHandler(w, r, storage)
+	})))
+	mux.HandleFunc("/churn_stats", tracing.Middleware("churn_stats", node.ID, chain("churn_stats", func(w http.ResponseWriter, r *http.Request) {
+		ChurnStatsHandler(w, r, routingTable)
+	})))
+	mux.HandleFunc("/peer_store", tracing.Middleware("peer_store", node.ID, chain("peer_store", func(w http.ResponseWriter, r *http.Request) {
+		PeerStoreHandler(w, r, routingTable)
+	})))
+}
@@ -0,0 +1,102 @@
+package kademlia
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Limits of /routing_table. A snapshot is far larger than a FIND_NODE
+// reply, so each caller IP may only fetch a few in quick succession.
+const (
+	MaxSnapshotSize = 1000 // Contacts returned per snapshot
+	SnapshotRate    = 0.1  // Snapshots per second allowed from each caller IP
+	SnapshotBurst   = 3    // Snapshots a caller may fetch at once before being limited
+)
+
+// RoutingTableSnapshot returns up to MaxSnapshotSize contacts of the
+// routing table, excluding the local node, closest buckets first
+func RoutingTableSnapshot(routingTable *models.RoutingTable, localID string) []*models.Node {
+	contacts := []*models.Node{}
+	for _, n := range routingTable.Contacts() {
+		if n.ID == localID {
+			continue
+		}
+		if len(contacts) == MaxSnapshotSize {
+			return contacts
+		}
+		contacts = append(contacts, n)
+	}
+	return contacts
+}
+
+// RoutingTableHandler handles /routing_table requests, returning a
+// snapshot of the routing table so joining nodes can fill theirs in one
+// round trip instead of many FIND_NODE rounds
+func RoutingTableHandler(w http.ResponseWriter, r *http.Request, node *models.Node, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeResponder(w, node)
+	writeEncoded(w, r, RoutingTableSnapshot(routingTable, node.ID))
+}
+
+// SendRoutingTableSnapshot fetches a snapshot of peer's routing table. A
+// reply from a node other than peer is rejected.
+func SendRoutingTableSnapshot(ctx context.Context, peer *models.Node) ([]*models.Node, error) {
+	addr := peerAddr(peer)
+	header, body, err := rpcGetRaw(ctx, addr, "/routing_table", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponder(peer, header); err != nil {
+		return nil, fmt.Errorf("invalid routing table reply from %s: %v", addr, err)
+	}
+
+	var nodes []*models.Node
+	if err := decodeBody(header.Get("Content-Type"), body, &nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode routing table from %s: %v", addr, err)
+	}
+	dropInvalidRecords(nodes)
+	return nodes, nil
+}
+
+// ImportRoutingTable fetches a snapshot of peer's routing table and adds
+// its valid contacts to routingTable under the usual bucket rules,
+// returning the number of contacts that were new. Contacts with a
+// malformed ID or address or of another network than peer's, and the
+// local node itself, are skipped.
+func ImportRoutingTable(ctx context.Context, routingTable *models.RoutingTable, peer *models.Node, localID string) (int, error) {
+	nodes, err := SendRoutingTableSnapshot(ctx, peer)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, n := range nodes {
+		if !validContact(n) || n.ID == localID || containsNode(routingTable, n.ID) || n.Network != peer.Network {
+			continue
+		}
+		AddNodeToRoutingTable(routingTable, n, localID)
+		if containsNode(routingTable, n.ID) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// validContact reports whether a contact reported by a peer can be dialed
+func validContact(n *models.Node) bool {
+	if n == nil || validators.ValidateID(n.ID, validators.HexadecimalValidator) != nil {
+		return false
+	}
+	if n.Relay != "" {
+		return true
+	}
+	return n.IP != "" && n.Port > 0 && n.Port <= 65535
+}
@@ -1,12 +1,46 @@
 package kademlia
 
-import "github.com/Aradhya2708/kademlia/pkg/models"
+import (
+	"fmt"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/pkg/storage"
+)
 
 // NewKeyValueStore creates a new thread-safe KeyValueStore.
 func NewKeyValueStore() *models.KeyValueStore {
 	return models.NewKeyValueStore()
 }
 
+// OpenKeyValueStore creates a KeyValueStore on the backend selected by
+// cfg, loading the values a persistent backend already holds
+func OpenKeyValueStore(cfg config.StorageConfig) (*models.KeyValueStore, error) {
+	var backend models.Storage
+	var err error
+	switch cfg.Backend {
+	case "", "memory":
+		backend = models.NewMemoryStorage()
+	case "file":
+		backend, err = storage.OpenFile(cfg.Path)
+	case "sqlite":
+		backend, err = storage.OpenSQLite(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kvs, err := models.NewKeyValueStoreOn(backend)
+	if err != nil {
+		backend.Close()
+		return nil, fmt.Errorf("failed to load storage: %v", err)
+	}
+	return kvs, nil
+}
+
 // StoreKeyValue stores a key-value pair in the KeyValueStore.
 func StoreKeyValue(kvs *models.KeyValueStore, key, value string) {
 	kvs.Set(key, value)
@@ -16,3 +50,8 @@ func StoreKeyValue(kvs *models.KeyValueStore, key, value string) {
 func FindValue(kvs *models.KeyValueStore, key string) (string, bool) {
 	return kvs.Get(key)
 }
+
+// NewProviderStore creates a ProviderStore with the default TTL and size caps.
+func NewProviderStore() *models.ProviderStore {
+	return models.NewProviderStore(constants.DefaultProviderTTL, constants.DefaultMaxProvidersPerKey, constants.DefaultMaxProviderRecords)
+}
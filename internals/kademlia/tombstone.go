@@ -0,0 +1,252 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// DeleteRequest is the body of a /delete request. The value is replaced by
+// a tombstone dated DeletedAt, now if unset and never later than the
+// receiving node's clock, which replicas keep and exchange during
+// anti-entropy until garbage collection drops it, so a replica that
+// missed the DELETE cannot resurrect the value.
+//
+// A value stored with a publisher is only deleted by a request its
+// publisher signed with SignDelete: Publisher must be the PublisherID of
+// PublicKey, which signed the request. A value stored without one may be
+// deleted by anyone who may write the key.
+type DeleteRequest struct {
+	Key       string     `json:"key" validate:"required,id"`
+	Publisher string     `json:"publisher,omitempty"`
+	PublicKey string     `json:"public_key,omitempty"` // Hex ed25519 key of Publisher
+	Signature string     `json:"signature,omitempty"`  // Hex signature by PublicKey, see SignDelete
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Replicate bool       `json:"replicate,omitempty"`
+
+	// Requester, the node replicating the delete, Namespace and
+	// NamespaceToken are sent as headers, as is the write token nodes
+	// requiring write tokens need
+	Requester      string `json:"-"`
+	Namespace      string `json:"-"`
+	NamespaceToken string `json:"-"`
+}
+
+// DeleteHandler handles /delete requests
+func DeleteHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, routingTable *models.RoutingTable) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !validateBody(w, &req) {
+		return
+	}
+	if req.DeletedAt == nil {
+		now := clock.Now()
+		req.DeletedAt = &now
+	}
+
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+	req.Namespace = namespace
+	req.NamespaceToken = r.Header.Get(NamespaceTokenHeader)
+
+	// Delete from the k closest nodes on behalf of the client
+	if req.Replicate {
+		ack := IterativeDelete(r.Context(), routingTable, node, storage, req)
+		status := http.StatusOK
+		if ack.ReplicationFactor == 0 {
+			status = http.StatusBadGateway
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ack)
+		return
+	}
+
+	if !node.Supports(models.FlagStorage) {
+		http.Error(w, "This node does not accept STOREs", http.StatusForbidden)
+		return
+	}
+	if !allowedWriter(routingTable, r) {
+		writeStoreConflict(w, ErrNotAllowlisted)
+		return
+	}
+	if !checkWriteToken(r, node, storage, r.Header.Get(WriteTokenHeader)) {
+		writeStoreConflict(w, ErrInvalidWriteToken)
+		return
+	}
+	if err := deleteLocal(storage, req); err != nil {
+		writeStoreConflict(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StoreAck{
+		Key:               req.Key,
+		Replicas:          []ReplicaAck{newReplicaAck(node, nil)},
+		ReplicationFactor: 1,
+	})
+}
+
+// IterativeDelete tombstones req.Key on the k nodes closest to it, this
+// node included when it is among them. The ack counts the replicas that
+// recorded the tombstone.
+func IterativeDelete(ctx context.Context, routingTable *models.RoutingTable, self *models.Node, storage *models.KeyValueStore, req DeleteRequest) StoreAck {
+	req.Replicate = false
+	req.Requester = self.ID
+	if req.DeletedAt == nil {
+		now := clock.Now()
+		req.DeletedAt = &now
+	}
+	closest := IterativeFindNodeWithOptions(ctx, routingTable, self.ID, req.Key, LookupOptions{Require: models.FlagStorage})
+
+	ack := StoreAck{Key: req.Key, Replicas: make([]ReplicaAck, len(closest))}
+	var wg sync.WaitGroup
+	for i, peer := range closest {
+		wg.Add(1)
+		go func(i int, peer *models.Node) {
+			defer wg.Done()
+
+			var err error
+			if peer.ID == self.ID {
+				err = deleteLocal(storage, req)
+			} else {
+				// Tombstones are idempotent, so every DELETE may be retried
+				err = retry.Do(ctx, retry.For(ctx), func(ctx context.Context) error {
+					return SendDelete(ctx, peer, req)
+				})
+			}
+			ack.Replicas[i] = newReplicaAck(peer, err)
+		}(i, peer)
+	}
+	wg.Wait()
+
+	for _, replica := range ack.Replicas {
+		if replica.Stored {
+			ack.ReplicationFactor++
+		} else {
+			ack.Failed++
+		}
+	}
+	return ack
+}
+
+// SendDelete asks peer to tombstone req.Key, presenting the write token
+// peer last issued as SendStoreRequest does
+func SendDelete(ctx context.Context, peer *models.Node, req DeleteRequest) error {
+	return withWriteToken(ctx, peer, req.Key, "", func(token string) error {
+		header := requesterHeader(req.Requester, req.Namespace, req.NamespaceToken)
+		if token != "" {
+			header.Set(WriteTokenHeader, token)
+		}
+		return rpcPostWithHeader(ctx, peerAddr(peer), "/delete", header, req, nil)
+	})
+}
+
+// deleteLocal tombstones req.Key in storage under its namespace, refusing
+// if the value has a publisher that did not sign req. A tombstone already
+// at least as new counts as success.
+func deleteLocal(storage *models.KeyValueStore, req DeleteRequest) error {
+	_, err := tombstoneLocal(storage, req)
+	return err
+}
+
+// tombstoneLocal tombstones req.Key in storage under its namespace with
+// the publisher's signature of req, if valid, failing with
+// ErrPublisherMismatch if the value has a publisher that did not sign
+// req. It reports whether the tombstone was recorded. The tombstone is
+// dated no later than now, as one from the future would outlive garbage
+// collection.
+func tombstoneLocal(storage *models.KeyValueStore, req DeleteRequest) (bool, error) {
+	key := models.NamespacedKey(req.Namespace, req.Key)
+	deletedAt, _ := clock.FromPeer(*req.DeletedAt, clock.Now())
+	return storage.TombstoneSigned(key, deletedAt, req.proof(), req.signedBy)
+}
+
+// PublisherID returns the publisher ID owned by the holder of the private
+// key of pub: the SHA-1 of pub, the size of a node ID. Values stored with
+// it as their publisher can only be deleted by a request SignDelete signed
+// with that key.
+func PublisherID(pub ed25519.PublicKey) string {
+	return KeyFromBytes(pub)
+}
+
+// SignDelete signs req with key as the publisher PublisherID of its public
+// key, dating it now if it has no DeletedAt. The signature covers the key,
+// its namespace and the date, so it cannot be replayed against another
+// key or to delete a value stored after it.
+func SignDelete(req *DeleteRequest, key ed25519.PrivateKey) {
+	if req.DeletedAt == nil {
+		now := clock.Now()
+		req.DeletedAt = &now
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	req.Publisher = PublisherID(pub)
+	req.PublicKey = hex.EncodeToString(pub)
+	req.Signature = hex.EncodeToString(ed25519.Sign(key, req.signingBytes()))
+}
+
+// proof returns the publisher's signature of req, to be kept with its
+// tombstone, or nil if req is not validly signed
+func (req DeleteRequest) proof() *models.DeleteProof {
+	if req.Signature == "" || !req.signedBy(req.Publisher) {
+		return nil
+	}
+	return &models.DeleteProof{Publisher: req.Publisher, PublicKey: req.PublicKey, Signature: req.Signature, DeletedAt: *req.DeletedAt}
+}
+
+// syncedDelete returns the delete that recorded the tombstone rec, with
+// its publisher's signature if it carries one
+func syncedDelete(rec KeyRecord) DeleteRequest {
+	ns, raw := models.SplitNamespacedKey(rec.Key)
+	return DeleteRequest{
+		Key:       raw,
+		Namespace: ns,
+		Publisher: rec.Publisher,
+		PublicKey: rec.PublicKey,
+		Signature: rec.Signature,
+		DeletedAt: rec.DeletedAt,
+	}
+}
+
+// signingBytes returns what the publisher's signature of req covers
+func (req DeleteRequest) signingBytes() []byte {
+	var deletedAt string
+	if req.DeletedAt != nil {
+		deletedAt = req.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return []byte("kademlia-delete\x00" + req.Namespace + "\x00" + req.Key + "\x00" + deletedAt)
+}
+
+// signedBy reports whether req was signed by publisher with SignDelete
+func (req DeleteRequest) signedBy(publisher string) bool {
+	if req.Publisher != publisher || req.DeletedAt == nil {
+		return false
+	}
+	pub, err := hex.DecodeString(req.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize || PublisherID(pub) != publisher {
+		return false
+	}
+	sig, err := hex.DecodeString(req.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pub, req.signingBytes(), sig)
+}
@@ -0,0 +1,327 @@
+package kademlia
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Limits of key watching
+const (
+	WatchTTL            = 10 * time.Minute // Registrations lapse unless renewed within this
+	MaxWatchers         = 10000            // Registrations a node keeps across all keys
+	DefaultWatchTimeout = 30               // Seconds a /watch_poll waits by default
+)
+
+var (
+	// ErrTooManyWatchers is returned when a node already keeps MaxWatchers
+	// registrations
+	ErrTooManyWatchers = errors.New("too many watchers")
+	// ErrNotWatching is returned when polling a key the watcher does not
+	// watch, or whose registration lapsed
+	ErrNotWatching = errors.New("not watching the key")
+)
+
+// KeyChange is the state of a watched key on a holder. Seq numbers the
+// versions of the key the holder has seen since the first watch: it grows
+// by one whenever a different value is stored or the value is deleted, and
+// is 0 for the state at the first watch.
+type KeyChange struct {
+	Key      string `json:"key"`
+	Seq      uint64 `json:"seq"`
+	Value    string `json:"value,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // models.ValueEncodingBase64 if Value is the base64 of a binary value
+	Deleted  bool   `json:"deleted,omitempty"`  // The key holds no value
+	Time     int64  `json:"time"`               // Unix time of the change
+}
+
+// Decoded returns the bytes of the change's value
+func (c KeyChange) Decoded() (string, error) {
+	return models.DecodeValue(c.Value, c.Encoding)
+}
+
+// Watches keeps the watchers of the keys a node stores and tells them when
+// the keys change, that is when a different value is stored or the value
+// is deleted, expires or is evicted: by POSTing the KeyChange to their webhook, and by
+// answering their long polls. Only the latest version of a key is kept, so
+// a watcher polling slower than the key changes sees the newest state
+// rather than every version.
+type Watches struct {
+	Webhooks *Webhooks // Notifies watchers with a webhook
+
+	storage *models.KeyValueStore
+
+	mu    sync.Mutex
+	keys  map[string]*watchedKey
+	count int // Registrations across all keys
+}
+
+type watchedKey struct {
+	state    KeyChange
+	digest   uint64 // Of state.Value, to tell changes from republishes
+	watchers map[string]*keyWatcher
+	signal   chan struct{} // closed and replaced whenever the key changes
+}
+
+type keyWatcher struct {
+	webhook string
+	expires time.Time
+}
+
+// NewWatches creates a Watches following the changes to storage, giving
+// storage an event bus if it has none. Only public webhooks are notified.
+func NewWatches(storage *models.KeyValueStore) *Watches {
+	ws := &Watches{Webhooks: NewWebhooks(false), storage: storage, keys: make(map[string]*watchedKey)}
+	if storage.Events == nil {
+		storage.Events = models.NewEventBus()
+	}
+	storage.Events.On(func(e models.Event) {
+		ws.changed(e.Key, e.Value, e.Type != models.ValueStored)
+	}, models.ValueStored, models.ValueDeleted, models.ValueExpired, models.ValueEvicted)
+	return ws
+}
+
+// Watch registers, or renews for another WatchTTL, watcherID's interest in
+// the storage key and returns the key's current state. With a webhook
+// every later change is POSTed to it, unless the webhook is refused with
+// ErrWebhookTarget.
+func (ws *Watches) Watch(key, watcherID, webhook string) (KeyChange, time.Time, error) {
+	if webhook != "" {
+		if err := ws.Webhooks.Check(context.Background(), webhook); err != nil {
+			return KeyChange{}, time.Time{}, err
+		}
+	}
+	now := clock.Now()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.expire(now)
+	wk := ws.keys[key]
+	if wk == nil {
+		if ws.count >= MaxWatchers {
+			return KeyChange{}, time.Time{}, ErrTooManyWatchers
+		}
+		value, found := ws.storage.Get(key)
+		wk = &watchedKey{watchers: make(map[string]*keyWatcher), signal: make(chan struct{})}
+		wk.set(key, value, !found, now)
+		ws.keys[key] = wk
+	}
+	w := wk.watchers[watcherID]
+	if w == nil {
+		if ws.count >= MaxWatchers {
+			return KeyChange{}, time.Time{}, ErrTooManyWatchers
+		}
+		w = &keyWatcher{}
+		wk.watchers[watcherID] = w
+		ws.count++
+	}
+	w.webhook = webhook
+	w.expires = now.Add(WatchTTL)
+	return wk.state, w.expires, nil
+}
+
+// Unwatch drops watcherID's registration for the storage key
+func (ws *Watches) Unwatch(key, watcherID string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if wk := ws.keys[key]; wk != nil && wk.watchers[watcherID] != nil {
+		ws.drop(key, wk, watcherID)
+	}
+}
+
+// Poll returns the state of the storage key watched by watcherID once its
+// Seq is above since, waiting up to timeout for a change. It reports false
+// if the key did not change in time, and fails with ErrNotWatching if
+// watcherID does not watch it.
+func (ws *Watches) Poll(ctx context.Context, key, watcherID string, since uint64, timeout time.Duration) (KeyChange, bool, error) {
+	ws.mu.Lock()
+	wk := ws.keys[key]
+	if wk == nil || wk.watchers[watcherID] == nil {
+		ws.mu.Unlock()
+		return KeyChange{}, false, ErrNotWatching
+	}
+	if wk.state.Seq <= since {
+		signal := wk.signal
+		ws.mu.Unlock()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-signal:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		ws.mu.Lock()
+	}
+	defer ws.mu.Unlock()
+	return wk.state, wk.state.Seq > since, nil
+}
+
+// Watchers returns the number of registrations the node keeps
+func (ws *Watches) Watchers() int {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.count
+}
+
+// changed records that the storage key now holds value, or nothing if
+// deleted, and notifies its watchers if that is a new version
+func (ws *Watches) changed(key, value string, deleted bool) {
+	now := clock.Now()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	wk := ws.keys[key]
+	if wk == nil || !wk.set(key, value, deleted, now) {
+		return
+	}
+	close(wk.signal)
+	wk.signal = make(chan struct{})
+	for id, w := range wk.watchers {
+		if now.After(w.expires) {
+			ws.drop(key, wk, id)
+			continue
+		}
+		if w.webhook != "" {
+			ws.Webhooks.Notify(w.webhook, wk.state)
+		}
+	}
+}
+
+// set makes value, or nothing if deleted, the key's state, reporting
+// whether that is a new version
+func (wk *watchedKey) set(key, value string, deleted bool, now time.Time) bool {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	digest := h.Sum64()
+	if wk.state.Key != "" && wk.state.Deleted == deleted && (deleted || wk.digest == digest) {
+		return false
+	}
+
+	seq := wk.state.Seq
+	if wk.state.Key != "" {
+		seq++
+	}
+	_, raw := models.SplitNamespacedKey(key)
+	state := KeyChange{Key: raw, Seq: seq, Deleted: deleted, Time: now.Unix()}
+	if !deleted {
+		state.Value, state.Encoding = models.EncodeValue(value)
+	}
+	wk.state, wk.digest = state, digest
+	return true
+}
+
+// expire drops the registrations that lapsed before now; ws.mu must be
+// held
+func (ws *Watches) expire(now time.Time) {
+	for key, wk := range ws.keys {
+		for id, w := range wk.watchers {
+			if now.After(w.expires) {
+				ws.drop(key, wk, id)
+			}
+		}
+	}
+}
+
+// drop removes a registration, and the key once nobody watches it; ws.mu
+// must be held
+func (ws *Watches) drop(key string, wk *watchedKey, watcherID string) {
+	delete(wk.watchers, watcherID)
+	ws.count--
+	if len(wk.watchers) == 0 {
+		delete(ws.keys, key)
+	}
+}
+
+// WatchHandler handles /watch requests, registering or renewing a watch on
+// a key this node stores, or with cancel set dropping it
+func WatchHandler(w http.ResponseWriter, r *http.Request, node *models.Node, storage *models.KeyValueStore, watches *Watches) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if !node.Supports(models.FlagStorage) {
+		http.Error(w, "This node does not store values", http.StatusForbidden)
+		return
+	}
+
+	var req WatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	var query StoreQuery
+	if !decodeQuery(w, r, &query) {
+		return
+	}
+	if query.Hash && req.Key != "" {
+		req.Key = KeyFromString(req.Key)
+	}
+	if !validateBody(w, &req) {
+		return
+	}
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+	key := models.NamespacedKey(namespace, req.Key)
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Cancel {
+		watches.Unwatch(key, req.WatcherID)
+		json.NewEncoder(w).Encode(WatchReply{NodeID: node.ID, State: KeyChange{Key: req.Key}})
+		return
+	}
+	state, expires, err := watches.Watch(key, req.WatcherID, req.Webhook)
+	switch {
+	case errors.Is(err, ErrWebhookTarget):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WatchReply{NodeID: node.ID, State: state, ExpiresAt: &expires})
+}
+
+// WatchReply confirms a watch on the node NodeID, giving the key's state
+// when it was registered and when the registration lapses unless renewed
+type WatchReply struct {
+	NodeID    string     `json:"node_id"`
+	State     KeyChange  `json:"state"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Not set when cancelling
+}
+
+// WatchPollHandler handles /watch_poll long-poll requests, answering with
+// the KeyChange once the key's Seq is above since, 204 if it did not
+// change in time, or 404 if the watcher must register again
+func WatchPollHandler(w http.ResponseWriter, r *http.Request, storage *models.KeyValueStore, watches *Watches) {
+	req := WatchPollRequest{Timeout: DefaultWatchTimeout}
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	namespace, ok := resolveNamespace(w, r, storage)
+	if !ok {
+		return
+	}
+
+	change, changed, err := watches.Poll(r.Context(), models.NamespacedKey(namespace, req.Key), req.WatcherID, uint64(req.Since), time.Duration(req.Timeout)*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !changed {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
@@ -0,0 +1,183 @@
+package kademlia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Limits of webhook delivery
+const (
+	WebhookWorkers = 8               // POSTs a node has in flight at once
+	WebhookQueue   = 1024            // Notifications waiting for a worker, beyond which new ones are dropped
+	WebhookTimeout = 5 * time.Second // Deadline of each POST
+)
+
+// ErrWebhookTarget is returned for a webhook that is not an http(s) URL of
+// a public address
+var ErrWebhookTarget = errors.New("webhook target not allowed")
+
+// Webhooks POSTs the notifications of pub/sub subscribers and key watchers
+// to their webhooks from at most WebhookWorkers goroutines, dropping those
+// that find WebhookQueue notifications waiting. Webhooks are given by
+// clients, so unless AllowPrivate is set only public addresses are
+// reached: loopback, private, link-local and unspecified addresses are
+// refused when subscribing and again when dialing, so a name resolving to
+// one later is refused too, and redirects are not followed.
+type Webhooks struct {
+	allowPrivate bool
+	client       *http.Client
+	queue        chan webhookCall
+	workers      chan struct{} // Held by running workers
+	dropped      atomic.Uint64
+}
+
+type webhookCall struct {
+	target string
+	body   []byte
+}
+
+// NewWebhooks creates a Webhooks, reaching private addresses too if
+// allowPrivate is set
+func NewWebhooks(allowPrivate bool) *Webhooks {
+	wh := &Webhooks{
+		allowPrivate: allowPrivate,
+		queue:        make(chan webhookCall, WebhookQueue),
+		workers:      make(chan struct{}, WebhookWorkers),
+	}
+	dialer := &net.Dialer{Timeout: WebhookTimeout, Control: wh.control}
+	wh.client = &http.Client{
+		Timeout:   WebhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, MaxIdleConnsPerHost: WebhookWorkers},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return wh
+}
+
+// Check reports whether target may be notified: an http or https URL
+// whose host is, or resolves to, addresses Webhooks may reach
+func (wh *Webhooks) Check(ctx context.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return fmt.Errorf("%w: %q is not an http(s) URL", ErrWebhookTarget, target)
+	}
+	if wh.allowPrivate {
+		return nil
+	}
+	host := u.Hostname()
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrWebhookTarget, err)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return fmt.Errorf("%w: %s is not a public address", ErrWebhookTarget, ip)
+		}
+	}
+	return nil
+}
+
+// Notify queues payload, as JSON, to be POSTed to target, reporting false
+// if the queue is full and it was dropped
+func (wh *Webhooks) Notify(target string, payload interface{}) bool {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	select {
+	case wh.queue <- webhookCall{target: target, body: body}:
+	default:
+		wh.dropped.Add(1)
+		fmt.Printf("Dropped a notification to webhook %s: %d waiting\n", target, WebhookQueue)
+		return false
+	}
+	select {
+	case wh.workers <- struct{}{}:
+		go wh.work()
+	default:
+		// Every worker is busy and will pick the call up
+	}
+	return true
+}
+
+// Dropped returns the number of notifications dropped for a full queue
+func (wh *Webhooks) Dropped() uint64 {
+	return wh.dropped.Load()
+}
+
+// work POSTs queued calls until the queue is empty. A call queued while
+// the worker was leaving is picked up by it again unless another worker
+// took its place.
+func (wh *Webhooks) work() {
+	for {
+		for drained := false; !drained; {
+			select {
+			case call := <-wh.queue:
+				wh.post(call)
+			default:
+				drained = true
+			}
+		}
+		<-wh.workers
+		if len(wh.queue) == 0 {
+			return
+		}
+		select {
+		case wh.workers <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// post sends one call
+func (wh *Webhooks) post(call webhookCall) {
+	resp, err := wh.client.Post(call.target, "application/json", bytes.NewReader(call.body))
+	if err != nil {
+		fmt.Printf("Failed to notify webhook %s: %v\n", call.target, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// control refuses connections to addresses Webhooks may not reach, after
+// name resolution
+func (wh *Webhooks) control(network, address string, _ syscall.RawConn) error {
+	if wh.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrWebhookTarget, host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is a globally routable unicast address
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
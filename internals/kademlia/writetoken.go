@@ -0,0 +1,237 @@
+package kademlia
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// WriteTokenHeader carries the write token a node issues in its FIND_NODE
+// and FIND_VALUE replies. Nodes requiring write tokens accept a STORE only
+// from an address presenting a recent token they issued to it, as in
+// BitTorrent's get_peers/announce_peer, so a peer must have been reachable
+// at the address it writes from.
+const WriteTokenHeader = "X-Kademlia-Write-Token"
+
+// WriteTokenWindow is how often the token issued to an address changes.
+// A token stays valid for the window it was issued in and the next one.
+const WriteTokenWindow = 5 * time.Minute
+
+// ErrInvalidWriteToken is returned when a STORE to a node requiring write
+// tokens carries no valid token for the sender's address
+var ErrInvalidWriteToken = errors.New("missing or invalid write token")
+
+// writeTokenErrorCode is the ErrorReply code of ErrInvalidWriteToken
+const writeTokenErrorCode = "invalid_write_token"
+
+// writeTokenSecret keys the tokens issued by this process. Tokens do not
+// survive a restart; writers then fetch a fresh one.
+var writeTokenSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// IssueWriteToken returns the token the node nodeID currently issues to ip
+func IssueWriteToken(nodeID, ip string) string {
+	return writeToken(nodeID, ip, clock.Now().Unix()/int64(WriteTokenWindow.Seconds()))
+}
+
+// ValidWriteToken reports whether token was issued by nodeID to ip in the
+// current or the previous window
+func ValidWriteToken(nodeID, ip, token string) bool {
+	if token == "" {
+		return false
+	}
+	window := clock.Now().Unix() / int64(WriteTokenWindow.Seconds())
+	for _, w := range []int64{window, window - 1} {
+		if hmac.Equal([]byte(token), []byte(writeToken(nodeID, ip, w))) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeToken(nodeID, ip string, window int64) string {
+	mac := hmac.New(sha256.New, writeTokenSecret)
+	mac.Write([]byte(nodeID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(ip))
+	binary.Write(mac, binary.BigEndian, window)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// writeWriteToken sets the token issued to the sender of r
+func writeWriteToken(w http.ResponseWriter, r *http.Request, node *models.Node) {
+	w.Header().Set(WriteTokenHeader, IssueWriteToken(node.ID, remoteIP(r)))
+}
+
+// checkWriteToken reports whether a STORE carrying token may write to
+// storage: always when storage does not require tokens
+func checkWriteToken(r *http.Request, node *models.Node, storage *models.KeyValueStore, token string) bool {
+	return !storage.RequireWriteToken || ValidWriteToken(node.ID, remoteIP(r), token)
+}
+
+// remoteIP returns the IP the request came from
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// MaxWriteTokens bounds the write tokens a WriteTokens keeps
+const MaxWriteTokens = 4096
+
+// WriteTokens caches the latest write token each peer address issued to a
+// node, presented on its next write to that peer. A token is forgotten
+// after two windows, when its issuer stops accepting it, and beyond
+// MaxWriteTokens the oldest is forgotten first. A nil WriteTokens caches
+// nothing.
+type WriteTokens struct {
+	mu     sync.Mutex
+	tokens map[string]cachedToken // addr -> latest token
+}
+
+type cachedToken struct {
+	token    string
+	issuedAt time.Time
+}
+
+// NewWriteTokens creates an empty cache
+func NewWriteTokens() *WriteTokens {
+	return &WriteTokens{tokens: make(map[string]cachedToken)}
+}
+
+// Remember caches the write token in a reply from addr, if any
+func (wt *WriteTokens) Remember(addr string, header http.Header) {
+	token := header.Get(WriteTokenHeader)
+	if wt == nil || token == "" {
+		return
+	}
+	now := clock.Now()
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	if _, exists := wt.tokens[addr]; !exists && len(wt.tokens) >= MaxWriteTokens {
+		wt.prune(now)
+	}
+	wt.tokens[addr] = cachedToken{token: token, issuedAt: now}
+}
+
+// prune forgets expired tokens, and the oldest one if none had expired;
+// wt.mu must be held
+func (wt *WriteTokens) prune(now time.Time) {
+	oldest := ""
+	for addr, cached := range wt.tokens {
+		if now.Sub(cached.issuedAt) >= 2*WriteTokenWindow {
+			delete(wt.tokens, addr)
+			continue
+		}
+		if oldest == "" || cached.issuedAt.Before(wt.tokens[oldest].issuedAt) {
+			oldest = addr
+		}
+	}
+	if len(wt.tokens) >= MaxWriteTokens {
+		delete(wt.tokens, oldest)
+	}
+}
+
+// Get returns the write token addr last issued, "" if none is cached or
+// it expired
+func (wt *WriteTokens) Get(addr string) string {
+	if wt == nil {
+		return ""
+	}
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	cached, exists := wt.tokens[addr]
+	if !exists {
+		return ""
+	}
+	if clock.Now().Sub(cached.issuedAt) >= 2*WriteTokenWindow {
+		delete(wt.tokens, addr)
+		return ""
+	}
+	return cached.token
+}
+
+// Len returns the number of cached tokens
+func (wt *WriteTokens) Len() int {
+	if wt == nil {
+		return 0
+	}
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	return len(wt.tokens)
+}
+
+type writeTokensKey struct{}
+
+// WithWriteTokens returns a context under which RPCs cache the write tokens
+// peers issue in wt and present them on writes
+func WithWriteTokens(ctx context.Context, wt *WriteTokens) context.Context {
+	return context.WithValue(ctx, writeTokensKey{}, wt)
+}
+
+func writeTokensFrom(ctx context.Context) *WriteTokens {
+	wt, _ := ctx.Value(writeTokensKey{}).(*WriteTokens)
+	return wt
+}
+
+// withWriteTokens runs h with wt in every request's context, for handlers
+// that replicate writes
+func withWriteTokens(wt *WriteTokens, h http.Handler) http.Handler {
+	if wt == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(WithWriteTokens(r.Context(), wt)))
+	})
+}
+
+// fetchWriteToken asks peer for a fresh write token with a FIND_NODE for
+// key, caching and returning it
+func fetchWriteToken(ctx context.Context, peer *models.Node, key string) (string, error) {
+	addr := peerAddr(peer)
+	header, _, err := rpcGetRaw(ctx, addr, "/find_node?count=1&id="+key, nil)
+	if err != nil {
+		return "", err
+	}
+	writeTokensFrom(ctx).Remember(addr, header)
+	return header.Get(WriteTokenHeader), nil
+}
+
+// withWriteToken runs send presenting token, or if it is empty the write
+// token peer last issued, and if peer refuses a cached token runs it once
+// more with a fresh one fetched with a FIND_NODE for key
+func withWriteToken(ctx context.Context, peer *models.Node, key, token string, send func(token string) error) error {
+	presented := token
+	if presented == "" {
+		presented = writeTokensFrom(ctx).Get(peerAddr(peer))
+	}
+	err := send(presented)
+	if !errors.Is(err, ErrInvalidWriteToken) || token != "" {
+		return err
+	}
+
+	// The cached token expired or the peer restarted, so fetch a fresh one
+	fresh, fetchErr := fetchWriteToken(ctx, peer, key)
+	if fetchErr != nil || fresh == "" || fresh == presented {
+		return err
+	}
+	return send(fresh)
+}
@@ -0,0 +1,136 @@
+// Package krpc speaks the BitTorrent Mainline DHT KRPC protocol (BEP 5):
+// bencoded ping, find_node, get_peers and announce_peer messages over UDP.
+package krpc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// KRPC error codes defined by BEP 5
+const (
+	ErrGeneric       = 201
+	ErrServer        = 202
+	ErrProtocol      = 203
+	ErrMethodUnknown = 204
+)
+
+// BucketSize is the number of contacts per bucket and per reply used by the
+// Mainline DHT
+const BucketSize = 8
+
+// message is a KRPC message. Exactly one of A (query), R (response) or E
+// (error) is set, matching Y.
+type message struct {
+	T string        `bencode:"t"`
+	Y string        `bencode:"y"`
+	Q string        `bencode:"q,omitempty"`
+	A *arguments    `bencode:"a,omitempty"`
+	R *response     `bencode:"r,omitempty"`
+	E []interface{} `bencode:"e,omitempty"`
+}
+
+type arguments struct {
+	ID          string `bencode:"id"`
+	Target      string `bencode:"target,omitempty"`
+	InfoHash    string `bencode:"info_hash,omitempty"`
+	Port        int    `bencode:"port,omitempty"`
+	ImpliedPort int    `bencode:"implied_port,omitempty"`
+	Token       string `bencode:"token,omitempty"`
+}
+
+type response struct {
+	ID     string   `bencode:"id"`
+	Nodes  string   `bencode:"nodes,omitempty"`
+	Values []string `bencode:"values,omitempty"`
+	Token  string   `bencode:"token,omitempty"`
+}
+
+// Error is a KRPC error reply from a remote node
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("krpc error %d: %s", e.Code, e.Message)
+}
+
+func errorFromList(list []interface{}) *Error {
+	e := &Error{Code: ErrGeneric}
+	if len(list) > 0 {
+		if code, ok := list[0].(int64); ok {
+			e.Code = int(code)
+		}
+	}
+	if len(list) > 1 {
+		e.Message, _ = list[1].(string)
+	}
+	return e
+}
+
+// binaryID converts a 40 character hex node ID to its 20 byte wire form
+func binaryID(id string) (string, error) {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != 20 {
+		return "", fmt.Errorf("invalid node ID %q", id)
+	}
+	return string(raw), nil
+}
+
+// encodeNodes packs IPv4 contacts into BEP 5 compact node info: 20 byte ID,
+// 4 byte IP and 2 byte port each. Contacts without an IPv4 address are
+// skipped.
+func encodeNodes(nodes []*models.Node) string {
+	buf := make([]byte, 0, 26*len(nodes))
+	for _, n := range nodes {
+		id, err := binaryID(n.ID)
+		ip := net.ParseIP(n.IP).To4()
+		if err != nil || ip == nil {
+			continue
+		}
+		buf = append(buf, id...)
+		buf = append(buf, ip...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n.Port))
+	}
+	return string(buf)
+}
+
+// decodeNodes unpacks BEP 5 compact node info
+func decodeNodes(data string) ([]*models.Node, error) {
+	if len(data)%26 != 0 {
+		return nil, fmt.Errorf("compact node info length %d is not a multiple of 26", len(data))
+	}
+	nodes := make([]*models.Node, 0, len(data)/26)
+	for i := 0; i < len(data); i += 26 {
+		entry := []byte(data[i : i+26])
+		nodes = append(nodes, &models.Node{
+			ID:   hex.EncodeToString(entry[:20]),
+			IP:   net.IP(entry[20:24]).String(),
+			Port: int(binary.BigEndian.Uint16(entry[24:])),
+		})
+	}
+	return nodes, nil
+}
+
+// encodePeer packs an IPv4 peer into 6 byte compact peer info
+func encodePeer(ip net.IP, port int) (string, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return "", false
+	}
+	return string(binary.BigEndian.AppendUint16(append([]byte{}, ip4...), uint16(port))), true
+}
+
+// decodePeer unpacks 6 byte compact peer info into <ip>:<port>
+func decodePeer(data string) (string, error) {
+	if len(data) != 6 {
+		return "", fmt.Errorf("compact peer info has length %d, want 6", len(data))
+	}
+	port := binary.BigEndian.Uint16([]byte(data[4:]))
+	return net.JoinHostPort(net.IP([]byte(data[:4])).String(), fmt.Sprint(port)), nil
+}
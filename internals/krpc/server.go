@@ -0,0 +1,420 @@
+package krpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/bencode"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// tokenRotation is how often the announce token secret changes; tokens from
+// the previous secret are still accepted, as BEP 5 recommends
+const tokenRotation = 5 * time.Minute
+
+// Server is a Mainline DHT node. It keeps its own routing table of UDP
+// contacts and the peers announced to it for each info-hash.
+type Server struct {
+	ID           string // Hex node ID, shared with the HTTP node
+	RoutingTable *models.RoutingTable
+	Peers        *models.ProviderStore // hex info-hash -> announced peers
+
+	conn *net.UDPConn
+
+	mu         sync.Mutex              // guards RoutingTable and the fields below
+	pending    map[string]pendingQuery // transaction ID -> query awaiting its response
+	secret     []byte
+	prevSecret []byte
+	rotated    time.Time
+}
+
+// NewServer creates a Mainline DHT node with the given hex ID
+func NewServer(id string) *Server {
+	s := &Server{
+		ID:           id,
+		RoutingTable: kademlia.NewRoutingTableWithK(id, BucketSize),
+		Peers:        models.NewProviderStore(constants.DefaultProviderTTL, constants.DefaultMaxProvidersPerKey, constants.DefaultMaxProviderRecords),
+		pending:      make(map[string]pendingQuery),
+		secret:       newSecret(),
+		rotated:      time.Now(),
+	}
+	s.RoutingTable.Confirm = s.confirm
+	return s
+}
+
+// confirm pings n at its UDP address and checks that it answers as n, for
+// the routing table to follow contacts seen at a new address
+func (s *Server) confirm(ctx context.Context, n *models.Node) error {
+	id, err := s.Ping(ctx, &net.UDPAddr{IP: net.ParseIP(n.IP), Port: n.Port})
+	if err != nil {
+		return err
+	}
+	if id != n.ID {
+		return fmt.Errorf("%s:%d answered as %s, not %s", n.IP, n.Port, id, n.ID)
+	}
+	return nil
+}
+
+// Listen binds the UDP address and starts answering queries
+func (s *Server) Listen(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	s.conn = conn
+	go s.readLoop()
+	return nil
+}
+
+// Addr returns the bound UDP address
+func (s *Server) Addr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+// Bootstrap joins the DHT by looking up our own ID through each router,
+// such as router.bittorrent.com:6881. It fails only if no router answered.
+func (s *Server) Bootstrap(ctx context.Context, routers []string) error {
+	var lastErr error
+	joined := false
+	for _, router := range routers {
+		addr, err := net.ResolveUDPAddr("udp", router)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		nodes, err := s.FindNode(ctx, addr, s.ID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		joined = true
+		for _, n := range nodes {
+			s.addContact(n)
+		}
+	}
+	if !joined {
+		return fmt.Errorf("failed to bootstrap mainline DHT: %v", lastErr)
+	}
+	return nil
+}
+
+// Ping sends a ping query to addr and returns the remote node ID
+func (s *Server) Ping(ctx context.Context, addr *net.UDPAddr) (string, error) {
+	r, err := s.query(ctx, addr, "ping", &arguments{})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString([]byte(r.ID)), nil
+}
+
+// FindNode asks addr for the contacts closest to the hex target
+func (s *Server) FindNode(ctx context.Context, addr *net.UDPAddr, target string) ([]*models.Node, error) {
+	bin, err := binaryID(target)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.query(ctx, addr, "find_node", &arguments{Target: bin})
+	if err != nil {
+		return nil, err
+	}
+	return decodeNodes(r.Nodes)
+}
+
+// GetPeers asks addr for peers of the hex info-hash. It returns the peers
+// as <ip>:<port>, closer contacts when addr knows no peers, and the token
+// required to announce to addr.
+func (s *Server) GetPeers(ctx context.Context, addr *net.UDPAddr, infoHash string) ([]string, []*models.Node, string, error) {
+	bin, err := binaryID(infoHash)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	r, err := s.query(ctx, addr, "get_peers", &arguments{InfoHash: bin})
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var peers []string
+	for _, v := range r.Values {
+		if peer, err := decodePeer(v); err == nil {
+			peers = append(peers, peer)
+		}
+	}
+	nodes, err := decodeNodes(r.Nodes)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return peers, nodes, r.Token, nil
+}
+
+// AnnouncePeer tells addr that we serve the hex info-hash on port, using
+// the token from an earlier GetPeers
+func (s *Server) AnnouncePeer(ctx context.Context, addr *net.UDPAddr, infoHash string, port int, token string) error {
+	bin, err := binaryID(infoHash)
+	if err != nil {
+		return err
+	}
+	_, err = s.query(ctx, addr, "announce_peer", &arguments{InfoHash: bin, Port: port, Token: token})
+	return err
+}
+
+// query sends a query and waits for the matching response or ctx
+func (s *Server) query(ctx context.Context, addr *net.UDPAddr, method string, args *arguments) (*response, error) {
+	id, err := binaryID(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	args.ID = id
+
+	ctx, cancel := context.WithTimeout(ctx, constants.GetRPCTimeout())
+	defer cancel()
+
+	// A random transaction ID doubles as the echo token: a response forged
+	// from another address cannot guess it, so it cannot plant a contact
+	reply := make(chan *message, 1)
+	s.mu.Lock()
+	tid := newTID()
+	for _, taken := s.pending[tid]; taken; _, taken = s.pending[tid] {
+		tid = newTID()
+	}
+	s.pending[tid] = pendingQuery{addr: addr, reply: reply}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, tid)
+		s.mu.Unlock()
+	}()
+
+	if err := s.send(addr, &message{T: tid, Y: "q", Q: method, A: args}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s to %s: %v", method, addr, ctx.Err())
+	case msg := <-reply:
+		if msg.Y == "e" {
+			return nil, errorFromList(msg.E)
+		}
+		if msg.R == nil || len(msg.R.ID) != 20 {
+			return nil, fmt.Errorf("%s to %s: malformed response", method, addr)
+		}
+		s.addContact(&models.Node{ID: hex.EncodeToString([]byte(msg.R.ID)), IP: addr.IP.String(), Port: addr.Port})
+		return msg.R, nil
+	}
+}
+
+func (s *Server) send(addr *net.UDPAddr, msg *message) error {
+	data, err := bencode.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.WriteToUDP(data, addr)
+	return err
+}
+
+func (s *Server) readLoop() {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Mainline DHT read failed: %v", err)
+			}
+			return
+		}
+
+		var msg message
+		if err := bencode.Unmarshal(buf[:n], &msg); err != nil {
+			continue
+		}
+
+		switch msg.Y {
+		case "q":
+			s.handleQuery(addr, &msg)
+		case "r", "e":
+			s.mu.Lock()
+			query, ok := s.pending[msg.T]
+			s.mu.Unlock()
+			// Only the queried address may answer
+			if ok && query.addr.IP.Equal(addr.IP) && query.addr.Port == addr.Port {
+				select {
+				case query.reply <- &msg:
+				default: // duplicate reply
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) handleQuery(addr *net.UDPAddr, msg *message) {
+	if msg.A == nil || len(msg.A.ID) != 20 {
+		s.sendError(addr, msg.T, ErrProtocol, "missing or invalid id")
+		return
+	}
+	requester := hex.EncodeToString([]byte(msg.A.ID))
+	s.addContact(&models.Node{ID: requester, IP: addr.IP.String(), Port: addr.Port})
+
+	self, _ := binaryID(s.ID)
+	r := &response{ID: self}
+
+	switch msg.Q {
+	case "ping":
+	case "find_node":
+		if len(msg.A.Target) != 20 {
+			s.sendError(addr, msg.T, ErrProtocol, "invalid target")
+			return
+		}
+		r.Nodes = encodeNodes(s.closest(hex.EncodeToString([]byte(msg.A.Target)), requester))
+	case "get_peers":
+		if len(msg.A.InfoHash) != 20 {
+			s.sendError(addr, msg.T, ErrProtocol, "invalid info_hash")
+			return
+		}
+		infoHash := hex.EncodeToString([]byte(msg.A.InfoHash))
+		for _, p := range s.Peers.Get(infoHash) {
+			if v, ok := encodePeer(net.ParseIP(p.IP), p.Port); ok {
+				r.Values = append(r.Values, v)
+			}
+		}
+		if len(r.Values) == 0 {
+			r.Nodes = encodeNodes(s.closest(infoHash, requester))
+		}
+		r.Token = s.token(addr.IP, false)
+	case "announce_peer":
+		if len(msg.A.InfoHash) != 20 {
+			s.sendError(addr, msg.T, ErrProtocol, "invalid info_hash")
+			return
+		}
+		if msg.A.Token == "" || (msg.A.Token != s.token(addr.IP, false) && msg.A.Token != s.token(addr.IP, true)) {
+			s.sendError(addr, msg.T, ErrProtocol, "bad token")
+			return
+		}
+		port := msg.A.Port
+		if msg.A.ImpliedPort != 0 {
+			port = addr.Port
+		}
+		if port <= 0 || port > 65535 {
+			s.sendError(addr, msg.T, ErrProtocol, "invalid port")
+			return
+		}
+		peer := models.Node{ID: net.JoinHostPort(addr.IP.String(), fmt.Sprint(port)), IP: addr.IP.String(), Port: port}
+		if err := s.Peers.Add(hex.EncodeToString([]byte(msg.A.InfoHash)), peer); err != nil {
+			s.sendError(addr, msg.T, ErrServer, err.Error())
+			return
+		}
+	default:
+		s.sendError(addr, msg.T, ErrMethodUnknown, "method unknown")
+		return
+	}
+
+	s.send(addr, &message{T: msg.T, Y: "r", R: r})
+}
+
+func (s *Server) sendError(addr *net.UDPAddr, tid string, code int, text string) {
+	s.send(addr, &message{T: tid, Y: "e", E: []interface{}{code, text}})
+}
+
+func (s *Server) addContact(n *models.Node) {
+	if n.ID == s.ID {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kademlia.AddNodeToRoutingTable(s.RoutingTable, n, s.ID)
+}
+
+// closest returns up to BucketSize contacts closest to the hex target,
+// leaving out the hex ID exclude, which is the querying node
+func (s *Server) closest(target, exclude string) []*models.Node {
+	t, _ := hex.DecodeString(target)
+
+	s.mu.Lock()
+	var nodes []*models.Node
+	for _, n := range s.RoutingTable.Contacts() {
+		if n.ID != exclude {
+			nodes = append(nodes, n)
+		}
+	}
+	s.mu.Unlock()
+
+	distance := func(n *models.Node) []byte {
+		id, _ := hex.DecodeString(n.ID)
+		d := make([]byte, len(t))
+		for i := range d {
+			if i < len(id) {
+				d[i] = t[i] ^ id[i]
+			}
+		}
+		return d
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return string(distance(nodes[i])) < string(distance(nodes[j]))
+	})
+	if len(nodes) > BucketSize {
+		nodes = nodes[:BucketSize]
+	}
+	return nodes
+}
+
+// token returns the announce token for ip under the current or previous
+// secret, rotating the secret when it is due
+func (s *Server) token(ip net.IP, previous bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.rotated) > tokenRotation {
+		s.prevSecret = s.secret
+		s.secret = newSecret()
+		s.rotated = time.Now()
+	}
+
+	secret := s.secret
+	if previous {
+		if s.prevSecret == nil {
+			return ""
+		}
+		secret = s.prevSecret
+	}
+	sum := sha1.Sum(append(append([]byte{}, secret...), ip...))
+	return string(sum[:8])
+}
+
+// pendingQuery is a query sent to addr whose response is delivered on reply
+type pendingQuery struct {
+	addr  *net.UDPAddr
+	reply chan *message
+}
+
+// newTID returns a random 4-byte transaction ID
+func newTID() string {
+	tid := make([]byte, 4)
+	rand.Read(tid)
+	return string(tid)
+}
+
+func newSecret() []byte {
+	secret := make([]byte, 16)
+	rand.Read(secret)
+	return secret
+}
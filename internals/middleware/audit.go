@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+)
+
+// AuditEntry is one line of the audit log. It records who called which
+// RPC and how it went, never the keys' values or other request bodies.
+type AuditEntry struct {
+	Time          time.Time `json:"time"`
+	RequestID     string    `json:"request_id,omitempty"`
+	RPC           string    `json:"rpc"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"` // Without the query, which may carry tokens
+	Remote        string    `json:"remote"`
+	Status        int       `json:"status"`
+	DurationMs    float64   `json:"duration_ms"`
+	RequestBytes  int64     `json:"request_bytes"` // Declared body size, -1 if unknown
+	ResponseBytes int64     `json:"response_bytes"`
+}
+
+// AuditLog writes AuditEntry lines to a file, rotating it when it would
+// grow past maxBytes: path is renamed to path.1, path.1 to path.2 and so
+// on, keeping at most backups old files.
+type AuditLog struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenAuditLog opens the audit log at path, appending to it if it exists.
+// maxBytes 0 disables rotation.
+func OpenAuditLog(path string, maxBytes int64, backups int) (*AuditLog, error) {
+	a := &AuditLog{path: path, maxBytes: maxBytes, backups: backups}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditLog) open() error {
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	a.file, a.size = file, info.Size()
+	return nil
+}
+
+// Record appends entry to the log
+func (a *AuditLog) Record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return os.ErrClosed
+	}
+	if a.maxBytes > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+// rotate shifts the backups and starts a new file; a.mu must be held
+func (a *AuditLog) rotate() error {
+	a.file.Close()
+	a.file = nil
+	if a.backups > 0 {
+		for i := a.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		}
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %v", err)
+		}
+	} else if err := os.Remove(a.path); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %v", err)
+	}
+	return a.open()
+}
+
+// Close closes the log; later entries are dropped
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// Middleware records every call to the wrapped RPC in the audit log.
+// Failures to write the log never fail the RPC.
+func (a *AuditLog) Middleware() Middleware {
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+
+			a.Record(AuditEntry{
+				Time:          start.UTC(),
+				RequestID:     tracing.RequestID(r.Context()),
+				RPC:           rpc,
+				Method:        r.Method,
+				Path:          r.URL.Path,
+				Remote:        r.RemoteAddr,
+				Status:        rec.status,
+				DurationMs:    float64(time.Since(start)) / float64(time.Millisecond),
+				RequestBytes:  r.ContentLength,
+				ResponseBytes: rec.bytes,
+			})
+		}
+	}
+}
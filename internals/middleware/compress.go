@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// Compress gzips responses of at least cfg.CompressMinBytes for callers
+// that send "Accept-Encoding: gzip", such as find_node replies carrying k
+// contacts or admin exports. Smaller responses, responses that already
+// have a Content-Encoding and connection upgrades are sent as is. next is
+// returned unchanged when cfg.Compression is off.
+func Compress(cfg config.ServerConfig, next http.Handler) http.Handler {
+	if !cfg.Compression {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		cw := &compressWriter{ResponseWriter: w, minBytes: cfg.CompressMinBytes, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.close()
+	})
+}
+
+// acceptsGzip reports whether r's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// compressWriter holds back the start of a response until it is known to
+// be at least minBytes long, then sends it gzipped
+type compressWriter struct {
+	http.ResponseWriter
+	minBytes int
+
+	status   int
+	pending  []byte
+	gz       *gzip.Writer
+	decided  bool // Whether the response is being sent, compressed or not
+	hijacked bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.decided {
+		return
+	}
+	if status < http.StatusOK {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	c.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		c.start(false)
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.decided {
+		c.pending = append(c.pending, b...)
+		if len(c.pending) < c.minBytes {
+			return len(b), nil
+		}
+		if err := c.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if c.gz != nil {
+		return c.gz.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// start sends the header and any pending body, gzipped if compress is set
+// and the handler did not encode the response itself
+func (c *compressWriter) start(compress bool) error {
+	c.decided = true
+	header := c.ResponseWriter.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		c.gz = gzipWriters.Get().(*gzip.Writer)
+		c.gz.Reset(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+
+	pending := c.pending
+	c.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	var err error
+	if c.gz != nil {
+		_, err = c.gz.Write(pending)
+	} else {
+		_, err = c.ResponseWriter.Write(pending)
+	}
+	return err
+}
+
+// Flush sends what has been written so far, compressing it if the
+// response was already large enough
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		c.start(len(c.pending) >= c.minBytes && len(c.pending) > 0)
+	}
+	if c.gz != nil {
+		c.gz.Flush()
+	}
+	http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection over uncompressed
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(c.ResponseWriter).Hijack()
+	if err == nil {
+		c.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (c *compressWriter) close() {
+	if c.hijacked {
+		return
+	}
+	if !c.decided {
+		c.start(false)
+	}
+	if c.gz != nil {
+		c.gz.Close()
+		c.gz.Reset(nil)
+		gzipWriters.Put(c.gz)
+		c.gz = nil
+	}
+}
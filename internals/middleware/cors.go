@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight
+const corsMaxAge = "600"
+
+// CORS lets web pages from cfg.CORSOrigins call the RPCs, so a node can
+// serve as the gateway of browser clients such as the WebAssembly build
+// of pkg/client. Preflight requests are answered here, before limits and
+// authentication, since browsers send them without credentials. next is
+// returned unchanged when no origins are configured.
+func CORS(cfg config.ServerConfig, next http.Handler) http.Handler {
+	if len(cfg.CORSOrigins) == 0 {
+		return next
+	}
+	allowed := make(map[string]bool, len(cfg.CORSOrigins))
+	for _, origin := range cfg.CORSOrigins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+		// Let scripts read the responder and request ID headers
+		header.Set("Access-Control-Expose-Headers", "*")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
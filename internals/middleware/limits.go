@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// Limits wraps a node's whole HTTP handler with the request limits of cfg,
+// so a single client cannot exhaust the node. Requests beyond
+// MaxConcurrent in flight are answered 503 and those beyond MaxPerIP in
+// flight from one caller IP 429, both with "Retry-After: 1". Bodies larger
+// than MaxBodyBytes are answered 413, or fail to read past the limit when
+// their length is not declared. A zero limit disables that check.
+func Limits(cfg config.ServerConfig, next http.Handler) http.Handler {
+	if cfg.MaxConcurrent <= 0 && cfg.MaxPerIP <= 0 && cfg.MaxBodyBytes <= 0 {
+		return next
+	}
+
+	var slots chan struct{}
+	if cfg.MaxConcurrent > 0 {
+		slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	perIP := &ipLimiter{max: cfg.MaxPerIP, inFlight: make(map[string]int)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Server busy", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		if cfg.MaxPerIP > 0 {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			if !perIP.acquire(ip) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many concurrent requests", http.StatusTooManyRequests)
+				return
+			}
+			defer perIP.release(ip)
+		}
+
+		if cfg.MaxBodyBytes > 0 {
+			if r.ContentLength > cfg.MaxBodyBytes {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ipLimiter counts the requests in flight from each caller IP
+type ipLimiter struct {
+	max int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func (l *ipLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[ip] >= l.max {
+		return false
+	}
+	l.inFlight[ip]++
+	return true
+}
+
+func (l *ipLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[ip]--; l.inFlight[ip] <= 0 {
+		delete(l.inFlight, ip)
+	}
+}
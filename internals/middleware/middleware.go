@@ -0,0 +1,344 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// Middleware wraps the handler of the RPC named rpc
+type Middleware func(rpc string, next http.HandlerFunc) http.HandlerFunc
+
+// Chain composes mws into one middleware; the first runs outermost
+func Chain(mws ...Middleware) Middleware {
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](rpc, next)
+		}
+		return next
+	}
+}
+
+// Default returns the standard chain configured by cfg: recovery, logging,
+// metrics, and rate limiting and authentication when enabled
+func Default(cfg config.ServerConfig, metrics *Metrics) []Middleware {
+	mws := []Middleware{Recovery(metrics), Logging(), metrics.Middleware()}
+	if cfg.RateLimit > 0 {
+		mws = append(mws, RateLimit(cfg.RateLimit, cfg.RateBurst))
+	}
+	if cfg.AuthToken != "" {
+		mws = append(mws, Auth(cfg.AuthToken))
+	}
+	return mws
+}
+
+// Recovery turns a panicking handler into a structured 500 response
+// instead of a dropped connection, logging the panic with its stack and
+// counting it in metrics, which may be nil
+func Recovery(metrics *Metrics) Middleware {
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				requestID := tracing.RequestID(r.Context())
+				log.Printf("[%s] %s panicked: %v\n%s", requestID, rpc, p, debug.Stack())
+				if metrics != nil {
+					metrics.recordPanic(rpc, time.Since(start))
+				}
+				if rec.wrote {
+					// Too late for an error response; the client sees a
+					// truncated reply instead
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":      "internal",
+					"message":    "internal server error",
+					"request_id": requestID,
+				})
+			}()
+			next(rec, r)
+		}
+	}
+}
+
+// Logging logs each RPC with its request ID, caller, status and duration
+func Logging() Middleware {
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+			log.Printf("[%s] %s from %s: %d in %v", tracing.RequestID(r.Context()), rpc, r.RemoteAddr, rec.status, time.Since(start))
+		}
+	}
+}
+
+// Auth rejects RPCs that do not carry "Authorization: Bearer <token>"
+func Auth(token string) Middleware {
+	want := []byte("Bearer " + token)
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="kademlia"`)
+				http.Error(w, "Missing or invalid authorization token", http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// AdminTokenHeader carries the admin token required by admin endpoints
+const AdminTokenHeader = "X-Kademlia-Admin-Token"
+
+// Admin rejects requests that do not carry the admin token in
+// AdminTokenHeader. It guards the admin endpoints on top of Auth, whose
+// token every node of the network shares.
+func Admin(token string) Middleware {
+	want := []byte(token)
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(AdminTokenHeader)), want) != 1 {
+				http.Error(w, "Missing or invalid admin token", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// APIKeyHeader carries the API key gateway clients authenticate with
+const APIKeyHeader = "X-API-Key"
+
+// APIKey rejects requests that do not carry one of keys in APIKeyHeader.
+// It guards the REST gateway, whose clients are not network members and
+// so do not hold the Auth token. With no keys every request is let through.
+func APIKey(keys []string) Middleware {
+	var wants [][]byte
+	for _, key := range keys {
+		if key != "" {
+			wants = append(wants, []byte(key))
+		}
+	}
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if len(wants) > 0 {
+				got := []byte(r.Header.Get(APIKeyHeader))
+				valid := 0
+				for _, want := range wants {
+					valid |= subtle.ConstantTimeCompare(got, want)
+				}
+				if valid != 1 {
+					http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+					return
+				}
+			}
+			next(w, r)
+		}
+	}
+}
+
+// RateLimit allows each caller IP perSecond RPCs on average with bursts of
+// up to burst, answering 429 beyond that. A burst below 1 is treated as 1.
+func RateLimit(perSecond float64, burst int) Middleware {
+	if burst < 1 {
+		burst = 1
+	}
+	limiter := &rateLimiter{rate: perSecond, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			if wait, ok := limiter.allow(ip, time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	rate, burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// allow takes a token from the bucket of key, returning how long to wait
+// for the next token if there is none
+func (l *rateLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		// Forget callers idle long enough to have a full bucket again
+		if len(l.buckets) >= 10000 {
+			for k, old := range l.buckets {
+				if now.Sub(old.last).Seconds()*l.rate >= l.burst {
+					delete(l.buckets, k)
+				}
+			}
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// RPCStats summarizes the calls to one RPC
+type RPCStats struct {
+	Requests       int64   `json:"requests"`
+	ClientErrors   int64   `json:"client_errors"` // 4xx responses
+	ServerErrors   int64   `json:"server_errors"` // 5xx responses, including panics
+	Panics         int64   `json:"panics"`        // Handler panics recovered
+	TotalLatencyMs float64 `json:"total_latency_ms"`
+}
+
+// Metrics counts requests, errors and latency per RPC, and keeps the
+// latest runtime sample
+type Metrics struct {
+	mu      sync.Mutex
+	stats   map[string]*RPCStats
+	runtime RuntimeStats
+}
+
+// NewMetrics creates an empty set of RPC metrics
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[string]*RPCStats)}
+}
+
+// Middleware records every call to the wrapped RPC in m
+func (m *Metrics) Middleware() Middleware {
+	return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+			m.record(rpc, rec.status, time.Since(start))
+		}
+	}
+}
+
+func (m *Metrics) record(rpc string, status int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.statsOf(rpc)
+	s.Requests++
+	switch {
+	case status >= 500:
+		s.ServerErrors++
+	case status >= 400:
+		s.ClientErrors++
+	}
+	s.TotalLatencyMs += float64(latency) / float64(time.Millisecond)
+}
+
+// recordPanic counts a call to rpc that panicked, which never reaches
+// record since the panic unwinds past the metrics middleware
+func (m *Metrics) recordPanic(rpc string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.statsOf(rpc)
+	s.Requests++
+	s.ServerErrors++
+	s.Panics++
+	s.TotalLatencyMs += float64(latency) / float64(time.Millisecond)
+}
+
+// statsOf returns the stats of rpc, creating them on first use. m.mu must
+// be held.
+func (m *Metrics) statsOf(rpc string) *RPCStats {
+	s, ok := m.stats[rpc]
+	if !ok {
+		s = &RPCStats{}
+		m.stats[rpc] = s
+	}
+	return s
+}
+
+// Snapshot returns a copy of the metrics keyed by RPC name
+func (m *Metrics) Snapshot() map[string]RPCStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]RPCStats, len(m.stats))
+	for rpc, s := range m.stats {
+		out[rpc] = *s
+	}
+	return out
+}
+
+// Handler serves the metrics snapshot as JSON
+func (m *Metrics) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.Snapshot())
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	wrote  bool  // Whether the status line has been sent
+	bytes  int64 // Body bytes written
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status = status
+		r.wrote = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// hijack the connection
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// RuntimeSampleInterval is how often a node's scheduler records runtime
+// stats
+const RuntimeSampleInterval = 10 * time.Second
+
+// RuntimeStats is a sample of the Go runtime's goroutine, heap and GC
+// counters
+type RuntimeStats struct {
+	SampledAt      time.Time `json:"sampled_at"`
+	Goroutines     int       `json:"goroutines"`
+	HeapAllocBytes uint64    `json:"heap_alloc_bytes"` // Bytes of live and not yet collected heap objects
+	HeapSysBytes   uint64    `json:"heap_sys_bytes"`   // Bytes of heap memory obtained from the OS
+	HeapObjects    uint64    `json:"heap_objects"`
+	NumGC          uint32    `json:"num_gc"`
+	GCPauseTotalMs float64   `json:"gc_pause_total_ms"`
+}
+
+// SampleRuntime records the current runtime stats and returns them
+func (m *Metrics) SampleRuntime() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := RuntimeStats{
+		SampledAt:      clock.Now(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapSysBytes:   mem.HeapSys,
+		HeapObjects:    mem.HeapObjects,
+		NumGC:          mem.NumGC,
+		GCPauseTotalMs: float64(mem.PauseTotalNs) / float64(time.Millisecond),
+	}
+
+	m.mu.Lock()
+	m.runtime = stats
+	m.mu.Unlock()
+	return stats
+}
+
+// Runtime returns the latest runtime sample, taking one if there is none
+func (m *Metrics) Runtime() RuntimeStats {
+	m.mu.Lock()
+	stats := m.runtime
+	m.mu.Unlock()
+	if stats.SampledAt.IsZero() {
+		return m.SampleRuntime()
+	}
+	return stats
+}
+
+// RuntimeHandler serves the latest runtime sample as JSON
+func (m *Metrics) RuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.Runtime())
+}
+
+// RegisterProfiling serves the net/http/pprof profiles under /debug/pprof/
+// on mux, each run through mws so the auth token and rate limit apply
+func RegisterProfiling(mux *http.ServeMux, mws ...Middleware) {
+	chain := Chain(mws...)
+	mux.HandleFunc("/debug/pprof/", chain("pprof", pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", chain("pprof_cmdline", pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", chain("pprof_profile", pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", chain("pprof_symbol", pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", chain("pprof_trace", pprof.Trace))
+}
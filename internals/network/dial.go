@@ -0,0 +1,17 @@
+//go:build !js
+
+package network
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// dialContext returns the dialer of the pooled transport's connections
+func dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+}
@@ -0,0 +1,12 @@
+package network
+
+import (
+	"context"
+	"net"
+)
+
+// dialContext returns nil under WebAssembly, where browsers allow no raw
+// sockets: a transport without a dialer sends requests with the Fetch API
+func dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return nil
+}
@@ -1 +1,120 @@
 package network
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// PoolStats is a snapshot of the shared connection pool's activity
+type PoolStats struct {
+	Requests     int64 `json:"requests"`      // Outbound requests issued
+	InFlight     int64 `json:"in_flight"`     // Requests currently awaiting a response
+	ConnsCreated int64 `json:"conns_created"` // Requests that had to dial a new connection
+	ConnsReused  int64 `json:"conns_reused"`  // Requests served by a pooled keep-alive connection
+	Errors       int64 `json:"errors"`        // Requests that failed at the transport level
+}
+
+var (
+	mu     sync.RWMutex
+	client = newClient(config.Default().Pool)
+
+	requests     atomic.Int64
+	inFlight     atomic.Int64
+	connsCreated atomic.Int64
+	connsReused  atomic.Int64
+	errorCount   atomic.Int64
+
+	authToken atomic.Value // string sent as a bearer token on every RPC
+)
+
+// Client returns the shared HTTP client used for all outbound RPCs. It
+// keeps connections alive between calls and carries trace context.
+func Client() *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return client
+}
+
+// Configure replaces the shared client with one tuned by cfg. It should be
+// called once at startup, before any RPCs are made.
+func Configure(cfg config.PoolConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	client.CloseIdleConnections()
+	client = newClient(cfg)
+}
+
+// SetAuthToken makes every outbound RPC carry token as a bearer token, for
+// networks whose nodes require one. An empty token sends none.
+func SetAuthToken(token string) {
+	authToken.Store(token)
+}
+
+// AuthToken returns the token set by SetAuthToken, for connections made
+// outside the shared client
+func AuthToken() string {
+	token, _ := authToken.Load().(string)
+	return token
+}
+
+// Stats returns a snapshot of the connection pool metrics
+func Stats() PoolStats {
+	return PoolStats{
+		Requests:     requests.Load(),
+		InFlight:     inFlight.Load(),
+		ConnsCreated: connsCreated.Load(),
+		ConnsReused:  connsReused.Load(),
+		Errors:       errorCount.Load(),
+	}
+}
+
+func newClient(cfg config.PoolConfig) *http.Client {
+	pooled := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialContext(),
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		ForceAttemptHTTP2:   true,
+		DisableCompression:  !cfg.Compression,
+	}
+	return &http.Client{Transport: tracing.NewTransport(&metricsTransport{base: pooled})}
+}
+
+// metricsTransport records pool usage for every request it forwards
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requests.Add(1)
+	inFlight.Add(1)
+	defer inFlight.Add(-1)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				connsReused.Add(1)
+			} else {
+				connsCreated.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	if token := AuthToken(); token != "" && req.Header.Get("Authorization") == "" {
+		req.Header = req.Header.Clone()
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		errorCount.Add(1)
+	}
+	return resp, err
+}
@@ -0,0 +1,290 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GenerateClient returns the gofmt'ed source of a Go client for doc in
+// package pkg. It declares a type per component schema, a query type per
+// operation with query parameters, and a method per operation on a Client
+// type the package provides, with this method:
+//
+//	do(ctx context.Context, method, path string, query url.Values, body interface{}, contentType string, out interface{}) error
+//
+// do sends body as JSON if contentType is "application/json" and as the
+// io.Reader it is otherwise, and decodes the response into out, which is
+// nil, a *[]byte taking a raw response, or a pointer to decode JSON into.
+func GenerateClient(doc *Document, pkg, tool string) ([]byte, error) {
+	g := &generator{doc: doc}
+	fmt.Fprintf(&g.buf, "// Code generated by %s from the OpenAPI document of %s %s. DO NOT EDIT.\n\n", tool, doc.Info.Title, doc.Info.Version)
+	fmt.Fprintf(&g.buf, "package %s\n\n", pkg)
+	g.buf.WriteString("import (\n")
+	importsAt := g.buf.Len()
+	g.buf.WriteString(")\n")
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		g.structType(name, doc.Components.Schemas[name])
+	}
+	for _, path := range sortedKeys(doc.Paths) {
+		for _, method := range sortedKeys(doc.Paths[path]) {
+			if err := g.operation(strings.ToUpper(method), path, doc.Paths[path][method]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Import only the packages the generated code uses
+	src := g.buf.Bytes()
+	var imports bytes.Buffer
+	for _, p := range []string{"context", "encoding/json", "io", "net/url", "strconv", "time"} {
+		if bytes.Contains(src[importsAt:], []byte(p[strings.LastIndex(p, "/")+1:]+".")) {
+			fmt.Fprintf(&imports, "\t%q\n", p)
+		}
+	}
+	src = append(append(append([]byte{}, src[:importsAt]...), imports.Bytes()...), src[importsAt:]...)
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: generated invalid code: %v", err)
+	}
+	return formatted, nil
+}
+
+type generator struct {
+	doc *Document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// structType declares the Go type of the component schema name
+func (g *generator) structType(name string, schema *Schema) {
+	g.printf("\n// %s mirrors the %s schema\n", name, name)
+	if schema.Type != "object" || schema.Properties == nil {
+		g.printf("type %s %s\n", name, g.goType(schema, true))
+		return
+	}
+
+	required := make(map[string]bool)
+	for _, prop := range schema.Required {
+		required[prop] = true
+	}
+	g.printf("type %s struct {\n", name)
+	for _, prop := range sortedKeys(schema.Properties) {
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		g.printf("\t%s %s `json:%q`\n", GoName(prop), g.goType(schema.Properties[prop], required[prop]), tag)
+	}
+	g.printf("}\n")
+}
+
+// goType returns the Go type of values of schema. Optional references are
+// pointers, so that they can be left out.
+func (g *generator) goType(schema *Schema, required bool) string {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		if !required {
+			return "*" + name
+		}
+		return name
+	}
+
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if schema.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(schema.Items, true)
+	case "object":
+		if schema.AdditionalProperties != nil {
+			return "map[string]" + g.goType(schema.AdditionalProperties, true)
+		}
+	}
+	return "json.RawMessage"
+}
+
+// operation declares the method calling op, and the type of its query
+// parameters if it has any
+func (g *generator) operation(method, path string, op *Operation) error {
+	name := GoName(op.OperationID)
+
+	args := []string{"ctx context.Context"}
+	var query []Parameter
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			args = append(args, GoArg(param.Name)+" string")
+		case "query":
+			query = append(query, param)
+		default:
+			return fmt.Errorf("openapi: %s: unsupported parameter location %q", op.OperationID, param.In)
+		}
+	}
+
+	// Build the path from literals and escaped path parameters
+	var pathParts []string
+	literal := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		literal += "/"
+		if strings.HasPrefix(segment, "{") {
+			pathParts = append(pathParts, strconv.Quote(literal), "url.PathEscape("+GoArg(strings.Trim(segment, "{}"))+")")
+			literal = ""
+		} else {
+			literal += segment
+		}
+	}
+	if literal != "" {
+		pathParts = append(pathParts, strconv.Quote(literal))
+	}
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		g.queryType(name, query)
+		args = append(args, "query "+name+"Query")
+		queryArg = "query.values()"
+	}
+
+	bodyArg, contentType := "nil", `""`
+	if op.RequestBody != nil {
+		ct := sortedKeys(op.RequestBody.Content)[0]
+		if ct == "application/json" {
+			args = append(args, "body "+g.goType(op.RequestBody.Content[ct].Schema, true))
+		} else {
+			args = append(args, "body io.Reader")
+		}
+		bodyArg, contentType = "body", strconv.Quote(ct)
+	}
+
+	// The first success response decides the result type
+	result := ""
+	response := op.Responses[sortedKeys(op.Responses)[0]]
+	if len(response.Content) > 0 {
+		ct := sortedKeys(response.Content)[0]
+		if ct == "application/json" {
+			result = g.goType(response.Content[ct].Schema, true)
+		} else {
+			result = "[]byte"
+		}
+	}
+
+	g.printf("\n// %s calls %s %s", name, method, path)
+	if op.Summary != "" {
+		g.printf(":\n// %s", op.Summary)
+	}
+	g.printf("\n")
+	if result == "" {
+		g.printf("func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+		g.printf("\treturn c.do(ctx, %q, %s, %s, %s, %s, nil)\n}\n", method, strings.Join(pathParts, "+"), queryArg, bodyArg, contentType)
+		return nil
+	}
+	g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), result)
+	g.printf("\tvar out %s\n", result)
+	g.printf("\terr := c.do(ctx, %q, %s, %s, %s, %s, &out)\n", method, strings.Join(pathParts, "+"), queryArg, bodyArg, contentType)
+	g.printf("\treturn out, err\n}\n")
+	return nil
+}
+
+// queryType declares the query parameters of the operation name. Zero
+// values are left out of the request, leaving the node's defaults, so
+// booleans are pointers to allow sending false.
+func (g *generator) queryType(name string, params []Parameter) {
+	g.printf("\n// %sQuery holds the query parameters of %s. Zero values are left\n// out, so the node's defaults apply.\n", name, name)
+	g.printf("type %sQuery struct {\n", name)
+	for _, param := range params {
+		typ := g.goType(param.Schema, true)
+		if typ == "bool" {
+			typ = "*bool"
+		}
+		var notes []string
+		if param.Required {
+			notes = append(notes, "required")
+		}
+		if param.Schema.Default != nil {
+			notes = append(notes, fmt.Sprintf("default %v", param.Schema.Default))
+		}
+		if len(notes) > 0 {
+			g.printf("\t%s %s // %s\n", GoName(param.Name), typ, strings.Join(notes, ", "))
+		} else {
+			g.printf("\t%s %s\n", GoName(param.Name), typ)
+		}
+	}
+	g.printf("}\n\n")
+
+	g.printf("func (q %sQuery) values() url.Values {\n\tv := url.Values{}\n", name)
+	for _, param := range params {
+		field := "q." + GoName(param.Name)
+		switch g.goType(param.Schema, true) {
+		case "bool":
+			g.printf("\tif %s != nil {\n\t\tv.Set(%q, strconv.FormatBool(*%s))\n\t}\n", field, param.Name, field)
+		case "int":
+			g.printf("\tif %s != 0 {\n\t\tv.Set(%q, strconv.Itoa(%s))\n\t}\n", field, param.Name, field)
+		case "int64":
+			g.printf("\tif %s != 0 {\n\t\tv.Set(%q, strconv.FormatInt(%s, 10))\n\t}\n", field, param.Name, field)
+		default:
+			g.printf("\tif %s != \"\" {\n\t\tv.Set(%q, %s)\n\t}\n", field, param.Name, field)
+		}
+	}
+	g.printf("\treturn v\n}\n")
+}
+
+// initialisms are words spelled differently in Go names
+var initialisms = map[string]string{
+	"api": "API", "gc": "GC", "http": "HTTP", "id": "ID", "ip": "IP",
+	"json": "JSON", "openapi": "OpenAPI", "rpc": "RPC", "ttl": "TTL",
+	"udp": "UDP", "url": "URL",
+}
+
+// GoName converts a snake_case or Go-style name into an exported Go name,
+// as in "node_id" to "NodeID"
+func GoName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		if word, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(word)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// GoArg converts a name into an unexported Go name
+func GoArg(name string) string {
+	exported := GoName(name)
+	if strings.ToUpper(exported) == exported {
+		return strings.ToLower(exported)
+	}
+	return strings.ToLower(exported[:1]) + exported[1:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,424 @@
+// Package openapi describes an HTTP API as an OpenAPI 3 document built
+// from annotations in code. Each operation is declared as an Endpoint
+// naming the Go types of its query parameters, body and response; their
+// schemas are derived from the same struct tags that decode and validate
+// requests, so the document cannot drift from the handlers:
+//
+//	json:"name,omitempty"   property name, and whether it may be left out
+//	param:"name"            query parameter name
+//	validate:"..."          required, id, port, min=N and max=N, as in
+//	                        package validators
+//
+// GenerateClient turns a document into the source of a Go client.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version documents are written in
+const Version = "3.0.3"
+
+// IDPattern matches the 160-bit hex IDs accepted by the "id" rule
+const IDPattern = "^[0-9a-fA-F]{40}$"
+
+// Document is an OpenAPI document. Maps are encoded with sorted keys, so
+// the same endpoints always give the same JSON.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	Tags       []Tag               `json:"tags,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations on one path by lowercase HTTP method
+type PathItem map[string]*Operation
+
+// Operation is one method on one path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a query or path parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation by content type
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one response of an operation by content type
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema the Go types of the API need. A
+// schema with no type accepts any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// Components holds the named schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes a way requests authenticate
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// OneOf is an Endpoint response that may take the shape of any of its
+// values' types
+type OneOf []interface{}
+
+// Endpoint annotates one operation. Query, Body and Response are values of
+// the Go types involved, typically zero values; they are only inspected.
+type Endpoint struct {
+	Method      string // HTTP method
+	Path        string // Path, with {name} segments for path parameters
+	OperationID string // snake_case name, unique in the document
+	Summary     string
+	Tag         string
+	Security    []string // Security schemes required together, if any
+
+	Query    interface{} // Struct whose "param" tagged fields are the query parameters
+	Defaults interface{} // Query struct holding the handler's defaults, if any
+
+	Body        interface{} // JSON request body, or nil
+	BodyType    string      // Content type of a raw request body, sent as is
+	Response    interface{} // JSON response, a OneOf, or nil
+	RawResponse string      // Content type of a raw response returned as is
+	Status      int         // Success status, http.StatusOK if 0
+}
+
+// New builds the document of endpoints. It panics on endpoints that
+// cannot be described, such as a duplicate operation ID, since those are
+// programming errors.
+func New(info Info, tags []Tag, schemes map[string]SecurityScheme, endpoints []Endpoint) *Document {
+	b := &builder{
+		names:   make(map[reflect.Type]string),
+		schemas: make(map[string]*Schema),
+	}
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: b.schemas, SecuritySchemes: schemes},
+		Tags:       tags,
+	}
+
+	seen := make(map[string]bool)
+	for i := range endpoints {
+		ep := &endpoints[i]
+		if seen[ep.OperationID] {
+			panic(fmt.Sprintf("openapi: duplicate operation %q", ep.OperationID))
+		}
+		seen[ep.OperationID] = true
+
+		item := doc.Paths[ep.Path]
+		if item == nil {
+			item = make(PathItem)
+			doc.Paths[ep.Path] = item
+		}
+		item[strings.ToLower(ep.Method)] = b.operation(ep)
+	}
+	return doc
+}
+
+// JSON encodes the document, indented
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Handler serves the document as JSON
+func (d *Document) Handler() http.HandlerFunc {
+	data, err := d.JSON()
+	if err != nil {
+		panic(fmt.Sprintf("openapi: %v", err))
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+// builder names the struct types it meets as component schemas
+type builder struct {
+	names   map[reflect.Type]string
+	schemas map[string]*Schema
+}
+
+func (b *builder) operation(ep *Endpoint) *Operation {
+	op := &Operation{
+		OperationID: ep.OperationID,
+		Summary:     ep.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if ep.Tag != "" {
+		op.Tags = []string{ep.Tag}
+	}
+	if len(ep.Security) > 0 {
+		requirement := make(map[string][]string)
+		for _, name := range ep.Security {
+			requirement[name] = []string{}
+		}
+		op.Security = []map[string][]string{requirement}
+	}
+
+	for _, segment := range strings.Split(ep.Path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     strings.Trim(segment, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	if ep.Query != nil {
+		op.Parameters = append(op.Parameters, b.queryParameters(ep.Query, ep.Defaults)...)
+	}
+
+	switch {
+	case ep.BodyType != "":
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{ep.BodyType: {Schema: &Schema{Type: "string"}}}}
+	case ep.Body != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: b.schema(reflect.TypeOf(ep.Body))}}}
+	}
+
+	status := ep.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := Response{Description: http.StatusText(status)}
+	switch {
+	case ep.RawResponse != "":
+		response.Content = map[string]MediaType{ep.RawResponse: {Schema: &Schema{Type: "string"}}}
+	case ep.Response != nil:
+		response.Content = map[string]MediaType{"application/json": {Schema: b.response(ep.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = response
+	return op
+}
+
+func (b *builder) response(v interface{}) *Schema {
+	alternatives, ok := v.(OneOf)
+	if !ok {
+		return b.schema(reflect.TypeOf(v))
+	}
+	schema := &Schema{}
+	for _, alt := range alternatives {
+		schema.OneOf = append(schema.OneOf, b.schema(reflect.TypeOf(alt)))
+	}
+	return schema
+}
+
+// queryParameters describes the "param" tagged fields of the struct query,
+// with the defaults held by the struct defaults if non-nil
+func (b *builder) queryParameters(query, defaults interface{}) []Parameter {
+	t := reflect.TypeOf(query)
+	var dv reflect.Value
+	if defaults != nil {
+		dv = reflect.ValueOf(defaults)
+	}
+
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("param")
+		if name == "" {
+			continue
+		}
+		schema := b.schema(field.Type)
+		required := applyRules(schema, field.Tag.Get("validate"))
+		if dv.IsValid() && !dv.Field(i).IsZero() {
+			schema.Default = dv.Field(i).Interface()
+		}
+		params = append(params, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+	return params
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of values of t, a reference for named structs
+func (b *builder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.name(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// name registers the named struct t as a component schema and returns its
+// name, qualified with its package if another type took the plain name
+func (b *builder) name(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := b.schemas[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	b.names[t] = name
+	b.schemas[name] = &Schema{} // Placeholder for recursive types
+	*b.schemas[name] = *b.object(t)
+	return name
+}
+
+// object describes the struct t as encoding/json encodes it
+func (b *builder) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schema(field.Type)
+		required := applyRules(property, field.Tag.Get("validate"))
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			// Always present in what the API sends
+			required = true
+		}
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// applyRules adds the constraints of the validate rules to schema and
+// reports whether they make the value required
+func applyRules(schema *Schema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "required":
+			required = true
+		case "id":
+			schema.Pattern = IDPattern
+		case "port":
+			schema.Minimum, schema.Maximum = bound(1), bound(65535)
+		case "min", "max":
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: invalid %s bound %q", rule, arg))
+			}
+			switch {
+			case schema.Type == "string" && rule == "min":
+				schema.MinLength = bound(n)
+			case schema.Type == "string":
+				schema.MaxLength = bound(n)
+			case rule == "min":
+				schema.Minimum = bound(n)
+			default:
+				schema.Maximum = bound(n)
+			}
+		}
+	}
+	return required
+}
+
+func bound(n int64) *int64 { return &n }
@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// Policy controls how Do retries a failing call
+type Policy struct {
+	Attempts   int           // Calls made in total; below 1 means a single call
+	Backoff    time.Duration // Delay before the first retry, doubling for each further one
+	MaxBackoff time.Duration // Longest delay between attempts, 0 for no cap
+	Jitter     float64       // Fraction, from 0 to 1, of each delay that is randomized
+
+	// Retryable decides which errors are worth another attempt;
+	// IsRetryable when nil
+	Retryable func(error) bool
+
+	// OnRetry, if set, is called before waiting delay to make the next
+	// attempt after attempt failed with err
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+var (
+	mu      sync.RWMutex
+	current = FromConfig(config.Default().Retry)
+)
+
+// FromConfig returns the policy described by cfg
+func FromConfig(cfg config.RetryConfig) Policy {
+	return Policy{
+		Attempts:   cfg.Attempts,
+		Backoff:    cfg.Backoff,
+		MaxBackoff: cfg.MaxBackoff,
+		Jitter:     cfg.Jitter,
+	}
+}
+
+// Configure replaces the policy returned by Default, used for outbound
+// RPCs. It should be called once at startup.
+func Configure(cfg config.RetryConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = FromConfig(cfg)
+}
+
+// Default returns the policy set by Configure
+func Default() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a context under which For returns p, so a caller can
+// tune or disable the retries of the RPCs it makes
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// For returns the policy set on ctx by WithPolicy, or Default
+func For(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return p
+	}
+	return Default()
+}
+
+// Do calls fn until it succeeds, fails with an error that is not
+// retryable, the attempts are used up or ctx is done, and returns fn's
+// last error
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.Attempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := p.Delay(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Delay returns the wait after the given failed attempt, counting from 1:
+// Backoff doubled attempt-1 times, capped at MaxBackoff, with up to
+// Jitter of it randomized
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < math.MaxInt64/2 && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 && delay > 0 {
+		spread := time.Duration(p.Jitter * float64(delay))
+		delay += time.Duration(rand.Int63n(int64(spread)+1)) - spread/2
+	}
+	return delay
+}
+
+// IsRetryable reports whether err looks transient: a transport failure,
+// timeout or truncated reply, or an error with a Retryable method that
+// says so, such as a 5xx status. Cancellation is never retried.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
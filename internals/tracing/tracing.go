@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// TracerName is the instrumentation scope used for all Kademlia spans
+const TracerName = "github.com/Aradhya2708/kademlia"
+
+// Setup installs the global tracer provider and W3C traceparent propagator
+// described by cfg. The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %v", cfg.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the Kademlia tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Middleware wraps an RPC handler in a server span, continuing the trace
+// carried in the request's traceparent header if there is one. The
+// request ID sent by the caller, or a new one if it sent none, is echoed
+// in the response and made available to the handler through RequestID.
+func Middleware(rpc string, nodeID string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx = WithRequestID(ctx, requestID)
+		ctx, span := Tracer().Start(ctx, rpc,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("kademlia.rpc", rpc),
+				attribute.String("kademlia.node_id", nodeID),
+				attribute.String("kademlia.request_id", requestID),
+				attribute.String("net.peer.addr", r.RemoteAddr),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.status_code", rec.status))
+		if rec.status >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	}
+}
+
+// Transport is an http.RoundTripper that wraps every outbound RPC in a
+// client span and injects the traceparent header.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport wraps base (http.DefaultTransport if nil) with tracing
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper. Each request is given a new
+// request ID unless the caller set one, and is logged together with the
+// ID of the RPC being handled, if any, so hops can be followed across
+// nodes.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := req.Header.Get(RequestIDHeader)
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	ctx, span := Tracer().Start(req.Context(), "rpc "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("kademlia.rpc", req.URL.Path),
+			attribute.String("kademlia.request_id", requestID),
+			attribute.String("net.peer.addr", req.URL.Host),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	req.Header.Set(RequestIDHeader, requestID)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	parent := ""
+	if id := RequestID(ctx); id != "" {
+		parent = " (handling " + id + ")"
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		log.Printf("[%s] %s %s%s failed: %v", requestID, req.URL.Host, req.URL.Path, parent, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	log.Printf("[%s] %s %s%s: %d", requestID, req.URL.Host, req.URL.Path, parent, resp.StatusCode)
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// hijack the connection
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,167 @@
+// Package validators checks IDs and the requests carrying them. Request
+// structs declare how they are decoded and validated in struct
+// tags, so every transport carrying a request applies the same checks:
+//
+//	ID    string `param:"id" validate:"required,id"`
+//	Count int    `param:"count" validate:"min=1"`
+//
+// The "param" tag names the query parameter DecodeQuery fills the field
+// from. The comma separated "validate" rules checked by Struct are:
+//
+//	required         the field must not be empty or zero, nor an empty slice
+//	required_with=F  required when the field named F is set
+//	id               a 160-bit hex ID, checked with HexadecimalValidator if set
+//	port             a TCP or UDP port, from 1 to 65535, if set
+//	min=N, max=N     bounds of a number, or of the length of a string or slice
+//
+// Fields are named in errors, and by required_with, by their "param" tag,
+// falling back to their "json" tag and then their Go name.
+package validators
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError reports the request field that failed decoding or validation
+type FieldError struct {
+	Field  string // Name of the field, as it appears on the wire
+	Reason string // Why the value was refused, empty when it is missing
+}
+
+func (e *FieldError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("Missing '%s'", e.Field)
+	}
+	return fmt.Sprintf("Invalid '%s': %s", e.Field, e.Reason)
+}
+
+// DecodeQuery sets the fields of the struct v points to from the query
+// parameters named by their "param" tags. Absent or empty parameters leave
+// the field unchanged, so defaults can be set beforehand. It does not
+// validate; call Struct once any defaults and normalization are applied.
+func DecodeQuery(values url.Values, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name := rt.Field(i).Tag.Get("param")
+		raw := values.Get(name)
+		if name == "" || raw == "" {
+			continue
+		}
+
+		field := rv.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(raw)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return &FieldError{Field: name, Reason: "not an integer"}
+			}
+			field.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return &FieldError{Field: name, Reason: "not a boolean"}
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("validators: unsupported parameter type %s", field.Type())
+		}
+	}
+	return nil
+}
+
+// Struct checks the fields of the struct v, or v points to, against their
+// "validate" rules, returning a *FieldError for the first that fails
+func Struct(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		rules := rt.Field(i).Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		name := fieldName(rt.Field(i))
+		for _, rule := range strings.Split(rules, ",") {
+			if ok, reason := check(rv, rv.Field(i), rule); !ok {
+				return &FieldError{Field: name, Reason: reason}
+			}
+		}
+	}
+	return nil
+}
+
+// check applies one rule to field, a field of the struct rv, returning
+// whether it passes and if not why, with no reason for a missing field
+func check(rv, field reflect.Value, rule string) (bool, string) {
+	rule, arg, _ := strings.Cut(rule, "=")
+	switch rule {
+	case "required":
+		if field.IsZero() || field.Kind() == reflect.Slice && field.Len() == 0 {
+			return false, ""
+		}
+	case "required_with":
+		if other, ok := fieldByName(rv, arg); ok && !other.IsZero() && field.IsZero() {
+			return false, ""
+		}
+	case "id":
+		if s := field.String(); s != "" {
+			if err := ValidateID(s, HexadecimalValidator); err != nil {
+				return false, err.Error()
+			}
+		}
+	case "port":
+		if n := field.Int(); n != 0 && (n < 1 || n > 65535) {
+			return false, "out of range"
+		}
+	case "min", "max":
+		bound, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validators: invalid %s bound %q", rule, arg))
+		}
+		n, what := int64(0), "value"
+		switch field.Kind() {
+		case reflect.String:
+			n, what = int64(len(field.String())), "length"
+		case reflect.Slice:
+			n, what = int64(field.Len()), "length"
+		default:
+			n = field.Int()
+		}
+		if rule == "min" && n < bound {
+			return false, fmt.Sprintf("%s below %d", what, bound)
+		}
+		if rule == "max" && n > bound {
+			return false, fmt.Sprintf("%s above %d", what, bound)
+		}
+	default:
+		panic(fmt.Sprintf("validators: unknown rule %q", rule))
+	}
+	return true, ""
+}
+
+// fieldName is the name field appears under on the wire
+func fieldName(field reflect.StructField) string {
+	if name := field.Tag.Get("param"); name != "" {
+		return name
+	}
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// fieldByName returns the field of the struct rv named name on the wire
+func fieldByName(rv reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < rv.NumField(); i++ {
+		if fieldName(rv.Type().Field(i)) == name {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
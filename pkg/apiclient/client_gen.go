@@ -0,0 +1,1357 @@
+// Code generated by cmd/apigen from the OpenAPI document of Kademlia node 1. DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AddProviderRequest mirrors the AddProviderRequest schema
+type AddProviderRequest struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Port int    `json:"port"`
+}
+
+// BloomFilter mirrors the BloomFilter schema
+type BloomFilter struct {
+	Bits   []byte `json:"bits"`
+	Hashes int    `json:"hashes"`
+}
+
+// BucketInfo mirrors the BucketInfo schema
+type BucketInfo struct {
+	Contacts      int       `json:"contacts"`
+	IdleSeconds   float64   `json:"idle_seconds"`
+	Index         int       `json:"index"`
+	LastUpdated   time.Time `json:"last_updated"`
+	MaxSize       int       `json:"max_size"`
+	SubnetRefused int64     `json:"subnet_refused"`
+}
+
+// ChurnStats mirrors the ChurnStats schema
+type ChurnStats struct {
+	Drops        int     `json:"drops"`
+	DropsPerHour float64 `json:"drops_per_hour"`
+	MeanSession  int64   `json:"mean_session"`
+	Online       int     `json:"online"`
+	Peers        int     `json:"peers"`
+	Rejoins      int     `json:"rejoins"`
+	Sessions     int     `json:"sessions"`
+}
+
+// DeleteRequest mirrors the DeleteRequest schema
+type DeleteRequest struct {
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
+	Key       string    `json:"key"`
+	PublicKey string    `json:"public_key,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Replicate bool      `json:"replicate,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// FindValueReply mirrors the FindValueReply schema
+type FindValueReply struct {
+	Encoding string     `json:"encoding,omitempty"`
+	Meta     RecordMeta `json:"meta"`
+	Value    string     `json:"value"`
+}
+
+// FindValuesReply mirrors the FindValuesReply schema
+type FindValuesReply struct {
+	Closest map[string][]int `json:"closest,omitempty"`
+	Nodes   []Node           `json:"nodes,omitempty"`
+	Values  []FoundValue     `json:"values"`
+}
+
+// FindValuesRequest mirrors the FindValuesRequest schema
+type FindValuesRequest struct {
+	Count int      `json:"count,omitempty"`
+	Keys  []string `json:"keys"`
+}
+
+// ForwardPeer mirrors the ForwardPeer schema
+type ForwardPeer struct {
+	Attempts int       `json:"attempts"`
+	ID       string    `json:"id"`
+	IP       string    `json:"ip"`
+	Oldest   time.Time `json:"oldest"`
+	Port     int       `json:"port"`
+	Writes   int       `json:"writes"`
+}
+
+// ForwardStats mirrors the ForwardStats schema
+type ForwardStats struct {
+	Delivered int64         `json:"delivered"`
+	Dropped   int64         `json:"dropped"`
+	Expired   int64         `json:"expired"`
+	Peers     []ForwardPeer `json:"peers"`
+	Queued    int           `json:"queued"`
+	Rejected  int64         `json:"rejected"`
+}
+
+// FoundValue mirrors the FoundValue schema
+type FoundValue struct {
+	Encoding string `json:"encoding,omitempty"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
+
+// GatewayBatchReply mirrors the GatewayBatchReply schema
+type GatewayBatchReply struct {
+	Missing []string     `json:"missing"`
+	Values  []FoundValue `json:"values"`
+}
+
+// GatewayBatchRequest mirrors the GatewayBatchRequest schema
+type GatewayBatchRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// ImportResult mirrors the ImportResult schema
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// InsertSource mirrors the InsertSource schema
+type InsertSource struct {
+	Ids     int    `json:"ids"`
+	IP      string `json:"ip"`
+	Refused int64  `json:"refused"`
+}
+
+// InsertStats mirrors the InsertStats schema
+type InsertStats struct {
+	Limit   int            `json:"limit"`
+	Refused int64          `json:"refused"`
+	Sources []InsertSource `json:"sources"`
+	Window  int64          `json:"window"`
+}
+
+// JobStatus mirrors the JobStatus schema
+type JobStatus struct {
+	IntervalSeconds float64   `json:"interval_seconds"`
+	LastRun         time.Time `json:"last_run"`
+	LastRunMs       float64   `json:"last_run_ms"`
+	Name            string    `json:"name"`
+	NextRun         time.Time `json:"next_run"`
+	Paused          bool      `json:"paused"`
+	Running         bool      `json:"running"`
+	Runs            int64     `json:"runs"`
+}
+
+// KeyChange mirrors the KeyChange schema
+type KeyChange struct {
+	Deleted  bool   `json:"deleted,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Key      string `json:"key"`
+	Seq      int64  `json:"seq"`
+	Time     int64  `json:"time"`
+	Value    string `json:"value,omitempty"`
+}
+
+// KeyInfo mirrors the KeyInfo schema
+type KeyInfo struct {
+	Key       string    `json:"key"`
+	SizeBytes int       `json:"size_bytes"`
+	StoredAt  time.Time `json:"stored_at"`
+}
+
+// KeyList mirrors the KeyList schema
+type KeyList struct {
+	Keys      []KeyInfo `json:"keys"`
+	NextToken string    `json:"next_token,omitempty"`
+}
+
+// KeyPage mirrors the KeyPage schema
+type KeyPage struct {
+	NextToken string      `json:"next_token,omitempty"`
+	Records   []KeyRecord `json:"records"`
+}
+
+// KeyRecord mirrors the KeyRecord schema
+type KeyRecord struct {
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
+	Key       string    `json:"key"`
+	PublicKey string    `json:"public_key,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	Value     string    `json:"value"`
+}
+
+// KeyspaceBin mirrors the KeyspaceBin schema
+type KeyspaceBin struct {
+	Keys  int    `json:"keys"`
+	Peers int    `json:"peers"`
+	Start string `json:"start"`
+}
+
+// KeyspaceHistogram mirrors the KeyspaceHistogram schema
+type KeyspaceHistogram struct {
+	Bins    []KeyspaceBin `json:"bins"`
+	Keys    int           `json:"keys"`
+	NodeID  string        `json:"node_id"`
+	Peers   int           `json:"peers"`
+	SelfBin int           `json:"self_bin"`
+}
+
+// Lease mirrors the Lease schema
+type Lease struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Holder    string    `json:"holder,omitempty"`
+	Key       string    `json:"key"`
+	Token     int64     `json:"token"`
+}
+
+// LeaseAck mirrors the LeaseAck schema
+type LeaseAck struct {
+	Granted  bool         `json:"granted"`
+	Key      string       `json:"key"`
+	Lease    Lease        `json:"lease"`
+	Replicas []ReplicaAck `json:"replicas"`
+}
+
+// LeaseRequest mirrors the LeaseRequest schema
+type LeaseRequest struct {
+	Holder    string `json:"holder"`
+	Key       string `json:"key"`
+	Release   bool   `json:"release,omitempty"`
+	Replicate bool   `json:"replicate,omitempty"`
+	TTL       int    `json:"ttl,omitempty"`
+}
+
+// LookupCacheStats mirrors the LookupCacheStats schema
+type LookupCacheStats struct {
+	Entries     int   `json:"entries"`
+	Hits        int64 `json:"hits"`
+	Invalidated int64 `json:"invalidated"`
+	Misses      int64 `json:"misses"`
+}
+
+// Message mirrors the Message schema
+type Message struct {
+	Count      int    `json:"count,omitempty"`
+	Encoding   string `json:"encoding,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Nodes      []Node `json:"nodes,omitempty"`
+	Nonce      string `json:"nonce"`
+	RecordType string `json:"record_type,omitempty"`
+	Sender     Node   `json:"sender"`
+	Signature  string `json:"signature,omitempty"`
+	Target     string `json:"target,omitempty"`
+	Token      string `json:"token,omitempty"`
+	Type       string `json:"type"`
+	Value      string `json:"value,omitempty"`
+	Version    int    `json:"version"`
+}
+
+// Node mirrors the Node schema
+type Node struct {
+	Flags    int64       `json:"Flags,omitempty"`
+	ID       string      `json:"ID"`
+	IP       string      `json:"IP"`
+	LastSeen int64       `json:"LastSeen"`
+	Network  string      `json:"Network,omitempty"`
+	Port     int         `json:"Port"`
+	Protocol int         `json:"Protocol,omitempty"`
+	Record   *NodeRecord `json:"Record,omitempty"`
+	Relay    string      `json:"Relay,omitempty"`
+}
+
+// NodeInfo mirrors the NodeInfo schema
+type NodeInfo struct {
+	Alpha          int               `json:"alpha"`
+	Bytes          int64             `json:"bytes"`
+	Contacts       int               `json:"contacts"`
+	Flags          int64             `json:"flags"`
+	IDBits         int               `json:"id_bits"`
+	K              int               `json:"k"`
+	KeyFilter      *BloomFilter      `json:"key_filter,omitempty"`
+	Keys           int               `json:"keys"`
+	LookupCache    *LookupCacheStats `json:"lookup_cache,omitempty"`
+	MessageVersion int               `json:"message_version"`
+	Network        string            `json:"network,omitempty"`
+	NodeID         string            `json:"node_id"`
+	Protocol       int               `json:"protocol"`
+	UptimeSeconds  float64           `json:"uptime_seconds"`
+	Version        string            `json:"version"`
+}
+
+// NodeRecord mirrors the NodeRecord schema
+type NodeRecord struct {
+	Capabilities []string `json:"capabilities"`
+	Endpoints    []string `json:"endpoints"`
+	ID           string   `json:"id"`
+	PublicKey    string   `json:"public_key"`
+	Seq          int64    `json:"seq"`
+	Signature    string   `json:"signature,omitempty"`
+}
+
+// Ownership mirrors the Ownership schema
+type Ownership struct {
+	Contacts             int     `json:"contacts"`
+	EstimatedNetworkSize float64 `json:"estimated_network_size"`
+	Fraction             float64 `json:"fraction"`
+	K                    int     `json:"k"`
+	KthDistanceBits      int     `json:"kth_distance_bits"`
+	NodeID               string  `json:"node_id"`
+	UnderPopulated       bool    `json:"under_populated"`
+}
+
+// PeerChurn mirrors the PeerChurn schema
+type PeerChurn struct {
+	Churn float64   `json:"churn"`
+	ID    string    `json:"id"`
+	Score PeerScore `json:"score"`
+	Trust float64   `json:"trust"`
+}
+
+// PeerInfo mirrors the PeerInfo schema
+type PeerInfo struct {
+	Addresses   []string  `json:"addresses"`
+	Failures    int       `json:"failures"`
+	FirstSeen   time.Time `json:"first_seen"`
+	Flags       int64     `json:"flags,omitempty"`
+	ID          string    `json:"id"`
+	InTable     bool      `json:"in_table"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	LastReply   time.Time `json:"last_reply,omitempty"`
+	LastSeen    time.Time `json:"last_seen,omitempty"`
+	Protocol    int       `json:"protocol,omitempty"`
+	Relay       string    `json:"relay,omitempty"`
+	Rtt         int64     `json:"rtt,omitempty"`
+	Trust       float64   `json:"trust"`
+}
+
+// PeerScore mirrors the PeerScore schema
+type PeerScore struct {
+	Drops     int       `json:"drops"`
+	Failures  float64   `json:"failures"`
+	JoinedAt  time.Time `json:"joined_at,omitempty"`
+	LeftAt    time.Time `json:"left_at,omitempty"`
+	Online    bool      `json:"online"`
+	Rejoins   int       `json:"rejoins"`
+	Rtt       int64     `json:"rtt,omitempty"`
+	RttVar    int64     `json:"rtt_var,omitempty"`
+	Sessions  int       `json:"sessions"`
+	Successes float64   `json:"successes"`
+	Updated   time.Time `json:"updated"`
+	Uptime    int64     `json:"uptime"`
+}
+
+// PeerStoreList mirrors the PeerStoreList schema
+type PeerStoreList struct {
+	Failing int         `json:"failing"`
+	InTable int         `json:"in_table"`
+	Inserts InsertStats `json:"inserts"`
+	List    []PeerInfo  `json:"list"`
+	Peers   int         `json:"peers"`
+}
+
+// PingReply mirrors the PingReply schema
+type PingReply struct {
+	Filter     *BloomFilter `json:"filter,omitempty"`
+	Flags      int64        `json:"flags,omitempty"`
+	Message    string       `json:"message"`
+	Network    string       `json:"network,omitempty"`
+	NodeID     string       `json:"node_id"`
+	ObservedIP string       `json:"observed_ip,omitempty"`
+	Protocol   int          `json:"protocol,omitempty"`
+	Record     *NodeRecord  `json:"record,omitempty"`
+	Token      string       `json:"token,omitempty"`
+}
+
+// PlacementSnapshot mirrors the PlacementSnapshot schema
+type PlacementSnapshot struct {
+	AcceptRate            float64 `json:"accept_rate"`
+	Accepted              int64   `json:"accepted"`
+	MeanGapBits           float64 `json:"mean_gap_bits"`
+	NotClosest            int64   `json:"not_closest"`
+	OutsideResponsibility int64   `json:"outside_responsibility"`
+}
+
+// PoolStats mirrors the PoolStats schema
+type PoolStats struct {
+	ConnsCreated int64 `json:"conns_created"`
+	ConnsReused  int64 `json:"conns_reused"`
+	Errors       int64 `json:"errors"`
+	InFlight     int64 `json:"in_flight"`
+	Requests     int64 `json:"requests"`
+}
+
+// ProvidersReply mirrors the ProvidersReply schema
+type ProvidersReply struct {
+	ClosestNodes []Node `json:"closest_nodes"`
+	Providers    []Node `json:"providers"`
+}
+
+// PublishReply mirrors the PublishReply schema
+type PublishReply struct {
+	Delivered int    `json:"delivered"`
+	NodeID    string `json:"node_id"`
+	Topic     string `json:"topic"`
+}
+
+// PublishRequest mirrors the PublishRequest schema
+type PublishRequest struct {
+	Payload string `json:"payload"`
+	Topic   string `json:"topic"`
+}
+
+// PunchReply mirrors the PunchReply schema
+type PunchReply struct {
+	Endpoint string `json:"endpoint"`
+}
+
+// PunchRequest mirrors the PunchRequest schema
+type PunchRequest struct {
+	Endpoint string `json:"endpoint"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// RPCStats mirrors the RPCStats schema
+type RPCStats struct {
+	ClientErrors   int64   `json:"client_errors"`
+	Panics         int64   `json:"panics"`
+	Requests       int64   `json:"requests"`
+	ServerErrors   int64   `json:"server_errors"`
+	TotalLatencyMs float64 `json:"total_latency_ms"`
+}
+
+// RecordMeta mirrors the RecordMeta schema
+type RecordMeta struct {
+	Hash          string    `json:"hash"`
+	Hops          int       `json:"hops"`
+	Publisher     string    `json:"publisher,omitempty"`
+	Republish     int       `json:"republish,omitempty"`
+	RepublishedAt time.Time `json:"republished_at"`
+	Size          int       `json:"size"`
+	StoredAt      time.Time `json:"stored_at"`
+	TTL           int       `json:"ttl,omitempty"`
+}
+
+// ReplicaAck mirrors the ReplicaAck schema
+type ReplicaAck struct {
+	Error  string `json:"error,omitempty"`
+	ID     string `json:"id"`
+	IP     string `json:"ip"`
+	Port   int    `json:"port"`
+	Queued bool   `json:"queued,omitempty"`
+	Stored bool   `json:"stored"`
+}
+
+// RuntimeStats mirrors the RuntimeStats schema
+type RuntimeStats struct {
+	GCPauseTotalMs float64   `json:"gc_pause_total_ms"`
+	Goroutines     int       `json:"goroutines"`
+	HeapAllocBytes int64     `json:"heap_alloc_bytes"`
+	HeapObjects    int64     `json:"heap_objects"`
+	HeapSysBytes   int64     `json:"heap_sys_bytes"`
+	NumGC          int64     `json:"num_gc"`
+	SampledAt      time.Time `json:"sampled_at"`
+}
+
+// StoreAck mirrors the StoreAck schema
+type StoreAck struct {
+	Failed            int          `json:"failed"`
+	Key               string       `json:"key"`
+	Queued            int          `json:"queued,omitempty"`
+	Replicas          []ReplicaAck `json:"replicas"`
+	ReplicationFactor int          `json:"replication_factor"`
+}
+
+// StoreRequest mirrors the StoreRequest schema
+type StoreRequest struct {
+	Encoding       string    `json:"encoding,omitempty"`
+	Hops           int       `json:"hops,omitempty"`
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+	Key            string    `json:"key"`
+	Policy         string    `json:"policy,omitempty"`
+	PublishedAt    time.Time `json:"published_at,omitempty"`
+	Publisher      string    `json:"publisher,omitempty"`
+	Replicate      bool      `json:"replicate,omitempty"`
+	Republish      int       `json:"republish,omitempty"`
+	Token          string    `json:"token,omitempty"`
+	TTL            int       `json:"ttl,omitempty"`
+	Type           string    `json:"type,omitempty"`
+	Value          string    `json:"value"`
+}
+
+// SubscribeReply mirrors the SubscribeReply schema
+type SubscribeReply struct {
+	Key      string `json:"key"`
+	NodeID   string `json:"node_id"`
+	Replicas int    `json:"replicas"`
+	Topic    string `json:"topic"`
+}
+
+// SubscribeRequest mirrors the SubscribeRequest schema
+type SubscribeRequest struct {
+	SubscriberID string `json:"subscriber_id"`
+	Topic        string `json:"topic"`
+	Webhook      string `json:"webhook,omitempty"`
+}
+
+// SyncPushReply mirrors the SyncPushReply schema
+type SyncPushReply struct {
+	Stored int `json:"stored"`
+}
+
+// TopicMessage mirrors the TopicMessage schema
+type TopicMessage struct {
+	Payload   string `json:"payload"`
+	Timestamp int64  `json:"timestamp"`
+	Topic     string `json:"topic"`
+}
+
+// WatchReply mirrors the WatchReply schema
+type WatchReply struct {
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	NodeID    string    `json:"node_id"`
+	State     KeyChange `json:"state"`
+}
+
+// WatchRequest mirrors the WatchRequest schema
+type WatchRequest struct {
+	Cancel    bool   `json:"cancel,omitempty"`
+	Key       string `json:"key"`
+	WatcherID string `json:"watcher_id"`
+	Webhook   string `json:"webhook,omitempty"`
+}
+
+// AddProvider calls POST /add_provider:
+// Announce a provider of a content key
+func (c *Client) AddProvider(ctx context.Context, body AddProviderRequest) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, "POST", "/add_provider", nil, body, "application/json", &out)
+	return out, err
+}
+
+// AdminExport calls GET /admin/export:
+// Every stored record as JSON lines after a versioned header
+func (c *Client) AdminExport(ctx context.Context) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, "GET", "/admin/export", nil, nil, "", &out)
+	return out, err
+}
+
+// AdminImportQuery holds the query parameters of AdminImport. Zero values are left
+// out, so the node's defaults apply.
+type AdminImportQuery struct {
+	Overwrite *bool
+}
+
+func (q AdminImportQuery) values() url.Values {
+	v := url.Values{}
+	if q.Overwrite != nil {
+		v.Set("overwrite", strconv.FormatBool(*q.Overwrite))
+	}
+	return v
+}
+
+// AdminImport calls POST /admin/import:
+// Store the records of an export; existing keys are skipped unless overwriting
+func (c *Client) AdminImport(ctx context.Context, query AdminImportQuery, body io.Reader) (ImportResult, error) {
+	var out ImportResult
+	err := c.do(ctx, "POST", "/admin/import", query.values(), body, "application/x-ndjson", &out)
+	return out, err
+}
+
+// AdminJobs calls GET /admin/jobs:
+// The node's periodic jobs, such as refresh, republish and expiry, with their runs and whether they are paused
+func (c *Client) AdminJobs(ctx context.Context) ([]JobStatus, error) {
+	var out []JobStatus
+	err := c.do(ctx, "GET", "/admin/jobs", nil, nil, "", &out)
+	return out, err
+}
+
+// AdminJobsPauseQuery holds the query parameters of AdminJobsPause. Zero values are left
+// out, so the node's defaults apply.
+type AdminJobsPauseQuery struct {
+	Job string
+}
+
+func (q AdminJobsPauseQuery) values() url.Values {
+	v := url.Values{}
+	if q.Job != "" {
+		v.Set("job", q.Job)
+	}
+	return v
+}
+
+// AdminJobsPause calls POST /admin/jobs/pause:
+// Stop starting runs of a periodic job, or of every job without one, letting runs in progress end
+func (c *Client) AdminJobsPause(ctx context.Context, query AdminJobsPauseQuery) ([]JobStatus, error) {
+	var out []JobStatus
+	err := c.do(ctx, "POST", "/admin/jobs/pause", query.values(), nil, "", &out)
+	return out, err
+}
+
+// AdminJobsResumeQuery holds the query parameters of AdminJobsResume. Zero values are left
+// out, so the node's defaults apply.
+type AdminJobsResumeQuery struct {
+	Job string
+}
+
+func (q AdminJobsResumeQuery) values() url.Values {
+	v := url.Values{}
+	if q.Job != "" {
+		v.Set("job", q.Job)
+	}
+	return v
+}
+
+// AdminJobsResume calls POST /admin/jobs/resume:
+// Resume a paused periodic job, or every job without one; runs that fell due meanwhile start at once
+func (c *Client) AdminJobsResume(ctx context.Context, query AdminJobsResumeQuery) ([]JobStatus, error) {
+	var out []JobStatus
+	err := c.do(ctx, "POST", "/admin/jobs/resume", query.values(), nil, "", &out)
+	return out, err
+}
+
+// AdminKeysQuery holds the query parameters of AdminKeys. Zero values are left
+// out, so the node's defaults apply.
+type AdminKeysQuery struct {
+	Prefix    string
+	Namespace string
+	Limit     int // default 100
+	Token     string
+}
+
+func (q AdminKeysQuery) values() url.Values {
+	v := url.Values{}
+	if q.Prefix != "" {
+		v.Set("prefix", q.Prefix)
+	}
+	if q.Namespace != "" {
+		v.Set("namespace", q.Namespace)
+	}
+	if q.Limit != 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Token != "" {
+		v.Set("token", q.Token)
+	}
+	return v
+}
+
+// AdminKeys calls GET /admin/keys:
+// One page of the stored keys whose raw key starts with a hex prefix
+func (c *Client) AdminKeys(ctx context.Context, query AdminKeysQuery) (KeyList, error) {
+	var out KeyList
+	err := c.do(ctx, "GET", "/admin/keys", query.values(), nil, "", &out)
+	return out, err
+}
+
+// AdminKeyspaceQuery holds the query parameters of AdminKeyspace. Zero values are left
+// out, so the node's defaults apply.
+type AdminKeyspaceQuery struct {
+	Bins   int    // default 16
+	Format string // default json
+}
+
+func (q AdminKeyspaceQuery) values() url.Values {
+	v := url.Values{}
+	if q.Bins != 0 {
+		v.Set("bins", strconv.Itoa(q.Bins))
+	}
+	if q.Format != "" {
+		v.Set("format", q.Format)
+	}
+	return v
+}
+
+// AdminKeyspace calls GET /admin/keyspace:
+// Counts of stored keys and known peers in equal ranges of the keyspace; format=csv returns CSV instead
+func (c *Client) AdminKeyspace(ctx context.Context, query AdminKeyspaceQuery) (KeyspaceHistogram, error) {
+	var out KeyspaceHistogram
+	err := c.do(ctx, "GET", "/admin/keyspace", query.values(), nil, "", &out)
+	return out, err
+}
+
+// Buckets calls GET /buckets:
+// Contacts, capacity and last update of every bucket in use
+func (c *Client) Buckets(ctx context.Context) ([]BucketInfo, error) {
+	var out []BucketInfo
+	err := c.do(ctx, "GET", "/buckets", nil, nil, "", &out)
+	return out, err
+}
+
+// ChurnStatsQuery holds the query parameters of ChurnStats. Zero values are left
+// out, so the node's defaults apply.
+type ChurnStatsQuery struct {
+	ID string
+}
+
+func (q ChurnStatsQuery) values() url.Values {
+	v := url.Values{}
+	if q.ID != "" {
+		v.Set("id", q.ID)
+	}
+	return v
+}
+
+// ChurnStats calls GET /churn_stats:
+// Session counts, drop rate and mean session length of the peers seen, or the session history and trust of one peer
+func (c *Client) ChurnStats(ctx context.Context, query ChurnStatsQuery) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/churn_stats", query.values(), nil, "", &out)
+	return out, err
+}
+
+// Delete calls POST /delete:
+// Replace a value by a tombstone that replicas keep until garbage collected, or with replicate set do so on the k closest nodes
+func (c *Client) Delete(ctx context.Context, body DeleteRequest) (StoreAck, error) {
+	var out StoreAck
+	err := c.do(ctx, "POST", "/delete", nil, body, "application/json", &out)
+	return out, err
+}
+
+// FindNodeQuery holds the query parameters of FindNode. Zero values are left
+// out, so the node's defaults apply.
+type FindNodeQuery struct {
+	ID        string // required
+	Requester string
+	Count     int
+}
+
+func (q FindNodeQuery) values() url.Values {
+	v := url.Values{}
+	if q.ID != "" {
+		v.Set("id", q.ID)
+	}
+	if q.Requester != "" {
+		v.Set("requester", q.Requester)
+	}
+	if q.Count != 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+	return v
+}
+
+// FindNode calls GET /find_node:
+// Find the contacts closest to an ID
+func (c *Client) FindNode(ctx context.Context, query FindNodeQuery) ([]Node, error) {
+	var out []Node
+	err := c.do(ctx, "GET", "/find_node", query.values(), nil, "", &out)
+	return out, err
+}
+
+// FindValueQuery holds the query parameters of FindValue. Zero values are left
+// out, so the node's defaults apply.
+type FindValueQuery struct {
+	Key   string // required
+	Count int
+	Hash  *bool
+	Meta  *bool
+}
+
+func (q FindValueQuery) values() url.Values {
+	v := url.Values{}
+	if q.Key != "" {
+		v.Set("key", q.Key)
+	}
+	if q.Count != 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	if q.Meta != nil {
+		v.Set("meta", strconv.FormatBool(*q.Meta))
+	}
+	return v
+}
+
+// FindValue calls GET /find_value:
+// Get the value of a key, with its provenance when meta is set, or the contacts closest to it if this node does not hold it; the value's ETag in If-None-Match answers 304 if it is unchanged
+func (c *Client) FindValue(ctx context.Context, query FindValueQuery) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/find_value", query.values(), nil, "", &out)
+	return out, err
+}
+
+// FindValueHeadQuery holds the query parameters of FindValueHead. Zero values are left
+// out, so the node's defaults apply.
+type FindValueHeadQuery struct {
+	Key   string // required
+	Count int
+	Hash  *bool
+	Meta  *bool
+}
+
+func (q FindValueHeadQuery) values() url.Values {
+	v := url.Values{}
+	if q.Key != "" {
+		v.Set("key", q.Key)
+	}
+	if q.Count != 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	if q.Meta != nil {
+		v.Set("meta", strconv.FormatBool(*q.Meta))
+	}
+	return v
+}
+
+// FindValueHead calls HEAD /find_value:
+// Check whether this node holds a key, answering 404 if not, with the value's ETag and its provenance in the X-Kademlia-Meta header but not the value
+func (c *Client) FindValueHead(ctx context.Context, query FindValueHeadQuery) error {
+	return c.do(ctx, "HEAD", "/find_value", query.values(), nil, "", nil)
+}
+
+// FindValuesQuery holds the query parameters of FindValues. Zero values are left
+// out, so the node's defaults apply.
+type FindValuesQuery struct {
+	Hash *bool
+}
+
+func (q FindValuesQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// FindValues calls POST /find_values:
+// Get the values of up to 64 keys at once, with the contacts closest to each key this node does not hold
+func (c *Client) FindValues(ctx context.Context, query FindValuesQuery, body FindValuesRequest) (FindValuesReply, error) {
+	var out FindValuesReply
+	err := c.do(ctx, "POST", "/find_values", query.values(), body, "application/json", &out)
+	return out, err
+}
+
+// ForwardStats calls GET /forward_stats:
+// Replica STOREs queued for unreachable peers, and how many were delivered, expired, refused or dropped
+func (c *Client) ForwardStats(ctx context.Context) (ForwardStats, error) {
+	var out ForwardStats
+	err := c.do(ctx, "GET", "/forward_stats", nil, nil, "", &out)
+	return out, err
+}
+
+// GetProvidersQuery holds the query parameters of GetProviders. Zero values are left
+// out, so the node's defaults apply.
+type GetProvidersQuery struct {
+	Key string // required
+}
+
+func (q GetProvidersQuery) values() url.Values {
+	v := url.Values{}
+	if q.Key != "" {
+		v.Set("key", q.Key)
+	}
+	return v
+}
+
+// GetProviders calls GET /get_providers:
+// List the providers of a content key and the contacts closest to it
+func (c *Client) GetProviders(ctx context.Context, query GetProvidersQuery) (ProvidersReply, error) {
+	var out ProvidersReply
+	err := c.do(ctx, "GET", "/get_providers", query.values(), nil, "", &out)
+	return out, err
+}
+
+// IterateKeysQuery holds the query parameters of IterateKeys. Zero values are left
+// out, so the node's defaults apply.
+type IterateKeysQuery struct {
+	Target string // required
+	Radius int    // default 160
+	Limit  int    // default 100
+	Token  string
+}
+
+func (q IterateKeysQuery) values() url.Values {
+	v := url.Values{}
+	if q.Target != "" {
+		v.Set("target", q.Target)
+	}
+	if q.Radius != 0 {
+		v.Set("radius", strconv.Itoa(q.Radius))
+	}
+	if q.Limit != 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Token != "" {
+		v.Set("token", q.Token)
+	}
+	return v
+}
+
+// IterateKeys calls GET /iterate_keys:
+// Page through the stored records near a target
+func (c *Client) IterateKeys(ctx context.Context, query IterateKeysQuery) (KeyPage, error) {
+	var out KeyPage
+	err := c.do(ctx, "GET", "/iterate_keys", query.values(), nil, "", &out)
+	return out, err
+}
+
+// LeaseQuery holds the query parameters of Lease. Zero values are left
+// out, so the node's defaults apply.
+type LeaseQuery struct {
+	Hash *bool
+}
+
+func (q LeaseQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// Lease calls POST /lease:
+// Acquire, renew or release the lease on a key, or with replicate set do so on a majority of the k closest nodes
+func (c *Client) Lease(ctx context.Context, query LeaseQuery, body LeaseRequest) (LeaseAck, error) {
+	var out LeaseAck
+	err := c.do(ctx, "POST", "/lease", query.values(), body, "application/json", &out)
+	return out, err
+}
+
+// NodeInfoQuery holds the query parameters of NodeInfo. Zero values are left
+// out, so the node's defaults apply.
+type NodeInfoQuery struct {
+	Filter *bool
+}
+
+func (q NodeInfoQuery) values() url.Values {
+	v := url.Values{}
+	if q.Filter != nil {
+		v.Set("filter", strconv.FormatBool(*q.Filter))
+	}
+	return v
+}
+
+// NodeInfo calls GET /node_info:
+// Software and protocol versions, uptime, k and alpha, storage counts and capability flags, and optionally a Bloom filter of the stored keys
+func (c *Client) NodeInfo(ctx context.Context, query NodeInfoQuery) (NodeInfo, error) {
+	var out NodeInfo
+	err := c.do(ctx, "GET", "/node_info", query.values(), nil, "", &out)
+	return out, err
+}
+
+// OpenAPI calls GET /openapi.json:
+// This OpenAPI document
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/openapi.json", nil, nil, "", &out)
+	return out, err
+}
+
+// Ownership calls GET /ownership:
+// Estimated share of the keyspace this node stores and the network size it implies
+func (c *Client) Ownership(ctx context.Context) (Ownership, error) {
+	var out Ownership
+	err := c.do(ctx, "GET", "/ownership", nil, nil, "", &out)
+	return out, err
+}
+
+// PeerStoreQuery holds the query parameters of PeerStore. Zero values are left
+// out, so the node's defaults apply.
+type PeerStoreQuery struct {
+	ID    string
+	Limit int // default 100
+}
+
+func (q PeerStoreQuery) values() url.Values {
+	v := url.Values{}
+	if q.ID != "" {
+		v.Set("id", q.ID)
+	}
+	if q.Limit != 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return v
+}
+
+// PeerStore calls GET /peer_store:
+// Addresses, RTT, trust, capabilities and last error of the peers seen, most recently seen first, or of one peer
+func (c *Client) PeerStore(ctx context.Context, query PeerStoreQuery) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/peer_store", query.values(), nil, "", &out)
+	return out, err
+}
+
+// PeersQuery holds the query parameters of Peers. Zero values are left
+// out, so the node's defaults apply.
+type PeersQuery struct {
+	Key    string
+	Count  int
+	Radius int // default 160
+	Sample string
+}
+
+func (q PeersQuery) values() url.Values {
+	v := url.Values{}
+	if q.Key != "" {
+		v.Set("key", q.Key)
+	}
+	if q.Count != 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+	if q.Radius != 0 {
+		v.Set("radius", strconv.Itoa(q.Radius))
+	}
+	if q.Sample != "" {
+		v.Set("sample", q.Sample)
+	}
+	return v
+}
+
+// Peers calls GET /peers:
+// Sample known peers by a sampling policy, optionally within an XOR radius of a key
+func (c *Client) Peers(ctx context.Context, query PeersQuery) ([]Node, error) {
+	var out []Node
+	err := c.do(ctx, "GET", "/peers", query.values(), nil, "", &out)
+	return out, err
+}
+
+// PingQuery holds the query parameters of Ping. Zero values are left
+// out, so the node's defaults apply.
+type PingQuery struct {
+	ID      string
+	Port    int
+	Token   string
+	Network string
+	Filter  *bool
+}
+
+func (q PingQuery) values() url.Values {
+	v := url.Values{}
+	if q.ID != "" {
+		v.Set("id", q.ID)
+	}
+	if q.Port != 0 {
+		v.Set("port", strconv.Itoa(q.Port))
+	}
+	if q.Token != "" {
+		v.Set("token", q.Token)
+	}
+	if q.Network != "" {
+		v.Set("network", q.Network)
+	}
+	if q.Filter != nil {
+		v.Set("filter", strconv.FormatBool(*q.Filter))
+	}
+	return v
+}
+
+// Ping calls GET /ping:
+// Check the node is alive; a pinger sending its ID and port is added to the routing table
+func (c *Client) Ping(ctx context.Context, query PingQuery) (PingReply, error) {
+	var out PingReply
+	err := c.do(ctx, "GET", "/ping", query.values(), nil, "", &out)
+	return out, err
+}
+
+// PingContact calls POST /ping:
+// Ping with the sender's contact details, whose advertised IP is used instead of the connection's
+func (c *Client) PingContact(ctx context.Context, body Node) (PingReply, error) {
+	var out PingReply
+	err := c.do(ctx, "POST", "/ping", nil, body, "application/json", &out)
+	return out, err
+}
+
+// PollQuery holds the query parameters of Poll. Zero values are left
+// out, so the node's defaults apply.
+type PollQuery struct {
+	Topic        string // required
+	SubscriberID string // required
+	Timeout      int    // default 30
+}
+
+func (q PollQuery) values() url.Values {
+	v := url.Values{}
+	if q.Topic != "" {
+		v.Set("topic", q.Topic)
+	}
+	if q.SubscriberID != "" {
+		v.Set("subscriber_id", q.SubscriberID)
+	}
+	if q.Timeout != 0 {
+		v.Set("timeout", strconv.Itoa(q.Timeout))
+	}
+	return v
+}
+
+// Poll calls GET /poll:
+// Long-poll a subscriber's pending messages
+func (c *Client) Poll(ctx context.Context, query PollQuery) ([]TopicMessage, error) {
+	var out []TopicMessage
+	err := c.do(ctx, "GET", "/poll", query.values(), nil, "", &out)
+	return out, err
+}
+
+// PoolStats calls GET /pool_stats:
+// Outbound connection pool metrics
+func (c *Client) PoolStats(ctx context.Context) (PoolStats, error) {
+	var out PoolStats
+	err := c.do(ctx, "GET", "/pool_stats", nil, nil, "", &out)
+	return out, err
+}
+
+// Publish calls POST /publish:
+// Publish a message to a topic's subscribers
+func (c *Client) Publish(ctx context.Context, body PublishRequest) (PublishReply, error) {
+	var out PublishReply
+	err := c.do(ctx, "POST", "/publish", nil, body, "application/json", &out)
+	return out, err
+}
+
+// Punch calls POST /punch:
+// Ask this node to introduce you to a contact for UDP hole punching
+func (c *Client) Punch(ctx context.Context, body PunchRequest) (PunchReply, error) {
+	var out PunchReply
+	err := c.do(ctx, "POST", "/punch", nil, body, "application/json", &out)
+	return out, err
+}
+
+// PunchNotify calls POST /punch_notify:
+// Sent by a coordinator to the target of a punch, which starts probing the initiator
+func (c *Client) PunchNotify(ctx context.Context, body PunchRequest) (PunchReply, error) {
+	var out PunchReply
+	err := c.do(ctx, "POST", "/punch_notify", nil, body, "application/json", &out)
+	return out, err
+}
+
+// RoutingTable calls GET /routing_table:
+// Snapshot of up to 1000 routing table contacts
+func (c *Client) RoutingTable(ctx context.Context) ([]Node, error) {
+	var out []Node
+	err := c.do(ctx, "GET", "/routing_table", nil, nil, "", &out)
+	return out, err
+}
+
+// RPC calls POST /rpc:
+// Send any RPC in a message envelope, answered with one
+func (c *Client) RPC(ctx context.Context, body Message) (Message, error) {
+	var out Message
+	err := c.do(ctx, "POST", "/rpc", nil, body, "application/json", &out)
+	return out, err
+}
+
+// RPCStats calls GET /rpc_stats:
+// Request, error, panic and latency counts of inbound RPCs, by RPC
+func (c *Client) RPCStats(ctx context.Context) (map[string]RPCStats, error) {
+	var out map[string]RPCStats
+	err := c.do(ctx, "GET", "/rpc_stats", nil, nil, "", &out)
+	return out, err
+}
+
+// RuntimeStats calls GET /runtime_stats:
+// Latest sample of goroutine, heap and GC counters
+func (c *Client) RuntimeStats(ctx context.Context) (RuntimeStats, error) {
+	var out RuntimeStats
+	err := c.do(ctx, "GET", "/runtime_stats", nil, nil, "", &out)
+	return out, err
+}
+
+// StoreQuery holds the query parameters of Store. Zero values are left
+// out, so the node's defaults apply.
+type StoreQuery struct {
+	Hash *bool
+}
+
+func (q StoreQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// Store calls POST /store:
+// Store a value, or with replicate set store it on the k closest nodes; a node that is not among the closest answers 200 with closer contacts
+func (c *Client) Store(ctx context.Context, query StoreQuery, body StoreRequest) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "POST", "/store", query.values(), body, "application/json", &out)
+	return out, err
+}
+
+// StoreStats calls GET /store_stats:
+// How many STOREs were accepted, answered with closer contacts or refused outside the responsibility radius
+func (c *Client) StoreStats(ctx context.Context) (PlacementSnapshot, error) {
+	var out PlacementSnapshot
+	err := c.do(ctx, "GET", "/store_stats", nil, nil, "", &out)
+	return out, err
+}
+
+// Subscribe calls POST /subscribe:
+// Subscribe to a topic on its rendezvous node
+func (c *Client) Subscribe(ctx context.Context, body SubscribeRequest) (SubscribeReply, error) {
+	var out SubscribeReply
+	err := c.do(ctx, "POST", "/subscribe", nil, body, "application/json", &out)
+	return out, err
+}
+
+// SyncDigestQuery holds the query parameters of SyncDigest. Zero values are left
+// out, so the node's defaults apply.
+type SyncDigestQuery struct {
+	Target string // required
+	Radius int    // default 160
+	Bucket string
+}
+
+func (q SyncDigestQuery) values() url.Values {
+	v := url.Values{}
+	if q.Target != "" {
+		v.Set("target", q.Target)
+	}
+	if q.Radius != 0 {
+		v.Set("radius", strconv.Itoa(q.Radius))
+	}
+	if q.Bucket != "" {
+		v.Set("bucket", q.Bucket)
+	}
+	return v
+}
+
+// SyncDigest calls GET /sync_digest:
+// Per-bucket hashes of the records near a target, or the records of one bucket
+func (c *Client) SyncDigest(ctx context.Context, query SyncDigestQuery) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/sync_digest", query.values(), nil, "", &out)
+	return out, err
+}
+
+// SyncPush calls POST /sync_push:
+// Store records a replica found missing; existing keys are kept
+func (c *Client) SyncPush(ctx context.Context, body []KeyRecord) (SyncPushReply, error) {
+	var out SyncPushReply
+	err := c.do(ctx, "POST", "/sync_push", nil, body, "application/json", &out)
+	return out, err
+}
+
+// GatewayGetManyQuery holds the query parameters of GatewayGetMany. Zero values are left
+// out, so the node's defaults apply.
+type GatewayGetManyQuery struct {
+	Hash *bool // default true
+}
+
+func (q GatewayGetManyQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// GatewayGetMany calls POST /v1/keys:
+// Look the values of up to 64 keys up across the network in one batched lookup
+func (c *Client) GatewayGetMany(ctx context.Context, query GatewayGetManyQuery, body GatewayBatchRequest) (GatewayBatchReply, error) {
+	var out GatewayBatchReply
+	err := c.do(ctx, "POST", "/v1/keys", query.values(), body, "application/json", &out)
+	return out, err
+}
+
+// GatewayGetQuery holds the query parameters of GatewayGet. Zero values are left
+// out, so the node's defaults apply.
+type GatewayGetQuery struct {
+	Hash *bool // default true
+}
+
+func (q GatewayGetQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// GatewayGet calls GET /v1/keys/{key}:
+// Look a value up across the network
+func (c *Client) GatewayGet(ctx context.Context, key string, query GatewayGetQuery) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, "GET", "/v1/keys/"+url.PathEscape(key), query.values(), nil, "", &out)
+	return out, err
+}
+
+// GatewayPutQuery holds the query parameters of GatewayPut. Zero values are left
+// out, so the node's defaults apply.
+type GatewayPutQuery struct {
+	Hash *bool // default true
+}
+
+func (q GatewayPutQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// GatewayPut calls PUT /v1/keys/{key}:
+// Store the body as the value on the k closest nodes
+func (c *Client) GatewayPut(ctx context.Context, key string, query GatewayPutQuery, body io.Reader) (StoreAck, error) {
+	var out StoreAck
+	err := c.do(ctx, "PUT", "/v1/keys/"+url.PathEscape(key), query.values(), body, "text/plain", &out)
+	return out, err
+}
+
+// WatchQuery holds the query parameters of Watch. Zero values are left
+// out, so the node's defaults apply.
+type WatchQuery struct {
+	Hash *bool
+}
+
+func (q WatchQuery) values() url.Values {
+	v := url.Values{}
+	if q.Hash != nil {
+		v.Set("hash", strconv.FormatBool(*q.Hash))
+	}
+	return v
+}
+
+// Watch calls POST /watch:
+// Watch a stored key for changes for the next 10 minutes, or cancel the watch
+func (c *Client) Watch(ctx context.Context, query WatchQuery, body WatchRequest) (WatchReply, error) {
+	var out WatchReply
+	err := c.do(ctx, "POST", "/watch", query.values(), body, "application/json", &out)
+	return out, err
+}
+
+// WatchPollQuery holds the query parameters of WatchPoll. Zero values are left
+// out, so the node's defaults apply.
+type WatchPollQuery struct {
+	Key       string // required
+	WatcherID string // required
+	Since     int64
+	Timeout   int // default 30
+}
+
+func (q WatchPollQuery) values() url.Values {
+	v := url.Values{}
+	if q.Key != "" {
+		v.Set("key", q.Key)
+	}
+	if q.WatcherID != "" {
+		v.Set("watcher_id", q.WatcherID)
+	}
+	if q.Since != 0 {
+		v.Set("since", strconv.FormatInt(q.Since, 10))
+	}
+	if q.Timeout != 0 {
+		v.Set("timeout", strconv.Itoa(q.Timeout))
+	}
+	return v
+}
+
+// WatchPoll calls GET /watch_poll:
+// Long-poll a watched key for a version above since; 204 if none came in time, 404 if the watch lapsed
+func (c *Client) WatchPoll(ctx context.Context, query WatchPollQuery) (KeyChange, error) {
+	var out KeyChange
+	err := c.do(ctx, "GET", "/watch_poll", query.values(), nil, "", &out)
+	return out, err
+}
@@ -0,0 +1,376 @@
+// Package bencode implements BitTorrent bencoding, a compact alternative to
+// JSON on the wire. Struct fields are named by their `bencode` tag, then
+// their `json` tag, then the field name.
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Marshal returns the bencoding of v
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes bencoded data into the value pointed to by v. Decoding
+// into an empty interface yields string, int64, []interface{} and
+// map[string]interface{} values.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("bencode: Unmarshal requires a non-nil pointer")
+	}
+	d := &decoder{data: data}
+	if err := d.value(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("bencode: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return nil
+}
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+func encode(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		return errors.New("bencode: cannot encode nil")
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return errors.New("bencode: cannot encode nil")
+		}
+		return encode(buf, v.Elem())
+	case reflect.String:
+		writeString(buf, v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteString("i1e")
+		} else {
+			buf.WriteString("i0e")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString("i" + strconv.FormatInt(v.Int(), 10) + "e")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString("i" + strconv.FormatUint(v.Uint(), 10) + "e")
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			writeString(buf, string(b))
+			return nil
+		}
+		buf.WriteByte('l')
+		for i := 0; i < v.Len(); i++ {
+			if err := encode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("bencode: unsupported map key type %s", v.Type().Key())
+		}
+		entries := make(map[string]reflect.Value, v.Len())
+		for _, k := range v.MapKeys() {
+			entries[k.String()] = v.MapIndex(k)
+		}
+		return encodeDict(buf, entries)
+	case reflect.Struct:
+		entries := make(map[string]reflect.Value)
+		for _, f := range structFields(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			entries[f.name] = fv
+		}
+		return encodeDict(buf, entries)
+	default:
+		return fmt.Errorf("bencode: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// encodeDict writes entries with keys in sorted order, as bencoding requires
+func encodeDict(buf *bytes.Buffer, entries map[string]reflect.Value) error {
+	keys := make([]string, 0, len(entries))
+	for k, v := range entries {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('d')
+	for _, k := range keys {
+		writeString(buf, k)
+		if err := encode(buf, entries[k]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('e')
+	return nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("bencode")
+		if tag == "" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, field{name: name, index: f.Index, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errors.New("bencode: unexpected end of input")
+	}
+	return d.data[d.pos], nil
+}
+
+func (d *decoder) value(v reflect.Value) error {
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.value(v.Elem())
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		generic, err := d.generic()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(generic))
+		return nil
+	}
+
+	switch {
+	case c == 'i':
+		n, err := d.integer()
+		if err != nil {
+			return err
+		}
+		return setInt(v, n)
+	case c >= '0' && c <= '9':
+		s, err := d.string()
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(s)
+		case v.Type() == bytesType:
+			v.SetBytes([]byte(s))
+		default:
+			return fmt.Errorf("bencode: cannot decode string into %s", v.Type())
+		}
+		return nil
+	case c == 'l':
+		return d.list(v)
+	case c == 'd':
+		return d.dict(v)
+	}
+	return fmt.Errorf("bencode: invalid character %q at offset %d", c, d.pos)
+}
+
+func setInt(v reflect.Value, n int64) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(n) {
+			return fmt.Errorf("bencode: %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return fmt.Errorf("bencode: %d overflows %s", n, v.Type())
+		}
+		v.SetUint(uint64(n))
+	case reflect.Bool:
+		v.SetBool(n != 0)
+	default:
+		return fmt.Errorf("bencode: cannot decode integer into %s", v.Type())
+	}
+	return nil
+}
+
+func (d *decoder) integer() (int64, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 'e')
+	if end < 0 {
+		return 0, errors.New("bencode: unterminated integer")
+	}
+	n, err := strconv.ParseInt(string(d.data[d.pos+1:d.pos+end]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bencode: invalid integer at offset %d", d.pos)
+	}
+	d.pos += end + 1
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", errors.New("bencode: unterminated string length")
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("bencode: invalid string length at offset %d", d.pos)
+	}
+	start := d.pos + colon + 1
+	if n > len(d.data)-start {
+		return "", errors.New("bencode: string exceeds input")
+	}
+	d.pos = start + n
+	return string(d.data[start:d.pos]), nil
+}
+
+func (d *decoder) list(v reflect.Value) error {
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("bencode: cannot decode list into %s", v.Type())
+	}
+	d.pos++
+	items := reflect.MakeSlice(v.Type(), 0, 0)
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return err
+		}
+		if c == 'e' {
+			d.pos++
+			v.Set(items)
+			return nil
+		}
+		item := reflect.New(v.Type().Elem()).Elem()
+		if err := d.value(item); err != nil {
+			return err
+		}
+		items = reflect.Append(items, item)
+	}
+}
+
+func (d *decoder) dict(v reflect.Value) error {
+	var fields map[string]field
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("bencode: unsupported map key type %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	case reflect.Struct:
+		fields = make(map[string]field)
+		for _, f := range structFields(v.Type()) {
+			fields[f.name] = f
+		}
+	default:
+		return fmt.Errorf("bencode: cannot decode dictionary into %s", v.Type())
+	}
+
+	d.pos++
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return err
+		}
+		if c == 'e' {
+			d.pos++
+			return nil
+		}
+		key, err := d.string()
+		if err != nil {
+			return err
+		}
+
+		if v.Kind() == reflect.Map {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.value(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+			continue
+		}
+
+		f, ok := fields[key]
+		if !ok {
+			if _, err := d.generic(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.value(v.FieldByIndex(f.index)); err != nil {
+			return err
+		}
+	}
+}
+
+// generic decodes the next value into its natural Go representation
+func (d *decoder) generic() (interface{}, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == 'i':
+		return d.integer()
+	case c >= '0' && c <= '9':
+		return d.string()
+	case c == 'l':
+		var items []interface{}
+		err := d.list(reflect.ValueOf(&items).Elem())
+		return items, err
+	case c == 'd':
+		m := make(map[string]interface{})
+		err := d.dict(reflect.ValueOf(&m).Elem())
+		return m, err
+	}
+	return nil, fmt.Errorf("bencode: invalid character %q at offset %d", c, d.pos)
+}
@@ -0,0 +1,902 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Client talks to a Kademlia node over its HTTP RPC interface
+type Client struct {
+	Addr       string       // Address (ip:port, or a URL such as https://host) of the node used as entry point
+	HTTPClient *http.Client // HTTP client used for all requests
+	AdminToken string       // Admin token of the entry node, needed by ListKeys, Export and Import
+}
+
+// ImportBatchSize bounds the body of each request Import sends, keeping it
+// under the entry node's request body limit
+const ImportBatchSize = 1 << 20
+
+// NewClient creates a client using the node at addr as entry point. It
+// shares the pooled keep-alive HTTP client used for node-to-node RPCs.
+func NewClient(addr string) *Client {
+	return &Client{
+		Addr:       addr,
+		HTTPClient: network.Client(),
+	}
+}
+
+// FindNode asks the entry node for the closest nodes it knows to id
+func (c *Client) FindNode(ctx context.Context, id string) ([]*models.Node, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.FindNode")
+	defer span.End()
+
+	return c.findNode(ctx, id, 0)
+}
+
+// FindNodeN is FindNode asking for only count contacts, or the entry node's
+// k if count is larger or 0
+func (c *Client) FindNodeN(ctx context.Context, id string, count int) ([]*models.Node, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.FindNodeN")
+	defer span.End()
+
+	return c.findNode(ctx, id, count)
+}
+
+func (c *Client) findNode(ctx context.Context, id string, count int) ([]*models.Node, error) {
+	query := url.Values{"id": {id}}
+	if count > 0 {
+		query.Set("count", fmt.Sprintf("%d", count))
+	}
+
+	var nodes []*models.Node
+	err := c.getJSON(ctx, c.Addr, "/find_node?"+query.Encode(), &nodes)
+	return nodes, err
+}
+
+// Peers asks the entry node for a sample of up to count known peers, chosen
+// by the node's sampling policy. When key is non-empty only peers within
+// XOR distance 2^radius of key are returned; a negative radius means no
+// bound.
+func (c *Client) Peers(ctx context.Context, key string, radius, count int) ([]*models.Node, error) {
+	return c.PeersWithPolicy(ctx, key, radius, count, "")
+}
+
+// PeersWithPolicy is Peers with the sample chosen by the named sampling
+// policy, e.g. "closest" or "freshest", instead of the node's
+func (c *Client) PeersWithPolicy(ctx context.Context, key string, radius, count int, policy string) ([]*models.Node, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Peers")
+	defer span.End()
+
+	query := url.Values{}
+	if key != "" {
+		query.Set("key", key)
+	}
+	if policy != "" {
+		query.Set("sample", policy)
+	}
+	if radius >= 0 {
+		query.Set("radius", fmt.Sprintf("%d", radius))
+	}
+	if count > 0 {
+		query.Set("count", fmt.Sprintf("%d", count))
+	}
+
+	var nodes []*models.Node
+	err := c.getJSON(ctx, c.Addr, "/peers?"+query.Encode(), &nodes)
+	return nodes, err
+}
+
+// NodeInfo returns the entry node's software and protocol versions,
+// uptime, parameters and storage counts
+func (c *Client) NodeInfo(ctx context.Context) (kademlia.NodeInfo, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.NodeInfo")
+	defer span.End()
+
+	var info kademlia.NodeInfo
+	err := c.getJSON(ctx, c.Addr, "/node_info", &info)
+	return info, err
+}
+
+// IterateKeys fetches one page of the entry node's records within XOR
+// distance 2^radius of target. Pass the returned NextToken to fetch the
+// following page; it is empty on the last page.
+func (c *Client) IterateKeys(ctx context.Context, target string, radius, limit int, token string) (kademlia.KeyPage, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.IterateKeys")
+	defer span.End()
+
+	query := url.Values{}
+	query.Set("target", target)
+	query.Set("radius", fmt.Sprintf("%d", radius))
+	if limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	if token != "" {
+		query.Set("token", token)
+	}
+
+	var page kademlia.KeyPage
+	err := c.getJSON(ctx, c.Addr, "/iterate_keys?"+query.Encode(), &page)
+	return page, err
+}
+
+// Store has the entry node store value under key on the k nodes closest to
+// it. With hash set, key may be any string and is hashed into the keyspace
+// with kademlia.KeyFromString; the returned ack carries the hashed key.
+func (c *Client) Store(ctx context.Context, key, value string, hash bool) (kademlia.StoreAck, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Store")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	value, encoding := models.EncodeValue(value)
+	var ack kademlia.StoreAck
+	err := c.postJSON(ctx, c.Addr, "/store", kademlia.StoreRequest{Key: key, Value: value, Encoding: encoding, Replicate: true}, &ack)
+	return ack, err
+}
+
+// StoreWithIntervals is Store for a record that expires after ttl and
+// whose publisher stores it again every republish, instead of the nodes'
+// defaults. Nodes clamp both to their bounds; 0 keeps the default.
+func (c *Client) StoreWithIntervals(ctx context.Context, key, value string, ttl, republish time.Duration, hash bool) (kademlia.StoreAck, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.StoreWithIntervals")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	value, encoding := models.EncodeValue(value)
+	req := kademlia.StoreRequest{Key: key, Value: value, Encoding: encoding, Replicate: true, TTL: int(ttl / time.Second), Republish: int(republish / time.Second)}
+	var ack kademlia.StoreAck
+	err := c.postJSON(ctx, c.Addr, "/store", req, &ack)
+	return ack, err
+}
+
+// Delete has the entry node replace the value of key with a tombstone on
+// the k nodes closest to it. With hash set, key is hashed as by Store.
+func (c *Client) Delete(ctx context.Context, key string, hash bool) (kademlia.StoreAck, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Delete")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	var ack kademlia.StoreAck
+	err := c.postJSON(ctx, c.Addr, "/delete", kademlia.DeleteRequest{Key: key, Replicate: true}, &ack)
+	return ack, err
+}
+
+// StoreRecord has the entry node merge rec into the record of its type
+// stored under key on the k nodes closest to it, instead of replacing the
+// value as Store does. A key holding a value of another kind is refused.
+// With hash set, key is hashed as by Store.
+func (c *Client) StoreRecord(ctx context.Context, key string, rec models.CRDTRecord, hash bool) (kademlia.StoreAck, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.StoreRecord")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	var ack kademlia.StoreAck
+	err := c.postJSON(ctx, c.Addr, "/store", kademlia.StoreRequest{Key: key, Value: rec.Encode(), Type: rec.Type, Replicate: true}, &ack)
+	return ack, err
+}
+
+// GetRecord is Get for a record of type t. A key the entry node does not
+// hold yields an empty record.
+func (c *Client) GetRecord(ctx context.Context, key string, t models.RecordType, hash bool) (rec models.CRDTRecord, found bool, closest []*models.Node, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.GetRecord")
+	defer span.End()
+
+	value, found, closest, err := c.Get(ctx, key, hash)
+	if err != nil || !found {
+		return models.NewCRDTRecord(t), false, closest, err
+	}
+	rec, err = models.ParseCRDTRecord(value, t)
+	return rec, true, nil, err
+}
+
+// AcquireLease has the entry node take the lease on key for holder for
+// ttl, rounded up to whole seconds, on a majority of the k nodes closest to
+// key. Acquiring a lease the holder already holds renews it. It fails with
+// kademlia.ErrLeaseHeld if someone else holds the lease, and with
+// kademlia.ErrNoLeaseQuorum if too few replicas answered. With hash set,
+// key is hashed as by Store.
+//
+// Leases are best effort: a majority of replicas may be lost to churn, or
+// partitioned away, while the lease is held, and the replicas' clocks
+// decide when it lapses. Two holders may then believe they hold it, so
+// resources guarded by a lease should check its fencing token.
+func (c *Client) AcquireLease(ctx context.Context, key, holder string, ttl time.Duration, hash bool) (kademlia.Lease, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.AcquireLease")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	return c.lease(ctx, kademlia.LeaseRequest{Key: key, Holder: holder, TTL: leaseSeconds(ttl)})
+}
+
+// RenewLease extends lease for another ttl. It fails with
+// kademlia.ErrLeaseLost, returning the new lease, if lease lapsed and was
+// acquired again since, so another holder may have held it in between.
+func (c *Client) RenewLease(ctx context.Context, lease kademlia.Lease, ttl time.Duration) (kademlia.Lease, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.RenewLease")
+	defer span.End()
+
+	renewed, err := c.lease(ctx, kademlia.LeaseRequest{Key: lease.Key, Holder: lease.Holder, TTL: leaseSeconds(ttl)})
+	if err == nil && renewed.Token != lease.Token {
+		err = kademlia.ErrLeaseLost
+	}
+	return renewed, err
+}
+
+// ReleaseLease gives lease up on the nodes holding it
+func (c *Client) ReleaseLease(ctx context.Context, lease kademlia.Lease) error {
+	ctx, span := tracing.Tracer().Start(ctx, "client.ReleaseLease")
+	defer span.End()
+
+	_, err := c.lease(ctx, kademlia.LeaseRequest{Key: lease.Key, Holder: lease.Holder, Release: true})
+	return err
+}
+
+// lease sends req to the entry node to be granted by the k closest nodes
+func (c *Client) lease(ctx context.Context, req kademlia.LeaseRequest) (kademlia.Lease, error) {
+	req.Replicate = true
+	var ack kademlia.LeaseAck
+	if err := c.postJSON(ctx, c.Addr, "/lease", req, &ack); err != nil {
+		return kademlia.Lease{}, err
+	}
+	switch {
+	case ack.Granted:
+		return ack.Lease, nil
+	case ack.Lease.Holder != "":
+		return ack.Lease, kademlia.ErrLeaseHeld
+	default:
+		return ack.Lease, kademlia.ErrNoLeaseQuorum
+	}
+}
+
+// leaseSeconds returns ttl in whole seconds, rounded up
+func leaseSeconds(ttl time.Duration) int {
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+// Get asks the entry node for the value of key. If the node does not hold
+// it, found is false and the closest nodes to key it knows are returned for
+// continuing the lookup. With hash set, key is hashed as by Store.
+func (c *Client) Get(ctx context.Context, key string, hash bool) (value string, found bool, closest []*models.Node, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Get")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	var raw json.RawMessage
+	if err := c.getJSON(ctx, c.Addr, "/find_value?key="+url.QueryEscape(key), &raw); err != nil {
+		return "", false, nil, err
+	}
+	if json.Unmarshal(raw, &value) == nil {
+		return value, true, nil, nil
+	}
+	if json.Unmarshal(raw, &closest) == nil {
+		return "", false, closest, nil
+	}
+	// Binary values come as a reply carrying their encoding
+	var reply kademlia.FindValueReply
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return "", false, nil, err
+	}
+	if value, err = reply.Decoded(); err != nil {
+		return "", false, nil, err
+	}
+	return value, true, nil, nil
+}
+
+// GetMany is Get for several keys at once, sent to the entry node as
+// FIND_VALUES requests of up to kademlia.MaxFindValuesKeys keys. It returns
+// the values the node holds and its closest contacts to each other key,
+// both by key as given. With hash set, keys are hashed as by Store.
+func (c *Client) GetMany(ctx context.Context, keys []string, hash bool) (values map[string]string, closest map[string][]*models.Node, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.GetMany")
+	defer span.End()
+
+	path := "/find_values"
+	if hash {
+		path += "?hash=true"
+	}
+	values = make(map[string]string)
+	closest = make(map[string][]*models.Node)
+	for start := 0; start < len(keys); start += kademlia.MaxFindValuesKeys {
+		chunk := keys[start:min(start+kademlia.MaxFindValuesKeys, len(keys))]
+		var reply kademlia.FindValuesReply
+		if err := c.postJSON(ctx, c.Addr, path, kademlia.FindValuesRequest{Keys: chunk}, &reply); err != nil {
+			return nil, nil, err
+		}
+		// The reply is by key as the node used it
+		given := make(map[string]string, len(chunk))
+		for _, key := range chunk {
+			if hash {
+				given[kademlia.KeyFromString(key)] = key
+			} else {
+				given[key] = key
+			}
+		}
+		for _, found := range reply.Values {
+			value, err := models.DecodeValue(found.Value, found.Encoding)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[given[found.Key]] = value
+		}
+		for key := range reply.Closest {
+			closest[given[key]] = reply.ClosestTo(key)
+		}
+	}
+	return values, closest, nil
+}
+
+// GetMeta is Get returning the provenance of the value when the entry node
+// holds it: its publisher, when it was first and last stored, and the
+// STORE RPCs it travelled to get there
+func (c *Client) GetMeta(ctx context.Context, key string, hash bool) (reply kademlia.FindValueReply, found bool, closest []*models.Node, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.GetMeta")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	var raw json.RawMessage
+	if err := c.getJSON(ctx, c.Addr, "/find_value?meta=true&key="+url.QueryEscape(key), &raw); err != nil {
+		return reply, false, nil, err
+	}
+	if json.Unmarshal(raw, &closest) == nil {
+		return reply, false, closest, nil
+	}
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return reply, false, nil, err
+	}
+	if reply.Value, err = reply.Decoded(); err != nil {
+		return reply, false, nil, err
+	}
+	reply.Encoding = ""
+	return reply, true, nil, nil
+}
+
+// Head asks the entry node whether it holds key, returning the provenance
+// of the value without the value. With hash set, key is hashed as by Store.
+func (c *Client) Head(ctx context.Context, key string, hash bool) (meta models.RecordMeta, found bool, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Head")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, nodeURL(c.Addr, "/find_value?key="+url.QueryEscape(key)), nil)
+	if err != nil {
+		return meta, false, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return meta, false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return meta, false, nil
+	}
+	if err := decodeResponse(resp, nil); err != nil {
+		return meta, false, err
+	}
+	meta, err = kademlia.DecodeMetaHeader(resp.Header.Get(kademlia.MetaHeader))
+	return meta, err == nil, err
+}
+
+// GetIfChanged is Get for polling a value the caller already has: etag is
+// the one returned with it, "" for none, and the value is only sent again
+// if it changed. It returns the value and its ETag, or etag and an empty
+// value if the value is unchanged; found is false if the node does not
+// hold key. With hash set, key is hashed as by Store.
+func (c *Client) GetIfChanged(ctx context.Context, key, etag string, hash bool) (value, tag string, found bool, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.GetIfChanged")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL(c.Addr, "/find_value?meta=true&key="+url.QueryEscape(key)), nil)
+	if err != nil {
+		return "", "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", "", false, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return "", etag, true, nil
+	}
+	tag = resp.Header.Get("ETag")
+	var raw json.RawMessage
+	if err := decodeResponse(resp, &raw); err != nil {
+		return "", "", false, err
+	}
+	var reply kademlia.FindValueReply
+	if tag == "" || json.Unmarshal(raw, &reply) != nil {
+		// Not held, answered with the closest contacts
+		return "", "", false, nil
+	}
+	if value, err = reply.Decoded(); err != nil {
+		return "", "", false, err
+	}
+	return value, tag, true, nil
+}
+
+// AddProvider announces provider as a provider of key to the entry node
+func (c *Client) AddProvider(ctx context.Context, key string, provider *models.Node) error {
+	ctx, span := tracing.Tracer().Start(ctx, "client.AddProvider")
+	defer span.End()
+
+	req := map[string]interface{}{
+		"key":  key,
+		"id":   provider.ID,
+		"ip":   provider.IP,
+		"port": provider.Port,
+	}
+	return c.postJSON(ctx, c.Addr, "/add_provider", req, nil)
+}
+
+// GetProviders asks the entry node for the providers of key it knows, along
+// with the closest nodes to key for continuing the lookup
+func (c *Client) GetProviders(ctx context.Context, key string) ([]models.Node, []*models.Node, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.GetProviders")
+	defer span.End()
+
+	var resp struct {
+		Providers    []models.Node  `json:"providers"`
+		ClosestNodes []*models.Node `json:"closest_nodes"`
+	}
+	err := c.getJSON(ctx, c.Addr, "/get_providers?key="+url.QueryEscape(key), &resp)
+	return resp.Providers, resp.ClosestNodes, err
+}
+
+// Subscribe registers subscriberID for topic on the topic's rendezvous node.
+// If webhook is non-empty the node will POST every published message to it.
+func (c *Client) Subscribe(ctx context.Context, topic, subscriberID, webhook string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Subscribe")
+	defer span.End()
+
+	req := map[string]string{
+		"topic":         topic,
+		"subscriber_id": subscriberID,
+		"webhook":       webhook,
+	}
+	return c.postJSON(ctx, c.rendezvous(ctx, topic), "/subscribe", req, nil)
+}
+
+// Publish sends payload to every subscriber of topic and returns how many
+// subscribers it was delivered to.
+func (c *Client) Publish(ctx context.Context, topic, payload string) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Publish")
+	defer span.End()
+
+	req := map[string]string{
+		"topic":   topic,
+		"payload": payload,
+	}
+	var resp struct {
+		Delivered int `json:"delivered"`
+	}
+	err := c.postJSON(ctx, c.rendezvous(ctx, topic), "/publish", req, &resp)
+	return resp.Delivered, err
+}
+
+// Poll long-polls the topic's rendezvous node for messages addressed to
+// subscriberID, waiting at most timeout for new ones to arrive.
+func (c *Client) Poll(ctx context.Context, topic, subscriberID string, timeout time.Duration) ([]models.TopicMessage, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Poll")
+	defer span.End()
+
+	query := url.Values{}
+	query.Set("topic", topic)
+	query.Set("subscriber_id", subscriberID)
+	query.Set("timeout", fmt.Sprintf("%d", int(timeout.Seconds())))
+
+	var messages []models.TopicMessage
+	err := c.getJSON(ctx, c.rendezvous(ctx, topic), "/poll?"+query.Encode(), &messages)
+	return messages, err
+}
+
+// Watch registers watcherID's interest in key on the node closest to it,
+// which every store of the key reaches, and returns the key's state there.
+// With webhook set the node POSTs every change to it; otherwise poll with
+// WatchPoll. The watch lapses after kademlia.WatchTTL unless renewed by
+// watching again. With hash set, key is hashed as by Store.
+func (c *Client) Watch(ctx context.Context, key, watcherID, webhook string, hash bool) (kademlia.WatchReply, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Watch")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	var reply kademlia.WatchReply
+	err := c.postJSON(ctx, c.closestTo(ctx, key), "/watch", kademlia.WatchRequest{Key: key, WatcherID: watcherID, Webhook: webhook}, &reply)
+	if err == nil {
+		err = decodeChange(&reply.State)
+	}
+	return reply, err
+}
+
+// WatchPoll long-polls the node closest to key for a version of it above
+// since, waiting at most timeout. It reports false if none came in time,
+// and fails with status 404 once the watch lapsed. With hash set, key is
+// hashed as by Store.
+func (c *Client) WatchPoll(ctx context.Context, key, watcherID string, since uint64, timeout time.Duration, hash bool) (kademlia.KeyChange, bool, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.WatchPoll")
+	defer span.End()
+
+	if hash {
+		key = kademlia.KeyFromString(key)
+	}
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("watcher_id", watcherID)
+	query.Set("since", fmt.Sprintf("%d", since))
+	query.Set("timeout", fmt.Sprintf("%d", int(timeout.Seconds())))
+
+	var change kademlia.KeyChange
+	if err := c.getJSON(ctx, c.closestTo(ctx, key), "/watch_poll?"+query.Encode(), &change); err != nil {
+		return change, false, err
+	}
+	if err := decodeChange(&change); err != nil {
+		return change, false, err
+	}
+	return change, change.Seq > since, nil
+}
+
+// decodeChange replaces the value of change by its bytes
+func decodeChange(change *kademlia.KeyChange) error {
+	value, err := change.Decoded()
+	if err != nil {
+		return err
+	}
+	change.Value, change.Encoding = value, ""
+	return nil
+}
+
+// ListKeys lists one page of the keys stored on the entry node whose raw
+// key starts with the hex prefix. Pass the returned NextToken to fetch the
+// following page; it is empty on the last page.
+func (c *Client) ListKeys(ctx context.Context, prefix string, limit int, token string) (kademlia.KeyList, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.ListKeys")
+	defer span.End()
+
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	if limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	if token != "" {
+		query.Set("token", token)
+	}
+
+	var list kademlia.KeyList
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL(c.Addr, "/admin/keys?"+query.Encode()), nil)
+	if err != nil {
+		return list, err
+	}
+	req.Header.Set(middleware.AdminTokenHeader, c.AdminToken)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return list, err
+	}
+	err = decodeResponse(resp, &list)
+	return list, err
+}
+
+// Keyspace returns the histogram of the keys stored on the entry node and
+// the peers it knows over bins equal ranges of the keyspace, or
+// kademlia.DefaultKeyspaceBins if bins is 0
+func (c *Client) Keyspace(ctx context.Context, bins int) (kademlia.KeyspaceHistogram, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Keyspace")
+	defer span.End()
+
+	path := "/admin/keyspace"
+	if bins > 0 {
+		path += fmt.Sprintf("?bins=%d", bins)
+	}
+
+	var h kademlia.KeyspaceHistogram
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL(c.Addr, path), nil)
+	if err != nil {
+		return h, err
+	}
+	req.Header.Set(middleware.AdminTokenHeader, c.AdminToken)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return h, err
+	}
+	err = decodeResponse(resp, &h)
+	return h, err
+}
+
+// Jobs returns the periodic jobs scheduled on the entry node
+func (c *Client) Jobs(ctx context.Context) ([]kademlia.JobStatus, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Jobs")
+	defer span.End()
+	return c.jobs(ctx, http.MethodGet, "/admin/jobs")
+}
+
+// PauseJobs stops the entry node starting runs of the job called job, or
+// of every job if job is empty, and returns the jobs
+func (c *Client) PauseJobs(ctx context.Context, job string) ([]kademlia.JobStatus, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.PauseJobs")
+	defer span.End()
+	return c.jobs(ctx, http.MethodPost, "/admin/jobs/pause?job="+url.QueryEscape(job))
+}
+
+// ResumeJobs undoes PauseJobs for the job called job, or every job if job
+// is empty, and returns the jobs
+func (c *Client) ResumeJobs(ctx context.Context, job string) ([]kademlia.JobStatus, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.ResumeJobs")
+	defer span.End()
+	return c.jobs(ctx, http.MethodPost, "/admin/jobs/resume?job="+url.QueryEscape(job))
+}
+
+func (c *Client) jobs(ctx context.Context, method, path string) ([]kademlia.JobStatus, error) {
+	var jobs []kademlia.JobStatus
+	req, err := http.NewRequestWithContext(ctx, method, nodeURL(c.Addr, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(middleware.AdminTokenHeader, c.AdminToken)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeResponse(resp, &jobs)
+	return jobs, err
+}
+
+// Export writes an export of every record stored on the entry node to w
+func (c *Client) Export(ctx context.Context, w io.Writer) error {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Export")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL(c.Addr, "/admin/export"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(middleware.AdminTokenHeader, c.AdminToken)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeResponse(resp, nil)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Import stores the records of the export read from r on the entry node,
+// in batches of up to ImportBatchSize bytes. Existing keys are only
+// replaced if overwrite is set. The result counts the records of the
+// batches imported before any error.
+func (c *Client) Import(ctx context.Context, r io.Reader, overwrite bool) (kademlia.ImportResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client.Import")
+	defer span.End()
+
+	var total kademlia.ImportResult
+	send := func(batch []byte) error {
+		path := "/admin/import"
+		if overwrite {
+			path += "?overwrite=true"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, nodeURL(c.Addr, path), bytes.NewReader(batch))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set(middleware.AdminTokenHeader, c.AdminToken)
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+		var result kademlia.ImportResult
+		if err := decodeResponse(resp, &result); err != nil {
+			return err
+		}
+		total.Imported += result.Imported
+		total.Skipped += result.Skipped
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), ImportBatchSize)
+	var batch []byte
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(batch) > 0 && len(batch)+len(line)+1 > ImportBatchSize {
+			if err := send(batch); err != nil {
+				return total, err
+			}
+			batch = batch[:0]
+		}
+		batch = append(append(batch, line...), '\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return total, err
+	}
+	if len(batch) > 0 {
+		if err := send(batch); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// rendezvous returns the address of the node closest to the topic's key,
+// falling back to the entry node when the lookup fails.
+func (c *Client) rendezvous(ctx context.Context, topic string) string {
+	return c.closestTo(ctx, kademlia.TopicKey(topic))
+}
+
+// closestTo returns the address of the node closest to key, found by an
+// iterative lookup from the entry node, falling back to the entry node
+// when the lookup fails
+func (c *Client) closestTo(ctx context.Context, key string) string {
+	var entry *models.Node
+	if info, err := c.NodeInfo(ctx); err == nil {
+		entry = &models.Node{ID: info.NodeID}
+	}
+	closest := entry
+	queried := map[string]bool{c.Addr: true}
+	nodes, err := c.findNode(ctx, key, 0)
+	if err != nil {
+		return c.Addr
+	}
+	// Each round asks the alpha closest contacts not asked yet for theirs,
+	// until the closest contact found stops changing
+	for {
+		for _, n := range nodes {
+			if closer(key, n, closest) {
+				closest = n
+			}
+		}
+		sortByDistanceTo(key, nodes)
+		var round []*models.Node
+		for _, n := range nodes {
+			addr := fmt.Sprintf("%s:%d", n.IP, n.Port)
+			if !queried[addr] && len(round) < constants.GetAlpha() {
+				queried[addr] = true
+				round = append(round, n)
+			}
+		}
+		if len(round) == 0 {
+			break
+		}
+		found := nodes
+		seen := make(map[string]bool)
+		for _, n := range nodes {
+			seen[n.ID] = true
+		}
+		for _, n := range round {
+			var more []*models.Node
+			if err := c.getJSON(ctx, fmt.Sprintf("%s:%d", n.IP, n.Port), "/find_node?"+url.Values{"id": {key}}.Encode(), &more); err != nil {
+				continue
+			}
+			for _, m := range more {
+				if !seen[m.ID] {
+					seen[m.ID] = true
+					found = append(found, m)
+				}
+			}
+		}
+		best := closest
+		for _, n := range found {
+			if closer(key, n, best) {
+				best = n
+			}
+		}
+		nodes = found
+		if best == closest {
+			break
+		}
+	}
+	if closest == nil || closest == entry {
+		return c.Addr
+	}
+	return fmt.Sprintf("%s:%d", closest.IP, closest.Port)
+}
+
+// closer reports whether a is closer to key than b, which may be nil
+func closer(key string, a, b *models.Node) bool {
+	da, ok := kademlia.XORDistance(key, a.ID)
+	if !ok {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	db, ok := kademlia.XORDistance(key, b.ID)
+	return !ok || da.Cmp(db) < 0
+}
+
+// sortByDistanceTo sorts nodes closest to key first
+func sortByDistanceTo(key string, nodes []*models.Node) {
+	sort.SliceStable(nodes, func(i, j int) bool { return closer(key, nodes[i], nodes[j]) })
+}
+
+// nodeURL returns the URL of path on the node at addr. addr may carry a
+// scheme, as in "https://gateway.example.com", for nodes behind a TLS
+// proxy; plain HTTP is used otherwise.
+func nodeURL(addr, path string) string {
+	if strings.Contains(addr, "://") {
+		return strings.TrimSuffix(addr, "/") + path
+	}
+	return "http://" + addr + path
+}
+
+func (c *Client) getJSON(ctx context.Context, addr, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL(addr, path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+func (c *Client) postJSON(ctx context.Context, addr, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nodeURL(addr, path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d (request %s): %s", resp.StatusCode, resp.Header.Get(tracing.RequestIDHeader), bytes.TrimSpace(msg))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package clock abstracts the passage of time for expiry, timers and
+// LastSeen bookkeeping, so tests and simulations can replace the wall clock
+// with a Fake one and fast-forward it deterministically
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules timers
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on Chan every period until stopped
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) Chan() <-chan time.Time { return t.C }
+
+var (
+	mu      sync.RWMutex
+	current = Real
+)
+
+// Set makes c the clock used by the package-level functions, returning the
+// previous one so it can be restored. A nil c restores the wall clock.
+func Set(c Clock) Clock {
+	if c == nil {
+		c = Real
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	previous := current
+	current = c
+	return previous
+}
+
+// Default returns the clock in use
+func Default() Clock {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Now returns the current time of the clock in use
+func Now() time.Time {
+	return Default().Now()
+}
+
+// After waits for d on the clock in use
+func After(d time.Duration) <-chan time.Time {
+	return Default().After(d)
+}
+
+// NewTicker returns a ticker of period d on the clock in use
+func NewTicker(d time.Duration) Ticker {
+	return Default().NewTicker(d)
+}
+
+// Fake is a Clock that only moves when told to. Timers and tickers fire,
+// in deadline order, as Advance moves the time past their deadlines.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+type waiter struct {
+	at     time.Time
+	period time.Duration // 0 for a one-shot timer
+	ch     chan time.Time
+}
+
+// NewFake returns a Fake clock reading start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After fires once the clock has been advanced by d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &waiter{at: f.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- f.now
+		return w.ch
+	}
+	f.waiters = append(f.waiters, w)
+	return w.ch
+}
+
+// NewTicker returns a ticker firing every d of fake time. Like a real
+// ticker it drops ticks its reader is not ready for.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &waiter{at: f.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	return &fakeTicker{clock: f, w: w}
+}
+
+// Advance moves the clock forward by d, firing every timer and tick due
+// on the way
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := f.now.Add(d)
+	for {
+		sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+		if len(f.waiters) == 0 || f.waiters[0].at.After(end) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		select {
+		case w.ch <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+	}
+	f.now = end
+}
+
+// Waiters returns the number of pending timers and tickers, so a test can
+// wait until a goroutine is blocked on the clock before advancing it
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+type fakeTicker struct {
+	clock *Fake
+	w     *waiter
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, w := range t.clock.waiters {
+		if w == t.w {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package clock
+
+import "time"
+
+// MaxSkew is how far apart the clocks of two nodes may be before the
+// timestamps one sends the other are treated as wrong rather than skewed
+const MaxSkew = 5 * time.Minute
+
+// FromPeer maps t, a time read from a peer's clock, onto the local clock
+// reading now. Nothing a peer reports can have happened in our future, so
+// later times are clamped to now; ok is false if t was more than MaxSkew
+// ahead, a sign of a misconfigured clock rather than ordinary skew. A zero
+// t, meaning unknown, is returned as is.
+func FromPeer(t, now time.Time) (time.Time, bool) {
+	if t.IsZero() || !t.After(now) {
+		return t, true
+	}
+	return now, t.Sub(now) <= MaxSkew
+}
+
+// Expired reports whether deadline, set by a peer's clock, has passed at
+// now. The deadline counts as passed only once it is more than MaxSkew
+// behind, so a peer whose clock runs ahead cannot expire values early.
+func Expired(deadline, now time.Time) bool {
+	return now.Sub(deadline) > MaxSkew
+}
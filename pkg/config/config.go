@@ -0,0 +1,1338 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/models"
+)
+
+// Config holds the runtime configuration of a Kademlia node
+type Config struct {
+	NodeID    string // Hex node ID, generated at startup when empty
+	Host      string // Address the node listens on and advertises to peers
+	Advertise string // IP advertised to peers instead of Host, e.g. the public IP behind NAT
+	Port      int    // RPC port, 0 picks a free port
+	PunchPort int    // UDP port for hole punching probes, 0 disables hole punching
+	Bootstrap string // <ip>:<port>, <host>:<port> or DNS seed name to join through, or a comma separated list of them; empty to start a new network
+
+	// NetworkID names the network the node belongs to, e.g. "testnet".
+	// Peers announcing another network are refused, so a node pointed at
+	// another deployment's bootstrap address fails to join instead of
+	// merging the two routing tables. Empty is the default network, the
+	// one of nodes predating network IDs.
+	NetworkID string
+
+	// Allowlist makes the network private: only peers whose node ID, or
+	// "key:" and the hex public key of their signed record, is listed are
+	// added to the routing table or may replicate writes to the node.
+	// Empty admits every peer.
+	Allowlist []string
+
+	// MaxIDsPerIP caps the new node IDs a single IP may add to the routing
+	// table per IDsPerIPWindow, so one host cannot flood it with Sybil
+	// identities. 0 disables the limit.
+	MaxIDsPerIP    int
+	IDsPerIPWindow time.Duration
+
+	// MaxPerSubnetBucket and MaxPerSubnetTable cap the contacts sharing a
+	// /24 (IPv4) or /64 (IPv6) per bucket and in the whole routing table,
+	// so an attacker on one subnet cannot eclipse the node. 0 disables
+	// either limit.
+	MaxPerSubnetBucket int
+	MaxPerSubnetTable  int
+
+	// PeerSampling names the policy choosing the peers the node hands out
+	// at /peers: "random", "closest", "freshest" or one registered with
+	// kademlia.RegisterPeerSampler. MaxExchangePeers caps how many it
+	// hands out per request, however many are asked for; 0 disables the cap.
+	PeerSampling     string
+	MaxExchangePeers int
+
+	// Peers lists the <host>:<port> of pinned peers, e.g. stable
+	// infrastructure nodes, which are never evicted from the routing table
+	// and are dialed again every PeerRedialInterval if lost
+	Peers              []string
+	PeerRedialInterval time.Duration
+
+	// Seed, when non-zero, makes the CLI run in deterministic mode: node
+	// IDs, record keys, peer sampling and lookup reply order all derive
+	// from it, so test networks and simulations are reproducible
+	Seed int64
+
+	// ClientOnly makes the node a client that looks up, gets and puts
+	// values but refuses STOREs and does not advertise storage, e.g. for
+	// short-lived or mobile peers
+	ClientOnly bool
+
+	// RejectDistantStores makes the node refuse STOREs of keys farther
+	// from it than its k-th closest contact, so peers cannot exhaust its
+	// storage with keys it is not responsible for
+	RejectDistantStores bool
+
+	// RequireWriteTokens makes the node accept a STORE only with a write
+	// token it issued to the sender's address in a FIND_NODE or FIND_VALUE
+	// reply, so peers cannot write from addresses they were never reached at
+	RequireWriteTokens bool
+
+	// LogLevel is "debug" to add source locations and microseconds to log
+	// lines, "info" (or empty) for the default output, or "warn" to drop
+	// the progress messages printed to standard output, keeping logged
+	// warnings and errors
+	LogLevel string
+
+	// LogStorePlacement logs every STORE the node did not accept for being
+	// too far from the key, with the distance gap to the k-th closest
+	// contact; counts are always served at /store_stats
+	LogStorePlacement bool
+
+	K               int           // Bucket size and number of replicas per key
+	Alpha           int           // Contacts queried in parallel per lookup round
+	RefreshInterval time.Duration // Time between refreshes of the routing table buckets, 0 disables refresh
+	LookupCacheTTL  time.Duration // How long node lookup results are reused unless the contacts change, 0 disables caching
+
+	RPCTimeout time.Duration // Deadline applied to each outbound RPC
+	WireFormat string        // Encoding requested from peers: "json" or "bencode"
+	Pool       PoolConfig
+	Storage    StorageConfig
+	GC         GCConfig
+	Tracing    TracingConfig
+	Chaos      ChaosConfig
+	Mainline   MainlineConfig
+	Join       JoinConfig
+	Retry      RetryConfig
+	Server     ServerConfig
+	Relay      RelayConfig
+	Partition  PartitionConfig
+	Address    AddressConfig
+	Scheduler  SchedulerConfig
+
+	// AdaptiveTimeout makes lookups wait for each peer only its smoothed
+	// RTT plus four times its variation, at most RPCTimeout
+	AdaptiveTimeout bool
+
+	// DialBack makes the node ping a pinger back at the address it
+	// advertised, and add it to the routing table only if it answers with
+	// the same node ID
+	DialBack bool
+
+	// WebhookPrivate lets pub/sub subscribers and key watchers register
+	// webhooks on loopback, private and link-local addresses, which are
+	// refused by default so clients cannot make the node reach internal
+	// services
+	WebhookPrivate bool
+
+	// AntiEntropyInterval is the time between reconciliations with the
+	// closest contacts, 0 disables anti-entropy
+	AntiEntropyInterval time.Duration
+
+	// RepublishInterval is how often publishers are expected to store their
+	// records again; the closest holder of a record not refreshed for one
+	// and a half intervals republishes it until it expires. 0 disables
+	// backup republishing.
+	RepublishInterval time.Duration
+
+	// RecordIntervals bounds the expire and republish intervals STOREs may
+	// set for their record in place of GC.TTL and RepublishInterval
+	RecordIntervals models.RecordIntervals
+
+	// ForwardTTL is how long replica STOREs to unreachable peers are queued
+	// for delivery when the peer returns, retried every
+	// ForwardRetryInterval meanwhile; 0 disables the queue
+	ForwardTTL           time.Duration
+	ForwardRetryInterval time.Duration
+
+	// KeyFilterInterval is the time between fetches of the contacts' key
+	// filters, Bloom filters of the keys they store that let FIND_VALUE
+	// ask likely holders first. Filters are trusted for two intervals; 0
+	// disables fetching.
+	KeyFilterInterval time.Duration
+
+	// Namespaces holds the quota and token of each configured namespace;
+	// namespaces not listed are open and unlimited
+	Namespaces map[string]models.NamespacePolicy
+}
+
+// PoolConfig tunes the shared HTTP connection pool used for outbound RPCs
+type PoolConfig struct {
+	MaxIdleConns        int           // Idle keep-alive connections kept across all peers
+	MaxIdleConnsPerHost int           // Idle keep-alive connections kept per peer
+	MaxConnsPerHost     int           // Total connections per peer, 0 means unlimited
+	IdleConnTimeout     time.Duration // How long an idle connection is kept open
+	Compression         bool          // Ask peers for gzip-compressed responses
+}
+
+// StorageConfig selects the backend stored values are kept in
+type StorageConfig struct {
+	Backend string // "memory", "file" or "sqlite"
+	Path    string // Log file of the file backend, database of the sqlite backend
+}
+
+// GCConfig configures storage garbage collection
+type GCConfig struct {
+	Strategy string        // Eviction order under pressure: "ttl", "lru" or "distance"
+	MaxBytes int64         // Storage budget in bytes, 0 means unlimited
+	TTL      time.Duration // Maximum age of an entry, 0 means entries never expire
+	Interval time.Duration // Time between collections
+
+	TombstoneTTL time.Duration // How long deleted keys keep their tombstone, 0 means forever
+}
+
+// TracingConfig configures OpenTelemetry tracing of RPCs and lookups
+type TracingConfig struct {
+	Exporter    string  // "none", "stdout" or "otlp"
+	Endpoint    string  // OTLP/HTTP collector endpoint (host:port), e.g. Jaeger's 4318
+	Insecure    bool    // Use plain HTTP instead of HTTPS for the OTLP endpoint
+	ServiceName string  // service.name resource attribute
+	SampleRatio float64 // Fraction of root traces to sample, between 0 and 1
+}
+
+// ChaosConfig configures fault injection into RPC handling. It only takes
+// effect when Enabled is set, which the CLI does for the --chaos flag.
+type ChaosConfig struct {
+	Enabled     bool
+	Latency     time.Duration // Delay added before every RPC is handled
+	Jitter      time.Duration // Random extra delay of up to this much
+	DropRate    float64       // Fraction of RPCs whose connection is closed without a reply
+	CorruptRate float64       // Fraction of replies whose body is garbled
+}
+
+// JoinConfig configures how nodes joining in the background, as the CLI
+// does, retry the bootstrap node. The delay between attempts starts at
+// Backoff and doubles up to MaxBackoff.
+type JoinConfig struct {
+	Attempts   int // Join attempts before running standalone, 0 retries forever
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Background makes Node.Start return once serving and join in the
+	// background, retrying as above, instead of joining once before it
+	// returns and failing if that join fails
+	Background bool
+}
+
+// RetryConfig configures how transient failures of outbound RPCs, such as
+// join pings, lookup queries and replication writes, are retried. The
+// delay between attempts starts at Backoff and doubles up to MaxBackoff.
+type RetryConfig struct {
+	Attempts   int // Calls per RPC including the first, 1 disables retries
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Jitter     float64 // Fraction of each delay that is randomized, from 0 to 1
+}
+
+// ServerConfig configures the middleware applied to every inbound RPC
+type ServerConfig struct {
+	RateLimit float64 // RPCs per second allowed from each caller IP, 0 disables rate limiting
+	RateBurst int     // RPCs a caller may send at once before being limited
+	AuthToken string  // Bearer token required on every inbound RPC, empty disables auth
+	Pprof     bool    // Serve net/http/pprof profiles under /debug/pprof/, for diagnosing production nodes
+
+	// AdminToken enables the admin endpoints under /admin/, which require
+	// it in the X-Kademlia-Admin-Token header; empty disables them
+	AdminToken string
+
+	MaxConcurrent int   // Requests handled at once, beyond which callers get 503; 0 means unlimited
+	MaxPerIP      int   // Requests handled at once for each caller IP, beyond which it gets 429; 0 means unlimited
+	MaxBodyBytes  int64 // Largest request body accepted, 0 means unlimited
+
+	// AuditLog is a file to record every inbound RPC's caller, path,
+	// status, latency and sizes in, as JSON lines; empty disables it. It
+	// is rotated past AuditMaxBytes, keeping AuditBackups old files.
+	AuditLog      string
+	AuditMaxBytes int64
+	AuditBackups  int
+
+	Compression      bool // Gzip responses for callers that accept it
+	CompressMinBytes int  // Smallest response worth compressing
+
+	// CORSOrigins are the web origins, such as "https://app.example.com",
+	// allowed to call the RPCs from a browser, or "*" for any; empty
+	// disables cross-origin requests
+	CORSOrigins []string
+
+	// Gateway serves REST endpoints under /v1/ that run full lookups and
+	// stores on behalf of clients that do not speak the DHT. They require
+	// one of GatewayAPIKeys in the X-API-Key header instead of AuthToken;
+	// no keys leaves them open.
+	Gateway        bool
+	GatewayAPIKeys []string
+
+	// Handlers are the handler sets served on Port, every set when empty.
+	// Listeners serve sets on further addresses, such as the admin
+	// endpoints on a loopback address, or RPCs on IPv6 as well as IPv4.
+	Handlers  []string
+	Listeners []ListenerConfig
+
+	// AdminAddr moves the admin handler set off Port onto a server of its
+	// own bound to this <host>:<port>, so operators can firewall it apart
+	// from peer traffic; empty keeps it wherever Handlers puts it
+	AdminAddr string
+}
+
+// PortHandlers returns the handler sets served on Port: Handlers, or every
+// set when empty, less the admin set when AdminAddr serves it
+func (s ServerConfig) PortHandlers() []string {
+	sets := s.Handlers
+	if len(sets) == 0 {
+		sets = HandlerSets
+	}
+	if s.AdminAddr == "" {
+		return sets
+	}
+	var port []string
+	for _, set := range sets {
+		if set != HandlersAdmin {
+			port = append(port, set)
+		}
+	}
+	return port
+}
+
+// AllListeners returns Listeners followed by the admin listener on
+// AdminAddr, if set
+func (s ServerConfig) AllListeners() []ListenerConfig {
+	if s.AdminAddr == "" {
+		return s.Listeners
+	}
+	return append(append([]ListenerConfig{}, s.Listeners...), ListenerConfig{Addr: s.AdminAddr, Handlers: []string{HandlersAdmin}})
+}
+
+// Handler sets a listener can serve
+const (
+	HandlersRPC     = "rpc"     // Node-to-node RPCs and the OpenAPI document
+	HandlersAdmin   = "admin"   // Admin endpoints, /rpc_stats, /runtime_stats, /pool_stats, /store_stats, /churn_stats, /peer_store and pprof profiles
+	HandlersGateway = "gateway" // REST gateway under /v1/
+)
+
+// HandlerSets lists every handler set
+var HandlerSets = []string{HandlersRPC, HandlersAdmin, HandlersGateway}
+
+// ListenerConfig is an address the node serves some of its handler sets
+// on besides Port
+type ListenerConfig struct {
+	Addr     string   // <host>:<port> to bind, such as "127.0.0.1:9090" or "[::]:8080"
+	Handlers []string // Handler sets served
+}
+
+// RelayConfig configures forwarding of RPCs to nodes behind NAT
+type RelayConfig struct {
+	Enabled bool   // Forward RPCs to peers behind NAT that register with this node
+	Via     string // <ip>:<port> of a relay to receive RPCs through when this node cannot be reached directly
+}
+
+// PartitionConfig configures detection of the node being cut off from the
+// network, and rejoining through seeds when it is
+type PartitionConfig struct {
+	Interval  time.Duration // Time between probes of the least recently seen contacts, 0 disables detection
+	Sample    int           // Contacts probed per round
+	Threshold float64       // Fraction of probed contacts that must be unreachable to declare a partition
+	Seeds     []string      // Bootstrap addresses to rejoin through, Bootstrap when empty
+}
+
+// AddressConfig configures detection of changes to the node's advertised
+// IP, and announcing the new one to its contacts
+type AddressConfig struct {
+	Interval time.Duration // Time between checks of the IP contacts see the node at, 0 disables detection
+	Sample   int           // Contacts asked per check
+	Quorum   int           // Contacts that must agree on a new IP before the node adopts it
+}
+
+// SchedulerConfig configures the scheduler running the node's periodic
+// jobs, such as bucket refresh, republishing, expiry and anti-entropy
+type SchedulerConfig struct {
+	Jitter        float64 // Fraction of each delay between runs that is randomized, from 0 to 1
+	MaxConcurrent int     // Jobs running at once, 0 for no cap
+}
+
+// MainlineConfig configures the BitTorrent Mainline DHT (BEP 5)
+// compatibility transport
+type MainlineConfig struct {
+	Port    int      // UDP port for KRPC, 0 disables the transport
+	Routers []string // <host>:<port> of nodes used to join the Mainline DHT
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Host:            "127.0.0.1",
+		K:               20,
+		Alpha:           3,
+		RefreshInterval: time.Hour,
+		IDsPerIPWindow:  time.Hour,
+		LookupCacheTTL:  10 * time.Second,
+		RPCTimeout:      30 * time.Second,
+		WireFormat:      "json",
+		AdaptiveTimeout: true,
+		Pool: PoolConfig{
+			MaxIdleConns:        256,
+			MaxIdleConnsPerHost: 8,
+			MaxConnsPerHost:     32,
+			IdleConnTimeout:     90 * time.Second,
+			Compression:         true,
+		},
+		Storage: StorageConfig{
+			Backend: "memory",
+		},
+		GC: GCConfig{
+			Strategy: "ttl",
+			TTL:      24 * time.Hour,
+			Interval: time.Minute,
+
+			TombstoneTTL: 24 * time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "kademlia",
+			SampleRatio: 1,
+		},
+		Mainline: MainlineConfig{
+			Routers: []string{"router.bittorrent.com:6881", "dht.transmissionbt.com:6881"},
+		},
+		Join: JoinConfig{
+			Backoff:    time.Second,
+			MaxBackoff: time.Minute,
+		},
+		Retry: RetryConfig{
+			Attempts:   3,
+			Backoff:    50 * time.Millisecond,
+			MaxBackoff: time.Second,
+			Jitter:     0.2,
+		},
+		Server: ServerConfig{
+			RateBurst:     50,
+			MaxConcurrent: 1024,
+			MaxPerIP:      128,
+			MaxBodyBytes:  4 << 20,
+			AuditMaxBytes: 10 << 20,
+			AuditBackups:  3,
+
+			Compression:      true,
+			CompressMinBytes: 1024,
+		},
+		Partition: PartitionConfig{
+			Interval:  5 * time.Minute,
+			Sample:    8,
+			Threshold: 0.75,
+		},
+		Address: AddressConfig{
+			Interval: 5 * time.Minute,
+			Sample:   8,
+			Quorum:   3,
+		},
+		Scheduler: SchedulerConfig{
+			Jitter:        0.1,
+			MaxConcurrent: 2,
+		},
+		LogLevel:             "info",
+		AntiEntropyInterval:  10 * time.Minute,
+		RepublishInterval:    time.Hour,
+		ForwardTTL:           time.Hour,
+		ForwardRetryInterval: time.Minute,
+		KeyFilterInterval:    5 * time.Minute,
+		PeerRedialInterval:   30 * time.Second,
+		PeerSampling:         "random",
+		MaxExchangePeers:     50,
+		Namespaces:           make(map[string]models.NamespacePolicy),
+		RecordIntervals: models.RecordIntervals{
+			MinTTL:       time.Minute,
+			MaxTTL:       24 * time.Hour,
+			MinRepublish: 5 * time.Minute,
+			MaxRepublish: 24 * time.Hour,
+		},
+	}
+}
+
+// FromEnv returns the default configuration overridden by KADEMLIA_*
+// environment variables
+func FromEnv() (*Config, error) {
+	cfg := Default()
+
+	if v := os.Getenv("KADEMLIA_ADVERTISE_IP"); v != "" {
+		cfg.Advertise = v
+	}
+	if v := os.Getenv("KADEMLIA_NETWORK_ID"); v != "" {
+		cfg.NetworkID = v
+	}
+	if v := os.Getenv("KADEMLIA_ALLOWLIST"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				cfg.Allowlist = append(cfg.Allowlist, entry)
+			}
+		}
+	}
+	if v := os.Getenv("KADEMLIA_MAX_IDS_PER_IP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_IDS_PER_IP: %q", v)
+		}
+		cfg.MaxIDsPerIP = n
+	}
+	if v := os.Getenv("KADEMLIA_IDS_PER_IP_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_IDS_PER_IP_WINDOW: %q", v)
+		}
+		cfg.IDsPerIPWindow = d
+	}
+	if v := os.Getenv("KADEMLIA_MAX_PER_SUBNET_BUCKET"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_PER_SUBNET_BUCKET: %q", v)
+		}
+		cfg.MaxPerSubnetBucket = n
+	}
+	if v := os.Getenv("KADEMLIA_MAX_PER_SUBNET_TABLE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_PER_SUBNET_TABLE: %q", v)
+		}
+		cfg.MaxPerSubnetTable = n
+	}
+	if v := os.Getenv("KADEMLIA_PEER_SAMPLING"); v != "" {
+		cfg.PeerSampling = v
+	}
+	if v := os.Getenv("KADEMLIA_MAX_EXCHANGE_PEERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_EXCHANGE_PEERS: %q", v)
+		}
+		cfg.MaxExchangePeers = n
+	}
+	if v := os.Getenv("KADEMLIA_PEERS"); v != "" {
+		for _, peer := range strings.Split(v, ",") {
+			cfg.Peers = append(cfg.Peers, strings.TrimSpace(peer))
+		}
+	}
+	if v := os.Getenv("KADEMLIA_PEER_REDIAL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_PEER_REDIAL_INTERVAL: %q", v)
+		}
+		cfg.PeerRedialInterval = d
+	}
+	if v := os.Getenv("KADEMLIA_SEED"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_SEED: %q", v)
+		}
+		cfg.Seed = seed
+	}
+	if v := os.Getenv("KADEMLIA_CLIENT_ONLY"); v != "" {
+		clientOnly, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_CLIENT_ONLY: %q", v)
+		}
+		cfg.ClientOnly = clientOnly
+	}
+	if v := os.Getenv("KADEMLIA_REJECT_DISTANT_STORES"); v != "" {
+		reject, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_REJECT_DISTANT_STORES: %q", v)
+		}
+		cfg.RejectDistantStores = reject
+	}
+	if v := os.Getenv("KADEMLIA_REQUIRE_WRITE_TOKENS"); v != "" {
+		require, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_REQUIRE_WRITE_TOKENS: %q", v)
+		}
+		cfg.RequireWriteTokens = require
+	}
+	if v := os.Getenv("KADEMLIA_LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+	if v := os.Getenv("KADEMLIA_LOG_STORE_PLACEMENT"); v != "" {
+		logPlacement, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_LOG_STORE_PLACEMENT: %q", v)
+		}
+		cfg.LogStorePlacement = logPlacement
+	}
+	if v := os.Getenv("KADEMLIA_K"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_K: %q", v)
+		}
+		cfg.K = n
+	}
+	if v := os.Getenv("KADEMLIA_ALPHA"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_ALPHA: %q", v)
+		}
+		cfg.Alpha = n
+	}
+	if v := os.Getenv("KADEMLIA_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_REFRESH_INTERVAL: %q", v)
+		}
+		cfg.RefreshInterval = d
+	}
+	if v := os.Getenv("KADEMLIA_LOOKUP_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_LOOKUP_CACHE_TTL: %q", v)
+		}
+		cfg.LookupCacheTTL = d
+	}
+	if v := os.Getenv("KADEMLIA_TIMEOUT"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_TIMEOUT: %q", v)
+		}
+		cfg.RPCTimeout = time.Duration(seconds) * time.Second
+	}
+	if v := os.Getenv("KADEMLIA_ADAPTIVE_TIMEOUT"); v != "" {
+		adaptive, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_ADAPTIVE_TIMEOUT: %q", v)
+		}
+		cfg.AdaptiveTimeout = adaptive
+	}
+	if v := os.Getenv("KADEMLIA_DIAL_BACK"); v != "" {
+		dialBack, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_DIAL_BACK: %q", v)
+		}
+		cfg.DialBack = dialBack
+	}
+	if v := os.Getenv("KADEMLIA_WEBHOOK_PRIVATE"); v != "" {
+		private, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_WEBHOOK_PRIVATE: %q", v)
+		}
+		cfg.WebhookPrivate = private
+	}
+	if v := os.Getenv("KADEMLIA_WIRE_FORMAT"); v != "" {
+		switch v {
+		case "json", "bencode":
+			cfg.WireFormat = v
+		default:
+			return nil, fmt.Errorf("invalid KADEMLIA_WIRE_FORMAT: %q", v)
+		}
+	}
+	if v := os.Getenv("KADEMLIA_POOL_MAX_IDLE_PER_HOST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_POOL_MAX_IDLE_PER_HOST: %q", v)
+		}
+		cfg.Pool.MaxIdleConnsPerHost = n
+	}
+	if v := os.Getenv("KADEMLIA_POOL_MAX_CONNS_PER_HOST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_POOL_MAX_CONNS_PER_HOST: %q", v)
+		}
+		cfg.Pool.MaxConnsPerHost = n
+	}
+	if v := os.Getenv("KADEMLIA_POOL_COMPRESSION"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_POOL_COMPRESSION: %q", v)
+		}
+		cfg.Pool.Compression = enabled
+	}
+	if v := os.Getenv("KADEMLIA_STORAGE_BACKEND"); v != "" {
+		switch v {
+		case "memory", "file", "sqlite":
+			cfg.Storage.Backend = v
+		default:
+			return nil, fmt.Errorf("invalid KADEMLIA_STORAGE_BACKEND: %q", v)
+		}
+	}
+	if v := os.Getenv("KADEMLIA_STORAGE_PATH"); v != "" {
+		cfg.Storage.Path = v
+	}
+	if v := os.Getenv("KADEMLIA_GC_STRATEGY"); v != "" {
+		switch v {
+		case "ttl", "lru", "distance":
+			cfg.GC.Strategy = v
+		default:
+			return nil, fmt.Errorf("invalid KADEMLIA_GC_STRATEGY: %q", v)
+		}
+	}
+	if v := os.Getenv("KADEMLIA_GC_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_GC_MAX_BYTES: %q", v)
+		}
+		cfg.GC.MaxBytes = n
+	}
+	if v := os.Getenv("KADEMLIA_GC_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_GC_TTL: %q", v)
+		}
+		cfg.GC.TTL = d
+	}
+	if v := os.Getenv("KADEMLIA_GC_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_GC_INTERVAL: %q", v)
+		}
+		cfg.GC.Interval = d
+	}
+	if v := os.Getenv("KADEMLIA_GC_TOMBSTONE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_GC_TOMBSTONE_TTL: %q", v)
+		}
+		cfg.GC.TombstoneTTL = d
+	}
+	if v := os.Getenv("KADEMLIA_ANTI_ENTROPY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_ANTI_ENTROPY_INTERVAL: %q", v)
+		}
+		cfg.AntiEntropyInterval = d
+	}
+	if v := os.Getenv("KADEMLIA_REPUBLISH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_REPUBLISH_INTERVAL: %q", v)
+		}
+		cfg.RepublishInterval = d
+	}
+	if v := os.Getenv("KADEMLIA_MIN_RECORD_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MIN_RECORD_TTL: %q", v)
+		}
+		cfg.RecordIntervals.MinTTL = d
+	}
+	if v := os.Getenv("KADEMLIA_MAX_RECORD_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_RECORD_TTL: %q", v)
+		}
+		cfg.RecordIntervals.MaxTTL = d
+	}
+	if v := os.Getenv("KADEMLIA_MIN_RECORD_REPUBLISH"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MIN_RECORD_REPUBLISH: %q", v)
+		}
+		cfg.RecordIntervals.MinRepublish = d
+	}
+	if v := os.Getenv("KADEMLIA_MAX_RECORD_REPUBLISH"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_RECORD_REPUBLISH: %q", v)
+		}
+		cfg.RecordIntervals.MaxRepublish = d
+	}
+	if v := os.Getenv("KADEMLIA_FORWARD_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_FORWARD_TTL: %q", v)
+		}
+		cfg.ForwardTTL = d
+	}
+	if v := os.Getenv("KADEMLIA_FORWARD_RETRY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_FORWARD_RETRY_INTERVAL: %q", v)
+		}
+		cfg.ForwardRetryInterval = d
+	}
+	if v := os.Getenv("KADEMLIA_KEY_FILTER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_KEY_FILTER_INTERVAL: %q", v)
+		}
+		cfg.KeyFilterInterval = d
+	}
+	if v := os.Getenv("KADEMLIA_JOIN_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_JOIN_ATTEMPTS: %q", v)
+		}
+		cfg.Join.Attempts = n
+	}
+	if v := os.Getenv("KADEMLIA_JOIN_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_JOIN_BACKOFF: %q", v)
+		}
+		cfg.Join.Backoff = d
+	}
+	if v := os.Getenv("KADEMLIA_JOIN_MAX_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_JOIN_MAX_BACKOFF: %q", v)
+		}
+		cfg.Join.MaxBackoff = d
+	}
+	if v := os.Getenv("KADEMLIA_RETRY_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid KADEMLIA_RETRY_ATTEMPTS: %q", v)
+		}
+		cfg.Retry.Attempts = n
+	}
+	if v := os.Getenv("KADEMLIA_RETRY_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_RETRY_BACKOFF: %q", v)
+		}
+		cfg.Retry.Backoff = d
+	}
+	if v := os.Getenv("KADEMLIA_RETRY_MAX_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_RETRY_MAX_BACKOFF: %q", v)
+		}
+		cfg.Retry.MaxBackoff = d
+	}
+	if v := os.Getenv("KADEMLIA_RETRY_JITTER"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid KADEMLIA_RETRY_JITTER: %q", v)
+		}
+		cfg.Retry.Jitter = f
+	}
+	if v := os.Getenv("KADEMLIA_RATE_LIMIT"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_RATE_LIMIT: %q", v)
+		}
+		cfg.Server.RateLimit = rate
+	}
+	if v := os.Getenv("KADEMLIA_RATE_BURST"); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_RATE_BURST: %q", v)
+		}
+		cfg.Server.RateBurst = burst
+	}
+	if v := os.Getenv("KADEMLIA_AUTH_TOKEN"); v != "" {
+		cfg.Server.AuthToken = v
+	}
+	if v := os.Getenv("KADEMLIA_ADMIN_TOKEN"); v != "" {
+		cfg.Server.AdminToken = v
+	}
+	if v := os.Getenv("KADEMLIA_ADMIN_ADDR"); v != "" {
+		cfg.Server.AdminAddr = v
+	}
+	if v := os.Getenv("KADEMLIA_PPROF"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_PPROF: %q", v)
+		}
+		cfg.Server.Pprof = enabled
+	}
+	if v := os.Getenv("KADEMLIA_MAX_CONCURRENT_REQUESTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_CONCURRENT_REQUESTS: %q", v)
+		}
+		cfg.Server.MaxConcurrent = n
+	}
+	if v := os.Getenv("KADEMLIA_MAX_REQUESTS_PER_IP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_REQUESTS_PER_IP: %q", v)
+		}
+		cfg.Server.MaxPerIP = n
+	}
+	if v := os.Getenv("KADEMLIA_MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAX_BODY_BYTES: %q", v)
+		}
+		cfg.Server.MaxBodyBytes = n
+	}
+	if v := os.Getenv("KADEMLIA_AUDIT_LOG"); v != "" {
+		cfg.Server.AuditLog = v
+	}
+	if v := os.Getenv("KADEMLIA_AUDIT_LOG_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_AUDIT_LOG_MAX_BYTES: %q", v)
+		}
+		cfg.Server.AuditMaxBytes = n
+	}
+	if v := os.Getenv("KADEMLIA_AUDIT_LOG_BACKUPS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_AUDIT_LOG_BACKUPS: %q", v)
+		}
+		cfg.Server.AuditBackups = n
+	}
+	if v := os.Getenv("KADEMLIA_COMPRESSION"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_COMPRESSION: %q", v)
+		}
+		cfg.Server.Compression = enabled
+	}
+	if v := os.Getenv("KADEMLIA_COMPRESS_MIN_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_COMPRESS_MIN_BYTES: %q", v)
+		}
+		cfg.Server.CompressMinBytes = n
+	}
+	if v := os.Getenv("KADEMLIA_CORS_ORIGINS"); v != "" {
+		for _, origin := range strings.Split(v, ",") {
+			cfg.Server.CORSOrigins = append(cfg.Server.CORSOrigins, strings.TrimSpace(origin))
+		}
+	}
+	if v := os.Getenv("KADEMLIA_GATEWAY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_GATEWAY: %q", v)
+		}
+		cfg.Server.Gateway = enabled
+	}
+	if v := os.Getenv("KADEMLIA_GATEWAY_API_KEYS"); v != "" {
+		for _, key := range strings.Split(v, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				cfg.Server.GatewayAPIKeys = append(cfg.Server.GatewayAPIKeys, key)
+			}
+		}
+	}
+	if v := os.Getenv("KADEMLIA_HANDLERS"); v != "" {
+		cfg.Server.Handlers = splitHandlers(v, ",")
+	}
+	if v := os.Getenv("KADEMLIA_LISTENERS"); v != "" {
+		listeners, err := parseListeners(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_LISTENERS: %v", err)
+		}
+		cfg.Server.Listeners = listeners
+	}
+	if v := os.Getenv("KADEMLIA_RELAY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_RELAY: %q", v)
+		}
+		cfg.Relay.Enabled = enabled
+	}
+	if v := os.Getenv("KADEMLIA_RELAY_VIA"); v != "" {
+		cfg.Relay.Via = v
+	}
+	if v := os.Getenv("KADEMLIA_PUNCH_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_PUNCH_PORT: %q", v)
+		}
+		cfg.PunchPort = port
+	}
+	if v := os.Getenv("KADEMLIA_PARTITION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_PARTITION_INTERVAL: %q", v)
+		}
+		cfg.Partition.Interval = d
+	}
+	if v := os.Getenv("KADEMLIA_PARTITION_SAMPLE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_PARTITION_SAMPLE: %q", v)
+		}
+		cfg.Partition.Sample = n
+	}
+	if v := os.Getenv("KADEMLIA_PARTITION_THRESHOLD"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_PARTITION_THRESHOLD: %q", v)
+		}
+		cfg.Partition.Threshold = threshold
+	}
+	if v := os.Getenv("KADEMLIA_ADDRESS_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_ADDRESS_CHECK_INTERVAL: %q", v)
+		}
+		cfg.Address.Interval = d
+	}
+	if v := os.Getenv("KADEMLIA_ADDRESS_CHECK_SAMPLE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_ADDRESS_CHECK_SAMPLE: %q", v)
+		}
+		cfg.Address.Sample = n
+	}
+	if v := os.Getenv("KADEMLIA_ADDRESS_QUORUM"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_ADDRESS_QUORUM: %q", v)
+		}
+		cfg.Address.Quorum = n
+	}
+	if v := os.Getenv("KADEMLIA_SCHEDULER_JITTER"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid KADEMLIA_SCHEDULER_JITTER: %q", v)
+		}
+		cfg.Scheduler.Jitter = f
+	}
+	if v := os.Getenv("KADEMLIA_SCHEDULER_MAX_CONCURRENT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_SCHEDULER_MAX_CONCURRENT: %q", v)
+		}
+		cfg.Scheduler.MaxConcurrent = n
+	}
+	if v := os.Getenv("KADEMLIA_SEEDS"); v != "" {
+		for _, seed := range strings.Split(v, ",") {
+			cfg.Partition.Seeds = append(cfg.Partition.Seeds, strings.TrimSpace(seed))
+		}
+	}
+	if v := os.Getenv("KADEMLIA_TRACING_EXPORTER"); v != "" {
+		cfg.Tracing.Exporter = v
+	}
+	if v := os.Getenv("KADEMLIA_TRACING_ENDPOINT"); v != "" {
+		cfg.Tracing.Endpoint = v
+	}
+	if v := os.Getenv("KADEMLIA_TRACING_INSECURE"); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_TRACING_INSECURE: %v", err)
+		}
+		cfg.Tracing.Insecure = insecure
+	}
+	if v := os.Getenv("KADEMLIA_TRACING_SERVICE_NAME"); v != "" {
+		cfg.Tracing.ServiceName = v
+	}
+	if v := os.Getenv("KADEMLIA_TRACING_SAMPLE_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid KADEMLIA_TRACING_SAMPLE_RATIO: %q", v)
+		}
+		cfg.Tracing.SampleRatio = ratio
+	}
+	if v := os.Getenv("KADEMLIA_CHAOS_LATENCY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_CHAOS_LATENCY: %q", v)
+		}
+		cfg.Chaos.Latency = d
+	}
+	if v := os.Getenv("KADEMLIA_CHAOS_JITTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KADEMLIA_CHAOS_JITTER: %q", v)
+		}
+		cfg.Chaos.Jitter = d
+	}
+	if v := os.Getenv("KADEMLIA_CHAOS_DROP_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid KADEMLIA_CHAOS_DROP_RATE: %q", v)
+		}
+		cfg.Chaos.DropRate = rate
+	}
+	if v := os.Getenv("KADEMLIA_CHAOS_CORRUPT_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid KADEMLIA_CHAOS_CORRUPT_RATE: %q", v)
+		}
+		cfg.Chaos.CorruptRate = rate
+	}
+	if v := os.Getenv("KADEMLIA_MAINLINE_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid KADEMLIA_MAINLINE_PORT: %q", v)
+		}
+		cfg.Mainline.Port = port
+	}
+	if v := os.Getenv("KADEMLIA_MAINLINE_ROUTERS"); v != "" {
+		cfg.Mainline.Routers = strings.Split(v, ",")
+	}
+
+	if v := os.Getenv("KADEMLIA_NAMESPACES"); v != "" {
+		namespaces, err := parseNamespaces(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KADEMLIA_NAMESPACES: %v", err)
+		}
+		cfg.Namespaces = namespaces
+	}
+
+	switch cfg.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %q", cfg.Tracing.Exporter)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Flags returns the capability flags the node advertises: those of a full
+// node, without storage in client-only mode and with relaying when enabled
+func (c *Config) Flags() models.CapabilityFlags {
+	flags := models.DefaultFlags
+	if c.ClientOnly {
+		flags &^= models.FlagStorage
+	}
+	if c.Relay.Enabled {
+		flags |= models.FlagRelay
+	}
+	return flags
+}
+
+// RejoinSeeds returns the addresses to rejoin through after a partition:
+// the configured seeds, or else the bootstrap address
+func (c *Config) RejoinSeeds() []string {
+	if len(c.Partition.Seeds) > 0 {
+		return c.Partition.Seeds
+	}
+	if c.Bootstrap != "" {
+		return []string{c.Bootstrap}
+	}
+	return nil
+}
+
+// Validate reports the first setting that would make the node misbehave,
+// so it can refuse to start instead of running with it
+func (c *Config) Validate() error {
+	if c.K < 1 {
+		return fmt.Errorf("k must be at least 1, got %d", c.K)
+	}
+	if c.Alpha < 1 {
+		return fmt.Errorf("alpha must be at least 1, got %d", c.Alpha)
+	}
+	if c.RefreshInterval < 0 {
+		return fmt.Errorf("refresh interval must not be negative, got %v", c.RefreshInterval)
+	}
+	if _, err := models.NewAllowlist(c.Allowlist); err != nil {
+		return err
+	}
+	if c.MaxIDsPerIP < 0 {
+		return fmt.Errorf("max IDs per IP must not be negative, got %d", c.MaxIDsPerIP)
+	}
+	if c.MaxIDsPerIP > 0 && c.IDsPerIPWindow <= 0 {
+		return fmt.Errorf("IDs per IP window must be positive, got %v", c.IDsPerIPWindow)
+	}
+	if c.LookupCacheTTL < 0 {
+		return fmt.Errorf("lookup cache TTL must not be negative, got %v", c.LookupCacheTTL)
+	}
+	if b := c.RecordIntervals; b.MinTTL < 0 || b.MaxTTL < 0 || b.MinRepublish < 0 || b.MaxRepublish < 0 {
+		return fmt.Errorf("record interval bounds must not be negative, got %+v", b)
+	}
+	if b := c.RecordIntervals; b.MaxTTL > 0 && b.MinTTL > b.MaxTTL {
+		return fmt.Errorf("minimum record TTL %v exceeds the maximum %v", b.MinTTL, b.MaxTTL)
+	}
+	if b := c.RecordIntervals; b.MaxRepublish > 0 && b.MinRepublish > b.MaxRepublish {
+		return fmt.Errorf("minimum record republish interval %v exceeds the maximum %v", b.MinRepublish, b.MaxRepublish)
+	}
+	if c.ForwardTTL < 0 {
+		return fmt.Errorf("forward TTL must not be negative, got %v", c.ForwardTTL)
+	}
+	if c.ForwardTTL > 0 && c.ForwardRetryInterval <= 0 {
+		return fmt.Errorf("forward retry interval must be positive, got %v", c.ForwardRetryInterval)
+	}
+	if c.MaxPerSubnetBucket < 0 || c.MaxPerSubnetTable < 0 {
+		return fmt.Errorf("subnet limits must not be negative, got %d per bucket and %d per table", c.MaxPerSubnetBucket, c.MaxPerSubnetTable)
+	}
+	if c.MaxExchangePeers < 0 {
+		return fmt.Errorf("max exchange peers must not be negative, got %d", c.MaxExchangePeers)
+	}
+	if !models.ValidNetworkID(c.NetworkID) {
+		return fmt.Errorf("network ID must be at most %d letters, digits, '.', '_' or '-', got %q", models.MaxNetworkIDLength, c.NetworkID)
+	}
+	switch c.LogLevel {
+	case "", "debug", "info", "warn":
+	default:
+		return fmt.Errorf("unknown log level %q", c.LogLevel)
+	}
+	switch c.Storage.Backend {
+	case "memory":
+	case "file", "sqlite":
+		if c.Storage.Path == "" {
+			return fmt.Errorf("storage backend %q needs a path", c.Storage.Backend)
+		}
+	default:
+		return fmt.Errorf("unknown storage backend %q", c.Storage.Backend)
+	}
+	if c.RPCTimeout <= 0 {
+		return fmt.Errorf("RPC timeout must be positive, got %v", c.RPCTimeout)
+	}
+	if c.GC.TTL < 0 {
+		return fmt.Errorf("expiry must not be negative, got %v", c.GC.TTL)
+	}
+	if c.GC.TombstoneTTL < 0 {
+		return fmt.Errorf("tombstone expiry must not be negative, got %v", c.GC.TombstoneTTL)
+	}
+	if c.Join.Attempts < 0 {
+		return fmt.Errorf("join attempts must not be negative, got %d", c.Join.Attempts)
+	}
+	if c.Join.Backoff <= 0 || c.Join.MaxBackoff < c.Join.Backoff {
+		return fmt.Errorf("join backoff must be positive and at most the maximum backoff, got %v and %v", c.Join.Backoff, c.Join.MaxBackoff)
+	}
+	if c.Retry.Attempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1, got %d", c.Retry.Attempts)
+	}
+	if c.Retry.Backoff < 0 || c.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative, got %v and %v", c.Retry.Backoff, c.Retry.MaxBackoff)
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %v", c.Retry.Jitter)
+	}
+	if c.Server.RateLimit < 0 {
+		return fmt.Errorf("rate limit must not be negative, got %v", c.Server.RateLimit)
+	}
+	if c.Server.RateLimit > 0 && c.Server.RateBurst < 1 {
+		return fmt.Errorf("rate burst must be at least 1, got %d", c.Server.RateBurst)
+	}
+	if c.Server.MaxConcurrent < 0 || c.Server.MaxPerIP < 0 || c.Server.MaxBodyBytes < 0 {
+		return fmt.Errorf("server limits must not be negative, got %d, %d and %d", c.Server.MaxConcurrent, c.Server.MaxPerIP, c.Server.MaxBodyBytes)
+	}
+	if c.Server.AuditMaxBytes < 0 || c.Server.AuditBackups < 0 {
+		return fmt.Errorf("audit log size and backups must not be negative, got %d and %d", c.Server.AuditMaxBytes, c.Server.AuditBackups)
+	}
+	if c.Server.CompressMinBytes < 0 {
+		return fmt.Errorf("compression threshold must not be negative, got %d", c.Server.CompressMinBytes)
+	}
+	if err := validateHandlers(c.Server.Handlers); err != nil {
+		return err
+	}
+	if len(c.Server.Handlers) > 0 && !contains(c.Server.Handlers, HandlersRPC) {
+		return fmt.Errorf("the node's port must serve the %q handlers, got %v", HandlersRPC, c.Server.Handlers)
+	}
+	if c.Server.AdminAddr != "" {
+		if _, port, err := net.SplitHostPort(c.Server.AdminAddr); err != nil || port == "" {
+			return fmt.Errorf("admin address %q is not <host>:<port>", c.Server.AdminAddr)
+		}
+	}
+	for _, l := range c.Server.Listeners {
+		if _, port, err := net.SplitHostPort(l.Addr); err != nil || port == "" {
+			return fmt.Errorf("listener address %q is not <host>:<port>", l.Addr)
+		}
+		if len(l.Handlers) == 0 {
+			return fmt.Errorf("listener %s serves no handlers", l.Addr)
+		}
+		if err := validateHandlers(l.Handlers); err != nil {
+			return err
+		}
+	}
+	if c.Relay.Via != "" {
+		if host, port, err := net.SplitHostPort(c.Relay.Via); err != nil || host == "" || port == "" {
+			return fmt.Errorf("relay address %q is not <host>:<port>", c.Relay.Via)
+		}
+	}
+	for _, peer := range c.Peers {
+		if host, port, err := net.SplitHostPort(peer); err != nil || host == "" || port == "" {
+			return fmt.Errorf("pinned peer address %q is not <host>:<port>", peer)
+		}
+	}
+	if len(c.Peers) > 0 && c.PeerRedialInterval <= 0 {
+		return fmt.Errorf("peer redial interval must be positive, got %v", c.PeerRedialInterval)
+	}
+	if c.Partition.Interval > 0 {
+		if c.Partition.Sample < 1 {
+			return fmt.Errorf("partition probe sample must be at least 1, got %d", c.Partition.Sample)
+		}
+		if c.Partition.Threshold <= 0 || c.Partition.Threshold > 1 {
+			return fmt.Errorf("partition threshold must be in (0, 1], got %v", c.Partition.Threshold)
+		}
+	}
+	if c.Address.Interval > 0 {
+		if c.Address.Sample < 1 {
+			return fmt.Errorf("address check sample must be at least 1, got %d", c.Address.Sample)
+		}
+		if c.Address.Quorum < 1 || c.Address.Quorum > c.Address.Sample {
+			return fmt.Errorf("address quorum must be between 1 and the sample of %d, got %d", c.Address.Sample, c.Address.Quorum)
+		}
+	}
+	if c.Scheduler.Jitter < 0 || c.Scheduler.Jitter > 1 {
+		return fmt.Errorf("scheduler jitter must be between 0 and 1, got %v", c.Scheduler.Jitter)
+	}
+	if c.Scheduler.MaxConcurrent < 0 {
+		return fmt.Errorf("scheduler max concurrent jobs must not be negative, got %d", c.Scheduler.MaxConcurrent)
+	}
+	if c.Advertise != "" && net.ParseIP(c.Advertise) == nil {
+		return fmt.Errorf("advertised IP %q is not an IP address", c.Advertise)
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", c.Port)
+	}
+	if c.PunchPort < 0 || c.PunchPort > 65535 {
+		return fmt.Errorf("hole punching port %d is out of range", c.PunchPort)
+	}
+	if c.Mainline.Port < 0 || c.Mainline.Port > 65535 {
+		return fmt.Errorf("Mainline port %d is out of range", c.Mainline.Port)
+	}
+	return nil
+}
+
+// parseListeners parses a comma-separated list of addr=set[+set...]
+func parseListeners(v string) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+	for _, entry := range strings.Split(v, ",") {
+		addr, sets, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || addr == "" || sets == "" {
+			return nil, fmt.Errorf("expected addr=set[+set...], got %q", entry)
+		}
+		listener := ListenerConfig{Addr: addr, Handlers: splitHandlers(sets, "+")}
+		if err := validateHandlers(listener.Handlers); err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+func splitHandlers(v, sep string) []string {
+	var sets []string
+	for _, set := range strings.Split(v, sep) {
+		if set = strings.TrimSpace(set); set != "" {
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
+
+func validateHandlers(sets []string) error {
+	for _, set := range sets {
+		if !contains(HandlerSets, set) {
+			return fmt.Errorf("unknown handler set %q, expected one of %v", set, HandlerSets)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNamespaces parses a comma-separated list of name:quota[:token]
+func parseNamespaces(v string) (map[string]models.NamespacePolicy, error) {
+	namespaces := make(map[string]models.NamespacePolicy)
+	for _, entry := range strings.Split(v, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("expected name:quota[:token], got %q", entry)
+		}
+		if err := models.ValidateNamespace(parts[0]); err != nil || parts[0] == "" {
+			return nil, fmt.Errorf("invalid namespace name %q", parts[0])
+		}
+		quota, err := strconv.Atoi(parts[1])
+		if err != nil || quota < 0 {
+			return nil, fmt.Errorf("invalid quota for namespace %q", parts[0])
+		}
+		policy := models.NamespacePolicy{Quota: quota}
+		if len(parts) == 3 {
+			policy.Token = parts[2]
+		}
+		namespaces[parts[0]] = policy
+	}
+	return namespaces, nil
+}
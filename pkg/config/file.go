@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadEnvFile sets the KADEMLIA_* variables listed in the file at path,
+// one KEY=value per line, so FromEnv reads them. Blank lines and lines
+// starting with # are skipped, an "export " prefix is allowed and values
+// may be quoted. Variables already set in the environment win over the
+// file.
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !strings.HasPrefix(key, "KADEMLIA_") {
+			return fmt.Errorf("%s:%d: expected KADEMLIA_<NAME>=<value>, got %q", path, line, text)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	return nil
+}
@@ -1,25 +1,80 @@
 package constants
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 var (
 	// Default values for Kademlia
-	kValue = 1 // Bucket size, can be updated dynamically
+	kValue = 20 // Default bucket size for routing tables created without an explicit k
+
+	alphaValue = Alpha // Default lookup parallelism
+
+	rpcTimeout = 30 * time.Second // Deadline applied to each outbound RPC
 
 	// Mutex for thread-safe access
 	mu sync.RWMutex
 )
 
-// GetK returns the current value of k
+// GetK returns the default value of k
 func GetK() int {
 	mu.RLock()
 	defer mu.RUnlock()
 	return kValue
 }
 
-// SetK allows updating the value of k dynamically
+// SetK updates the default k used by routing tables created afterwards
+// without an explicit k. Tables created with NewRoutingTableWithK are not
+// affected.
 func SetK(value int) {
 	mu.Lock()
 	defer mu.Unlock()
 	kValue = value
 }
+
+// GetAlpha returns the default number of contacts queried in parallel per
+// lookup round
+func GetAlpha() int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return alphaValue
+}
+
+// SetAlpha updates the default lookup parallelism
+func SetAlpha(value int) {
+	mu.Lock()
+	defer mu.Unlock()
+	alphaValue = value
+}
+
+// GetRPCTimeout returns the deadline applied to each outbound RPC
+func GetRPCTimeout() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return rpcTimeout
+}
+
+// SetRPCTimeout allows updating the per-RPC deadline dynamically
+func SetRPCTimeout(value time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	rpcTimeout = value
+}
+
+const (
+	// DefaultProviderTTL is how long a provider record stays valid unless re-announced
+	DefaultProviderTTL = 24 * time.Hour
+
+	// DefaultMaxProvidersPerKey caps the number of provider records kept for one content key
+	DefaultMaxProvidersPerKey = 20
+
+	// DefaultMaxProviderRecords caps the number of provider records kept across all content keys
+	DefaultMaxProviderRecords = 100000
+
+	// ProviderSweepInterval is the time between sweeps of expired provider records
+	ProviderSweepInterval = 10 * time.Minute
+
+	// Alpha is the initial number of peers queried in parallel per lookup round
+	Alpha = 3
+)
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// AllowlistKeyPrefix marks an allowlist entry naming the public key of a
+// peer's record rather than its node ID
+const AllowlistKeyPrefix = "key:"
+
+// Allowlist holds the peers admitted by a node of a private network, by
+// node ID or by the hex ed25519 public key of the signed record they
+// present. A nil Allowlist admits every peer.
+type Allowlist struct {
+	ids  map[string]bool
+	keys map[string]bool
+}
+
+// NewAllowlist parses entries, each a hex node ID or "key:" followed by a
+// hex ed25519 public key
+func NewAllowlist(entries []string) (*Allowlist, error) {
+	a := &Allowlist{ids: make(map[string]bool), keys: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if key, ok := strings.CutPrefix(entry, AllowlistKeyPrefix); ok {
+			if decoded, err := hex.DecodeString(key); err != nil || len(decoded) != 32 {
+				return nil, fmt.Errorf("invalid allowlist key %q: expected 64 hex digits", key)
+			}
+			a.keys[key] = true
+			continue
+		}
+		if _, err := hex.DecodeString(entry); err != nil || entry == "" {
+			return nil, fmt.Errorf("invalid allowlist node ID %q", entry)
+		}
+		a.ids[entry] = true
+	}
+	return a, nil
+}
+
+// Allows reports whether n is admitted: its ID is listed, or it carries a
+// record signed by a listed key. The record must have been verified
+// against n's ID.
+func (a *Allowlist) Allows(n *Node) bool {
+	if a == nil {
+		return true
+	}
+	if a.ids[strings.ToLower(n.ID)] {
+		return true
+	}
+	return n.Record != nil && a.keys[strings.ToLower(n.Record.PublicKey)]
+}
+
+// Len returns the number of entries
+func (a *Allowlist) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.ids) + len(a.keys)
+}
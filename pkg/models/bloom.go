@@ -0,0 +1,162 @@
+package models
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// Key filter tuning
+const (
+	// BloomFalsePositiveRate is the share of absent keys a node's key
+	// filter is sized to report as present
+	BloomFalsePositiveRate = 0.01
+
+	// MaxBloomFilterBytes bounds a key filter, so nodes storing many keys
+	// advertise a filter with more false positives rather than a larger one
+	MaxBloomFilterBytes = 16 << 10
+)
+
+// BloomFilter is a compact, lossy set of keys: MayContain is never false
+// for an added key, and true for absent keys at about the rate it was
+// sized for
+type BloomFilter struct {
+	Bits   []byte `json:"bits"`   // Base64 on the wire
+	Hashes int    `json:"hashes"` // Bits set per key
+}
+
+// NewBloomFilter returns an empty filter sized for n keys at the false
+// positive rate p, capped at MaxBloomFilterBytes
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	bits := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	size := int(math.Ceil(bits / 8))
+	size = max(8, min(size, MaxBloomFilterBytes))
+	hashes := int(math.Round(float64(size*8) / float64(n) * math.Ln2))
+	hashes = max(1, min(hashes, 16))
+	return &BloomFilter{Bits: make([]byte, size), Hashes: hashes}
+}
+
+// Add inserts key
+func (f *BloomFilter) Add(key string) {
+	m := uint64(len(f.Bits)) * 8
+	h1, h2 := bloomHashes(key)
+	for i := 0; i < f.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		f.Bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// MayContain reports whether key may have been added; false means it
+// certainly was not. A nil or empty filter may contain anything.
+func (f *BloomFilter) MayContain(key string) bool {
+	if f == nil || len(f.Bits) == 0 || f.Hashes <= 0 {
+		return true
+	}
+	m := uint64(len(f.Bits)) * 8
+	h1, h2 := bloomHashes(key)
+	for i := 0; i < f.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if f.Bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone returns a copy of the filter
+func (f *BloomFilter) Clone() *BloomFilter {
+	return &BloomFilter{Bits: append([]byte(nil), f.Bits...), Hashes: f.Hashes}
+}
+
+// bloomHashes derives the two hashes combined into a key's bit positions
+// from its FNV-1a hash
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+// KeyFilters caches the key filters peers advertise, so lookups can ask
+// the peers that may hold a key first. Filters older than MaxAge are
+// ignored. A nil *KeyFilters knows no filters.
+type KeyFilters struct {
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	filters map[string]keyFilter
+}
+
+type keyFilter struct {
+	filter  *BloomFilter
+	fetched time.Time
+}
+
+// NewKeyFilters creates an empty cache of filters trusted for maxAge
+func NewKeyFilters(maxAge time.Duration) *KeyFilters {
+	return &KeyFilters{MaxAge: maxAge, filters: make(map[string]keyFilter)}
+}
+
+// Set records the filter the peer id advertised just now
+func (kf *KeyFilters) Set(id string, filter *BloomFilter) {
+	if kf == nil || filter == nil {
+		return
+	}
+	kf.mu.Lock()
+	defer kf.mu.Unlock()
+	if _, ok := kf.filters[id]; !ok && len(kf.filters) >= MaxReputationPeers {
+		kf.purge(clock.Now())
+	}
+	kf.filters[id] = keyFilter{filter: filter, fetched: clock.Now()}
+}
+
+// Forget drops the filter of the peer id
+func (kf *KeyFilters) Forget(id string) {
+	if kf == nil {
+		return
+	}
+	kf.mu.Lock()
+	defer kf.mu.Unlock()
+	delete(kf.filters, id)
+}
+
+// Lacks reports whether the peer id's current filter rules key out. It is
+// false without a filter, or with one older than MaxAge.
+func (kf *KeyFilters) Lacks(id, key string) bool {
+	if kf == nil {
+		return false
+	}
+	kf.mu.Lock()
+	defer kf.mu.Unlock()
+	entry, ok := kf.filters[id]
+	if !ok || clock.Now().Sub(entry.fetched) > kf.MaxAge {
+		return false
+	}
+	return !entry.filter.MayContain(key)
+}
+
+// Len returns the number of peers with a current filter
+func (kf *KeyFilters) Len() int {
+	if kf == nil {
+		return 0
+	}
+	kf.mu.Lock()
+	defer kf.mu.Unlock()
+	kf.purge(clock.Now())
+	return len(kf.filters)
+}
+
+// purge drops the filters older than MaxAge; kf.mu must be held
+func (kf *KeyFilters) purge(now time.Time) {
+	for id, entry := range kf.filters {
+		if now.Sub(entry.fetched) > kf.MaxAge {
+			delete(kf.filters, id)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// ChurnHalfLife is how long it takes a peer's drop to count half as much
+// against its trust, and the window drop rates are averaged over
+const ChurnHalfLife = time.Hour
+
+// Churn is the peer's drop count decayed by ChurnHalfLife as of now: 0 for
+// a peer that never dropped, about 1 for one that dropped just now
+func (s PeerScore) Churn(now time.Time) float64 {
+	if s.churn == 0 {
+		return 0
+	}
+	return s.churn * math.Exp2(-float64(now.Sub(s.droppedAt))/float64(ChurnHalfLife))
+}
+
+// Trust is the peer's success rate discounted by its churn, so a peer that
+// answers reliably but keeps dropping out ranks below one that stays
+func (s PeerScore) Trust(now time.Time) float64 {
+	return s.SuccessRate() / (1 + s.Churn(now))
+}
+
+// join starts a session at now unless one is running
+func (s *PeerScore) join(now time.Time) {
+	if s.Online {
+		return
+	}
+	if s.Sessions > 0 {
+		s.Rejoins++
+	}
+	s.Online, s.JoinedAt = true, now
+	s.Sessions++
+}
+
+// leave ends the running session at now, if any, counting a drop if the
+// peer failed
+func (s *PeerScore) leave(now time.Time, dropped bool) {
+	if !s.Online {
+		return
+	}
+	s.Online, s.LeftAt = false, now
+	s.Uptime += now.Sub(s.JoinedAt)
+	if dropped {
+		s.Drops++
+		s.churn = s.Churn(now) + 1
+		s.droppedAt = now
+	}
+}
+
+// Joined notes that the peer id joined the routing table
+func (r *PeerReputation) Joined(id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := clock.Now()
+	score := r.scoreOf(id)
+	score.join(now)
+	score.Updated = now
+}
+
+// Left notes that the peer id left the routing table, ending its session
+// without counting a drop
+func (r *PeerReputation) Left(id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if score, ok := r.peers[id]; ok {
+		score.leave(clock.Now(), false)
+	}
+}
+
+// Trust returns Score(id).Trust at the current time
+func (r *PeerReputation) Trust(id string) float64 {
+	return r.Score(id).Trust(clock.Now())
+}
+
+// ChurnStats summarises the session history of the tracked peers
+type ChurnStats struct {
+	Peers        int           `json:"peers"`
+	Online       int           `json:"online"`
+	Sessions     int           `json:"sessions"`
+	Rejoins      int           `json:"rejoins"`
+	Drops        int           `json:"drops"`
+	DropsPerHour float64       `json:"drops_per_hour"` // Averaged over about ChurnHalfLife
+	MeanSession  time.Duration `json:"mean_session"`   // Of the ended sessions
+}
+
+// Churn summarises the session history of every tracked peer
+func (r *PeerReputation) Churn() ChurnStats {
+	var stats ChurnStats
+	if r == nil {
+		return stats
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := clock.Now()
+	var recent float64
+	var uptime time.Duration
+	for _, score := range r.peers {
+		stats.Peers++
+		stats.Sessions += score.Sessions
+		stats.Rejoins += score.Rejoins
+		stats.Drops += score.Drops
+		if score.Online {
+			stats.Online++
+		}
+		recent += score.Churn(now)
+		uptime += score.Uptime
+	}
+	// Decayed drop counts sum to the rate times the mean decay time
+	stats.DropsPerHour = recent * math.Ln2 / ChurnHalfLife.Hours()
+	if ended := stats.Sessions - stats.Online; ended > 0 {
+		stats.MeanSession = uptime / time.Duration(ended)
+	}
+	return stats
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RecordType selects how a STORE combines with the value a holder already
+// has for the key
+type RecordType string
+
+const (
+	RecordValue    RecordType = ""         // Replaces the value, last write wins (default)
+	RecordGCounter RecordType = "gcounter" // Grow-only counter, merged by taking each actor's highest count
+	RecordORSet    RecordType = "orset"    // Observed-remove set, merged by uniting its adds and removes
+)
+
+// ErrRecordType is returned when a STORE of a typed record meets a value
+// that is not a record of the same type
+var ErrRecordType = errors.New("value is not a record of the type stored")
+
+// ValidRecordType reports whether t is a known record type
+func ValidRecordType(t RecordType) bool {
+	switch t {
+	case RecordValue, RecordGCounter, RecordORSet:
+		return true
+	}
+	return false
+}
+
+// CRDTRecord is the value of a key stored with a conflict-free record
+// type. Holders merge every STORE into the record they have, so replicas
+// that received the same STOREs in any order, or exchanged their records,
+// hold the same value.
+//
+// A G-counter keeps a count per actor, its value being their sum; each
+// writer increments only its own actor's count. An OR-set keeps a unique
+// tag per addition of an element and the tags of the additions removed
+// since; an element is in the set while one of its additions is not
+// removed, so an addition concurrent with a removal wins.
+type CRDTRecord struct {
+	Type    RecordType          `json:"type"`
+	Counts  map[string]uint64   `json:"counts,omitempty"`  // G-counter: actor -> count
+	Adds    map[string][]string `json:"adds,omitempty"`    // OR-set: element -> tags of its additions
+	Removes map[string][]string `json:"removes,omitempty"` // OR-set: element -> tags of its removed additions
+}
+
+// NewCRDTRecord returns an empty record of type t
+func NewCRDTRecord(t RecordType) CRDTRecord {
+	return CRDTRecord{Type: t}
+}
+
+// ParseCRDTRecord parses a value holding a record of type t
+func ParseCRDTRecord(value string, t RecordType) (CRDTRecord, error) {
+	var rec CRDTRecord
+	if err := json.Unmarshal([]byte(value), &rec); err != nil {
+		return CRDTRecord{}, fmt.Errorf("invalid %s record: %v", t, err)
+	}
+	if rec.Type != t {
+		return CRDTRecord{}, fmt.Errorf("%w: %q is not %q", ErrRecordType, rec.Type, t)
+	}
+	switch t {
+	case RecordGCounter:
+		if len(rec.Adds) > 0 || len(rec.Removes) > 0 {
+			return CRDTRecord{}, fmt.Errorf("invalid %s record: set fields in a counter", t)
+		}
+	case RecordORSet:
+		if len(rec.Counts) > 0 {
+			return CRDTRecord{}, fmt.Errorf("invalid %s record: counts in a set", t)
+		}
+	default:
+		return CRDTRecord{}, fmt.Errorf("unknown record type %q", t)
+	}
+	return rec, nil
+}
+
+// MergeCRDTRecord returns the value that results from storing rec over
+// current, the value a holder has, exists false if it has none. It fails
+// with ErrRecordType if current is not a record of rec's type.
+func MergeCRDTRecord(current string, exists bool, rec CRDTRecord) (string, error) {
+	if exists {
+		have, err := ParseCRDTRecord(current, rec.Type)
+		if err != nil {
+			return "", ErrRecordType
+		}
+		rec = have.Merge(rec)
+	}
+	return rec.Encode(), nil
+}
+
+// Merge returns the union of r and other, which must be of the same type
+func (r CRDTRecord) Merge(other CRDTRecord) CRDTRecord {
+	merged := CRDTRecord{Type: r.Type}
+	for _, rec := range []CRDTRecord{r, other} {
+		for actor, n := range rec.Counts {
+			if merged.Counts == nil {
+				merged.Counts = make(map[string]uint64)
+			}
+			merged.Counts[actor] = max(merged.Counts[actor], n)
+		}
+		merged.Adds = unionTags(merged.Adds, rec.Adds)
+		merged.Removes = unionTags(merged.Removes, rec.Removes)
+	}
+	return merged
+}
+
+// Encode returns the record as a value. Equal records encode identically.
+func (r CRDTRecord) Encode() string {
+	// encoding/json sorts map keys, and unionTags keeps tags sorted
+	data, _ := json.Marshal(r.Merge(CRDTRecord{}))
+	return string(data)
+}
+
+// Count returns the value of a G-counter
+func (r CRDTRecord) Count() uint64 {
+	var total uint64
+	for _, n := range r.Counts {
+		total += n
+	}
+	return total
+}
+
+// Increment adds delta to actor's count in a G-counter
+func (r *CRDTRecord) Increment(actor string, delta uint64) {
+	if r.Counts == nil {
+		r.Counts = make(map[string]uint64)
+	}
+	r.Counts[actor] += delta
+}
+
+// Elements returns the elements of an OR-set, sorted
+func (r CRDTRecord) Elements() []string {
+	var elements []string
+	for element := range r.Adds {
+		if r.Contains(element) {
+			elements = append(elements, element)
+		}
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// Contains reports whether element is in an OR-set
+func (r CRDTRecord) Contains(element string) bool {
+	removed := make(map[string]bool)
+	for _, tag := range r.Removes[element] {
+		removed[tag] = true
+	}
+	for _, tag := range r.Adds[element] {
+		if !removed[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds element to an OR-set as the addition tag, which must be unique
+// to this addition
+func (r *CRDTRecord) Add(element, tag string) {
+	r.Adds = unionTags(r.Adds, map[string][]string{element: {tag}})
+}
+
+// Remove removes element from an OR-set, removing the additions of it the
+// record holds. Additions it has not seen are kept.
+func (r *CRDTRecord) Remove(element string) {
+	r.Removes = unionTags(r.Removes, map[string][]string{element: r.Adds[element]})
+}
+
+// unionTags returns a with the tags of b added to their elements, keeping
+// each element's tags sorted and unique
+func unionTags(a, b map[string][]string) map[string][]string {
+	for element, tags := range b {
+		if len(tags) == 0 {
+			continue
+		}
+		if a == nil {
+			a = make(map[string][]string)
+		}
+		seen := make(map[string]bool)
+		var union []string
+		for _, tag := range append(append([]string(nil), a[element]...), tags...) {
+			if !seen[tag] {
+				seen[tag] = true
+				union = append(union, tag)
+			}
+		}
+		sort.Strings(union)
+		a[element] = union
+	}
+	return a
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies something that happened inside a node
+type EventType string
+
+const (
+	PeerAdded       EventType = "PEER_ADDED"       // A contact entered the routing table
+	PeerEvicted     EventType = "PEER_EVICTED"     // A contact was dropped from a full bucket
+	ValueStored     EventType = "VALUE_STORED"     // A key-value pair was written
+	ValueExpired    EventType = "VALUE_EXPIRED"    // A key-value pair outlived its TTL
+	ValueEvicted    EventType = "VALUE_EVICTED"    // A key-value pair was dropped under storage pressure
+	ValueDeleted    EventType = "VALUE_DELETED"    // A key-value pair was replaced by a tombstone
+	LookupCompleted EventType = "LOOKUP_COMPLETED" // A FIND_NODE fan-out finished
+
+	PartitionDetected EventType = "PARTITION_DETECTED" // Most probed contacts stopped answering
+	PartitionHealed   EventType = "PARTITION_HEALED"   // The node rejoined the network through a seed
+	AddressChanged    EventType = "ADDRESS_CHANGED"    // The node's advertised IP changed and was announced to its contacts
+)
+
+// Event describes a single occurrence; only the fields relevant to its Type are set
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Peer  *Node   // PeerAdded, PeerEvicted, AddressChanged (the node itself)
+	Key   string  // ValueStored, ValueExpired, ValueEvicted, ValueDeleted, LookupCompleted (target)
+	Value string  // ValueStored, ValueExpired, ValueEvicted, ValueDeleted, AddressChanged (previous IP)
+	Nodes []*Node // LookupCompleted, PartitionDetected (unreachable contacts)
+}
+
+// EventBus fans events out to channel and callback subscribers. A nil
+// *EventBus is valid and discards every event.
+type EventBus struct {
+	mu          sync.RWMutex
+	next        int
+	subscribers map[int]*eventSubscriber
+	dropped     atomic.Int64
+}
+
+type eventSubscriber struct {
+	types    map[EventType]bool // empty means every type
+	ch       chan Event
+	callback func(Event)
+}
+
+// NewEventBus creates an EventBus with no subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[int]*eventSubscriber)}
+}
+
+// Subscribe returns a channel receiving events of the given types (all
+// types if none are given) and a function that cancels the subscription.
+// Events are dropped rather than blocking the node when the channel's
+// buffer is full.
+func (b *EventBus) Subscribe(buffer int, types ...EventType) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	cancel := b.add(&eventSubscriber{types: typeSet(types), ch: ch})
+	return ch, cancel
+}
+
+// On registers fn to be called synchronously for events of the given types
+// (all types if none are given) and returns a function that removes it.
+// Callbacks run on the goroutine that emitted the event and must not block.
+func (b *EventBus) On(fn func(Event), types ...EventType) func() {
+	return b.add(&eventSubscriber{types: typeSet(types), callback: fn})
+}
+
+// Emit delivers e to every interested subscriber
+func (b *EventBus) Emit(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subscribers {
+		if len(sub.types) > 0 && !sub.types[e.Type] {
+			continue
+		}
+		if sub.callback != nil {
+			sub.callback(e)
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of events discarded because a channel was full
+func (b *EventBus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+func (b *EventBus) add(sub *eventSubscriber) func() {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, id)
+			b.mu.Unlock()
+			if sub.ch != nil {
+				close(sub.ch)
+			}
+		})
+	}
+}
+
+func typeSet(types []EventType) map[EventType]bool {
+	set := make(map[EventType]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// RecordIntervals bounds the expire and republish intervals a STORE may
+// ask for its record, so applications can keep short-lived presence
+// records next to long-lived content without either escaping the node's
+// policy. A zero bound leaves that side unbounded.
+type RecordIntervals struct {
+	MinTTL       time.Duration // Shortest expire interval a record may ask for
+	MaxTTL       time.Duration // Longest expire interval a record may ask for
+	MinRepublish time.Duration // Shortest republish interval a record may ask for
+	MaxRepublish time.Duration // Longest republish interval a record may ask for
+}
+
+// Clamp returns ttl and republish moved within the bounds. 0, asking for
+// the node's default, is kept.
+func (b RecordIntervals) Clamp(ttl, republish time.Duration) (time.Duration, time.Duration) {
+	return clampInterval(ttl, b.MinTTL, b.MaxTTL), clampInterval(republish, b.MinRepublish, b.MaxRepublish)
+}
+
+func clampInterval(d, lo, hi time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	if lo > 0 && d < lo {
+		d = lo
+	}
+	if hi > 0 && d > hi {
+		d = hi
+	}
+	return d
+}
+
+// Seconds converts an interval given in whole seconds, as STOREs carry
+// them, to a duration
+func Seconds(s int) time.Duration {
+	return time.Duration(s) * time.Second
+}
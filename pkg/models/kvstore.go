@@ -1,43 +1,831 @@
 package models
 
-import "sync"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
-// KeyValueStore represents a thread-safe key-value store
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// OverwritePolicy decides what happens when a STORE targets an existing key
+type OverwritePolicy string
+
+const (
+	OverwriteAlways        OverwritePolicy = "overwrite"       // Replace the existing value (default)
+	OverwriteRejectExists  OverwritePolicy = "reject_existing" // Refuse to touch an existing key
+	OverwriteSamePublisher OverwritePolicy = "same_publisher"  // Only the original publisher may replace the value
+)
+
+// IdempotencyWindow is how long an idempotency key is remembered
+const IdempotencyWindow = 24 * time.Hour
+
+// MaxIdempotencyKeys bounds the idempotency keys a store remembers; beyond
+// it the oldest are forgotten before their window ends
+const MaxIdempotencyKeys = 100000
+
+var (
+	// ErrKeyExists is returned when OverwriteRejectExists meets an existing key
+	ErrKeyExists = errors.New("key already exists")
+	// ErrPublisherMismatch is returned when OverwriteSamePublisher meets a key stored by someone else
+	ErrPublisherMismatch = errors.New("key was stored by a different publisher")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is replayed with a different write
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different write")
+	// ErrDeleted is returned when a replica copy or republish of a value meets a newer tombstone
+	ErrDeleted = errors.New("key was deleted")
+)
+
+// KeyValueStore represents a thread-safe key-value store. Values live in
+// a Storage backend; the bookkeeping of policies, quotas and garbage
+// collection is kept in memory, and the publisher, age and intervals of
+// each value and the tombstones are saved beside the values in a
+// persistent backend.
 type KeyValueStore struct {
-	mu    sync.RWMutex
-	Store map[string]string
+	mu          sync.RWMutex
+	backend     Storage
+	persistent  bool                       // backend outlives the process, so bookkeeping is saved in it
+	publishers  map[string]string          // key -> publisher ID of the current value
+	idempotency map[string]idempotentWrite // idempotency key -> write it identified
+	idemOrder   []string                   // Idempotency keys, oldest first and so in order of expiry
+	namespaces  map[string]NamespacePolicy // namespace -> quota and token
+	usage       map[string]int             // namespace -> number of keys stored
+	entries     map[string]*entryMeta      // key -> bookkeeping used by garbage collection
+	tombstones  map[string]time.Time       // key -> when it was deleted, until garbage collected
+	proofs      map[string]DeleteProof     // key -> publisher's signature of its tombstone, if it had one
+	bytes       int64                      // Total size of stored keys and values
+	filter      *BloomFilter               // Stored keys, built by KeyFilter and nil until then
+	filterCap   int                        // Keys filter was sized for
+
+	Events *EventBus // Receives ValueStored events, may be nil
+
+	// RejectDistant makes the node refuse STOREs of keys farther from it
+	// than its k-th closest contact, which it is not responsible for
+	RejectDistant bool
+	// RequireWriteToken makes the node refuse STOREs that do not present a
+	// write token it recently issued to the sender's address
+	RequireWriteToken bool
+	// Placement counts how STOREs were answered, and LogPlacement logs
+	// the distance gap of every STORE not accepted for being too far
+	Placement    PlacementStats
+	LogPlacement bool
+	// Intervals bounds the expire and republish intervals STOREs set for
+	// their record with SetIntervals
+	Intervals RecordIntervals
+}
+
+type entryMeta struct {
+	size        int           // Bytes of key plus value
+	storedAt    time.Time     // When the value was last written
+	firstStored time.Time     // When the value was first written, kept while it is rewritten unchanged
+	digest      [32]byte      // SHA-256 of the value, telling republishes from new values and tagging it for conditional reads
+	hops        int           // STORE RPCs the value travelled from its publisher
+	ttl         time.Duration // Expire interval overriding the node's, 0 if none
+	republish   time.Duration // Republish interval overriding the node's, 0 if none
+	lastAccess  atomic.Int64  // Unix nanoseconds, updated under the read lock
+}
+
+// RecordMeta is the provenance of a stored value, returned by FIND_VALUE
+// with meta=true
+type RecordMeta struct {
+	Publisher     string    `json:"publisher,omitempty"` // Empty for values stored without a publisher
+	StoredAt      time.Time `json:"stored_at"`           // When this node first stored the value
+	RepublishedAt time.Time `json:"republished_at"`      // When the value was last stored again unchanged, StoredAt if never
+	Hops          int       `json:"hops"`                // STORE RPCs the value travelled from its publisher, 0 if stored locally
+	TTL           int       `json:"ttl,omitempty"`       // Expire interval in seconds set by the STORE, 0 for the node's default
+	Republish     int       `json:"republish,omitempty"` // Republish interval in seconds set by the STORE, 0 for the node's default
+	Hash          string    `json:"hash"`                // Hex SHA-256 of the value
+	Size          int       `json:"size"`                // Bytes of the value
+}
+
+// EntryInfo describes a stored entry for eviction decisions
+type EntryInfo struct {
+	Key        string
+	Size       int // Bytes of key plus value
+	StoredAt   time.Time
+	LastAccess time.Time
+	TTL        time.Duration // Expire interval set for the entry, 0 for the collector's
+}
+
+// StoredRecord is a stored value with the metadata needed to move it to
+// another node
+type StoredRecord struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	Publisher string     `json:"publisher,omitempty"` // Empty for values stored without a publisher
+	StoredAt  time.Time  `json:"stored_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // When garbage collection drops the value, if it expires
+
+	// FirstStoredAt is when the value was first stored, before StoredAt if
+	// it was republished since; zero in exports that predate it
+	FirstStoredAt time.Time `json:"first_stored_at"`
+	Hops          int       `json:"hops,omitempty"`
+
+	// TTL and Republish are the expire and republish intervals in seconds
+	// the record's STORE set, 0 for the node's defaults
+	TTL       int `json:"ttl,omitempty"`
+	Republish int `json:"republish,omitempty"`
+}
+
+// DeleteProof is the signature with which a value's publisher deleted it,
+// kept with the tombstone so the replicas it is copied to can check it
+type DeleteProof struct {
+	Publisher string    `json:"publisher"`
+	PublicKey string    `json:"public_key"` // Hex ed25519 key of Publisher
+	Signature string    `json:"signature"`
+	DeletedAt time.Time `json:"deleted_at"` // The date signed, which the tombstone's may have been clamped from
 }
 
-// NewKeyValueStore initializes a new KeyValueStore
+type idempotentWrite struct {
+	key, value, publisher string
+	expires               time.Time
+}
+
+// NewKeyValueStore initializes a new KeyValueStore kept in memory
 func NewKeyValueStore() *KeyValueStore {
-	return &KeyValueStore{
-		Store: make(map[string]string),
+	kv, _ := NewKeyValueStoreOn(NewMemoryStorage())
+	return kv
+}
+
+// NewKeyValueStoreOn initializes a KeyValueStore on backend, taking over
+// the values it already holds with the publisher, age and intervals and
+// the tombstones saved beside them
+func NewKeyValueStoreOn(backend Storage) (*KeyValueStore, error) {
+	_, memory := backend.(*MemoryStorage)
+	kv := &KeyValueStore{
+		backend:     backend,
+		persistent:  !memory,
+		publishers:  make(map[string]string),
+		idempotency: make(map[string]idempotentWrite),
+		namespaces:  make(map[string]NamespacePolicy),
+		usage:       make(map[string]int),
+		entries:     make(map[string]*entryMeta),
+		tombstones:  make(map[string]time.Time),
+		proofs:      make(map[string]DeleteProof),
+	}
+	if err := kv.load(); err != nil {
+		return nil, err
 	}
+	return kv, nil
 }
 
-// Set stores a key-value pair
-func (kv *KeyValueStore) Set(key, value string) {
+// ValidOverwritePolicy reports whether p is a known policy
+func ValidOverwritePolicy(p OverwritePolicy) bool {
+	switch p {
+	case OverwriteAlways, OverwriteRejectExists, OverwriteSamePublisher:
+		return true
+	}
+	return false
+}
+
+// Put stores a key-value pair on behalf of publisher, subject to policy.
+// A non-empty idempotencyKey makes retries safe: repeating the same write
+// with the same key is a no-op reported as replayed, even if the policy
+// would now reject it.
+func (kv *KeyValueStore) Put(key, value, publisher string, policy OverwritePolicy, idempotencyKey string) (replayed bool, err error) {
+	return kv.PutWithHops(key, value, publisher, 0, policy, idempotencyKey)
+}
+
+// PutWithHops is Put for a value that travelled hops STORE RPCs from its
+// publisher, which is recorded in its provenance
+func (kv *KeyValueStore) PutWithHops(key, value, publisher string, hops int, policy OverwritePolicy, idempotencyKey string) (replayed bool, err error) {
+	kv.mu.Lock()
+	replayed, err = kv.put(key, value, publisher, hops, policy, idempotencyKey)
+	kv.mu.Unlock()
+
+	// Emit after releasing the lock so subscribers may read the store
+	if err == nil && !replayed {
+		kv.Events.Emit(Event{Type: ValueStored, Key: key, Value: value})
+	}
+	return replayed, err
+}
+
+// put implements Put; callers must hold kv.mu
+func (kv *KeyValueStore) put(key, value, publisher string, hops int, policy OverwritePolicy, idempotencyKey string) (bool, error) {
+	now := clock.Now()
+	if idempotencyKey != "" {
+		kv.pruneIdempotencyKeys(now)
+		if prev, seen := kv.idempotency[idempotencyKey]; seen && !now.After(prev.expires) {
+			if prev.key != key || prev.value != value || prev.publisher != publisher {
+				return false, ErrIdempotencyKeyReused
+			}
+			return true, nil
+		}
+	}
+
+	_, exists := kv.entries[key]
+	if exists {
+		switch policy {
+		case OverwriteRejectExists:
+			return false, ErrKeyExists
+		case OverwriteSamePublisher:
+			if owner := kv.publishers[key]; owner != "" && owner != publisher {
+				return false, ErrPublisherMismatch
+			}
+		}
+	} else {
+		ns, _ := SplitNamespacedKey(key)
+		if quota := kv.namespaces[ns].Quota; quota > 0 && kv.usage[ns] >= quota {
+			return false, ErrQuotaExceeded
+		}
+	}
+
+	if err := kv.set(key, value); err != nil {
+		return false, err
+	}
+	kv.publishers[key] = publisher
+	kv.entries[key].hops = hops
+	kv.saveMeta(key)
+	kv.dropTombstone(key)
+	if idempotencyKey != "" {
+		kv.rememberIdempotencyKey(idempotencyKey, idempotentWrite{
+			key:       key,
+			value:     value,
+			publisher: publisher,
+			expires:   now.Add(IdempotencyWindow),
+		})
+	}
+	return false, nil
+}
+
+// rememberIdempotencyKey records the write idempotencyKey identified,
+// forgetting the oldest keys beyond MaxIdempotencyKeys; callers must hold
+// kv.mu
+func (kv *KeyValueStore) rememberIdempotencyKey(idempotencyKey string, w idempotentWrite) {
+	if _, seen := kv.idempotency[idempotencyKey]; seen {
+		// Its window ended but the clock went back since older keys were
+		// added, so pruning did not reach it; drop it to add it again last
+		kv.forgetIdempotencyKey(idempotencyKey)
+	}
+	kv.idempotency[idempotencyKey] = w
+	kv.idemOrder = append(kv.idemOrder, idempotencyKey)
+	for len(kv.idemOrder) > MaxIdempotencyKeys {
+		delete(kv.idempotency, kv.idemOrder[0])
+		kv.idemOrder = kv.idemOrder[1:]
+	}
+}
+
+// PruneIdempotencyKeys forgets the idempotency keys whose window ended
+// before now and returns how many it forgot. Keys are kept in order of
+// expiry, so only the expired ones are visited.
+func (kv *KeyValueStore) PruneIdempotencyKeys(now time.Time) int {
 	kv.mu.Lock()
 	defer kv.mu.Unlock()
-	kv.Store[key] = value
+	return kv.pruneIdempotencyKeys(now)
+}
+
+// pruneIdempotencyKeys implements PruneIdempotencyKeys; callers must hold
+// kv.mu
+func (kv *KeyValueStore) pruneIdempotencyKeys(now time.Time) int {
+	pruned := 0
+	for len(kv.idemOrder) > 0 && now.After(kv.idempotency[kv.idemOrder[0]].expires) {
+		delete(kv.idempotency, kv.idemOrder[0])
+		kv.idemOrder = kv.idemOrder[1:]
+		pruned++
+	}
+	return pruned
 }
 
-// Get retrieves the value for a given key
+// forgetIdempotencyKey drops idempotencyKey; callers must hold kv.mu
+func (kv *KeyValueStore) forgetIdempotencyKey(idempotencyKey string) {
+	delete(kv.idempotency, idempotencyKey)
+	for i, k := range kv.idemOrder {
+		if k == idempotencyKey {
+			kv.idemOrder = append(kv.idemOrder[:i], kv.idemOrder[i+1:]...)
+			return
+		}
+	}
+}
+
+// IdempotencyKeys returns the number of idempotency keys remembered
+func (kv *KeyValueStore) IdempotencyKeys() int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return len(kv.idempotency)
+}
+
+// Update atomically replaces the value of key, on behalf of publisher, by
+// what fn makes of the current one, exists false if there is none. fn runs
+// under the store's lock and must not use the store; an error from it
+// leaves the key untouched and is returned.
+func (kv *KeyValueStore) Update(key, publisher string, fn func(current string, exists bool) (string, error)) error {
+	kv.mu.Lock()
+	current, exists := "", false
+	var err error
+	if kv.entries[key] != nil {
+		current, exists, err = kv.backend.Get(key)
+	}
+	var value string
+	if err == nil {
+		value, err = fn(current, exists)
+	}
+	if err == nil {
+		_, err = kv.put(key, value, publisher, 0, OverwriteAlways, "")
+	}
+	kv.mu.Unlock()
+
+	// Emit after releasing the lock so subscribers may read the store
+	if err == nil {
+		kv.Events.Emit(Event{Type: ValueStored, Key: key, Value: value})
+	}
+	return err
+}
+
+// Set stores a key-value pair copied from another replica. It fails with
+// ErrDeleted if the key has a tombstone, so stragglers cannot resurrect a
+// deleted value, or if the backend fails.
+func (kv *KeyValueStore) Set(key, value string) error {
+	kv.mu.Lock()
+	err := ErrDeleted
+	if _, deleted := kv.tombstones[key]; !deleted {
+		err = kv.set(key, value)
+	}
+	if err == nil {
+		delete(kv.publishers, key)
+		kv.saveMeta(key)
+	}
+	kv.mu.Unlock()
+
+	if err == nil {
+		kv.Events.Emit(Event{Type: ValueStored, Key: key, Value: value})
+	}
+	return err
+}
+
+// set stores a value and keeps the bookkeeping in sync; callers must hold kv.mu
+func (kv *KeyValueStore) set(key, value string) error {
+	if err := kv.backend.Set(key, value); err != nil {
+		return err
+	}
+	kv.track(key, value)
+	return nil
+}
+
+// track records a stored value in the bookkeeping; callers must hold
+// kv.mu. Rewriting a key with the value it holds is a republish, which
+// keeps the first store time.
+func (kv *KeyValueStore) track(key, value string) {
+	now := clock.Now()
+	size := len(key) + len(value)
+	meta := &entryMeta{size: size, storedAt: now, firstStored: now, digest: sha256.Sum256([]byte(value))}
+
+	if old, exists := kv.entries[key]; exists {
+		kv.bytes -= int64(old.size)
+		if old.digest == meta.digest {
+			meta.firstStored = old.firstStored
+		}
+	} else {
+		ns, _ := SplitNamespacedKey(key)
+		kv.usage[ns]++
+		if kv.filter != nil && len(kv.entries) < kv.filterCap {
+			kv.filter.Add(key)
+		} else {
+			kv.filter = nil
+		}
+	}
+	kv.bytes += int64(size)
+
+	meta.lastAccess.Store(now.UnixNano())
+	kv.entries[key] = meta
+}
+
+// Meta returns the provenance of the value stored under key
+func (kv *KeyValueStore) Meta(key string) (RecordMeta, bool) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	meta := kv.entries[key]
+	if meta == nil {
+		return RecordMeta{}, false
+	}
+	return kv.recordMeta(key, meta), true
+}
+
+// recordMeta describes the value stored under key; callers must hold kv.mu
+func (kv *KeyValueStore) recordMeta(key string, meta *entryMeta) RecordMeta {
+	return RecordMeta{
+		Publisher:     kv.publishers[key],
+		StoredAt:      meta.firstStored,
+		RepublishedAt: meta.storedAt,
+		Hops:          meta.hops,
+		TTL:           int(meta.ttl / time.Second),
+		Republish:     int(meta.republish / time.Second),
+		Hash:          hex.EncodeToString(meta.digest[:]),
+		Size:          meta.size - len(key),
+	}
+}
+
+// SetIntervals sets the expire and republish intervals of the value stored
+// under key, clamped to kv.Intervals; 0 leaves the node's default. They
+// hold until the value is next written. It reports whether key is stored.
+func (kv *KeyValueStore) SetIntervals(key string, ttl, republish time.Duration) bool {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	meta := kv.entries[key]
+	if meta == nil {
+		return false
+	}
+	meta.ttl, meta.republish = kv.Intervals.Clamp(ttl, republish)
+	kv.saveMeta(key)
+	return true
+}
+
+// Get retrieves the value for a given key. A value the backend fails to
+// read is reported as missing.
 func (kv *KeyValueStore) Get(key string) (string, bool) {
 	kv.mu.RLock()
 	defer kv.mu.RUnlock()
-	value, exists := kv.Store[key]
-	return value, exists
+	meta := kv.entries[key]
+	if meta == nil {
+		return "", false
+	}
+	value, exists, err := kv.backend.Get(key)
+	if err != nil || !exists {
+		return "", false
+	}
+	meta.lastAccess.Store(clock.Now().UnixNano())
+	return value, true
 }
 
-func (kv *KeyValueStore) GetAll() map[string]string {
+// GetWithMeta is Get also returning the provenance of the value, read
+// together so the metadata, and its hash, describe the value returned
+func (kv *KeyValueStore) GetWithMeta(key string) (string, RecordMeta, bool) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	meta := kv.entries[key]
+	if meta == nil {
+		return "", RecordMeta{}, false
+	}
+	value, exists, err := kv.backend.Get(key)
+	if err != nil || !exists {
+		return "", RecordMeta{}, false
+	}
+	meta.lastAccess.Store(clock.Now().UnixNano())
+	return value, kv.recordMeta(key, meta), true
+}
+
+// Delete removes a key, returning its value if it was present
+func (kv *KeyValueStore) Delete(key string) (string, bool) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.delete(key)
+}
+
+// Evict removes a key only if it has not been rewritten since storedAt,
+// so a garbage collector working from a snapshot never drops fresh data
+func (kv *KeyValueStore) Evict(key string, storedAt time.Time) (string, bool) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if meta := kv.entries[key]; meta == nil || !meta.storedAt.Equal(storedAt) {
+		return "", false
+	}
+	return kv.delete(key)
+}
+
+// delete removes a key and its bookkeeping; callers must hold kv.mu. The
+// key is kept if the backend fails to delete it.
+func (kv *KeyValueStore) delete(key string) (string, bool) {
+	meta := kv.entries[key]
+	if meta == nil {
+		return "", false
+	}
+	value, _, err := kv.backend.Get(key)
+	if err != nil {
+		return "", false
+	}
+	if err := kv.backend.Delete(key); err != nil {
+		return "", false
+	}
+	delete(kv.publishers, key)
+	delete(kv.entries, key)
+	kv.dropMeta(key)
+	kv.bytes -= int64(meta.size)
+
+	ns, _ := SplitNamespacedKey(key)
+	if kv.usage[ns]--; kv.usage[ns] <= 0 {
+		delete(kv.usage, ns)
+	}
+	return value, true
+}
+
+// Tombstone deletes key as of deletedAt, keeping a tombstone that refuses
+// older copies of the value until PurgeTombstones drops it. A value
+// written after deletedAt is kept. It reports whether the tombstone was
+// recorded, false if the key already has one at least as new.
+func (kv *KeyValueStore) Tombstone(key string, deletedAt time.Time) bool {
+	recorded, _ := kv.TombstoneSigned(key, deletedAt, nil, nil)
+	return recorded
+}
+
+// TombstoneSigned is Tombstone for a delete its publisher may have signed.
+// A value stored with a publisher is only deleted if signedBy reports
+// that publisher signed the delete, and ErrPublisherMismatch is returned
+// otherwise; a nil signedBy deletes any value. proof, if not nil, is kept
+// with the tombstone and returned by DeleteProofOf.
+func (kv *KeyValueStore) TombstoneSigned(key string, deletedAt time.Time, proof *DeleteProof, signedBy func(publisher string) bool) (bool, error) {
+	kv.mu.Lock()
+	if prev, exists := kv.tombstones[key]; exists && !prev.Before(deletedAt) {
+		kv.mu.Unlock()
+		return false, nil
+	}
+	value, deleted := "", false
+	if meta := kv.entries[key]; meta != nil {
+		if publisher := kv.publishers[key]; publisher != "" && signedBy != nil && !signedBy(publisher) {
+			kv.mu.Unlock()
+			return false, ErrPublisherMismatch
+		}
+		if meta.storedAt.After(deletedAt) {
+			kv.mu.Unlock()
+			return false, nil
+		}
+		if value, deleted = kv.delete(key); !deleted {
+			kv.mu.Unlock()
+			return false, nil
+		}
+	}
+	kv.setTombstone(key, deletedAt, proof)
+	kv.mu.Unlock()
+
+	// Emit after releasing the lock so subscribers may read the store
+	if deleted {
+		kv.Events.Emit(Event{Type: ValueDeleted, Key: key, Value: value})
+	}
+	return true, nil
+}
+
+// DeleteProofOf returns the publisher's signature of the tombstone of
+// key, if it has a signed one
+func (kv *KeyValueStore) DeleteProofOf(key string) (DeleteProof, bool) {
 	kv.mu.RLock()
 	defer kv.mu.RUnlock()
+	proof, signed := kv.proofs[key]
+	return proof, signed
+}
 
-	// Create a copy to ensure thread safety
-	copy := make(map[string]string, len(kv.Store))
-	for key, value := range kv.Store {
-		copy[key] = value
+// DeletedAt returns when key was deleted, if it has a tombstone
+func (kv *KeyValueStore) DeletedAt(key string) (time.Time, bool) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	at, deleted := kv.tombstones[key]
+	return at, deleted
+}
+
+// Tombstones returns a copy of every tombstone, keyed by storage key
+func (kv *KeyValueStore) Tombstones() map[string]time.Time {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	copy := make(map[string]time.Time, len(kv.tombstones))
+	for key, at := range kv.tombstones {
+		copy[key] = at
 	}
 	return copy
 }
+
+// PurgeTombstones drops the tombstones of keys deleted before cutoff and
+// returns how many it dropped
+func (kv *KeyValueStore) PurgeTombstones(cutoff time.Time) int {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	purged := 0
+	for key, at := range kv.tombstones {
+		if at.Before(cutoff) {
+			kv.dropTombstone(key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// SizeBytes returns the total size of stored keys and values
+func (kv *KeyValueStore) SizeBytes() int64 {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.bytes
+}
+
+// Entries returns a snapshot of the bookkeeping of every stored entry
+func (kv *KeyValueStore) Entries() []EntryInfo {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	infos := make([]EntryInfo, 0, len(kv.entries))
+	for key, meta := range kv.entries {
+		infos = append(infos, EntryInfo{
+			Key:        key,
+			Size:       meta.size,
+			StoredAt:   meta.storedAt,
+			LastAccess: time.Unix(0, meta.lastAccess.Load()),
+			TTL:        meta.ttl,
+		})
+	}
+	return infos
+}
+
+// GetAll returns a copy of every stored pair. Pairs the backend fails to
+// read are left out.
+//
+// Deprecated: GetAll holds the read lock while it copies every value,
+// stalling writers on large stores. Use Scan.
+func (kv *KeyValueStore) GetAll() map[string]string {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	copy := make(map[string]string, len(kv.entries))
+	kv.backend.Iterate(func(key, value string) bool {
+		if !bookkeepingKey(key) {
+			copy[key] = value
+		}
+		return true
+	})
+	return copy
+}
+
+// Records returns a snapshot of every stored value with its publisher and
+// store time, in no particular order. Values the backend fails to read are
+// left out. Like GetAll it holds the read lock throughout; Scan does not.
+func (kv *KeyValueStore) Records() []StoredRecord {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	records := make([]StoredRecord, 0, len(kv.entries))
+	kv.backend.Iterate(func(key, value string) bool {
+		if bookkeepingKey(key) {
+			return true
+		}
+		rec := StoredRecord{Key: key, Value: value, Publisher: kv.publishers[key]}
+		if meta := kv.entries[key]; meta != nil {
+			rec.StoredAt = meta.storedAt
+			rec.FirstStoredAt = meta.firstStored
+			rec.Hops = meta.hops
+			rec.TTL = int(meta.ttl / time.Second)
+			rec.Republish = int(meta.republish / time.Second)
+		}
+		records = append(records, rec)
+		return true
+	})
+	return records
+}
+
+// ScanChunkSize is the number of records Scan reads per hold of the
+// store's lock
+const ScanChunkSize = 256
+
+// Scan calls fn with every stored record, in key order, until fn returns
+// false. The keys visited are those stored when Scan begins; the records
+// are read ScanChunkSize at a time, each chunk under one hold of the read
+// lock and as of that moment, and keys deleted before their chunk is read
+// are skipped. The lock is never held while fn runs, so writers are only
+// stalled for a chunk and fn may write to the store. Values the backend
+// fails to read are left out.
+func (kv *KeyValueStore) Scan(fn func(rec StoredRecord) bool) {
+	kv.mu.RLock()
+	keys := make([]string, 0, len(kv.entries))
+	for key := range kv.entries {
+		keys = append(keys, key)
+	}
+	kv.mu.RUnlock()
+	sort.Strings(keys)
+
+	chunk := make([]StoredRecord, 0, ScanChunkSize)
+	for start := 0; start < len(keys); start += ScanChunkSize {
+		chunk = chunk[:0]
+		kv.mu.RLock()
+		for _, key := range keys[start:min(start+ScanChunkSize, len(keys))] {
+			meta := kv.entries[key]
+			if meta == nil {
+				continue
+			}
+			value, exists, err := kv.backend.Get(key)
+			if err != nil || !exists {
+				continue
+			}
+			chunk = append(chunk, StoredRecord{
+				Key:           key,
+				Value:         value,
+				Publisher:     kv.publishers[key],
+				StoredAt:      meta.storedAt,
+				FirstStoredAt: meta.firstStored,
+				Hops:          meta.hops,
+				TTL:           int(meta.ttl / time.Second),
+				Republish:     int(meta.republish / time.Second),
+			})
+		}
+		kv.mu.RUnlock()
+
+		for _, rec := range chunk {
+			if !fn(rec) {
+				return
+			}
+		}
+	}
+}
+
+// Restore stores rec as if rec.Publisher had stored it at rec.StoredAt, so
+// its age, provenance and intervals carry over, bypassing overwrite
+// policies and namespace quotas. An existing key is only replaced if
+// overwrite is set. It reports whether rec was stored.
+func (kv *KeyValueStore) Restore(rec StoredRecord, overwrite bool) (bool, error) {
+	kv.mu.Lock()
+	if _, exists := kv.entries[rec.Key]; exists && !overwrite {
+		kv.mu.Unlock()
+		return false, nil
+	}
+	if err := kv.restore(rec); err != nil {
+		kv.mu.Unlock()
+		return false, err
+	}
+	kv.mu.Unlock()
+
+	kv.Events.Emit(Event{Type: ValueStored, Key: rec.Key, Value: rec.Value})
+	return true, nil
+}
+
+// RestoreMissing is Restore for a record copied from another node, such as
+// a backup republish: rec is stored only if the key is missing, counting
+// against its namespace's quota like a Put, and never over a tombstone
+// dated at or after rec.StoredAt, which fails with ErrDeleted. As an
+// existing value is never replaced, no overwrite policy can be violated.
+// It reports whether rec was stored.
+func (kv *KeyValueStore) RestoreMissing(rec StoredRecord) (bool, error) {
+	kv.mu.Lock()
+	if deletedAt, deleted := kv.tombstones[rec.Key]; deleted && !rec.StoredAt.After(deletedAt) {
+		kv.mu.Unlock()
+		return false, ErrDeleted
+	}
+	if _, exists := kv.entries[rec.Key]; exists {
+		kv.mu.Unlock()
+		return false, nil
+	}
+	ns, _ := SplitNamespacedKey(rec.Key)
+	if quota := kv.namespaces[ns].Quota; quota > 0 && kv.usage[ns] >= quota {
+		kv.mu.Unlock()
+		return false, ErrQuotaExceeded
+	}
+	if err := kv.restore(rec); err != nil {
+		kv.mu.Unlock()
+		return false, err
+	}
+	kv.dropTombstone(rec.Key)
+	kv.mu.Unlock()
+
+	kv.Events.Emit(Event{Type: ValueStored, Key: rec.Key, Value: rec.Value})
+	return true, nil
+}
+
+// restore implements Restore; callers must hold kv.mu
+func (kv *KeyValueStore) restore(rec StoredRecord) error {
+	if err := kv.set(rec.Key, rec.Value); err != nil {
+		return err
+	}
+	if rec.Publisher != "" {
+		kv.publishers[rec.Key] = rec.Publisher
+	} else {
+		delete(kv.publishers, rec.Key)
+	}
+	meta := kv.entries[rec.Key]
+	if !rec.StoredAt.IsZero() {
+		meta.storedAt = rec.StoredAt
+		meta.firstStored = rec.StoredAt
+	}
+	if !rec.FirstStoredAt.IsZero() && rec.FirstStoredAt.Before(meta.storedAt) {
+		meta.firstStored = rec.FirstStoredAt
+	}
+	meta.hops = rec.Hops
+	meta.ttl, meta.republish = kv.Intervals.Clamp(Seconds(rec.TTL), Seconds(rec.Republish))
+	kv.saveMeta(rec.Key)
+	return nil
+}
+
+// KeyFilter returns a Bloom filter of the stored keys. It is kept up to
+// date as keys are added and rebuilt once the store outgrows it; deleted
+// keys linger in it until then, which only costs false positives.
+func (kv *KeyValueStore) KeyFilter() *BloomFilter {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.filter == nil {
+		// Leave room to grow before the next rebuild
+		kv.filterCap = 2*len(kv.entries) + 64
+		kv.filter = NewBloomFilter(kv.filterCap, BloomFalsePositiveRate)
+		for key := range kv.entries {
+			kv.filter.Add(key)
+		}
+	}
+	return kv.filter.Clone()
+}
+
+// Len returns the number of stored keys
+func (kv *KeyValueStore) Len() int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return len(kv.entries)
+}
+
+// Close closes the backend, flushing it to disk if it is persistent. The
+// store must not be used afterwards.
+func (kv *KeyValueStore) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.backend.Close()
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// MaxLookupCacheEntries bounds the lookups a LookupCache remembers; the
+// oldest is forgotten first
+const MaxLookupCacheEntries = 1024
+
+// LookupEntry is the result of one node lookup
+type LookupEntry struct {
+	Target  string
+	K       int
+	Require CapabilityFlags
+	Nodes   []*Node // Closest to Target first
+	Expires time.Time
+}
+
+// LookupCache remembers the closest contacts recent node lookups found,
+// so a burst of operations on the same keys runs one lookup per key
+// rather than one per operation. Entries live for the TTL unless the
+// routing table changes in a way that could alter them first. A nil
+// *LookupCache remembers nothing.
+type LookupCache struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	entries     map[string]*LookupEntry
+	hits        uint64
+	misses      uint64
+	invalidated uint64
+}
+
+// LookupCacheStats counts the cache's use
+type LookupCacheStats struct {
+	Entries     int    `json:"entries"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Invalidated uint64 `json:"invalidated"` // Entries dropped by routing table changes before they expired
+}
+
+// NewLookupCache creates a cache keeping lookup results for ttl
+func NewLookupCache(ttl time.Duration) *LookupCache {
+	return &LookupCache{ttl: ttl, entries: make(map[string]*LookupEntry)}
+}
+
+// Get returns the contacts of the unexpired lookup for target with k and
+// require
+func (c *LookupCache) Get(target string, k int, require CapabilityFlags) ([]*Node, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := lookupKey(target, k, require)
+	entry, ok := c.entries[key]
+	if ok && !clock.Now().Before(entry.Expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return append([]*Node(nil), entry.Nodes...), true
+}
+
+// Put remembers nodes as the result of the lookup for target with k and
+// require
+func (c *LookupCache) Put(target string, k int, require CapabilityFlags, nodes []*Node) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := clock.Now()
+	key := lookupKey(target, k, require)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= MaxLookupCacheEntries {
+		c.evict(now)
+	}
+	c.entries[key] = &LookupEntry{Target: target, K: k, Require: require, Nodes: append([]*Node(nil), nodes...), Expires: now.Add(c.ttl)}
+}
+
+// Invalidate drops the entries for which stale returns true and returns
+// how many it dropped
+func (c *LookupCache) Invalidate(stale func(*LookupEntry) bool) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dropped := 0
+	for key, entry := range c.entries {
+		if stale(entry) {
+			delete(c.entries, key)
+			dropped++
+		}
+	}
+	c.invalidated += uint64(dropped)
+	return dropped
+}
+
+// Stats returns the cache's counters
+func (c *LookupCache) Stats() LookupCacheStats {
+	if c == nil {
+		return LookupCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return LookupCacheStats{Entries: len(c.entries), Hits: c.hits, Misses: c.misses, Invalidated: c.invalidated}
+}
+
+// evict drops the expired entries, or else the one expiring first; c.mu
+// must be held
+func (c *LookupCache) evict(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.Expires) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) < MaxLookupCacheEntries {
+		return
+	}
+	var oldest string
+	for key, entry := range c.entries {
+		if oldest == "" || entry.Expires.Before(c.entries[oldest].Expires) {
+			oldest = key
+		}
+	}
+	delete(c.entries, oldest)
+}
+
+func lookupKey(target string, k int, require CapabilityFlags) string {
+	return fmt.Sprintf("%s/%d/%d", target, k, require)
+}
@@ -1,5 +1,14 @@
 package models
 
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Aradhya2708/kademlia/pkg/bencode"
+)
+
 type MessageType string
 
 const (
@@ -8,12 +17,104 @@ const (
 	Store     MessageType = "STORE"
 	FindValue MessageType = "FIND_VALUE"
 	Pong      MessageType = "PONG"
+
+	AddProvider  MessageType = "ADD_PROVIDER"
+	GetProviders MessageType = "GET_PROVIDERS"
+
+	// Replies
+	FoundNodes     MessageType = "NODES"     // Closest contacts, answering FIND_NODE or a FIND_VALUE miss
+	FoundValue     MessageType = "VALUE"     // The value, answering FIND_VALUE
+	Stored         MessageType = "STORED"    // Acknowledges STORE and ADD_PROVIDER
+	FoundProviders MessageType = "PROVIDERS" // Providers of the key, answering GET_PROVIDERS
+	Error          MessageType = "ERROR"     // The request was refused, see Message.Error
 )
 
+// MessageVersion is the version of the Message envelope spoken by this
+// implementation. Peers reject envelopes with a newer version.
+const MessageVersion = 1
+
+// ErrInvalidMessage is returned when a message signature fails verification
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Message is the envelope of every RPC sent to /rpc and of its reply. The
+// reply echoes the request's Nonce, so a caller can tell it answers its
+// own request. A message may be signed by the key of the sender's record.
 type Message struct {
-	Type   MessageType // Type of the message (PING, STORE, etc.)
-	Sender Node        // Sender's information
-	Key    string      // Key being looked up (if applicable)
-	Value  string      // Value to store (if applicable)
-	Target string      // Target ID for FIND_NODE or FIND_VALUE
+	Type   MessageType `json:"type"`             // Type of the message (PING, STORE, etc.)
+	Sender Node        `json:"sender"`           // Sender's information
+	Key    string      `json:"key,omitempty"`    // Key being looked up (if applicable)
+	Value  string      `json:"value,omitempty"`  // Value to store (if applicable)
+	Target string      `json:"target,omitempty"` // Target ID for FIND_NODE or FIND_VALUE
+	Count  int         `json:"count,omitempty"`  // Contacts wanted from FIND_NODE or FIND_VALUE, up to the responder's k; 0 for k
+
+	Version   int     `json:"version"`             // Envelope version, MessageVersion when sent by this implementation
+	Nonce     string  `json:"nonce"`               // Random request ID, echoed in the reply
+	Nodes     []*Node `json:"nodes,omitempty"`     // Contacts or providers carried by a reply
+	Error     string  `json:"error,omitempty"`     // Reason an ERROR reply refused the request
+	Signature string  `json:"signature,omitempty"` // Hex ed25519 signature of the other fields by Sender.Record's key
+	Token     string  `json:"token,omitempty"`     // Write token issued in FOUND_NODES and FOUND_VALUE replies, presented by STOREs
+	Encoding  string  `json:"encoding,omitempty"`  // ValueEncodingBase64 if Value holds the base64 of a binary value
+
+	RecordType RecordType `json:"record_type,omitempty"` // Type of the record a STORE merges into the stored one, RecordValue to replace it
+}
+
+// EncodeValue makes the message's value safe for JSON, as EncodeValue does,
+// unless it is already encoded. Signed messages must be encoded before
+// signing.
+func (m *Message) EncodeValue() {
+	if m.Encoding == "" {
+		m.Value, m.Encoding = EncodeValue(m.Value)
+	}
+}
+
+// DecodeValue replaces the message's value by the bytes it encodes
+func (m *Message) DecodeValue() error {
+	value, err := DecodeValue(m.Value, m.Encoding)
+	if err != nil {
+		return err
+	}
+	m.Value, m.Encoding = value, ""
+	return nil
+}
+
+// signingBytes returns the canonical encoding covered by the signature
+func (m *Message) signingBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return bencode.Marshal(unsigned)
+}
+
+// Sign signs the message with key, which must be the key of the sender's
+// record for peers to accept it
+func (m *Message) Sign(key ed25519.PrivateKey) error {
+	data, err := m.signingBytes()
+	if err != nil {
+		return err
+	}
+	m.Signature = hex.EncodeToString(ed25519.Sign(key, data))
+	return nil
+}
+
+// Verify checks that the message is signed by the public key of the
+// sender's record
+func (m *Message) Verify() error {
+	if m.Sender.Record == nil {
+		return fmt.Errorf("%w: signed by a sender without a record", ErrInvalidMessage)
+	}
+	pub, err := hex.DecodeString(m.Sender.Record.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: bad public key", ErrInvalidMessage)
+	}
+	sig, err := hex.DecodeString(m.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: bad signature encoding", ErrInvalidMessage)
+	}
+	data, err := m.signingBytes()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidMessage)
+	}
+	return nil
 }
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// NamespacePolicy holds the per-namespace limits of a KeyValueStore
+type NamespacePolicy struct {
+	Quota int    // Maximum number of keys stored in the namespace, 0 means unlimited
+	Token string // Token required to read or write the namespace, empty means open
+}
+
+var namespacePattern = regexp.MustCompile("^[a-z0-9_-]{1,64}$")
+
+var (
+	// ErrInvalidNamespace is returned for namespace names that are not [a-z0-9_-]{1,64}
+	ErrInvalidNamespace = errors.New("invalid namespace name")
+	// ErrQuotaExceeded is returned when a STORE would create a key beyond the namespace quota
+	ErrQuotaExceeded = errors.New("namespace quota exceeded")
+)
+
+// ValidateNamespace checks a namespace name; the empty (default) namespace is valid
+func ValidateNamespace(ns string) error {
+	if ns != "" && !namespacePattern.MatchString(ns) {
+		return ErrInvalidNamespace
+	}
+	return nil
+}
+
+// NamespacedKey returns the storage key of key within namespace ns, so that
+// equal keys from different applications never collide.
+func NamespacedKey(ns, key string) string {
+	if ns == "" {
+		return key
+	}
+	return ns + "/" + key
+}
+
+// SplitNamespacedKey is the inverse of NamespacedKey
+func SplitNamespacedKey(storageKey string) (ns, key string) {
+	if i := strings.IndexByte(storageKey, '/'); i >= 0 {
+		return storageKey[:i], storageKey[i+1:]
+	}
+	return "", storageKey
+}
+
+// SetNamespacePolicy configures the quota and token of namespace ns
+func (kv *KeyValueStore) SetNamespacePolicy(ns string, policy NamespacePolicy) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.namespaces[ns] = policy
+}
+
+// NamespacePolicy returns the policy of namespace ns (the zero policy if none is set)
+func (kv *KeyValueStore) NamespacePolicy(ns string) NamespacePolicy {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.namespaces[ns]
+}
+
+// NamespaceUsage returns the number of keys stored in namespace ns
+func (kv *KeyValueStore) NamespaceUsage(ns string) int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.usage[ns]
+}
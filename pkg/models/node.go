@@ -1,17 +1,178 @@
 package models
 
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+)
+
 type Node struct {
 	ID       string // Unique identifier for the node (e.g., SHA-1 or XOR hash of IP+port)
 	IP       string // IP address of the node
 	Port     int    // Port on which the node is listening
-	LastSeen int64  // Timestamp for when the node was last active
+	LastSeen int64  // Unix time the node was last active; use SeenAt to compare
+
+	seen time.Time // LastSeen as read from the local clock, with its monotonic reading
+
+	Flags    CapabilityFlags `json:",omitempty"` // Services the node offers, 0 if it never said
+	Protocol int             `json:",omitempty"` // RPC protocol version the node speaks, 0 if it never said
+	Relay    string          `json:",omitempty"` // <ip>:<port> of the relay forwarding RPCs to a node behind NAT
+	Network  string          `json:",omitempty"` // Network the node belongs to, empty for the default network
+
+	Record *NodeRecord `json:",omitempty"` // Signed advertisement, if the node sent one
+}
+
+// CapabilityFlags is a bitmask of the optional services a node offers.
+// Contacts carry their flags in PING and FIND_NODE exchanges, so lookups
+// can skip nodes that would refuse the operation being performed.
+type CapabilityFlags uint32
+
+// Services a node can offer
+const (
+	FlagStorage   CapabilityFlags = 1 << iota // Accepts STOREs and answers FIND_VALUE from its storage
+	FlagRelay                                 // Forwards RPCs for peers that cannot be reached directly
+	FlagPubSub                                // Hosts pub/sub topics
+	FlagProviders                             // Tracks content providers
+)
+
+// DefaultFlags are the services offered by a full node
+const DefaultFlags = FlagStorage | FlagPubSub | FlagProviders
+
+// ProtocolVersion is the RPC protocol version spoken by this implementation
+const ProtocolVersion = 1
+
+// MaxNetworkIDLength bounds the length of a network ID
+const MaxNetworkIDLength = 64
+
+// ValidNetworkID reports whether id may name a network: at most
+// MaxNetworkIDLength letters, digits, '.', '_' or '-'. The empty ID names
+// the default network.
+func ValidNetworkID(id string) bool {
+	if len(id) > MaxNetworkIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MarkSeen records that the node was active at now. Local clock readings
+// keep their monotonic reading, so contacts seen by this node are ordered
+// correctly even when the wall clock steps; LastSeen holds the same time
+// for the wire.
+func (n *Node) MarkSeen(now time.Time) {
+	n.seen = now
+	n.LastSeen = now.Unix()
+}
+
+// SeenAt returns when the node was last active: the time given to
+// MarkSeen, or else LastSeen, which may have been set by a peer's clock.
+// It is zero if the node was never seen.
+func (n *Node) SeenAt() time.Time {
+	if !n.seen.IsZero() {
+		return n.seen
+	}
+	if n.LastSeen == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n.LastSeen, 0)
+}
+
+// Supports reports whether the node offers every service in flags. Nodes
+// that never advertised flags predate them and are assumed to offer
+// everything a full node does.
+func (n *Node) Supports(flags CapabilityFlags) bool {
+	if n.Flags == 0 {
+		return DefaultFlags&flags == flags
+	}
+	return n.Flags&flags == flags
 }
 
 type Bucket struct {
-	Nodes   []*Node // List of nodes in the bucket
-	MaxSize int     // Maximum allowed nodes (k)
+	Nodes       []*Node   // List of nodes in the bucket
+	MaxSize     int       // Maximum allowed nodes (k)
+	LastUpdated time.Time // When a contact in range was last seen or looked up, zero if never
+
+	SubnetRefused uint64 // Contacts in range refused by the routing table's SubnetLimit
 }
 
 type RoutingTable struct {
-	Buckets []*Bucket // List of buckets
+	Buckets []*Bucket       // List of buckets
+	Events  *EventBus       // Receives PeerAdded/PeerEvicted events, may be nil
+	K       int             // Bucket size and number of closest contacts returned, 0 for the default
+	Pinned  map[string]bool // IDs of contacts that are never evicted, may be nil
+
+	// Reputation records how reliably contacts answer lookups, so
+	// failing ones are queried later; may be nil
+	Reputation *PeerReputation
+
+	// Filters holds the key filters contacts advertised, so FIND_VALUE
+	// asks the contacts that may hold a key first; may be nil
+	Filters *KeyFilters
+
+	// Peers keeps the metadata of every peer seen, and the contacts the
+	// buckets reference; may be nil
+	Peers *PeerStore
+
+	// Allowlist holds the only peers admitted as contacts and allowed to
+	// write to the node's storage; nil admits every peer
+	Allowlist *Allowlist
+
+	// SubnetLimit caps the contacts from one subnet per bucket and in the
+	// whole table; the zero value leaves them unlimited
+	SubnetLimit SubnetLimit
+
+	// Lookups caches recent node lookup results, dropping those a change
+	// of contacts could alter; may be nil
+	Lookups *LookupCache
+
+	// PeerSampling names the policy choosing the peers /peers hands out,
+	// "" for random, and MaxExchangePeers caps how many it hands out per
+	// request, 0 for no cap
+	PeerSampling     string
+	MaxExchangePeers int
+
+	// DialBack makes the node ping a pinger not yet known at its address
+	// back there before adding it
+	DialBack bool
+
+	// FixedTimeouts makes lookups wait for each peer the whole RPC
+	// timeout instead of only as long as its RTT history suggests
+	FixedTimeouts bool
+
+	// Confirm pings n at its address over the table's transport and
+	// returns nil if it answers as n.ID, before a contact is followed to
+	// an address it could not prove; nil pings over HTTP
+	Confirm func(ctx context.Context, n *Node) error
+
+	// RWMutex guards Buckets and the contacts in them, which handlers,
+	// scheduled jobs and lookups reach concurrently
+	sync.RWMutex
+}
+
+// BucketSize returns the table's k, falling back to the global default
+// when it was not set at construction
+func (rt *RoutingTable) BucketSize() int {
+	if rt.K > 0 {
+		return rt.K
+	}
+	return constants.GetK()
+}
+
+// Contacts returns the contacts of every bucket, in bucket order
+func (rt *RoutingTable) Contacts() []*Node {
+	rt.RLock()
+	defer rt.RUnlock()
+	var contacts []*Node
+	for _, bucket := range rt.Buckets {
+		contacts = append(contacts, bucket.Nodes...)
+	}
+	return contacts
 }
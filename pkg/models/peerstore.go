@@ -0,0 +1,402 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// MaxPeerAddresses bounds the addresses remembered per peer
+const MaxPeerAddresses = 4
+
+// PeerInfo is everything known about a peer, whether or not the routing
+// table holds it
+type PeerInfo struct {
+	ID        string          `json:"id"`
+	Addresses []string        `json:"addresses"` // <ip>:<port> it was seen at, most recent first
+	Relay     string          `json:"relay,omitempty"`
+	Flags     CapabilityFlags `json:"flags,omitempty"`
+	Protocol  int             `json:"protocol,omitempty"`
+	InTable   bool            `json:"in_table"` // Whether the routing table holds it
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen,omitempty"`  // Last activity, by the contact's SeenAt
+	LastReply time.Time `json:"last_reply,omitempty"` // Last RPC to it that succeeded
+
+	// Failures counts the RPCs to it that failed since its last reply, the
+	// latest of which is LastError
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+
+	// From the routing table's reputation
+	RTT   time.Duration `json:"rtt,omitempty"`
+	Trust float64       `json:"trust"`
+}
+
+// Failing reports whether the last RPC to the peer failed
+func (p PeerInfo) Failing() bool {
+	return p.Failures > 0
+}
+
+// PeerStore keeps the metadata of every peer seen, up to
+// MaxReputationPeers, and the one *Node per peer that routing table
+// buckets reference. Peers out of the table are forgotten least recently
+// seen first. It also counts the new IDs each IP adds to the routing
+// table, refusing those beyond the insert limit. A nil *PeerStore keeps
+// nothing and refuses nothing.
+type PeerStore struct {
+	reputation *PeerReputation
+
+	mu    sync.Mutex
+	peers map[string]*peerEntry
+
+	insertLimit  int           // New IDs an IP may add per insertWindow, 0 for unlimited
+	insertWindow time.Duration // Window over which insertions are counted
+	inserts      map[string]*ipInserts
+	refused      uint64 // Insertions refused over the limit, ever
+}
+
+// ipInserts are the IDs one IP added to the routing table within the
+// insert window
+type ipInserts struct {
+	ids     map[string]time.Time // ID -> when it was last added
+	refused uint64               // Insertions refused over the limit, ever
+}
+
+type peerEntry struct {
+	info PeerInfo
+	node *Node // nil until the peer is observed as a contact
+}
+
+// NewPeerStore creates an empty store, reporting RTT and trust from
+// reputation, which may be nil
+func NewPeerStore(reputation *PeerReputation) *PeerStore {
+	return &PeerStore{reputation: reputation, peers: make(map[string]*peerEntry), inserts: make(map[string]*ipInserts)}
+}
+
+// Observe records contact and returns the *Node the routing table should
+// hold for it: the one already stored for its ID, updated with contact's
+// address, activity and capabilities, or else contact itself
+func (s *PeerStore) Observe(contact *Node) *Node {
+	if s == nil {
+		return contact
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entryOf(contact.ID)
+	if entry.node == nil {
+		entry.node = contact
+	} else if entry.node != contact {
+		n := entry.node
+		if contact.IP != "" && contact.Port != 0 {
+			n.IP, n.Port = contact.IP, contact.Port
+		}
+		if seen := contact.SeenAt(); seen.After(n.SeenAt()) {
+			n.MarkSeen(seen)
+		}
+		if contact.Flags != 0 {
+			n.Flags, n.Protocol, n.Relay = contact.Flags, contact.Protocol, contact.Relay
+		}
+	}
+	entry.noteAddress(entry.node)
+	entry.info.InTable = true
+	return entry.node
+}
+
+// Left records that the routing table dropped the peer id
+func (s *PeerStore) Left(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.peers[id]; ok {
+		entry.info.InTable = false
+	}
+}
+
+// RecordRPC records the outcome of an RPC to peer, err nil if it
+// succeeded
+func (s *PeerStore) RecordRPC(peer *Node, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entryOf(peer.ID)
+	if entry.node == nil {
+		entry.noteAddress(peer)
+	}
+	now := clock.Now()
+	if err == nil {
+		entry.info.LastReply = now
+		entry.info.Failures = 0
+		return
+	}
+	entry.info.Failures++
+	entry.info.LastError = err.Error()
+	entry.info.LastErrorAt = now
+}
+
+// Failing reports whether the last RPC to the peer id failed
+func (s *PeerStore) Failing(id string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.peers[id]
+	return ok && entry.info.Failing()
+}
+
+// Peer returns what is known about the peer id
+func (s *PeerStore) Peer(id string) (PeerInfo, bool) {
+	if s == nil {
+		return PeerInfo{}, false
+	}
+	s.mu.Lock()
+	entry, ok := s.peers[id]
+	var info PeerInfo
+	if ok {
+		info = entry.snapshot()
+	}
+	s.mu.Unlock()
+	if !ok {
+		return PeerInfo{}, false
+	}
+	return s.withReputation(info, clock.Now()), true
+}
+
+// Peers returns what is known about every stored peer, most recently seen
+// first
+func (s *PeerStore) Peers() []PeerInfo {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	peers := make([]PeerInfo, 0, len(s.peers))
+	for _, entry := range s.peers {
+		peers = append(peers, entry.snapshot())
+	}
+	s.mu.Unlock()
+
+	now := clock.Now()
+	for i := range peers {
+		peers[i] = s.withReputation(peers[i], now)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if !peers[i].LastSeen.Equal(peers[j].LastSeen) {
+			return peers[i].LastSeen.After(peers[j].LastSeen)
+		}
+		return peers[i].ID < peers[j].ID
+	})
+	return peers
+}
+
+// Len returns the number of stored peers
+func (s *PeerStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.peers)
+}
+
+// SetInsertLimit lets each IP add at most limit new IDs to the routing
+// table per window, 0 for no limit
+func (s *PeerStore) SetInsertLimit(limit int, window time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.insertLimit, s.insertWindow = limit, window
+}
+
+// AllowInsert reports whether ip may add the ID id to the routing table,
+// counting it among ip's insertions if so. An ID the IP added within the
+// window is always allowed again; a new one only while the IP added
+// fewer IDs than the limit in the window.
+func (s *PeerStore) AllowInsert(ip, id string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.insertLimit <= 0 {
+		return true
+	}
+
+	now := clock.Now()
+	if len(s.inserts) >= MaxReputationPeers {
+		s.forgetInserts(now)
+	}
+	inserts, ok := s.inserts[ip]
+	if !ok {
+		inserts = &ipInserts{ids: make(map[string]time.Time)}
+		s.inserts[ip] = inserts
+	}
+	inserts.expire(now.Add(-s.insertWindow))
+	if _, known := inserts.ids[id]; !known && len(inserts.ids) >= s.insertLimit {
+		inserts.refused++
+		s.refused++
+		return false
+	}
+	inserts.ids[id] = now
+	return true
+}
+
+// InsertSource counts the IDs one IP added to the routing table
+type InsertSource struct {
+	IP      string `json:"ip"`
+	IDs     int    `json:"ids"`     // Distinct IDs added within the window
+	Refused uint64 `json:"refused"` // IDs refused over the limit, ever
+}
+
+// InsertStats reports the insert limit and how close the IPs came to it
+type InsertStats struct {
+	Limit   int            `json:"limit"` // New IDs an IP may add per window, 0 for unlimited
+	Window  time.Duration  `json:"window"`
+	Refused uint64         `json:"refused"` // Insertions refused over the limit, ever
+	Sources []InsertSource `json:"sources"` // IPs that added IDs within the window or were refused, most IDs first
+}
+
+// InsertStats returns the insertion counts of every IP tracked
+func (s *PeerStore) InsertStats() InsertStats {
+	if s == nil {
+		return InsertStats{Sources: []InsertSource{}}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := InsertStats{Limit: s.insertLimit, Window: s.insertWindow, Refused: s.refused, Sources: []InsertSource{}}
+	since := clock.Now().Add(-s.insertWindow)
+	for ip, inserts := range s.inserts {
+		inserts.expire(since)
+		if len(inserts.ids) > 0 || inserts.refused > 0 {
+			stats.Sources = append(stats.Sources, InsertSource{IP: ip, IDs: len(inserts.ids), Refused: inserts.refused})
+		}
+	}
+	sort.Slice(stats.Sources, func(i, j int) bool {
+		a, b := stats.Sources[i], stats.Sources[j]
+		if a.IDs != b.IDs {
+			return a.IDs > b.IDs
+		}
+		if a.Refused != b.Refused {
+			return a.Refused > b.Refused
+		}
+		return a.IP < b.IP
+	})
+	return stats
+}
+
+// forgetInserts drops the IPs that added no ID within the window and were
+// never refused; s.mu must be held
+func (s *PeerStore) forgetInserts(now time.Time) {
+	since := now.Add(-s.insertWindow)
+	for ip, inserts := range s.inserts {
+		inserts.expire(since)
+		if len(inserts.ids) == 0 && inserts.refused == 0 {
+			delete(s.inserts, ip)
+		}
+	}
+}
+
+// expire drops the IDs added before since
+func (in *ipInserts) expire(since time.Time) {
+	for id, added := range in.ids {
+		if added.Before(since) {
+			delete(in.ids, id)
+		}
+	}
+}
+
+// entryOf returns the entry of the peer id, creating it and forgetting the
+// least recently seen peer out of the table if the store is full; s.mu
+// must be held
+func (s *PeerStore) entryOf(id string) *peerEntry {
+	entry, ok := s.peers[id]
+	if !ok {
+		if len(s.peers) >= MaxReputationPeers {
+			s.forgetOldest()
+		}
+		entry = &peerEntry{info: PeerInfo{ID: id, FirstSeen: clock.Now()}}
+		s.peers[id] = entry
+	}
+	return entry
+}
+
+// forgetOldest drops the least recently seen peer the routing table does
+// not hold, if any; s.mu must be held
+func (s *PeerStore) forgetOldest() {
+	var oldest *peerEntry
+	for _, entry := range s.peers {
+		if entry.info.InTable {
+			continue
+		}
+		if oldest == nil || entry.lastSeen().Before(oldest.lastSeen()) {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		delete(s.peers, oldest.info.ID)
+	}
+}
+
+// withReputation fills in the RTT and trust of info
+func (s *PeerStore) withReputation(info PeerInfo, now time.Time) PeerInfo {
+	score := s.reputation.Score(info.ID)
+	info.RTT = score.RTT
+	info.Trust = score.Trust(now)
+	return info
+}
+
+// noteAddress moves the address of n to the front of the entry's
+// addresses and copies its capabilities
+func (e *peerEntry) noteAddress(n *Node) {
+	if n.Flags != 0 {
+		e.info.Flags, e.info.Protocol, e.info.Relay = n.Flags, n.Protocol, n.Relay
+	}
+	if n.IP == "" || n.Port == 0 {
+		return
+	}
+	addr := fmt.Sprintf("%s:%d", n.IP, n.Port)
+	addresses := []string{addr}
+	for _, a := range e.info.Addresses {
+		if a != addr && len(addresses) < MaxPeerAddresses {
+			addresses = append(addresses, a)
+		}
+	}
+	e.info.Addresses = addresses
+}
+
+// lastSeen returns when the peer was last active or answered
+func (e *peerEntry) lastSeen() time.Time {
+	seen := e.info.LastReply
+	if e.node != nil && e.node.SeenAt().After(seen) {
+		seen = e.node.SeenAt()
+	}
+	if seen.IsZero() {
+		return e.info.FirstSeen
+	}
+	return seen
+}
+
+// snapshot returns a copy of the entry's info with the contact's current
+// activity and capabilities
+func (e *peerEntry) snapshot() PeerInfo {
+	info := e.info
+	info.Addresses = append([]string(nil), e.info.Addresses...)
+	info.LastSeen = e.lastSeen()
+	if e.node != nil && e.node.Flags != 0 {
+		info.Flags, info.Protocol, info.Relay = e.node.Flags, e.node.Protocol, e.node.Relay
+	}
+	return info
+}
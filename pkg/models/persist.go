@@ -0,0 +1,152 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Keys under which a KeyValueStore keeps its bookkeeping in a persistent
+// backend, beside the values. Stored keys are hex IDs, behind a namespace
+// of lowercase letters, digits, '-' and '_' if any, so they never start
+// with '!'.
+const (
+	metaKeyPrefix      = "!meta/"
+	tombstoneKeyPrefix = "!tombstone/"
+)
+
+// persistedMeta is the bookkeeping of a value kept in a persistent backend,
+// so a restarted node keeps its publisher, age and intervals
+type persistedMeta struct {
+	Publisher   string        `json:"publisher,omitempty"`
+	StoredAt    time.Time     `json:"stored_at"`
+	FirstStored time.Time     `json:"first_stored"`
+	Hops        int           `json:"hops,omitempty"`
+	TTL         time.Duration `json:"ttl,omitempty"`
+	Republish   time.Duration `json:"republish,omitempty"`
+}
+
+// persistedTombstone is a tombstone kept in a persistent backend with the
+// publisher's signature of it. Unsigned tombstones are kept as their date
+// alone.
+type persistedTombstone struct {
+	DeletedAt time.Time    `json:"deleted_at"`
+	Proof     *DeleteProof `json:"proof"`
+}
+
+// bookkeepingKey reports whether key holds bookkeeping rather than a value
+func bookkeepingKey(key string) bool {
+	return strings.HasPrefix(key, metaKeyPrefix) || strings.HasPrefix(key, tombstoneKeyPrefix)
+}
+
+// load takes over what backend holds: values as if they had just been
+// stored, then the bookkeeping saved beside them. Bookkeeping of values
+// that are gone is dropped.
+func (kv *KeyValueStore) load() error {
+	metas := make(map[string]string)
+	err := kv.backend.Iterate(func(key, value string) bool {
+		switch {
+		case strings.HasPrefix(key, metaKeyPrefix):
+			metas[strings.TrimPrefix(key, metaKeyPrefix)] = value
+		case strings.HasPrefix(key, tombstoneKeyPrefix):
+			key = strings.TrimPrefix(key, tombstoneKeyPrefix)
+			var saved persistedTombstone
+			if at, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				kv.tombstones[key] = at
+			} else if json.Unmarshal([]byte(value), &saved) == nil && saved.Proof != nil {
+				kv.tombstones[key] = saved.DeletedAt
+				kv.proofs[key] = *saved.Proof
+			}
+		default:
+			kv.track(key, value)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, raw := range metas {
+		meta := kv.entries[key]
+		var saved persistedMeta
+		if meta == nil || json.Unmarshal([]byte(raw), &saved) != nil {
+			kv.backend.Delete(metaKeyPrefix + key)
+			continue
+		}
+		if saved.Publisher != "" {
+			kv.publishers[key] = saved.Publisher
+		}
+		if !saved.StoredAt.IsZero() {
+			meta.storedAt = saved.StoredAt
+			meta.firstStored = saved.StoredAt
+		}
+		if !saved.FirstStored.IsZero() && saved.FirstStored.Before(meta.storedAt) {
+			meta.firstStored = saved.FirstStored
+		}
+		meta.hops, meta.ttl, meta.republish = saved.Hops, saved.TTL, saved.Republish
+	}
+	return nil
+}
+
+// saveMeta writes the bookkeeping of key beside its value in a persistent
+// backend; callers must hold kv.mu. A failed write only loses it if the
+// node restarts.
+func (kv *KeyValueStore) saveMeta(key string) {
+	meta := kv.entries[key]
+	if !kv.persistent || meta == nil {
+		return
+	}
+	data, err := json.Marshal(persistedMeta{
+		Publisher:   kv.publishers[key],
+		StoredAt:    meta.storedAt,
+		FirstStored: meta.firstStored,
+		Hops:        meta.hops,
+		TTL:         meta.ttl,
+		Republish:   meta.republish,
+	})
+	if err == nil {
+		kv.backend.Set(metaKeyPrefix+key, string(data))
+	}
+}
+
+// dropMeta removes the bookkeeping of a deleted key from a persistent
+// backend; callers must hold kv.mu
+func (kv *KeyValueStore) dropMeta(key string) {
+	if kv.persistent {
+		kv.backend.Delete(metaKeyPrefix + key)
+	}
+}
+
+// setTombstone records that key was deleted at deletedAt, with proof if
+// its publisher signed the delete, in a persistent backend too, so a
+// restarted node still refuses the value; callers must hold kv.mu
+func (kv *KeyValueStore) setTombstone(key string, deletedAt time.Time, proof *DeleteProof) {
+	kv.tombstones[key] = deletedAt
+	delete(kv.proofs, key)
+	if proof != nil {
+		kv.proofs[key] = *proof
+	}
+	if !kv.persistent {
+		return
+	}
+	saved := deletedAt.UTC().Format(time.RFC3339Nano)
+	if proof != nil {
+		if data, err := json.Marshal(persistedTombstone{DeletedAt: deletedAt, Proof: proof}); err == nil {
+			saved = string(data)
+		}
+	}
+	kv.backend.Set(tombstoneKeyPrefix+key, saved)
+}
+
+// dropTombstone removes the tombstone of key, if any; callers must hold
+// kv.mu
+func (kv *KeyValueStore) dropTombstone(key string) {
+	if _, exists := kv.tombstones[key]; !exists {
+		return
+	}
+	delete(kv.tombstones, key)
+	delete(kv.proofs, key)
+	if kv.persistent {
+		kv.backend.Delete(tombstoneKeyPrefix + key)
+	}
+}
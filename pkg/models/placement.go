@@ -0,0 +1,57 @@
+package models
+
+import "sync/atomic"
+
+// PlacementStats counts how a node answered the STOREs sent to it. Many
+// STOREs answered with closer contacts mean senders' routing tables lack
+// the nodes nearest the keys they store, so values land on the wrong
+// replicas. The zero value is ready to use.
+type PlacementStats struct {
+	accepted   atomic.Int64
+	notClosest atomic.Int64
+	outside    atomic.Int64
+	gapBits    atomic.Int64 // Sum of the gaps of the NotClosest STOREs
+}
+
+// PlacementSnapshot is a copy of PlacementStats as served by /store_stats
+type PlacementSnapshot struct {
+	Accepted              int64   `json:"accepted"`               // Stored here
+	NotClosest            int64   `json:"not_closest"`            // Answered with closer contacts
+	OutsideResponsibility int64   `json:"outside_responsibility"` // Refused by the responsibility radius
+	AcceptRate            float64 `json:"accept_rate"`            // Accepted share of the three, 0 before any STORE
+	MeanGapBits           float64 `json:"mean_gap_bits"`          // Mean log2 distance by which the node missed the k closest, over NotClosest
+}
+
+// Accept counts a STORE the node kept
+func (s *PlacementStats) Accept() {
+	s.accepted.Add(1)
+}
+
+// NotClosest counts a STORE answered with closer contacts because the node
+// was gapBits farther from the key, in log2 distance, than the farthest of
+// the k closest contacts it knows
+func (s *PlacementStats) NotClosest(gapBits int) {
+	s.notClosest.Add(1)
+	s.gapBits.Add(int64(gapBits))
+}
+
+// Outside counts a STORE refused for a key outside the responsibility radius
+func (s *PlacementStats) Outside() {
+	s.outside.Add(1)
+}
+
+// Snapshot returns the current counts
+func (s *PlacementStats) Snapshot() PlacementSnapshot {
+	snap := PlacementSnapshot{
+		Accepted:              s.accepted.Load(),
+		NotClosest:            s.notClosest.Load(),
+		OutsideResponsibility: s.outside.Load(),
+	}
+	if total := snap.Accepted + snap.NotClosest + snap.OutsideResponsibility; total > 0 {
+		snap.AcceptRate = float64(snap.Accepted) / float64(total)
+	}
+	if snap.NotClosest > 0 {
+		snap.MeanGapBits = float64(s.gapBits.Load()) / float64(snap.NotClosest)
+	}
+	return snap
+}
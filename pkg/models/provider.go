@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// ErrProvidersFull is returned when the provider store holds MaxRecords
+// unexpired records and a new one is announced
+var ErrProvidersFull = errors.New("provider store is full")
+
+// ProviderRecord announces that a node can serve the content behind a key
+type ProviderRecord struct {
+	Provider Node  // Node claiming to provide the content
+	Expires  int64 // Unix time after which the record is no longer valid
+}
+
+// ProviderStore represents a thread-safe store of provider records, kept
+// separately from the key-value pairs themselves
+type ProviderStore struct {
+	mu         sync.Mutex
+	Providers  map[string]map[string]*ProviderRecord // content key -> provider ID -> record
+	TTL        time.Duration                         // Lifetime of a record since its last announcement
+	MaxPerKey  int                                   // Maximum number of providers kept per key
+	MaxRecords int                                   // Maximum number of records kept across all keys, 0 for no limit
+
+	records int // Records held across all keys
+}
+
+// NewProviderStore initializes a new ProviderStore
+func NewProviderStore(ttl time.Duration, maxPerKey, maxRecords int) *ProviderStore {
+	return &ProviderStore{
+		Providers:  make(map[string]map[string]*ProviderRecord),
+		TTL:        ttl,
+		MaxPerKey:  maxPerKey,
+		MaxRecords: maxRecords,
+	}
+}
+
+// Add records provider as a provider of key, refreshing its expiry if it is
+// already known. When the key is at capacity the record closest to expiry
+// is replaced. A provider new to the store is refused with
+// ErrProvidersFull while it holds MaxRecords unexpired records.
+func (ps *ProviderStore) Add(key string, provider Node) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := clock.Now()
+	ps.expire(key, now.Unix())
+
+	records := ps.Providers[key]
+	_, exists := records[provider.ID]
+	if !exists && len(records) < ps.MaxPerKey && ps.MaxRecords > 0 && ps.records >= ps.MaxRecords {
+		ps.sweep(now.Unix())
+		if ps.records >= ps.MaxRecords {
+			return ErrProvidersFull
+		}
+	}
+	if records == nil {
+		records = make(map[string]*ProviderRecord)
+		ps.Providers[key] = records
+	}
+
+	if !exists && len(records) >= ps.MaxPerKey {
+		var oldest string
+		for id, rec := range records {
+			if oldest == "" || rec.Expires < records[oldest].Expires {
+				oldest = id
+			}
+		}
+		delete(records, oldest)
+		ps.records--
+	}
+
+	if !exists {
+		ps.records++
+	}
+	records[provider.ID] = &ProviderRecord{
+		Provider: provider,
+		Expires:  now.Add(ps.TTL).Unix(),
+	}
+	return nil
+}
+
+// Get returns the unexpired providers of key
+func (ps *ProviderStore) Get(key string) []Node {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.expire(key, clock.Now().Unix())
+
+	providers := make([]Node, 0, len(ps.Providers[key]))
+	for _, rec := range ps.Providers[key] {
+		providers = append(providers, rec.Provider)
+	}
+	return providers
+}
+
+// Len returns the number of records held across all keys, including
+// expired ones not yet swept
+func (ps *ProviderStore) Len() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.records
+}
+
+// Sweep drops the expired records of every key and returns how many it
+// dropped
+func (ps *ProviderStore) Sweep() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.sweep(clock.Now().Unix())
+}
+
+// sweep drops the expired records of every key; callers must hold ps.mu
+func (ps *ProviderStore) sweep(now int64) int {
+	dropped := 0
+	for key := range ps.Providers {
+		dropped += ps.expire(key, now)
+	}
+	return dropped
+}
+
+// expire drops the expired records of key and returns how many it
+// dropped; callers must hold ps.mu
+func (ps *ProviderStore) expire(key string, now int64) int {
+	dropped := 0
+	for id, rec := range ps.Providers[key] {
+		if rec.Expires <= now {
+			delete(ps.Providers[key], id)
+			dropped++
+		}
+	}
+	ps.records -= dropped
+	if len(ps.Providers[key]) == 0 {
+		delete(ps.Providers, key)
+	}
+	return dropped
+}
@@ -0,0 +1,14 @@
+package models
+
+// Subscriber represents a party interested in messages published to a topic
+type Subscriber struct {
+	ID      string `json:"id"`                // Application-chosen subscriber identifier
+	Webhook string `json:"webhook,omitempty"` // Optional URL notified on every publish
+}
+
+// TopicMessage is a single message published to a topic
+type TopicMessage struct {
+	Topic     string `json:"topic"`     // Human-readable topic name
+	Payload   string `json:"payload"`   // Message body
+	Timestamp int64  `json:"timestamp"` // Unix time at which the rendezvous node accepted the message
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+)
+
+// Reputation tuning
+const (
+	// ReputationDecay scales a peer's past outcomes down at each new one,
+	// so its last ten or so RPCs dominate its success rate
+	ReputationDecay = 0.9
+
+	// MaxReputationPeers bounds the peers tracked; the least recently
+	// contacted are forgotten first
+	MaxReputationPeers = 4096
+
+	// RTTSmoothing is the weight of a new round-trip sample in a peer's
+	// smoothed RTT, as in TCP's SRTT
+	RTTSmoothing = 0.125
+
+	// RTTVarSmoothing is the weight of a new sample's deviation in a
+	// peer's RTT variation, as in TCP's RTTVAR
+	RTTVarSmoothing = 0.25
+
+	// MinAdaptiveTimeout is the shortest timeout Timeout gives a peer, so
+	// a run of fast replies does not make the next slightly slower one fail
+	MinAdaptiveTimeout = 100 * time.Millisecond
+)
+
+// PeerScore is the decayed count of a peer's successful and failed RPCs,
+// and its smoothed round-trip time
+type PeerScore struct {
+	Successes float64       `json:"successes"`
+	Failures  float64       `json:"failures"`
+	RTT       time.Duration `json:"rtt,omitempty"`     // 0 until an RTT is recorded
+	RTTVar    time.Duration `json:"rtt_var,omitempty"` // Smoothed deviation of the RTT samples
+	Updated   time.Time     `json:"updated"`
+
+	// Session history: a peer is online from joining the routing table or
+	// answering an RPC until an RPC to it fails or it leaves the table
+	Online   bool          `json:"online"`
+	JoinedAt time.Time     `json:"joined_at,omitempty"` // Start of the current or last session
+	LeftAt   time.Time     `json:"left_at,omitempty"`   // End of the last session
+	Sessions int           `json:"sessions"`
+	Rejoins  int           `json:"rejoins"` // Sessions after the first
+	Drops    int           `json:"drops"`   // Sessions ended by a failed RPC
+	Uptime   time.Duration `json:"uptime"`  // Total length of the ended sessions
+
+	churn     float64   // Drops decayed by ChurnHalfLife as of droppedAt
+	droppedAt time.Time // End of the last dropped session
+}
+
+// SuccessRate estimates the chance of the peer's next RPC succeeding. A
+// peer without history gets 0.5, and every outcome moves it towards 0 or 1.
+func (s PeerScore) SuccessRate() float64 {
+	return (s.Successes + 1) / (s.Successes + s.Failures + 2)
+}
+
+// PeerReputation tracks how reliably each peer answers RPCs. A nil
+// *PeerReputation records nothing and rates every peer 0.5.
+type PeerReputation struct {
+	mu    sync.Mutex
+	peers map[string]*PeerScore
+}
+
+// NewPeerReputation creates an empty reputation record
+func NewPeerReputation() *PeerReputation {
+	return &PeerReputation{peers: make(map[string]*PeerScore)}
+}
+
+// Record notes the outcome of an RPC to the peer id
+func (r *PeerReputation) Record(id string, success bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	score := r.scoreOf(id)
+	score.Successes *= ReputationDecay
+	score.Failures *= ReputationDecay
+	now := clock.Now()
+	if success {
+		score.Successes++
+		score.join(now)
+	} else {
+		score.Failures++
+		score.leave(now, true)
+	}
+	score.Updated = now
+}
+
+// RecordRTT folds a round-trip time measured to the peer id into its
+// smoothed RTT
+func (r *PeerReputation) RecordRTT(id string, rtt time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	score := r.scoreOf(id)
+	if score.RTT == 0 {
+		score.RTT = rtt
+		score.RTTVar = rtt / 2
+	} else {
+		deviation := rtt - score.RTT
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		score.RTTVar += time.Duration(RTTVarSmoothing * float64(deviation-score.RTTVar))
+		score.RTT += time.Duration(RTTSmoothing * float64(rtt-score.RTT))
+	}
+	score.Updated = clock.Now()
+}
+
+// Timeout returns how long to wait for an RPC to the peer id: its
+// smoothed RTT plus four times its RTT variation, as TCP computes its
+// retransmission timeout, within MinAdaptiveTimeout and max. A peer
+// without RTT samples gets max.
+func (r *PeerReputation) Timeout(id string, max time.Duration) time.Duration {
+	score := r.Score(id)
+	if score.RTT == 0 {
+		return max
+	}
+	timeout := score.RTT + 4*score.RTTVar
+	if timeout < MinAdaptiveTimeout {
+		timeout = MinAdaptiveTimeout
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
+}
+
+// scoreOf returns the record of the peer id, creating it and forgetting
+// the oldest peer if the table is full; r.mu must be held
+func (r *PeerReputation) scoreOf(id string) *PeerScore {
+	score, ok := r.peers[id]
+	if !ok {
+		if len(r.peers) >= MaxReputationPeers {
+			r.forgetOldest()
+		}
+		score = &PeerScore{}
+		r.peers[id] = score
+	}
+	return score
+}
+
+// forgetOldest drops the least recently updated peer; r.mu must be held
+func (r *PeerReputation) forgetOldest() {
+	var oldest string
+	for id, score := range r.peers {
+		if oldest == "" || score.Updated.Before(r.peers[oldest].Updated) {
+			oldest = id
+		}
+	}
+	delete(r.peers, oldest)
+}
+
+// Score returns the peer's record, zero if it has none
+func (r *PeerReputation) Score(id string) PeerScore {
+	if r == nil {
+		return PeerScore{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if score, ok := r.peers[id]; ok {
+		return *score
+	}
+	return PeerScore{}
+}
+
+// RTT returns the smoothed round-trip time of the peer id, 0 if none was
+// recorded
+func (r *PeerReputation) RTT(id string) time.Duration {
+	return r.Score(id).RTT
+}
+
+// SuccessRate returns Score(id).SuccessRate()
+func (r *PeerReputation) SuccessRate(id string) float64 {
+	return r.Score(id).SuccessRate()
+}
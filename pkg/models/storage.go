@@ -0,0 +1,78 @@
+package models
+
+import "sync"
+
+// Storage is the backend a KeyValueStore keeps its values in. The
+// KeyValueStore applies overwrite policies, quotas and garbage collection
+// bookkeeping on top; a backend only persists key-value pairs.
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	Set(key, value string) error
+	Get(key string) (value string, found bool, err error)
+	Delete(key string) error
+	// Iterate calls fn for every stored pair, in no particular order,
+	// until fn returns false
+	Iterate(fn func(key, value string) bool) error
+	Len() int
+	Close() error
+}
+
+// MemoryStorage keeps values in a map. It is the fastest backend and the
+// default, but loses everything when the process exits.
+type MemoryStorage struct {
+	mu   sync.RWMutex
+	data map[string]string
+}
+
+// NewMemoryStorage creates an empty in-memory backend
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{data: make(map[string]string)}
+}
+
+func (m *MemoryStorage) Set(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *MemoryStorage) Get(key string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, found := m.data[key]
+	return value, found, nil
+}
+
+func (m *MemoryStorage) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+// Iterate runs fn on a snapshot, so fn may modify the storage
+func (m *MemoryStorage) Iterate(fn func(key, value string) bool) error {
+	m.mu.RLock()
+	snapshot := make(map[string]string, len(m.data))
+	for k, v := range m.data {
+		snapshot[k] = v
+	}
+	m.mu.RUnlock()
+
+	for k, v := range snapshot {
+		if !fn(k, v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.data)
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
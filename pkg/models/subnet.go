@@ -0,0 +1,29 @@
+package models
+
+import "net"
+
+// SubnetLimit caps the contacts a routing table holds from one subnet, a
+// /24 for IPv4 or a /64 for IPv6, so an attacker controlling a subnet
+// cannot fill the buckets and eclipse the node. 0 leaves either unlimited.
+type SubnetLimit struct {
+	PerBucket int // Contacts from one subnet per bucket
+	PerTable  int // Contacts from one subnet in the whole table
+}
+
+// Enabled reports whether either limit is set
+func (l SubnetLimit) Enabled() bool {
+	return l.PerBucket > 0 || l.PerTable > 0
+}
+
+// Subnet returns the /24 of an IPv4 address or the /64 of an IPv6 one in
+// CIDR notation, or "" if ip is not an IP address
+func Subnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
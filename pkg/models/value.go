@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ValueEncodingBase64 marks a value carried in JSON as the standard base64
+// of its bytes. Values are byte strings, but JSON strings hold only UTF-8
+// text, so values that are not valid UTF-8 are sent this way.
+const ValueEncodingBase64 = "base64"
+
+// EncodeValue returns value as carried in a JSON field with its encoding:
+// unchanged with no encoding if it is valid UTF-8, else its base64 with
+// ValueEncodingBase64
+func EncodeValue(value string) (string, string) {
+	if utf8.ValidString(value) {
+		return value, ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(value)), ValueEncodingBase64
+}
+
+// DecodeValue returns the bytes of a value carried in JSON with the given
+// encoding, as returned by EncodeValue
+func DecodeValue(value, encoding string) (string, error) {
+	switch encoding {
+	case "":
+		return value, nil
+	case ValueEncodingBase64:
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 value: %v", err)
+		}
+		return string(raw), nil
+	default:
+		return "", fmt.Errorf("unknown value encoding %q", encoding)
+	}
+}
+
+// MarshalJSON writes the record with its value encoded by EncodeValue
+func (r StoredRecord) MarshalJSON() ([]byte, error) {
+	type plain StoredRecord
+	value, encoding := EncodeValue(r.Value)
+	r.Value = value
+	return json.Marshal(struct {
+		plain
+		Encoding string `json:"encoding,omitempty"`
+	}{plain(r), encoding})
+}
+
+// UnmarshalJSON reads a record written by MarshalJSON
+func (r *StoredRecord) UnmarshalJSON(data []byte) error {
+	type plain StoredRecord
+	var rec struct {
+		plain
+		Encoding string `json:"encoding,omitempty"`
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	value, err := DecodeValue(rec.Value, rec.Encoding)
+	if err != nil {
+		return err
+	}
+	rec.Value = value
+	*r = StoredRecord(rec.plain)
+	return nil
+}
@@ -0,0 +1,243 @@
+// Package storage provides the persistent models.Storage backends: a flat
+// file and SQLite.
+package storage
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrClosed is returned by a backend used after Close
+var ErrClosed = errors.New("storage is closed")
+
+// fileRecord is one line of a FileStorage log. A deletion has Deleted set
+// and no value. Values that are not valid UTF-8 are written as base64 with
+// Base64 set, as JSON strings cannot hold them.
+type fileRecord struct {
+	Key     string `json:"k"`
+	Value   string `json:"v,omitempty"`
+	Base64  bool   `json:"b,omitempty"`
+	Deleted bool   `json:"d,omitempty"`
+}
+
+// newFileRecord returns the log line storing value under key
+func newFileRecord(key, value string) fileRecord {
+	if utf8.ValidString(value) {
+		return fileRecord{Key: key, Value: value}
+	}
+	return fileRecord{Key: key, Value: base64.StdEncoding.EncodeToString([]byte(value)), Base64: true}
+}
+
+// value returns the stored value of the record
+func (rec fileRecord) value() (string, error) {
+	if !rec.Base64 {
+		return rec.Value, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(rec.Value)
+	return string(raw), err
+}
+
+// FileStorage keeps values in memory and appends every change to a flat
+// file of JSON lines, which is replayed on open. The log is compacted when
+// it grows well past the number of live keys. Writes reach the operating
+// system before returning but are only synced to disk on compaction and
+// Close.
+type FileStorage struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	data    map[string]string
+	records int // Lines in the log, live or superseded
+}
+
+// OpenFile opens the log at path, creating it if it does not exist
+func OpenFile(path string) (*FileStorage, error) {
+	fs := &FileStorage{path: path, data: make(map[string]string)}
+	if err := fs.replay(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage file: %v", err)
+	}
+	fs.file = file
+	fs.writer = bufio.NewWriter(file)
+	return fs, nil
+}
+
+// replay loads the log into memory. A torn last line, left by a crash
+// mid-write, is ignored.
+func (fs *FileStorage) replay() error {
+	file, err := os.Open(fs.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open storage file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var rec fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Deleted {
+			delete(fs.data, rec.Key)
+		} else if value, err := rec.value(); err == nil {
+			fs.data[rec.Key] = value
+		} else {
+			continue
+		}
+		fs.records++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read storage file: %v", err)
+	}
+	return nil
+}
+
+func (fs *FileStorage) Set(key, value string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.append(newFileRecord(key, value)); err != nil {
+		return err
+	}
+	fs.data[key] = value
+	return nil
+}
+
+func (fs *FileStorage) Get(key string) (string, bool, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.file == nil {
+		return "", false, ErrClosed
+	}
+	value, found := fs.data[key]
+	return value, found, nil
+}
+
+func (fs *FileStorage) Delete(key string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, found := fs.data[key]; !found {
+		return nil
+	}
+	if err := fs.append(fileRecord{Key: key, Deleted: true}); err != nil {
+		return err
+	}
+	delete(fs.data, key)
+	return nil
+}
+
+// Iterate runs fn on a snapshot, so fn may modify the storage
+func (fs *FileStorage) Iterate(fn func(key, value string) bool) error {
+	fs.mu.RLock()
+	if fs.file == nil {
+		fs.mu.RUnlock()
+		return ErrClosed
+	}
+	snapshot := make(map[string]string, len(fs.data))
+	for k, v := range fs.data {
+		snapshot[k] = v
+	}
+	fs.mu.RUnlock()
+
+	for k, v := range snapshot {
+		if !fn(k, v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (fs *FileStorage) Len() int {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return len(fs.data)
+}
+
+// Close compacts the log and closes it
+func (fs *FileStorage) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.file == nil {
+		return nil
+	}
+	err := fs.compact()
+	if closeErr := fs.file.Close(); err == nil {
+		err = closeErr
+	}
+	fs.file, fs.writer = nil, nil
+	return err
+}
+
+// append writes rec to the log, compacting it first if most of its lines
+// are superseded; fs.mu must be held
+func (fs *FileStorage) append(rec fileRecord) error {
+	if fs.file == nil {
+		return ErrClosed
+	}
+	if fs.records > 1024 && fs.records > 4*len(fs.data) {
+		if err := fs.compact(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	fs.writer.Write(line)
+	fs.writer.WriteByte('\n')
+	if err := fs.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write storage file: %v", err)
+	}
+	fs.records++
+	return nil
+}
+
+// compact rewrites the log with one line per live key, replacing the old
+// log atomically; fs.mu must be held
+func (fs *FileStorage) compact() error {
+	tmp := fs.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to compact storage file: %v", err)
+	}
+	writer := bufio.NewWriter(file)
+	for k, v := range fs.data {
+		line, _ := json.Marshal(newFileRecord(k, v))
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compact storage file: %v", err)
+	}
+	if err := os.Rename(tmp, fs.path); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compact storage file: %v", err)
+	}
+
+	// Keep appending to the compacted log, which is now at fs.path
+	fs.file.Close()
+	fs.file, fs.writer = file, bufio.NewWriter(file)
+	fs.records = len(fs.data)
+	return nil
+}
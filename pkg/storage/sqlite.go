@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SQLiteStorage keeps values in a SQLite database. Every write is a
+// committed transaction, so it is the most durable backend and the
+// slowest.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens the database at path, creating it and its table if they
+// do not exist. It fails on js/wasm, where the driver is not built in.
+func OpenSQLite(path string) (*SQLiteStorage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage database: %v", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids
+	// SQLITE_BUSY errors between the pool's connections
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`PRAGMA journal_mode=WAL`,
+		`CREATE TABLE IF NOT EXISTS kv (key TEXT PRIMARY KEY, value TEXT NOT NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize storage database: %v", err)
+		}
+	}
+	return &SQLiteStorage{db: db}, nil
+}
+
+func (s *SQLiteStorage) Set(key, value string) error {
+	_, err := s.db.Exec(`INSERT INTO kv (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func (s *SQLiteStorage) Get(key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM kv WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *SQLiteStorage) Delete(key string) error {
+	_, err := s.db.Exec(`DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+// Iterate reads every pair before calling fn, so fn may modify the storage
+// without waiting for the single connection
+func (s *SQLiteStorage) Iterate(fn func(key, value string) bool) error {
+	rows, err := s.db.Query(`SELECT key, value FROM kv`)
+	if err != nil {
+		return err
+	}
+	var pairs [][2]string
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range pairs {
+		if !fn(p[0], p[1]) {
+			break
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored keys, or 0 if the database cannot be
+// read
+func (s *SQLiteStorage) Len() int {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM kv`).Scan(&n)
+	return n
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,7 @@
+//go:build !js
+
+package storage
+
+import (
+	_ "modernc.org/sqlite" // Registers the pure-Go "sqlite" driver
+)
@@ -0,0 +1,115 @@
+package benchmark
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// BenchmarkXORDistance compares the fixed-size XOR distance with the
+// big.Int implementation it replaced on the routing table's hot paths
+func BenchmarkXORDistance(b *testing.B) {
+	logger := testutils.NewTestLogger(nil, "BENCHMARK")
+	fixtures := testutils.NewTestFixtures(logger)
+
+	ids := make([]string, 256)
+	for i := range ids {
+		ids[i] = fixtures.GenerateValidHexID(fmt.Sprintf("distance%d", i))
+	}
+
+	b.Run("BigInt", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = bigXORDistance(ids[i%len(ids)], ids[(i+1)%len(ids)])
+		}
+	})
+
+	b.Run("Fixed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = kademlia.XORDistance(ids[i%len(ids)], ids[(i+1)%len(ids)])
+		}
+	})
+
+	b.Run("BigIntBucketIndex", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = bigXORDistance(ids[i%len(ids)], ids[(i+1)%len(ids)]).BitLen()
+		}
+	})
+
+	b.Run("FixedBucketIndex", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			d, _ := kademlia.XORDistance(ids[i%len(ids)], ids[(i+1)%len(ids)])
+			_ = d.BitLen()
+		}
+	})
+
+	b.Run("BigIntCompare", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			target := ids[i%len(ids)]
+			_ = bigXORDistance(target, ids[(i+1)%len(ids)]).Cmp(bigXORDistance(target, ids[(i+2)%len(ids)]))
+		}
+	})
+
+	b.Run("FixedCompare", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			target := ids[i%len(ids)]
+			d1, _ := kademlia.XORDistance(target, ids[(i+1)%len(ids)])
+			d2, _ := kademlia.XORDistance(target, ids[(i+2)%len(ids)])
+			_ = d1.Cmp(d2)
+		}
+	})
+}
+
+// bigXORDistance is the big.Int distance the routing table used before
+// Distance, kept here as the baseline
+func bigXORDistance(id1, id2 string) *big.Int {
+	decode := func(s string) []byte {
+		result, ok := new(big.Int).SetString(strings.ToUpper(s), 16)
+		if !ok {
+			return []byte{}
+		}
+		return result.Bytes()
+	}
+	return new(big.Int).Xor(new(big.Int).SetBytes(decode(id1)), new(big.Int).SetBytes(decode(id2)))
+}
+
+// BenchmarkFindClosestNodes compares scanning a large routing table on one
+// goroutine with scanning it in shards on one per CPU. Run it with
+// -cpu 1,2,4,8 to see the sharded scan scale with cores.
+func BenchmarkFindClosestNodes(b *testing.B) {
+	defer func(threshold int) { kademlia.ParallelScanThreshold = threshold }(kademlia.ParallelScanThreshold)
+
+	for _, contacts := range []int{1000, 10000, 100000} {
+		localID := kademlia.KeyFromString("local")
+		rt := kademlia.NewRoutingTableWithK(localID, contacts)
+		for i := 0; i < contacts; i++ {
+			kademlia.AddNodeToRoutingTable(rt, &models.Node{ID: kademlia.KeyFromString(fmt.Sprintf("contact%d", i))}, localID)
+		}
+		targets := make([]string, 256)
+		for i := range targets {
+			targets[i] = kademlia.KeyFromString(fmt.Sprintf("target%d", i))
+		}
+
+		for _, mode := range []struct {
+			name      string
+			threshold int
+		}{{"Single", 1 << 30}, {"Sharded", 1}} {
+			b.Run(fmt.Sprintf("Contacts=%d/%s", contacts, mode.name), func(b *testing.B) {
+				kademlia.ParallelScanThreshold = mode.threshold
+				for i := 0; i < b.N; i++ {
+					kademlia.FindClosestNodes(rt, targets[i%len(targets)], localID, 20)
+				}
+			})
+		}
+	}
+}
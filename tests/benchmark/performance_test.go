@@ -88,7 +88,7 @@ func BenchmarkRoutingTable(b *testing.B) {
 	b.Run("FindClosestNodes", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			targetID := fixtures.GenerateValidHexID(fmt.Sprintf("target%d", i))
-			kademlia.FindClosestNodes(routingTable, targetID, node.ID)
+			kademlia.FindClosestNodes(routingTable, targetID, node.ID, 0)
 		}
 	})
 }
@@ -167,7 +167,7 @@ func TestPerformanceRegression(t *testing.T) {
 		start = time.Now()
 		for i := 0; i < numFinds; i++ {
 			targetID := fixtures.GenerateValidHexID(fmt.Sprintf("findperf%d", i))
-			kademlia.FindClosestNodes(routingTable, targetID, node.ID)
+			kademlia.FindClosestNodes(routingTable, targetID, node.ID, 0)
 		}
 		findDuration := time.Since(start)
 
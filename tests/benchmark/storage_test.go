@@ -0,0 +1,31 @@
+package benchmark
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+)
+
+// BenchmarkStorageBackends compares writes and reads through a
+// KeyValueStore on each storage backend
+func BenchmarkStorageBackends(b *testing.B) {
+	for _, backend := range []string{"memory", "file", "sqlite"} {
+		b.Run(backend, func(b *testing.B) {
+			kvs, err := kademlia.OpenKeyValueStore(config.StorageConfig{Backend: backend, Path: filepath.Join(b.TempDir(), "store")})
+			if err != nil {
+				b.Fatalf("failed to open %s storage: %v", backend, err)
+			}
+			defer kvs.Close()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				key := fmt.Sprintf("key%d", i%1024)
+				kvs.Set(key, "value")
+				kvs.Get(key)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -64,7 +65,7 @@ func TestFullKademliaWorkflow(t *testing.T) {
 		// First node is bootstrap, others join through it
 		for i := 1; i < numNodes; i++ {
 			bootstrapAddr := getServerAddress(servers[0])
-			err := kademlia.JoinNetwork(nodes[i], routingTables[i], bootstrapAddr)
+			err := kademlia.JoinNetwork(context.Background(), nodes[i], routingTables[i], bootstrapAddr)
 			assert.NoError(err, "Node %d should join network successfully", i)
 		}
 
@@ -72,7 +73,7 @@ func TestFullKademliaWorkflow(t *testing.T) {
 		time.Sleep(100 * time.Millisecond) // Allow routing tables to update
 
 		for i := 0; i < numNodes; i++ {
-			closestNodes := kademlia.FindClosestNodes(routingTables[i], nodes[0].ID, nodes[i].ID)
+			closestNodes := kademlia.FindClosestNodes(routingTables[i], nodes[0].ID, nodes[i].ID, 0)
 			assert.True(len(closestNodes) > 0, "Node %d should know about other nodes", i)
 			section.Info("Node %d knows about %d other nodes", i, len(closestNodes))
 		}
@@ -145,7 +146,7 @@ func TestNetworkResilience(t *testing.T) {
 
 		section.Step(2, "Bootstrap network")
 		for i := 1; i < 3; i++ {
-			kademlia.JoinNetwork(nodes[i], routingTables[i], getServerAddress(servers[0]))
+			kademlia.JoinNetwork(context.Background(), nodes[i], routingTables[i], getServerAddress(servers[0]))
 		}
 
 		section.Step(3, "Store data before failure")
@@ -237,7 +238,7 @@ func TestScalability(t *testing.T) {
 		targetID := fixtures.GenerateValidHexID("target")
 
 		start = time.Now()
-		closestNodes := kademlia.FindClosestNodes(routingTable, targetID, node.ID)
+		closestNodes := kademlia.FindClosestNodes(routingTable, targetID, node.ID, 0)
 		findDuration := time.Since(start)
 
 		section.Step(4, "Verify performance")
@@ -78,6 +78,7 @@ func (m *MockServer) handlePing(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"message": "pong",
 		"node_id": m.node.ID,
+		"token":   r.URL.Query().Get("token"),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,150 @@
+package unit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestAddressMonitor tests detecting a change of the node's address and
+// announcing the new one
+func TestAddressMonitor(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "ADDRESS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting address change tests")
+
+	// peer serves PING as a node with its own routing table
+	peer := func(name string) (*models.Node, *models.RoutingTable) {
+		n := &models.Node{ID: fixtures.GenerateValidHexID(name), IP: "127.0.0.1"}
+		table := kademlia.NewRoutingTable(n.ID)
+		storage := kademlia.NewKeyValueStore()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PingHandler(w, r, n, storage, table)
+		}))
+		t.Cleanup(server.Close)
+		addr := strings.TrimPrefix(server.URL, "http://")
+		n.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		return n, table
+	}
+	// contactIn returns the contact id has in table
+	contactIn := func(table *models.RoutingTable, localID, id string) *models.Node {
+		for _, n := range kademlia.FindClosestNodes(table, id, localID, 0) {
+			if n.ID == id {
+				return n
+			}
+		}
+		return nil
+	}
+
+	t.Run("Change", func(t *testing.T) {
+		section := logger.Section("Adopting a New Address")
+
+		self := &models.Node{ID: fixtures.GenerateValidHexID("moved"), IP: "10.9.9.9", Port: 8080}
+		key, _ := kademlia.GenerateRecordKey()
+		sign := func() error {
+			return kademlia.SignNodeRecord(self, key, []string{"http://" + net.JoinHostPort(self.IP, strconv.Itoa(self.Port))}, nil)
+		}
+		assert.NoError(sign(), "Signing should succeed")
+		table := kademlia.NewRoutingTable(self.ID)
+		table.Events = models.NewEventBus()
+		kademlia.AddNodeToRoutingTable(table, self, self.ID)
+		tables := make(map[string]*models.RoutingTable)
+		for _, name := range []string{"a", "b", "c"} {
+			n, peerTable := peer(name)
+			kademlia.AddNodeToRoutingTable(table, n, self.ID)
+			tables[n.ID] = peerTable
+		}
+		resigned := 0
+		monitor := kademlia.NewAddressMonitor(self, table, kademlia.AddressConfig{Sample: 8, Quorum: 3, Resign: func() error {
+			resigned++
+			return sign()
+		}})
+		events, cancel := table.Events.Subscribe(4, models.AddressChanged)
+		defer cancel()
+
+		section.Step(1, "A quorum of contacts seeing a new IP makes the node adopt it")
+		report := monitor.Check(context.Background())
+		assert.Equal(3, report.Answered, "Every contact should answer")
+		assert.Equal(3, report.Observed["127.0.0.1"], "Every contact should see the loopback IP")
+		assert.Equal("10.9.9.9", report.Previous, "Old IP should be reported")
+		assert.Equal("127.0.0.1", report.Current, "New IP should be adopted")
+		assert.Equal("127.0.0.1", self.IP, "Node should advertise the new IP")
+		assert.Equal(1, resigned, "A new record should be signed")
+
+		section.Step(2, "Every contact is told the new address, signed by the node's key")
+		assert.Equal(3, report.Announced, "Every contact should be announced to")
+		for id, peerTable := range tables {
+			contact := contactIn(peerTable, id, self.ID)
+			assert.True(contact != nil, "Contact should know the node")
+			if contact != nil {
+				assert.Equal("127.0.0.1", contact.IP, "Contact should follow the new IP")
+			}
+		}
+		if contact := contactIn(table, self.ID, self.ID); contact != nil {
+			assert.Equal("127.0.0.1", contact.IP, "Own contact should follow the new IP")
+		}
+		select {
+		case e := <-events:
+			assert.Equal("10.9.9.9", e.Value, "Event should carry the old IP")
+		case <-time.After(time.Second):
+			assert.True(false, "AddressChanged should be emitted")
+		}
+
+		section.Step(3, "An unchanged address is left alone")
+		report = monitor.Check(context.Background())
+		assert.Equal(0, report.Announced, "Nothing should be announced")
+		assert.Equal(1, resigned, "No record should be signed")
+
+		section.Success("Address change adopted correctly")
+	})
+
+	t.Run("Quorum", func(t *testing.T) {
+		section := logger.Section("Address Quorum")
+
+		self := &models.Node{ID: fixtures.GenerateValidHexID("quorum"), IP: "10.9.9.9", Port: 8080}
+		table := kademlia.NewRoutingTable(self.ID)
+		for _, name := range []string{"d", "e"} {
+			n, _ := peer(name)
+			kademlia.AddNodeToRoutingTable(table, n, self.ID)
+		}
+
+		section.Step(1, "Fewer contacts than the quorum do not change the address")
+		report := kademlia.NewAddressMonitor(self, table, kademlia.AddressConfig{Sample: 8, Quorum: 3}).Check(context.Background())
+		assert.Equal(2, report.Observed["127.0.0.1"], "Both contacts should see the loopback IP")
+		assert.Equal("10.9.9.9", self.IP, "Address should be kept")
+
+		section.Step(2, "Nodes advertising an unspecified IP keep it")
+		self.IP = "0.0.0.0"
+		kademlia.NewAddressMonitor(self, table, kademlia.AddressConfig{Sample: 8, Quorum: 1}).Check(context.Background())
+		assert.Equal("0.0.0.0", self.IP, "Unspecified IP should be kept")
+
+		section.Success("Address quorum working correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Address Check Configuration")
+
+		section.Step(1, "Settings are validated")
+		cfg := config.Default()
+		cfg.Address.Sample = 0
+		assert.HasError(cfg.Validate(), "Empty sample should be refused")
+		cfg.Address.Sample, cfg.Address.Quorum = 4, 5
+		assert.HasError(cfg.Validate(), "Quorum above the sample should be refused")
+		cfg.Address.Interval = 0
+		assert.NoError(cfg.Validate(), "Disabled checks need no sample")
+
+		section.Success("Address check configuration working correctly")
+	})
+}
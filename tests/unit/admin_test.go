@@ -0,0 +1,238 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestAdmin tests the admin endpoints: moving stored records between nodes
+// and listing stored keys
+func TestAdmin(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "ADMIN")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting admin endpoint tests")
+
+	start := func(adminToken string) *kademlia.Node {
+		cfg := config.Default()
+		cfg.Port = 0
+		cfg.Server.AdminToken = adminToken
+		node := kademlia.NewNode(cfg)
+		assert.NoError(node.Start(context.Background()), "Node should start")
+		return node
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		section := logger.Section("Export And Import")
+		ctx := context.Background()
+
+		source := start("admin-secret")
+		defer source.Stop()
+		target := start("admin-secret")
+		defer target.Stop()
+
+		section.Step(1, "Export records with their metadata")
+		keyA := fixtures.GenerateValidHexID("exportA")
+		keyB := models.NamespacedKey("app", fixtures.GenerateValidHexID("exportB"))
+		_, err := source.Storage.Put(keyA, "alpha", "publisher1", models.OverwriteAlways, "")
+		assert.NoError(err, "Put should succeed")
+		source.Storage.Set(keyB, "beta")
+		storedAt := map[string]time.Time{}
+		for _, rec := range source.Storage.Records() {
+			storedAt[rec.Key] = rec.StoredAt
+		}
+
+		c := client.NewClient(source.Addr())
+		c.AdminToken = "admin-secret"
+		var export bytes.Buffer
+		assert.NoError(c.Export(ctx, &export), "Export should succeed")
+		lines := strings.Split(strings.TrimSpace(export.String()), "\n")
+		assert.Equal(3, len(lines), "Export should hold a header and two records")
+		assert.Contains(lines[0], `"format":"kademlia-export"`, "First line should be the header")
+		assert.Contains(export.String(), `"publisher":"publisher1"`, "Publisher should be exported")
+		assert.Contains(export.String(), `"expires_at"`, "Expiry should be exported under the default TTL")
+
+		section.Step(2, "Import into another node")
+		c = client.NewClient(target.Addr())
+		c.AdminToken = "admin-secret"
+		result, err := c.Import(ctx, bytes.NewReader(export.Bytes()), false)
+		assert.NoError(err, "Import should succeed")
+		assert.Equal(2, result.Imported, "Both records should be imported")
+
+		value, found := target.Storage.Get(keyA)
+		assert.True(found && value == "alpha", "Value should be imported")
+		assert.Equal(1, target.Storage.NamespaceUsage("app"), "Namespace usage should count the import")
+		for _, rec := range target.Storage.Records() {
+			assert.True(rec.StoredAt.Equal(storedAt[rec.Key]), "Age of %s should carry over", rec.Key)
+		}
+		_, err = target.Storage.Put(keyA, "hijack", "publisher2", models.OverwriteSamePublisher, "")
+		assert.Equal(models.ErrPublisherMismatch, err, "Publisher should carry over")
+
+		section.Step(3, "Existing keys are kept unless overwriting")
+		target.Storage.Set(keyB, "local")
+		result, err = c.Import(ctx, bytes.NewReader(export.Bytes()), false)
+		assert.NoError(err, "Import should succeed")
+		assert.Equal(2, result.Skipped, "Existing keys should be skipped")
+		value, _ = target.Storage.Get(keyB)
+		assert.Equal("local", value, "Existing value should be kept")
+		result, err = c.Import(ctx, bytes.NewReader(export.Bytes()), true)
+		assert.NoError(err, "Import should succeed")
+		assert.Equal(2, result.Imported, "Existing keys should be overwritten")
+		value, _ = target.Storage.Get(keyB)
+		assert.Equal("beta", value, "Imported value should replace the local one")
+
+		section.Step(4, "Malformed imports are rejected")
+		_, err = c.Import(ctx, strings.NewReader(`{"format":"other","version":1}`+"\n"), false)
+		assert.HasError(err, "Unknown formats should be rejected")
+
+		section.Success("Records moved between nodes with their metadata")
+	})
+
+	t.Run("ListKeys", func(t *testing.T) {
+		section := logger.Section("List Keys")
+		ctx := context.Background()
+
+		node := start("admin-secret")
+		defer node.Stop()
+		for _, key := range []string{"ab01", "ab02", "ab03", "cd01"} {
+			node.Storage.Set(key, "v")
+		}
+		node.Storage.Set(models.NamespacedKey("app", "ab04"), "v")
+
+		c := client.NewClient(node.Addr())
+		c.AdminToken = "admin-secret"
+		keys := func(list kademlia.KeyList) string {
+			var out []string
+			for _, k := range list.Keys {
+				out = append(out, k.Key)
+			}
+			return fmt.Sprint(out)
+		}
+
+		section.Step(1, "Keys are listed by hex prefix across namespaces")
+		list, err := c.ListKeys(ctx, "AB", 0, "")
+		assert.NoError(err, "Listing should succeed")
+		assert.Equal("[ab01 ab02 ab03 app/ab04]", keys(list), "Keys matching the prefix should be listed in order")
+		assert.Equal(5, list.Keys[0].SizeBytes, "Size of key and value should be reported")
+
+		section.Step(2, "Listings are paginated")
+		list, err = c.ListKeys(ctx, "ab", 2, "")
+		assert.NoError(err, "Listing should succeed")
+		assert.Equal("[ab01 ab02]", keys(list), "First page should hold two keys")
+		list, err = c.ListKeys(ctx, "ab", 2, list.NextToken)
+		assert.NoError(err, "Listing should succeed")
+		assert.Equal("[ab03 app/ab04]", keys(list), "Second page should resume after the first")
+		assert.Equal("", list.NextToken, "Last page should have no token")
+
+		section.Step(3, "Listings can be restricted to a namespace")
+		page, err := kademlia.ListKeys(node.Storage, "ab", "", true, 10, "")
+		assert.NoError(err, "Listing should succeed")
+		assert.Equal("[ab01 ab02 ab03]", keys(page), "Only the default namespace should be listed")
+
+		section.Step(4, "Invalid prefixes are rejected")
+		_, err = c.ListKeys(ctx, "xyz", 0, "")
+		assert.HasError(err, "Non-hex prefix should be rejected")
+
+		section.Success("Stored keys listed by prefix")
+	})
+
+	t.Run("Keyspace", func(t *testing.T) {
+		section := logger.Section("Keyspace Histogram")
+		ctx := context.Background()
+
+		node := start("admin-secret")
+		defer node.Stop()
+
+		section.Step(1, "Keys and peers are counted in their range")
+		node.Storage.Set("0"+fixtures.GenerateValidHexID("low")[1:], "a")
+		node.Storage.Set("1"+fixtures.GenerateValidHexID("low2")[1:], "b")
+		node.Storage.Set(models.NamespacedKey("app", "f"+fixtures.GenerateValidHexID("high")[1:]), "c")
+		node.Storage.Set("not-an-id", "d")
+		kademlia.AddNodeToRoutingTable(node.RoutingTable, &models.Node{ID: "8" + fixtures.GenerateValidHexID("peer")[1:], IP: "10.0.0.1", Port: 8080}, node.Self.ID)
+
+		c := client.NewClient(node.Addr())
+		c.AdminToken = "admin-secret"
+		h, err := c.Keyspace(ctx, 4)
+		if !assert.NoError(err, "Histogram should be served") {
+			return
+		}
+		assert.Equal(4, len(h.Bins), "Bins should split the keyspace")
+		assert.Equal("4000000000000000000000000000000000000000", h.Bins[1].Start, "Bins should be equal ranges")
+		assert.Equal(3, h.Keys, "Hex keys should be counted by their raw key")
+		assert.Equal(2, h.Bins[0].Keys, "Low keys should fall in the first bin")
+		assert.Equal(1, h.Bins[3].Keys, "Namespaced keys should count by raw key")
+		assert.Equal(1, h.Peers, "Contacts should be counted")
+		assert.Equal(1, h.Bins[2].Peers, "The contact should fall in its range")
+
+		section.Step(2, "Odd bin counts still cover the keyspace")
+		h, _ = c.Keyspace(ctx, 3)
+		assert.Equal(3, h.Bins[0].Keys+h.Bins[1].Keys+h.Bins[2].Keys, "Every key should be counted once")
+		assert.Equal("5555555555555556000000000000000000000000", h.Bins[1].Start, "Starts should round up")
+
+		section.Step(3, "CSV is served for plotting")
+		req, _ := http.NewRequest("GET", "http://"+node.Addr()+"/admin/keyspace?bins=2&format=csv", nil)
+		req.Header.Set(middleware.AdminTokenHeader, "admin-secret")
+		resp, err := http.DefaultClient.Do(req)
+		if assert.NoError(err, "CSV should be served") {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			assert.Equal(3, len(lines), "CSV should have a header and a row per bin")
+			assert.Equal("bin,start,keys,peers,self", lines[0], "CSV should have a header")
+			assert.True(strings.HasPrefix(lines[1], "0,0000000000000000000000000000000000000000,2,"), "Rows should hold the counts, got %s", lines[1])
+		}
+		_, err = c.Keyspace(ctx, 5000)
+		assert.HasError(err, "Too many bins should be refused")
+
+		section.Success("Keyspace histogram exported")
+	})
+
+	t.Run("AdminOnly", func(t *testing.T) {
+		section := logger.Section("Admin Only")
+
+		enabled := start("admin-secret")
+		defer enabled.Stop()
+		disabled := start("")
+		defer disabled.Stop()
+
+		get := func(addr, token string) int {
+			req, _ := http.NewRequest("GET", "http://"+addr+"/admin/export", nil)
+			if token != "" {
+				req.Header.Set(middleware.AdminTokenHeader, token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(err, "Node should answer") {
+				return 0
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		section.Step(1, "Admin endpoints need the admin token")
+		assert.Equal(http.StatusForbidden, get(enabled.Addr(), ""), "Missing token should be rejected")
+		assert.Equal(http.StatusForbidden, get(enabled.Addr(), "wrong"), "Wrong token should be rejected")
+		assert.Equal(http.StatusOK, get(enabled.Addr(), "admin-secret"), "Admin token should be accepted")
+		c := client.NewClient(enabled.Addr())
+		_, err := c.ListKeys(context.Background(), "", 0, "")
+		assert.HasError(err, "Key listing should need the admin token")
+
+		section.Step(2, "Admin endpoints are off without a token")
+		assert.Equal(http.StatusNotFound, get(disabled.Addr(), ""), "Admin endpoints should not be served")
+
+		section.Success("Admin endpoints guarded")
+	})
+}
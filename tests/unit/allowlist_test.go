@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestAllowlist tests private networks admitting only allowlisted peers
+func TestAllowlist(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "ALLOWLIST")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting allowlist tests")
+
+	t.Run("Entries", func(t *testing.T) {
+		section := logger.Section("Allowlist Entries")
+
+		section.Step(1, "IDs and keys are parsed")
+		member := fixtures.CreateTestNode(8081, "member")
+		public, _, _ := ed25519.GenerateKey(rand.Reader)
+		allowlist, err := models.NewAllowlist([]string{strings.ToUpper(member.ID), "key:" + hex.EncodeToString(public)})
+		assert.NoError(err, "Allowlist should parse")
+		assert.Equal(2, allowlist.Len(), "Both entries should be kept")
+		assert.True(allowlist.Allows(member), "Listed ID should be allowed regardless of case")
+		assert.False(allowlist.Allows(fixtures.CreateTestNode(8082, "stranger")), "Unlisted ID should not be allowed")
+
+		section.Step(2, "Malformed entries are refused")
+		_, err = models.NewAllowlist([]string{"not-hex"})
+		assert.HasError(err, "Non-hex ID should be refused")
+		_, err = models.NewAllowlist([]string{"key:abcd"})
+		assert.HasError(err, "Short key should be refused")
+
+		section.Step(3, "No allowlist admits everyone")
+		var none *models.Allowlist
+		assert.True(none.Allows(member), "Nil allowlist should allow every peer")
+
+		section.Success("Allowlist entries parsed correctly")
+	})
+
+	t.Run("RoutingTable", func(t *testing.T) {
+		section := logger.Section("Routing Table Admission")
+
+		node := fixtures.CreateTestNode(8080, "private")
+		table := kademlia.NewRoutingTable(node.ID)
+		member := fixtures.CreateTestNode(8081, "member")
+		signed := fixtures.CreateTestNode(8082, "signed")
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(kademlia.SignNodeRecord(signed, key, nil, nil), "Signing should succeed")
+		table.Allowlist, _ = models.NewAllowlist([]string{member.ID, "key:" + signed.Record.PublicKey})
+
+		section.Step(1, "Only allowlisted contacts are added")
+		stranger := fixtures.CreateTestNode(8083, "stranger")
+		for _, n := range []*models.Node{member, signed, stranger, node} {
+			kademlia.AddNodeToRoutingTable(table, n, node.ID)
+		}
+		assert.Equal(1, len(kademlia.FindClosestNodes(table, member.ID, node.ID, 1)), "Member should be added")
+		contacts := kademlia.FindClosestNodes(table, stranger.ID, node.ID, 0)
+		for _, n := range contacts {
+			assert.False(n.ID == stranger.ID, "Stranger should not be added")
+		}
+		assert.Equal(3, len(contacts), "Member, the key holder and the node itself should be kept")
+
+		section.Step(2, "Pings from strangers are refused")
+		body, _ := json.Marshal(stranger)
+		req := httptest.NewRequest("POST", "/ping", strings.NewReader(string(body)))
+		req.RemoteAddr = "127.0.0.1:12345"
+		rr := httptest.NewRecorder()
+		kademlia.PingHandler(rr, req, node, kademlia.NewKeyValueStore(), table)
+		assert.Equal(http.StatusForbidden, rr.Code, "Stranger's ping should be refused")
+
+		section.Success("Routing table admitting only allowlisted peers")
+	})
+
+	t.Run("Writes", func(t *testing.T) {
+		section := logger.Section("Writes From Peers")
+
+		node := fixtures.CreateTestNode(8080, "private")
+		table := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(table, node, node.ID)
+		member := fixtures.CreateTestNode(8081, "member")
+		_, key1, _ := ed25519.GenerateKey(rand.Reader)
+		signer := fixtures.CreateTestNode(8082, "signer")
+		assert.NoError(kademlia.SignNodeRecord(signer, key1, nil, nil), "Signing should succeed")
+		table.Allowlist, _ = models.NewAllowlist([]string{member.ID, "key:" + signer.Record.PublicKey})
+		kademlia.AddNodeToRoutingTable(table, member, node.ID)
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("private")
+
+		store := func(requester, ip string) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(kademlia.StoreRequest{Key: key, Value: "secret"})
+			req := httptest.NewRequest("POST", "/store", strings.NewReader(string(body)))
+			req.RemoteAddr = ip + ":12345"
+			if requester != "" {
+				req.Header.Set(kademlia.RequesterIDHeader, requester)
+			}
+			rr := httptest.NewRecorder()
+			kademlia.StoreHandler(rr, req, node, storage, table)
+			return rr
+		}
+
+		section.Step(1, "STOREs not naming an allowlisted peer are refused")
+		rr := store("", member.IP)
+		assert.Equal(http.StatusForbidden, rr.Code, "Anonymous STORE should be refused")
+		assert.Contains(rr.Body.String(), "not_allowlisted", "Refusal should be typed")
+		assert.Equal(http.StatusForbidden, store(fixtures.GenerateValidHexID("stranger"), member.IP).Code, "Stranger's STORE should be refused")
+		_, exists := storage.Get(key)
+		assert.False(exists, "Nothing should be stored")
+
+		section.Step(2, "Naming an allowlisted peer from another address is refused")
+		assert.Equal(http.StatusForbidden, store(member.ID, "192.0.2.1").Code, "Spoofed requester should be refused")
+		_, exists = storage.Get(key)
+		assert.False(exists, "Nothing should be stored")
+
+		section.Step(3, "STOREs from confirmed allowlisted peers at their address are stored")
+		assert.Equal(http.StatusForbidden, store(member.ID, member.IP).Code, "Member listed by ID alone should be refused until confirmed")
+		kademlia.PinNode(table, member.ID)
+		assert.Equal(http.StatusCreated, store(member.ID, member.IP).Code, "Pinned member's STORE should succeed")
+		kademlia.AddNodeToRoutingTable(table, signer, node.ID)
+		assert.Equal(http.StatusCreated, store(signer.ID, signer.IP).Code, "STORE from a contact listed by its key should succeed")
+
+		section.Step(4, "Envelope STOREs are checked by their proven sender")
+		envelope := func(sender *models.Node, key1 ed25519.PrivateKey, value string) int {
+			msg := kademlia.NewMessage(models.Store, sender)
+			msg.Key, msg.Value = key, value
+			if key1 != nil {
+				assert.NoError(msg.Sign(key1), "Signing should succeed")
+			}
+			body, _ := json.Marshal(msg)
+			req := httptest.NewRequest("POST", "/rpc", strings.NewReader(string(body)))
+			req.RemoteAddr = "192.0.2.1:12345"
+			rr := httptest.NewRecorder()
+			kademlia.MessageHandler(rr, req, node, storage, kademlia.NewProviderStore(), table)
+			return rr.Code
+		}
+		assert.Equal(http.StatusForbidden, envelope(fixtures.CreateTestNode(9090, "stranger"), nil, "overwritten"), "Stranger's envelope STORE should be refused")
+		spoofed := *member
+		spoofed.Port = 9090
+		assert.Equal(http.StatusForbidden, envelope(&spoofed, nil, "overwritten"), "Unsigned envelope naming a member from another address should be refused")
+		value, _ := storage.Get(key)
+		assert.Equal("secret", value, "Value should be kept")
+		assert.Equal(http.StatusOK, envelope(signer, key1, "signed"), "Envelope signed by a listed key should be stored")
+		value, _ = storage.Get(key)
+		assert.Equal("signed", value, "Signed value should be stored")
+
+		section.Step(5, "Anti-entropy pushes are checked too")
+		push, _ := json.Marshal([]kademlia.KeyRecord{{Key: fixtures.GenerateValidHexID("pushed"), Value: "x"}})
+		rr = httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, httptest.NewRequest("POST", "/sync_push", strings.NewReader(string(push))), node, storage, table)
+		assert.Equal(http.StatusForbidden, rr.Code, "Anonymous push should be refused")
+
+		section.Success("Writes accepted only from allowlisted peers")
+	})
+
+	t.Run("Replication", func(t *testing.T) {
+		section := logger.Section("Replicated Writes")
+
+		self := fixtures.CreateTestNode(8080, "writer")
+		self.Flags = models.FlagRelay
+		table := kademlia.NewRoutingTable(self.ID)
+
+		replica := &models.Node{ID: fixtures.GenerateValidHexID("replica"), IP: "127.0.0.1"}
+		replicaTable := kademlia.NewRoutingTable(replica.ID)
+		kademlia.AddNodeToRoutingTable(replicaTable, replica, replica.ID)
+		replicaTable.Allowlist, _ = models.NewAllowlist([]string{self.ID})
+		kademlia.AddNodeToRoutingTable(replicaTable, self, replica.ID)
+		kademlia.PinNode(replicaTable, self.ID)
+		replicaStorage := kademlia.NewKeyValueStore()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, replica, replicaTable)
+		})
+		mux.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, replica, replicaStorage, replicaTable)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		replica.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		kademlia.AddNodeToRoutingTable(table, replica, self.ID)
+
+		section.Step(1, "Nodes name themselves in the STOREs they replicate")
+		key := fixtures.GenerateValidHexID("replicated")
+		ack := kademlia.IterativeStore(context.Background(), table, self, kademlia.NewKeyValueStore(), kademlia.StoreRequest{Key: key, Value: "shared"})
+		assert.Equal(1, ack.ReplicationFactor, "Allowlisted writer should reach the replica")
+		value, _ := replicaStorage.Get(key)
+		assert.Equal("shared", value, "Replica should store the value")
+
+		section.Success("Replicated writes accepted correctly")
+	})
+}
@@ -0,0 +1,371 @@
+package unit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestAntiEntropy tests digest-based reconciliation between replicas
+func TestAntiEntropy(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "ANTIENTROPY")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting anti-entropy tests")
+
+	localID := "0000000000000000000000000000000000000000"
+	remoteID := "8000000000000000000000000000000000000000"
+	onlyLocal := "00000000000000000000000000000000000000a1"
+	onlyRemote := "00000000000000000000000000000000000000b2"
+	shared := "00000000000000000000000000000000000000c3"
+	conflict := "00000000000000000000000000000000000000d4"
+
+	t.Run("Digest", func(t *testing.T) {
+		section := logger.Section("Digest")
+
+		a := kademlia.NewKeyValueStore()
+		b := kademlia.NewKeyValueStore()
+		a.Set(shared, "same")
+		b.Set(shared, "same")
+		assert.Equal(fmt.Sprint(kademlia.Digest(a, localID, 160)), fmt.Sprint(kademlia.Digest(b, localID, 160)), "Equal stores should have equal digests")
+
+		b.Set(conflict, "other")
+		assert.NotEqual(fmt.Sprint(kademlia.Digest(a, localID, 160)), fmt.Sprint(kademlia.Digest(b, localID, 160)), "Diverged stores should differ")
+		assert.Equal(0, len(kademlia.Digest(b, remoteID, 8)), "Records outside the radius should be skipped")
+
+		section.Success("Digests reflect the records in range")
+	})
+
+	t.Run("SyncWith", func(t *testing.T) {
+		section := logger.Section("Sync With")
+
+		remote := &models.Node{ID: remoteID}
+		remoteStorage := kademlia.NewKeyValueStore()
+		remoteStorage.Set(onlyRemote, "remote")
+		remoteStorage.Set(shared, "same")
+		remoteStorage.Set(conflict, "remote-version")
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/sync_digest", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncDigestHandler(w, r, remote, remoteStorage)
+		})
+		mux.HandleFunc("/sync_push", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncPushHandler(w, r, remote, remoteStorage, kademlia.NewRoutingTable(remoteID))
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		localStorage := kademlia.NewKeyValueStore()
+		localStorage.Set(onlyLocal, "local")
+		localStorage.Set(shared, "same")
+		localStorage.Set(conflict, "local-version")
+
+		ae := kademlia.NewAntiEntropy(localStorage, kademlia.NewRoutingTable(localID), localID)
+		pulled, pushed, err := ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(1, pulled, "Missing remote record should be pulled")
+		assert.Equal(1, pushed, "Missing local record should be pushed")
+
+		section.Step(1, "Both sides hold the union of records")
+		value, _ := localStorage.Get(onlyRemote)
+		assert.Equal("remote", value, "Remote record should be copied locally")
+		value, _ = remoteStorage.Get(onlyLocal)
+		assert.Equal("local", value, "Local record should be copied to the remote")
+
+		section.Step(2, "Conflicting values are left alone")
+		value, _ = localStorage.Get(conflict)
+		assert.Equal("local-version", value, "Local value should not be overwritten")
+		value, _ = remoteStorage.Get(conflict)
+		assert.Equal("remote-version", value, "Remote value should not be overwritten")
+
+		section.Step(3, "A second round finds nothing to copy")
+		pulled, pushed, err = ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(0, pulled+pushed, "Converged replicas should not exchange records")
+
+		section.Success("Replicas reconciled")
+	})
+
+	t.Run("Tombstones", func(t *testing.T) {
+		section := logger.Section("Tombstones")
+
+		section.Step(1, "A straggler replica still holds a deleted value")
+		remote := &models.Node{ID: remoteID}
+		remoteStorage := kademlia.NewKeyValueStore()
+		remoteStorage.Set(shared, "stale")
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/sync_digest", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncDigestHandler(w, r, remote, remoteStorage)
+		})
+		mux.HandleFunc("/sync_push", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncPushHandler(w, r, remote, remoteStorage, kademlia.NewRoutingTable(remoteID))
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		localStorage := kademlia.NewKeyValueStore()
+		localStorage.Set(shared, "stale")
+		assert.True(localStorage.Tombstone(shared, time.Now()), "Delete should record a tombstone")
+		_, exists := localStorage.Get(shared)
+		assert.False(exists, "Deleted value should be gone")
+		assert.Equal(models.ErrDeleted, localStorage.Set(shared, "stale"), "Replica copies should not resurrect the key")
+
+		section.Step(2, "Anti-entropy deletes the straggler's copy")
+		ae := kademlia.NewAntiEntropy(localStorage, kademlia.NewRoutingTable(localID), localID)
+		pulled, pushed, err := ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(0, pulled, "The stale value should not be pulled")
+		assert.Equal(1, pushed, "The tombstone should be pushed")
+		_, exists = localStorage.Get(shared)
+		assert.False(exists, "Local key should stay deleted")
+		_, exists = remoteStorage.Get(shared)
+		assert.False(exists, "Remote value should be deleted")
+		_, deleted := remoteStorage.DeletedAt(shared)
+		assert.True(deleted, "Remote should keep the tombstone")
+
+		pulled, pushed, err = ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(0, pulled+pushed, "Converged tombstones should not be exchanged again")
+
+		section.Step(3, "A new STORE replaces the tombstone")
+		_, err = localStorage.Put(shared, "fresh", "", models.OverwriteAlways, "")
+		assert.NoError(err, "Store after delete should succeed")
+		_, deleted = localStorage.DeletedAt(shared)
+		assert.False(deleted, "Store should clear the tombstone")
+
+		section.Success("Tombstones stop resurrection")
+	})
+
+	t.Run("Publishers", func(t *testing.T) {
+		section := logger.Section("Publishers")
+
+		key, _ := kademlia.GenerateRecordKey()
+		publisher := kademlia.PublisherID(key.Public().(ed25519.PublicKey))
+		remote := &models.Node{ID: remoteID}
+		remoteStorage := kademlia.NewKeyValueStore()
+		remoteStorage.Put(shared, "owned", publisher, models.OverwriteAlways, "")
+		mux := http.NewServeMux()
+		mux.HandleFunc("/sync_digest", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncDigestHandler(w, r, remote, remoteStorage)
+		})
+		mux.HandleFunc("/sync_push", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncPushHandler(w, r, remote, remoteStorage, kademlia.NewRoutingTable(remoteID))
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		local := &models.Node{ID: localID, IP: "127.0.0.1", Port: 8080}
+		localStorage := kademlia.NewKeyValueStore()
+		ae := kademlia.NewAntiEntropy(localStorage, kademlia.NewRoutingTable(localID), localID)
+
+		section.Step(1, "Pulled records keep their publisher")
+		pulled, _, err := ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(1, pulled, "Record should be pulled")
+		meta, _ := localStorage.Meta(shared)
+		assert.Equal(publisher, meta.Publisher, "Publisher should be copied with the record")
+		rr := httptest.NewRecorder()
+		kademlia.DeleteHandler(rr, httptest.NewRequest("POST", "/delete", strings.NewReader(`{"key":"`+shared+`"}`)), local, localStorage, kademlia.NewRoutingTable(localID))
+		assert.Equal(http.StatusConflict, rr.Code, "Unsigned delete of a copied record should be refused")
+
+		section.Step(2, "Unsigned tombstones do not delete published values")
+		now := time.Now()
+		body, _ := json.Marshal([]kademlia.KeyRecord{{Key: shared, DeletedAt: &now}})
+		rr = httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, httptest.NewRequest("POST", "/sync_push", strings.NewReader(string(body))), local, localStorage, kademlia.NewRoutingTable(localID))
+		_, exists := localStorage.Get(shared)
+		assert.True(exists, "Pushed unsigned tombstone should be dropped")
+		forged := kademlia.DeleteRequest{Key: shared}
+		other, _ := kademlia.GenerateRecordKey()
+		kademlia.SignDelete(&forged, other)
+		body, _ = json.Marshal([]kademlia.KeyRecord{{Key: shared, DeletedAt: forged.DeletedAt, Publisher: publisher, PublicKey: forged.PublicKey, Signature: forged.Signature}})
+		rr = httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, httptest.NewRequest("POST", "/sync_push", strings.NewReader(string(body))), local, localStorage, kademlia.NewRoutingTable(localID))
+		_, exists = localStorage.Get(shared)
+		assert.True(exists, "Tombstone signed by another key should be dropped")
+
+		section.Step(3, "The publisher's signed delete reaches replicas through anti-entropy")
+		signed := kademlia.DeleteRequest{Key: shared}
+		kademlia.SignDelete(&signed, key)
+		body, _ = json.Marshal(signed)
+		rr = httptest.NewRecorder()
+		kademlia.DeleteHandler(rr, httptest.NewRequest("POST", "/delete", strings.NewReader(string(body))), remote, remoteStorage, kademlia.NewRoutingTable(remoteID))
+		assert.Equal(http.StatusOK, rr.Code, "Signed delete should succeed")
+		pulled, _, err = ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(1, pulled, "Signed tombstone should be pulled")
+		_, exists = localStorage.Get(shared)
+		assert.False(exists, "Value should be deleted")
+		proof, signedProof := localStorage.DeleteProofOf(shared)
+		assert.True(signedProof && proof.Publisher == publisher, "Signature should be kept with the tombstone")
+
+		section.Success("Provenance survives anti-entropy")
+	})
+
+	t.Run("PushValidation", func(t *testing.T) {
+		section := logger.Section("Push Validation")
+
+		node := &models.Node{ID: localID, IP: "127.0.0.1", Port: 8080}
+		storage := kademlia.NewKeyValueStore()
+		body := `[{"key":"not-hex","value":"x"},{"key":"` + onlyLocal + `","value":"y"}]`
+		req := httptest.NewRequest("POST", "/sync_push", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, req, node, storage, kademlia.NewRoutingTable(node.ID))
+		assert.Equal(http.StatusOK, rr.Code, "Push should succeed")
+		assert.Equal(1, len(storage.GetAll()), "Only valid keys should be stored")
+
+		req = httptest.NewRequest("GET", "/sync_push", nil)
+		rr = httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, req, node, storage, kademlia.NewRoutingTable(node.ID))
+		assert.Equal(http.StatusMethodNotAllowed, rr.Code, "GET should be rejected")
+
+		section.Success("Push validates records")
+	})
+
+	t.Run("PushAdmission", func(t *testing.T) {
+		section := logger.Section("Push Admission")
+
+		node := &models.Node{ID: localID, IP: "127.0.0.1", Port: 8080}
+		table := kademlia.NewRoutingTableWithK(node.ID, 2)
+		kademlia.AddNodeToRoutingTable(table, node, node.ID)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000001"}, node.ID)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000003"}, node.ID)
+		storage := kademlia.NewKeyValueStore()
+		storage.RequireWriteToken = true
+		storage.RejectDistant = true
+		storage.SetNamespacePolicy("app", models.NamespacePolicy{Quota: 1, Token: "secret"})
+		push := func(header http.Header, records ...kademlia.KeyRecord) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(records)
+			req := httptest.NewRequest("POST", "/sync_push", strings.NewReader(string(body)))
+			for name, values := range header {
+				req.Header[name] = values
+			}
+			rr := httptest.NewRecorder()
+			kademlia.SyncPushHandler(rr, req, node, storage, table)
+			return rr
+		}
+		// httptest requests come from 192.0.2.1
+		token := kademlia.IssueWriteToken(node.ID, "192.0.2.1")
+		authorized := http.Header{
+			kademlia.NamespaceHeader:      {"app"},
+			kademlia.NamespaceTokenHeader: {"secret"},
+			kademlia.WriteTokenHeader:     {token},
+		}
+		near := "0000000000000000000000000000000000000002"
+		nearer := "0000000000000000000000000000000000000000"
+		far := "8000000000000000000000000000000000000000"
+
+		section.Step(1, "Pushes need a write token and the namespace token")
+		header := authorized.Clone()
+		header.Del(kademlia.WriteTokenHeader)
+		rr := push(header, kademlia.KeyRecord{Key: models.NamespacedKey("app", near), Value: "x"})
+		assert.Equal(http.StatusForbidden, rr.Code, "Push without a write token should be refused")
+		assert.Contains(rr.Body.String(), "invalid_write_token", "Rejection should be typed")
+		header = authorized.Clone()
+		header.Del(kademlia.NamespaceTokenHeader)
+		assert.Equal(http.StatusUnauthorized, push(header, kademlia.KeyRecord{Key: models.NamespacedKey("app", near), Value: "x"}).Code, "Push without the namespace token should be refused")
+		assert.Equal(0, len(storage.GetAll()), "Refused pushes should store nothing")
+
+		section.Step(2, "Records outside the namespace, the radius or the quota are skipped")
+		var reply kademlia.SyncPushReply
+		rr = push(authorized,
+			kademlia.KeyRecord{Key: near, Value: "other namespace"},
+			kademlia.KeyRecord{Key: models.NamespacedKey("app", far), Value: "distant"},
+			kademlia.KeyRecord{Key: models.NamespacedKey("app", near), Value: "kept"},
+			kademlia.KeyRecord{Key: models.NamespacedKey("app", nearer), Value: "over quota"},
+		)
+		assert.Equal(http.StatusOK, rr.Code, "Authorized push should succeed")
+		json.NewDecoder(rr.Body).Decode(&reply)
+		assert.Equal(1, reply.Stored, "Only the admissible record should be stored")
+		value, _ := storage.Get(models.NamespacedKey("app", near))
+		assert.Equal("kept", value, "Admissible record should be stored")
+		assert.Equal(1, storage.NamespaceUsage("app"), "Quota should hold")
+		assert.Equal(int64(1), storage.Placement.Snapshot().OutsideResponsibility, "Distant record should count as refused")
+
+		section.Step(3, "Pushed tombstones are clamped to the present")
+		future := time.Now().Add(100 * 365 * 24 * time.Hour)
+		push(authorized, kademlia.KeyRecord{Key: models.NamespacedKey("app", near), DeletedAt: &future})
+		at, deleted := storage.DeletedAt(models.NamespacedKey("app", near))
+		assert.True(deleted, "Tombstone should be recorded")
+		assert.True(at.Before(future), "Far-future tombstone should be clamped")
+
+		section.Success("Pushes admitted like STOREs")
+	})
+
+	t.Run("PullAdmission", func(t *testing.T) {
+		section := logger.Section("Pull Admission")
+
+		near := "0000000000000000000000000000000000000002"
+		nearer := "0000000000000000000000000000000000000000"
+		next := "0000000000000000000000000000000000000001"
+		far := "8000000000000000000000000000000000000000"
+		remote := &models.Node{ID: remoteID}
+		remoteStorage := kademlia.NewKeyValueStore()
+		remoteStorage.Set(near, "kept")
+		remoteStorage.Set(nearer, strings.Repeat("x", kademlia.MaxValueSize+1))
+		remoteStorage.Set(far, "distant")
+		remoteStorage.Set(models.NamespacedKey("app", near), "private")
+		mux := http.NewServeMux()
+		mux.HandleFunc("/sync_digest", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SyncDigestHandler(w, r, remote, remoteStorage)
+		})
+		mux.HandleFunc("/sync_push", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"stored":0}`))
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		table := kademlia.NewRoutingTableWithK(localID, 2)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: localID}, localID)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000001"}, localID)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000003"}, localID)
+		storage := kademlia.NewKeyValueStore()
+		storage.RejectDistant = true
+		storage.SetNamespacePolicy("", models.NamespacePolicy{Quota: 1})
+		storage.SetNamespacePolicy("app", models.NamespacePolicy{Token: "secret"})
+		ae := kademlia.NewAntiEntropy(storage, table, localID)
+
+		section.Step(1, "Pulled records outside the radius, oversized or of token namespaces are skipped")
+		pulled, _, err := ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(1, pulled, "Only the admissible record should be pulled")
+		value, _ := storage.Get(near)
+		assert.Equal("kept", value, "Admissible record should be stored")
+		_, exists := storage.Get(models.NamespacedKey("app", near))
+		assert.False(exists, "Token namespace records should wait for a push")
+		assert.Equal(int64(1), storage.Placement.Snapshot().OutsideResponsibility, "Distant record should count as refused")
+
+		section.Step(2, "Pulled records are held to the namespace quota")
+		remoteStorage.Set(next, "over quota")
+		pulled, _, err = ae.SyncWith(context.Background(), remote)
+		assert.NoError(err, "Sync should succeed")
+		assert.Equal(0, pulled, "Record beyond the quota should not be pulled")
+		assert.Equal(1, storage.NamespaceUsage(""), "Quota should hold")
+
+		section.Success("Pulls admitted like pushes")
+	})
+}
@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/bench"
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// memoryClient is a bench.Client backed by a map
+type memoryClient struct {
+	mu     sync.Mutex
+	values map[string]string
+	puts   int
+	gets   int
+}
+
+func (c *memoryClient) Put(ctx context.Context, key, value string) (kademlia.StoreAck, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	c.puts++
+	return kademlia.StoreAck{Key: key}, nil
+}
+
+func (c *memoryClient) Get(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	value, ok := c.values[key]
+	return value, ok, nil
+}
+
+// TestBench tests the load generator behind kademlia bench
+func TestBench(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "BENCH")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting bench tests")
+
+	t.Run("Workload", func(t *testing.T) {
+		section := logger.Section("Workload")
+
+		section.Step(1, "Run a fixed number of operations")
+		client := &memoryClient{values: make(map[string]string)}
+		w := bench.DefaultWorkload()
+		w.Keys = 10
+		w.ValueSize = 33
+		w.Ops = 200
+		w.Concurrency = 4
+		w.ReadRatio = 0.5
+		report, err := bench.Run(context.Background(), client, w)
+		assert.NoError(err, "Benchmark should run")
+		assert.Equal(10, report.Load.Count, "Every key should be stored once first")
+		assert.Equal(200, report.Ops, "The timed run should do the requested operations")
+		assert.Equal(report.Ops, report.Put.Count+report.Get.Count, "Operations should be puts or gets")
+		assert.Equal(10+report.Put.Count, client.puts, "Every put should reach the client")
+		assert.Equal(0, report.Get.Misses, "Loaded keys should be found")
+		assert.True(report.Put.Count > 0 && report.Get.Count > 0, "A 50% read ratio should mix puts and gets")
+		assert.True(report.Get.P50 <= report.Get.P99 && report.Get.P99 <= report.Get.Max, "Percentiles should be ordered")
+		for _, value := range client.values {
+			assert.Equal(33, len(value), "Values should have the requested size")
+		}
+
+		section.Step(2, "Reads only")
+		w.ReadRatio = 1
+		report, _ = bench.Run(context.Background(), client, w)
+		assert.Equal(0, report.Put.Count, "A read ratio of 1 should only get")
+
+		section.Step(3, "Report as text")
+		var out bytes.Buffer
+		assert.NoError(report.WriteText(&out), "Report should be written")
+		assert.True(strings.Contains(out.String(), "ops/s"), "Report should show throughput")
+
+		section.Step(4, "Invalid workloads are rejected")
+		w.ReadRatio = 1.5
+		_, err = bench.Run(context.Background(), client, w)
+		assert.HasError(err, "A read ratio above 1 should be rejected")
+
+		section.Success("Workloads run and report their latencies")
+	})
+
+	t.Run("Percentile", func(t *testing.T) {
+		section := logger.Section("Percentile")
+
+		section.Step(1, "Nearest rank over 100 samples")
+		var sorted []time.Duration
+		for i := 1; i <= 100; i++ {
+			sorted = append(sorted, time.Duration(i)*time.Millisecond)
+		}
+		assert.Equal(50*time.Millisecond, bench.Percentile(sorted, 0.5), "p50 should be the 50th sample")
+		assert.Equal(99*time.Millisecond, bench.Percentile(sorted, 0.99), "p99 should be the 99th sample")
+		assert.Equal(100*time.Millisecond, bench.Percentile(sorted, 1), "p100 should be the maximum")
+		assert.Equal(time.Duration(0), bench.Percentile(nil, 0.5), "No samples should give 0")
+
+		section.Success("Percentiles use the nearest rank")
+	})
+}
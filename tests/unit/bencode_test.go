@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/bencode"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestBencodeWireFormat tests the bencode codec and per-peer negotiation
+func TestBencodeWireFormat(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "BENCODE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting bencode wire format tests")
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		section := logger.Section("Round Trip")
+
+		section.Step(1, "Encode a dictionary with sorted keys")
+		data, err := bencode.Marshal(map[string]interface{}{"b": 1, "a": "spam", "c": []string{"x"}})
+		assert.NoError(err, "Marshal should succeed")
+		assert.Equal("d1:a4:spam1:bi1e1:cl1:xee", string(data), "Encoding should follow BEP3")
+
+		section.Step(2, "Round-trip routing responses")
+		nodes := fixtures.CreateTestNodes(3, 9000)
+		data, err = bencode.Marshal(nodes)
+		assert.NoError(err, "Marshal should succeed")
+
+		var decoded []*models.Node
+		assert.NoError(bencode.Unmarshal(data, &decoded), "Unmarshal should succeed")
+		assert.Equal(len(nodes), len(decoded), "All nodes should be decoded")
+		assert.Equal(nodes[1].ID, decoded[1].ID, "IDs should survive")
+		assert.Equal(nodes[1].Port, decoded[1].Port, "Ports should survive")
+
+		var value string
+		assert.HasError(bencode.Unmarshal(data, &value), "A list is not a string")
+		assert.HasError(bencode.Unmarshal([]byte("5:ab"), &value), "Truncated input should fail")
+
+		section.Success("Bencode round-trips")
+	})
+
+	t.Run("Negotiation", func(t *testing.T) {
+		section := logger.Section("Negotiation")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := fixtures.CreatePopulatedRoutingTable(node.ID, 10)
+
+		section.Step(1, "Peers asking for bencode get bencode")
+		req := httptest.NewRequest("GET", "/find_node?id="+node.ID, nil)
+		req.Header.Set("Accept", kademlia.ContentTypeBencode+", application/json;q=0.5")
+		rr := httptest.NewRecorder()
+		kademlia.FindNodeHandler(rr, req, node, routingTable)
+		assert.Equal(kademlia.ContentTypeBencode, rr.Header().Get("Content-Type"), "Reply should be bencoded")
+		assert.Equal(byte('l'), rr.Body.Bytes()[0], "Body should be a bencoded list")
+
+		section.Step(2, "Other peers get JSON")
+		req = httptest.NewRequest("GET", "/find_node?id="+node.ID, nil)
+		rr = httptest.NewRecorder()
+		kademlia.FindNodeHandler(rr, req, node, routingTable)
+		assert.Equal(kademlia.ContentTypeJSON, rr.Header().Get("Content-Type"), "Reply should be JSON")
+
+		section.Step(3, "Outbound RPCs decode either format")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, node, routingTable)
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		peer := &models.Node{ID: node.ID, IP: "127.0.0.1", Port: port}
+
+		nodes, err := kademlia.SendFindNode(kademlia.WithWireFormat(context.Background(), "bencode"), peer, node.ID)
+		assert.NoError(err, "Bencoded reply should decode")
+		assert.True(len(nodes) > 0, "Nodes should be returned")
+
+		section.Success("Wire format negotiated per peer")
+	})
+}
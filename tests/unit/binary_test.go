@@ -0,0 +1,168 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/pkg/storage"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// binaryValue is not valid UTF-8, so JSON cannot carry it as is
+const binaryValue = "\x00\xff\xfe binary \x80"
+
+// TestBinaryValues tests that values which are not valid UTF-8 survive
+// every transport
+func TestBinaryValues(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "BINARY")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting binary value tests")
+
+	t.Run("Encoding", func(t *testing.T) {
+		section := logger.Section("Value Encoding")
+
+		section.Step(1, "Text values are sent unchanged")
+		value, encoding := models.EncodeValue("héllo")
+		assert.Equal("héllo", value, "Text should not be encoded")
+		assert.Equal("", encoding, "Text should carry no encoding")
+
+		section.Step(2, "Binary values roundtrip through base64")
+		value, encoding = models.EncodeValue(binaryValue)
+		assert.Equal(models.ValueEncodingBase64, encoding, "Binary should be base64")
+		decoded, err := models.DecodeValue(value, encoding)
+		assert.NoError(err, "Base64 should decode")
+		assert.Equal(binaryValue, decoded, "Bytes should survive")
+
+		section.Step(3, "Bad encodings are refused")
+		_, err = models.DecodeValue("!!", models.ValueEncodingBase64)
+		assert.HasError(err, "Invalid base64 should be refused")
+		_, err = models.DecodeValue("x", "hex")
+		assert.HasError(err, "Unknown encodings should be refused")
+
+		section.Success("Value encoding working correctly")
+	})
+
+	t.Run("StoreAndFindValue", func(t *testing.T) {
+		section := logger.Section("Store and Find Binary Value")
+
+		node := fixtures.CreateTestNode(8080, "test")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kvStore := kademlia.NewKeyValueStore()
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		key := fixtures.GenerateValidHexID("binary")
+
+		section.Step(1, "A base64 STORE is stored as bytes")
+		value, encoding := models.EncodeValue(binaryValue)
+		body, _ := json.Marshal(kademlia.StoreRequest{Key: key, Value: value, Encoding: encoding})
+		req, _ := http.NewRequest("POST", "/store", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		kademlia.StoreHandler(rr, req, node, kvStore, routingTable)
+		assert.Equal(http.StatusCreated, rr.Code, "Store should succeed: %s", rr.Body.String())
+		stored, _ := kvStore.Get(key)
+		assert.Equal(binaryValue, stored, "Decoded bytes should be stored")
+
+		section.Step(2, "An invalid base64 STORE is refused")
+		body, _ = json.Marshal(kademlia.StoreRequest{Key: key, Value: "!!", Encoding: models.ValueEncodingBase64})
+		req, _ = http.NewRequest("POST", "/store", bytes.NewReader(body))
+		rr = httptest.NewRecorder()
+		kademlia.StoreHandler(rr, req, node, kvStore, routingTable)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Invalid base64 should be refused")
+
+		section.Step(3, "JSON replies carry the value as base64")
+		req, _ = http.NewRequest("GET", "/find_value?key="+key, nil)
+		rr = httptest.NewRecorder()
+		kademlia.FindValueHandler(rr, req, node, kvStore, routingTable)
+		var reply kademlia.FindValueReply
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &reply), "Reply should be a JSON object")
+		assert.Equal(models.ValueEncodingBase64, reply.Encoding, "Reply should name its encoding")
+		decoded, err := reply.Decoded()
+		assert.NoError(err, "Reply value should decode")
+		assert.Equal(binaryValue, decoded, "Reply should hold the bytes")
+
+		section.Step(4, "Octet-stream replies carry the raw bytes")
+		req, _ = http.NewRequest("GET", "/find_value?key="+key, nil)
+		req.Header.Set("Accept", kademlia.ContentTypeOctetStream)
+		rr = httptest.NewRecorder()
+		kademlia.FindValueHandler(rr, req, node, kvStore, routingTable)
+		assert.Equal(kademlia.ContentTypeOctetStream, rr.Header().Get("Content-Type"), "Reply should be raw")
+		assert.Equal(binaryValue, rr.Body.String(), "Body should be the bytes")
+
+		section.Success("Binary values stored and found")
+	})
+
+	t.Run("SendFindValue", func(t *testing.T) {
+		section := logger.Section("FIND_VALUE RPC")
+
+		peer := startFilterPeer(fixtures.GenerateValidHexID("peer"))
+		defer peer.server.Close()
+		key := fixtures.GenerateValidHexID("rpc")
+		peer.storage.Set(key, binaryValue)
+
+		section.Step(1, "The RPC returns the raw bytes")
+		value, _, found, err := kademlia.SendFindValue(context.Background(), peer.node, key)
+		assert.NoError(err, "RPC should succeed")
+		assert.True(found, "Value should be found")
+		assert.Equal(binaryValue, value, "Bytes should survive the RPC")
+
+		section.Success("FIND_VALUE RPC binary-safe")
+	})
+
+	t.Run("Records", func(t *testing.T) {
+		section := logger.Section("Record JSON")
+
+		section.Step(1, "Iteration records roundtrip")
+		data, err := json.Marshal(kademlia.KeyRecord{Key: "k", Value: binaryValue})
+		assert.NoError(err, "Record should marshal")
+		assert.Contains(string(data), `"encoding":"base64"`, "Record should name its encoding")
+		var record kademlia.KeyRecord
+		assert.NoError(json.Unmarshal(data, &record), "Record should unmarshal")
+		assert.Equal(binaryValue, record.Value, "Record value should survive")
+
+		section.Step(2, "Export records roundtrip")
+		data, err = json.Marshal(models.StoredRecord{Key: "k", Value: binaryValue, Publisher: "p"})
+		assert.NoError(err, "Stored record should marshal")
+		var stored models.StoredRecord
+		assert.NoError(json.Unmarshal(data, &stored), "Stored record should unmarshal")
+		assert.Equal(binaryValue, stored.Value, "Stored value should survive")
+		assert.Equal("p", stored.Publisher, "Other fields should survive")
+
+		section.Step(3, "Text records carry no encoding")
+		data, _ = json.Marshal(kademlia.KeyRecord{Key: "k", Value: "text"})
+		assert.Equal(`{"key":"k","value":"text"}`, string(data), "Text record should be unchanged")
+
+		section.Success("Record JSON binary-safe")
+	})
+
+	t.Run("FileBackend", func(t *testing.T) {
+		section := logger.Section("File Backend")
+		path := filepath.Join(t.TempDir(), "binary")
+
+		section.Step(1, "Binary values survive a reopen")
+		fs, err := storage.OpenFile(path)
+		assert.NoError(err, "File backend should open")
+		fs.Set("bin", binaryValue)
+		fs.Set("text", "plain")
+		fs.Close()
+
+		fs, err = storage.OpenFile(path)
+		assert.NoError(err, "File backend should reopen")
+		defer fs.Close()
+		value, found, _ := fs.Get("bin")
+		assert.True(found, "Binary value should be loaded")
+		assert.Equal(binaryValue, value, "Binary value should be intact")
+		value, _, _ = fs.Get("text")
+		assert.Equal("plain", value, "Text value should be intact")
+
+		section.Success("File backend binary-safe")
+	})
+}
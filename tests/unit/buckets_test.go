@@ -0,0 +1,63 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestBucketLastUpdated tests per-bucket LastUpdated tracking
+func TestBucketLastUpdated(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "BUCKETS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting bucket tracking tests")
+
+	t.Run("UpdatedOnContactAndLookup", func(t *testing.T) {
+		section := logger.Section("Updated On Contact And Lookup")
+
+		start := time.Unix(1700000000, 0)
+		fake := clock.NewFake(start)
+		defer clock.Set(clock.Set(fake))
+
+		localID := "0000000000000000000000000000000000000000"
+		rt := kademlia.NewRoutingTable(localID)
+		assert.Equal(0, len(kademlia.BucketStats(rt)), "A new table should report no buckets")
+
+		section.Step(1, "Adding a contact updates its bucket")
+		node := fixtures.CreateTestNode(0, "bucket")
+		node.ID = "8000000000000000000000000000000000000000"
+		kademlia.AddNodeToRoutingTable(rt, node, localID)
+		assert.Equal(start, rt.Buckets[159].LastUpdated, "Bucket 159 should be stamped")
+
+		section.Step(2, "A lookup updates the bucket of its target")
+		fake.Advance(time.Minute)
+		kademlia.IterativeFindNode(context.Background(), rt, localID, "0000000000000000000000000000000000000003")
+		assert.Equal(start.Add(time.Minute), rt.Buckets[1].LastUpdated, "Bucket 1 should be stamped by the lookup")
+		assert.Equal(start, rt.Buckets[159].LastUpdated, "Other buckets should keep their time")
+
+		section.Step(3, "The admin API reports them")
+		fake.Advance(time.Minute)
+		rr := httptest.NewRecorder()
+		kademlia.BucketsHandler(rr, httptest.NewRequest("GET", "/buckets", nil), rt)
+		var stats []kademlia.BucketInfo
+		assert.NoError(json.NewDecoder(rr.Body).Decode(&stats), "Response should decode")
+		assert.Equal(2, len(stats), "Used buckets should be listed")
+		if len(stats) == 2 {
+			assert.Equal(1, stats[0].Index, "Buckets should be in index order")
+			assert.Equal(0, stats[0].Contacts, "Bucket 1 holds no contacts")
+			assert.Equal(159, stats[1].Index, "Bucket 159 should be listed")
+			assert.Equal(1, stats[1].Contacts, "Bucket 159 holds the contact")
+			assert.Equal(120.0, stats[1].IdleSeconds, "Idle time should follow the clock")
+		}
+
+		section.Success("Buckets track their last update")
+	})
+}
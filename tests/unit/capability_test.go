@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestCapabilityFlags tests advertising node capabilities and filtering
+// lookups by them
+func TestCapabilityFlags(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CAPABILITIES")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting capability flag tests")
+
+	t.Run("Supports", func(t *testing.T) {
+		section := logger.Section("Supports")
+
+		legacy := &models.Node{}
+		assert.True(legacy.Supports(models.FlagStorage), "Nodes without flags should be assumed full nodes")
+		assert.False(legacy.Supports(models.FlagRelay), "Nodes without flags should not be assumed relays")
+
+		client := &models.Node{Flags: models.FlagPubSub}
+		assert.False(client.Supports(models.FlagStorage), "Missing flag should not be supported")
+		assert.True(client.Supports(models.FlagPubSub), "Advertised flag should be supported")
+		assert.False(client.Supports(models.FlagPubSub|models.FlagProviders), "All required flags should be needed")
+		assert.True(client.Supports(0), "No requirement should always be met")
+
+		section.Success("Flags checked correctly")
+	})
+
+	t.Run("LookupFiltersByCapability", func(t *testing.T) {
+		section := logger.Section("Lookup Filters By Capability")
+
+		// Contacts on a closed port fail fast and stay in the shortlist
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(err, "Should find a free port")
+		port := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+
+		localID := fixtures.GenerateValidHexID("local")
+		routingTable := kademlia.NewRoutingTable(localID)
+		storer := &models.Node{ID: fixtures.GenerateValidHexID("storer"), IP: "127.0.0.1", Port: port, Flags: models.DefaultFlags}
+		client := &models.Node{ID: fixtures.GenerateValidHexID("client"), IP: "127.0.0.1", Port: port, Flags: models.FlagPubSub}
+		kademlia.AddNodeToRoutingTable(routingTable, storer, localID)
+		kademlia.AddNodeToRoutingTable(routingTable, client, localID)
+
+		all := kademlia.IterativeFindNodeWithOptions(context.Background(), routingTable, localID, localID, kademlia.LookupOptions{})
+		assert.Equal(2, len(all), "Unfiltered lookup should return both contacts")
+
+		storers := kademlia.IterativeFindNodeWithOptions(context.Background(), routingTable, localID, localID, kademlia.LookupOptions{Require: models.FlagStorage})
+		if assert.Equal(1, len(storers), "Filtered lookup should skip the client") {
+			assert.Equal(storer.ID, storers[0].ID, "Storage node should be returned")
+		}
+
+		section.Success("Lookups return only capable contacts")
+	})
+
+	t.Run("PropagatedInRPCs", func(t *testing.T) {
+		section := logger.Section("Propagated In RPCs")
+
+		section.Step(1, "PING body flags are stored and refreshed")
+		remote := fixtures.CreateTestNode(8080, "remote")
+		remoteTable := kademlia.NewRoutingTable(remote.ID)
+		pinger := models.Node{ID: fixtures.GenerateValidHexID("pinger"), IP: "127.0.0.1", Port: 9090}
+		ping := func(flags models.CapabilityFlags) {
+			pinger.Flags, pinger.Protocol = flags, models.ProtocolVersion
+			body, _ := json.Marshal(pinger)
+			rr := httptest.NewRecorder()
+			kademlia.PingHandler(rr, httptest.NewRequest("POST", "/ping", bytes.NewReader(body)), remote, kademlia.NewKeyValueStore(), remoteTable)
+			assert.Equal(http.StatusOK, rr.Code, "Ping should succeed")
+		}
+		ping(models.FlagPubSub)
+		ping(models.DefaultFlags)
+		closest := kademlia.FindClosestNodes(remoteTable, pinger.ID, remote.ID, 0)
+		assert.Equal(models.DefaultFlags, closest[0].Flags, "Latest advertised flags should be kept")
+		assert.Equal(models.ProtocolVersion, closest[0].Protocol, "Protocol version should be kept")
+
+		section.Step(2, "FIND_NODE responders advertise their flags")
+		responder := fixtures.CreateTestNode(8080, "responder")
+		responder.Flags, responder.Protocol = models.FlagStorage|models.FlagRelay, models.ProtocolVersion
+		routingTable := fixtures.CreatePopulatedRoutingTable(responder.ID, 3)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, responder, routingTable)
+		}))
+		defer server.Close()
+
+		addr := strings.TrimPrefix(server.URL, "http://")
+		port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		peer := &models.Node{ID: responder.ID, IP: "127.0.0.1", Port: port}
+		_, err := kademlia.SendFindNode(context.Background(), peer, responder.ID)
+		assert.NoError(err, "FIND_NODE should succeed")
+		assert.True(peer.Supports(models.FlagRelay), "Responder flags should be learned")
+		assert.Equal(models.ProtocolVersion, peer.Protocol, "Responder protocol should be learned")
+
+		section.Success("Flags travel with PING and FIND_NODE")
+	})
+}
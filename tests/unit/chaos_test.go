@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/chaos"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestChaosMiddleware tests fault injection into RPC handling
+func TestChaosMiddleware(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CHAOS")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting chaos middleware tests")
+
+	const reply = `{"message":"pong","node_id":"0000000000000000000000000000000000000000"}`
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(reply))
+	})
+	get := func(h http.Handler) (string, error) {
+		server := httptest.NewServer(h)
+		defer server.Close()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	t.Run("DisabledIsPassThrough", func(t *testing.T) {
+		section := logger.Section("Disabled Is Pass Through")
+
+		cfg := config.ChaosConfig{DropRate: 1, CorruptRate: 1}
+		body, err := get(chaos.Middleware(cfg, handler))
+		assert.NoError(err, "Request should succeed")
+		assert.Equal(reply, body, "Faults should not be injected unless enabled")
+
+		section.Success("Chaos is opt-in")
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		section := logger.Section("Latency")
+
+		cfg := config.ChaosConfig{Enabled: true, Latency: 50 * time.Millisecond}
+		start := time.Now()
+		body, err := get(chaos.Middleware(cfg, handler))
+		assert.NoError(err, "Request should succeed")
+		assert.Equal(reply, body, "Reply should be intact")
+		assert.True(time.Since(start) >= 50*time.Millisecond, "Reply should be delayed")
+
+		section.Success("Latency injected")
+	})
+
+	t.Run("DropAndCorrupt", func(t *testing.T) {
+		section := logger.Section("Drop And Corrupt")
+
+		section.Step(1, "Dropped replies surface as transport errors")
+		_, err := get(chaos.NewInjector(config.ChaosConfig{Enabled: true, DropRate: 1}, 1).Wrap(handler))
+		assert.HasError(err, "Dropped reply should fail the request")
+
+		section.Step(2, "Corrupted replies keep their length but not their content")
+		body, err := get(chaos.NewInjector(config.ChaosConfig{Enabled: true, CorruptRate: 1}, 1).Wrap(handler))
+		assert.NoError(err, "Corrupted reply should still be delivered")
+		assert.Equal(len(reply), len(body), "Body length should be preserved")
+		assert.True(body != reply, "Body should be garbled")
+
+		section.Success("Faults injected")
+	})
+}
@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestClock tests the fake clock and the code that reads time through it
+func TestClock(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CLOCK")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting virtual clock tests")
+
+	start := time.Unix(1700000000, 0)
+
+	t.Run("FakeClock", func(t *testing.T) {
+		section := logger.Section("Fake Clock")
+
+		fake := clock.NewFake(start)
+		after := fake.After(time.Minute)
+		ticker := fake.NewTicker(10 * time.Second)
+		assert.Equal(2, fake.Waiters(), "Timer and ticker should be pending")
+
+		section.Step(1, "Nothing fires before its deadline")
+		fake.Advance(9 * time.Second)
+		select {
+		case <-ticker.Chan():
+			assert.True(false, "Ticker should not fire early")
+		case <-after:
+			assert.True(false, "Timer should not fire early")
+		default:
+		}
+
+		section.Step(2, "Ticks fire at their deadlines and unread ticks are dropped")
+		fake.Advance(21 * time.Second)
+		assert.True(start.Add(10*time.Second).Equal(<-ticker.Chan()), "First tick should carry its deadline")
+		select {
+		case <-ticker.Chan():
+			assert.True(false, "Missed ticks should be dropped")
+		default:
+		}
+
+		section.Step(3, "Timers fire once")
+		fake.Advance(time.Minute)
+		assert.True(start.Add(time.Minute).Equal(<-after), "Timer should carry its deadline")
+		assert.True(start.Add(90*time.Second).Equal(fake.Now()), "Clock should read the advanced time")
+		assert.Equal(1, fake.Waiters(), "Fired timer should be removed")
+
+		ticker.Stop()
+		assert.Equal(0, fake.Waiters(), "Stopped ticker should be removed")
+
+		section.Success("Fake clock fires deterministically")
+	})
+
+	t.Run("GarbageCollectorTimer", func(t *testing.T) {
+		section := logger.Section("Garbage Collector Timer")
+
+		fake := clock.NewFake(start)
+		defer clock.Set(clock.Set(fake))
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("expiring")
+		storage.Set(key, "value")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		evicted := make(chan string, 1)
+		gc := kademlia.NewGarbageCollector(storage, fixtures.GenerateValidHexID("local"), kademlia.GCConfig{
+			TTL:      time.Hour,
+			Interval: time.Minute,
+			OnEvict:  func(k, v, reason string) { evicted <- k },
+		})
+		scheduler := kademlia.NewScheduler(kademlia.SchedulerConfig{})
+		scheduler.Add(kademlia.Job{Name: "expire", Interval: time.Minute, Run: func(context.Context) time.Duration {
+			gc.Collect()
+			return 0
+		}})
+		go scheduler.Start(ctx)
+		for fake.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		section.Step(1, "An hour of fake time expires the entry on the next collection")
+		fake.Advance(time.Hour + time.Minute)
+		select {
+		case k := <-evicted:
+			assert.Equal(key, k, "Expired entry should be evicted")
+		case <-time.After(time.Second):
+			assert.True(false, "Collection should run when the fake clock ticks")
+		}
+
+		section.Success("Expiry driven by the fake clock")
+	})
+
+	t.Run("LastSeen", func(t *testing.T) {
+		section := logger.Section("Last Seen")
+
+		fake := clock.NewFake(start)
+		defer clock.Set(clock.Set(fake))
+
+		remote := fixtures.CreateTestNode(0, "remote")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, remote, kademlia.NewRoutingTable(remote.ID))
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		fake.Advance(time.Hour)
+		_, err := kademlia.SendFindNode(context.Background(), remote, remote.ID)
+		assert.NoError(err, "FIND_NODE should succeed")
+		assert.Equal(start.Add(time.Hour).Unix(), remote.LastSeen, "LastSeen should come from the fake clock")
+
+		section.Success("LastSeen stamped by the fake clock")
+	})
+
+	t.Run("ClockSkew", func(t *testing.T) {
+		section := logger.Section("Clock Skew")
+
+		fake := clock.NewFake(start)
+		defer clock.Set(clock.Set(fake))
+
+		section.Step(1, "Peer times ahead of ours are clamped")
+		seen, ok := clock.FromPeer(start.Add(time.Minute), start)
+		assert.True(ok && seen.Equal(start), "Small skew should be tolerated and clamped")
+		seen, ok = clock.FromPeer(start.Add(time.Hour), start)
+		assert.True(!ok && seen.Equal(start), "Large skew should be flagged and clamped")
+		seen, _ = clock.FromPeer(start.Add(-time.Hour), start)
+		assert.True(seen.Equal(start.Add(-time.Hour)), "Past times should be kept")
+		assert.False(clock.Expired(start.Add(-time.Minute), start), "Deadlines within the skew should not expire")
+		assert.True(clock.Expired(start.Add(-time.Hour), start), "Deadlines well past should expire")
+
+		section.Step(2, "Contacts from a fast clock cannot look fresher")
+		localID := "0000000000000000000000000000000000000000"
+		routingTable := kademlia.NewRoutingTable(localID)
+		seenHere := &models.Node{ID: "8000000000000000000000000000000000000001", IP: "10.0.0.1", Port: 8080}
+		seenHere.MarkSeen(fake.Now())
+		reported := &models.Node{ID: "8000000000000000000000000000000000000002", IP: "10.0.0.2", Port: 8080, LastSeen: start.Add(24 * time.Hour).Unix()}
+		kademlia.AddNodeToRoutingTable(routingTable, seenHere, localID)
+		kademlia.AddNodeToRoutingTable(routingTable, reported, localID)
+		assert.Equal(start.Unix(), reported.LastSeen, "Future LastSeen should be capped at our time")
+		fake.Advance(time.Second)
+		kademlia.AddNodeToRoutingTable(routingTable, &models.Node{ID: seenHere.ID, LastSeen: start.Add(48 * time.Hour).Unix()}, localID)
+		assert.Equal(start.Add(time.Second).Unix(), seenHere.LastSeen, "Updates should be capped too")
+
+		section.Step(3, "Seen times keep the monotonic reading")
+		local := &models.Node{}
+		now := time.Now()
+		local.MarkSeen(now)
+		assert.True(local.SeenAt() == now, "SeenAt should return the local reading as is")
+
+		section.Step(4, "Imports distrust the source clock")
+		expired := start.Add(-time.Hour)
+		export := fmt.Sprintf(`{"key":%q,"value":"future","stored_at":%q}`+"\n"+`{"key":%q,"value":"gone","stored_at":%q,"expires_at":%q}`+"\n",
+			fixtures.GenerateValidHexID("future"), start.Add(time.Hour).Format(time.RFC3339),
+			fixtures.GenerateValidHexID("gone"), start.Add(-2*time.Hour).Format(time.RFC3339), expired.Format(time.RFC3339))
+		storage := kademlia.NewKeyValueStore()
+		result, err := kademlia.ImportRecords(strings.NewReader(export), storage, false)
+		assert.NoError(err, "Import should succeed")
+		assert.Equal(1, result.Imported, "Only the live record should be imported")
+		assert.Equal(1, result.Skipped, "The expired record should be skipped")
+		for _, rec := range storage.Records() {
+			assert.True(!rec.StoredAt.After(fake.Now()), "Store time should not be in the future")
+		}
+
+		section.Success("Skewed clocks tolerated")
+	})
+}
@@ -0,0 +1,54 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/cmd"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestCluster tests running several bootstrapped nodes in one process
+func TestCluster(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CLUSTER")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting cluster tests")
+
+	t.Run("ClusterServesValues", func(t *testing.T) {
+		section := logger.Section("Cluster Serves Values")
+		ctx := context.Background()
+
+		section.Step(1, "Start a three node cluster")
+		nodes, err := cmd.StartCluster(ctx, config.Default(), 3)
+		assert.NoError(err, "Cluster should start")
+		defer func() {
+			for _, n := range nodes {
+				n.Stop()
+			}
+		}()
+		assert.Equal(3, len(nodes), "All nodes should be started")
+
+		section.Step(2, "Store through the last node and read through the first")
+		key := fixtures.GenerateValidHexID("cluster")
+		_, err = nodes[2].Put(ctx, key, "value")
+		assert.NoError(err, "Put should succeed")
+		value, found, err := nodes[0].Get(ctx, key)
+		assert.NoError(err, "Get should succeed")
+		assert.True(found, "Value should be found")
+		assert.Equal("value", value, "Value should match")
+
+		section.Success("Cluster nodes are bootstrapped to each other")
+	})
+
+	t.Run("InvalidSize", func(t *testing.T) {
+		section := logger.Section("Invalid Size")
+
+		_, err := cmd.StartCluster(context.Background(), config.Default(), 0)
+		assert.HasError(err, "Empty cluster should be rejected")
+
+		section.Success("Invalid sizes rejected")
+	})
+}
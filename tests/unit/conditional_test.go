@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestConditionalFindValue tests HEAD and conditional GET on /find_value
+func TestConditionalFindValue(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CONDITIONAL")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting conditional FIND_VALUE tests")
+
+	node := fixtures.CreateTestNode(8080, "local")
+	table := kademlia.NewRoutingTable(node.ID)
+	storage := kademlia.NewKeyValueStore()
+	key, missing := fixtures.GenerateValidHexID("status"), fixtures.GenerateValidHexID("missing")
+	storage.Set(key, "online")
+
+	// find sends a /find_value for key with the If-None-Match header, if any
+	find := func(method, key, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/find_value?key="+key, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		kademlia.FindValueHandler(rr, req, node, storage, table)
+		return rr
+	}
+	sum := sha256.Sum256([]byte("online"))
+	hash := hex.EncodeToString(sum[:])
+
+	t.Run("Head", func(t *testing.T) {
+		section := logger.Section("HEAD Requests")
+
+		section.Step(1, "A held key answers with its tag and provenance")
+		rr := find(http.MethodHead, key, "")
+		assert.Equal(http.StatusOK, rr.Code, "HEAD of a held key should succeed")
+		assert.Equal(0, rr.Body.Len(), "Value should not be sent")
+		assert.Equal(`W/"`+hash+`"`, rr.Header().Get("ETag"), "ETag should follow the value's hash")
+		meta, err := kademlia.DecodeMetaHeader(rr.Header().Get(kademlia.MetaHeader))
+		assert.NoError(err, "Provenance should decode")
+		assert.Equal(hash, meta.Hash, "Provenance should carry the hash")
+		assert.Equal(len("online"), meta.Size, "Provenance should carry the size")
+
+		section.Step(2, "A missing key answers 404")
+		rr = find(http.MethodHead, missing, "")
+		assert.Equal(http.StatusNotFound, rr.Code, "HEAD of a missing key should be 404")
+
+		section.Success("HEAD requests working correctly")
+	})
+
+	t.Run("Conditional", func(t *testing.T) {
+		section := logger.Section("Conditional GETs")
+
+		section.Step(1, "A matching tag answers 304 without the value")
+		etag := find(http.MethodGet, key, "").Header().Get("ETag")
+		rr := find(http.MethodGet, key, etag)
+		assert.Equal(http.StatusNotModified, rr.Code, "Unchanged value should not be sent")
+		assert.Equal(0, rr.Body.Len(), "304 should have no body")
+		assert.Equal(http.StatusNotModified, find(http.MethodGet, key, `"other", `+strings.TrimPrefix(etag, "W/")).Code, "Strong form in a list should match")
+		assert.Equal(http.StatusNotModified, find(http.MethodGet, key, "*").Code, "Wildcard should match a held key")
+
+		section.Step(2, "A republish keeps the tag")
+		storage.Set(key, "online")
+		assert.Equal(http.StatusNotModified, find(http.MethodGet, key, etag).Code, "Republished value should be unchanged")
+
+		section.Step(3, "A new value is sent with a new tag")
+		storage.Set(key, "away")
+		rr = find(http.MethodGet, key, etag)
+		assert.Equal(http.StatusOK, rr.Code, "Changed value should be sent")
+		assert.NotEqual(etag, rr.Header().Get("ETag"), "Tag should change with the value")
+		storage.Set(key, "online")
+
+		section.Step(4, "Missing keys are answered with contacts")
+		assert.Equal(http.StatusOK, find(http.MethodGet, missing, "*").Code, "Wildcard should not match a missing key")
+
+		section.Success("Conditional GETs working correctly")
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		section := logger.Section("Client Polling")
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindValueHandler(w, r, node, storage, table)
+		}))
+		defer server.Close()
+		c := client.NewClient(strings.TrimPrefix(server.URL, "http://"))
+		ctx := context.Background()
+
+		section.Step(1, "Head returns the provenance")
+		meta, found, err := c.Head(ctx, key, false)
+		assert.NoError(err, "Head should succeed")
+		assert.True(found, "Key should be found")
+		assert.Equal(hash, meta.Hash, "Hash should be returned")
+		_, found, err = c.Head(ctx, missing, false)
+		assert.NoError(err, "Head of a missing key should succeed")
+		assert.False(found, "Missing key should not be found")
+
+		section.Step(2, "GetIfChanged only fetches changed values")
+		value, tag, found, err := c.GetIfChanged(ctx, key, "", false)
+		assert.NoError(err, "First poll should succeed")
+		assert.True(found, "Key should be found")
+		assert.Equal("online", value, "First poll should fetch the value")
+		value, again, _, _ := c.GetIfChanged(ctx, key, tag, false)
+		assert.Equal("", value, "Unchanged value should not be fetched")
+		assert.Equal(tag, again, "Tag should be kept")
+		storage.Set(key, "busy")
+		value, again, _, _ = c.GetIfChanged(ctx, key, tag, false)
+		assert.Equal("busy", value, "Changed value should be fetched")
+		assert.NotEqual(tag, again, "Tag should change")
+		_, _, found, err = c.GetIfChanged(ctx, missing, "", false)
+		assert.NoError(err, "Polling a missing key should succeed")
+		assert.False(found, "Missing key should not be found")
+
+		section.Success("Client polling working correctly")
+	})
+}
@@ -0,0 +1,230 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/cmd"
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestConfigDefaults tests the default parameters and their validation
+func TestConfigDefaults(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CONFIG")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting configuration tests")
+
+	t.Run("Defaults", func(t *testing.T) {
+		section := logger.Section("Defaults")
+
+		cfg := config.Default()
+		assert.Equal(20, cfg.K, "k should default to 20")
+		assert.Equal(3, cfg.Alpha, "alpha should default to 3")
+		assert.Equal(time.Hour, cfg.RefreshInterval, "Buckets should be refreshed hourly")
+		assert.Equal(24*time.Hour, cfg.GC.TTL, "Entries should expire after a day")
+		assert.NoError(cfg.Validate(), "Defaults should be valid")
+
+		section.Success("Defaults are production-grade")
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		section := logger.Section("Validation")
+
+		for name, mutate := range map[string]func(*config.Config){
+			"k below 1":        func(c *config.Config) { c.K = 0 },
+			"alpha below 1":    func(c *config.Config) { c.Alpha = 0 },
+			"negative refresh": func(c *config.Config) { c.RefreshInterval = -time.Second },
+			"port out of range": func(c *config.Config) {
+				c.Port = 70000
+			},
+			"Mainline port out of range":  func(c *config.Config) { c.Mainline.Port = -1 },
+			"pinned peer without port":    func(c *config.Config) { c.Peers = []string{"10.0.0.1"} },
+			"partition threshold above 1": func(c *config.Config) { c.Partition.Threshold = 1.5 },
+			"port without RPCs":           func(c *config.Config) { c.Server.Handlers = []string{config.HandlersAdmin} },
+			"unknown handler set": func(c *config.Config) {
+				c.Server.Listeners = []config.ListenerConfig{{Addr: "127.0.0.1:9090", Handlers: []string{"metrics"}}}
+			},
+		} {
+			cfg := config.Default()
+			mutate(cfg)
+			assert.HasError(cfg.Validate(), "Validation should reject "+name)
+		}
+
+		section.Step(1, "Environment values are validated")
+		os.Setenv("KADEMLIA_K", "0")
+		_, err := config.FromEnv()
+		assert.HasError(err, "k=0 from the environment should be rejected")
+		os.Setenv("KADEMLIA_K", "8")
+		os.Setenv("KADEMLIA_ALPHA", "2")
+		defer os.Unsetenv("KADEMLIA_K")
+		defer os.Unsetenv("KADEMLIA_ALPHA")
+		cfg, err := config.FromEnv()
+		assert.NoError(err, "Valid environment should load")
+		assert.Equal(8, cfg.K, "k should be overridden")
+		assert.Equal(2, cfg.Alpha, "alpha should be overridden")
+
+		os.Setenv("KADEMLIA_LISTENERS", "127.0.0.1:9090=admin, [::]:8080=rpc+gateway")
+		defer os.Unsetenv("KADEMLIA_LISTENERS")
+		cfg, err = config.FromEnv()
+		assert.NoError(err, "Listeners should parse")
+		assert.Equal(2, len(cfg.Server.Listeners), "Both listeners should be configured")
+		assert.Equal("[::]:8080", cfg.Server.Listeners[1].Addr, "IPv6 address should be kept")
+		assert.Equal(2, len(cfg.Server.Listeners[1].Handlers), "Sets should be split on +")
+		os.Setenv("KADEMLIA_LISTENERS", "127.0.0.1:9090")
+		_, err = config.FromEnv()
+		assert.HasError(err, "A listener without handler sets should be rejected")
+		os.Unsetenv("KADEMLIA_LISTENERS")
+
+		section.Step(2, "Nodes refuse to start with an invalid configuration")
+		cfg = config.Default()
+		cfg.K = 0
+		assert.HasError(kademlia.NewNode(cfg).Start(context.Background()), "Start should fail fast")
+
+		section.Success("Invalid settings are rejected")
+	})
+
+	t.Run("CommandLine", func(t *testing.T) {
+		section := logger.Section("Command Line")
+
+		section.Step(1, "Flags override the environment")
+		os.Setenv("KADEMLIA_K", "8")
+		defer os.Unsetenv("KADEMLIA_K")
+		dataDir := filepath.Join(t.TempDir(), "data")
+		opts, err := cmd.ParseArgs([]string{"--port", "9000", "--bootstrap", "127.0.0.1:8080", "--k", "12", "--alpha", "5", "--log-level", "warn", "--data-dir", dataDir}, io.Discard)
+		assert.NoError(err, "Flags should parse")
+		assert.False(opts.Deprecated, "Flags should not be deprecated")
+		cfg, err := config.FromEnv()
+		assert.NoError(err, "Environment should load")
+		assert.NoError(opts.Apply(cfg), "Flags should apply")
+		assert.Equal(9000, cfg.Port, "Port should be set")
+		assert.Equal("127.0.0.1:8080", cfg.Bootstrap, "Bootstrap should be set")
+		assert.Equal(12, cfg.K, "Flag should win over KADEMLIA_K")
+		assert.Equal(5, cfg.Alpha, "Alpha should be set")
+		assert.Equal("warn", cfg.LogLevel, "Log level should be set")
+		assert.Equal("file", cfg.Storage.Backend, "A data directory should switch to the file backend")
+		assert.Equal(filepath.Join(dataDir, "kademlia.log"), cfg.Storage.Path, "Values should be kept in the data directory")
+		_, err = os.Stat(dataDir)
+		assert.NoError(err, "Data directory should be created")
+
+		section.Step(2, "Unset flags keep the environment")
+		opts, err = cmd.ParseArgs([]string{"--port", "9000"}, io.Discard)
+		assert.NoError(err, "Flags should parse")
+		cfg, _ = config.FromEnv()
+		assert.NoError(opts.Apply(cfg), "Flags should apply")
+		assert.Equal(8, cfg.K, "KADEMLIA_K should be kept")
+		opts, _ = cmd.ParseArgs([]string{"--port", "9000", "--log-level", "loud"}, io.Discard)
+		assert.HasError(opts.Apply(config.Default()), "Unknown log levels should be rejected")
+
+		section.Step(3, "Positional arguments still work but are deprecated")
+		opts, err = cmd.ParseArgs([]string{"--client", "8081", "127.0.0.1:8080"}, io.Discard)
+		assert.NoError(err, "Positional arguments should parse")
+		assert.True(opts.Deprecated, "Positional arguments should be flagged deprecated")
+		cfg = config.Default()
+		assert.NoError(opts.Apply(cfg), "Positional arguments should apply")
+		assert.Equal(8081, cfg.Port, "Positional port should be used")
+		assert.Equal("127.0.0.1:8080", cfg.Bootstrap, "Positional bootstrap should be used")
+		assert.True(cfg.ClientOnly, "Flags before positional arguments should apply")
+		_, err = cmd.ParseArgs([]string{"--port", "9000", "8081"}, io.Discard)
+		assert.HasError(err, "A port given twice should be rejected")
+		_, err = cmd.ParseArgs([]string{"--help"}, io.Discard)
+		assert.True(errors.Is(err, flag.ErrHelp), "--help should be reported")
+
+		section.Step(4, "Config files fill in unset variables")
+		path := filepath.Join(t.TempDir(), "kademlia.env")
+		os.WriteFile(path, []byte("# test network\nKADEMLIA_ALPHA=6\nexport KADEMLIA_K=\"4\"\n"), 0o644)
+		defer os.Unsetenv("KADEMLIA_ALPHA")
+		assert.NoError(config.LoadEnvFile(path), "Config file should load")
+		cfg, err = config.FromEnv()
+		assert.NoError(err, "Environment should load")
+		assert.Equal(6, cfg.Alpha, "File should set unset variables")
+		assert.Equal(8, cfg.K, "The environment should win over the file")
+		os.WriteFile(path, []byte("alpha: 6\n"), 0o644)
+		assert.HasError(config.LoadEnvFile(path), "Malformed lines should be rejected")
+
+		section.Success("Flags, positional arguments and config files combine")
+	})
+
+	t.Run("Subcommands", func(t *testing.T) {
+		section := logger.Section("Subcommands")
+
+		section.Step(1, "Help and usage errors")
+		assert.Equal(0, cmd.Main([]string{"help"}), "help should succeed")
+		assert.Equal(0, cmd.Main([]string{"serve", "--help"}), "serve --help should succeed")
+		assert.Equal(2, cmd.Main([]string{"frobnicate"}), "Unknown commands should be usage errors")
+		assert.Equal(2, cmd.Main([]string{"get", "somekey"}), "get without --bootstrap should be a usage error")
+		assert.Equal(2, cmd.Main([]string{"put", "--bootstrap", "127.0.0.1:1", "key"}), "put without a value should be a usage error")
+
+		section.Step(2, "Failures exit 1")
+		assert.Equal(1, cmd.Main([]string{"get", "--bootstrap", "127.0.0.1:1", "--hash", "somekey"}), "An unreachable bootstrap node should fail")
+		assert.Equal(1, cmd.Main([]string{"serve"}), "serve without a port should fail")
+
+		section.Success("Commands are dispatched and report their exit status")
+	})
+
+	t.Run("BucketRefresh", func(t *testing.T) {
+		section := logger.Section("Bucket Refresh")
+
+		localID := fixtures.GenerateValidHexID("refresh")
+		for _, index := range []int{0, 7, 80, 159} {
+			id := kademlia.RandomIDInBucket(localID, index)
+			assert.Equal(len(localID), len(id), "Random ID should keep the ID length")
+		}
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		section.Step(1, "A peer reports contacts the table does not know")
+		peer := fixtures.CreateTestNode(0, "peer")
+		peerTable := kademlia.NewRoutingTable(peer.ID)
+		discovered := fixtures.CreateTestNodes(5, 9000)
+		for _, n := range discovered {
+			kademlia.AddNodeToRoutingTable(peerTable, n, peer.ID)
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, peer, peerTable)
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		peer.IP = "127.0.0.1"
+		peer.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		routingTable := kademlia.NewRoutingTable(localID)
+		kademlia.AddNodeToRoutingTable(routingTable, peer, localID)
+
+		section.Step(2, "Refreshing adds them once the bucket goes stale")
+		refresher := kademlia.NewBucketRefresher(routingTable, localID, time.Hour)
+		assert.Equal(0, refresher.Refresh(context.Background()), "A bucket just updated should not be refreshed")
+		fake.Advance(time.Hour)
+		added := refresher.Refresh(context.Background())
+		assert.Equal(len(discovered), added, "Every discovered contact should be added")
+		found := kademlia.FindClosestNodes(routingTable, discovered[0].ID, localID, 0)
+		assert.True(containsNode(found, discovered[0].ID), "Discovered contact should be in the table")
+
+		section.Success("Buckets refreshed through lookups")
+	})
+}
+
+func containsNode(nodes []*models.Node, id string) bool {
+	for _, n := range nodes {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestContactMoves tests when the routing table follows a known contact
+// to a new address
+func TestContactMoves(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CONTACT_MOVES")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting contact move tests")
+
+	local := "0000000000000000000000000000000000000000"
+	id := "8000000000000000000000000000000000000001"
+
+	// at returns the contact id at ip:port, with a record signed by key
+	// listing that endpoint if key is set
+	at := func(key ed25519.PrivateKey, ip string, port int, endpoint string) *models.Node {
+		n := &models.Node{ID: id, IP: ip, Port: port}
+		if key != nil {
+			assert.NoError(kademlia.SignNodeRecord(n, key, []string{endpoint}, nil), "Signing should succeed")
+		}
+		return n
+	}
+
+	t.Run("Signed", func(t *testing.T) {
+		section := logger.Section("Signed Moves")
+
+		key, _ := kademlia.GenerateRecordKey()
+		other, _ := kademlia.GenerateRecordKey()
+		table := kademlia.NewRoutingTable(local)
+		contact := at(key, "10.0.0.1", 8080, "http://10.0.0.1:8080")
+		kademlia.AddNodeToRoutingTable(table, contact, local)
+
+		section.Step(1, "Records signed by another key or not listing the address are ignored")
+		kademlia.AddNodeToRoutingTable(table, at(other, "10.0.0.9", 9090, "http://10.0.0.9:9090"), local)
+		assert.Equal("10.0.0.1", contact.IP, "A record signed by another key should not move the contact")
+		kademlia.AddNodeToRoutingTable(table, at(key, "10.0.0.9", 9090, "http://10.0.0.1:8080"), local)
+		assert.Equal("10.0.0.1", contact.IP, "A record without the new endpoint should not move the contact")
+
+		section.Step(2, "A record signed by the contact's key moves it")
+		kademlia.AddNodeToRoutingTable(table, at(key, "10.0.0.9", 9090, "http://10.0.0.9:9090"), local)
+		assert.Equal("10.0.0.9", contact.IP, "IP should follow the signed record")
+		assert.Equal(9090, contact.Port, "Port should follow the signed record")
+
+		section.Success("Signed moves followed")
+	})
+
+	t.Run("Diversity", func(t *testing.T) {
+		section := logger.Section("Moves Within The Limits")
+
+		key, _ := kademlia.GenerateRecordKey()
+		table := kademlia.NewRoutingTable(local)
+		table.SubnetLimit = models.SubnetLimit{PerBucket: 1}
+		table.Peers.SetInsertLimit(1, time.Hour)
+		contact := at(key, "10.0.0.1", 8080, "http://10.0.0.1:8080")
+		kademlia.AddNodeToRoutingTable(table, contact, local)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "8000000000000000000000000000000000000002", IP: "10.0.1.1", Port: 8080}, local)
+
+		section.Step(1, "A move into a full subnet is refused")
+		kademlia.AddNodeToRoutingTable(table, at(key, "10.0.1.9", 8080, "http://10.0.1.9:8080"), local)
+		assert.Equal("10.0.0.1", contact.IP, "Contact should stay out of the full subnet")
+		stats := kademlia.BucketStats(table)
+		assert.True(len(stats) == 1 && stats[0].SubnetRefused == 1, "The refusal should be counted")
+
+		section.Step(2, "A move to a host that used up its inserts is refused")
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "8000000000000000000000000000000000000003", IP: "10.0.2.1", Port: 8080}, local)
+		kademlia.AddNodeToRoutingTable(table, at(key, "10.0.2.1", 9090, "http://10.0.2.1:9090"), local)
+		assert.Equal("10.0.0.1", contact.IP, "Contact should not move to a host at its insert limit")
+
+		section.Step(3, "A move within the limits is followed")
+		kademlia.AddNodeToRoutingTable(table, at(key, "10.0.3.1", 8080, "http://10.0.3.1:8080"), local)
+		assert.Equal("10.0.3.1", contact.IP, "Contact should move to a fresh subnet")
+
+		section.Success("Moves checked like insertions")
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		section := logger.Section("Moves Confirmed By Ping")
+
+		// pong serves PING as the node id
+		pong := func() (*httptest.Server, int) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(kademlia.PingReply{Message: "pong", NodeID: id, Token: r.URL.Query().Get("token")})
+			}))
+			addr := strings.TrimPrefix(server.URL, "http://")
+			port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+			return server, port
+		}
+		// settled waits up to wait for the contact's port to become want,
+		// reading it under the table's lock as the move happens in the
+		// background
+		settled := func(contact *models.Node, table *models.RoutingTable, want int, wait time.Duration) int {
+			deadline := time.Now().Add(wait)
+			for {
+				table.RLock()
+				port := contact.Port
+				table.RUnlock()
+				if port == want || time.Now().After(deadline) {
+					return port
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+
+		old, oldPort := pong()
+		defer old.Close()
+		current, currentPort := pong()
+		defer current.Close()
+		table := kademlia.NewRoutingTable(local)
+		contact := &models.Node{ID: id, IP: "127.0.0.1", Port: oldPort}
+		kademlia.AddNodeToRoutingTable(table, contact, local)
+
+		section.Step(1, "An unsigned move is ignored while the old address answers")
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: id, IP: "127.0.0.1", Port: currentPort}, local)
+		assert.Equal(oldPort, settled(contact, table, currentPort, 200*time.Millisecond), "Contact should stay where it still answers")
+
+		section.Step(2, "Once the old address is gone the contact follows")
+		old.Close()
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: id, IP: "127.0.0.1", Port: currentPort}, local)
+		assert.Equal(currentPort, settled(contact, table, currentPort, 2*time.Second), "Contact should move after the ping")
+
+		section.Success("Unsigned moves confirmed by ping")
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		section := logger.Section("Concurrent Access")
+
+		table := kademlia.NewRoutingTableWithK(local, 4)
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					contact := &models.Node{ID: fmt.Sprintf("%040x", w*1000+i+1), IP: fmt.Sprintf("10.%d.%d.1", w, i), Port: 8080}
+					kademlia.AddNodeToRoutingTable(table, contact, local)
+					kademlia.FindClosestNodes(table, contact.ID, local, 0)
+					kademlia.BucketStats(table)
+				}
+			}(w)
+		}
+		wg.Wait()
+		for _, bucket := range table.Buckets {
+			assert.True(len(bucket.Nodes) <= 4, "Buckets should stay within k")
+		}
+
+		section.Success("Table stays consistent under concurrent updates")
+	})
+}
@@ -0,0 +1,56 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/crawler"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestCrawler tests the network crawler
+func TestCrawler(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CRAWLER")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting crawler tests")
+
+	t.Run("CrawlSmallNetwork", func(t *testing.T) {
+		section := logger.Section("Crawl Small Network")
+
+		section.Step(1, "Start two mock nodes that know each other and a dead peer")
+		nodeA := fixtures.CreateTestNode(0, "a")
+		nodeB := fixtures.CreateTestNode(0, "b")
+		serverA := testutils.NewMockServer(section, nodeA)
+		defer serverA.Close()
+		serverB := testutils.NewMockServer(section, nodeB)
+		defer serverB.Close()
+
+		dead := &models.Node{ID: fixtures.GenerateValidHexID("dead"), IP: "127.0.0.1", Port: 1}
+		serverA.SetResponse("find_node", []*models.Node{nodeB, dead})
+		serverB.SetResponse("find_node", []*models.Node{nodeA})
+
+		section.Step(2, "Crawl from node A")
+		c := crawler.NewCrawler()
+		graph := c.Crawl(context.Background(), []string{serverA.GetAddress()})
+
+		section.Step(3, "Verify graph")
+		assert.Equal(3, graph.NetworkSize, "Should discover A, B and the dead peer")
+		assert.Equal(2, graph.Reachable, "A and B should be reachable")
+		assert.Equal(1, len(graph.Unreachable), "Dead peer should be unreachable")
+		assert.Equal(3, len(graph.Edges), "Should record A->B, A->dead and B->A")
+		assert.Equal(2, graph.Peers[serverA.GetAddress()].OutDegree, "A should have out-degree 2")
+		assert.Equal(1, graph.DegreeDistribution[2], "One peer should have out-degree 2")
+
+		section.Step(4, "Render DOT output")
+		var buf bytes.Buffer
+		assert.NoError(graph.WriteDOT(&buf), "DOT rendering should succeed")
+		assert.Contains(buf.String(), "digraph kademlia", "DOT output should contain a digraph")
+		assert.Contains(buf.String(), "style=dashed", "Unreachable peers should be dashed")
+
+		section.Success("Crawler mapped the network correctly")
+	})
+}
@@ -0,0 +1,185 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestCRDTRecords tests the record types whose STOREs merge
+func TestCRDTRecords(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "CRDT")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting CRDT record tests")
+
+	t.Run("GCounter", func(t *testing.T) {
+		section := logger.Section("G-Counter")
+
+		section.Step(1, "Merging keeps each actor's highest count")
+		a := models.NewCRDTRecord(models.RecordGCounter)
+		a.Increment("alice", 3)
+		b := models.NewCRDTRecord(models.RecordGCounter)
+		b.Increment("alice", 1)
+		b.Increment("bob", 2)
+		merged := a.Merge(b)
+		assert.Equal(uint64(5), merged.Count(), "Counts should be 3 from alice plus 2 from bob")
+
+		section.Step(2, "Merging is commutative and idempotent")
+		assert.Equal(merged.Encode(), b.Merge(a).Encode(), "Merge order should not matter")
+		assert.Equal(merged.Encode(), merged.Merge(a).Encode(), "Merging again should change nothing")
+
+		section.Success("G-counter merging correctly")
+	})
+
+	t.Run("ORSet", func(t *testing.T) {
+		section := logger.Section("OR-Set")
+
+		section.Step(1, "Removed elements leave the set")
+		set := models.NewCRDTRecord(models.RecordORSet)
+		set.Add("x", "t1")
+		set.Add("y", "t2")
+		set.Remove("x")
+		assert.Equal("y", strings.Join(set.Elements(), ","), "Only y should remain")
+
+		section.Step(2, "An addition concurrent with a removal wins")
+		concurrent := models.NewCRDTRecord(models.RecordORSet)
+		concurrent.Add("x", "t3")
+		merged := set.Merge(concurrent)
+		assert.True(merged.Contains("x"), "Unseen addition should survive the removal")
+		assert.Equal(merged.Encode(), concurrent.Merge(set).Encode(), "Merge order should not matter")
+
+		section.Step(3, "Records of the wrong type are refused")
+		_, err := models.ParseCRDTRecord(set.Encode(), models.RecordGCounter)
+		assert.HasError(err, "A set should not parse as a counter")
+		_, err = models.MergeCRDTRecord("plain", true, set)
+		assert.Equal(models.ErrRecordType, err, "A plain value should not be merged into")
+
+		section.Success("OR-set merging correctly")
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		section := logger.Section("Typed STOREs")
+
+		node := fixtures.CreateTestNode(8080, "holder")
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		kademlia.AddNodeToRoutingTable(table, node, node.ID)
+		key := fixtures.GenerateValidHexID("counter")
+
+		store := func(key, value string, recordType models.RecordType) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(kademlia.StoreRequest{Key: key, Value: value, Type: recordType})
+			req := httptest.NewRequest("POST", "/store", strings.NewReader(string(body)))
+			rr := httptest.NewRecorder()
+			kademlia.StoreHandler(rr, req, node, storage, table)
+			return rr
+		}
+		counter := func(actor string, n uint64) string {
+			rec := models.NewCRDTRecord(models.RecordGCounter)
+			rec.Increment(actor, n)
+			return rec.Encode()
+		}
+
+		section.Step(1, "Concurrent STOREs merge instead of overwriting")
+		assert.Equal(http.StatusCreated, store(key, counter("alice", 2), models.RecordGCounter).Code, "First STORE should succeed")
+		assert.Equal(http.StatusCreated, store(key, counter("bob", 5), models.RecordGCounter).Code, "Second STORE should succeed")
+		value, _ := storage.Get(key)
+		rec, err := models.ParseCRDTRecord(value, models.RecordGCounter)
+		assert.NoError(err, "Stored value should be a counter")
+		assert.Equal(uint64(7), rec.Count(), "Both writers' counts should be kept")
+
+		section.Step(2, "Invalid records are refused")
+		assert.Equal(http.StatusBadRequest, store(key, "not json", models.RecordGCounter).Code, "Invalid record should be refused")
+		assert.Equal(http.StatusBadRequest, store(key, counter("alice", 1), "lww-map").Code, "Unknown type should be refused")
+		assert.Equal(http.StatusBadRequest, store(key, counter("alice", 1), models.RecordORSet).Code, "Mislabelled record should be refused")
+
+		section.Step(3, "Typed STOREs over other values conflict")
+		plain := fixtures.GenerateValidHexID("plain")
+		storage.Set(plain, "hello")
+		rr := store(plain, counter("alice", 1), models.RecordGCounter)
+		assert.Equal(http.StatusConflict, rr.Code, "Typed STORE over a plain value should conflict")
+		assert.Contains(rr.Body.String(), "record_type_mismatch", "Conflict should name the mismatch")
+
+		section.Step(4, "Envelope STOREs merge too")
+		msg := models.Message{Type: models.Store, Sender: *fixtures.CreateTestNode(9090, "sender"), Key: key, Value: counter("carol", 1), RecordType: models.RecordGCounter, Version: models.MessageVersion}
+		body, _ := json.Marshal(msg)
+		rr = httptest.NewRecorder()
+		kademlia.MessageHandler(rr, httptest.NewRequest("POST", "/rpc", strings.NewReader(string(body))), node, storage, models.NewProviderStore(time.Hour, 10, 0), table)
+		assert.Equal(http.StatusOK, rr.Code, "Envelope STORE should succeed: %s", rr.Body.String())
+		value, _ = storage.Get(key)
+		rec, _ = models.ParseCRDTRecord(value, models.RecordGCounter)
+		assert.Equal(uint64(8), rec.Count(), "Envelope count should be merged")
+
+		section.Success("Typed STOREs merging correctly")
+	})
+
+	t.Run("AntiEntropy", func(t *testing.T) {
+		section := logger.Section("Anti-Entropy Merge")
+
+		node := fixtures.CreateTestNode(8080, "replica")
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("set")
+		mine := models.NewCRDTRecord(models.RecordORSet)
+		mine.Add("a", "t1")
+		storage.Set(key, mine.Encode())
+
+		section.Step(1, "Pushed records merge into the stored ones")
+		theirs := models.NewCRDTRecord(models.RecordORSet)
+		theirs.Add("b", "t2")
+		body, _ := json.Marshal([]kademlia.KeyRecord{{Key: key, Value: theirs.Encode()}})
+		rr := httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, httptest.NewRequest("POST", "/sync_push", strings.NewReader(string(body))), node, storage, kademlia.NewRoutingTable(node.ID))
+		assert.Contains(rr.Body.String(), `"stored":1`, "Merge should count as stored")
+		value, _ := storage.Get(key)
+		merged, _ := models.ParseCRDTRecord(value, models.RecordORSet)
+		assert.Equal("a,b", strings.Join(merged.Elements(), ","), "Both replicas' elements should be kept")
+
+		section.Step(2, "Pushing a record already merged stores nothing")
+		rr = httptest.NewRecorder()
+		kademlia.SyncPushHandler(rr, httptest.NewRequest("POST", "/sync_push", strings.NewReader(string(body))), node, storage, kademlia.NewRoutingTable(node.ID))
+		assert.Contains(rr.Body.String(), `"stored":0`, "Nothing should change")
+
+		section.Success("Anti-entropy merging correctly")
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		section := logger.Section("Client Records")
+
+		node := fixtures.CreateTestNode(8080, "entry")
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_value", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindValueHandler(w, r, node, storage, table)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		c := client.NewClient(strings.TrimPrefix(server.URL, "http://"))
+
+		section.Step(1, "Missing records read as empty")
+		rec, found, _, err := c.GetRecord(context.Background(), "page:views", models.RecordGCounter, true)
+		assert.NoError(err, "GetRecord should succeed")
+		assert.False(found, "Record should be missing")
+		assert.Equal(models.RecordGCounter, rec.Type, "Empty record should have the type asked for")
+
+		section.Step(2, "Stored records are parsed")
+		rec.Increment("web-1", 4)
+		storage.Set(kademlia.KeyFromString("page:views"), rec.Encode())
+		rec, found, _, err = c.GetRecord(context.Background(), "page:views", models.RecordGCounter, true)
+		assert.NoError(err, "GetRecord should succeed")
+		assert.True(found, "Record should be found")
+		assert.Equal(uint64(4), rec.Count(), "Count should be read back")
+
+		section.Success("Client records working correctly")
+	})
+}
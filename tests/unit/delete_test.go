@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestDeletes tests how /delete dates tombstones, who may delete a value
+// and what survives a restart
+func TestDeletes(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "DELETES")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting delete tests")
+
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	defer clock.Set(clock.Set(fake))
+
+	node := &models.Node{ID: "0000000000000000000000000000000000000000", IP: "127.0.0.1", Port: 8080}
+	table := kademlia.NewRoutingTable(node.ID)
+	del := func(storage *models.KeyValueStore, req kademlia.DeleteRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		kademlia.DeleteHandler(rr, httptest.NewRequest("POST", "/delete", strings.NewReader(string(body))), node, storage, table)
+		return rr
+	}
+	key := "00000000000000000000000000000000000000aa"
+	other := "00000000000000000000000000000000000000bb"
+	signed := "00000000000000000000000000000000000000cc"
+
+	t.Run("FutureTombstones", func(t *testing.T) {
+		section := logger.Section("Future Tombstones")
+
+		storage := kademlia.NewKeyValueStore()
+		storage.Put(key, "value", "", models.OverwriteAlways, "")
+
+		section.Step(1, "A deleted_at ahead of the clock is taken as now")
+		future := fake.Now().Add(24 * time.Hour)
+		assert.Equal(http.StatusOK, del(storage, kademlia.DeleteRequest{Key: key, DeletedAt: &future}).Code, "Delete should succeed")
+		at, deleted := storage.DeletedAt(key)
+		assert.True(deleted && at.Equal(fake.Now()), "Tombstone should be dated now")
+
+		section.Step(2, "The tombstone is purged after the TTL")
+		fake.Advance(time.Hour)
+		assert.Equal(1, storage.PurgeTombstones(fake.Now()), "Tombstone should be purged")
+
+		section.Success("Tombstones cannot outlive garbage collection")
+	})
+
+	t.Run("Publishers", func(t *testing.T) {
+		section := logger.Section("Publisher Signatures")
+
+		key1, _ := kademlia.GenerateRecordKey()
+		key2, _ := kademlia.GenerateRecordKey()
+		publisher := kademlia.PublisherID(key1.Public().(ed25519.PublicKey))
+		storage := kademlia.NewKeyValueStore()
+		storage.Put(key, "value", publisher, models.OverwriteAlways, "")
+		storage.Put(other, "value", publisher, models.OverwriteAlways, "")
+
+		section.Step(1, "Naming the publisher is not enough")
+		rr := del(storage, kademlia.DeleteRequest{Key: key, Publisher: publisher})
+		assert.Equal(http.StatusConflict, rr.Code, "Unsigned delete should be refused")
+		assert.Contains(rr.Body.String(), "publisher_mismatch", "Rejection should be typed")
+
+		section.Step(2, "A signature by another key is refused")
+		forged := kademlia.DeleteRequest{Key: key}
+		kademlia.SignDelete(&forged, key2)
+		forged.Publisher = publisher
+		assert.Equal(http.StatusConflict, del(storage, forged).Code, "Delete signed by another key should be refused")
+
+		section.Step(3, "The publisher's signature deletes the value, for that key only")
+		signed := kademlia.DeleteRequest{Key: key}
+		kademlia.SignDelete(&signed, key1)
+		assert.Equal(http.StatusOK, del(storage, signed).Code, "Signed delete should succeed")
+		_, exists := storage.Get(key)
+		assert.False(exists, "Value should be deleted")
+		signed.Key = other
+		assert.Equal(http.StatusConflict, del(storage, signed).Code, "Signature should not carry over to another key")
+
+		section.Step(4, "Values without a publisher are open to any writer")
+		storage.Put(key, "open", "", models.OverwriteAlways, "")
+		assert.Equal(http.StatusOK, del(storage, kademlia.DeleteRequest{Key: key}).Code, "Unpublished value should be deleted")
+
+		section.Success("Only publishers delete their values")
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		section := logger.Section("Restart")
+
+		dir := t.TempDir()
+		for i, backend := range []string{"file", "sqlite"} {
+			section.Step(i+1, "Reopen the "+backend+" backend")
+			cfg := config.StorageConfig{Backend: backend, Path: filepath.Join(dir, backend)}
+			storage, err := kademlia.OpenKeyValueStore(cfg)
+			if !assert.NoError(err, "%s store should open", backend) {
+				continue
+			}
+			storedAt := fake.Now()
+			storage.Put(key, "value", "publisher", models.OverwriteAlways, "")
+			storage.SetIntervals(key, 0, time.Hour)
+			storage.Put(other, "value", "", models.OverwriteAlways, "")
+			storage.Tombstone(other, fake.Now())
+			storage.TombstoneSigned(signed, fake.Now(), &models.DeleteProof{Publisher: "publisher", Signature: "signature", DeletedAt: fake.Now()}, nil)
+			assert.NoError(storage.Close(), "%s store should close", backend)
+
+			fake.Advance(time.Minute)
+			storage, err = kademlia.OpenKeyValueStore(cfg)
+			if !assert.NoError(err, "%s store should reopen", backend) {
+				continue
+			}
+			meta, exists := storage.Meta(key)
+			assert.True(exists, "%s: Value should survive", backend)
+			assert.Equal("publisher", meta.Publisher, "%s: Publisher should survive", backend)
+			assert.Equal(3600, meta.Republish, "%s: Intervals should survive", backend)
+			assert.True(meta.StoredAt.Equal(storedAt), "%s: Store time should survive", backend)
+			_, deleted := storage.DeletedAt(other)
+			assert.True(deleted, "%s: Tombstone should survive", backend)
+			assert.Equal(models.ErrDeleted, storage.Set(other, "value"), "%s: Deleted value should not come back", backend)
+			proof, hasProof := storage.DeleteProofOf(signed)
+			assert.True(hasProof && proof.Signature == "signature", "%s: Delete signature should survive", backend)
+			assert.Equal(1, len(storage.GetAll()), "%s: Bookkeeping should not be listed as values", backend)
+			assert.Equal(1, len(storage.Records()), "%s: Bookkeeping should not be exported", backend)
+
+			storage.PurgeTombstones(fake.Now())
+			storage.Close()
+			storage, _ = kademlia.OpenKeyValueStore(cfg)
+			_, deleted = storage.DeletedAt(other)
+			assert.False(deleted, "%s: Purged tombstone should stay purged", backend)
+			storage.Close()
+		}
+
+		section.Success("Tombstones and provenance survive restarts")
+	})
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestDeterministicMode tests that seeded runs reproduce IDs, sampling and
+// lookup order
+func TestDeterministicMode(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "DETERMINISTIC")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting deterministic mode tests")
+	defer kademlia.SetSeed(0)
+
+	t.Run("SeededIDs", func(t *testing.T) {
+		section := logger.Section("Seeded IDs")
+
+		kademlia.SetSeed(42)
+		assert.True(kademlia.Deterministic(), "Seed should enable deterministic mode")
+		first := []string{kademlia.GenerateNodeID(), kademlia.GenerateNodeID(), kademlia.NewNode(nil).Self.ID}
+		kademlia.SetSeed(42)
+		second := []string{kademlia.GenerateNodeID(), kademlia.GenerateNodeID(), kademlia.NewNode(nil).Self.ID}
+
+		assert.Equal(fmt.Sprint(first), fmt.Sprint(second), "Same seed should yield the same IDs")
+		assert.True(first[0] != first[1], "IDs within a run should differ")
+		assert.Equal(40, len(first[0]), "IDs should be 160-bit hex")
+
+		kademlia.SetSeed(0)
+		assert.False(kademlia.Deterministic(), "Seed 0 should restore randomness")
+
+		section.Success("IDs reproducible")
+	})
+
+	t.Run("SeededSampling", func(t *testing.T) {
+		section := logger.Section("Seeded Sampling")
+
+		localID := fixtures.GenerateValidHexID("local")
+		rt := kademlia.NewRoutingTable(localID)
+		for i := 0; i < 10; i++ {
+			kademlia.AddNodeToRoutingTable(rt, &models.Node{ID: fixtures.GenerateValidHexID("peer" + strconv.Itoa(i))}, localID)
+		}
+		sample := func() string {
+			var ids []string
+			for _, n := range kademlia.SamplePeers(rt, "", 0, 3, localID) {
+				ids = append(ids, n.ID)
+			}
+			return fmt.Sprint(ids)
+		}
+
+		kademlia.SetSeed(7)
+		first := sample()
+		kademlia.SetSeed(7)
+		assert.Equal(first, sample(), "Same seed should sample the same peers")
+
+		section.Success("Sampling reproducible")
+	})
+
+	t.Run("LookupReplyOrder", func(t *testing.T) {
+		section := logger.Section("Lookup Reply Order")
+
+		// serve answers FIND_NODE with contact after delay
+		serve := func(name string, contact *models.Node, delay time.Duration) (*models.Node, *httptest.Server) {
+			node := fixtures.CreateTestNode(0, name)
+			table := kademlia.NewRoutingTable(node.ID)
+			kademlia.AddNodeToRoutingTable(table, contact, node.ID)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(delay)
+				kademlia.FindNodeHandler(w, r, node, table)
+			}))
+			addr := strings.TrimPrefix(server.URL, "http://")
+			node.IP = "127.0.0.1"
+			node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+			return node, server
+		}
+		slowContact := fixtures.CreateTestNode(9001, "slow-contact")
+		fastContact := fixtures.CreateTestNode(9002, "fast-contact")
+		slow, slowServer := serve("slow", slowContact, 50*time.Millisecond)
+		defer slowServer.Close()
+		fast, fastServer := serve("fast", fastContact, 0)
+		defer fastServer.Close()
+
+		kademlia.SetSeed(0)
+		found := kademlia.FanOutFindNode(context.Background(), []*models.Node{slow, fast}, fastContact.ID)
+		assert.True(len(found) == 2 && found[0].ID == fastContact.ID, "Replies should normally merge in arrival order")
+
+		kademlia.SetSeed(1)
+		found = kademlia.FanOutFindNode(context.Background(), []*models.Node{slow, fast}, fastContact.ID)
+		assert.True(len(found) == 2 && found[0].ID == slowContact.ID, "Deterministic mode should merge replies in query order")
+
+		section.Success("Lookup replies merged in query order")
+	})
+}
@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestXORDistance tests the fixed-size XOR distance against big.Int
+func TestXORDistance(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "DISTANCE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting XOR distance tests")
+
+	t.Run("MatchesBigInt", func(t *testing.T) {
+		section := logger.Section("Matches big.Int")
+
+		ids := []string{
+			"0000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000001",
+			"8000000000000000000000000000000000000000",
+			"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+			"abc",
+		}
+		for i := 0; i < 20; i++ {
+			ids = append(ids, fixtures.GenerateValidHexID(fmt.Sprintf("distance%d", i)))
+		}
+
+		for _, a := range ids {
+			for _, b := range ids {
+				d, ok := kademlia.XORDistance(a, b)
+				assert.True(ok, "Well-formed IDs should fit a Distance")
+				want := calculateXORDistance(a, b)
+				assert.Equal(0, d.Big().Cmp(want), fmt.Sprintf("Distance of %q and %q should match big.Int", a, b))
+				assert.Equal(want.BitLen(), d.BitLen(), fmt.Sprintf("BitLen of %q and %q should match big.Int", a, b))
+
+				other, _ := kademlia.XORDistance(a, ids[0])
+				assert.Equal(want.Cmp(calculateXORDistance(a, ids[0])), d.Cmp(other), "Cmp should match big.Int")
+			}
+		}
+
+		section.Success("Distances match big.Int")
+	})
+
+	t.Run("RejectsMalformedIDs", func(t *testing.T) {
+		section := logger.Section("Rejects Malformed IDs")
+
+		valid := fixtures.GenerateValidHexID("valid")
+		for _, id := range []string{"xyz", "-1", valid + "00"} {
+			_, ok := kademlia.XORDistance(valid, id)
+			assert.False(ok, fmt.Sprintf("%q should not fit a Distance", id))
+		}
+
+		section.Step(1, "Oversized contacts are still kept out of the table")
+		rt := kademlia.NewRoutingTable(valid)
+		node := fixtures.CreateTestNode(8080, "oversized")
+		node.ID = "ff" + valid
+		kademlia.AddNodeToRoutingTable(rt, node, valid)
+		assert.Equal(0, len(kademlia.FindClosestNodes(rt, valid, valid, 0)), "Oversized IDs should be ignored")
+
+		section.Success("Malformed IDs fall back to big.Int")
+	})
+
+	t.Run("ClosestNodesOrdered", func(t *testing.T) {
+		section := logger.Section("Closest Nodes Ordered")
+
+		localID := fixtures.GenerateValidHexID("local")
+		rt := kademlia.NewRoutingTableWithK(localID, 50)
+		for _, node := range fixtures.CreateTestNodes(40, 8080) {
+			kademlia.AddNodeToRoutingTable(rt, node, localID)
+		}
+
+		target := fixtures.GenerateValidHexID("target")
+		closest := kademlia.FindClosestNodes(rt, target, localID, 0)
+		for i := 1; i < len(closest); i++ {
+			prev := calculateXORDistance(target, closest[i-1].ID)
+			assert.True(prev.Cmp(calculateXORDistance(target, closest[i].ID)) <= 0, "Nodes should be ordered by distance")
+		}
+		assert.True(new(big.Int).Cmp(calculateXORDistance(target, target)) == 0, "Distance to self should be 0")
+
+		section.Success("Closest nodes sorted by fixed-size distance")
+	})
+
+	t.Run("ParallelScanMatches", func(t *testing.T) {
+		section := logger.Section("Parallel Scan Matches")
+
+		section.Step(1, "Fill a large table")
+		localID := fixtures.GenerateValidHexID("local")
+		rt := kademlia.NewRoutingTableWithK(localID, 1000)
+		for i := 0; i < 3000; i++ {
+			node := fixtures.CreateTestNode(8080, "")
+			node.ID = kademlia.KeyFromString(fmt.Sprintf("contact-%d", i))
+			kademlia.AddNodeToRoutingTable(rt, node, localID)
+		}
+
+		section.Step(2, "Sharded and single scans return the same contacts")
+		defer func(threshold int) { kademlia.ParallelScanThreshold = threshold }(kademlia.ParallelScanThreshold)
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+		for i := 0; i < 20; i++ {
+			target := kademlia.KeyFromString(fmt.Sprintf("target-%d", i))
+			kademlia.ParallelScanThreshold = 1 << 30
+			single := kademlia.FindClosestNodes(rt, target, localID, 20)
+			kademlia.ParallelScanThreshold = 1
+			sharded := kademlia.FindClosestNodes(rt, target, localID, 20)
+			assert.Equal(20, len(sharded), "Sharded scan should return count contacts")
+			assert.Equal(fmt.Sprint(nodeIDs(single)), fmt.Sprint(nodeIDs(sharded)), "Sharded scan should match the single scan")
+		}
+
+		section.Success("Sharded bucket scans find the same closest contacts")
+	})
+}
+
+func nodeIDs(nodes []*models.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return ids
+}
@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/doctor"
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestDoctor tests the startup checks behind kademlia doctor
+func TestDoctor(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "DOCTOR")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting doctor tests")
+
+	t.Run("Ports", func(t *testing.T) {
+		section := logger.Section("Ports")
+
+		section.Step(1, "A port in use fails with a hint")
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(err, "Listener should start")
+		defer listener.Close()
+		result := doctor.CheckPort("tcp", listener.Addr().String())
+		assert.Equal(doctor.Fail, result.Status, "A bound port should fail the check")
+		assert.Contains(result.Hint, "another process", "Hint should explain the port is taken")
+
+		section.Step(2, "A free port passes")
+		listener.Close()
+		assert.Equal(doctor.OK, doctor.CheckPort("tcp", listener.Addr().String()).Status, "A released port should pass")
+
+		section.Step(3, "A node without a port fails")
+		results := doctor.CheckPorts(config.Default())
+		assert.Equal(doctor.Fail, results[0].Status, "No port should fail the check")
+
+		section.Success("Ports checked")
+	})
+
+	t.Run("DataDir", func(t *testing.T) {
+		section := logger.Section("Data Dir")
+
+		section.Step(1, "A writable directory passes and is left clean")
+		dir := t.TempDir()
+		result := doctor.CheckDataDir(config.StorageConfig{Backend: "file", Path: filepath.Join(dir, "kademlia.log")})
+		assert.Equal(doctor.OK, result.Status, "A temporary directory should be writable")
+		entries, _ := os.ReadDir(dir)
+		assert.Equal(0, len(entries), "The probe file should be removed")
+
+		section.Step(2, "Missing directories and files in their place fail")
+		result = doctor.CheckDataDir(config.StorageConfig{Backend: "file", Path: filepath.Join(dir, "missing", "kademlia.log")})
+		assert.Equal(doctor.Fail, result.Status, "A missing directory should fail")
+		blocker := filepath.Join(dir, "file")
+		assert.NoError(os.WriteFile(blocker, nil, 0o644), "File should be written")
+		result = doctor.CheckDataDir(config.StorageConfig{Backend: "sqlite", Path: filepath.Join(blocker, "kademlia.db")})
+		assert.Equal(doctor.Fail, result.Status, "A file where the directory should be should fail")
+
+		section.Step(3, "The memory backend needs no directory")
+		assert.Equal(doctor.OK, doctor.CheckDataDir(config.StorageConfig{Backend: "memory"}).Status, "Memory backend should pass")
+
+		section.Success("Data directories checked")
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		section := logger.Section("Clock")
+		now := time.Unix(1700000000, 0)
+
+		section.Step(1, "An unset clock fails")
+		assert.Equal(doctor.Fail, doctor.CheckClock(time.Unix(0, 0)).Status, "A clock in 1970 should fail")
+		assert.Equal(doctor.OK, doctor.CheckClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)).Status, "A current clock should pass")
+
+		section.Step(2, "Skew to a peer warns, then fails past MaxSkew")
+		assert.Equal(doctor.OK, doctor.CheckSkew("peer", now.Add(time.Second), now).Status, "A second apart should pass")
+		assert.Equal(doctor.Warn, doctor.CheckSkew("peer", now.Add(-time.Minute), now).Status, "A minute apart should warn")
+		assert.Equal(doctor.Fail, doctor.CheckSkew("peer", now.Add(clock.MaxSkew+time.Minute), now).Status, "Past MaxSkew should fail")
+
+		section.Success("Clocks checked")
+	})
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		section := logger.Section("Bootstrap")
+
+		section.Step(1, "A compatible seed passes")
+		node := fixtures.CreateTestNode(8080, "seed")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		token := ""
+		seed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			kademlia.NodeInfoHandler(w, r, node, routingTable, storage, clock.Now())
+		}))
+		defer seed.Close()
+		cfg := config.Default()
+		cfg.Bootstrap = strings.TrimPrefix(seed.URL, "http://")
+		results := doctor.CheckBootstrap(context.Background(), cfg)
+		assert.Equal(2, len(results), "Seed and its clock should be checked")
+		assert.Equal(doctor.OK, results[0].Status, "Seed should pass: %s", results[0].Detail)
+		assert.Equal(doctor.OK, results[1].Status, "Seed clock should pass: %s", results[1].Detail)
+
+		section.Step(2, "A differing k warns")
+		cfg.K = routingTable.BucketSize() + 1
+		results = doctor.CheckBootstrap(context.Background(), cfg)
+		assert.Equal(doctor.Warn, results[0].Status, "A differing k should warn")
+		cfg.K = routingTable.BucketSize()
+
+		section.Step(3, "A seed requiring a token fails with a hint")
+		token = "secret"
+		results = doctor.CheckBootstrap(context.Background(), cfg)
+		assert.Equal(doctor.Fail, results[0].Status, "An unauthorized request should fail")
+		assert.Contains(results[0].Hint, "KADEMLIA_AUTH_TOKEN", "Hint should name the token variable")
+
+		section.Step(4, "An unreachable seed fails")
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+		cfg.Bootstrap = "127.0.0.1:" + strconv.Itoa(port)
+		results = doctor.CheckBootstrap(context.Background(), cfg)
+		assert.Equal(doctor.Fail, results[0].Status, "A closed port should fail")
+		assert.Contains(results[0].Hint, "nothing listens", "Hint should say nothing listens")
+
+		section.Success("Bootstrap nodes checked")
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		section := logger.Section("Report")
+
+		section.Step(1, "Failures are counted and hints printed")
+		report := &doctor.Report{}
+		report.Add(doctor.Result{Check: "port", Detail: "free"}, doctor.Result{Check: "clock", Status: doctor.Warn, Detail: "drifting", Hint: "use NTP"})
+		assert.False(report.Failed(), "Warnings alone should not fail")
+		report.Add(doctor.Result{Check: "bootstrap", Status: doctor.Fail, Detail: "refused"})
+		assert.True(report.Failed(), "A failed check should fail the report")
+		var out bytes.Buffer
+		assert.NoError(report.Write(&out), "Report should be written")
+		assert.Contains(out.String(), "[FAIL] bootstrap", "Failures should be marked")
+		assert.Contains(out.String(), "-> use NTP", "Hints should be printed")
+		assert.Contains(out.String(), "3 checks, 1 warnings, 1 failures", "Summary should count outcomes")
+
+		section.Success("Reports written")
+	})
+}
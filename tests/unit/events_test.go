@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestEventBus tests the node event bus and its emission points
+func TestEventBus(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "EVENTS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting event bus tests")
+
+	t.Run("PeerEvents", func(t *testing.T) {
+		section := logger.Section("Peer Events")
+
+		originalK := constants.GetK()
+		constants.SetK(1)
+		defer constants.SetK(originalK)
+
+		section.Step(1, "Subscribe to peer events")
+		bus := models.NewEventBus()
+		events, cancel := bus.Subscribe(10, models.PeerAdded, models.PeerEvicted)
+		defer cancel()
+
+		local := "0000000000000000000000000000000000000000"
+		routingTable := kademlia.NewRoutingTable(local)
+		routingTable.Events = bus
+
+		section.Step(2, "Fill a bucket past capacity")
+		first := &models.Node{ID: "ff00000000000000000000000000000000000000"}
+		second := &models.Node{ID: "ff00000000000000000000000000000000000001"}
+		kademlia.AddNodeToRoutingTable(routingTable, first, local)
+		kademlia.AddNodeToRoutingTable(routingTable, second, local)
+
+		section.Step(3, "Verify emitted events")
+		var got []models.EventType
+		for i := 0; i < 3; i++ {
+			e := <-events
+			got = append(got, e.Type)
+		}
+		assert.Equal(models.PeerAdded, got[0], "First insert should emit PeerAdded")
+		assert.Equal(models.PeerEvicted, got[1], "Full bucket should emit PeerEvicted")
+		assert.Equal(models.PeerAdded, got[2], "Replacement should emit PeerAdded")
+
+		section.Success("Peer events emitted")
+	})
+
+	t.Run("ValueEvents", func(t *testing.T) {
+		section := logger.Section("Value Events")
+
+		bus := models.NewEventBus()
+		var seen []models.Event
+		remove := bus.On(func(e models.Event) { seen = append(seen, e) }, models.ValueStored, models.ValueExpired)
+		defer remove()
+
+		storage := kademlia.NewKeyValueStore()
+		storage.Events = bus
+		key := fixtures.GenerateValidHexID("value")
+
+		section.Step(1, "Store and expire a value")
+		storage.Set(key, "v")
+		time.Sleep(time.Millisecond)
+		kademlia.NewGarbageCollector(storage, key, kademlia.GCConfig{TTL: time.Nanosecond}).Collect()
+
+		assert.Equal(2, len(seen), "Should see stored and expired events")
+		assert.Equal(models.ValueStored, seen[0].Type, "First event should be ValueStored")
+		assert.Equal(models.ValueExpired, seen[1].Type, "Second event should be ValueExpired")
+		assert.Equal("v", seen[1].Value, "Expired event should carry the value")
+
+		section.Step(2, "Removed callbacks receive nothing")
+		remove()
+		storage.Set(key, "v2")
+		assert.Equal(2, len(seen), "Removed callback should not be called")
+
+		section.Success("Value events emitted")
+	})
+
+	t.Run("LookupCompleted", func(t *testing.T) {
+		section := logger.Section("Lookup Completed")
+
+		contacts := fixtures.CreateTestNodes(2, 9000)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(contacts)
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		peer := &models.Node{ID: fixtures.GenerateValidHexID("peer"), IP: "127.0.0.1", Port: port}
+
+		bus := models.NewEventBus()
+		events, cancel := bus.Subscribe(1, models.LookupCompleted)
+		defer cancel()
+
+		target := fixtures.GenerateValidHexID("target")
+		kademlia.FanOutFindNode(kademlia.WithEvents(context.Background(), bus), []*models.Node{peer}, target)
+
+		e := <-events
+		assert.Equal(target, e.Key, "Event should carry the lookup target")
+		assert.Equal(2, len(e.Nodes), "Event should carry the lookup result")
+
+		section.Success("Lookup completion reported")
+	})
+
+	t.Run("FullChannelDropsEvents", func(t *testing.T) {
+		section := logger.Section("Full Channel Drops Events")
+
+		bus := models.NewEventBus()
+		_, cancel := bus.Subscribe(0)
+		defer cancel()
+
+		bus.Emit(models.Event{Type: models.ValueStored})
+		assert.Equal(int64(1), bus.Dropped(), "Unread events should be dropped, not block")
+
+		var nilBus *models.EventBus
+		nilBus.Emit(models.Event{Type: models.ValueStored})
+
+		section.Success("Emit never blocks")
+	})
+}
@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
 	"github.com/Aradhya2708/kademlia/pkg/models"
 	"github.com/Aradhya2708/kademlia/tests/testutils"
 )
@@ -221,6 +223,63 @@ func TestHandlerIntegration(t *testing.T) {
 		section.Success("Store and find value integration working correctly")
 	})
 
+	t.Run("FindValueMeta", func(t *testing.T) {
+		section := logger.Section("Find Value Meta")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		node := fixtures.CreateTestNode(8080, "meta")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		storage := kademlia.NewKeyValueStore()
+		testKey := fixtures.GenerateValidHexID("meta")
+
+		store := func(value string) {
+			body, _ := json.Marshal(kademlia.StoreRequest{Key: testKey, Value: value, Publisher: "publisher1", Hops: 1})
+			rr := httptest.NewRecorder()
+			kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", bytes.NewReader(body)), node, storage, routingTable)
+			assert.Equal(http.StatusCreated, rr.Code, "Store should succeed")
+		}
+		findMeta := func() kademlia.FindValueReply {
+			rr := httptest.NewRecorder()
+			kademlia.FindValueHandler(rr, httptest.NewRequest("GET", "/find_value?meta=true&key="+testKey, nil), node, storage, routingTable)
+			var reply kademlia.FindValueReply
+			assert.NoError(json.Unmarshal(rr.Body.Bytes(), &reply), "Reply should carry the value and its meta")
+			return reply
+		}
+
+		section.Step(1, "A forwarded STORE records its publisher and hops")
+		stored := fake.Now()
+		store("v1")
+		reply := findMeta()
+		assert.Equal("v1", reply.Value, "Value should be returned")
+		assert.Equal("publisher1", reply.Meta.Publisher, "Publisher should be recorded")
+		assert.Equal(2, reply.Meta.Hops, "Hops should count this STORE")
+		assert.True(reply.Meta.StoredAt.Equal(stored), "Store time should be recorded")
+
+		section.Step(2, "Republishing keeps the original store time")
+		fake.Advance(time.Hour)
+		store("v1")
+		reply = findMeta()
+		assert.True(reply.Meta.StoredAt.Equal(stored), "Original store time should be kept")
+		assert.True(reply.Meta.RepublishedAt.Equal(fake.Now()), "Republish time should advance")
+
+		section.Step(3, "A new value starts a new history")
+		fake.Advance(time.Hour)
+		store("v2")
+		reply = findMeta()
+		assert.True(reply.Meta.StoredAt.Equal(fake.Now()), "New value should reset the store time")
+
+		section.Step(4, "Without meta the reply is the bare value")
+		rr := httptest.NewRecorder()
+		kademlia.FindValueHandler(rr, httptest.NewRequest("GET", "/find_value?key="+testKey, nil), node, storage, routingTable)
+		var value string
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &value), "Plain reply should be a string")
+
+		section.Success("Provenance stored and returned")
+	})
+
 	t.Run("CompleteWorkflow", func(t *testing.T) {
 		section := logger.Section("Complete Workflow")
 
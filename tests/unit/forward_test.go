@@ -0,0 +1,198 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestForwardQueue tests queueing replica STOREs for peers that are down
+func TestForwardQueue(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "FORWARD")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting store-and-forward tests")
+
+	// replicaServer serves a replica whose /store answers status instead
+	// while it is set
+	replicaServer := func(status *atomic.Int32) (*models.Node, *models.KeyValueStore, func()) {
+		replica := &models.Node{ID: fixtures.GenerateValidHexID("replica"), IP: "127.0.0.1"}
+		table := kademlia.NewRoutingTable(replica.ID)
+		kademlia.AddNodeToRoutingTable(table, replica, replica.ID)
+		storage := kademlia.NewKeyValueStore()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, replica, table)
+		})
+		mux.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+			if code := status.Load(); code != 0 {
+				http.Error(w, "unavailable", int(code))
+				return
+			}
+			kademlia.StoreHandler(w, r, replica, storage, table)
+		})
+		server := httptest.NewServer(mux)
+		addr := strings.TrimPrefix(server.URL, "http://")
+		replica.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		return replica, storage, server.Close
+	}
+
+	t.Run("Queue", func(t *testing.T) {
+		section := logger.Section("Queueing Failed Replicas")
+
+		var status atomic.Int32
+		status.Store(http.StatusServiceUnavailable)
+		replica, replicaStorage, stop := replicaServer(&status)
+		defer stop()
+
+		self := fixtures.CreateTestNode(8080, "writer")
+		self.Flags = models.FlagRelay
+		table := kademlia.NewRoutingTable(self.ID)
+		table.Events = models.NewEventBus()
+		kademlia.AddNodeToRoutingTable(table, replica, self.ID)
+		queue := kademlia.NewForwardQueue(table, time.Hour, time.Hour)
+		ctx := kademlia.WithForwardQueue(retry.WithPolicy(context.Background(), retry.Policy{Attempts: 1}), queue)
+
+		section.Step(1, "A STORE to a replica that is down is queued")
+		key := fixtures.GenerateValidHexID("forwarded")
+		ack := kademlia.IterativeStore(ctx, table, self, kademlia.NewKeyValueStore(), kademlia.StoreRequest{Key: key, Value: "first"})
+		assert.Equal(0, ack.ReplicationFactor, "Replica should not store the value")
+		assert.Equal(1, ack.Queued, "Failed replica should be queued")
+		assert.Equal(1, queue.Pending(replica.ID), "One write should wait for the replica")
+
+		section.Step(2, "A later write of the key replaces the queued one")
+		kademlia.IterativeStore(ctx, table, self, kademlia.NewKeyValueStore(), kademlia.StoreRequest{Key: key, Value: "second"})
+		assert.Equal(1, queue.Pending(replica.ID), "Only the latest write should be kept")
+
+		section.Step(3, "The write is delivered when the replica re-enters the table")
+		runCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go queue.Watch(runCtx)
+		status.Store(0)
+		deadline := time.Now().Add(5 * time.Second)
+		for queue.Pending(replica.ID) > 0 && time.Now().Before(deadline) {
+			table.Events.Emit(models.Event{Type: models.PeerAdded, Peer: replica})
+			time.Sleep(20 * time.Millisecond)
+		}
+		value, found := replicaStorage.Get(key)
+		assert.True(found, "Replica should store the queued write")
+		assert.Equal("second", value, "Latest write should be delivered")
+		stats := queue.Stats()
+		assert.Equal(uint64(1), stats.Delivered, "Delivery should be counted")
+		assert.Equal(0, stats.Queued, "Nothing should be left queued")
+
+		section.Step(4, "Queued writes are retried by the scheduler")
+		retried := fixtures.GenerateValidHexID("retried")
+		assert.True(queue.Enqueue(replica, kademlia.StoreRequest{Key: retried, Value: "retried"}), "Write should be queued")
+		scheduler := kademlia.NewScheduler(kademlia.SchedulerConfig{})
+		scheduler.Add(kademlia.Job{Name: "forward", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) time.Duration {
+			queue.Flush(ctx)
+			return 0
+		}})
+		go scheduler.Start(runCtx)
+		deadline = time.Now().Add(5 * time.Second)
+		for queue.Pending(replica.ID) > 0 && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		value, found = replicaStorage.Get(retried)
+		assert.True(found && value == "retried", "Replica should store the retried write")
+		assert.Equal(uint64(2), queue.Stats().Delivered, "Retry should be counted")
+
+		section.Success("Failed replicas queued and delivered correctly")
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		section := logger.Section("Refused Writes")
+
+		var status atomic.Int32
+		status.Store(http.StatusForbidden)
+		replica, _, stop := replicaServer(&status)
+		defer stop()
+
+		self := fixtures.CreateTestNode(8080, "writer")
+		self.Flags = models.FlagRelay
+		table := kademlia.NewRoutingTable(self.ID)
+		kademlia.AddNodeToRoutingTable(table, replica, self.ID)
+		queue := kademlia.NewForwardQueue(table, time.Hour, time.Hour)
+		ctx := kademlia.WithForwardQueue(context.Background(), queue)
+
+		section.Step(1, "STOREs a reachable replica refuses are not queued")
+		ack := kademlia.IterativeStore(ctx, table, self, kademlia.NewKeyValueStore(), kademlia.StoreRequest{Key: fixtures.GenerateValidHexID("refused"), Value: "x"})
+		assert.Equal(1, ack.Failed, "Replica should refuse the write")
+		assert.Equal(0, ack.Queued, "Refused write should not be queued")
+
+		section.Step(2, "Queued writes the returning replica refuses are dropped")
+		assert.True(queue.Enqueue(replica, kademlia.StoreRequest{Key: fixtures.GenerateValidHexID("queued"), Value: "x"}), "Write should be queued")
+		assert.Equal(0, queue.Deliver(context.Background(), replica), "Refused write should not be delivered")
+		assert.Equal(0, queue.Pending(replica.ID), "Refused write should be dropped")
+		assert.Equal(uint64(1), queue.Stats().Rejected, "Refusal should be counted")
+
+		section.Success("Refused writes dropped correctly")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		section := logger.Section("Queue Expiry")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		node := fixtures.CreateTestNode(8080, "writer")
+		queue := kademlia.NewForwardQueue(kademlia.NewRoutingTable(node.ID), time.Hour, time.Minute)
+		peer := fixtures.CreateTestNode(8081, "gone")
+
+		section.Step(1, "Writes older than the TTL expire")
+		queue.Enqueue(peer, kademlia.StoreRequest{Key: fixtures.GenerateValidHexID("old"), Value: "x"})
+		stats := queue.Stats()
+		assert.Equal(1, len(stats.Peers), "Peer should be listed")
+		if len(stats.Peers) == 1 {
+			assert.Equal(peer.ID, stats.Peers[0].ID, "Listed peer should be the one written to")
+			assert.Equal(1, stats.Peers[0].Writes, "One write should wait")
+		}
+		fake.Advance(time.Hour + time.Second)
+		stats = queue.Stats()
+		assert.Equal(0, stats.Queued, "Expired write should be dropped")
+		assert.Equal(uint64(1), stats.Expired, "Expiry should be counted")
+
+		section.Step(2, "A disabled queue queues nothing")
+		var none *kademlia.ForwardQueue
+		assert.False(none.Enqueue(peer, kademlia.StoreRequest{Key: fixtures.GenerateValidHexID("none"), Value: "x"}), "Nil queue should not queue")
+
+		section.Success("Queue expiry working correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Forward Queue Configuration")
+
+		section.Step(1, "Settings are validated")
+		cfg := config.Default()
+		cfg.ForwardTTL = -time.Second
+		assert.HasError(cfg.Validate(), "Negative TTL should be refused")
+		cfg.ForwardTTL, cfg.ForwardRetryInterval = time.Hour, 0
+		assert.HasError(cfg.Validate(), "Queue without a retry interval should be refused")
+
+		section.Step(2, "Nodes queue unless disabled")
+		cfg.ForwardRetryInterval = time.Minute
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		assert.True(n.Forward != nil, "Node should have a queue")
+		cfg.ForwardTTL = 0
+		disabled := kademlia.NewNode(cfg)
+		defer disabled.Storage.Close()
+		assert.True(disabled.Forward == nil, "Disabled queue should not be created")
+
+		section.Success("Forward queue configuration working correctly")
+	})
+}
@@ -0,0 +1,107 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestGateway tests the REST gateway serving lookups and stores for
+// clients outside the DHT
+func TestGateway(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "GATEWAY")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting gateway tests")
+	ctx := context.Background()
+
+	section := logger.Section("REST Gateway")
+
+	section.Step(1, "Start a network whose gateway node requires an API key")
+	network.SetAuthToken("network-secret")
+	defer network.SetAuthToken("")
+	cfg := config.Default()
+	cfg.Port = 0
+	cfg.Server.AuthToken = "network-secret"
+	seed := kademlia.NewNode(cfg)
+	assert.NoError(seed.Start(ctx), "Seed should start")
+	defer seed.Stop()
+
+	gatewayCfg := config.Default()
+	gatewayCfg.Port = 0
+	gatewayCfg.Bootstrap = seed.Addr()
+	gatewayCfg.Server.AuthToken = "network-secret"
+	gatewayCfg.Server.Gateway = true
+	gatewayCfg.Server.GatewayAPIKeys = []string{"key-1", "key-2"}
+	gateway := kademlia.NewNode(gatewayCfg)
+	assert.NoError(gateway.Start(ctx), "Gateway should start")
+	defer gateway.Stop()
+
+	call := func(method, path, apiKey, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, "http://"+gateway.Addr()+path, strings.NewReader(body))
+		if apiKey != "" {
+			req.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(err, "%s %s should be answered", method, path) {
+			return &http.Response{}, ""
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	section.Step(2, "Requests without a valid API key are refused")
+	resp, _ := call("GET", "/v1/keys/user:42", "", "")
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode, "Missing API key should be refused")
+	resp, _ = call("GET", "/v1/keys/user:42", "wrong", "")
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode, "Wrong API key should be refused")
+
+	section.Step(3, "PUT stores the value across the network")
+	resp, body := call("PUT", "/v1/keys/user:42", "key-2", "alice")
+	assert.Equal(http.StatusCreated, resp.StatusCode, "PUT should store the value")
+	var ack kademlia.StoreAck
+	json.Unmarshal([]byte(body), &ack)
+	assert.Equal(kademlia.KeyFromString("user:42"), ack.Key, "Path keys should be hashed")
+	assert.Equal(ack.Key, resp.Header.Get("X-Kademlia-Key"), "Key header should carry the hashed key")
+	assert.True(ack.ReplicationFactor >= 2, "Both nodes should store the value, got %d", ack.ReplicationFactor)
+	value, found, err := seed.Get(ctx, kademlia.KeyFromString("user:42"))
+	assert.NoError(err, "DHT lookup should succeed")
+	assert.True(found && value == "alice", "Value should be stored on the network")
+
+	section.Step(4, "GET runs a lookup across the network")
+	raw := fixtures.GenerateValidHexID("remote")
+	seed.Storage.Set(raw, "held elsewhere")
+	resp, body = call("GET", "/v1/keys/"+raw+"?hash=false", "key-1", "")
+	assert.Equal(http.StatusOK, resp.StatusCode, "GET should find the remote value")
+	assert.Equal("held elsewhere", body, "Value should be returned as is")
+	resp, body = call("GET", "/v1/keys/user:42", "key-1", "")
+	assert.Equal("alice", body, "Stored value should be returned")
+
+	section.Step(5, "Misses, bad keys and other methods are reported")
+	resp, _ = call("GET", "/v1/keys/nobody", "key-1", "")
+	assert.Equal(http.StatusNotFound, resp.StatusCode, "Missing keys should be 404")
+	resp, _ = call("GET", "/v1/keys/user:42?hash=false", "key-1", "")
+	assert.Equal(http.StatusBadRequest, resp.StatusCode, "Unhashed application keys should be refused")
+	resp, _ = call("PUT", "/v1/keys/empty", "key-1", "")
+	assert.Equal(http.StatusBadRequest, resp.StatusCode, "Empty values should be refused")
+	resp, _ = call("DELETE", "/v1/keys/user:42", "key-1", "")
+	assert.Equal(http.StatusMethodNotAllowed, resp.StatusCode, "Other methods should be refused")
+	assert.Equal("GET, PUT", resp.Header.Get("Allow"), "Allowed methods should be listed")
+
+	section.Step(6, "DHT RPCs still require the network token")
+	resp, _ = call("GET", "/find_node?id="+raw, "key-1", "")
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode, "API keys should not open the RPCs")
+
+	section.Success("Gateway served REST clients")
+}
@@ -0,0 +1,134 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestGarbageCollection tests storage eviction by age and budget
+func TestGarbageCollection(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "GC")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting garbage collection tests")
+
+	t.Run("TTLExpiry", func(t *testing.T) {
+		section := logger.Section("TTL Expiry")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("old")
+		storage.Set(key, "value")
+
+		var archived []string
+		gc := kademlia.NewGarbageCollector(storage, fixtures.GenerateValidHexID("local"), kademlia.GCConfig{
+			TTL:     time.Nanosecond,
+			OnEvict: func(k, v, reason string) { archived = append(archived, k+"="+v+":"+reason) },
+		})
+
+		fake.Advance(time.Millisecond)
+		assert.Equal(1, gc.Collect(), "Expired entry should be evicted")
+		_, exists := storage.Get(key)
+		assert.False(exists, "Expired entry should be gone")
+		assert.Equal(1, len(archived), "OnEvict should be called once")
+		assert.Contains(archived[0], "value:expired", "OnEvict should receive value and reason")
+		assert.Equal(int64(0), storage.SizeBytes(), "Size should drop to zero")
+
+		section.Success("Expired entries evicted")
+	})
+
+	t.Run("TombstoneExpiry", func(t *testing.T) {
+		section := logger.Section("Tombstone Expiry")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("deleted")
+		storage.Set(key, "value")
+		storage.Tombstone(key, clock.Now())
+		gc := kademlia.NewGarbageCollector(storage, fixtures.GenerateValidHexID("local"), kademlia.GCConfig{TombstoneTTL: time.Hour})
+
+		fake.Advance(time.Minute)
+		gc.Collect()
+		_, deleted := storage.DeletedAt(key)
+		assert.True(deleted, "Tombstone should be kept within the window")
+
+		fake.Advance(time.Hour)
+		gc.Collect()
+		_, deleted = storage.DeletedAt(key)
+		assert.False(deleted, "Tombstone should be dropped after the window")
+		assert.NoError(storage.Set(key, "value"), "Replica copies should be accepted again")
+
+		section.Success("Tombstones garbage collected")
+	})
+
+	t.Run("LRUUnderPressure", func(t *testing.T) {
+		section := logger.Section("LRU Under Pressure")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		storage := kademlia.NewKeyValueStore()
+		keys := []string{
+			fixtures.GenerateValidHexID("a"),
+			fixtures.GenerateValidHexID("b"),
+			fixtures.GenerateValidHexID("c"),
+		}
+		for _, k := range keys {
+			storage.Set(k, strings.Repeat("x", 10))
+			fake.Advance(time.Millisecond)
+		}
+
+		section.Step(1, "Touch the oldest key so it becomes most recently used")
+		storage.Get(keys[0])
+
+		section.Step(2, "Shrink the budget to two entries")
+		entrySize := int64(40 + 10)
+		gc := kademlia.NewGarbageCollector(storage, fixtures.GenerateValidHexID("local"), kademlia.GCConfig{
+			Strategy: kademlia.EvictLRU,
+			MaxBytes: 2 * entrySize,
+		})
+		assert.Equal(1, gc.Collect(), "One entry should be evicted")
+
+		_, exists := storage.Get(keys[1])
+		assert.False(exists, "Least recently used entry should be evicted")
+		_, exists = storage.Get(keys[0])
+		assert.True(exists, "Recently read entry should survive")
+
+		section.Success("LRU eviction working correctly")
+	})
+
+	t.Run("DistanceUnderPressure", func(t *testing.T) {
+		section := logger.Section("Distance Under Pressure")
+
+		localID := "0000000000000000000000000000000000000000"
+		near := "0000000000000000000000000000000000000001"
+		far := "ffffffffffffffffffffffffffffffffffffffff"
+
+		storage := kademlia.NewKeyValueStore()
+		storage.Set(far, "v")
+		storage.Set(near, "v")
+
+		gc := kademlia.NewGarbageCollector(storage, localID, kademlia.GCConfig{
+			Strategy: kademlia.EvictDistance,
+			MaxBytes: 41,
+		})
+		assert.Equal(1, gc.Collect(), "One entry should be evicted")
+
+		_, exists := storage.Get(far)
+		assert.False(exists, "Farthest entry should be evicted")
+		_, exists = storage.Get(near)
+		assert.True(exists, "Nearest entry should survive")
+
+		section.Success("Distance-based eviction working correctly")
+	})
+}
@@ -3,11 +3,13 @@ package unit
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
 	"github.com/Aradhya2708/kademlia/tests/testutils"
 )
 
@@ -69,7 +71,7 @@ func TestPingHandler(t *testing.T) {
 		assert.Equal(http.StatusOK, rr.Code, "Should return 200 OK")
 
 		section.Step(5, "Verify routing table update")
-		closestNodes := kademlia.FindClosestNodes(routingTable, pingerID, node.ID)
+		closestNodes := kademlia.FindClosestNodes(routingTable, pingerID, node.ID, 0)
 		found := false
 		for _, foundNode := range closestNodes {
 			if foundNode.ID == pingerID {
@@ -108,6 +110,110 @@ func TestPingHandler(t *testing.T) {
 
 		section.Success("Invalid ports properly rejected")
 	})
+
+	t.Run("PingWithJSONBody", func(t *testing.T) {
+		section := logger.Section("Ping with JSON Body")
+
+		node := fixtures.CreateTestNode(8080, "test")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		ping := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/ping", bytes.NewBufferString(body))
+			req.RemoteAddr = "192.0.2.1:12345"
+			rr := httptest.NewRecorder()
+			kademlia.PingHandler(rr, req, node, storage, routingTable)
+			return rr
+		}
+		contact := func(id string) *models.Node {
+			for _, n := range kademlia.FindClosestNodes(routingTable, id, node.ID, 0) {
+				if n.ID == id {
+					return n
+				}
+			}
+			return nil
+		}
+
+		section.Step(1, "Advertised IP is used instead of the connection's")
+		natted := fixtures.GenerateValidHexID("natted")
+		rr := ping(`{"ID":"` + natted + `","IP":"203.0.113.7","Port":9000}`)
+		assert.Equal(http.StatusOK, rr.Code, "Valid ping should succeed")
+		if n := contact(natted); assert.True(n != nil, "Pinger should be added") {
+			assert.Equal("203.0.113.7", n.IP, "Advertised IP should be stored")
+			assert.Equal(9000, n.Port, "Advertised port should be stored")
+		}
+
+		section.Step(2, "A missing or unspecified IP falls back to the connection's")
+		unspecified := fixtures.GenerateValidHexID("unspecified")
+		ping(`{"ID":"` + unspecified + `","IP":"0.0.0.0","Port":9001}`)
+		if n := contact(unspecified); assert.True(n != nil, "Pinger should be added") {
+			assert.Equal("192.0.2.1", n.IP, "Connection IP should be stored")
+		}
+
+		section.Step(3, "Invalid senders are rejected")
+		valid := fixtures.GenerateValidHexID("invalid")
+		for _, body := range []string{
+			`{"ID":"xyz","Port":9000}`,
+			`{"ID":"` + valid + `","Port":0}`,
+			`{"ID":"` + valid + `","IP":"not-an-ip","Port":9000}`,
+			`{"ID":"` + valid + `","IP":"224.0.0.1","Port":9000}`,
+			`not json`,
+		} {
+			assert.Equal(http.StatusBadRequest, ping(body).Code, "Should reject "+body)
+		}
+		assert.True(contact(valid) == nil, "Rejected pingers should not be added")
+
+		section.Success("JSON pings validated and applied")
+	})
+
+	t.Run("PingDialBack", func(t *testing.T) {
+		section := logger.Section("Ping with Dial-Back")
+		node := fixtures.CreateTestNode(8080, "test")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		routingTable.DialBack = true
+		storage := kademlia.NewKeyValueStore()
+		ping := func(id string, port int) int {
+			body := fmt.Sprintf(`{"ID":"%s","IP":"127.0.0.1","Port":%d}`, id, port)
+			req := httptest.NewRequest("POST", "/ping", bytes.NewBufferString(body))
+			rr := httptest.NewRecorder()
+			kademlia.PingHandler(rr, req, node, storage, routingTable)
+			return rr.Code
+		}
+		known := func(id string) bool {
+			for _, n := range kademlia.FindClosestNodes(routingTable, id, node.ID, 0) {
+				if n.ID == id {
+					return true
+				}
+			}
+			return false
+		}
+
+		section.Step(1, "A pinger answering at its address is added")
+		peer := startFilterPeer(fixtures.GenerateValidHexID("reachable"))
+		defer peer.server.Close()
+		assert.Equal(http.StatusOK, ping(peer.node.ID, peer.node.Port), "Ping should be answered")
+		assert.True(known(peer.node.ID), "Verified pinger should be added")
+
+		section.Step(2, "A pinger claiming another node's address is not")
+		spoofed := fixtures.GenerateValidHexID("spoofed")
+		assert.Equal(http.StatusOK, ping(spoofed, peer.node.Port), "Ping should still be answered")
+		assert.False(known(spoofed), "Pinger answered for by another ID should not be added")
+
+		section.Step(3, "An unreachable pinger is not")
+		closed := startFilterPeer(fixtures.GenerateValidHexID("closed"))
+		closed.server.Close()
+		assert.Equal(http.StatusOK, ping(closed.node.ID, closed.node.Port), "Ping should still be answered")
+		assert.False(known(closed.node.ID), "Unreachable pinger should not be added")
+
+		section.Step(4, "A known contact moving to an unreachable address keeps its old one")
+		assert.Equal(http.StatusOK, ping(peer.node.ID, closed.node.Port), "Ping should be answered")
+		for _, n := range kademlia.FindClosestNodes(routingTable, peer.node.ID, node.ID, 0) {
+			if n.ID == peer.node.ID {
+				assert.Equal(peer.node.Port, n.Port, "Unverified address should not replace the known one")
+			}
+		}
+
+		section.Success("Pingers verified before they are added")
+	})
 }
 
 // TestFindNodeHandler tests the find_node handler
@@ -193,6 +299,108 @@ func TestFindNodeHandler(t *testing.T) {
 
 		section.Success("Missing ID properly handled")
 	})
+
+	t.Run("FindNodeExcludesRequester", func(t *testing.T) {
+		section := logger.Section("Find Node Excludes Requester")
+
+		section.Step(1, "Setup a responder that knows itself, the requester and others")
+		node := fixtures.CreateTestNode(8080, "test")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		testNodes := fixtures.CreateTestNodes(3, 8081)
+		for _, testNode := range testNodes {
+			kademlia.AddNodeToRoutingTable(routingTable, testNode, node.ID)
+		}
+		requester := testNodes[0]
+
+		find := func(req *http.Request) []*models.Node {
+			rr := httptest.NewRecorder()
+			kademlia.FindNodeHandler(rr, req, node, routingTable)
+			assert.Equal(http.StatusOK, rr.Code, "Should return 200 OK")
+			var nodes []*models.Node
+			json.Unmarshal(rr.Body.Bytes(), &nodes)
+			return nodes
+		}
+		ids := func(nodes []*models.Node) map[string]bool {
+			set := make(map[string]bool)
+			for _, n := range nodes {
+				set[n.ID] = true
+			}
+			return set
+		}
+
+		section.Step(2, "Plain queries get every contact")
+		req, _ := http.NewRequest("GET", "/find_node?id="+requester.ID, nil)
+		found := ids(find(req))
+		assert.Equal(4, len(found), "All contacts should be returned")
+		assert.True(found[node.ID] && found[requester.ID], "Responder and requester should be included")
+
+		section.Step(3, "The requester header leaves out both parties")
+		req, _ = http.NewRequest("GET", "/find_node?id="+requester.ID, nil)
+		req.Header.Set(kademlia.RequesterIDHeader, requester.ID)
+		found = ids(find(req))
+		assert.Equal(2, len(found), "Only the other contacts should be returned")
+		assert.False(found[node.ID] || found[requester.ID], "Responder and requester should be left out")
+
+		section.Step(4, "The requester query parameter does the same")
+		req, _ = http.NewRequest("GET", "/find_node?id="+requester.ID+"&requester="+requester.ID, nil)
+		found = ids(find(req))
+		assert.False(found[node.ID] || found[requester.ID], "Responder and requester should be left out")
+
+		section.Step(5, "An invalid requester ID is rejected")
+		req, _ = http.NewRequest("GET", "/find_node?id="+requester.ID+"&requester=xyz", nil)
+		rr := httptest.NewRecorder()
+		kademlia.FindNodeHandler(rr, req, node, routingTable)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Should return 400 for an invalid requester")
+
+		section.Success("Requester excluded from find_node replies")
+	})
+
+	t.Run("FindNodeCount", func(t *testing.T) {
+		section := logger.Section("Find Node Count")
+
+		section.Step(1, "Setup a responder with k=4 and six contacts")
+		node := fixtures.CreateTestNode(8080, "test")
+		routingTable := kademlia.NewRoutingTableWithK(node.ID, 4)
+		for _, testNode := range fixtures.CreateTestNodes(6, 8081) {
+			kademlia.AddNodeToRoutingTable(routingTable, testNode, node.ID)
+		}
+		storage := kademlia.NewKeyValueStore()
+		queryID := fixtures.GenerateValidHexID("query")
+
+		find := func(path string) (int, int) {
+			req, _ := http.NewRequest("GET", path, nil)
+			rr := httptest.NewRecorder()
+			if path[:10] == "/find_node" {
+				kademlia.FindNodeHandler(rr, req, node, routingTable)
+			} else {
+				kademlia.FindValueHandler(rr, req, node, storage, routingTable)
+			}
+			var nodes []*models.Node
+			json.Unmarshal(rr.Body.Bytes(), &nodes)
+			return rr.Code, len(nodes)
+		}
+
+		section.Step(2, "Counts up to k are honoured")
+		_, n := find("/find_node?id=" + queryID)
+		assert.Equal(4, n, "Default should be k contacts")
+		_, n = find("/find_node?id=" + queryID + "&count=2")
+		assert.Equal(2, n, "Light clients should get fewer contacts")
+		_, n = find("/find_value?key=" + queryID + "&count=1")
+		assert.Equal(1, n, "find_value misses should honour count")
+
+		section.Step(3, "Larger counts are capped at k")
+		_, n = find("/find_node?id=" + queryID + "&count=50")
+		assert.Equal(4, n, "Count should be capped at k")
+
+		section.Step(4, "Invalid counts are rejected")
+		for _, count := range []string{"0", "-1", "abc"} {
+			code, _ := find("/find_node?id=" + queryID + "&count=" + count)
+			assert.Equal(http.StatusBadRequest, code, "Should return 400 for count %s", count)
+		}
+
+		section.Success("find_node count applied")
+	})
 }
 
 // TestStoreHandler tests the store handler
@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestInsertLimit tests limiting the node IDs one IP adds to the routing table
+func TestInsertLimit(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "INSERT_LIMIT")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting insert limit tests")
+
+	inTable := func(table *models.RoutingTable, localID, id string) bool {
+		for _, n := range kademlia.FindClosestNodes(table, id, localID, 0) {
+			if n.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("RoutingTable", func(t *testing.T) {
+		section := logger.Section("Routing Table Insertion")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		node := fixtures.CreateTestNode(8080, "local")
+		table := kademlia.NewRoutingTable(node.ID)
+		table.Peers.SetInsertLimit(2, time.Hour)
+		sybil := func(name string) *models.Node {
+			return &models.Node{ID: fixtures.GenerateValidHexID(name), IP: "10.0.0.66", Port: 9000}
+		}
+
+		section.Step(1, "An IP adds IDs up to the limit")
+		first, second, third := sybil("sybil-1"), sybil("sybil-2"), sybil("sybil-3")
+		for _, n := range []*models.Node{first, second, third} {
+			kademlia.AddNodeToRoutingTable(table, n, node.ID)
+		}
+		assert.True(inTable(table, node.ID, first.ID), "First ID should be added")
+		assert.True(inTable(table, node.ID, second.ID), "Second ID should be added")
+		assert.False(inTable(table, node.ID, third.ID), "Third ID should be refused")
+
+		section.Step(2, "Known IDs are refreshed")
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: first.ID, IP: "10.0.0.66", Port: 9000, Flags: models.FlagStorage}, node.ID)
+		assert.Equal(models.FlagStorage, first.Flags, "Known ID should take its new capabilities")
+
+		section.Step(3, "Other IPs, pinned peers and the node itself are not limited")
+		other := &models.Node{ID: fixtures.GenerateValidHexID("honest"), IP: "10.0.0.7", Port: 9000}
+		kademlia.AddNodeToRoutingTable(table, other, node.ID)
+		assert.True(inTable(table, node.ID, other.ID), "Another IP's ID should be added")
+		pinned := sybil("pinned")
+		table.Pinned = map[string]bool{pinned.ID: true}
+		kademlia.AddNodeToRoutingTable(table, pinned, node.ID)
+		assert.True(inTable(table, node.ID, pinned.ID), "Pinned peer should be added")
+		self := &models.Node{ID: node.ID, IP: "10.0.0.66", Port: 8080}
+		kademlia.AddNodeToRoutingTable(table, self, node.ID)
+		assert.True(inTable(table, node.ID, node.ID), "Node itself should be added")
+
+		section.Step(4, "The IP may add IDs again once the window passes")
+		fake.Advance(time.Hour + time.Minute)
+		kademlia.AddNodeToRoutingTable(table, third, node.ID)
+		assert.True(inTable(table, node.ID, third.ID), "Third ID should be added after the window")
+
+		section.Success("Routing table insertion limited correctly")
+	})
+
+	t.Run("Relayed", func(t *testing.T) {
+		section := logger.Section("Nodes Behind NAT")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		table := kademlia.NewRoutingTable(node.ID)
+		table.Peers.SetInsertLimit(1, time.Hour)
+
+		section.Step(1, "Relayed nodes count against their relay's IP")
+		first := &models.Node{ID: fixtures.GenerateValidHexID("natted-1"), Relay: "10.0.0.9:8080"}
+		second := &models.Node{ID: fixtures.GenerateValidHexID("natted-2"), Relay: "10.0.0.9:8080"}
+		kademlia.AddNodeToRoutingTable(table, first, node.ID)
+		kademlia.AddNodeToRoutingTable(table, second, node.ID)
+		assert.True(inTable(table, node.ID, first.ID), "First relayed node should be added")
+		assert.False(inTable(table, node.ID, second.ID), "Second relayed node should be refused")
+
+		section.Success("Relayed nodes limited correctly")
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		section := logger.Section("Insert Stats")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		table := kademlia.NewRoutingTable(node.ID)
+		table.Peers.SetInsertLimit(1, time.Hour)
+		for _, name := range []string{"a", "b", "c"} {
+			kademlia.AddNodeToRoutingTable(table, &models.Node{ID: fixtures.GenerateValidHexID(name), IP: "10.0.0.66", Port: 9000}, node.ID)
+		}
+
+		section.Step(1, "/peer_store reports insertions per IP")
+		rr := httptest.NewRecorder()
+		kademlia.PeerStoreHandler(rr, httptest.NewRequest("GET", "/peer_store", nil), table)
+		assert.Equal(http.StatusOK, rr.Code, "Peer store should be listed")
+		var list kademlia.PeerStoreList
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &list), "Listing should decode")
+		assert.Equal(1, list.Inserts.Limit, "Limit should be reported")
+		assert.Equal(uint64(2), list.Inserts.Refused, "Two insertions should be refused")
+		assert.Equal(1, len(list.Inserts.Sources), "One IP should be reported")
+		if len(list.Inserts.Sources) == 1 {
+			source := list.Inserts.Sources[0]
+			assert.Equal("10.0.0.66", source.IP, "Source should be the flooding IP")
+			assert.Equal(1, source.IDs, "One ID should be counted")
+			assert.Equal(uint64(2), source.Refused, "Refusals should be counted per IP")
+		}
+
+		section.Step(2, "Without a limit nothing is refused")
+		var none *models.PeerStore
+		assert.True(none.AllowInsert("10.0.0.66", node.ID), "Nil store should allow every insertion")
+		open := models.NewPeerStore(nil)
+		for _, name := range []string{"a", "b", "c"} {
+			assert.True(open.AllowInsert("10.0.0.66", fixtures.GenerateValidHexID(name)), "Unlimited store should allow %s", name)
+		}
+
+		section.Success("Insert stats reported correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Insert Limit Configuration")
+
+		section.Step(1, "Limits are validated")
+		cfg := config.Default()
+		cfg.MaxIDsPerIP = -1
+		assert.HasError(cfg.Validate(), "Negative limit should be refused")
+		cfg.MaxIDsPerIP, cfg.IDsPerIPWindow = 4, 0
+		assert.HasError(cfg.Validate(), "Limit without a window should be refused")
+		cfg.IDsPerIPWindow = time.Minute
+		assert.NoError(cfg.Validate(), "Limit with a window should be accepted")
+
+		section.Step(2, "Nodes take the configured limit")
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		stats := n.RoutingTable.Peers.InsertStats()
+		assert.Equal(4, stats.Limit, "Node should apply the configured limit")
+		assert.Equal(time.Minute, stats.Window, "Node should apply the configured window")
+
+		section.Success("Insert limit configuration working correctly")
+	})
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestIterateKeys tests paging through stored records by XOR distance
+func TestIterateKeys(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "ITERATE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting key iteration tests")
+
+	target := "0000000000000000000000000000000000000000"
+	newStorage := func() *models.KeyValueStore {
+		storage := kademlia.NewKeyValueStore()
+		for i := 1; i <= 25; i++ {
+			storage.Set(fmt.Sprintf("%040x", i), fmt.Sprintf("v%d", i))
+		}
+		storage.Set("ffffffffffffffffffffffffffffffffffffffff", "far")
+		return storage
+	}
+
+	t.Run("PagesCoverRangeOnce", func(t *testing.T) {
+		section := logger.Section("Pages Cover Range Once")
+
+		storage := newStorage()
+		seen := make(map[string]bool)
+		token := ""
+		pages := 0
+		for {
+			page, err := kademlia.IterateKeys(storage, target, 8, 10, token)
+			assert.NoError(err, "Iteration should succeed")
+			pages++
+			for _, rec := range page.Records {
+				assert.False(seen[rec.Key], "Record should be returned once")
+				seen[rec.Key] = true
+			}
+			if page.NextToken == "" {
+				break
+			}
+			token = page.NextToken
+		}
+
+		assert.Equal(3, pages, "25 records should take three pages of 10")
+		assert.Equal(25, len(seen), "All records in range should be returned")
+		assert.False(seen["ffffffffffffffffffffffffffffffffffffffff"], "Records outside the radius should be skipped")
+
+		first, _ := kademlia.IterateKeys(storage, target, 8, 1, "")
+		assert.Equal(fmt.Sprintf("%040x", 1), first.Records[0].Key, "Closest record should come first")
+
+		_, err := kademlia.IterateKeys(storage, target, 8, 10, "!!!")
+		assert.HasError(err, "Malformed token should be rejected")
+
+		section.Success("Iteration pages through the range")
+	})
+
+	t.Run("HandlerValidation", func(t *testing.T) {
+		section := logger.Section("Handler Validation")
+
+		node := fixtures.CreateTestNode(8080, "iter")
+		for query, want := range map[string]int{
+			"target=" + target + "&radius=8&limit=5": http.StatusOK,
+			"target=xyz":                             http.StatusBadRequest,
+			"radius=161":                             http.StatusBadRequest,
+			"limit=0":                                http.StatusBadRequest,
+			"token=***":                              http.StatusBadRequest,
+		} {
+			req := httptest.NewRequest("GET", "/iterate_keys?"+query, nil)
+			rr := httptest.NewRecorder()
+			kademlia.IterateKeysHandler(rr, req, node, newStorage())
+			assert.Equal(want, rr.Code, "Unexpected status for "+query)
+		}
+
+		section.Success("Handler validates parameters")
+	})
+
+	t.Run("PullRecords", func(t *testing.T) {
+		section := logger.Section("Pull Records")
+
+		remote := &models.Node{ID: "8000000000000000000000000000000000000000"}
+		remoteStorage := newStorage()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.IterateKeysHandler(w, r, remote, remoteStorage)
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		local := kademlia.NewKeyValueStore()
+		pulled, err := kademlia.PullRecords(context.Background(), target, local, remote)
+		assert.NoError(err, "Pull should succeed")
+		assert.Equal(25, pulled, "Records closer to the new node should be pulled")
+
+		_, exists := local.Get("ffffffffffffffffffffffffffffffffffffffff")
+		assert.False(exists, "Records closer to the remote should stay")
+
+		section.Success("Joining node pulls its records")
+	})
+}
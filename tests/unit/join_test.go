@@ -1,7 +1,16 @@
 package unit
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Aradhya2708/kademlia/internals/kademlia"
 	"github.com/Aradhya2708/kademlia/pkg/models"
@@ -29,12 +38,12 @@ func TestKademliaJoinNetwork(t *testing.T) {
 		routingTable := kademlia.NewRoutingTable(joiningNode.ID)
 
 		section.Step(3, "Attempt to join network")
-		err := kademlia.JoinNetwork(joiningNode, routingTable, mockServer.GetAddress())
+		err := kademlia.JoinNetwork(context.Background(), joiningNode, routingTable, mockServer.GetAddress())
 
 		assert.NoError(err, "Join should succeed")
 
 		section.Step(4, "Verify bootstrap node in routing table")
-		closestNodes := kademlia.FindClosestNodes(routingTable, bootstrapNode.ID, joiningNode.ID)
+		closestNodes := kademlia.FindClosestNodes(routingTable, bootstrapNode.ID, joiningNode.ID, 0)
 
 		found := false
 		for _, node := range closestNodes {
@@ -58,7 +67,7 @@ func TestKademliaJoinNetwork(t *testing.T) {
 
 		section.Step(2, "Attempt join with invalid address")
 		invalidAddress := "nonexistent:99999"
-		err := kademlia.JoinNetwork(joiningNode, routingTable, invalidAddress)
+		err := kademlia.JoinNetwork(context.Background(), joiningNode, routingTable, invalidAddress)
 
 		assert.HasError(err, "Join should fail with invalid address")
 		section.Success("Network join properly failed")
@@ -81,7 +90,7 @@ func TestKademliaJoinNetwork(t *testing.T) {
 
 		for i, addr := range invalidAddresses {
 			section.Step(i+1, "Testing invalid address: "+addr)
-			err := kademlia.JoinNetwork(joiningNode, routingTable, addr)
+			err := kademlia.JoinNetwork(context.Background(), joiningNode, routingTable, addr)
 			assert.HasError(err, "Should fail for invalid address: %s", addr)
 		}
 
@@ -101,7 +110,7 @@ func TestKademliaJoinNetwork(t *testing.T) {
 		joiningNode := fixtures.CreateTestNode(8085, "valid")
 		routingTable := kademlia.NewRoutingTable(joiningNode.ID)
 
-		err := kademlia.JoinNetwork(joiningNode, routingTable, mockServer.GetAddress())
+		err := kademlia.JoinNetwork(context.Background(), joiningNode, routingTable, mockServer.GetAddress())
 		assert.NoError(err, "Should succeed with valid response")
 
 		// Test with invalid response (empty node ID)
@@ -114,11 +123,81 @@ func TestKademliaJoinNetwork(t *testing.T) {
 		joiningNode2 := fixtures.CreateTestNode(8086, "invalid")
 		routingTable2 := kademlia.NewRoutingTable(joiningNode2.ID)
 
-		err = kademlia.JoinNetwork(joiningNode2, routingTable2, mockServer.GetAddress())
+		err = kademlia.JoinNetwork(context.Background(), joiningNode2, routingTable2, mockServer.GetAddress())
 		assert.HasError(err, "Should fail with empty node ID in response")
 
 		section.Success("Response handling working correctly")
 	})
+
+	t.Run("PingTokenEcho", func(t *testing.T) {
+		section := logger.Section("Ping Token Echo")
+
+		section.Step(1, "PONG echoes the PING token")
+		node := fixtures.CreateTestNode(8080, "echo")
+		rr := httptest.NewRecorder()
+		kademlia.PingHandler(rr, httptest.NewRequest("GET", "/ping?token=abc123", nil), node, kademlia.NewKeyValueStore(), kademlia.NewRoutingTable(node.ID))
+		var pong map[string]interface{}
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &pong), "PONG should decode")
+		assert.Equal("abc123", pong["token"], "Token should be echoed")
+
+		section.Step(2, "A PONG without the token is not trusted")
+		bootstrapNode := fixtures.CreateTestNode(8087, "forged")
+		mockServer := testutils.NewMockServer(section, bootstrapNode)
+		defer mockServer.Close()
+		for _, token := range []string{"", "0000000000000000"} {
+			mockServer.SetResponse("ping", map[string]interface{}{
+				"message": "pong",
+				"node_id": bootstrapNode.ID,
+				"token":   token,
+			})
+			joiningNode := fixtures.CreateTestNode(8088, "spoofed")
+			routingTable := kademlia.NewRoutingTable(joiningNode.ID)
+			err := kademlia.JoinNetwork(context.Background(), joiningNode, routingTable, mockServer.GetAddress())
+			assert.HasError(err, "PONG with token %q should be rejected", token)
+			assert.Equal(0, len(kademlia.FindClosestNodes(routingTable, bootstrapNode.ID, joiningNode.ID, 0)), "No contact should be added")
+		}
+
+		section.Success("Only PONGs echoing the token are trusted")
+	})
+
+	t.Run("NodeInfoCompatibility", func(t *testing.T) {
+		section := logger.Section("Node Info Compatibility")
+		ctx := context.Background()
+
+		section.Step(1, "A running node describes itself")
+		full := kademlia.NewNode(nil)
+		assert.NoError(full.Start(ctx), "Node should start")
+		defer full.Stop()
+		full.Storage.Set(fixtures.GenerateValidHexID("info"), "value")
+		info, err := kademlia.SendNodeInfo(ctx, full.Addr())
+		assert.NoError(err, "Node info should be served")
+		assert.Equal(full.Self.ID, info.NodeID, "Info should name the node")
+		assert.Equal(models.ProtocolVersion, info.Protocol, "Info should carry the protocol version")
+		assert.Equal(160, info.IDBits, "IDs should be 160 bits")
+		assert.Equal(20, info.K, "k should be reported")
+		assert.Equal(1, info.Keys, "Stored keys should be counted")
+		assert.True(info.UptimeSeconds >= 0, "Uptime should be reported")
+
+		section.Step(2, "Joining a node with IDs of another size fails")
+		bootstrapID := strings.Repeat("ab", 32)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/ping":
+				json.NewEncoder(w).Encode(map[string]interface{}{"message": "pong", "node_id": bootstrapID, "token": r.URL.Query().Get("token")})
+			case "/node_info":
+				json.NewEncoder(w).Encode(kademlia.NodeInfo{NodeID: bootstrapID, Protocol: models.ProtocolVersion, IDBits: 256})
+			}
+		}))
+		defer server.Close()
+		joiningNode := fixtures.CreateTestNode(8089, "incompatible")
+		routingTable := kademlia.NewRoutingTable(joiningNode.ID)
+		err = kademlia.JoinNetwork(ctx, joiningNode, routingTable, strings.TrimPrefix(server.URL, "http://"))
+		assert.HasError(err, "Join should refuse an incompatible node")
+		assert.Equal(0, len(kademlia.FindClosestNodes(routingTable, bootstrapID, joiningNode.ID, 0)), "No contact should be added")
+
+		section.Success("Node info served and checked on join")
+	})
 }
 
 // TestKademliaIntegration tests integration between different Kademlia components
@@ -152,7 +231,7 @@ func TestKademliaIntegration(t *testing.T) {
 		section.Step(4, "Verify both components work together")
 		// Find closest nodes
 		targetID := fixtures.GenerateValidHexID("target")
-		closestNodes := kademlia.FindClosestNodes(routingTable, targetID, localNodeID)
+		closestNodes := kademlia.FindClosestNodes(routingTable, targetID, localNodeID, 0)
 		assert.True(len(closestNodes) > 0, "Should find closest nodes")
 
 		// Verify storage
@@ -189,7 +268,7 @@ func TestKademliaIntegration(t *testing.T) {
 		for i, node := range nodes {
 			for j, otherNode := range nodes {
 				if i != j {
-					closestNodes := kademlia.FindClosestNodes(routingTables[i], otherNode.ID, node.ID)
+					closestNodes := kademlia.FindClosestNodes(routingTables[i], otherNode.ID, node.ID, 0)
 					found := false
 					for _, foundNode := range closestNodes {
 						if foundNode.ID == otherNode.ID {
@@ -204,4 +283,177 @@ func TestKademliaIntegration(t *testing.T) {
 
 		section.Success("Multiple node interaction working correctly")
 	})
+	t.Run("JoinWithRetry", func(t *testing.T) {
+		section := logger.Section("Join With Retry")
+
+		section.Step(1, "Bootstrap node fails its first two pings")
+		bootstrapNode := fixtures.CreateTestNode(8080, "flaky")
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/ping" {
+				http.NotFound(w, r)
+				return
+			}
+			if atomic.AddInt32(&attempts, 1) <= 2 {
+				http.Error(w, "starting up", http.StatusServiceUnavailable)
+				return
+			}
+			kademlia.PingHandler(w, r, bootstrapNode, kademlia.NewKeyValueStore(), kademlia.NewRoutingTable(bootstrapNode.ID))
+		}))
+		defer server.Close()
+
+		joiningNode := fixtures.CreateTestNode(8081, "retrying")
+		addr := strings.TrimPrefix(server.URL, "http://")
+		retry := kademlia.JoinRetry{Attempts: 5, Backoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+		section.Step(2, "Retrying joins once the bootstrap node recovers")
+		routingTable := kademlia.NewRoutingTable(joiningNode.ID)
+		assert.NoError(kademlia.JoinWithRetry(context.Background(), joiningNode, routingTable, addr, retry), "Join should eventually succeed")
+		assert.Equal(int32(3), atomic.LoadInt32(&attempts), "Join should take three attempts")
+
+		section.Step(3, "Attempts are bounded")
+		atomic.StoreInt32(&attempts, -10)
+		retry.Attempts = 2
+		err := kademlia.JoinWithRetry(context.Background(), joiningNode, kademlia.NewRoutingTable(joiningNode.ID), addr, retry)
+		assert.HasError(err, "Join should give up after the last attempt")
+		assert.Equal(int32(-8), atomic.LoadInt32(&attempts), "No attempts should be made beyond the limit")
+
+		section.Step(4, "Cancellation stops retrying")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		retry.Attempts = 0
+		err = kademlia.JoinWithRetry(ctx, joiningNode, kademlia.NewRoutingTable(joiningNode.ID), addr, retry)
+		assert.HasError(err, "Cancelled join should fail")
+
+		section.Success("Join retried with backoff")
+	})
+
+	t.Run("DNSSeeds", func(t *testing.T) {
+		section := logger.Section("DNS Seeds")
+
+		resolver := fakeResolver{
+			hosts: map[string][]string{
+				"seed.example.org":  {"10.0.0.1", "10.0.0.2", "fd00::1"},
+				"seed2.example.org": {"10.0.0.3"},
+			},
+			txt: map[string][]string{
+				"seeds.example.org": {"seed2.example.org:9000 10.0.0.9:9001", "10.0.0.10:9002,unknown.example.org:9003"},
+			},
+		}
+		resolve := func(bootstrap string) map[string]bool {
+			addrs, err := kademlia.ResolveBootstrap(context.Background(), resolver, bootstrap)
+			assert.NoError(err, "Resolving %s should succeed", bootstrap)
+			set := make(map[string]bool)
+			for _, addr := range addrs {
+				set[addr] = true
+			}
+			return set
+		}
+
+		section.Step(1, "Addresses and host names resolve to every record")
+		assert.Equal(fmt.Sprint(map[string]bool{"127.0.0.1:8080": true}), fmt.Sprint(resolve("127.0.0.1:8080")), "IP addresses should pass through")
+		assert.Equal(fmt.Sprint(map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:8080": true, "[fd00::1]:8080": true}), fmt.Sprint(resolve("seed.example.org:8080")), "A and AAAA records should be used")
+
+		section.Step(2, "Names without a port read a TXT seed list")
+		assert.Equal(fmt.Sprint(map[string]bool{"10.0.0.3:9000": true, "10.0.0.9:9001": true, "10.0.0.10:9002": true}), fmt.Sprint(resolve("seeds.example.org")), "TXT entries should be resolved")
+
+		section.Step(3, "Unresolvable or malformed names fail")
+		for _, bootstrap := range []string{"unknown.example.org:8080", "unknown.example.org", "seed.example.org:0", ""} {
+			_, err := kademlia.ResolveBootstrap(context.Background(), resolver, bootstrap)
+			assert.HasError(err, "Resolving %q should fail", bootstrap)
+		}
+
+		section.Step(4, "Joining tries seeds until one answers")
+		bootstrapNode := fixtures.CreateTestNode(8080, "seed")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PingHandler(w, r, bootstrapNode, kademlia.NewKeyValueStore(), kademlia.NewRoutingTable(bootstrapNode.ID))
+		}))
+		defer server.Close()
+		dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}))
+		defer dead.Close()
+
+		previous := kademlia.SeedResolver
+		defer func() { kademlia.SeedResolver = previous }()
+		kademlia.SeedResolver = fakeResolver{txt: map[string][]string{
+			"seeds.example.org": {dead.Listener.Addr().String() + " " + server.Listener.Addr().String()},
+		}}
+
+		joiningNode := fixtures.CreateTestNode(8081, "seeded")
+		routingTable := kademlia.NewRoutingTable(joiningNode.ID)
+		assert.NoError(kademlia.JoinBootstrap(context.Background(), joiningNode, routingTable, "seeds.example.org"), "Join should succeed through the live seed")
+		closest := kademlia.FindClosestNodes(routingTable, bootstrapNode.ID, joiningNode.ID, 0)
+		assert.True(len(closest) == 1 && closest[0].ID == bootstrapNode.ID, "Live seed should be added to the routing table")
+
+		section.Success("DNS seeds resolved and tried")
+	})
+
+	t.Run("LatencyAwareBootstrap", func(t *testing.T) {
+		section := logger.Section("Latency Aware Bootstrap")
+
+		section.Step(1, "Start three fast seeds, a slow one and a dead one")
+		var addrs []string
+		seed := func(name string, delay time.Duration) *models.Node {
+			seedNode := fixtures.CreateTestNode(8080, name)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(delay)
+				kademlia.PingHandler(w, r, seedNode, kademlia.NewKeyValueStore(), kademlia.NewRoutingTable(seedNode.ID))
+			}))
+			t.Cleanup(server.Close)
+			addrs = append(addrs, server.Listener.Addr().String())
+			return seedNode
+		}
+		fast := []*models.Node{seed("fast-1", 0), seed("fast-2", 0), seed("fast-3", 0)}
+		slow := seed("slow", 200*time.Millisecond)
+		dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}))
+		defer dead.Close()
+		addrs = append(addrs, dead.Listener.Addr().String())
+
+		section.Step(2, "Join through the fastest responders")
+		joiningNode := fixtures.CreateTestNode(8081, "multi-region")
+		routingTable := kademlia.NewRoutingTable(joiningNode.ID)
+		assert.NoError(kademlia.JoinBootstrap(context.Background(), joiningNode, routingTable, strings.Join(addrs, ",")), "Join should succeed")
+
+		known := make(map[string]bool)
+		for _, n := range kademlia.FindClosestNodes(routingTable, joiningNode.ID, joiningNode.ID, 0) {
+			known[n.ID] = true
+		}
+		for _, n := range fast {
+			assert.True(known[n.ID], "Fast seed %s should be joined through", n.ID)
+		}
+		assert.False(known[slow.ID], "Slow seed should not be joined through")
+
+		section.Step(3, "Round-trip times seed the peer latency data")
+		slowRTT := routingTable.Reputation.RTT(slow.ID)
+		assert.True(slowRTT >= 200*time.Millisecond, "Slow seed RTT should be recorded, got %v", slowRTT)
+		for _, n := range fast {
+			rtt := routingTable.Reputation.RTT(n.ID)
+			assert.True(rtt > 0 && rtt < slowRTT, "Fast seed RTT should be recorded below the slow one, got %v", rtt)
+		}
+
+		section.Success("Fastest seeds joined")
+	})
+}
+
+// fakeResolver answers DNS lookups from fixed records
+type fakeResolver struct {
+	hosts map[string][]string
+	txt   map[string][]string
+}
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if records, ok := r.txt[name]; ok {
+		return records, nil
+	}
+	return nil, errors.New("no such host")
 }
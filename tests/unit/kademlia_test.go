@@ -1,11 +1,13 @@
 package unit
 
 import (
+	"fmt"
 	"math/big"
 	"testing"
 
 	"github.com/Aradhya2708/kademlia/internals/kademlia"
 	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
 	"github.com/Aradhya2708/kademlia/tests/testutils"
 )
 
@@ -183,7 +185,7 @@ func TestKademliaRoutingTable(t *testing.T) {
 		section.Step(3, "Verify nodes were added")
 		totalNodesFound := 0
 		for _, node := range testNodes {
-			closestNodes := kademlia.FindClosestNodes(routingTable, node.ID, localNodeID)
+			closestNodes := kademlia.FindClosestNodes(routingTable, node.ID, localNodeID, 0)
 			for _, foundNode := range closestNodes {
 				if foundNode.ID == node.ID {
 					totalNodesFound++
@@ -212,7 +214,7 @@ func TestKademliaRoutingTable(t *testing.T) {
 		}
 
 		section.Step(3, "Verify only one instance exists")
-		closestNodes := kademlia.FindClosestNodes(routingTable, testNode.ID, localNodeID)
+		closestNodes := kademlia.FindClosestNodes(routingTable, testNode.ID, localNodeID, 0)
 
 		duplicateCount := 0
 		for _, node := range closestNodes {
@@ -226,6 +228,54 @@ func TestKademliaRoutingTable(t *testing.T) {
 		section.Success("Duplicate node prevention working correctly")
 	})
 
+	t.Run("DuplicateContactUpdated", func(t *testing.T) {
+		section := logger.Section("Duplicate Contact Updated")
+
+		localNodeID := "0000000000000000000000000000000000000000"
+		routingTable := kademlia.NewRoutingTableWithK(localNodeID, 2)
+		a := &models.Node{ID: "8000000000000000000000000000000000000001", IP: "10.0.0.1", Port: 8080, LastSeen: 100}
+		b := &models.Node{ID: "8000000000000000000000000000000000000002", IP: "10.0.0.2", Port: 8080}
+		kademlia.AddNodeToRoutingTable(routingTable, a, localNodeID)
+		kademlia.AddNodeToRoutingTable(routingTable, b, localNodeID)
+
+		section.Step(1, "Seeing a contact again refreshes it, but not from an unproven endpoint")
+		moved := &models.Node{ID: a.ID, IP: "10.0.0.9", Port: 9090, LastSeen: 200}
+		kademlia.AddNodeToRoutingTable(routingTable, moved, localNodeID)
+		bucket := routingTable.Buckets[159]
+		assert.Equal(2, len(bucket.Nodes), "No duplicate should be added")
+		assert.Equal("10.0.0.1", a.IP, "IP should be kept until the move is proven")
+		assert.Equal(8080, a.Port, "Port should be kept until the move is proven")
+		assert.Equal(int64(200), a.LastSeen, "LastSeen should advance")
+
+		section.Step(2, "It moves to the tail of its bucket")
+		assert.Equal(b.ID, bucket.Nodes[0].ID, "Least recently seen contact should be at the head")
+		assert.Equal(a.ID, bucket.Nodes[1].ID, "Most recently seen contact should be at the tail")
+
+		section.Step(3, "Eviction takes the least recently seen contact")
+		kademlia.AddNodeToRoutingTable(routingTable, &models.Node{ID: "8000000000000000000000000000000000000003"}, localNodeID)
+		ids := []string{bucket.Nodes[0].ID, bucket.Nodes[1].ID}
+		assert.Equal(fmt.Sprint([]string{a.ID, "8000000000000000000000000000000000000003"}), fmt.Sprint(ids), "The stale contact should be evicted")
+
+		section.Step(4, "Contacts without an endpoint keep the known one")
+		kademlia.AddNodeToRoutingTable(routingTable, &models.Node{ID: a.ID}, localNodeID)
+		assert.Equal("10.0.0.1", a.IP, "IP should be kept")
+		assert.Equal(int64(200), a.LastSeen, "LastSeen should not go backwards")
+
+		section.Success("Duplicate contacts refreshed")
+	})
+
+	t.Run("OversizedIDIgnored", func(t *testing.T) {
+		section := logger.Section("Oversized ID Ignored")
+
+		localNodeID := fixtures.GenerateValidHexID("local")
+		routingTable := kademlia.NewRoutingTable(localNodeID)
+		oversized := &models.Node{ID: "ff" + fixtures.GenerateValidHexID("long"), IP: "127.0.0.1", Port: 8080}
+		kademlia.AddNodeToRoutingTable(routingTable, oversized, localNodeID)
+		assert.Equal(0, len(kademlia.FindClosestNodes(routingTable, localNodeID, localNodeID, 0)), "Contact with an ID longer than ours should be ignored")
+
+		section.Success("Oversized IDs do not index past the last bucket")
+	})
+
 	t.Run("FindClosestNodes", func(t *testing.T) {
 		section := logger.Section("Find Closest Nodes")
 
@@ -246,7 +296,7 @@ func TestKademliaRoutingTable(t *testing.T) {
 
 		section.Step(2, "Find closest nodes to target")
 		targetID := fixtures.GenerateValidHexID("target")
-		closestNodes := kademlia.FindClosestNodes(routingTable, targetID, localNodeID)
+		closestNodes := kademlia.FindClosestNodes(routingTable, targetID, localNodeID, 0)
 
 		section.Step(3, "Verify results")
 		k := constants.GetK()
@@ -271,6 +321,37 @@ func TestKademliaRoutingTable(t *testing.T) {
 		section.Success("Find closest nodes working correctly")
 	})
 
+	t.Run("PerTableK", func(t *testing.T) {
+		section := logger.Section("Per-Table K")
+
+		section.Step(1, "Create tables with different k")
+		localNodeID := fixtures.GenerateValidHexID("local")
+		small := kademlia.NewRoutingTableWithK(localNodeID, 2)
+		large := kademlia.NewRoutingTableWithK(localNodeID, 5)
+		for _, node := range fixtures.CreateTestNodes(10, 8080) {
+			kademlia.AddNodeToRoutingTable(small, node, localNodeID)
+			kademlia.AddNodeToRoutingTable(large, node, localNodeID)
+		}
+
+		section.Step(2, "Changing the global default leaves them alone")
+		originalK := constants.GetK()
+		constants.SetK(1)
+		defer constants.SetK(originalK)
+
+		targetID := fixtures.GenerateValidHexID("target")
+		assert.Equal(2, len(kademlia.FindClosestNodes(small, targetID, localNodeID, 0)), "Small table should return its own k")
+		assert.Equal(5, len(kademlia.FindClosestNodes(large, targetID, localNodeID, 0)), "Large table should return its own k")
+		assert.Equal(1, kademlia.NewRoutingTable(localNodeID).BucketSize(), "Default tables should use the global default")
+
+		section.Step(3, "An explicit count overrides k")
+		closest := kademlia.FindClosestNodes(large, targetID, localNodeID, 0)
+		fewer := kademlia.FindClosestNodes(large, targetID, localNodeID, 2)
+		assert.Equal(fmt.Sprint(closest[:2]), fmt.Sprint(fewer), "Smaller count should return the closest contacts")
+		assert.Equal(8, len(kademlia.FindClosestNodes(large, targetID, localNodeID, 8)), "Larger count should return more than k")
+
+		section.Success("Each routing table keeps its own k")
+	})
+
 	t.Run("XORDistanceCalculation", func(t *testing.T) {
 		section := logger.Section("XOR Distance Calculation")
 
@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// filterPeer is a storage node serving PING, FIND_NODE and FIND_VALUE,
+// counting the FIND_VALUEs it answers
+type filterPeer struct {
+	node       *models.Node
+	storage    *models.KeyValueStore
+	findValues atomic.Int32
+	server     *httptest.Server
+}
+
+func startFilterPeer(id string) *filterPeer {
+	p := &filterPeer{node: &models.Node{ID: id, IP: "127.0.0.1"}, storage: kademlia.NewKeyValueStore()}
+	table := kademlia.NewRoutingTable(id)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		kademlia.PingHandler(w, r, p.node, p.storage, table)
+	})
+	mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+		kademlia.FindNodeHandler(w, r, p.node, table)
+	})
+	mux.HandleFunc("/find_value", func(w http.ResponseWriter, r *http.Request) {
+		p.findValues.Add(1)
+		kademlia.FindValueHandler(w, r, p.node, p.storage, table)
+	})
+	p.server = httptest.NewServer(mux)
+	addr := strings.TrimPrefix(p.server.URL, "http://")
+	p.node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+	return p
+}
+
+// TestKeyFilter tests the Bloom filters of stored keys nodes advertise
+func TestKeyFilter(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "KEYFILTER")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting key filter tests")
+
+	t.Run("BloomFilter", func(t *testing.T) {
+		section := logger.Section("Bloom Filter")
+
+		section.Step(1, "Added keys are always reported")
+		filter := models.NewBloomFilter(1000, models.BloomFalsePositiveRate)
+		for i := 0; i < 1000; i++ {
+			filter.Add(kademlia.KeyFromString(fmt.Sprintf("present-%d", i)))
+		}
+		for i := 0; i < 1000; i++ {
+			assert.True(filter.MayContain(kademlia.KeyFromString(fmt.Sprintf("present-%d", i))), "Added key %d should be reported", i)
+		}
+
+		section.Step(2, "Absent keys are rarely reported")
+		falsePositives := 0
+		for i := 0; i < 1000; i++ {
+			if filter.MayContain(kademlia.KeyFromString(fmt.Sprintf("absent-%d", i))) {
+				falsePositives++
+			}
+		}
+		assert.True(falsePositives < 30, "False positives should stay near 1%%, got %d of 1000", falsePositives)
+		var empty *models.BloomFilter
+		assert.True(empty.MayContain("anything"), "A missing filter should rule nothing out")
+
+		section.Step(3, "The store's filter follows new keys and rebuilds as it grows")
+		storage := kademlia.NewKeyValueStore()
+		first := fixtures.GenerateValidHexID("first")
+		storage.Set(first, "v")
+		assert.True(storage.KeyFilter().MayContain(first), "Filter should hold the stored key")
+		var keys []string
+		for i := 0; i < 500; i++ {
+			key := kademlia.KeyFromString(fmt.Sprintf("grow-%d", i))
+			keys = append(keys, key)
+			storage.Set(key, "v")
+		}
+		grown := storage.KeyFilter()
+		for _, key := range keys {
+			assert.True(grown.MayContain(key), "Filter should hold keys stored after it was built")
+		}
+
+		section.Success("Bloom filters have no false negatives and few false positives")
+	})
+
+	t.Run("Advertised", func(t *testing.T) {
+		section := logger.Section("Advertised")
+
+		section.Step(1, "PONGs carry the filter only when asked")
+		node := fixtures.CreateTestNode(8080, "filter")
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("held")
+		storage.Set(key, "v")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		ping := func(path string) kademlia.PingReply {
+			rr := httptest.NewRecorder()
+			kademlia.PingHandler(rr, httptest.NewRequest("GET", path, nil), node, storage, routingTable)
+			var reply kademlia.PingReply
+			json.Unmarshal(rr.Body.Bytes(), &reply)
+			return reply
+		}
+		assert.True(ping("/ping").Filter == nil, "Plain pings should not carry a filter")
+		reply := ping("/ping?filter=true")
+		assert.True(reply.Filter != nil && reply.Filter.MayContain(key), "Filter should hold the stored key")
+
+		section.Step(2, "Node info carries it too")
+		rr := httptest.NewRecorder()
+		kademlia.NodeInfoHandler(rr, httptest.NewRequest("GET", "/node_info?filter=true", nil), node, routingTable, storage, clock.Now())
+		var info kademlia.NodeInfo
+		json.Unmarshal(rr.Body.Bytes(), &info)
+		assert.True(info.KeyFilter != nil && info.KeyFilter.MayContain(key), "Node info should carry the filter")
+
+		section.Step(3, "Client-only nodes advertise none")
+		node.Flags = models.FlagPubSub
+		assert.True(ping("/ping?filter=true").Filter == nil, "Nodes without storage should not send a filter")
+
+		section.Success("Filters advertised on request")
+	})
+
+	t.Run("FindValueAsksHoldersFirst", func(t *testing.T) {
+		section := logger.Section("Find Value Asks Holders First")
+
+		section.Step(1, "Two peers, the closer one without the key")
+		key := "0000000000000000000000000000000000000001"
+		closer := startFilterPeer("0000000000000000000000000000000000000002")
+		defer closer.server.Close()
+		farther := startFilterPeer("f000000000000000000000000000000000000000")
+		defer farther.server.Close()
+		farther.storage.Set(key, "held far away")
+
+		local := "8000000000000000000000000000000000000000"
+		routingTable := kademlia.NewRoutingTable(local)
+		kademlia.AddNodeToRoutingTable(routingTable, closer.node, local)
+		kademlia.AddNodeToRoutingTable(routingTable, farther.node, local)
+		refresher := kademlia.NewKeyFilterRefresher(routingTable, local, time.Minute)
+		assert.Equal(2, refresher.Refresh(context.Background()), "Both peers' filters should be fetched")
+
+		section.Step(2, "The holder is asked first")
+		opts := kademlia.LookupOptions{Strategy: kademlia.ByDistance{}}
+		value, found := kademlia.IterativeFindValue(context.Background(), routingTable, local, kademlia.NewKeyValueStore(), key, opts)
+		assert.True(found && value == "held far away", "Value should be found")
+		assert.Equal(int32(0), closer.findValues.Load(), "The peer whose filter rules the key out should not be asked")
+
+		section.Step(3, "Keys stored after the filter was fetched are still found")
+		late := "0000000000000000000000000000000000000003"
+		closer.storage.Set(late, "stored late")
+		value, found = kademlia.IterativeFindValue(context.Background(), routingTable, local, kademlia.NewKeyValueStore(), late, opts)
+		assert.True(found && value == "stored late", "Peers ruled out by a stale filter should still be asked last")
+
+		section.Success("FIND_VALUE asks likely holders first")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		section := logger.Section("Expiry")
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		section.Step(1, "Filters are trusted until MaxAge")
+		filters := models.NewKeyFilters(time.Minute)
+		filters.Set("peer", models.NewBloomFilter(10, models.BloomFalsePositiveRate))
+		assert.True(filters.Lacks("peer", "absent"), "A fresh empty filter should rule keys out")
+		assert.False(filters.Lacks("other", "absent"), "Peers without a filter should not be ruled out")
+		fake.Advance(2 * time.Minute)
+		assert.False(filters.Lacks("peer", "absent"), "An expired filter should be ignored")
+		assert.Equal(0, filters.Len(), "Expired filters should be dropped")
+
+		section.Success("Old filters are ignored")
+	})
+}
@@ -0,0 +1,92 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestKeyHashing tests hashing arbitrary application keys into the
+// keyspace
+func TestKeyHashing(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "KEYS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting key hashing tests")
+
+	t.Run("Helpers", func(t *testing.T) {
+		section := logger.Section("Key Helpers")
+
+		section.Step(1, "Arbitrary input hashes to a valid key")
+		for _, s := range []string{"", "user:42", "ünïcode", strings.Repeat("x", 10000)} {
+			key := kademlia.KeyFromString(s)
+			assert.NoError(validators.ValidateID(key, validators.HexadecimalValidator), "Key for %q should be valid", s)
+		}
+
+		section.Step(2, "Hashing is deterministic and agrees across helpers")
+		assert.Equal(kademlia.KeyFromString("user:42"), kademlia.KeyFromString("user:42"), "Same input should give the same key")
+		assert.Equal(kademlia.KeyFromString("user:42"), kademlia.KeyFromBytes([]byte("user:42")), "String and byte helpers should agree")
+		assert.True(kademlia.KeyFromString("user:42") != kademlia.KeyFromString("user:43"), "Different inputs should give different keys")
+		assert.Equal("a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", kademlia.KeyFromString("test"), "Keys should be the SHA-1 of the input")
+		assert.Equal(kademlia.KeyFromString("news"), kademlia.TopicKey("news"), "Topic keys should use the same hash")
+
+		section.Success("Keys hashed")
+	})
+
+	t.Run("StoreAndGet", func(t *testing.T) {
+		section := logger.Section("Store And Get")
+		ctx := context.Background()
+
+		cfg := config.Default()
+		cfg.Port = 0
+		node := kademlia.NewNode(cfg)
+		assert.NoError(node.Start(ctx), "Node should start")
+		defer node.Stop()
+		c := client.NewClient(node.Addr())
+
+		section.Step(1, "The client hashes keys it is asked to")
+		ack, err := c.Store(ctx, "user:42", "alice", true)
+		assert.NoError(err, "Store should succeed")
+		assert.Equal(kademlia.KeyFromString("user:42"), ack.Key, "Ack should carry the hashed key")
+		value, found, _, err := c.Get(ctx, "user:42", true)
+		assert.NoError(err, "Get should succeed")
+		assert.True(found, "Value should be found under the hashed key")
+		assert.Equal("alice", value, "Stored value should be returned")
+
+		_, err = c.Store(ctx, "user:42", "alice", false)
+		assert.HasError(err, "Unhashed application keys should be rejected")
+
+		section.Step(2, "HTTP callers pass hash=true")
+		req := httptest.NewRequest("GET", "/find_value?key=user:42&hash=true", nil)
+		rr := httptest.NewRecorder()
+		kademlia.FindValueHandler(rr, req, node.Self, node.Storage, node.RoutingTable)
+		assert.Equal(http.StatusOK, rr.Code, "Hashed lookup should succeed")
+		json.Unmarshal(rr.Body.Bytes(), &value)
+		assert.Equal("alice", value, "Hashed lookup should find the value")
+
+		req = httptest.NewRequest("POST", "/store?hash=true", strings.NewReader(`{"key":"user:43","value":"bob"}`))
+		rr = httptest.NewRecorder()
+		kademlia.StoreHandler(rr, req, node.Self, node.Storage, node.RoutingTable)
+		assert.Equal(http.StatusCreated, rr.Code, "Hashed store should succeed")
+		stored, _ := node.Storage.Get(kademlia.KeyFromString("user:43"))
+		assert.Equal("bob", stored, "Value should be stored under the hashed key")
+
+		section.Step(3, "Misses return contacts to continue from")
+		_, found, closest, err := c.Get(ctx, fixtures.GenerateValidHexID("missing"), false)
+		assert.NoError(err, "Get of a missing key should succeed")
+		assert.False(found, "Missing key should not be found")
+		assert.True(len(closest) > 0, "Closest contacts should be returned")
+
+		section.Success("Application keys stored and fetched")
+	})
+}
@@ -0,0 +1,198 @@
+package unit
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/krpc"
+	"github.com/Aradhya2708/kademlia/pkg/bencode"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestMainlineKRPC tests the BEP 5 KRPC transport between two local nodes
+func TestMainlineKRPC(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "KRPC")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting Mainline DHT KRPC tests")
+
+	start := func(suffix string) *krpc.Server {
+		s := krpc.NewServer(fixtures.GenerateValidHexID(suffix))
+		assert.NoError(s.Listen("127.0.0.1:0"), "Server should listen")
+		return s
+	}
+
+	t.Run("PingAndFindNode", func(t *testing.T) {
+		section := logger.Section("Ping And Find Node")
+		ctx := context.Background()
+
+		router := start("router")
+		defer router.Close()
+		a := start("a")
+		defer a.Close()
+		b := start("b")
+		defer b.Close()
+
+		section.Step(1, "Ping returns the remote ID")
+		id, err := a.Ping(ctx, router.Addr())
+		assert.NoError(err, "Ping should succeed")
+		assert.Equal(router.ID, id, "Ping should return the router's ID")
+
+		section.Step(2, "Bootstrap through the router discovers other nodes")
+		assert.NoError(b.Bootstrap(ctx, []string{router.Addr().String()}), "Bootstrap should succeed")
+		nodes, err := b.FindNode(ctx, router.Addr(), a.ID)
+		assert.NoError(err, "FindNode should succeed")
+		assert.True(len(nodes) > 0 && nodes[0].ID == a.ID, "Router should return node a")
+		assert.Equal(a.Addr().Port, nodes[0].Port, "Compact node info should carry the port")
+
+		section.Success("KRPC ping and find_node working")
+	})
+
+	t.Run("GetPeersAndAnnounce", func(t *testing.T) {
+		section := logger.Section("Get Peers And Announce")
+		ctx := context.Background()
+
+		tracker := start("tracker")
+		defer tracker.Close()
+		client := start("client")
+		defer client.Close()
+		infoHash := fixtures.GenerateValidHexID("torrent")
+
+		section.Step(1, "Unknown info-hash returns a token and no peers")
+		peers, _, token, err := client.GetPeers(ctx, tracker.Addr(), infoHash)
+		assert.NoError(err, "GetPeers should succeed")
+		assert.Equal(0, len(peers), "No peers should be known yet")
+		assert.True(token != "", "A token should be issued")
+
+		section.Step(2, "Announces need a valid token")
+		assert.HasError(client.AnnouncePeer(ctx, tracker.Addr(), infoHash, 6881, "forged"), "Bad token should be rejected")
+		assert.NoError(client.AnnouncePeer(ctx, tracker.Addr(), infoHash, 6881, token), "Announce should succeed")
+
+		section.Step(3, "Announced peer is returned")
+		peers, _, _, err = client.GetPeers(ctx, tracker.Addr(), infoHash)
+		assert.NoError(err, "GetPeers should succeed")
+		assert.Equal(1, len(peers), "One peer should be known")
+		assert.Equal("127.0.0.1:6881", peers[0], "Peer should be in compact form")
+
+		section.Success("KRPC get_peers and announce_peer working")
+	})
+
+	t.Run("UnknownMethod", func(t *testing.T) {
+		section := logger.Section("Unknown Method")
+
+		server := start("server")
+		defer server.Close()
+
+		conn, err := net.DialUDP("udp", nil, server.Addr())
+		assert.NoError(err, "Dial should succeed")
+		defer conn.Close()
+
+		query, _ := bencode.Marshal(map[string]interface{}{
+			"t": "aa", "y": "q", "q": "vote",
+			"a": map[string]string{"id": "abcdefghij0123456789"},
+		})
+		conn.Write(query)
+
+		buf := make([]byte, 1500)
+		n, err := conn.Read(buf)
+		assert.NoError(err, "Server should reply")
+
+		var reply map[string]interface{}
+		assert.NoError(bencode.Unmarshal(buf[:n], &reply), "Reply should be bencoded")
+		assert.Equal("e", reply["y"], "Reply should be an error")
+		assert.Equal(int64(krpc.ErrMethodUnknown), reply["e"].([]interface{})[0], "Error code should be 204")
+
+		section.Success("Unknown methods rejected")
+	})
+
+	t.Run("ForgedResponse", func(t *testing.T) {
+		section := logger.Section("Forged Response")
+
+		client := start("client")
+		defer client.Close()
+		peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		assert.NoError(err, "Peer should listen")
+		defer peer.Close()
+		forger, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		assert.NoError(err, "Forger should listen")
+		defer forger.Close()
+
+		type result struct {
+			id  string
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			id, err := client.Ping(context.Background(), peer.LocalAddr().(*net.UDPAddr))
+			done <- result{id, err}
+		}()
+
+		section.Step(1, "Queries carry a random transaction ID")
+		buf := make([]byte, 1500)
+		n, _, err := peer.ReadFromUDP(buf)
+		assert.NoError(err, "Peer should receive the ping")
+		var query map[string]interface{}
+		assert.NoError(bencode.Unmarshal(buf[:n], &query), "Query should be bencoded")
+		tid, _ := query["t"].(string)
+		assert.Equal(4, len(tid), "Transaction ID should be 4 bytes")
+
+		section.Step(2, "A response from another address is ignored")
+		reply := func(id string) []byte {
+			data, _ := bencode.Marshal(map[string]interface{}{
+				"t": tid, "y": "r",
+				"r": map[string]string{"id": id},
+			})
+			return data
+		}
+		forger.WriteToUDP(reply("forgedforgedforged00"), client.Addr())
+		peer.WriteToUDP(reply("abcdefghij0123456789"), client.Addr())
+
+		res := <-done
+		assert.NoError(res.err, "Ping should succeed")
+		assert.Equal(hex.EncodeToString([]byte("abcdefghij0123456789")), res.id, "Only the genuine reply should be accepted")
+
+		section.Success("Forged responses ignored")
+	})
+
+	t.Run("MoveConfirmation", func(t *testing.T) {
+		section := logger.Section("Move Confirmation")
+
+		a := start("a")
+		defer a.Close()
+		b := start("b")
+		defer b.Close()
+		other := start("other")
+		defer other.Close()
+		at := func(s *krpc.Server) *models.Node {
+			return &models.Node{ID: b.ID, IP: "127.0.0.1", Port: s.Addr().Port}
+		}
+		port := func() int {
+			return kademlia.FindClosestNodes(a.RoutingTable, b.ID, a.ID, 0)[0].Port
+		}
+
+		section.Step(1, "A contact still answering at its address is not moved")
+		kademlia.AddNodeToRoutingTable(a.RoutingTable, at(b), a.ID)
+		kademlia.AddNodeToRoutingTable(a.RoutingTable, at(other), a.ID)
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(b.Addr().Port, port(), "Contact should stay where it answers")
+
+		section.Step(2, "A contact is followed once confirmed over UDP")
+		fresh := start("fresh")
+		defer fresh.Close()
+		kademlia.AddNodeToRoutingTable(fresh.RoutingTable, at(other), fresh.ID)
+		kademlia.AddNodeToRoutingTable(fresh.RoutingTable, at(b), fresh.ID)
+		deadline := time.Now().Add(2 * time.Second)
+		for kademlia.FindClosestNodes(fresh.RoutingTable, b.ID, fresh.ID, 0)[0].Port != b.Addr().Port && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(b.Addr().Port, kademlia.FindClosestNodes(fresh.RoutingTable, b.ID, fresh.ID, 0)[0].Port, "Contact should move to where it answers as itself")
+
+		section.Success("Moves confirmed over the KRPC transport")
+	})
+}
@@ -2,6 +2,7 @@ package unit
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"testing"
 
@@ -24,7 +25,7 @@ func TestKeyValueStore(t *testing.T) {
 		store := models.NewKeyValueStore()
 
 		assert.NotNil(store, "Store should not be nil")
-		assert.NotNil(store.Store, "Internal store should not be nil")
+		assert.Equal(0, store.Len(), "Store should hold no keys")
 
 		section.Step(2, "Verify empty store")
 		allData := store.GetAll()
@@ -146,6 +147,47 @@ func TestKeyValueStore(t *testing.T) {
 
 		section.Success("Edge cases handled correctly")
 	})
+
+	t.Run("KeyValueStoreScan", func(t *testing.T) {
+		section := logger.Section("KeyValueStore Scan")
+
+		section.Step(1, "Every record is visited once, in key order, across chunks")
+		store := models.NewKeyValueStore()
+		total := 2*models.ScanChunkSize + 10
+		for i := 0; i < total; i++ {
+			store.Put(fmt.Sprintf("key-%04d", i), fmt.Sprintf("value-%d", i), "publisher", models.OverwriteAlways, "")
+		}
+		var keys []string
+		store.Scan(func(rec models.StoredRecord) bool {
+			keys = append(keys, rec.Key)
+			assert.Equal("publisher", rec.Publisher, "Records should carry their publisher")
+			return true
+		})
+		assert.Equal(total, len(keys), "Every record should be visited")
+		assert.True(sort.StringsAreSorted(keys), "Records should be visited in key order")
+
+		section.Step(2, "The callback may write, and deleted keys are skipped")
+		visited := 0
+		store.Scan(func(rec models.StoredRecord) bool {
+			if visited == 0 {
+				store.Set("key-new", "written during the scan")
+				store.Delete(fmt.Sprintf("key-%04d", total-1))
+			}
+			visited++
+			return true
+		})
+		assert.Equal(total-1, visited, "Keys deleted before their chunk should be skipped and new keys not visited")
+
+		section.Step(3, "Returning false stops the scan")
+		visited = 0
+		store.Scan(func(rec models.StoredRecord) bool {
+			visited++
+			return visited < 3
+		})
+		assert.Equal(3, visited, "Scan should stop when the callback returns false")
+
+		section.Success("Scan iterates without holding the lock")
+	})
 }
 
 // TestRoutingTableModel tests the RoutingTable model
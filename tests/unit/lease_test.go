@@ -0,0 +1,214 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// leasePeer is a storage node serving FIND_NODE and leases
+type leasePeer struct {
+	node    *models.Node
+	storage *models.KeyValueStore
+	server  *httptest.Server
+}
+
+func startLeasePeer(id string) *leasePeer {
+	p := &leasePeer{node: &models.Node{ID: id, IP: "127.0.0.1"}, storage: kademlia.NewKeyValueStore()}
+	table := kademlia.NewRoutingTable(id)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+		kademlia.FindNodeHandler(w, r, p.node, table)
+	})
+	mux.HandleFunc("/lease", func(w http.ResponseWriter, r *http.Request) {
+		kademlia.LeaseHandler(w, r, p.node, p.storage, table)
+	})
+	p.server = httptest.NewServer(mux)
+	addr := strings.TrimPrefix(p.server.URL, "http://")
+	p.node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+	return p
+}
+
+// TestLease tests leases on keys
+func TestLease(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "LEASE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting lease tests")
+
+	t.Run("Replica", func(t *testing.T) {
+		section := logger.Section("Lease on One Replica")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		node := fixtures.CreateTestNode(8080, "replica")
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("lock")
+
+		lease := func(holder string, ttl int, release bool) (int, kademlia.LeaseAck) {
+			body, _ := json.Marshal(kademlia.LeaseRequest{Key: key, Holder: holder, TTL: ttl, Release: release})
+			req := httptest.NewRequest("POST", "/lease", strings.NewReader(string(body)))
+			rr := httptest.NewRecorder()
+			kademlia.LeaseHandler(rr, req, node, storage, table)
+			var ack kademlia.LeaseAck
+			json.Unmarshal(rr.Body.Bytes(), &ack)
+			return rr.Code, ack
+		}
+
+		section.Step(1, "A free lease is granted")
+		code, ack := lease("alice", 10, false)
+		assert.Equal(http.StatusOK, code, "Lease should be answered")
+		assert.True(ack.Granted, "Free lease should be granted")
+		assert.Equal("alice", ack.Lease.Holder, "Alice should hold the lease")
+		assert.Equal(uint64(1), ack.Lease.Token, "First lease should have token 1")
+		value, _ := storage.Get(key)
+		assert.Contains(value, `"holder":"alice"`, "Lease should be stored as the key's value")
+
+		section.Step(2, "A held lease is refused to others")
+		_, ack = lease("bob", 10, false)
+		assert.False(ack.Granted, "Held lease should be refused")
+		assert.Equal("alice", ack.Lease.Holder, "Refusal should name the holder")
+		_, ack = lease("bob", 0, true)
+		assert.False(ack.Granted, "Others should not release the lease")
+
+		section.Step(3, "The holder renews with the same token")
+		fake.Advance(5 * time.Second)
+		_, ack = lease("alice", 10, false)
+		assert.True(ack.Granted, "Renewal should be granted")
+		assert.Equal(uint64(1), ack.Lease.Token, "Renewal should keep the token")
+		assert.Equal(clock.Now().Add(10*time.Second).Unix(), ack.Lease.ExpiresAt.Unix(), "Renewal should extend the lease")
+
+		section.Step(4, "A lapsed lease passes on with a new token")
+		fake.Advance(11 * time.Second)
+		_, ack = lease("bob", 10, false)
+		assert.True(ack.Granted, "Lapsed lease should be granted")
+		assert.Equal(uint64(2), ack.Lease.Token, "New holder should get a new token")
+
+		section.Step(5, "The holder releases the lease")
+		_, ack = lease("bob", 0, true)
+		assert.True(ack.Granted, "Release should be granted")
+		assert.Equal("", ack.Lease.Holder, "Released lease should have no holder")
+		_, ack = lease("alice", 10, false)
+		assert.True(ack.Granted, "Released lease should be granted")
+		assert.Equal(uint64(3), ack.Lease.Token, "Token should keep growing")
+
+		section.Step(6, "Keys holding other values are refused")
+		other := fixtures.GenerateValidHexID("plain")
+		storage.Set(other, "not a lease")
+		body, _ := json.Marshal(kademlia.LeaseRequest{Key: other, Holder: "alice"})
+		rr := httptest.NewRecorder()
+		kademlia.LeaseHandler(rr, httptest.NewRequest("POST", "/lease", strings.NewReader(string(body))), node, storage, table)
+		assert.Equal(http.StatusConflict, rr.Code, "Plain values should not be taken as leases")
+
+		section.Step(7, "Bad requests are refused")
+		rr = httptest.NewRecorder()
+		kademlia.LeaseHandler(rr, httptest.NewRequest("POST", "/lease", strings.NewReader(`{"key": "`+key+`"}`)), node, storage, table)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Missing holder should be refused")
+		rr = httptest.NewRecorder()
+		kademlia.LeaseHandler(rr, httptest.NewRequest("POST", "/lease", strings.NewReader(`{"key": "`+key+`", "holder": "a", "ttl": 3601}`)), node, storage, table)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Long leases should be refused")
+
+		section.Success("Replica leases working correctly")
+	})
+
+	t.Run("Majority", func(t *testing.T) {
+		section := logger.Section("Majority of Replicas")
+
+		self := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(self.ID)
+		storage := kademlia.NewKeyValueStore()
+		var peers []*leasePeer
+		for i := 0; i < 4; i++ {
+			p := startLeasePeer(fixtures.GenerateValidHexID(fmt.Sprintf("replica%d", i)))
+			defer p.server.Close()
+			kademlia.AddNodeToRoutingTable(table, p.node, self.ID)
+			peers = append(peers, p)
+		}
+		// self is not a storage node, leaving the four peers as replicas
+		self.Flags = models.FlagRelay
+		key := fixtures.GenerateValidHexID("shared")
+		ctx := context.Background()
+
+		section.Step(1, "A lease held by one replica is granted by the rest")
+		peers[0].storage.Set(key, fmt.Sprintf(`{"key":%q,"holder":"bob","token":7,"expires_at":%q}`, key, clock.Now().Add(time.Hour).Format(time.RFC3339)))
+		ack := kademlia.IterativeLease(ctx, table, self, storage, kademlia.LeaseRequest{Key: key, Holder: "alice", TTL: 10})
+		assert.Equal(4, len(ack.Replicas), "Every replica should be asked")
+		assert.True(ack.Granted, "Three of four replicas should grant the lease")
+		assert.Equal("alice", ack.Lease.Holder, "Alice should hold the lease")
+
+		section.Step(2, "A lease a majority refuses is rolled back")
+		contested := fixtures.GenerateValidHexID("contested")
+		held := fmt.Sprintf(`{"key":%q,"holder":"carol","token":9,"expires_at":%q}`, contested, clock.Now().Add(time.Hour).Format(time.RFC3339))
+		peers[1].storage.Set(contested, held)
+		peers[2].storage.Set(contested, held)
+		ack = kademlia.IterativeLease(ctx, table, self, storage, kademlia.LeaseRequest{Key: contested, Holder: "dave", TTL: 10})
+		assert.False(ack.Granted, "Two of four replicas should not grant the lease")
+		assert.Equal("carol", ack.Lease.Holder, "Refusal should name the holder")
+		for _, i := range []int{0, 3} {
+			value, _ := peers[i].storage.Get(contested)
+			assert.False(strings.Contains(value, `"holder":"dave"`), "Replica %d should release the lease again", i)
+		}
+
+		section.Success("Majority leases working correctly")
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		section := logger.Section("Client Leases")
+
+		node := fixtures.CreateTestNode(8080, "entry")
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		for i := 0; i < 3; i++ {
+			p := startLeasePeer(fixtures.GenerateValidHexID(fmt.Sprintf("holder%d", i)))
+			defer p.server.Close()
+			kademlia.AddNodeToRoutingTable(table, p.node, node.ID)
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.LeaseHandler(w, r, node, storage, table)
+		}))
+		defer server.Close()
+		c := client.NewClient(strings.TrimPrefix(server.URL, "http://"))
+		ctx := context.Background()
+
+		section.Step(1, "Acquire, renew and release")
+		lease, err := c.AcquireLease(ctx, "jobs:nightly", "worker-1", 1500*time.Millisecond, true)
+		assert.NoError(err, "Acquire should succeed")
+		assert.Equal(kademlia.KeyFromString("jobs:nightly"), lease.Key, "Lease should be on the hashed key")
+		assert.True(lease.ExpiresAt.Sub(clock.Now()) > time.Second, "TTL should be rounded up to whole seconds")
+		_, err = c.AcquireLease(ctx, "jobs:nightly", "worker-2", time.Minute, true)
+		assert.Equal(kademlia.ErrLeaseHeld, err, "Held lease should be refused")
+		renewed, err := c.RenewLease(ctx, lease, time.Minute)
+		assert.NoError(err, "Renewal should succeed")
+		assert.Equal(lease.Token, renewed.Token, "Renewal should keep the token")
+		assert.NoError(c.ReleaseLease(ctx, renewed), "Release should succeed")
+		other, err := c.AcquireLease(ctx, "jobs:nightly", "worker-2", time.Minute, true)
+		assert.NoError(err, "Released lease should be granted")
+
+		section.Step(2, "Renewing a lease held by someone else is refused")
+		_, err = c.RenewLease(ctx, lease, time.Minute)
+		assert.Equal(kademlia.ErrLeaseHeld, err, "Worker 2 holds the lease now")
+
+		section.Step(3, "Renewing a lease that passed on meanwhile is reported lost")
+		assert.NoError(c.ReleaseLease(ctx, other), "Release should succeed")
+		renewed, err = c.RenewLease(ctx, lease, time.Minute)
+		assert.Equal(kademlia.ErrLeaseLost, err, "Lease should have changed hands")
+		assert.True(renewed.Token > lease.Token, "The new lease should carry a newer token")
+
+		section.Success("Client leases working correctly")
+	})
+}
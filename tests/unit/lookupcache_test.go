@@ -0,0 +1,140 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestLookupCache tests reusing node lookup results until they may change
+func TestLookupCache(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "LOOKUP_CACHE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting lookup cache tests")
+
+	t.Run("Cache", func(t *testing.T) {
+		section := logger.Section("Cache Entries")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		cache := models.NewLookupCache(10 * time.Second)
+		target := fixtures.GenerateValidHexID("target")
+		nodes := []*models.Node{fixtures.CreateTestNode(8081, "a"), fixtures.CreateTestNode(8082, "b")}
+
+		section.Step(1, "Results are returned for the same target, k and capabilities")
+		cache.Put(target, 2, 0, nodes)
+		cached, ok := cache.Get(target, 2, 0)
+		assert.True(ok, "Result should be cached")
+		assert.Equal(2, len(cached), "Both contacts should be returned")
+		_, ok = cache.Get(target, 3, 0)
+		assert.False(ok, "Another k should miss")
+		_, ok = cache.Get(target, 2, models.FlagStorage)
+		assert.False(ok, "Other capabilities should miss")
+
+		section.Step(2, "Results expire after the TTL")
+		fake.Advance(11 * time.Second)
+		_, ok = cache.Get(target, 2, 0)
+		assert.False(ok, "Expired result should miss")
+		stats := cache.Stats()
+		assert.Equal(uint64(1), stats.Hits, "One hit should be counted")
+		assert.Equal(uint64(3), stats.Misses, "Three misses should be counted")
+
+		section.Step(3, "No cache remembers nothing")
+		var none *models.LookupCache
+		none.Put(target, 2, 0, nodes)
+		_, ok = none.Get(target, 2, 0)
+		assert.False(ok, "Nil cache should always miss")
+
+		section.Success("Cache entries working correctly")
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		section := logger.Section("Cached Lookups")
+
+		var queries atomic.Int32
+		// contact serves FIND_NODE as a node with an empty routing table
+		contact := func(id string) *models.Node {
+			n := &models.Node{ID: id, IP: "127.0.0.1"}
+			table := kademlia.NewRoutingTable(id)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				queries.Add(1)
+				kademlia.FindNodeHandler(w, r, n, table)
+			}))
+			t.Cleanup(server.Close)
+			addr := strings.TrimPrefix(server.URL, "http://")
+			n.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+			return n
+		}
+		id := func(prefix string, last byte) string {
+			return prefix + strings.Repeat("0", 39-len(prefix)) + string(last)
+		}
+
+		localID := id("", '0')
+		target := id("8", '0')
+		table := kademlia.NewRoutingTableWithK(localID, 3)
+		table.Lookups = models.NewLookupCache(time.Minute)
+		for _, n := range []*models.Node{contact(id("8", '1')), contact(id("8", '2')), contact(id("4", '0'))} {
+			kademlia.AddNodeToRoutingTable(table, n, localID)
+		}
+
+		section.Step(1, "A repeated lookup is answered from the cache")
+		first := kademlia.IterativeFindNode(context.Background(), table, localID, target)
+		assert.Equal(3, len(first), "Lookup should find all three contacts")
+		asked := queries.Load()
+		assert.Equal(int32(3), asked, "Every contact should be queried")
+		second := kademlia.IterativeFindNode(context.Background(), table, localID, target)
+		assert.Equal(len(first), len(second), "Cached result should match")
+		assert.Equal(asked, queries.Load(), "No contact should be queried again")
+
+		section.Step(2, "A farther contact leaves the result cached")
+		kademlia.AddNodeToRoutingTable(table, contact(id("6", '0')), localID)
+		kademlia.IterativeFindNode(context.Background(), table, localID, target)
+		assert.Equal(asked, queries.Load(), "Farther contact should not invalidate the result")
+
+		section.Step(3, "A closer contact invalidates the result")
+		closer := contact(id("8", '3'))
+		kademlia.AddNodeToRoutingTable(table, closer, localID)
+		third := kademlia.IterativeFindNode(context.Background(), table, localID, target)
+		assert.True(queries.Load() > asked, "Lookup should run again")
+		found := false
+		for _, n := range third {
+			found = found || n.ID == closer.ID
+		}
+		assert.True(found, "New result should include the closer contact")
+		assert.Equal(uint64(1), table.Lookups.Stats().Invalidated, "Invalidation should be counted")
+
+		section.Success("Cached lookups working correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Lookup Cache Configuration")
+
+		section.Step(1, "Nodes cache lookups unless disabled")
+		cfg := config.Default()
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		assert.True(n.RoutingTable.Lookups != nil, "Node should cache lookups by default")
+		cfg.LookupCacheTTL = 0
+		disabled := kademlia.NewNode(cfg)
+		defer disabled.Storage.Close()
+		assert.True(disabled.RoutingTable.Lookups == nil, "Disabled cache should not be created")
+		cfg.LookupCacheTTL = -time.Second
+		assert.HasError(cfg.Validate(), "Negative TTL should be refused")
+
+		section.Success("Lookup cache configuration working correctly")
+	})
+}
@@ -0,0 +1,200 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestMessageEnvelope tests RPCs carried in models.Message envelopes
+func TestMessageEnvelope(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "MESSAGE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting message envelope tests")
+
+	t.Run("SignAndVerify", func(t *testing.T) {
+		section := logger.Section("Sign And Verify")
+
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		sender := fixtures.CreateTestNode(8080, "sender")
+		assert.NoError(kademlia.SignNodeRecord(sender, key, nil, nil), "Signing the record should succeed")
+
+		msg := kademlia.NewMessage(models.Store, sender)
+		msg.Key, msg.Value = fixtures.GenerateValidHexID("key"), "value"
+		assert.Equal(models.MessageVersion, msg.Version, "Version should be set")
+		assert.Equal(32, len(msg.Nonce), "Nonce should be 16 hex bytes")
+		assert.NoError(msg.Sign(key), "Signing should succeed")
+		assert.NoError(msg.Verify(), "Signed message should verify")
+
+		tampered := msg
+		tampered.Value = "other"
+		assert.HasError(tampered.Verify(), "Tampered message should not verify")
+
+		anonymous := kademlia.NewMessage(models.Ping, fixtures.CreateTestNode(8080, "anon"))
+		anonymous.Signature = msg.Signature
+		assert.HasError(anonymous.Verify(), "A sender without a record cannot sign")
+
+		section.Success("Messages signed and verified")
+	})
+
+	// Serve one node's /rpc and return it with a contact for it
+	serve := func(name string) (*models.Node, *models.RoutingTable, *httptest.Server) {
+		node := fixtures.CreateTestNode(0, name)
+		node.Flags = models.DefaultFlags
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		providers := kademlia.NewProviderStore()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.MessageHandler(w, r, node, storage, providers, table)
+		}))
+		addr := strings.TrimPrefix(server.URL, "http://")
+		node.IP = "127.0.0.1"
+		node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		kademlia.AddNodeToRoutingTable(table, node, node.ID)
+		return node, table, server
+	}
+
+	t.Run("EveryRPC", func(t *testing.T) {
+		section := logger.Section("Every RPC")
+
+		remote, remoteTable, server := serve("remote")
+		defer server.Close()
+		peer := &models.Node{ID: remote.ID, IP: remote.IP, Port: remote.Port}
+		local := fixtures.CreateTestNode(9090, "local")
+		local.IP = "127.0.0.1"
+		ctx := context.Background()
+
+		section.Step(1, "PING is answered with PONG and adds the sender")
+		reply, err := kademlia.SendMessage(ctx, peer, kademlia.NewMessage(models.Ping, local))
+		assert.NoError(err, "PING should succeed")
+		assert.Equal(models.Pong, reply.Type, "PING should be answered with PONG")
+		assert.Equal(remote.ID, reply.Sender.ID, "Reply should come from the remote")
+		assert.True(peer.LastSeen > 0, "Peer should be marked as seen")
+		found := kademlia.FindClosestNodes(remoteTable, local.ID, remote.ID, 0)
+		assert.True(containsNode(found, local.ID), "Sender should be added to the routing table")
+
+		section.Step(2, "FIND_NODE returns contacts other than the two parties")
+		other := fixtures.CreateTestNode(9091, "other")
+		kademlia.AddNodeToRoutingTable(remoteTable, other, remote.ID)
+		msg := kademlia.NewMessage(models.FindNode, local)
+		msg.Target = fixtures.GenerateValidHexID("target")
+		reply, err = kademlia.SendMessage(ctx, peer, msg)
+		assert.NoError(err, "FIND_NODE should succeed")
+		assert.Equal(models.FoundNodes, reply.Type, "FIND_NODE should be answered with NODES")
+		assert.Equal(1, len(reply.Nodes), "Only the third contact should be returned")
+		assert.False(containsNode(reply.Nodes, local.ID) || containsNode(reply.Nodes, remote.ID), "Sender and responder should be left out")
+
+		section.Step(3, "STORE then FIND_VALUE")
+		key := fixtures.GenerateValidHexID("stored")
+		msg = kademlia.NewMessage(models.Store, local)
+		msg.Key, msg.Value = key, "hello"
+		reply, err = kademlia.SendMessage(ctx, peer, msg)
+		assert.NoError(err, "STORE should succeed")
+		assert.Equal(models.Stored, reply.Type, "STORE should be acknowledged")
+
+		msg = kademlia.NewMessage(models.FindValue, local)
+		msg.Key = key
+		reply, err = kademlia.SendMessage(ctx, peer, msg)
+		assert.NoError(err, "FIND_VALUE should succeed")
+		assert.Equal(models.FoundValue, reply.Type, "Stored value should be found")
+		assert.Equal("hello", reply.Value, "Value should round-trip")
+
+		section.Step(4, "ADD_PROVIDER then GET_PROVIDERS")
+		msg = kademlia.NewMessage(models.AddProvider, local)
+		msg.Key = key
+		_, err = kademlia.SendMessage(ctx, peer, msg)
+		assert.NoError(err, "ADD_PROVIDER should succeed")
+		msg = kademlia.NewMessage(models.GetProviders, local)
+		msg.Key = key
+		reply, err = kademlia.SendMessage(ctx, peer, msg)
+		assert.NoError(err, "GET_PROVIDERS should succeed")
+		assert.Equal(models.FoundProviders, reply.Type, "Provider should be found")
+		assert.True(len(reply.Nodes) == 1 && reply.Nodes[0].ID == local.ID, "Sender should be the provider")
+
+		section.Step(5, "Replies honour the bencode wire format")
+		reply, err = kademlia.SendMessage(kademlia.WithWireFormat(ctx, "bencode"), peer, kademlia.NewMessage(models.Ping, local))
+		assert.NoError(err, "Bencoded PING should succeed")
+		assert.Equal(models.Pong, reply.Type, "Bencoded reply should decode")
+
+		section.Success("Every RPC carried in an envelope")
+	})
+
+	t.Run("Rejections", func(t *testing.T) {
+		section := logger.Section("Rejections")
+
+		remote, _, server := serve("strict")
+		defer server.Close()
+		local := fixtures.CreateTestNode(9090, "local")
+		local.IP = "127.0.0.1"
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(kademlia.SignNodeRecord(local, key, nil, nil), "Signing the record should succeed")
+
+		post := func(msg models.Message) int {
+			body, _ := json.Marshal(msg)
+			resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				return 0
+			}
+			defer resp.Body.Close()
+			var reply models.Message
+			json.NewDecoder(resp.Body).Decode(&reply)
+			if resp.StatusCode != http.StatusOK {
+				assert.Equal(models.Error, reply.Type, "Refusals should be ERROR envelopes")
+				assert.Equal(msg.Nonce, reply.Nonce, "Refusals should echo the nonce")
+			}
+			return resp.StatusCode
+		}
+
+		signed := kademlia.NewMessage(models.Ping, local)
+		assert.NoError(signed.Sign(key), "Signing should succeed")
+		assert.Equal(http.StatusOK, post(signed), "Signed message should be accepted")
+
+		forged := signed
+		forged.Target = remote.ID
+		assert.Equal(http.StatusBadRequest, post(forged), "Message altered after signing should be rejected")
+
+		future := kademlia.NewMessage(models.Ping, local)
+		future.Version = models.MessageVersion + 1
+		assert.Equal(http.StatusBadRequest, post(future), "Newer envelope versions should be rejected")
+
+		gossip := kademlia.NewMessage("GOSSIP", local)
+		assert.NoError(gossip.Sign(key), "Signing should succeed")
+		assert.Equal(http.StatusBadRequest, post(gossip), "Unknown types should be rejected")
+
+		bad := kademlia.NewMessage(models.FindNode, local)
+		bad.Target = "xyz"
+		assert.NoError(bad.Sign(key), "Signing should succeed")
+		assert.Equal(http.StatusBadRequest, post(bad), "Invalid targets should be rejected")
+
+		section.Step(1, "Unsigned messages carrying a record are rejected")
+		copied := kademlia.NewMessage(models.Store, local)
+		copied.Key, copied.Value = fixtures.GenerateValidHexID("copied"), "value"
+		assert.Equal(http.StatusBadRequest, post(copied), "A copy of another node's record should not vouch for an unsigned message")
+		resigned := copied
+		_, other, _ := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(resigned.Sign(other), "Signing should succeed")
+		assert.Equal(http.StatusBadRequest, post(resigned), "A message signed by a key other than the record's should be rejected")
+		anonymous := kademlia.NewMessage(models.Ping, fixtures.CreateTestNode(9092, "anonymous"))
+		assert.Equal(http.StatusOK, post(anonymous), "Unsigned messages without a record should still be accepted")
+
+		section.Step(2, "Replies from the wrong node are rejected")
+		impostor := &models.Node{ID: fixtures.GenerateValidHexID("impostor"), IP: remote.IP, Port: remote.Port}
+		_, err := kademlia.SendMessage(context.Background(), impostor, kademlia.NewMessage(models.Ping, local))
+		assert.HasError(err, "Reply from another node should be rejected")
+
+		section.Success("Invalid envelopes refused")
+	})
+}
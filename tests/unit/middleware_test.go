@@ -0,0 +1,446 @@
+package unit
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/cmd"
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestMiddleware tests the RPC middleware chain and its standard middleware
+func TestMiddleware(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "MIDDLEWARE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting middleware tests")
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	serve := func(h http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	t.Run("ChainOrder", func(t *testing.T) {
+		section := logger.Section("Chain Order")
+
+		var order []string
+		tag := func(name string) middleware.Middleware {
+			return func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name+":"+rpc)
+					next(w, r)
+				}
+			}
+		}
+		h := middleware.Chain(tag("a"), tag("b"))("ping", ok)
+		serve(h, httptest.NewRequest("GET", "/ping", nil))
+		assert.Equal("[a:ping b:ping]", fmt.Sprint(order), "First middleware should run outermost")
+
+		section.Success("Middleware runs in order")
+	})
+
+	t.Run("RecoveryAuthAndRateLimit", func(t *testing.T) {
+		section := logger.Section("Recovery, Auth And Rate Limit")
+
+		section.Step(1, "Panics become structured 500s and are counted")
+		metrics := middleware.NewMetrics()
+		panicky := middleware.Chain(middleware.Recovery(metrics), metrics.Middleware())("ping", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+		rr := serve(panicky, httptest.NewRequest("GET", "/ping", nil))
+		assert.Equal(http.StatusInternalServerError, rr.Code, "Panic should be recovered")
+		var body map[string]string
+		assert.NoError(json.NewDecoder(rr.Body).Decode(&body), "Error body should be JSON")
+		assert.Equal("internal", body["error"], "Error body should carry a code")
+		assert.Equal(int64(1), metrics.Snapshot()["ping"].Panics, "Panic should be counted")
+		assert.Equal(int64(1), metrics.Snapshot()["ping"].ServerErrors, "Panic should count as a server error")
+
+		section.Step(2, "Only the configured token is accepted")
+		auth := middleware.Auth("secret")("ping", ok)
+		for header, want := range map[string]int{
+			"":              http.StatusUnauthorized,
+			"Bearer wrong":  http.StatusUnauthorized,
+			"Bearer secret": http.StatusOK,
+		} {
+			req := httptest.NewRequest("GET", "/ping", nil)
+			req.Header.Set("Authorization", header)
+			assert.Equal(want, serve(auth, req).Code, "Unexpected status for "+header)
+		}
+
+		section.Step(3, "Callers beyond their burst are limited")
+		limited := middleware.RateLimit(1, 2)("ping", ok)
+		req := httptest.NewRequest("GET", "/ping", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		assert.Equal(http.StatusOK, serve(limited, req).Code, "First request should pass")
+		assert.Equal(http.StatusOK, serve(limited, req).Code, "Burst should allow a second request")
+		rr = serve(limited, req)
+		assert.Equal(http.StatusTooManyRequests, rr.Code, "Third request should be limited")
+		assert.Equal("1", rr.Header().Get("Retry-After"), "Limited response should say when to retry")
+
+		other := httptest.NewRequest("GET", "/ping", nil)
+		other.RemoteAddr = "10.0.0.2:1234"
+		assert.Equal(http.StatusOK, serve(limited, other).Code, "Other callers should have their own budget")
+
+		section.Success("Standard middleware behaves")
+	})
+
+	t.Run("ServerChainAndHook", func(t *testing.T) {
+		section := logger.Section("Server Chain And Hook")
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(err, "Should find a free port")
+		port := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+
+		cfg := config.Default()
+		cfg.Server.AuthToken = "secret"
+		var hooked []string
+		hook := func(rpc string, next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				hooked = append(hooked, rpc)
+				next(w, r)
+			}
+		}
+
+		node := fixtures.CreateTestNode(port, "chained")
+		server, err := cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), port, cfg, hook)
+		assert.NoError(err, "Server should start")
+		defer server.Shutdown(context.Background())
+
+		get := func(path, token string) *http.Response {
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d%s", port, path), nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err, "Server should answer")
+			return resp
+		}
+
+		section.Step(1, "Unauthenticated RPCs are rejected before the hook")
+		resp := get("/ping", "")
+		resp.Body.Close()
+		assert.Equal(http.StatusUnauthorized, resp.StatusCode, "Ping without token should be rejected")
+		assert.Equal(0, len(hooked), "Hook should run after auth")
+
+		section.Step(2, "Authenticated RPCs reach the hook and are counted")
+		resp = get("/ping", "secret")
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode, "Ping with token should succeed")
+		assert.Equal("[ping]", fmt.Sprint(hooked), "Hook should see the RPC name")
+
+		resp = get("/rpc_stats", "secret")
+		var stats map[string]middleware.RPCStats
+		assert.NoError(json.NewDecoder(resp.Body).Decode(&stats), "Stats should decode")
+		resp.Body.Close()
+		assert.Equal(int64(2), stats["ping"].Requests, "Both pings should be counted")
+		assert.Equal(int64(1), stats["ping"].ClientErrors, "Rejected ping should count as an error")
+
+		section.Success("Server runs the configured chain and the hook")
+	})
+
+	t.Run("RuntimeStatsAndProfiling", func(t *testing.T) {
+		section := logger.Section("Runtime Stats And Profiling")
+
+		section.Step(1, "Runtime stats are sampled")
+		metrics := middleware.NewMetrics()
+		rr := serve(metrics.RuntimeHandler, httptest.NewRequest("GET", "/runtime_stats", nil))
+		var stats middleware.RuntimeStats
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &stats), "Runtime stats should be JSON")
+		assert.True(stats.Goroutines > 0, "Goroutines should be counted")
+		assert.True(stats.HeapAllocBytes > 0 && stats.HeapSysBytes >= stats.HeapAllocBytes, "Heap should be measured")
+		assert.False(stats.SampledAt.IsZero(), "Sample time should be set")
+
+		section.Step(2, "Profiles are served only when enabled, behind auth")
+		cfg := config.Default()
+		cfg.Port = 0
+		cfg.Server.AuthToken = "secret"
+		cfg.Server.Pprof = true
+		node := kademlia.NewNode(cfg)
+		assert.NoError(node.Start(context.Background()), "Node should start")
+		defer node.Stop()
+
+		disabledCfg := config.Default()
+		disabledCfg.Port = 0
+		disabled := kademlia.NewNode(disabledCfg)
+		assert.NoError(disabled.Start(context.Background()), "Node should start")
+		defer disabled.Stop()
+
+		get := func(addr, path, token string) int {
+			req, _ := http.NewRequest("GET", "http://"+addr+path, nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err, "Node should answer")
+			if err != nil {
+				return 0
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		assert.Equal(http.StatusOK, get(node.Addr(), "/debug/pprof/cmdline", "secret"), "Profiles should be served to the admin")
+		assert.Equal(http.StatusUnauthorized, get(node.Addr(), "/debug/pprof/cmdline", ""), "Profiles should require the token")
+		assert.Equal(http.StatusOK, get(node.Addr(), "/runtime_stats", "secret"), "Runtime stats should be served")
+		assert.Equal(http.StatusNotFound, get(disabled.Addr(), "/debug/pprof/cmdline", ""), "Profiles should be off by default")
+
+		section.Success("Runtime stats sampled and profiles guarded")
+	})
+
+	t.Run("ServerLimits", func(t *testing.T) {
+		section := logger.Section("Server Limits")
+
+		release := make(chan struct{})
+		entered := make(chan struct{}, 4)
+		blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+		})
+		request := func(h http.Handler, remote string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/ping", nil)
+			req.RemoteAddr = remote
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			return rr
+		}
+
+		section.Step(1, "Requests beyond the per-IP limit get 429")
+		limited := middleware.Limits(config.ServerConfig{MaxConcurrent: 2, MaxPerIP: 1}, blocking)
+		done := make(chan int, 2)
+		go func() { done <- request(limited, "10.0.0.1:1000").Code }()
+		<-entered
+		rr := request(limited, "10.0.0.1:1001")
+		assert.Equal(http.StatusTooManyRequests, rr.Code, "Second request from the same IP should be limited")
+		assert.Equal("1", rr.Header().Get("Retry-After"), "Retry-After should be set")
+
+		section.Step(2, "Requests beyond the global limit get 503")
+		go func() { done <- request(limited, "10.0.0.2:1000").Code }()
+		<-entered
+		assert.Equal(http.StatusServiceUnavailable, request(limited, "10.0.0.3:1000").Code, "Third concurrent request should be refused")
+
+		section.Step(3, "Slots are released when requests finish")
+		close(release)
+		assert.Equal(http.StatusOK, <-done, "In-flight request should complete")
+		assert.Equal(http.StatusOK, <-done, "In-flight request should complete")
+		assert.Equal(http.StatusOK, request(limited, "10.0.0.1:1002").Code, "Caller should be served again")
+
+		section.Step(4, "Oversized bodies are rejected")
+		echo := middleware.Limits(config.ServerConfig{MaxBodyBytes: 8}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := io.ReadAll(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			}
+		}))
+		rr = httptest.NewRecorder()
+		echo.ServeHTTP(rr, httptest.NewRequest("POST", "/store", strings.NewReader("0123456789")))
+		assert.Equal(http.StatusRequestEntityTooLarge, rr.Code, "Declared oversized body should get 413")
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/store", io.NopCloser(strings.NewReader("0123456789")))
+		req.ContentLength = -1
+		echo.ServeHTTP(rr, req)
+		assert.Equal(http.StatusRequestEntityTooLarge, rr.Code, "Undeclared oversized body should fail to read")
+		rr = httptest.NewRecorder()
+		echo.ServeHTTP(rr, httptest.NewRequest("POST", "/store", strings.NewReader("small")))
+		assert.Equal(http.StatusOK, rr.Code, "Small body should be accepted")
+
+		section.Step(5, "Defaults are enabled and validated")
+		cfg := config.Default()
+		assert.True(cfg.Server.MaxConcurrent > cfg.Server.MaxPerIP && cfg.Server.MaxPerIP > 0, "Per-IP limit should be below the global one")
+		assert.True(cfg.Server.MaxBodyBytes > 0, "Body limit should be on by default")
+		cfg.Server.MaxPerIP = -1
+		assert.HasError(cfg.Validate(), "Negative limits should be rejected")
+
+		section.Success("Server limits enforced")
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		section := logger.Section("Audit Log")
+		path := filepath.Join(t.TempDir(), "audit.log")
+
+		cfg := config.Default()
+		cfg.Port = 0
+		cfg.Server.AuditLog = path
+		cfg.Server.AuditMaxBytes = 1024
+		cfg.Server.AuditBackups = 2
+		node := kademlia.NewNode(cfg)
+		assert.NoError(node.Start(context.Background()), "Node should start")
+		defer node.Stop()
+
+		section.Step(1, "RPCs are recorded without their values")
+		key := fixtures.GenerateValidHexID("audited")
+		body := fmt.Sprintf(`{"key":%q,"value":"top-secret-value"}`, key)
+		resp, err := http.Post("http://"+node.Addr()+"/store", "application/json", strings.NewReader(body))
+		if assert.NoError(err, "Store should be answered") {
+			resp.Body.Close()
+		}
+		data, _ := os.ReadFile(path)
+		var entry middleware.AuditEntry
+		assert.NoError(json.Unmarshal(data, &entry), "Audit log should hold one JSON entry")
+		assert.Equal("store", entry.RPC, "RPC name should be recorded")
+		assert.Equal(resp.StatusCode, entry.Status, "Status should be recorded")
+		assert.Equal(int64(len(body)), entry.RequestBytes, "Request size should be recorded")
+		assert.True(strings.HasPrefix(entry.Remote, "127.0.0.1:"), "Caller should be recorded, got %s", entry.Remote)
+		assert.False(strings.Contains(string(data), "top-secret-value"), "Values should never be logged")
+
+		section.Step(2, "The log is rotated by size")
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get("http://" + node.Addr() + "/rpc_stats")
+			if err == nil {
+				resp.Body.Close()
+			}
+		}
+		for _, name := range []string{path, path + ".1", path + ".2"} {
+			info, err := os.Stat(name)
+			if assert.NoError(err, "%s should exist", name) {
+				assert.True(info.Size() <= 1024, "%s should not exceed the limit, got %d bytes", name, info.Size())
+			}
+		}
+		_, err = os.Stat(path + ".3")
+		assert.True(os.IsNotExist(err), "Only two backups should be kept")
+
+		section.Step(3, "Negative sizes are rejected")
+		cfg = config.Default()
+		cfg.Server.AuditBackups = -1
+		assert.HasError(cfg.Validate(), "Negative backups should be rejected")
+
+		section.Success("RPCs audited with rotation")
+	})
+
+	t.Run("Compression", func(t *testing.T) {
+		section := logger.Section("Compression")
+
+		large := strings.Repeat(`{"id":"0123456789abcdef","ip":"127.0.0.1"},`, 100)
+		handler := middleware.Compress(config.Default().Server, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Query().Get("small") != "" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(large[:len(large)/2]))
+			w.Write([]byte(large[len(large)/2:]))
+		}))
+		request := func(path, encoding string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", path, nil)
+			if encoding != "" {
+				req.Header.Set("Accept-Encoding", encoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr
+		}
+
+		section.Step(1, "Large responses are gzipped for callers that accept it")
+		rr := request("/find_node", "gzip, deflate")
+		assert.Equal("gzip", rr.Header().Get("Content-Encoding"), "Response should be gzipped")
+		assert.Equal("Accept-Encoding", rr.Header().Get("Vary"), "Vary should be set")
+		assert.True(rr.Body.Len() < len(large)/4, "Compressed body should be smaller, got %d bytes", rr.Body.Len())
+		if gz, err := gzip.NewReader(rr.Body); assert.NoError(err, "Body should be gzip") {
+			body, _ := io.ReadAll(gz)
+			assert.Equal(large, string(body), "Body should decompress to the original")
+		}
+
+		section.Step(2, "Small responses and other callers are sent as is")
+		rr = request("/find_node?small=1", "gzip")
+		assert.Equal("", rr.Header().Get("Content-Encoding"), "Small response should not be compressed")
+		assert.Equal("[]", rr.Body.String(), "Small response should be intact")
+		rr = request("/find_node", "")
+		assert.Equal("", rr.Header().Get("Content-Encoding"), "Callers without gzip should get plain responses")
+		rr = request("/find_node", "gzip;q=0")
+		assert.Equal("", rr.Header().Get("Content-Encoding"), "Refused gzip should not be used")
+
+		section.Step(3, "Nodes compress find_node and clients decompress transparently")
+		cfg := config.Default()
+		cfg.Port = 0
+		node := kademlia.NewNode(cfg)
+		assert.NoError(node.Start(context.Background()), "Node should start")
+		defer node.Stop()
+		for _, contact := range fixtures.CreateTestNodes(20, 10000) {
+			kademlia.AddNodeToRoutingTable(node.RoutingTable, contact, node.Self.ID)
+		}
+		target := fixtures.GenerateValidHexID("target")
+		req, _ := http.NewRequest("GET", "http://"+node.Addr()+"/find_node?id="+target, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if assert.NoError(err, "find_node should be answered") {
+			resp.Body.Close()
+			assert.Equal("gzip", resp.Header.Get("Content-Encoding"), "find_node reply should be gzipped")
+		}
+		nodes, err := client.NewClient(node.Addr()).FindNode(context.Background(), target)
+		assert.NoError(err, "Client should decode the compressed reply")
+		assert.True(len(nodes) >= 20, "Client should receive every contact, got %d", len(nodes))
+
+		section.Step(4, "Compression can be turned off")
+		off := config.Default().Server
+		off.Compression = false
+		rr = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/find_node", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		middleware.Compress(off, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(large))
+		})).ServeHTTP(rr, req)
+		assert.Equal("", rr.Header().Get("Content-Encoding"), "Disabled compression should send plain responses")
+
+		section.Success("Responses compressed by negotiation")
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		section := logger.Section("CORS")
+
+		cfg := config.Default().Server
+		cfg.CORSOrigins = []string{"https://app.example.com"}
+		handler := middleware.CORS(cfg, http.HandlerFunc(ok))
+		request := func(method, origin string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, "/find_node", nil)
+			if origin != "" {
+				req.Header.Set("Origin", origin)
+			}
+			if method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				req.Header.Set("Access-Control-Request-Headers", "content-type, traceparent")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr
+		}
+
+		section.Step(1, "Allowed origins may read responses")
+		rr := request("GET", "https://app.example.com")
+		assert.Equal(http.StatusOK, rr.Code, "Request should reach the handler")
+		assert.Equal("https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"), "Origin should be allowed")
+		assert.Equal("Origin", rr.Header().Get("Vary"), "Vary should be set")
+
+		section.Step(2, "Preflights are answered without reaching the handler")
+		rr = request(http.MethodOptions, "https://app.example.com")
+		assert.Equal(http.StatusNoContent, rr.Code, "Preflight should be answered")
+		assert.True(strings.Contains(rr.Header().Get("Access-Control-Allow-Methods"), "POST"), "POST should be allowed")
+		assert.Equal("content-type, traceparent", rr.Header().Get("Access-Control-Allow-Headers"), "Requested headers should be allowed")
+
+		section.Step(3, "Other origins and same-origin callers get no CORS headers")
+		rr = request("GET", "https://evil.example.com")
+		assert.Equal("", rr.Header().Get("Access-Control-Allow-Origin"), "Unlisted origin should not be allowed")
+		rr = request("GET", "")
+		assert.Equal("", rr.Header().Get("Access-Control-Allow-Origin"), "Requests without an origin need no CORS headers")
+
+		section.Step(4, "A wildcard allows any origin")
+		cfg.CORSOrigins = []string{"*"}
+		handler = middleware.CORS(cfg, http.HandlerFunc(ok))
+		rr = request("GET", "https://evil.example.com")
+		assert.Equal("https://evil.example.com", rr.Header().Get("Access-Control-Allow-Origin"), "Any origin should be allowed")
+
+		section.Success("Cross-origin requests allowed by configuration")
+	})
+}
@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// multiGetPeer is a node serving FIND_VALUES and counting the requests
+type multiGetPeer struct {
+	node     *models.Node
+	storage  *models.KeyValueStore
+	server   *httptest.Server
+	requests atomic.Int32
+}
+
+func startMultiGetPeer(id string) *multiGetPeer {
+	p := &multiGetPeer{node: &models.Node{ID: id, IP: "127.0.0.1"}, storage: kademlia.NewKeyValueStore()}
+	table := kademlia.NewRoutingTable(id)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/find_values", func(w http.ResponseWriter, r *http.Request) {
+		p.requests.Add(1)
+		kademlia.FindValuesHandler(w, r, p.node, p.storage, table)
+	})
+	p.server = httptest.NewServer(mux)
+	addr := strings.TrimPrefix(p.server.URL, "http://")
+	p.node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+	return p
+}
+
+// TestMultiGet tests fetching several keys in one operation
+func TestMultiGet(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "MULTIGET")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting multi-get tests")
+
+	findValues := func(node *models.Node, storage *models.KeyValueStore, table *models.RoutingTable, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/find_values", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		kademlia.FindValuesHandler(rr, req, node, storage, table)
+		return rr
+	}
+
+	t.Run("Handler", func(t *testing.T) {
+		section := logger.Section("FIND_VALUES Handler")
+
+		node := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		for _, contact := range fixtures.CreateTestNodes(3, 8081) {
+			kademlia.AddNodeToRoutingTable(table, contact, node.ID)
+		}
+		held := fixtures.GenerateValidHexID("held")
+		storage.Set(held, "v1")
+		missA, missB := fixtures.GenerateValidHexID("missa"), fixtures.GenerateValidHexID("missb")
+
+		section.Step(1, "Held values and closest contacts come in one reply")
+		body, _ := json.Marshal(kademlia.FindValuesRequest{Keys: []string{held, missA, missB, held}})
+		rr := findValues(node, storage, table, string(body))
+		assert.Equal(http.StatusOK, rr.Code, "Request should succeed: %s", rr.Body.String())
+		var reply kademlia.FindValuesReply
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &reply), "Reply should decode")
+		assert.Equal(1, len(reply.Values), "Held key should be answered once")
+		assert.Equal("v1", reply.Values[0].Value, "Held value should be returned")
+		assert.Equal(2, len(reply.Closest), "Each missed key should get contacts")
+		assert.Equal(3, len(reply.ClosestTo(missA)), "Contacts should be listed for a missed key")
+
+		section.Step(2, "Shared contacts are listed once")
+		assert.Equal(3, len(reply.Nodes), "Contacts should not be repeated")
+
+		section.Step(3, "Bad requests are refused")
+		keys := make([]string, kademlia.MaxFindValuesKeys+1)
+		for i := range keys {
+			keys[i] = held
+		}
+		body, _ = json.Marshal(kademlia.FindValuesRequest{Keys: keys})
+		assert.Equal(http.StatusBadRequest, findValues(node, storage, table, string(body)).Code, "Too many keys should be refused")
+		assert.Equal(http.StatusBadRequest, findValues(node, storage, table, `{"keys": ["nothex"]}`).Code, "Invalid keys should be refused")
+		assert.Equal(http.StatusBadRequest, findValues(node, storage, table, `{"keys": []}`).Code, "No keys should be refused")
+
+		section.Success("FIND_VALUES handler working correctly")
+	})
+
+	t.Run("BatchedLookup", func(t *testing.T) {
+		section := logger.Section("Batched Lookup")
+
+		self := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(self.ID)
+		var peers []*multiGetPeer
+		for i := 0; i < 3; i++ {
+			p := startMultiGetPeer(fixtures.GenerateValidHexID(fmt.Sprintf("peer%d", i)))
+			defer p.server.Close()
+			kademlia.AddNodeToRoutingTable(table, p.node, self.ID)
+			peers = append(peers, p)
+		}
+		var keys []string
+		for i := 0; i < 10; i++ {
+			key := fixtures.GenerateValidHexID(fmt.Sprintf("key%d", i))
+			peers[i%3].storage.Set(key, "value-"+key)
+			keys = append(keys, key)
+		}
+		missing := fixtures.GenerateValidHexID("missing")
+
+		section.Step(1, "Every held value is found")
+		values := kademlia.IterativeFindValues(context.Background(), table, self.ID, kademlia.NewKeyValueStore(), append(keys, missing), kademlia.LookupOptions{Alpha: 3})
+		assert.Equal(len(keys), len(values), "All held keys should be found")
+		for _, key := range keys {
+			assert.Equal("value-"+key, values[key], "Value of %s should be found", key)
+		}
+		_, found := values[missing]
+		assert.False(found, "A key nobody holds should be missing")
+
+		section.Step(2, "Keys sharing contacts share their RPCs")
+		total := 0
+		for _, p := range peers {
+			total += int(p.requests.Load())
+		}
+		assert.True(total <= len(peers)*2, "Expected at most one request per peer per round, got %d", total)
+
+		section.Success("Batched lookup working correctly")
+	})
+
+	t.Run("FallbackToFindValue", func(t *testing.T) {
+		section := logger.Section("Fallback to FIND_VALUE")
+
+		self := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(self.ID)
+		peer := startFilterPeer(fixtures.GenerateValidHexID("old"))
+		defer peer.server.Close()
+		kademlia.AddNodeToRoutingTable(table, peer.node, self.ID)
+		key := fixtures.GenerateValidHexID("oldkey")
+		peer.storage.Set(key, "old-value")
+
+		section.Step(1, "Peers without FIND_VALUES are asked key by key")
+		values := kademlia.IterativeFindValues(context.Background(), table, self.ID, kademlia.NewKeyValueStore(), []string{key}, kademlia.LookupOptions{})
+		assert.Equal("old-value", values[key], "Value should be found through FIND_VALUE")
+		assert.Equal(int32(1), peer.findValues.Load(), "One FIND_VALUE should be sent")
+
+		section.Success("Fallback working correctly")
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		section := logger.Section("Client GetMany")
+
+		node := fixtures.CreateTestNode(8080, "entry")
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		storage.Set(kademlia.KeyFromString("user:42"), "alice")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindValuesHandler(w, r, node, storage, table)
+		}))
+		defer server.Close()
+
+		section.Step(1, "Values come back under the keys as given")
+		c := client.NewClient(strings.TrimPrefix(server.URL, "http://"))
+		values, closest, err := c.GetMany(context.Background(), []string{"user:42", "user:43"}, true)
+		assert.NoError(err, "GetMany should succeed")
+		assert.Equal("alice", values["user:42"], "Held value should be returned")
+		_, missed := closest["user:43"]
+		assert.True(missed, "Missed key should get its contacts")
+
+		section.Step(2, "Binary values are decoded")
+		storage.Set(kademlia.KeyFromString("bin"), binaryValue)
+		values, _, err = c.GetMany(context.Background(), []string{"bin"}, true)
+		assert.NoError(err, "GetMany should succeed")
+		assert.Equal(binaryValue, values["bin"], "Binary value should survive")
+
+		section.Success("Client GetMany working correctly")
+	})
+}
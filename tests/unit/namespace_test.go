@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestNamespaces tests key namespaces, quotas and tokens
+func TestNamespaces(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "NAMESPACES")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting namespace tests")
+
+	node := fixtures.CreateTestNode(8080, "test")
+	routingTable := kademlia.NewRoutingTable(node.ID)
+	kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+
+	store := func(storage *models.KeyValueStore, namespace, token, key, value string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(map[string]string{"key": key, "value": value})
+		req := httptest.NewRequest("POST", "/store", bytes.NewBuffer(jsonData))
+		req.Header.Set(kademlia.NamespaceHeader, namespace)
+		req.Header.Set(kademlia.NamespaceTokenHeader, token)
+		rr := httptest.NewRecorder()
+		kademlia.StoreHandler(rr, req, node, storage, routingTable)
+		return rr
+	}
+	findValue := func(storage *models.KeyValueStore, query string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/find_value?"+query, nil)
+		req.Header.Set(kademlia.NamespaceTokenHeader, token)
+		rr := httptest.NewRecorder()
+		kademlia.FindValueHandler(rr, req, node, storage, routingTable)
+		return rr
+	}
+
+	t.Run("NoCollisionsAcrossNamespaces", func(t *testing.T) {
+		section := logger.Section("No Collisions Across Namespaces")
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("shared")
+
+		section.Step(1, "Store the same key in two namespaces")
+		assert.Equal(http.StatusCreated, store(storage, "app-a", "", key, "from-a").Code, "Store in app-a should succeed")
+		assert.Equal(http.StatusCreated, store(storage, "app-b", "", key, "from-b").Code, "Store in app-b should succeed")
+
+		section.Step(2, "Each namespace sees its own value")
+		assert.Contains(findValue(storage, "namespace=app-a&key="+key, "").Body.String(), "from-a", "app-a should read its value")
+		assert.Contains(findValue(storage, "namespace=app-b&key="+key, "").Body.String(), "from-b", "app-b should read its value")
+
+		_, exists := storage.Get(key)
+		assert.False(exists, "Default namespace should not see namespaced keys")
+
+		section.Success("Namespaces isolate keys")
+	})
+
+	t.Run("QuotaEnforced", func(t *testing.T) {
+		section := logger.Section("Quota Enforced")
+
+		storage := kademlia.NewKeyValueStore()
+		storage.SetNamespacePolicy("small", models.NamespacePolicy{Quota: 1})
+
+		key := fixtures.GenerateValidHexID("first")
+		assert.Equal(http.StatusCreated, store(storage, "small", "", key, "v1").Code, "First key fits the quota")
+		assert.Equal(http.StatusCreated, store(storage, "small", "", key, "v2").Code, "Overwriting does not consume quota")
+
+		rr := store(storage, "small", "", fixtures.GenerateValidHexID("second"), "v")
+		assert.Equal(http.StatusInsufficientStorage, rr.Code, "Second key exceeds the quota")
+		assert.Contains(rr.Body.String(), "quota_exceeded", "Error should be typed")
+		assert.Equal(1, storage.NamespaceUsage("small"), "Usage should be tracked")
+
+		section.Success("Quota enforced")
+	})
+
+	t.Run("TokenRequired", func(t *testing.T) {
+		section := logger.Section("Token Required")
+
+		storage := kademlia.NewKeyValueStore()
+		storage.SetNamespacePolicy("private", models.NamespacePolicy{Token: "s3cret"})
+		key := fixtures.GenerateValidHexID("private")
+
+		assert.Equal(http.StatusUnauthorized, store(storage, "private", "", key, "v").Code, "Missing token should be rejected")
+		assert.Equal(http.StatusUnauthorized, store(storage, "private", "wrong", key, "v").Code, "Wrong token should be rejected")
+		assert.Equal(http.StatusCreated, store(storage, "private", "s3cret", key, "v").Code, "Correct token should be accepted")
+
+		assert.Equal(http.StatusUnauthorized, findValue(storage, "namespace=private&key="+key, "").Code, "Reads require the token too")
+		assert.Equal(http.StatusOK, findValue(storage, "namespace=private&key="+key, "s3cret").Code, "Authorized read should succeed")
+
+		section.Success("Namespace tokens enforced")
+	})
+
+	t.Run("InvalidNamespace", func(t *testing.T) {
+		section := logger.Section("Invalid Namespace")
+
+		storage := kademlia.NewKeyValueStore()
+		rr := store(storage, "Bad/Name", "", fixtures.GenerateValidHexID("bad"), "v")
+		assert.Equal(http.StatusBadRequest, rr.Code, "Invalid namespace names should be rejected")
+
+		section.Success("Invalid namespaces rejected")
+	})
+}
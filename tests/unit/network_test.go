@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/network"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestConnectionPool tests the shared keep-alive HTTP client
+func TestConnectionPool(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "NETWORK")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting connection pool tests")
+
+	t.Run("ConnectionsAreReused", func(t *testing.T) {
+		section := logger.Section("Connections Are Reused")
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		section.Step(1, "Issue sequential requests to one peer")
+		before := network.Stats()
+		for i := 0; i < 3; i++ {
+			resp, err := network.Client().Get(server.URL)
+			assert.NoError(err, "Request should succeed")
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		after := network.Stats()
+
+		section.Step(2, "Verify pool metrics")
+		assert.Equal(before.Requests+3, after.Requests, "All requests should be counted")
+		assert.Equal(int64(0), after.InFlight, "No requests should remain in flight")
+		assert.True(after.ConnsReused-before.ConnsReused >= 2, "Later requests should reuse the keep-alive connection")
+
+		section.Success("Keep-alive connections reused")
+	})
+
+	t.Run("PoolStatsEndpoint", func(t *testing.T) {
+		section := logger.Section("Pool Stats Endpoint")
+
+		rr := httptest.NewRecorder()
+		kademlia.PoolStatsHandler(rr, httptest.NewRequest("GET", "/pool_stats", nil))
+		assert.Equal(http.StatusOK, rr.Code, "Should return 200 OK")
+
+		var stats network.PoolStats
+		err := json.Unmarshal(rr.Body.Bytes(), &stats)
+		assert.NoError(err, "Response should be valid JSON")
+
+		section.Success("Pool metrics exposed")
+	})
+}
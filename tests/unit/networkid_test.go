@@ -0,0 +1,147 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestNetworkID tests that nodes of different networks refuse each other
+func TestNetworkID(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "NETWORK")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting network ID tests")
+
+	t.Run("Ping", func(t *testing.T) {
+		section := logger.Section("Pings Across Networks")
+
+		node := fixtures.CreateTestNode(8080, "testnet")
+		node.Network = "testnet"
+		table := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+
+		ping := func(pinger models.Node) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(pinger)
+			req := httptest.NewRequest("POST", "/ping", strings.NewReader(string(body)))
+			req.RemoteAddr = "127.0.0.1:12345"
+			rr := httptest.NewRecorder()
+			kademlia.PingHandler(rr, req, node, storage, table)
+			return rr
+		}
+
+		section.Step(1, "A pinger of another network is refused")
+		mainnet := fixtures.CreateTestNode(8081, "mainnet")
+		mainnet.Network = "mainnet"
+		assert.Equal(http.StatusForbidden, ping(*mainnet).Code, "Pinger of another network should be refused")
+		assert.Equal(0, len(kademlia.FindClosestNodes(table, mainnet.ID, node.ID, 0)), "Refused pinger should not be added")
+
+		section.Step(2, "A pinger announcing no network is on the default network")
+		legacy := fixtures.CreateTestNode(8082, "legacy")
+		assert.Equal(http.StatusForbidden, ping(*legacy).Code, "Pinger of the default network should be refused")
+		req := httptest.NewRequest("GET", "/ping?id="+legacy.ID+"&port=8082", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		rr := httptest.NewRecorder()
+		kademlia.PingHandler(rr, req, node, storage, table)
+		assert.Equal(http.StatusForbidden, rr.Code, "Query pings should be checked too")
+
+		section.Step(3, "A pinger of the same network is added")
+		peer := fixtures.CreateTestNode(8083, "peer")
+		peer.Network = "testnet"
+		rr = ping(*peer)
+		assert.Equal(http.StatusOK, rr.Code, "Pinger of the same network should be answered")
+		var reply kademlia.PingReply
+		json.Unmarshal(rr.Body.Bytes(), &reply)
+		assert.Equal("testnet", reply.Network, "PONG should name the network")
+		assert.Equal(1, len(kademlia.FindClosestNodes(table, peer.ID, node.ID, 0)), "Pinger should be added")
+
+		section.Success("Pings across networks refused correctly")
+	})
+
+	t.Run("Join", func(t *testing.T) {
+		section := logger.Section("Joining Another Network")
+
+		bootstrap := fixtures.CreateTestNode(8080, "bootstrap")
+		bootstrap.Network = "testnet"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/ping" {
+				http.NotFound(w, r)
+				return
+			}
+			kademlia.PingHandler(w, r, bootstrap, kademlia.NewKeyValueStore(), kademlia.NewRoutingTable(bootstrap.ID))
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+
+		section.Step(1, "A node of the default network fails to join")
+		joining := fixtures.CreateTestNode(8081, "joining")
+		table := kademlia.NewRoutingTable(joining.ID)
+		err := kademlia.JoinNetwork(context.Background(), joining, table, addr)
+		assert.HasError(err, "Join should fail")
+		assert.True(err != nil && strings.Contains(err.Error(), "another network"), "Error should name the network mismatch")
+		assert.Equal(0, len(kademlia.FindClosestNodes(table, bootstrap.ID, joining.ID, 0)), "Bootstrap node should not be added")
+
+		section.Step(2, "A node of the same network joins")
+		joining.Network = "testnet"
+		err = kademlia.JoinNetwork(context.Background(), joining, table, addr)
+		assert.NoError(err, "Join should succeed")
+		contacts := kademlia.FindClosestNodes(table, bootstrap.ID, joining.ID, 0)
+		assert.Equal(1, len(contacts), "Bootstrap node should be added")
+		if len(contacts) == 1 {
+			assert.Equal("testnet", contacts[0].Network, "Contact should carry its network")
+		}
+
+		section.Success("Joining another network refused correctly")
+	})
+
+	t.Run("Envelope", func(t *testing.T) {
+		section := logger.Section("Envelopes Across Networks")
+
+		node := fixtures.CreateTestNode(8080, "testnet")
+		node.Network = "testnet"
+		table := kademlia.NewRoutingTable(node.ID)
+
+		section.Step(1, "An envelope from another network is refused")
+		sender := fixtures.CreateTestNode(9090, "sender")
+		msg := kademlia.NewMessage(models.Ping, sender)
+		body, _ := json.Marshal(msg)
+		rr := httptest.NewRecorder()
+		kademlia.MessageHandler(rr, httptest.NewRequest("POST", "/rpc", strings.NewReader(string(body))), node, kademlia.NewKeyValueStore(), models.NewProviderStore(time.Hour, 10, 0), table)
+		assert.Equal(http.StatusForbidden, rr.Code, "Envelope of another network should be refused")
+		assert.Contains(rr.Body.String(), "another network", "Refusal should name the mismatch")
+		assert.Equal(0, len(kademlia.FindClosestNodes(table, sender.ID, node.ID, 0)), "Sender should not be added")
+
+		section.Success("Envelopes across networks refused correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Network ID Configuration")
+
+		section.Step(1, "Network IDs are validated")
+		cfg := config.Default()
+		cfg.NetworkID = "testnet-2"
+		assert.NoError(cfg.Validate(), "Plain network ID should be accepted")
+		cfg.NetworkID = "test net"
+		assert.HasError(cfg.Validate(), "Network ID with spaces should be refused")
+		cfg.NetworkID = strings.Repeat("x", models.MaxNetworkIDLength+1)
+		assert.HasError(cfg.Validate(), "Long network ID should be refused")
+
+		section.Step(2, "Nodes take the configured network")
+		cfg.NetworkID = "testnet"
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		assert.Equal("testnet", n.Self.Network, "Node should announce the configured network")
+
+		section.Success("Network ID configuration working correctly")
+	})
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestEmbeddedNode tests the embeddable Node lifecycle and in-process API
+func TestEmbeddedNode(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "NODE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting embedded node tests")
+
+	t.Run("PutGetAcrossNodes", func(t *testing.T) {
+		section := logger.Section("Put Get Across Nodes")
+		ctx := context.Background()
+
+		section.Step(1, "Start a bootstrap node on a free port")
+		first := kademlia.NewNode(nil)
+		assert.NoError(first.Start(ctx), "First node should start")
+		defer first.Stop()
+		assert.True(first.Self.Port != 0, "Chosen port should be recorded")
+
+		section.Step(2, "Start a second node joining through the first")
+		cfg := config.Default()
+		cfg.Bootstrap = first.Addr()
+		second := kademlia.NewNode(cfg)
+		assert.NoError(second.Start(ctx), "Second node should join")
+		defer second.Stop()
+
+		section.Step(3, "Find the first node from the second")
+		nodes, err := second.FindNode(ctx, first.Self.ID)
+		assert.NoError(err, "FindNode should succeed")
+		assert.True(len(nodes) > 0 && nodes[0].ID == first.Self.ID, "Lookup should return the first node")
+
+		section.Step(4, "Put through one node and get through the other")
+		key := fixtures.GenerateValidHexID("embedded")
+		ack, err := second.Put(ctx, key, "hello")
+		assert.NoError(err, "Put should succeed")
+		assert.Equal(ack.ReplicationFactor, len(ack.Replicas)-ack.Failed, "Replication factor should count acknowledged replicas")
+
+		value, found, err := first.Get(ctx, key)
+		assert.NoError(err, "Get should succeed")
+		assert.True(found, "Value should be found")
+		assert.Equal("hello", value, "Value should round-trip")
+
+		_, err = second.FindNode(ctx, "not-hex")
+		assert.HasError(err, "Invalid IDs should be rejected")
+
+		section.Success("Embedded nodes exchange values")
+	})
+
+	t.Run("ClientOnly", func(t *testing.T) {
+		section := logger.Section("Client Only")
+		ctx := context.Background()
+
+		section.Step(1, "Join a full node as a client")
+		full := kademlia.NewNode(nil)
+		assert.NoError(full.Start(ctx), "Full node should start")
+		defer full.Stop()
+
+		cfg := config.Default()
+		cfg.Bootstrap = full.Addr()
+		cfg.ClientOnly = true
+		client := kademlia.NewNode(cfg)
+		assert.NoError(client.Start(ctx), "Client should join")
+		defer client.Stop()
+		assert.False(client.Self.Supports(models.FlagStorage), "Client should not advertise storage")
+
+		closest := kademlia.FindClosestNodes(full.RoutingTable, client.Self.ID, full.Self.ID, 0)
+		assert.False(closest[0].Supports(models.FlagStorage), "Full node should learn the client does not store")
+
+		section.Step(2, "Puts from the client land on storage nodes only")
+		key := fixtures.GenerateValidHexID("client")
+		ack, err := client.Put(ctx, key, "hello")
+		assert.NoError(err, "Put should succeed")
+		if assert.Equal(1, len(ack.Replicas), "Only the full node should be a replica") {
+			assert.Equal(full.Self.ID, ack.Replicas[0].ID, "Full node should store the value")
+		}
+		_, kept := client.Storage.Get(key)
+		assert.False(kept, "Client should not keep the value")
+
+		value, found, err := client.Get(ctx, key)
+		assert.NoError(err, "Get should succeed")
+		assert.True(found && value == "hello", "Client should read the value back")
+
+		section.Step(3, "STOREs sent to the client are refused")
+		err = kademlia.SendStoreRequest(ctx, client.Self, kademlia.StoreRequest{Key: key, Value: "direct"})
+		assert.HasError(err, "Client should refuse STOREs")
+		assert.Equal(0, len(client.Storage.GetAll()), "Client storage should stay empty")
+
+		section.Success("Client-only nodes use the network without storing")
+	})
+
+	t.Run("StopReleasesPort", func(t *testing.T) {
+		section := logger.Section("Stop Releases Port")
+
+		node := kademlia.NewNode(nil)
+		assert.NoError(node.Start(context.Background()), "Node should start")
+		assert.HasError(node.Start(context.Background()), "Starting twice should fail")
+
+		resp, err := http.Get("http://" + node.Addr() + "/ping")
+		assert.NoError(err, "Running node should answer")
+		resp.Body.Close()
+
+		assert.NoError(node.Stop(), "Stop should succeed")
+		assert.NoError(node.Stop(), "Second Stop should be a no-op")
+
+		_, err = http.Get("http://" + node.Addr() + "/ping")
+		assert.HasError(err, "Stopped node should not answer")
+
+		section.Success("Node lifecycle working correctly")
+	})
+}
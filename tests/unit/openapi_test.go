@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/middleware"
+	"github.com/Aradhya2708/kademlia/internals/openapi"
+	"github.com/Aradhya2708/kademlia/pkg/apiclient"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestOpenAPI tests the OpenAPI document nodes serve and the Go client
+// generated from it
+func TestOpenAPI(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "OPENAPI")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting OpenAPI tests")
+	ctx := context.Background()
+
+	cfg := config.Default()
+	cfg.Port = 0
+	cfg.Server.AdminToken = "admin-secret"
+	cfg.Server.Gateway = true
+	node := kademlia.NewNode(cfg)
+	assert.NoError(node.Start(ctx), "Node should start")
+	defer node.Stop()
+
+	t.Run("ServedDocument", func(t *testing.T) {
+		section := logger.Section("Served Document")
+
+		section.Step(1, "Fetch the document")
+		resp, err := http.Get("http://" + node.Addr() + kademlia.OpenAPIPath)
+		if !assert.NoError(err, "Document should be served") {
+			return
+		}
+		defer resp.Body.Close()
+		var doc openapi.Document
+		assert.NoError(json.NewDecoder(resp.Body).Decode(&doc), "Document should be JSON")
+		assert.Equal(openapi.Version, doc.OpenAPI, "OpenAPI version should be set")
+
+		section.Step(2, "Every endpoint is described")
+		operations := 0
+		for _, item := range doc.Paths {
+			operations += len(item)
+		}
+		assert.Equal(len(kademlia.Endpoints), operations, "Each endpoint should be one operation")
+		find := doc.Paths["/find_node"]["get"]
+		if assert.NotNil(find, "find_node should be described") {
+			var id *openapi.Parameter
+			for i := range find.Parameters {
+				if find.Parameters[i].Name == "id" {
+					id = &find.Parameters[i]
+				}
+			}
+			assert.True(id != nil && id.Required && id.Schema.Pattern == openapi.IDPattern, "Validation rules should become constraints")
+		}
+		assert.NotNil(doc.Components.Schemas["StoreAck"], "Named types should become components")
+
+		section.Step(3, "Every described path is served")
+		for _, ep := range kademlia.Endpoints {
+			path := strings.Replace(ep.Path, "{key}", "probe", 1)
+			req, _ := http.NewRequest(ep.Method, "http://"+node.Addr()+path, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(err, "%s %s should be answered", ep.Method, path) {
+				continue
+			}
+			resp.Body.Close()
+			assert.True(resp.StatusCode != http.StatusMethodNotAllowed, "%s %s should accept its method", ep.Method, path)
+			if !strings.HasPrefix(path, kademlia.GatewayKeysPath) {
+				assert.True(resp.StatusCode != http.StatusNotFound, "%s %s should be routed", ep.Method, path)
+			}
+		}
+
+		section.Success("Document described the served API")
+	})
+
+	t.Run("GeneratedClient", func(t *testing.T) {
+		section := logger.Section("Generated Client")
+
+		section.Step(1, "The checked-in client matches the document")
+		want, err := openapi.GenerateClient(kademlia.OpenAPI(), "apiclient", "cmd/apigen")
+		assert.NoError(err, "Client should generate")
+		got, err := os.ReadFile("../../pkg/apiclient/client_gen.go")
+		assert.NoError(err, "Generated client should exist")
+		assert.True(string(want) == string(got), "pkg/apiclient is stale, run go generate ./pkg/apiclient")
+		assert.Equal(middleware.AdminTokenHeader, apiclient.AdminTokenHeader, "Admin token header should match")
+		assert.Equal(middleware.APIKeyHeader, apiclient.APIKeyHeader, "API key header should match")
+
+		section.Step(2, "Call a node through the client")
+		c := apiclient.New(node.Addr())
+		pong, err := c.Ping(ctx, apiclient.PingQuery{})
+		assert.NoError(err, "Ping should succeed")
+		assert.Equal(node.Self.ID, pong.NodeID, "Ping should return the node ID")
+
+		key := fixtures.GenerateValidHexID("apiclient")
+		_, err = c.Store(ctx, apiclient.StoreQuery{}, apiclient.StoreRequest{Key: key, Value: "generated"})
+		assert.NoError(err, "Store should succeed")
+		raw, err := c.FindValue(ctx, apiclient.FindValueQuery{Key: key})
+		assert.NoError(err, "FindValue should succeed")
+		var value string
+		json.Unmarshal(raw, &value)
+		assert.Equal("generated", value, "Stored value should be found")
+
+		section.Step(3, "Errors and credentials")
+		_, err = c.FindNode(ctx, apiclient.FindNodeQuery{ID: "not-an-id"})
+		var apiErr *apiclient.APIError
+		assert.True(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest, "Invalid IDs should be an APIError, got %v", err)
+		_, err = c.AdminKeys(ctx, apiclient.AdminKeysQuery{})
+		assert.True(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden, "Admin calls should need the admin token, got %v", err)
+		c.AdminToken = "admin-secret"
+		keys, err := c.AdminKeys(ctx, apiclient.AdminKeysQuery{Prefix: key[:8]})
+		assert.NoError(err, "AdminKeys should succeed with the token")
+		assert.Equal(1, len(keys.Keys), "Stored key should be listed")
+
+		section.Success("Generated client called the node")
+	})
+}
@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestOwnership tests estimating the keyspace share of a node
+func TestOwnership(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "OWNERSHIP")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting keyspace ownership tests")
+
+	localID := "0000000000000000000000000000000000000000"
+	newTable := func(k int) *models.RoutingTable {
+		rt := kademlia.NewRoutingTableWithK(localID, k)
+		kademlia.AddNodeToRoutingTable(rt, &models.Node{ID: localID}, localID)
+		for _, id := range []string{
+			"8000000000000000000000000000000000000000",
+			"4000000000000000000000000000000000000000",
+			"2000000000000000000000000000000000000000",
+		} {
+			kademlia.AddNodeToRoutingTable(rt, &models.Node{ID: id}, localID)
+		}
+		return rt
+	}
+
+	t.Run("Estimate", func(t *testing.T) {
+		section := logger.Section("Estimate")
+
+		section.Step(1, "Share is the distance to the k-th closest contact")
+		o := kademlia.EstimateOwnership(newTable(2), localID)
+		assert.Equal(3, o.Contacts, "The node itself should not be counted")
+		assert.Equal(159, o.KthDistanceBits, "Second closest contact should be 2^158 away")
+		assert.Equal(0.25, o.Fraction, "A quarter of the keyspace should be owned")
+		assert.Equal(8.0, o.EstimatedNetworkSize, "k over the share should estimate the network size")
+		assert.False(o.UnderPopulated, "k contacts should be enough")
+
+		section.Step(2, "Fewer than k contacts own the whole keyspace")
+		o = kademlia.EstimateOwnership(newTable(20), localID)
+		assert.Equal(1.0, o.Fraction, "Every key should be owned")
+		assert.Equal(4.0, o.EstimatedNetworkSize, "Only known nodes should be counted")
+		assert.True(o.UnderPopulated, "Table should be reported under-populated")
+
+		section.Success("Ownership estimated")
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		section := logger.Section("Handler")
+
+		rr := httptest.NewRecorder()
+		kademlia.OwnershipHandler(rr, httptest.NewRequest("GET", "/ownership", nil), &models.Node{ID: localID}, newTable(2))
+
+		var o kademlia.Ownership
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &o), "Response should be JSON")
+		assert.Equal(localID, o.NodeID, "Response should name the node")
+		assert.Equal(0.25, o.Fraction, "Response should carry the estimate")
+
+		section.Success("Ownership served")
+	})
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPartitionDetection tests detecting a partition and rejoining through
+// seeds
+func TestPartitionDetection(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PARTITION")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting partition detection tests")
+
+	// serve starts a node answering PING and FIND_NODE at its address
+	serve := func(name string) (*models.Node, *httptest.Server) {
+		node := fixtures.CreateTestNode(0, name)
+		table := kademlia.NewRoutingTable(node.ID)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PingHandler(w, r, node, kademlia.NewKeyValueStore(), table)
+		})
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, node, table)
+		})
+		server := httptest.NewServer(mux)
+		addr := strings.TrimPrefix(server.URL, "http://")
+		node.IP = "127.0.0.1"
+		node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		return node, server
+	}
+	// dead returns a contact at an address nothing listens on
+	dead := func(name string, lastSeen int64) *models.Node {
+		node, server := serve(name)
+		server.Close()
+		node.LastSeen = lastSeen
+		return node
+	}
+
+	t.Run("HealthyNetwork", func(t *testing.T) {
+		section := logger.Section("Healthy Network")
+
+		remote, server := serve("remote")
+		defer server.Close()
+
+		local := fixtures.CreateTestNode(8080, "local")
+		rt := kademlia.NewRoutingTable(local.ID)
+		kademlia.AddNodeToRoutingTable(rt, remote, local.ID)
+		kademlia.AddNodeToRoutingTable(rt, dead("gone", 0), local.ID)
+
+		report := kademlia.NewPartitionDetector(local, rt, kademlia.PartitionConfig{Sample: 8, Threshold: 0.75}).Probe(context.Background())
+		assert.Equal(2, report.Probed, "Every contact should be probed")
+		assert.Equal(1, len(report.Unreachable), "Dead contact should be unreachable")
+		assert.False(report.Partitioned, "Half the contacts answering is no partition")
+
+		section.Success("No partition reported")
+	})
+
+	t.Run("SampleLongestUnseen", func(t *testing.T) {
+		section := logger.Section("Sample Longest Unseen")
+
+		local := fixtures.CreateTestNode(8080, "local")
+		rt := kademlia.NewRoutingTable(local.ID)
+		oldest, older := dead("oldest", 1), dead("older", 2)
+		for _, n := range []*models.Node{dead("recent", 4), oldest, dead("newer", 3), older} {
+			kademlia.AddNodeToRoutingTable(rt, n, local.ID)
+		}
+
+		report := kademlia.NewPartitionDetector(local, rt, kademlia.PartitionConfig{Sample: 2, Threshold: 0.75}).Probe(context.Background())
+		assert.Equal(2, report.Probed, "Probes should be limited to the sample")
+		assert.True(len(report.Unreachable) == 2 && report.Unreachable[0].ID == oldest.ID && report.Unreachable[1].ID == older.ID, "Least recently seen contacts should be probed")
+		assert.True(report.Partitioned, "All probes failing is a partition")
+		assert.False(report.Healed, "Without seeds the node cannot rejoin")
+
+		section.Success("Longest unseen contacts probed")
+	})
+
+	t.Run("RejoinThroughSeeds", func(t *testing.T) {
+		section := logger.Section("Rejoin Through Seeds")
+
+		seed, server := serve("seed")
+		defer server.Close()
+
+		local := fixtures.CreateTestNode(8080, "local")
+		rt := kademlia.NewRoutingTable(local.ID)
+		rt.Events = models.NewEventBus()
+		events, cancel := rt.Events.Subscribe(4, models.PartitionDetected, models.PartitionHealed)
+		defer cancel()
+		for i := 0; i < 3; i++ {
+			kademlia.AddNodeToRoutingTable(rt, dead("gone"+strconv.Itoa(i), 0), local.ID)
+		}
+
+		section.Step(1, "Unreachable contacts raise an event")
+		cfg := kademlia.PartitionConfig{Sample: 8, Threshold: 0.75, Seeds: []string{"127.0.0.1:1", server.Listener.Addr().String()}}
+		report := kademlia.NewPartitionDetector(local, rt, cfg).Probe(context.Background())
+		assert.True(report.Partitioned, "Partition should be detected")
+
+		select {
+		case e := <-events:
+			assert.Equal(models.PartitionDetected, e.Type, "Detection should be reported first")
+			assert.Equal(3, len(e.Nodes), "Event should list the unreachable contacts")
+		case <-time.After(time.Second):
+			assert.True(false, "PartitionDetected should be emitted")
+		}
+
+		section.Step(2, "The node rejoins through the first seed that answers")
+		assert.True(report.Healed, "Node should rejoin through the live seed")
+		closest := kademlia.FindClosestNodes(rt, seed.ID, local.ID, 0)
+		assert.True(len(closest) > 0 && closest[0].ID == seed.ID, "Seed should be added to the routing table")
+		select {
+		case e := <-events:
+			assert.Equal(models.PartitionHealed, e.Type, "Healing should be reported")
+		case <-time.After(time.Second):
+			assert.True(false, "PartitionHealed should be emitted")
+		}
+
+		section.Success("Partition healed")
+	})
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPeersHandler tests the peer discovery endpoint
+func TestPeersHandler(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PEERS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	originalK := constants.GetK()
+	constants.SetK(20)
+	defer constants.SetK(originalK)
+
+	logger.Info("Starting peer discovery tests")
+
+	t.Run("RandomSample", func(t *testing.T) {
+		section := logger.Section("Random Sample")
+
+		section.Step(1, "Setup populated routing table including self")
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := fixtures.CreatePopulatedRoutingTable(node.ID, 10)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+
+		section.Step(2, "Request a sample of 3 peers")
+		req := httptest.NewRequest("GET", "/peers?count=3", nil)
+		rr := httptest.NewRecorder()
+		kademlia.PeersHandler(rr, req, node, routingTable)
+		assert.Equal(http.StatusOK, rr.Code, "Should return 200 OK")
+
+		var peers []*models.Node
+		err := json.Unmarshal(rr.Body.Bytes(), &peers)
+		assert.NoError(err, "Response should be valid JSON")
+		assert.Equal(3, len(peers), "Should return the requested number of peers")
+		for _, p := range peers {
+			assert.NotEqual(node.ID, p.ID, "Local node should not be returned")
+		}
+
+		section.Success("Random sampling working correctly")
+	})
+
+	t.Run("DistanceBounded", func(t *testing.T) {
+		section := logger.Section("Distance Bounded")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := fixtures.CreatePopulatedRoutingTable(node.ID, 10)
+		target := kademlia.FindClosestNodes(routingTable, node.ID, node.ID, 0)[0]
+
+		section.Step(1, "Radius 0 around an existing peer returns only that peer")
+		peers := kademlia.SamplePeers(routingTable, target.ID, 0, 10, node.ID)
+		assert.Equal(1, len(peers), "Only the exact match lies within radius 0")
+		assert.Equal(target.ID, peers[0].ID, "Exact match should be returned")
+
+		section.Step(2, "Full radius returns every peer")
+		peers = kademlia.SamplePeers(routingTable, target.ID, 160, 100, node.ID)
+		assert.Equal(10, len(peers), "All peers lie within the full keyspace")
+
+		section.Success("Distance bounding working correctly")
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		section := logger.Section("Invalid Parameters")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+
+		for _, query := range []string{"key=xyz", "count=0", "count=abc", "radius=161", "radius=-1"} {
+			rr := httptest.NewRecorder()
+			kademlia.PeersHandler(rr, httptest.NewRequest("GET", "/peers?"+query, nil), node, routingTable)
+			assert.Equal(http.StatusBadRequest, rr.Code, "Should reject %s", query)
+		}
+
+		section.Success("Invalid parameters properly rejected")
+	})
+}
@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPeerSampling tests the sampling policies and cap of /peers
+func TestPeerSampling(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PEER_SAMPLING")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting peer sampling tests")
+
+	// peers asks the node's /peers with query and returns the status and list
+	peers := func(node *models.Node, table *models.RoutingTable, query string) (int, []*models.Node) {
+		rr := httptest.NewRecorder()
+		kademlia.PeersHandler(rr, httptest.NewRequest("GET", "/peers?"+query, nil), node, table)
+		var list []*models.Node
+		if rr.Code == http.StatusOK {
+			json.Unmarshal(rr.Body.Bytes(), &list)
+		}
+		return rr.Code, list
+	}
+
+	t.Run("Policies", func(t *testing.T) {
+		section := logger.Section("Sampling Policies")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		table := fixtures.CreatePopulatedRoutingTable(node.ID, 10)
+		contacts := kademlia.FindClosestNodes(table, node.ID, node.ID, 0)
+
+		section.Step(1, "Closest returns the contacts nearest the key first")
+		target := contacts[len(contacts)-1]
+		code, list := peers(node, table, "sample=closest&count=3&key="+target.ID)
+		assert.Equal(http.StatusOK, code, "Closest sampling should succeed")
+		assert.Equal(3, len(list), "Three peers should be returned")
+		if len(list) > 0 {
+			assert.Equal(target.ID, list[0].ID, "Peer at the key should come first")
+		}
+
+		section.Step(2, "Freshest returns the most recently seen contacts first")
+		fresh := contacts[3]
+		fresh.MarkSeen(time.Now().Add(time.Hour))
+		code, list = peers(node, table, "sample=freshest&count=1")
+		assert.Equal(http.StatusOK, code, "Freshest sampling should succeed")
+		if assert.Equal(1, len(list), "One peer should be returned") {
+			assert.Equal(fresh.ID, list[0].ID, "Most recently seen peer should be returned")
+		}
+
+		section.Step(3, "The node's policy applies when none is asked for")
+		table.PeerSampling = kademlia.SampleFreshest
+		_, list = peers(node, table, "count=1")
+		if len(list) == 1 {
+			assert.Equal(fresh.ID, list[0].ID, "Node's policy should be used")
+		}
+
+		section.Step(4, "Unknown policies are refused")
+		code, _ = peers(node, table, "sample=nearest")
+		assert.Equal(http.StatusBadRequest, code, "Unknown policy should be refused")
+
+		section.Success("Sampling policies working correctly")
+	})
+
+	t.Run("Cap", func(t *testing.T) {
+		section := logger.Section("Peer List Cap")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		table := fixtures.CreatePopulatedRoutingTable(node.ID, 10)
+		table.MaxExchangePeers = 4
+
+		section.Step(1, "Requests for more peers than the cap get the cap")
+		_, list := peers(node, table, "count=8")
+		assert.Equal(4, len(list), "List should be cut to the cap")
+
+		section.Step(2, "Registered samplers are selectable and capped")
+		kademlia.RegisterPeerSampler("everyone", func(candidates []*models.Node, target string, count int) []*models.Node {
+			return candidates
+		})
+		code, list := peers(node, table, "sample=everyone&count=8")
+		assert.Equal(http.StatusOK, code, "Registered sampler should be accepted")
+		assert.Equal(4, len(list), "Sampler returning more should still be cut to the cap")
+
+		section.Success("Peer list cap working correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Peer Sampling Configuration")
+
+		section.Step(1, "Nodes take the configured policy and cap")
+		cfg := config.Default()
+		cfg.PeerSampling = kademlia.SampleClosest
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		assert.Equal(kademlia.SampleClosest, n.RoutingTable.PeerSampling, "Node should apply the policy")
+		assert.Equal(50, n.RoutingTable.MaxExchangePeers, "Node should apply the default cap")
+
+		section.Step(2, "Settings are validated")
+		cfg.MaxExchangePeers = -1
+		assert.HasError(cfg.Validate(), "Negative cap should be refused")
+		cfg.MaxExchangePeers = 50
+		cfg.PeerSampling = "nearest"
+		unknown := kademlia.NewNode(cfg)
+		defer unknown.Storage.Close()
+		assert.HasError(unknown.Start(context.Background()), "Unknown policy should keep the node from starting")
+
+		section.Success("Peer sampling configuration working correctly")
+	})
+}
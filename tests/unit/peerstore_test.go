@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPeerStore tests the per-peer metadata kept beside the routing table
+func TestPeerStore(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PEERSTORE")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting peer store tests")
+
+	local := "8000000000000000000000000000000000000000"
+
+	t.Run("SharedContacts", func(t *testing.T) {
+		section := logger.Section("Shared Contacts")
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		section.Step(1, "The table holds the store's contact for an ID")
+		routingTable := kademlia.NewRoutingTable(local)
+		id := "0000000000000000000000000000000000000001"
+		key, _ := kademlia.GenerateRecordKey()
+		first := &models.Node{ID: id, IP: "10.0.0.1", Port: 8080}
+		assert.NoError(kademlia.SignNodeRecord(first, key, []string{"http://10.0.0.1:8080"}, nil), "Signing should succeed")
+		kademlia.AddNodeToRoutingTable(routingTable, first, local)
+		moved := &models.Node{ID: id, IP: "10.0.0.2", Port: 9090, Flags: models.FlagStorage, Record: first.Record}
+		assert.NoError(kademlia.SignNodeRecord(moved, key, []string{"http://10.0.0.2:9090"}, nil), "Signing should succeed")
+		kademlia.AddNodeToRoutingTable(routingTable, moved, local)
+		assert.Equal("10.0.0.2", first.IP, "The stored contact should follow the new address")
+		assert.Equal(models.FlagStorage, first.Flags, "The stored contact should take the new capabilities")
+		nodes := kademlia.FindClosestNodes(routingTable, id, local, 1)
+		assert.True(len(nodes) == 1 && nodes[0] == first, "Lookups should return the same contact")
+
+		section.Step(2, "Addresses are remembered, most recent first")
+		info, ok := routingTable.Peers.Peer(id)
+		assert.True(ok, "Peer should be stored")
+		assert.Equal("10.0.0.2:9090,10.0.0.1:8080", strings.Join(info.Addresses, ","), "Both addresses should be kept")
+		assert.True(info.InTable, "Peer should be in the table")
+
+		section.Step(3, "RPC outcomes are recorded")
+		routingTable.Peers.RecordRPC(first, errors.New("connection refused"))
+		routingTable.Peers.RecordRPC(first, errors.New("timeout"))
+		info, _ = routingTable.Peers.Peer(id)
+		assert.Equal(2, info.Failures, "Failures should be counted")
+		assert.Equal("timeout", info.LastError, "The last error should be kept")
+		fake.Advance(time.Second)
+		routingTable.Peers.RecordRPC(first, nil)
+		info, _ = routingTable.Peers.Peer(id)
+		assert.False(info.Failing(), "A reply should clear the failures")
+		assert.Equal(fake.Now(), info.LastReply, "The reply should be timed")
+
+		section.Success("Contacts are shared and their metadata kept")
+	})
+
+	t.Run("EvictionPrefersFailing", func(t *testing.T) {
+		section := logger.Section("Eviction Prefers Failing")
+
+		section.Step(1, "Fill a bucket of two")
+		routingTable := kademlia.NewRoutingTableWithK(local, 2)
+		oldest := &models.Node{ID: "0000000000000000000000000000000000000001", IP: "10.0.0.1", Port: 1}
+		failing := &models.Node{ID: "0000000000000000000000000000000000000002", IP: "10.0.0.2", Port: 2}
+		kademlia.AddNodeToRoutingTable(routingTable, oldest, local)
+		kademlia.AddNodeToRoutingTable(routingTable, failing, local)
+		routingTable.Peers.RecordRPC(failing, errors.New("timeout"))
+
+		section.Step(2, "The failing contact is evicted rather than the oldest")
+		kademlia.AddNodeToRoutingTable(routingTable, &models.Node{ID: "0000000000000000000000000000000000000003", IP: "10.0.0.3", Port: 3}, local)
+		ids := map[string]bool{}
+		for _, n := range kademlia.FindClosestNodes(routingTable, oldest.ID, local, 0) {
+			ids[n.ID] = true
+		}
+		assert.True(ids[oldest.ID], "The answering oldest contact should stay")
+		assert.False(ids[failing.ID], "The failing contact should be evicted")
+
+		section.Step(3, "Evicted peers stay in the store")
+		info, ok := routingTable.Peers.Peer(failing.ID)
+		assert.True(ok, "Evicted peer should still be stored")
+		assert.False(info.InTable, "Evicted peer should be out of the table")
+
+		section.Success("Failing contacts are evicted first")
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		section := logger.Section("Handler")
+
+		routingTable := kademlia.NewRoutingTable(local)
+		for _, id := range []string{"0000000000000000000000000000000000000001", "0000000000000000000000000000000000000002"} {
+			kademlia.AddNodeToRoutingTable(routingTable, &models.Node{ID: id, IP: "10.0.0.1", Port: 1}, local)
+		}
+		routingTable.Peers.RecordRPC(&models.Node{ID: "0000000000000000000000000000000000000003", IP: "10.0.0.3", Port: 3}, errors.New("refused"))
+		get := func(path string) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			kademlia.PeerStoreHandler(rr, httptest.NewRequest("GET", path, nil), routingTable)
+			return rr
+		}
+
+		section.Step(1, "List the peers seen")
+		var list kademlia.PeerStoreList
+		assert.NoError(json.Unmarshal(get("/peer_store?limit=2").Body.Bytes(), &list), "List should decode")
+		assert.Equal(3, list.Peers, "Peers queried but never added should be counted")
+		assert.Equal(2, list.InTable, "Added peers should be in the table")
+		assert.Equal(1, list.Failing, "The refused peer should be failing")
+		assert.Equal(2, len(list.List), "The list should honour the limit")
+
+		section.Step(2, "One peer, and an unknown one")
+		var info models.PeerInfo
+		assert.NoError(json.Unmarshal(get("/peer_store?id=0000000000000000000000000000000000000003").Body.Bytes(), &info), "Peer should decode")
+		assert.Equal("refused", info.LastError, "The peer's last error should be reported")
+		assert.Equal("10.0.0.3:3", strings.Join(info.Addresses, ","), "The queried address should be kept")
+		assert.Equal(http.StatusNotFound, get("/peer_store?id=0000000000000000000000000000000000000009").Code, "Unknown peers should be 404")
+
+		section.Success("Peer store served")
+	})
+}
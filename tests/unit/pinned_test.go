@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPinnedPeers tests that pinned peers stay in the routing table and are
+// re-dialed
+func TestPinnedPeers(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PINNED")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting pinned peer tests")
+
+	localID := "0000000000000000000000000000000000000000"
+	contains := func(rt *models.RoutingTable, id string) bool {
+		for _, bucket := range rt.Buckets {
+			for _, n := range bucket.Nodes {
+				if n.ID == id {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	t.Run("NeverEvicted", func(t *testing.T) {
+		section := logger.Section("Never Evicted")
+
+		rt := kademlia.NewRoutingTableWithK(localID, 2)
+		pinned := &models.Node{ID: "8000000000000000000000000000000000000001"}
+		other := &models.Node{ID: "8000000000000000000000000000000000000002"}
+		kademlia.AddNodeToRoutingTable(rt, pinned, localID)
+		kademlia.AddNodeToRoutingTable(rt, other, localID)
+		kademlia.PinNode(rt, pinned.ID)
+
+		section.Step(1, "A full bucket evicts the oldest unpinned contact")
+		newcomer := &models.Node{ID: "8000000000000000000000000000000000000003"}
+		kademlia.AddNodeToRoutingTable(rt, newcomer, localID)
+		assert.True(contains(rt, pinned.ID), "Pinned contact should stay")
+		assert.False(contains(rt, other.ID), "Unpinned contact should be evicted")
+		assert.True(contains(rt, newcomer.ID), "New contact should be added")
+
+		section.Step(2, "A bucket of pinned contacts refuses new contacts")
+		kademlia.PinNode(rt, newcomer.ID)
+		kademlia.AddNodeToRoutingTable(rt, other, localID)
+		assert.True(contains(rt, pinned.ID) && contains(rt, newcomer.ID), "Pinned contacts should stay")
+		assert.False(contains(rt, other.ID), "New contact should be dropped")
+
+		section.Success("Pinned contacts survive eviction")
+	})
+
+	t.Run("Redial", func(t *testing.T) {
+		section := logger.Section("Redial")
+
+		remote := fixtures.CreateTestNode(8080, "pinned")
+		var down int32 = 1
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&down) == 1 {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			kademlia.PingHandler(w, r, remote, kademlia.NewKeyValueStore(), kademlia.NewRoutingTable(remote.ID))
+		}))
+		defer server.Close()
+
+		local := fixtures.CreateTestNode(8081, "local")
+		rt := kademlia.NewRoutingTable(local.ID)
+		peers := kademlia.NewPinnedPeers(local, rt, []string{server.Listener.Addr().String()})
+
+		section.Step(1, "Unreachable peers are retried on the next round")
+		assert.Equal(0, peers.Dial(context.Background()), "Unreachable peer should not be added")
+		atomic.StoreInt32(&down, 0)
+		assert.Equal(1, peers.Dial(context.Background()), "Peer should be added once it answers")
+		assert.True(contains(rt, remote.ID) && rt.Pinned[remote.ID], "Peer should be pinned")
+		assert.Equal(0, peers.Dial(context.Background()), "Present peer should not count as re-dialed")
+
+		section.Step(2, "A peer that restarts with a new ID replaces its old contact")
+		oldID := remote.ID
+		remote.ID = fixtures.CreateTestNode(8080, "restarted").ID
+		peers.Dial(context.Background())
+		assert.False(contains(rt, oldID) || rt.Pinned[oldID], "Old contact should be dropped and unpinned")
+		assert.True(contains(rt, remote.ID) && rt.Pinned[remote.ID], "New contact should be pinned")
+
+		section.Success("Pinned peers re-dialed")
+	})
+}
@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestProviderRecords tests ADD_PROVIDER / GET_PROVIDERS
+func TestProviderRecords(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PROVIDERS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting provider record tests")
+
+	t.Run("AddAndGetProviders", func(t *testing.T) {
+		section := logger.Section("Add And Get Providers")
+
+		section.Step(1, "Setup node")
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		providers := kademlia.NewProviderStore()
+		key := fixtures.GenerateValidHexID("content")
+
+		section.Step(2, "Announce a provider naming another host")
+		provider := fixtures.CreateTestNode(9000, "provider")
+		body, _ := json.Marshal(map[string]interface{}{"key": key, "id": provider.ID, "ip": "203.0.113.9", "port": provider.Port})
+		req := httptest.NewRequest("POST", "/add_provider", bytes.NewBuffer(body))
+		req.RemoteAddr = "10.0.0.7:4444"
+		rr := httptest.NewRecorder()
+		kademlia.AddProviderHandler(rr, req, node, providers)
+		assert.Equal(http.StatusCreated, rr.Code, "Should return 201 Created")
+
+		section.Step(3, "Fetch providers")
+		rr = httptest.NewRecorder()
+		kademlia.GetProvidersHandler(rr, httptest.NewRequest("GET", "/get_providers?key="+key, nil), node, providers, routingTable)
+		assert.Equal(http.StatusOK, rr.Code, "Should return 200 OK")
+
+		var resp struct {
+			Providers    []models.Node  `json:"providers"`
+			ClosestNodes []*models.Node `json:"closest_nodes"`
+		}
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		assert.NoError(err, "Response should be valid JSON")
+		assert.Equal(1, len(resp.Providers), "Should return one provider")
+		assert.Equal("10.0.0.7", resp.Providers[0].IP, "Provider IP should be the sender address, not the claimed one")
+		assert.Equal(1, len(resp.ClosestNodes), "Should include closest nodes")
+
+		section.Success("Provider records working correctly")
+	})
+
+	t.Run("SizeCapAndTTL", func(t *testing.T) {
+		section := logger.Section("Size Cap And TTL")
+
+		section.Step(1, "Fill a store capped at 2 providers")
+		store := models.NewProviderStore(time.Hour, 2, 0)
+		key := fixtures.GenerateValidHexID("content")
+		for i, p := range fixtures.CreateTestNodes(3, 9000) {
+			store.Add(key, *p)
+			section.Info("Added provider %d", i)
+		}
+		assert.Equal(2, len(store.Get(key)), "Store should respect the per-key cap")
+
+		section.Step(2, "Expired records are dropped")
+		expiring := models.NewProviderStore(-time.Second, 2, 0)
+		expiring.Add(key, *fixtures.CreateTestNode(9000, "expired"))
+		assert.Equal(0, len(expiring.Get(key)), "Expired providers should not be returned")
+
+		section.Success("Size cap and TTL enforced")
+	})
+
+	t.Run("GlobalCapAndSweep", func(t *testing.T) {
+		section := logger.Section("Global Cap And Sweep")
+
+		section.Step(1, "Fill a store capped at 2 records")
+		store := models.NewProviderStore(time.Hour, 2, 2)
+		provider := *fixtures.CreateTestNode(9000, "provider")
+		first, second := fixtures.GenerateValidHexID("first"), fixtures.GenerateValidHexID("second")
+		assert.NoError(store.Add(first, provider), "First record should be added")
+		assert.NoError(store.Add(second, provider), "Second record should be added")
+		err := store.Add(fixtures.GenerateValidHexID("third"), provider)
+		assert.True(errors.Is(err, models.ErrProvidersFull), "Records beyond the cap should be refused")
+		assert.NoError(store.Add(first, provider), "Known records should still be refreshed")
+		assert.Equal(2, store.Len(), "Store should hold 2 records")
+
+		section.Step(2, "Sweeps drop expired records of keys never looked up again")
+		expiring := models.NewProviderStore(-time.Second, 2, 0)
+		for _, p := range fixtures.CreateTestNodes(3, 9000) {
+			expiring.Add(fixtures.GenerateValidHexID(p.ID), *p)
+		}
+		assert.Equal(3, expiring.Len(), "Expired records should be held until swept")
+		assert.Equal(3, expiring.Sweep(), "Sweep should drop every expired record")
+		assert.Equal(0, expiring.Len(), "Store should be empty after the sweep")
+
+		section.Step(3, "Full stores refuse announcements")
+		node := fixtures.CreateTestNode(8080, "local")
+		body, _ := json.Marshal(map[string]interface{}{"key": fixtures.GenerateValidHexID("fourth"), "id": provider.ID, "port": provider.Port})
+		rr := httptest.NewRecorder()
+		kademlia.AddProviderHandler(rr, httptest.NewRequest("POST", "/add_provider", bytes.NewBuffer(body)), node, store)
+		assert.Equal(http.StatusInsufficientStorage, rr.Code, "Full store should refuse with 507")
+
+		section.Success("Global cap and sweep enforced")
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		section := logger.Section("Invalid Requests")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		providers := kademlia.NewProviderStore()
+
+		rr := httptest.NewRecorder()
+		kademlia.AddProviderHandler(rr, httptest.NewRequest("GET", "/add_provider", nil), node, providers)
+		assert.Equal(http.StatusMethodNotAllowed, rr.Code, "GET add_provider should be rejected")
+
+		body, _ := json.Marshal(map[string]interface{}{"key": "bad", "id": node.ID, "port": 80})
+		rr = httptest.NewRecorder()
+		kademlia.AddProviderHandler(rr, httptest.NewRequest("POST", "/add_provider", bytes.NewBuffer(body)), node, providers)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Invalid key should be rejected")
+
+		rr = httptest.NewRecorder()
+		kademlia.GetProvidersHandler(rr, httptest.NewRequest("GET", "/get_providers", nil), node, providers, routingTable)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Missing key should be rejected")
+
+		section.Success("Invalid requests properly rejected")
+	})
+}
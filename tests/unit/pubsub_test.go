@@ -0,0 +1,282 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPubSub tests topic-based publish/subscribe
+func TestPubSub(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PUBSUB")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting pub/sub tests")
+
+	t.Run("SubscriberListStoredAsRecord", func(t *testing.T) {
+		section := logger.Section("Subscriber List Stored As Record")
+
+		section.Step(1, "Subscribe two subscribers")
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+		pubsub.Subscribe(storage, "news", models.Subscriber{ID: "alice"})
+		pubsub.Subscribe(storage, "news", models.Subscriber{ID: "bob"})
+		pubsub.Subscribe(storage, "news", models.Subscriber{ID: "alice"})
+
+		section.Step(2, "Verify record under topic key")
+		raw, exists := storage.Get(kademlia.TopicKey("news"))
+		assert.True(exists, "Subscriber list should be stored under the topic key")
+		assert.Contains(raw, "alice", "Record should contain alice")
+
+		subscribers := kademlia.Subscribers(storage, "news")
+		assert.Equal(2, len(subscribers), "Duplicate subscriptions should be merged")
+
+		section.Success("Subscriber list stored correctly")
+	})
+
+	t.Run("PublishAndPoll", func(t *testing.T) {
+		section := logger.Section("Publish And Poll")
+
+		section.Step(1, "Subscribe and publish")
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+		pubsub.Subscribe(storage, "news", models.Subscriber{ID: "alice"})
+		delivered := pubsub.Publish(storage, "news", "hello")
+		assert.Equal(1, delivered, "Message should be delivered to one subscriber")
+
+		section.Step(2, "Poll mailbox")
+		req := httptest.NewRequest("GET", "/poll?topic=news&subscriber_id=alice&timeout=0", nil)
+		rr := httptest.NewRecorder()
+		kademlia.PollHandler(rr, req, pubsub)
+		assert.Equal(http.StatusOK, rr.Code, "Should return 200 OK")
+
+		var messages []models.TopicMessage
+		err := json.Unmarshal(rr.Body.Bytes(), &messages)
+		assert.NoError(err, "Response should be valid JSON")
+		assert.Equal(1, len(messages), "Should receive one message")
+		assert.Equal("hello", messages[0].Payload, "Payload should match")
+
+		section.Success("Publish and poll working correctly")
+	})
+
+	t.Run("LongPollWakesOnPublish", func(t *testing.T) {
+		section := logger.Section("Long Poll Wakes On Publish")
+
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+		pubsub.Subscribe(storage, "news", models.Subscriber{ID: "alice"})
+
+		section.Step(1, "Publish after a short delay")
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			pubsub.Publish(storage, "news", "late")
+		}()
+
+		section.Step(2, "Wait for message")
+		start := time.Now()
+		req := httptest.NewRequest("GET", "/poll?topic=news&subscriber_id=alice&timeout=5", nil)
+		rr := httptest.NewRecorder()
+		kademlia.PollHandler(rr, req, pubsub)
+		assert.True(time.Since(start) < 5*time.Second, "Poll should return before timeout")
+		assert.Contains(rr.Body.String(), "late", "Poll should return the published message")
+
+		section.Success("Long poll woke on publish")
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		section := logger.Section("Invalid Requests")
+
+		node := fixtures.CreateTestNode(8080, "test")
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+
+		rr := httptest.NewRecorder()
+		kademlia.SubscribeHandler(rr, httptest.NewRequest("GET", "/subscribe", nil), node, storage, kademlia.NewRoutingTable(node.ID), pubsub)
+		assert.Equal(http.StatusMethodNotAllowed, rr.Code, "GET subscribe should be rejected")
+
+		rr = httptest.NewRecorder()
+		kademlia.PublishHandler(rr, httptest.NewRequest("POST", "/publish", bytes.NewBufferString("{}")), node, storage, pubsub)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Publish without topic should be rejected")
+
+		rr = httptest.NewRecorder()
+		kademlia.PollHandler(rr, httptest.NewRequest("GET", "/poll?topic=news", nil), pubsub)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Poll without subscriber should be rejected")
+
+		section.Success("Invalid requests properly rejected")
+	})
+
+	t.Run("ClientRoundTrip", func(t *testing.T) {
+		section := logger.Section("Client Round Trip")
+
+		section.Step(1, "Start node server")
+		node := fixtures.CreateTestNode(0, "rendezvous")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+		pubsub.Webhooks = kademlia.NewWebhooks(true)
+
+		webhookHits := make(chan models.TopicMessage, 1)
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var msg models.TopicMessage
+			json.NewDecoder(r.Body).Decode(&msg)
+			webhookHits <- msg
+		}))
+		defer webhook.Close()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, node, routingTable)
+		})
+		mux.HandleFunc("/subscribe", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SubscribeHandler(w, r, node, storage, routingTable, pubsub)
+		})
+		mux.HandleFunc("/publish", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PublishHandler(w, r, node, storage, pubsub)
+		})
+		mux.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PollHandler(w, r, pubsub)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		addr := strings.TrimPrefix(server.URL, "http://")
+		node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+
+		section.Step(2, "Subscribe, publish and poll through the client")
+		ctx := context.Background()
+		c := client.NewClient(addr)
+		assert.NoError(c.Subscribe(ctx, "news", "alice", webhook.URL), "Subscribe should succeed")
+
+		delivered, err := c.Publish(ctx, "news", "hello")
+		assert.NoError(err, "Publish should succeed")
+		assert.Equal(1, delivered, "Message should reach one subscriber")
+
+		messages, err := c.Poll(ctx, "news", "alice", time.Second)
+		assert.NoError(err, "Poll should succeed")
+		assert.Equal(1, len(messages), "Should receive one message")
+
+		section.Step(3, "Verify webhook notification")
+		select {
+		case msg := <-webhookHits:
+			assert.Equal("hello", msg.Payload, "Webhook should receive payload")
+		case <-time.After(2 * time.Second):
+			t.Fatal("Webhook was not notified")
+		}
+
+		section.Success("Client round trip working correctly")
+	})
+	t.Run("Limits", func(t *testing.T) {
+		section := logger.Section("Pub/Sub Limits")
+
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+
+		section.Step(1, "Webhooks on private addresses are refused")
+		for _, hook := range []string{"http://127.0.0.1:9000/hook", "http://10.0.0.5/hook", "http://169.254.169.254/latest", "file:///etc/passwd"} {
+			_, err := pubsub.Subscribe(storage, "news", models.Subscriber{ID: "mallory", Webhook: hook})
+			assert.True(errors.Is(err, kademlia.ErrWebhookTarget), "Webhook "+hook+" should be refused")
+		}
+		assert.Equal(0, len(kademlia.Subscribers(storage, "news")), "Refused subscriber should not be listed")
+
+		section.Step(2, "Mailboxes keep only the newest messages")
+		pubsub.Subscribe(storage, "news", models.Subscriber{ID: "alice"})
+		for i := 0; i < kademlia.MaxMailbox+5; i++ {
+			pubsub.Publish(storage, "news", strconv.Itoa(i))
+		}
+		messages := pubsub.Poll(context.Background(), "news", "alice", 0)
+		assert.Equal(kademlia.MaxMailbox, len(messages), "Mailbox should be capped")
+		assert.Equal("5", messages[0].Payload, "Oldest messages should be dropped")
+
+		section.Step(3, "Subscriber lists are OR-set records")
+		raw, _ := storage.Get(kademlia.TopicKey("news"))
+		_, err := models.ParseCRDTRecord(raw, models.RecordORSet)
+		assert.NoError(err, "Subscriber list should be an OR-set record")
+
+		section.Success("Pub/sub limits working correctly")
+	})
+
+	// serve starts a node serving lookups, storage and pub/sub, and adds it
+	// to its own routing table
+	serve := func(id string) (*models.Node, *models.RoutingTable, *models.KeyValueStore) {
+		node := &models.Node{ID: id, IP: "127.0.0.1", Flags: models.DefaultFlags}
+		routingTable := kademlia.NewRoutingTable(id)
+		storage := kademlia.NewKeyValueStore()
+		pubsub := kademlia.NewPubSub()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, node, routingTable)
+		})
+		mux.HandleFunc("/node_info", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.NodeInfoHandler(w, r, node, routingTable, storage, time.Now())
+		})
+		mux.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, node, storage, routingTable)
+		})
+		mux.HandleFunc("/subscribe", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.SubscribeHandler(w, r, node, storage, routingTable, pubsub)
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		addr := strings.TrimPrefix(server.URL, "http://")
+		node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		kademlia.AddNodeToRoutingTable(routingTable, node, id)
+		return node, routingTable, storage
+	}
+	// near returns key with its hex digit at pos XORed with mask
+	near := func(key string, pos int, mask byte) string {
+		digit, _ := strconv.ParseUint(key[pos:pos+1], 16, 8)
+		return key[:pos] + strconv.FormatUint(digit^uint64(mask), 16) + key[pos+1:]
+	}
+
+	t.Run("Replication", func(t *testing.T) {
+		section := logger.Section("Subscriber List Replication")
+
+		key := kademlia.TopicKey("news")
+		rendezvous, table, _ := serve(near(key, 39, 1))
+		replica, _, replicaStorage := serve(near(key, 39, 2))
+		kademlia.AddNodeToRoutingTable(table, replica, rendezvous.ID)
+
+		section.Step(1, "Subscribing stores the list on the closest nodes")
+		c := client.NewClient(fmt.Sprintf("127.0.0.1:%d", rendezvous.Port))
+		assert.NoError(c.Subscribe(context.Background(), "news", "alice", ""), "Subscribe should succeed")
+		subscribers := kademlia.Subscribers(replicaStorage, "news")
+		assert.Equal(1, len(subscribers), "Replica should hold the subscriber list")
+
+		section.Success("Subscriber list replicated correctly")
+	})
+
+	t.Run("Rendezvous", func(t *testing.T) {
+		section := logger.Section("Rendezvous Lookup")
+
+		key := kademlia.TopicKey("sports")
+		entry, entryTable, entryStorage := serve(near(key, 0, 8))
+		hop, hopTable, hopStorage := serve(near(key, 0, 1))
+		closest, _, closestStorage := serve(near(key, 39, 1))
+		kademlia.AddNodeToRoutingTable(entryTable, hop, entry.ID)
+		kademlia.AddNodeToRoutingTable(hopTable, closest, hop.ID)
+
+		section.Step(1, "Clients find the rendezvous through contacts the entry node does not know")
+		c := client.NewClient(fmt.Sprintf("127.0.0.1:%d", entry.Port))
+		assert.NoError(c.Subscribe(context.Background(), "sports", "bob", ""), "Subscribe should succeed")
+		assert.Equal(1, len(kademlia.Subscribers(closestStorage, "sports")), "Closest node should be the rendezvous")
+		assert.Equal(0, len(kademlia.Subscribers(entryStorage, "sports")), "Entry node should not be the rendezvous")
+		assert.Equal(0, len(kademlia.Subscribers(hopStorage, "sports")), "Intermediate node should not be the rendezvous")
+
+		section.Success("Rendezvous found correctly")
+	})
+}
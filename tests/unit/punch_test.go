@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestHolePunching tests endpoint exchange through a coordinator and
+// simultaneous UDP probing
+func TestHolePunching(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "PUNCH")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting hole punching tests")
+
+	newPuncher := func(id string) *kademlia.HolePuncher {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		assert.NoError(err, "UDP socket should open")
+		return kademlia.NewHolePuncher(id, conn)
+	}
+	contactFor := func(server *httptest.Server, id string) *models.Node {
+		addr := strings.TrimPrefix(server.URL, "http://")
+		port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		return &models.Node{ID: id, IP: "127.0.0.1", Port: port}
+	}
+
+	t.Run("PunchThroughCoordinator", func(t *testing.T) {
+		section := logger.Section("Punch Through Coordinator")
+
+		section.Step(1, "Start the target and a coordinator that knows it")
+		target := fixtures.CreateTestNode(8080, "target")
+		targetPuncher := newPuncher(target.ID)
+		defer targetPuncher.Close()
+		targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PunchNotifyHandler(w, r, target, targetPuncher)
+		}))
+		defer targetServer.Close()
+
+		coordinator := fixtures.CreateTestNode(8080, "coordinator")
+		routingTable := kademlia.NewRoutingTable(coordinator.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, contactFor(targetServer, target.ID), coordinator.ID)
+		coordinatorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PunchHandler(w, r, coordinator, routingTable, kademlia.NewRelay())
+		}))
+		defer coordinatorServer.Close()
+
+		section.Step(2, "Initiator punches through to the target")
+		initiatorID := fixtures.GenerateValidHexID("initiator")
+		initiator := newPuncher(initiatorID)
+		defer initiator.Close()
+
+		addr, err := initiator.Punch(context.Background(), contactFor(coordinatorServer, coordinator.ID), target.ID)
+		assert.NoError(err, "Punch should succeed")
+		if assert.True(addr != nil, "Target address should be returned") {
+			assert.Equal(targetPuncher.LocalEndpoint(), addr.String(), "Path should lead to the target's socket")
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for targetPuncher.Established(initiatorID) == nil && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(targetPuncher.Established(initiatorID) != nil, "Target should have a path back to the initiator")
+
+		section.Step(3, "Unknown targets are reported")
+		_, err = initiator.Punch(context.Background(), contactFor(coordinatorServer, coordinator.ID), fixtures.GenerateValidHexID("unknown"))
+		assert.HasError(err, "Punching an unknown target should fail")
+
+		section.Success("Peers open a direct path")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		section := logger.Section("Disabled")
+
+		node := fixtures.CreateTestNode(8080, "plain")
+		body := `{"from":"` + fixtures.GenerateValidHexID("from") + `","to":"` + node.ID + `","endpoint":"127.0.0.1:9"}`
+		rr := httptest.NewRecorder()
+		kademlia.PunchNotifyHandler(rr, httptest.NewRequest("POST", "/punch_notify", strings.NewReader(body)), node, nil)
+		assert.Equal(http.StatusNotImplemented, rr.Code, "Nodes without a punch socket should say so")
+
+		_, err := kademlia.NewNode(nil).Punch(context.Background(), node, node.ID)
+		assert.Equal(kademlia.ErrHolePunchingDisabled, err, "Node without a punch port should refuse")
+
+		section.Success("Disabled hole punching is reported")
+	})
+}
@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestNodeRecords tests signed node records and their exchange over PING
+func TestNodeRecords(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "RECORDS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting node record tests")
+
+	newKey := func() ed25519.PrivateKey {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(err, "Key generation should succeed")
+		return key
+	}
+
+	t.Run("SignAndVerify", func(t *testing.T) {
+		section := logger.Section("Sign And Verify")
+
+		node := fixtures.CreateTestNode(8080, "signed")
+		key := newKey()
+		assert.NoError(kademlia.SignNodeRecord(node, key, []string{"http://127.0.0.1:8080"}, []string{models.CapKademliaHTTP}), "Signing should succeed")
+		assert.NoError(node.Record.Verify(), "Fresh record should verify")
+		assert.True(node.Record.Has(models.CapKademliaHTTP), "Capability should be advertised")
+		assert.False(node.Record.Has(models.CapMainline), "Other capabilities should not be advertised")
+
+		section.Step(1, "Tampering breaks the signature")
+		forged := *node.Record
+		forged.Endpoints = []string{"http://10.0.0.66:8080"}
+		assert.HasError(forged.Verify(), "Tampered record should not verify")
+
+		section.Step(2, "Only newer records from the same key supersede")
+		old := node.Record
+		assert.NoError(kademlia.SignNodeRecord(node, key, nil, nil), "Re-signing should succeed")
+		assert.Equal(old.Seq+1, node.Record.Seq, "Sequence should increase")
+		assert.True(node.Record.Supersedes(old), "Newer record should supersede")
+		assert.False(old.Supersedes(node.Record), "Older record should not supersede")
+
+		other := fixtures.CreateTestNode(8080, "signed")
+		other.Record = &models.NodeRecord{Seq: 100}
+		assert.NoError(kademlia.SignNodeRecord(other, newKey(), nil, nil), "Signing should succeed")
+		assert.False(other.Record.Supersedes(old), "A different key should not supersede")
+
+		section.Success("Records signed and verified")
+	})
+
+	t.Run("ExchangedDuringPing", func(t *testing.T) {
+		section := logger.Section("Exchanged During Ping")
+
+		remote := fixtures.CreateTestNode(8080, "remote")
+		assert.NoError(kademlia.SignNodeRecord(remote, newKey(), nil, []string{models.CapBencode}), "Signing should succeed")
+		remoteTable := kademlia.NewRoutingTable(remote.ID)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.PingHandler(w, r, remote, kademlia.NewKeyValueStore(), remoteTable)
+		}))
+		defer server.Close()
+
+		section.Step(1, "Join sends our record and receives the remote record")
+		local := fixtures.CreateTestNode(9090, "local")
+		assert.NoError(kademlia.SignNodeRecord(local, newKey(), nil, []string{models.CapMainline}), "Signing should succeed")
+		localTable := kademlia.NewRoutingTable(local.ID)
+		addr := server.Listener.Addr().String()
+		assert.NoError(kademlia.JoinNetwork(context.Background(), local, localTable, addr), "Join should succeed")
+
+		closest := kademlia.FindClosestNodes(localTable, remote.ID, local.ID, 0)
+		assert.True(closest[0].Record.Has(models.CapBencode), "Remote record should be stored with the contact")
+
+		closest = kademlia.FindClosestNodes(remoteTable, local.ID, remote.ID, 0)
+		assert.True(closest[0].Record.Has(models.CapMainline), "Our record should be stored by the remote")
+
+		section.Step(2, "Forged records are rejected")
+		forged := *local.Record
+		forged.Capabilities = []string{"admin"}
+		header, _ := kademlia.EncodeRecordHeader(&forged)
+		req := httptest.NewRequest("GET", "/ping?id="+local.ID+"&port=9090", nil)
+		req.Header.Set(kademlia.RecordHeader, header)
+		rr := httptest.NewRecorder()
+		kademlia.PingHandler(rr, req, remote, kademlia.NewKeyValueStore(), remoteTable)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Forged record should be rejected")
+
+		section.Success("Records exchanged during PING")
+	})
+}
@@ -0,0 +1,150 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestRecordIntervals tests STOREs setting their record's expire and
+// republish intervals
+func TestRecordIntervals(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "RECORD_INTERVALS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting record interval tests")
+
+	bounds := models.RecordIntervals{MinTTL: time.Minute, MaxTTL: time.Hour, MinRepublish: time.Minute, MaxRepublish: time.Hour}
+
+	// store sends req to the node's /store without replication
+	store := func(node *models.Node, storage *models.KeyValueStore, req kademlia.StoreRequest) int {
+		body, _ := json.Marshal(req)
+		table := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(table, node, node.ID)
+		rr := httptest.NewRecorder()
+		kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", bytes.NewReader(body)), node, storage, table)
+		return rr.Code
+	}
+
+	t.Run("Store", func(t *testing.T) {
+		section := logger.Section("Storing With Intervals")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		storage := kademlia.NewKeyValueStore()
+		storage.Intervals = bounds
+		key := fixtures.GenerateValidHexID("presence")
+
+		section.Step(1, "Intervals are kept with the record")
+		code := store(node, storage, kademlia.StoreRequest{Key: key, Value: "online", TTL: 120, Republish: 60})
+		assert.Equal(http.StatusCreated, code, "Store should succeed")
+		meta, _ := storage.Meta(key)
+		assert.Equal(120, meta.TTL, "TTL should be kept")
+		assert.Equal(60, meta.Republish, "Republish interval should be kept")
+
+		section.Step(2, "Intervals are clamped to the node's bounds")
+		store(node, storage, kademlia.StoreRequest{Key: key, Value: "online", TTL: 5, Republish: 86400})
+		meta, _ = storage.Meta(key)
+		assert.Equal(60, meta.TTL, "Short TTL should be raised to the minimum")
+		assert.Equal(3600, meta.Republish, "Long republish interval should be cut to the maximum")
+
+		section.Step(3, "A write without intervals restores the defaults")
+		store(node, storage, kademlia.StoreRequest{Key: key, Value: "away"})
+		meta, _ = storage.Meta(key)
+		assert.Equal(0, meta.TTL, "TTL should fall back to the default")
+		assert.Equal(0, meta.Republish, "Republish interval should fall back to the default")
+
+		section.Step(4, "Negative intervals are refused")
+		code = store(node, storage, kademlia.StoreRequest{Key: key, Value: "online", TTL: -1})
+		assert.Equal(http.StatusBadRequest, code, "Negative TTL should be refused")
+
+		section.Success("Storing with intervals working correctly")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		section := logger.Section("Expiry With Intervals")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		node := fixtures.CreateTestNode(8080, "local")
+		storage := kademlia.NewKeyValueStore()
+		storage.Intervals = bounds
+		presence, content := fixtures.GenerateValidHexID("presence"), fixtures.GenerateValidHexID("content")
+		store(node, storage, kademlia.StoreRequest{Key: presence, Value: "online", TTL: 120})
+		store(node, storage, kademlia.StoreRequest{Key: content, Value: "page"})
+
+		section.Step(1, "A record expires after its own TTL")
+		gc := kademlia.NewGarbageCollector(storage, node.ID, kademlia.GCConfig{TTL: 24 * time.Hour})
+		fake.Advance(3 * time.Minute)
+		assert.Equal(1, gc.Collect(), "Only the presence record should expire")
+		_, found := storage.Get(presence)
+		assert.False(found, "Presence record should be gone")
+		_, found = storage.Get(content)
+		assert.True(found, "Content record should be kept")
+
+		section.Step(2, "Records with their own TTL expire even without a default")
+		store(node, storage, kademlia.StoreRequest{Key: presence, Value: "online", TTL: 120})
+		forever := kademlia.NewGarbageCollector(storage, node.ID, kademlia.GCConfig{})
+		fake.Advance(3 * time.Minute)
+		assert.Equal(1, forever.Collect(), "Presence record should expire")
+		assert.Equal(1, storage.Len(), "Content record should be kept")
+
+		section.Step(3, "Exports date the expiry by the record's TTL")
+		store(node, storage, kademlia.StoreRequest{Key: presence, Value: "online", TTL: 120})
+		var export bytes.Buffer
+		assert.NoError(kademlia.ExportRecords(&export, storage, node.ID, 24*time.Hour), "Export should succeed")
+		imported := kademlia.NewKeyValueStore()
+		imported.Intervals = bounds
+		_, err := kademlia.ImportRecords(&export, imported, false)
+		assert.NoError(err, "Import should succeed")
+		meta, _ := imported.Meta(presence)
+		assert.Equal(120, meta.TTL, "TTL should survive an export")
+		for _, rec := range imported.Records() {
+			if rec.Key == presence {
+				assert.True(rec.StoredAt.Add(models.Seconds(rec.TTL)).Equal(clock.Now().Add(2*time.Minute)), "Record should expire two minutes after its store")
+			}
+		}
+
+		section.Success("Expiry with intervals working correctly")
+	})
+
+	t.Run("Bounds", func(t *testing.T) {
+		section := logger.Section("Interval Bounds")
+
+		section.Step(1, "Intervals are moved within the bounds")
+		ttl, republish := bounds.Clamp(30*time.Second, 2*time.Hour)
+		assert.Equal(time.Minute, ttl, "TTL should be raised to the minimum")
+		assert.Equal(time.Hour, republish, "Republish interval should be cut to the maximum")
+		ttl, republish = bounds.Clamp(0, 10*time.Minute)
+		assert.Equal(time.Duration(0), ttl, "0 should keep the default")
+		assert.Equal(10*time.Minute, republish, "Interval within the bounds should be kept")
+		ttl, _ = models.RecordIntervals{}.Clamp(time.Second, 0)
+		assert.Equal(time.Second, ttl, "No bounds should keep any interval")
+
+		section.Step(2, "Bounds are validated")
+		cfg := config.Default()
+		cfg.RecordIntervals.MinTTL = -time.Second
+		assert.HasError(cfg.Validate(), "Negative bound should be refused")
+		cfg.RecordIntervals.MinTTL = 48 * time.Hour
+		assert.HasError(cfg.Validate(), "Minimum above the maximum should be refused")
+		cfg.RecordIntervals.MaxTTL = 0
+		assert.NoError(cfg.Validate(), "Minimum without a maximum should be accepted")
+
+		section.Step(3, "Nodes take the configured bounds")
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		assert.Equal(cfg.RecordIntervals, n.Storage.Intervals, "Node should apply the configured bounds")
+
+		section.Success("Interval bounds working correctly")
+	})
+}
@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestRelay tests forwarding RPCs to nodes behind NAT through a relay
+func TestRelay(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "RELAY")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting relay tests")
+
+	t.Run("ForwardToNATedPeer", func(t *testing.T) {
+		section := logger.Section("Forward To NATed Peer")
+		ctx := context.Background()
+
+		section.Step(1, "Start a relay")
+		relayCfg := config.Default()
+		relayCfg.Relay.Enabled = true
+		relay := kademlia.NewNode(relayCfg)
+		assert.NoError(relay.Start(ctx), "Relay should start")
+		defer relay.Stop()
+		assert.True(relay.Self.Supports(models.FlagRelay), "Relay should advertise relaying")
+
+		section.Step(2, "Join through the relay from behind NAT")
+		natCfg := config.Default()
+		natCfg.Advertise = "192.0.2.1" // Unroutable, as a private address would be
+		natCfg.Bootstrap = relay.Addr()
+		natCfg.Relay.Via = relay.Addr()
+		nated := kademlia.NewNode(natCfg)
+		assert.NoError(nated.Start(ctx), "NATed node should start")
+		defer nated.Stop()
+
+		closest := kademlia.FindClosestNodes(relay.RoutingTable, nated.Self.ID, relay.Self.ID, 0)
+		assert.Equal(relay.Addr(), closest[0].Relay, "Relay should learn the NATed node's relay")
+
+		section.Step(3, "RPCs to the NATed node go through the relay")
+		contact := &models.Node{ID: nated.Self.ID, IP: "192.0.2.1", Port: nated.Self.Port, Relay: relay.Addr()}
+		key := fixtures.GenerateValidHexID("relayed")
+		assert.NoError(kademlia.SendStoreRequest(ctx, contact, kademlia.StoreRequest{Key: key, Value: "hello"}), "STORE should be relayed")
+		value, found := nated.Storage.Get(key)
+		assert.True(found && value == "hello", "NATed node should store the value")
+
+		value, _, found, err := kademlia.SendFindValue(ctx, contact, key)
+		assert.NoError(err, "FIND_VALUE should be relayed")
+		assert.True(found && value == "hello", "NATed node should serve the value")
+
+		_, err = kademlia.SendFindNode(ctx, contact, key)
+		assert.NoError(err, "FIND_NODE reply should come from the NATed node")
+
+		section.Step(4, "Peers that are not registered are reported")
+		rr := httptest.NewRecorder()
+		kademlia.RelayForwardHandler(rr, httptest.NewRequest("GET", "/relay/"+fixtures.GenerateValidHexID("absent")+"/ping", nil), relay.Self, kademlia.NewRelay())
+		assert.Equal(http.StatusNotFound, rr.Code, "Unknown peer should be reported")
+
+		section.Success("Relay forwards RPCs to the NATed node")
+	})
+
+	t.Run("Registration", func(t *testing.T) {
+		section := logger.Section("Registration")
+
+		section.Step(1, "Only relays accept registrations")
+		node := fixtures.CreateTestNode(8080, "plain")
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/relay/register?id="+node.ID, nil)
+		req.Header.Set("Upgrade", kademlia.RelayProtocol)
+		kademlia.RelayRegisterHandler(rr, req, node, kademlia.NewRelay())
+		assert.Equal(http.StatusForbidden, rr.Code, "Nodes without the relay flag should refuse registrations")
+
+		section.Step(2, "Peers register again after losing their connection")
+		relayNode := &models.Node{ID: fixtures.GenerateValidHexID("relay"), Flags: models.DefaultFlags | models.FlagRelay}
+		relay := kademlia.NewRelay()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.RelayRegisterHandler(w, r, relayNode, relay)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		addr := server.Listener.Addr().String()
+		signed := func(id string) (*models.NodeRecord, ed25519.PrivateKey) {
+			key, err := kademlia.GenerateRecordKey()
+			assert.NoError(err, "Key should generate")
+			node := &models.Node{ID: id}
+			assert.NoError(kademlia.SignNodeRecord(node, key, nil, nil), "Record should sign")
+			return node.Record, key
+		}
+		id := fixtures.GenerateValidHexID("nated")
+		record, key := signed(id)
+		go kademlia.ServeViaRelay(ctx, addr, record, key, http.NotFoundHandler())
+
+		deadline := time.Now().Add(2 * time.Second)
+		for !relay.Registered(id) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(relay.Registered(id), "Peer should register")
+
+		relay.Close()
+		deadline = time.Now().Add(3 * time.Second)
+		for !relay.Registered(id) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(relay.Registered(id), "Peer should register again after losing its connection")
+
+		section.Step(3, "Registrations must prove the ID")
+		rr = httptest.NewRecorder()
+		req = httptest.NewRequest("POST", "/relay/register?id="+id, nil)
+		req.Header.Set("Upgrade", kademlia.RelayProtocol)
+		kademlia.RelayRegisterHandler(rr, req, relayNode, relay)
+		assert.Equal(http.StatusUnauthorized, rr.Code, "Unsigned registrations should be refused")
+
+		section.Step(4, "Another key cannot take over a live registration")
+		_, otherKey := signed(id)
+		other := *record
+		assert.NoError(other.Sign(otherKey), "Record should sign")
+		_, err := kademlia.RegisterWithRelay(ctx, addr, &other, otherKey, http.NotFoundHandler())
+		assert.HasError(err, "Registration with another key should be refused")
+		assert.Contains(err.Error(), "409", "Registration should conflict")
+		assert.True(relay.Registered(id), "Original peer should stay registered")
+
+		section.Step(5, "Registrations are capped")
+		relay.MaxPeers = 1
+		newcomer, newcomerKey := signed(fixtures.GenerateValidHexID("newcomer"))
+		_, err = kademlia.RegisterWithRelay(ctx, addr, newcomer, newcomerKey, http.NotFoundHandler())
+		assert.HasError(err, "Registration beyond the cap should be refused")
+		assert.Contains(err.Error(), "503", "Full relay should report it")
+
+		section.Success("Peers stay registered with their relay")
+	})
+}
@@ -0,0 +1,198 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestStoreReplication tests the replication status reported by STORE
+func TestStoreReplication(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "REPLICATE")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting store replication tests")
+
+	originalK := constants.GetK()
+	constants.SetK(3)
+	defer constants.SetK(originalK)
+
+	key := fixtures.GenerateValidHexID("replicated")
+
+	t.Run("LocalStoreAck", func(t *testing.T) {
+		section := logger.Section("Local Store Ack")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+
+		body := `{"key":"` + key + `","value":"v"}`
+		rr := httptest.NewRecorder()
+		kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", strings.NewReader(body)), node, kademlia.NewKeyValueStore(), routingTable)
+		assert.Equal(http.StatusCreated, rr.Code, "Store should succeed")
+
+		var ack kademlia.StoreAck
+		assert.NoError(json.NewDecoder(rr.Body).Decode(&ack), "Ack should be JSON")
+		assert.Equal(1, ack.ReplicationFactor, "Only this node should hold the value")
+		assert.Equal(node.ID, ack.Replicas[0].ID, "This node should be the replica")
+
+		section.Success("Plain store acknowledges one replica")
+	})
+
+	t.Run("ReplicatedStoreAck", func(t *testing.T) {
+		section := logger.Section("Replicated Store Ack")
+
+		section.Step(1, "Start a live replica and register a dead one")
+		peer := fixtures.CreateTestNode(0, "peer")
+		peerTable := kademlia.NewRoutingTable(peer.ID)
+		kademlia.AddNodeToRoutingTable(peerTable, peer, peer.ID)
+		peerStorage := kademlia.NewKeyValueStore()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, peer, peerTable)
+		})
+		mux.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, peer, peerStorage, peerTable)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		peer.IP = "127.0.0.1"
+		peer.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		dead := httptest.NewServer(http.NotFoundHandler())
+		deadAddr := strings.TrimPrefix(dead.URL, "http://")
+		deadPort, _ := strconv.Atoi(deadAddr[strings.LastIndex(deadAddr, ":")+1:])
+		dead.Close()
+
+		node := fixtures.CreateTestNode(8080, "entry")
+		storage := kademlia.NewKeyValueStore()
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, peer, node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, &models.Node{ID: fixtures.GenerateValidHexID("dead"), IP: "127.0.0.1", Port: deadPort}, node.ID)
+
+		section.Step(2, "Store through the entry node with replication")
+		body := `{"key":"` + key + `","value":"v","replicate":true}`
+		rr := httptest.NewRecorder()
+		kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", strings.NewReader(body)), node, storage, routingTable)
+		assert.Equal(http.StatusCreated, rr.Code, "Store should succeed")
+
+		var ack kademlia.StoreAck
+		assert.NoError(json.NewDecoder(rr.Body).Decode(&ack), "Ack should be JSON")
+		assert.Equal(3, len(ack.Replicas), "All k closest nodes should be listed")
+		assert.Equal(2, ack.ReplicationFactor, "Entry node and live peer should acknowledge")
+		assert.Equal(1, ack.Failed, "Dead peer should be reported as failed")
+		for _, replica := range ack.Replicas {
+			assert.Equal(replica.Error == "", replica.Stored, "Only failed replicas should carry an error")
+		}
+
+		value, _ := peerStorage.Get(key)
+		assert.Equal("v", value, "Live peer should hold the value")
+		value, _ = storage.Get(key)
+		assert.Equal("v", value, "Entry node should hold the value")
+
+		section.Success("Replicated store reports each replica")
+	})
+
+	t.Run("NotClosestIsNotStored", func(t *testing.T) {
+		section := logger.Section("Not Closest Is Not Stored")
+
+		peer := fixtures.CreateTestNode(0, "far")
+		peerTable := kademlia.NewRoutingTable(peer.ID)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, peer, kademlia.NewKeyValueStore(), peerTable)
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		peer.IP = "127.0.0.1"
+		peer.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		err := kademlia.SendStoreRequest(context.Background(), peer, kademlia.StoreRequest{Key: key, Value: "v"})
+		assert.Equal(kademlia.ErrNotClosest, err, "Closer-nodes reply should not count as stored")
+
+		section.Success("Redirects are not acknowledgements")
+	})
+	t.Run("BackupRepublish", func(t *testing.T) {
+		section := logger.Section("Backup Republish")
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+		published := fake.Now()
+
+		section.Step(1, "Start a replica that lost one record and holds another")
+		nearLocal := "00000000000000000000000000000000000000a1"
+		nearPeer := "80000000000000000000000000000000000000b2"
+		peer := &models.Node{ID: "8000000000000000000000000000000000000000"}
+		peerTable := kademlia.NewRoutingTable(peer.ID)
+		kademlia.AddNodeToRoutingTable(peerTable, peer, peer.ID)
+		peerStorage := kademlia.NewKeyValueStore()
+		peerStorage.Put(nearPeer, "held", "publisher", models.OverwriteAlways, "")
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, peer, peerTable)
+		})
+		mux.HandleFunc("/find_value", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindValueHandler(w, r, peer, peerStorage, peerTable)
+		})
+		mux.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, peer, peerStorage, peerTable)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		peer.IP = "127.0.0.1"
+		peer.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		node := &models.Node{ID: "0000000000000000000000000000000000000000", IP: "127.0.0.1", Port: 1}
+		storage := kademlia.NewKeyValueStore()
+		storage.Put(nearLocal, "lost", "publisher", models.OverwriteAlways, "")
+		storage.Put(nearPeer, "held", "publisher", models.OverwriteAlways, "")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, peer, node.ID)
+		republisher := kademlia.NewBackupRepublisher(node, routingTable, storage, time.Hour)
+
+		section.Step(2, "Records the publisher refreshed recently are left alone")
+		fake.Advance(time.Hour)
+		assert.Equal(0, republisher.Republish(context.Background()), "Nothing should be republished within the window")
+
+		section.Step(3, "The closest holder republishes a record whose publisher went quiet")
+		fake.Advance(time.Hour)
+		assert.Equal(1, republisher.Republish(context.Background()), "Only the record no closer node holds should be republished")
+		value, ok := peerStorage.Get(nearLocal)
+		assert.True(ok && value == "lost", "Lost replica should be restored")
+		meta, _ := peerStorage.Meta(nearLocal)
+		assert.Equal("publisher", meta.Publisher, "Republish should keep the publisher")
+		assert.True(meta.RepublishedAt.Equal(published), "Restored copy should be dated at the publisher's last store, got %v", meta.RepublishedAt)
+		meta, _ = storage.Meta(nearLocal)
+		assert.True(meta.RepublishedAt.Equal(published), "Republishing should not refresh the holder's own copy")
+
+		section.Step(4, "A deleted value is not brought back")
+		peerStorage.Tombstone(nearLocal, fake.Now())
+		err := kademlia.SendStoreRequest(context.Background(), peer, kademlia.StoreRequest{Key: nearLocal, Value: "lost", PublishedAt: &published})
+		assert.True(err != nil, "Backup republish should not resurrect a deleted value")
+
+		section.Step(5, "A backup republish counts against the namespace quota")
+		peerStorage.SetNamespacePolicy("app", models.NamespacePolicy{Quota: 1})
+		peerStorage.Put(models.NamespacedKey("app", nearPeer), "held", "", models.OverwriteAlways, "")
+		err = kademlia.SendStoreRequest(context.Background(), peer, kademlia.StoreRequest{Key: nearLocal, Value: "lost", Namespace: "app", PublishedAt: &published})
+		var status *kademlia.StatusError
+		assert.True(errors.As(err, &status) && status.Code == http.StatusInsufficientStorage, "Backup republish over the quota should be refused, got %v", err)
+		assert.Equal(1, peerStorage.NamespaceUsage("app"), "Namespace should stay within its quota")
+
+		section.Success("Quiet publishers' records kept alive until they expire")
+	})
+}
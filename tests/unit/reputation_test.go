@@ -0,0 +1,251 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestPeerReputation tests peer success and session tracking and the lookup
+// ordering strategies built on it
+func TestPeerReputation(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "REPUTATION")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting peer reputation tests")
+
+	t.Run("Scores", func(t *testing.T) {
+		section := logger.Section("Scores")
+
+		section.Step(1, "Unknown peers are rated 0.5")
+		reputation := models.NewPeerReputation()
+		assert.Equal(0.5, reputation.SuccessRate("unknown"), "Unknown peer should be neutral")
+		var none *models.PeerReputation
+		none.Record("peer", false)
+		assert.Equal(0.5, none.SuccessRate("peer"), "Nil reputation should rate every peer neutral")
+
+		section.Step(2, "Outcomes move the rate, recent ones most")
+		for i := 0; i < 5; i++ {
+			reputation.Record("flaky", false)
+		}
+		failing := reputation.SuccessRate("flaky")
+		assert.True(failing < 0.25, "Failing peer should be rated low, got %v", failing)
+		for i := 0; i < 5; i++ {
+			reputation.Record("flaky", true)
+		}
+		assert.True(reputation.SuccessRate("flaky") > 0.5, "Recent successes should outweigh older failures, got %v", reputation.SuccessRate("flaky"))
+
+		section.Step(3, "The number of tracked peers is bounded")
+		for i := 0; i <= models.MaxReputationPeers; i++ {
+			reputation.Record(fmt.Sprint(i), true)
+		}
+		assert.Equal(models.PeerScore{}, reputation.Score("flaky"), "Least recently contacted peer should be forgotten")
+
+		section.Step(4, "Strategies weigh distance against reputation")
+		assert.Equal(1.0, kademlia.ByDistance{}.Weight(1, 0), "ByDistance should ignore reputation")
+		strategy := kademlia.ByReputation{Penalty: 4}
+		assert.True(strategy.Weight(0, 0) > strategy.Weight(3, 1), "A dead closest peer should be tried after reliable farther ones")
+		assert.True(strategy.Weight(0, 0.5) < strategy.Weight(1, 0.5), "Peers without history should keep distance order")
+
+		section.Success("Peer reputation scored")
+	})
+
+	t.Run("LookupOrdering", func(t *testing.T) {
+		section := logger.Section("Lookup Ordering")
+		ctx := retry.WithPolicy(context.Background(), retry.Policy{Attempts: 1})
+
+		var mu sync.Mutex
+		var queried []string
+		serve := func(node *models.Node, healthy bool) *httptest.Server {
+			table := kademlia.NewRoutingTable(node.ID)
+			kademlia.AddNodeToRoutingTable(table, node, node.ID)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				queried = append(queried, node.ID)
+				mu.Unlock()
+				if !healthy {
+					http.Error(w, "overloaded", http.StatusServiceUnavailable)
+					return
+				}
+				kademlia.FindNodeHandler(w, r, node, table)
+			}))
+			addr := strings.TrimPrefix(server.URL, "http://")
+			node.IP = "127.0.0.1"
+			node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+			return server
+		}
+
+		section.Step(1, "The closest contact fails, a farther one answers")
+		failing := fixtures.CreateTestNode(0, "failing")
+		reliable := fixtures.CreateTestNode(0, "reliable")
+		defer serve(failing, false).Close()
+		defer serve(reliable, true).Close()
+		target := failing.ID
+
+		self := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(self.ID)
+		kademlia.AddNodeToRoutingTable(table, failing, self.ID)
+		kademlia.AddNodeToRoutingTable(table, reliable, self.ID)
+		lookup := func(strategy kademlia.LookupStrategy) []string {
+			mu.Lock()
+			queried = nil
+			mu.Unlock()
+			kademlia.IterativeFindNodeWithOptions(ctx, table, self.ID, target, kademlia.LookupOptions{Alpha: 1, Strategy: strategy})
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), queried...)
+		}
+
+		order := lookup(nil)
+		assert.Equal(fmt.Sprint([]string{failing.ID, reliable.ID}), fmt.Sprint(order), "Without history contacts should be queried by distance")
+		assert.True(table.Reputation.SuccessRate(failing.ID) < table.Reputation.SuccessRate(reliable.ID), "Outcomes should be recorded")
+
+		section.Step(2, "The failing contact is tried later next time")
+		order = lookup(nil)
+		assert.Equal(fmt.Sprint([]string{reliable.ID, failing.ID}), fmt.Sprint(order), "Reliable contact should be queried first")
+
+		section.Step(3, "ByDistance keeps the plain Kademlia order")
+		order = lookup(kademlia.ByDistance{})
+		assert.Equal(fmt.Sprint([]string{failing.ID, reliable.ID}), fmt.Sprint(order), "ByDistance should ignore reputation")
+
+		section.Success("Failing contacts queried later")
+	})
+	t.Run("Churn", func(t *testing.T) {
+		section := logger.Section("Churn")
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		self := fixtures.CreateTestNode(8080, "self")
+		steady := fixtures.CreateTestNode(8081, "steady")
+		flapping := fixtures.CreateTestNode(8082, "flapping")
+		table := kademlia.NewRoutingTable(self.ID)
+
+		section.Step(1, "Joining the routing table starts a session")
+		kademlia.AddNodeToRoutingTable(table, self, self.ID)
+		kademlia.AddNodeToRoutingTable(table, steady, self.ID)
+		kademlia.AddNodeToRoutingTable(table, flapping, self.ID)
+		score := table.Reputation.Score(flapping.ID)
+		assert.True(score.Online, "New contact should be online")
+		assert.Equal(1, score.Sessions, "New contact should have one session")
+		assert.Equal(models.PeerScore{}, table.Reputation.Score(self.ID), "The local node should not be tracked")
+
+		section.Step(2, "A failed RPC drops the peer and an answer rejoins it")
+		for i := 0; i < 3; i++ {
+			fake.Advance(10 * time.Minute)
+			table.Reputation.Record(flapping.ID, false)
+			fake.Advance(time.Minute)
+			table.Reputation.Record(flapping.ID, true)
+			table.Reputation.Record(steady.ID, true)
+		}
+		score = table.Reputation.Score(flapping.ID)
+		assert.Equal(3, score.Drops, "Each failure should end a session")
+		assert.Equal(3, score.Rejoins, "Each answer after a drop should start a session")
+		assert.Equal(4, score.Sessions, "Sessions should count the first and the rejoins")
+		assert.Equal(30*time.Minute, score.Uptime, "Uptime should sum the ended sessions")
+		assert.True(table.Reputation.Trust(flapping.ID) < table.Reputation.Trust(steady.ID), "A flapping peer should be trusted less than a steady one")
+
+		section.Step(3, "Churn stats summarise every peer")
+		stats := table.Reputation.Churn()
+		assert.Equal(2, stats.Peers, "Both contacts should be tracked")
+		assert.Equal(2, stats.Online, "Both contacts should be online")
+		assert.Equal(3, stats.Drops, "Drops should be summed")
+		assert.Equal(10*time.Minute, stats.MeanSession, "Mean session should cover the ended sessions")
+		assert.True(stats.DropsPerHour > 0, "Recent drops should give a drop rate")
+
+		section.Step(4, "Drops are forgiven over time")
+		churn := score.Churn(fake.Now())
+		fake.Advance(models.ChurnHalfLife)
+		assert.True(math.Abs(table.Reputation.Score(flapping.ID).Churn(fake.Now())-churn/2) < 1e-9, "Churn should halve every half-life")
+
+		section.Step(5, "Eviction ends a session without a drop")
+		local := strings.Repeat("0", 40)
+		first := &models.Node{ID: "8" + strings.Repeat("0", 38) + "1", IP: "127.0.0.1", Port: 9001}
+		second := &models.Node{ID: "8" + strings.Repeat("0", 38) + "2", IP: "127.0.0.1", Port: 9002}
+		full := kademlia.NewRoutingTableWithK(local, 1)
+		kademlia.AddNodeToRoutingTable(full, first, local)
+		kademlia.AddNodeToRoutingTable(full, second, local)
+		evicted := full.Reputation.Score(first.ID)
+		assert.False(evicted.Online, "Evicted contact should be offline")
+		assert.Equal(0, evicted.Drops, "Eviction should not count as a drop")
+		assert.True(full.Reputation.Score(second.ID).Online, "Replacement should be online")
+
+		section.Step(6, "/churn_stats reports a summary or one peer")
+		handler := func(query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			kademlia.ChurnStatsHandler(w, httptest.NewRequest(http.MethodGet, "/churn_stats"+query, nil), table)
+			return w
+		}
+		var summary models.ChurnStats
+		assert.NoError(json.NewDecoder(handler("").Body).Decode(&summary), "Summary should decode")
+		assert.Equal(3, summary.Drops, "Summary should count drops")
+		var peer kademlia.PeerChurn
+		assert.NoError(json.NewDecoder(handler("?id="+flapping.ID).Body).Decode(&peer), "Peer history should decode")
+		assert.Equal(3, peer.Score.Drops, "Peer history should count its drops")
+		assert.True(peer.Trust < 0.5, "A flapping peer's trust should be discounted, got %v", peer.Trust)
+
+		section.Success("Sessions, churn and trust tracked")
+	})
+
+	t.Run("AdaptiveTimeout", func(t *testing.T) {
+		section := logger.Section("Adaptive Timeout")
+
+		section.Step(1, "Timeouts follow the smoothed RTT and its variation")
+		reputation := models.NewPeerReputation()
+		assert.Equal(30*time.Second, reputation.Timeout("peer", 30*time.Second), "Peers without samples should get the full timeout")
+		for i := 0; i < 20; i++ {
+			reputation.RecordRTT("peer", 40*time.Millisecond)
+		}
+		steady := reputation.Timeout("peer", 30*time.Second)
+		assert.True(steady >= 40*time.Millisecond && steady < 200*time.Millisecond, "A steady peer should get a short timeout, got %v", steady)
+		for i := 0; i < 20; i++ {
+			reputation.RecordRTT("jittery", time.Duration(10+i%2*200)*time.Millisecond)
+		}
+		assert.True(reputation.Timeout("jittery", 30*time.Second) > steady, "A jittery peer should get a longer timeout")
+		reputation.RecordRTT("fast", time.Millisecond)
+		assert.Equal(models.MinAdaptiveTimeout, reputation.Timeout("fast", 30*time.Second), "Timeouts should not drop below the minimum")
+		reputation.RecordRTT("slow", time.Minute)
+		assert.Equal(30*time.Second, reputation.Timeout("slow", 30*time.Second), "Timeouts should not exceed the RPC timeout")
+
+		section.Step(2, "A lookup gives up on a stalled peer by its history")
+		ctx := retry.WithPolicy(context.Background(), retry.Policy{Attempts: 1})
+		stalled := fixtures.CreateTestNode(0, "stalled")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		stalled.IP = "127.0.0.1"
+		stalled.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		self := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(self.ID)
+		kademlia.AddNodeToRoutingTable(table, stalled, self.ID)
+		for i := 0; i < 10; i++ {
+			table.Reputation.RecordRTT(stalled.ID, 20*time.Millisecond)
+		}
+		start := time.Now()
+		kademlia.IterativeFindNode(ctx, table, self.ID, stalled.ID)
+		assert.True(time.Since(start) < 2*time.Second, "The lookup should not wait out the RPC timeout, took %v", time.Since(start))
+		assert.True(table.Reputation.RTT(stalled.ID) > 20*time.Millisecond, "A cut off reply should lengthen the next timeout")
+
+		section.Success("Lookups time peers out by their RTT history")
+	})
+}
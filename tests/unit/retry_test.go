@@ -0,0 +1,225 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/retry"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestRetry tests the shared retry policy and its use by joins, lookups
+// and replication writes
+func TestRetry(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "RETRY")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting retry tests")
+
+	fast := retry.Policy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	unavailable := &kademlia.StatusError{Code: http.StatusServiceUnavailable}
+
+	t.Run("Policy", func(t *testing.T) {
+		section := logger.Section("Retry Policy")
+
+		section.Step(1, "Delays double up to the maximum")
+		p := retry.Policy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+		var delays []time.Duration
+		for attempt := 1; attempt <= 6; attempt++ {
+			delays = append(delays, p.Delay(attempt))
+		}
+		assert.Equal("[100ms 200ms 400ms 800ms 1s 1s]", fmt.Sprint(delays), "Backoff should double and be capped")
+
+		section.Step(2, "Jitter stays within its fraction of the delay")
+		p.Jitter = 0.5
+		varied := false
+		for i := 0; i < 100; i++ {
+			d := p.Delay(1)
+			assert.True(d >= 75*time.Millisecond && d <= 125*time.Millisecond, "Jittered delay out of range: %v", d)
+			varied = varied || d != 100*time.Millisecond
+		}
+		assert.True(varied, "Jitter should vary the delay")
+
+		section.Step(3, "Transient errors are retried until the attempts run out")
+		calls := 0
+		err := retry.Do(context.Background(), fast, func(ctx context.Context) error {
+			calls++
+			return unavailable
+		})
+		assert.Equal(unavailable, err, "Last error should be returned")
+		assert.Equal(3, calls, "Every attempt should be made")
+
+		calls = 0
+		err = retry.Do(context.Background(), fast, func(ctx context.Context) error {
+			calls++
+			if calls < 2 {
+				return unavailable
+			}
+			return nil
+		})
+		assert.NoError(err, "Call should succeed on retry")
+		assert.Equal(2, calls, "Retrying should stop on success")
+
+		section.Step(4, "Permanent errors and cancellation stop retrying")
+		calls = 0
+		retry.Do(context.Background(), fast, func(ctx context.Context) error {
+			calls++
+			return &kademlia.StatusError{Code: http.StatusBadRequest}
+		})
+		assert.Equal(1, calls, "Rejected requests should not be retried")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		calls = 0
+		retry.Do(ctx, retry.Policy{Attempts: 5, Backoff: time.Hour}, func(ctx context.Context) error {
+			calls++
+			cancel()
+			return unavailable
+		})
+		assert.Equal(1, calls, "Cancellation should end the backoff")
+
+		section.Success("Retry policy applied")
+	})
+
+	t.Run("Classification", func(t *testing.T) {
+		section := logger.Section("Error Classification")
+
+		section.Step(1, "Transient failures are retryable")
+		_, dialErr := http.Get("http://127.0.0.1:1/")
+		assert.True(retry.IsRetryable(dialErr), "Connection failures should be retryable")
+		assert.True(retry.IsRetryable(fmt.Errorf("rpc: %w", context.DeadlineExceeded)), "Timeouts should be retryable")
+		for _, code := range []int{408, 429, 500, 502, 503, 504} {
+			assert.True(retry.IsRetryable(&kademlia.StatusError{Code: code}), "Status %d should be retryable", code)
+		}
+
+		section.Step(2, "Rejections and cancellation are not")
+		for _, code := range []int{400, 401, 403, 404, 409, 507} {
+			assert.False(retry.IsRetryable(&kademlia.StatusError{Code: code}), "Status %d should not be retryable", code)
+		}
+		assert.False(retry.IsRetryable(context.Canceled), "Cancellation should not be retried")
+		assert.False(retry.IsRetryable(kademlia.ErrNotClosest), "Declined stores should not be retried")
+		assert.False(retry.IsRetryable(errors.New("invalid reply")), "Unknown errors should not be retried")
+
+		section.Step(3, "Configuration is validated")
+		cfg := config.Default()
+		assert.NoError(cfg.Validate(), "Default retry policy should be valid")
+		assert.True(cfg.Retry.Attempts > 1, "Retries should be on by default")
+		cfg.Retry.Jitter = 2
+		assert.HasError(cfg.Validate(), "Jitter above 1 should be rejected")
+		cfg = config.Default()
+		cfg.Retry.Attempts = 0
+		assert.HasError(cfg.Validate(), "Zero attempts should be rejected")
+
+		section.Success("Errors classified")
+	})
+
+	t.Run("RPCs", func(t *testing.T) {
+		section := logger.Section("Retried RPCs")
+		ctx := retry.WithPolicy(context.Background(), fast)
+
+		// flaky answers 503 to the first failures requests to path and
+		// counts the requests to it
+		type flakyPeer struct {
+			path     atomic.Value
+			failures atomic.Int32
+			calls    atomic.Int32
+		}
+		flaky := func(node *models.Node, routingTable *models.RoutingTable, storage *models.KeyValueStore) (*httptest.Server, *flakyPeer) {
+			state := &flakyPeer{}
+			state.path.Store("")
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == state.path.Load() {
+					state.calls.Add(1)
+					if state.failures.Add(-1) >= 0 {
+						http.Error(w, "busy", http.StatusServiceUnavailable)
+						return
+					}
+				}
+				switch r.URL.Path {
+				case "/ping":
+					kademlia.PingHandler(w, r, node, storage, routingTable)
+				case "/find_node":
+					kademlia.FindNodeHandler(w, r, node, routingTable)
+				case "/store":
+					kademlia.StoreHandler(w, r, node, storage, routingTable)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			addr := strings.TrimPrefix(server.URL, "http://")
+			node.IP = "127.0.0.1"
+			node.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+			return server, state
+		}
+		fail := func(state *flakyPeer, path string, failures int32) {
+			state.path.Store(path)
+			state.failures.Store(failures)
+			state.calls.Store(0)
+		}
+
+		section.Step(1, "Joins retry a bootstrap node that is starting up")
+		bootstrap := fixtures.CreateTestNode(0, "bootstrap")
+		bootstrapTable := kademlia.NewRoutingTable(bootstrap.ID)
+		server, state := flaky(bootstrap, bootstrapTable, kademlia.NewKeyValueStore())
+		defer server.Close()
+		fail(state, "/ping", 2)
+		joining := fixtures.CreateTestNode(8081, "joining")
+		joiningTable := kademlia.NewRoutingTable(joining.ID)
+		assert.NoError(kademlia.JoinNetwork(ctx, joining, joiningTable, server.Listener.Addr().String()), "Join should succeed on the third ping")
+		assert.Equal(int32(3), state.calls.Load(), "Join should take three pings")
+
+		section.Step(2, "Single-attempt policies disable retries")
+		fail(state, "/ping", 5)
+		err := kademlia.JoinNetwork(retry.WithPolicy(context.Background(), retry.Policy{Attempts: 1}), joining, joiningTable, server.Listener.Addr().String())
+		assert.HasError(err, "Join should fail without retries")
+		assert.Equal(int32(1), state.calls.Load(), "Only one ping should be sent")
+
+		section.Step(3, "Lookups retry a busy contact")
+		peer := fixtures.CreateTestNode(0, "peer")
+		peerTable := kademlia.NewRoutingTable(peer.ID)
+		kademlia.AddNodeToRoutingTable(peerTable, peer, peer.ID)
+		peerStorage := kademlia.NewKeyValueStore()
+		peerServer, peerState := flaky(peer, peerTable, peerStorage)
+		defer peerServer.Close()
+		fail(peerState, "/find_node", 1)
+
+		self := fixtures.CreateTestNode(8080, "self")
+		table := kademlia.NewRoutingTable(self.ID)
+		kademlia.AddNodeToRoutingTable(table, self, self.ID)
+		kademlia.AddNodeToRoutingTable(table, peer, self.ID)
+		found := kademlia.FanOutFindNode(ctx, []*models.Node{peer}, self.ID)
+		assert.True(len(found) > 0, "Busy contact should answer on retry")
+		assert.Equal(int32(2), peerState.calls.Load(), "Lookup should query twice")
+
+		section.Step(4, "Replication writes retry a busy replica")
+		fail(peerState, "/store", 2)
+		key := fixtures.GenerateValidHexID("retried")
+		ack := kademlia.IterativeStore(ctx, table, self, kademlia.NewKeyValueStore(), kademlia.StoreRequest{Key: key, Value: "v"})
+		for _, replica := range ack.Replicas {
+			assert.True(replica.Stored, "Replica %s should store the value: %s", replica.ID, replica.Error)
+		}
+		assert.Equal(int32(3), peerState.calls.Load(), "Write should be sent three times")
+		value, _ := peerStorage.Get(key)
+		assert.Equal("v", value, "Busy replica should hold the value")
+
+		section.Step(5, "Writes that a retry could misreport are not retried")
+		fail(peerState, "/store", 1)
+		other := fixtures.GenerateValidHexID("exclusive")
+		ack = kademlia.IterativeStore(ctx, table, self, kademlia.NewKeyValueStore(), kademlia.StoreRequest{Key: other, Value: "v", Policy: models.OverwriteRejectExists})
+		assert.Equal(int32(1), peerState.calls.Load(), "reject_existing write should be sent once")
+		assert.Equal(1, ack.Failed, "Busy replica should be reported as failed")
+
+		section.Success("Joins, lookups and writes retried")
+	})
+}
@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/constants"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestRPCTimeouts tests per-RPC deadlines and fan-out cancellation
+func TestRPCTimeouts(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "RPC")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting RPC timeout tests")
+
+	// peerFor returns a contact pointing at the given test server
+	peerFor := func(server *httptest.Server, suffix string) *models.Node {
+		addr := strings.TrimPrefix(server.URL, "http://")
+		port, _ := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		return &models.Node{ID: fixtures.GenerateValidHexID(suffix), IP: "127.0.0.1", Port: port}
+	}
+
+	t.Run("JoinRespectsTimeout", func(t *testing.T) {
+		section := logger.Section("Join Respects Timeout")
+
+		originalTimeout := constants.GetRPCTimeout()
+		constants.SetRPCTimeout(100 * time.Millisecond)
+		defer constants.SetRPCTimeout(originalTimeout)
+
+		section.Step(1, "Start a bootstrap node that never answers in time")
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer slow.Close()
+		defer close(release)
+
+		section.Step(2, "Join should fail once the deadline passes")
+		node := fixtures.CreateTestNode(8081, "joining")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		start := time.Now()
+		err := kademlia.JoinNetwork(context.Background(), node, routingTable, strings.TrimPrefix(slow.URL, "http://"))
+		assert.HasError(err, "Join should time out")
+		assert.True(time.Since(start) < 2*time.Second, "Join should give up close to the RPC timeout")
+
+		section.Success("Join respects the RPC timeout")
+	})
+
+	t.Run("FanOutCancelsAfterK", func(t *testing.T) {
+		section := logger.Section("Fan-Out Cancels After K")
+
+		originalK := constants.GetK()
+		constants.SetK(2)
+		defer constants.SetK(originalK)
+
+		section.Step(1, "Start one fast and one hanging peer")
+		contacts := fixtures.CreateTestNodes(2, 9000)
+		fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(contacts)
+		}))
+		defer fast.Close()
+
+		cancelled := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			close(cancelled)
+		}))
+		defer hanging.Close()
+
+		section.Step(2, "Fan out FIND_NODE")
+		start := time.Now()
+		found := kademlia.FanOutFindNode(context.Background(),
+			[]*models.Node{peerFor(fast, "fast"), peerFor(hanging, "hanging")},
+			fixtures.GenerateValidHexID("target"))
+		assert.Equal(2, len(found), "Should return the k contacts from the fast peer")
+		assert.True(time.Since(start) < time.Second, "Should not wait for the hanging peer")
+
+		section.Step(3, "Hanging request is cancelled")
+		select {
+		case <-cancelled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("In-flight request to the hanging peer was not cancelled")
+		}
+
+		section.Success("Fan-out cancels outstanding requests after k results")
+	})
+	t.Run("FindNodeIdentifiesResponder", func(t *testing.T) {
+		section := logger.Section("Find Node Identifies Responder")
+
+		section.Step(1, "Responder sends its ID, address, time and record")
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		responder := fixtures.CreateTestNode(8080, "responder")
+		assert.NoError(kademlia.SignNodeRecord(responder, key, nil, []string{models.CapBencode}), "Signing should succeed")
+		routingTable := fixtures.CreatePopulatedRoutingTable(responder.ID, 5)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, responder, routingTable)
+		}))
+		defer server.Close()
+
+		rr := httptest.NewRecorder()
+		kademlia.FindNodeHandler(rr, httptest.NewRequest("GET", "/find_node?id="+responder.ID, nil), responder, routingTable)
+		info, err := kademlia.ResponderFrom(rr.Header())
+		assert.NoError(err, "Responder headers should parse")
+		if assert.True(info != nil, "Responder headers should be present") {
+			assert.Equal(responder.ID, info.ID, "Responder ID should be reported")
+			assert.Equal("127.0.0.1:8080", info.Addr, "Responder address should be reported")
+			assert.True(time.Since(info.Time) < time.Minute, "Responder time should be recent")
+			assert.True(info.Record.Has(models.CapBencode), "Responder record should be attached")
+		}
+
+		section.Step(2, "Caller updates the contact it asked")
+		peer := peerFor(server, "responder")
+		peer.ID = responder.ID
+		_, err = kademlia.SendFindNode(context.Background(), peer, responder.ID)
+		assert.NoError(err, "FIND_NODE should succeed")
+		assert.True(peer.LastSeen > 0, "LastSeen should be updated")
+		assert.True(peer.Record.Has(models.CapBencode), "Record should be learned from the reply")
+
+		section.Step(3, "A reply from another node is rejected")
+		_, err = kademlia.SendFindNode(context.Background(), peerFor(server, "impostor"), responder.ID)
+		assert.HasError(err, "Mismatched responder should be rejected")
+
+		section.Success("FIND_NODE replies identify the responder")
+	})
+}
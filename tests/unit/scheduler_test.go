@@ -0,0 +1,234 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestScheduler tests running periodic jobs from one scheduler
+func TestScheduler(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "SCHEDULER")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting scheduler tests")
+
+	// signal returns a job run function reporting each run on the channel
+	signal := func(delay time.Duration) (func(context.Context) time.Duration, chan struct{}) {
+		ran := make(chan struct{}, 16)
+		return func(context.Context) time.Duration {
+			ran <- struct{}{}
+			return delay
+		}, ran
+	}
+	// received reports whether a run is reported within a second
+	received := func(ran chan struct{}) bool {
+		select {
+		case <-ran:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+	// quiet reports whether no run is reported for a moment
+	quiet := func(ran chan struct{}) bool {
+		select {
+		case <-ran:
+			return false
+		case <-time.After(50 * time.Millisecond):
+			return true
+		}
+	}
+	// blocked waits until the scheduler waits on the fake clock
+	blocked := func(fake *clock.Fake, waiters int) {
+		for i := 0; i < 100 && fake.Waiters() < waiters; i++ {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("Runs", func(t *testing.T) {
+		section := logger.Section("Running Jobs")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		s := kademlia.NewScheduler(kademlia.SchedulerConfig{})
+		run, ran := signal(0)
+		assert.NoError(s.Add(kademlia.Job{Name: "refresh", Interval: time.Minute, Run: run}), "Job should be added")
+		eager, eagerRan := signal(0)
+		assert.NoError(s.Add(kademlia.Job{Name: "pinned", Interval: time.Hour, Immediate: true, Run: eager}), "Immediate job should be added")
+		assert.HasError(s.Add(kademlia.Job{Name: "refresh", Interval: time.Minute, Run: run}), "Duplicate name should be refused")
+		assert.HasError(s.Add(kademlia.Job{Name: "broken", Run: run}), "Job without an interval should be refused")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Start(ctx)
+
+		section.Step(1, "Immediate jobs run at start, others after their interval")
+		assert.True(received(eagerRan), "Immediate job should run at start")
+		blocked(fake, 1)
+		fake.Advance(30 * time.Second)
+		assert.True(quiet(ran), "Job should not run before its interval")
+		fake.Advance(30 * time.Second)
+		assert.True(received(ran), "Job should run after its interval")
+
+		section.Step(2, "Runs are counted")
+		time.Sleep(10 * time.Millisecond)
+		for _, job := range s.Jobs() {
+			if job.Name == "refresh" {
+				assert.Equal(uint64(1), job.Runs, "One run should be counted")
+				assert.True(job.NextRun.Equal(clock.Now().Add(time.Minute)), "Next run should be an interval after the last")
+			}
+		}
+
+		section.Success("Running jobs working correctly")
+	})
+
+	t.Run("Pause", func(t *testing.T) {
+		section := logger.Section("Pausing Jobs")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		s := kademlia.NewScheduler(kademlia.SchedulerConfig{})
+		run, ran := signal(0)
+		s.Add(kademlia.Job{Name: "republish", Interval: time.Minute, Run: run})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Start(ctx)
+		blocked(fake, 1)
+
+		section.Step(1, "Paused jobs do not run")
+		assert.NoError(s.Pause("republish"), "Pause should succeed")
+		fake.Advance(2 * time.Minute)
+		assert.True(quiet(ran), "Paused job should not run")
+		assert.True(s.Jobs()[0].Paused, "Job should be reported paused")
+
+		section.Step(2, "Resumed jobs that fell due run at once")
+		assert.NoError(s.Resume(""), "Resuming every job should succeed")
+		assert.True(received(ran), "Resumed job should run")
+
+		section.Step(3, "Unknown jobs are refused")
+		assert.True(errors.Is(s.Pause("reaper"), kademlia.ErrUnknownJob), "Unknown job should be refused")
+
+		section.Success("Pausing jobs working correctly")
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		section := logger.Section("Concurrency And Jitter")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		section.Step(1, "No more jobs run at once than the cap")
+		s := kademlia.NewScheduler(kademlia.SchedulerConfig{MaxConcurrent: 1})
+		var running, most atomic.Int32
+		release := make(chan struct{})
+		done := make(chan struct{}, 3)
+		for _, name := range []string{"a", "b", "c"} {
+			s.Add(kademlia.Job{Name: name, Interval: time.Hour, Immediate: true, Run: func(context.Context) time.Duration {
+				now := running.Add(1)
+				if now > most.Load() {
+					most.Store(now)
+				}
+				<-release
+				running.Add(-1)
+				done <- struct{}{}
+				return 0
+			}})
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Start(ctx)
+		for i := 0; i < 3; i++ {
+			release <- struct{}{}
+			<-done
+		}
+		assert.Equal(int32(1), most.Load(), "Only one job should run at a time")
+
+		section.Step(2, "Delays are jittered within the configured fraction")
+		jittered := kademlia.NewScheduler(kademlia.SchedulerConfig{Jitter: 0.5})
+		run, _ := signal(0)
+		jittered.Add(kademlia.Job{Name: "expire", Interval: time.Hour, Run: run})
+		go jittered.Start(ctx)
+		for i := 0; i < 100 && jittered.Jobs()[0].NextRun.IsZero(); i++ {
+			time.Sleep(time.Millisecond)
+		}
+		delay := jittered.Jobs()[0].NextRun.Sub(clock.Now())
+		assert.True(delay >= 45*time.Minute && delay <= 75*time.Minute, "Delay should stay within a quarter hour of the interval")
+
+		section.Step(3, "Jobs can ask for a shorter delay")
+		shorter := kademlia.NewScheduler(kademlia.SchedulerConfig{})
+		quick, quickRan := signal(time.Minute)
+		shorter.Add(kademlia.Job{Name: "partition", Interval: time.Hour, Immediate: true, Run: quick})
+		go shorter.Start(ctx)
+		assert.True(received(quickRan), "Job should run at start")
+		time.Sleep(10 * time.Millisecond)
+		assert.True(shorter.Jobs()[0].NextRun.Equal(clock.Now().Add(time.Minute)), "Next run should follow the delay asked for")
+
+		section.Success("Concurrency and jitter working correctly")
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		section := logger.Section("Admin Endpoints")
+
+		s := kademlia.NewScheduler(kademlia.SchedulerConfig{})
+		run, _ := signal(0)
+		s.Add(kademlia.Job{Name: "anti_entropy", Interval: time.Hour, Run: run})
+		send := func(method, path string, pause bool) int {
+			rr := httptest.NewRecorder()
+			kademlia.AdminPauseJobsHandler(rr, httptest.NewRequest(method, path, nil), s, pause)
+			return rr.Code
+		}
+
+		section.Step(1, "Jobs are paused and resumed by name")
+		assert.Equal(http.StatusOK, send(http.MethodPost, "/admin/jobs/pause?job=anti_entropy", true), "Pause should succeed")
+		assert.True(s.Jobs()[0].Paused, "Job should be paused")
+		assert.Equal(http.StatusOK, send(http.MethodPost, "/admin/jobs/resume", false), "Resuming every job should succeed")
+		assert.False(s.Jobs()[0].Paused, "Job should be resumed")
+
+		section.Step(2, "Bad requests are refused")
+		assert.Equal(http.StatusNotFound, send(http.MethodPost, "/admin/jobs/pause?job=reaper", true), "Unknown job should be 404")
+		assert.Equal(http.StatusMethodNotAllowed, send(http.MethodGet, "/admin/jobs/pause", true), "GET should not pause")
+
+		section.Step(3, "Nodes schedule their periodic jobs")
+		cfg := config.Default()
+		cfg.Port = 0
+		cfg.Server.AdminToken = "admin-secret"
+		node := kademlia.NewNode(cfg)
+		assert.NoError(node.Start(context.Background()), "Node should start")
+		defer node.Stop()
+		c := client.NewClient(node.Addr())
+		c.AdminToken = "admin-secret"
+		jobs, err := c.PauseJobs(context.Background(), "refresh")
+		assert.NoError(err, "Pausing through the admin API should succeed")
+		names := map[string]bool{}
+		for _, job := range jobs {
+			names[job.Name] = true
+			if job.Name == "refresh" {
+				assert.True(job.Paused, "Refresh should be paused")
+			}
+		}
+		for _, name := range []string{"refresh", "republish", "expire", "anti_entropy"} {
+			assert.True(names[name], "Node should schedule "+name)
+		}
+
+		section.Step(4, "Settings are validated")
+		cfg.Scheduler.Jitter = 1.5
+		assert.HasError(cfg.Validate(), "Jitter above 1 should be refused")
+		cfg.Scheduler.Jitter, cfg.Scheduler.MaxConcurrent = 0.1, -1
+		assert.HasError(cfg.Validate(), "Negative cap should be refused")
+
+		section.Success("Admin endpoints working correctly")
+	})
+}
@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/cmd"
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestStartServer tests that servers use their own mux and shut down cleanly
+func TestStartServer(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "SERVER")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting server lifecycle tests")
+
+	freePort := func() int {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(err, "Should find a free port")
+		defer l.Close()
+		return l.Addr().(*net.TCPAddr).Port
+	}
+
+	t.Run("TwoServersInOneProcess", func(t *testing.T) {
+		section := logger.Section("Two Servers In One Process")
+
+		section.Step(1, "Start two servers")
+		var ports []int
+		for i := 0; i < 2; i++ {
+			port := freePort()
+			node := fixtures.CreateTestNode(port, fmt.Sprintf("server%d", i))
+			server, err := cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), port, config.Default())
+			assert.NoError(err, "Server should start")
+			defer server.Shutdown(context.Background())
+			ports = append(ports, port)
+		}
+
+		section.Step(2, "Both answer pings")
+		for _, port := range ports {
+			resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/ping", port))
+			assert.NoError(err, "Server should answer")
+			assert.Equal(http.StatusOK, resp.StatusCode, "Ping should succeed")
+			resp.Body.Close()
+		}
+
+		section.Success("Servers do not share the default mux")
+	})
+
+	t.Run("ShutdownAndPortInUse", func(t *testing.T) {
+		section := logger.Section("Shutdown And Port In Use")
+
+		port := freePort()
+		node := fixtures.CreateTestNode(port, "shutdown")
+		server, err := cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), port, config.Default())
+		assert.NoError(err, "Server should start")
+
+		_, err = cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), port, config.Default())
+		assert.HasError(err, "Binding a used port should return an error")
+		if err != nil {
+			assert.Contains(err.Error(), "already in use", "Error should name the port conflict")
+		}
+
+		assert.NoError(server.Shutdown(context.Background()), "Shutdown should succeed")
+		_, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d/ping", port))
+		assert.HasError(err, "Shut down server should not answer")
+
+		section.Success("Server shut down gracefully")
+	})
+
+	t.Run("HandlerSetsPerListener", func(t *testing.T) {
+		section := logger.Section("Handler Sets Per Listener")
+
+		section.Step(1, "Serve RPCs on the port and stats on a loopback listener")
+		port, adminPort := freePort(), freePort()
+		cfg := config.Default()
+		cfg.Server.Handlers = []string{config.HandlersRPC}
+		cfg.Server.Listeners = []config.ListenerConfig{{Addr: fmt.Sprintf("127.0.0.1:%d", adminPort), Handlers: []string{config.HandlersAdmin}}}
+		node := fixtures.CreateTestNode(port, "listeners")
+		server, err := cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), port, cfg)
+		assert.NoError(err, "Server should start")
+		defer server.Shutdown(context.Background())
+
+		section.Step(2, "Each address serves only its sets")
+		for _, tc := range []struct {
+			port   int
+			path   string
+			status int
+		}{
+			{port, "/ping", http.StatusOK},
+			{port, "/rpc_stats", http.StatusNotFound},
+			{adminPort, "/rpc_stats", http.StatusOK},
+			{port, "/pool_stats", http.StatusNotFound},
+			{adminPort, "/pool_stats", http.StatusOK},
+			{port, "/store_stats", http.StatusNotFound},
+			{adminPort, "/store_stats", http.StatusOK},
+			{port, "/churn_stats", http.StatusNotFound},
+			{adminPort, "/churn_stats", http.StatusOK},
+			{port, "/peer_store", http.StatusNotFound},
+			{adminPort, "/peer_store", http.StatusOK},
+			{adminPort, "/ping", http.StatusNotFound},
+		} {
+			resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", tc.port, tc.path))
+			assert.NoError(err, "Listener should answer")
+			assert.Equal(tc.status, resp.StatusCode, fmt.Sprintf("%s on port %d", tc.path, tc.port))
+			resp.Body.Close()
+		}
+
+		section.Step(3, "A used listener address fails the start")
+		cfg.Server.Listeners[0].Addr = fmt.Sprintf("127.0.0.1:%d", port)
+		other := freePort()
+		_, err = cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), other, cfg)
+		assert.HasError(err, "Binding a used listener address should return an error")
+
+		section.Success("Handler sets split across listeners")
+	})
+
+	t.Run("AdminPort", func(t *testing.T) {
+		section := logger.Section("Admin Port")
+
+		section.Step(1, "Serve admin endpoints on their own address")
+		port, adminPort := freePort(), freePort()
+		cfg := config.Default()
+		cfg.Server.AdminToken = "s3cret"
+		cfg.Server.AdminAddr = fmt.Sprintf("127.0.0.1:%d", adminPort)
+		node := fixtures.CreateTestNode(port, "admin-port")
+		server, err := cmd.StartServer(node, kademlia.NewRoutingTable(node.ID), kademlia.NewKeyValueStore(), port, cfg)
+		assert.NoError(err, "Server should start")
+
+		section.Step(2, "Only the admin address serves them")
+		get := func(port int, path string) int {
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", port, path), nil)
+			req.Header.Set("X-Kademlia-Admin-Token", "s3cret")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return 0
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		assert.Equal(http.StatusNotFound, get(port, "/admin/keys"), "RPC port should not serve admin endpoints")
+		assert.Equal(http.StatusNotFound, get(port, "/rpc_stats"), "RPC port should not serve metrics")
+		assert.Equal(http.StatusOK, get(port, "/ping"), "RPC port should answer pings")
+		assert.Equal(http.StatusOK, get(adminPort, "/admin/keys"), "Admin port should list keys")
+		assert.Equal(http.StatusOK, get(adminPort, "/rpc_stats"), "Admin port should serve metrics")
+		assert.Equal(http.StatusOK, get(adminPort, "/admin/jobs"), "Admin port should list the runtime sampler job")
+
+		section.Step(3, "Shutting the server down closes the admin port")
+		assert.NoError(server.Shutdown(context.Background()), "Shutdown should succeed")
+		deadline := time.Now().Add(time.Second)
+		for get(adminPort, "/rpc_stats") != 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(0, get(adminPort, "/rpc_stats"), "Admin port should stop answering")
+
+		section.Success("Admin endpoints served apart from peer RPCs")
+	})
+}
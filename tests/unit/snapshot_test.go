@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestRoutingTableSnapshot tests fetching and importing a peer's routing
+// table
+func TestRoutingTableSnapshot(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "SNAPSHOT")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting routing table snapshot tests")
+
+	remote := fixtures.CreateTestNode(8080, "remote")
+	remoteTable := kademlia.NewRoutingTable(remote.ID)
+	kademlia.AddNodeToRoutingTable(remoteTable, remote, remote.ID)
+	var known []*models.Node
+	for i := 0; i < 3; i++ {
+		n := fixtures.CreateTestNode(9000+i, "known"+strconv.Itoa(i))
+		known = append(known, n)
+		kademlia.AddNodeToRoutingTable(remoteTable, n, remote.ID)
+	}
+
+	remoteStorage := kademlia.NewKeyValueStore()
+	newServer := func() *httptest.Server {
+		mux := kademlia.NewServeMux(remote, remoteTable, remoteStorage, kademlia.NewProviderStore(), kademlia.NewPubSub(), kademlia.NewWatches(remoteStorage), kademlia.NewRelay(), nil)
+		server := httptest.NewServer(mux)
+		addr := strings.TrimPrefix(server.URL, "http://")
+		remote.IP = "127.0.0.1"
+		remote.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+		return server
+	}
+
+	t.Run("Import", func(t *testing.T) {
+		section := logger.Section("Import")
+
+		server := newServer()
+		defer server.Close()
+
+		section.Step(1, "Snapshot lists every contact but the responder")
+		snapshot, err := kademlia.SendRoutingTableSnapshot(context.Background(), remote)
+		assert.NoError(err, "Snapshot should be fetched")
+		assert.Equal(len(known), len(snapshot), "Snapshot should hold every other contact")
+
+		section.Step(2, "Valid contacts are added, others skipped")
+		local := fixtures.CreateTestNode(8081, "local")
+		kademlia.AddNodeToRoutingTable(remoteTable, local, remote.ID)
+		kademlia.AddNodeToRoutingTable(remoteTable, &models.Node{ID: "not-hex", IP: "127.0.0.1", Port: 9100}, remote.ID)
+		kademlia.AddNodeToRoutingTable(remoteTable, &models.Node{ID: fixtures.CreateTestNode(0, "portless").ID, IP: "127.0.0.1"}, remote.ID)
+
+		localTable := kademlia.NewRoutingTable(local.ID)
+		added, err := kademlia.ImportRoutingTable(context.Background(), localTable, remote, local.ID)
+		assert.NoError(err, "Import should succeed")
+		assert.Equal(len(known), added, "Only valid contacts should be imported")
+		for _, n := range known {
+			closest := kademlia.FindClosestNodes(localTable, n.ID, local.ID, 0)
+			assert.True(len(closest) > 0 && closest[0].ID == n.ID, "Imported contact should be in the table")
+		}
+
+		section.Step(3, "Importing again adds nothing new")
+		added, err = kademlia.ImportRoutingTable(context.Background(), localTable, remote, local.ID)
+		assert.NoError(err, "Import should succeed")
+		assert.Equal(0, added, "Known contacts should not be counted")
+
+		section.Success("Routing table imported")
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		section := logger.Section("Rate Limited")
+
+		server := newServer()
+		defer server.Close()
+
+		for i := 0; i < kademlia.SnapshotBurst; i++ {
+			resp, err := http.Get(server.URL + "/routing_table")
+			assert.NoError(err, "Snapshot request should succeed")
+			resp.Body.Close()
+			assert.Equal(http.StatusOK, resp.StatusCode, "Snapshots within the burst should be served")
+		}
+		resp, err := http.Get(server.URL + "/routing_table")
+		assert.NoError(err, "Snapshot request should succeed")
+		resp.Body.Close()
+		assert.Equal(http.StatusTooManyRequests, resp.StatusCode, "Snapshots beyond the burst should be limited")
+
+		resp, err = http.Get(server.URL + "/find_node?id=" + remote.ID)
+		assert.NoError(err, "FIND_NODE should succeed")
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode, "Other RPCs should not be limited")
+
+		section.Success("Snapshots rate limited")
+	})
+}
@@ -0,0 +1,168 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/pkg/storage"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestStorageBackends tests the memory, file and SQLite storage backends
+// and the KeyValueStore built on them
+func TestStorageBackends(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "STORAGE")
+	assert := testutils.NewAssert(logger)
+
+	logger.Info("Starting storage backend tests")
+
+	dir := t.TempDir()
+	backends := []struct {
+		name string
+		open func(path string) (models.Storage, error)
+	}{
+		{"memory", func(string) (models.Storage, error) { return models.NewMemoryStorage(), nil }},
+		{"file", func(path string) (models.Storage, error) { return storage.OpenFile(path) }},
+		{"sqlite", func(path string) (models.Storage, error) { return storage.OpenSQLite(path) }},
+	}
+
+	keys := func(s models.Storage) string {
+		var out []string
+		s.Iterate(func(key, value string) bool {
+			out = append(out, key+"="+value)
+			return true
+		})
+		sort.Strings(out)
+		return fmt.Sprint(out)
+	}
+
+	t.Run("Operations", func(t *testing.T) {
+		section := logger.Section("Backend Operations")
+
+		for i, b := range backends {
+			section.Step(i+1, "Exercise the "+b.name+" backend")
+			s, err := b.open(filepath.Join(dir, "ops-"+b.name))
+			if !assert.NoError(err, "%s backend should open", b.name) {
+				continue
+			}
+
+			assert.NoError(s.Set("a", "1"), "%s: Set should succeed", b.name)
+			assert.NoError(s.Set("b", "2"), "%s: Set should succeed", b.name)
+			assert.NoError(s.Set("a", "3"), "%s: Overwrite should succeed", b.name)
+			value, found, err := s.Get("a")
+			assert.NoError(err, "%s: Get should succeed", b.name)
+			assert.True(found && value == "3", "%s: Get should return the latest value", b.name)
+			_, found, _ = s.Get("missing")
+			assert.False(found, "%s: Missing key should not be found", b.name)
+
+			assert.NoError(s.Delete("b"), "%s: Delete should succeed", b.name)
+			assert.NoError(s.Delete("b"), "%s: Deleting a missing key should succeed", b.name)
+			assert.Equal(1, s.Len(), "%s: One key should remain", b.name)
+			assert.Equal("[a=3]", keys(s), "%s: Iterate should see the remaining key", b.name)
+
+			visited := 0
+			s.Set("c", "4")
+			s.Iterate(func(key, value string) bool {
+				visited++
+				return false
+			})
+			assert.Equal(1, visited, "%s: Iterate should stop when fn returns false", b.name)
+			assert.NoError(s.Close(), "%s: Close should succeed", b.name)
+		}
+
+		section.Success("All backends store, read, delete and iterate")
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		section := logger.Section("Persistence")
+
+		for i, backend := range []string{"file", "sqlite"} {
+			section.Step(i+1, "Reopen the "+backend+" backend")
+			cfg := config.StorageConfig{Backend: backend, Path: filepath.Join(dir, "persist-"+backend)}
+
+			kvs, err := kademlia.OpenKeyValueStore(cfg)
+			if !assert.NoError(err, "%s store should open", backend) {
+				continue
+			}
+			kvs.Set("app/one", "value")
+			kvs.Set("two", "other")
+			kvs.Set("gone", "x")
+			kvs.Delete("gone")
+			size := kvs.SizeBytes()
+			assert.NoError(kvs.Close(), "%s store should close", backend)
+
+			kvs, err = kademlia.OpenKeyValueStore(cfg)
+			if !assert.NoError(err, "%s store should reopen", backend) {
+				continue
+			}
+			value, found := kvs.Get("app/one")
+			assert.True(found && value == "value", "%s: Value should survive a restart", backend)
+			_, found = kvs.Get("gone")
+			assert.False(found, "%s: Deleted key should stay deleted", backend)
+			assert.Equal(2, kvs.Len(), "%s: Two keys should be loaded", backend)
+			assert.Equal(size, kvs.SizeBytes(), "%s: Size should be rebuilt", backend)
+			assert.Equal(1, kvs.NamespaceUsage("app"), "%s: Namespace usage should be rebuilt", backend)
+			kvs.Close()
+		}
+
+		section.Success("Persistent backends survive restarts")
+	})
+
+	t.Run("FileCompaction", func(t *testing.T) {
+		section := logger.Section("File Compaction")
+		path := filepath.Join(dir, "compact")
+
+		section.Step(1, "Rewriting one key many times compacts the log")
+		fs, err := storage.OpenFile(path)
+		assert.NoError(err, "File backend should open")
+		for i := 0; i < 5000; i++ {
+			fs.Set("key", fmt.Sprint(i))
+		}
+		info, _ := os.Stat(path)
+		assert.True(info.Size() < 100*1024, "Log should be compacted, got %d bytes", info.Size())
+		fs.Close()
+
+		section.Step(2, "A torn last line is ignored")
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		f.WriteString(`{"k":"torn","v":"hal`)
+		f.Close()
+		fs, err = storage.OpenFile(path)
+		assert.NoError(err, "File backend should reopen")
+		value, found, _ := fs.Get("key")
+		assert.True(found && value == "4999", "Latest value should be loaded")
+		_, found, _ = fs.Get("torn")
+		assert.False(found, "Torn record should be ignored")
+		fs.Close()
+
+		section.Success("File log compacted and crash tolerant")
+	})
+
+	t.Run("Configuration", func(t *testing.T) {
+		section := logger.Section("Storage Configuration")
+
+		section.Step(1, "Persistent backends need a path")
+		cfg := config.Default()
+		assert.Equal("memory", cfg.Storage.Backend, "Memory should be the default")
+		cfg.Storage.Backend = "file"
+		assert.HasError(cfg.Validate(), "File backend without a path should be rejected")
+		cfg.Storage.Backend = "tape"
+		assert.HasError(cfg.Validate(), "Unknown backend should be rejected")
+
+		section.Step(2, "Nodes fail to start on unusable storage")
+		cfg = config.Default()
+		cfg.Port = 0
+		cfg.Storage = config.StorageConfig{Backend: "file", Path: filepath.Join(dir, "missing", "dir", "log")}
+		node := kademlia.NewNode(cfg)
+		assert.HasError(node.Start(context.Background()), "Start should report the storage error")
+		node.Stop()
+
+		section.Success("Storage configuration validated")
+	})
+}
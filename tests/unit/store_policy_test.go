@@ -0,0 +1,306 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestStoreOverwritePolicy tests overwrite policies and idempotent STOREs
+func TestStoreOverwritePolicy(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "HANDLERS")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting store overwrite policy tests")
+
+	node := fixtures.CreateTestNode(8080, "test")
+	routingTable := kademlia.NewRoutingTable(node.ID)
+	kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+
+	store := func(storage *models.KeyValueStore, payload map[string]string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", "/store", bytes.NewBuffer(jsonData))
+		rr := httptest.NewRecorder()
+		kademlia.StoreHandler(rr, req, node, storage, routingTable)
+		return rr
+	}
+
+	t.Run("RejectExisting", func(t *testing.T) {
+		section := logger.Section("Reject Existing")
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("reject")
+
+		rr := store(storage, map[string]string{"key": key, "value": "v1"})
+		assert.Equal(http.StatusCreated, rr.Code, "First store should succeed")
+
+		rr = store(storage, map[string]string{"key": key, "value": "v2", "policy": "reject_existing"})
+		assert.Equal(http.StatusConflict, rr.Code, "Second store should conflict")
+
+		var typed map[string]string
+		err := json.Unmarshal(rr.Body.Bytes(), &typed)
+		assert.NoError(err, "Conflict body should be JSON")
+		assert.Equal("key_exists", typed["error"], "Conflict should be typed")
+
+		value, _ := storage.Get(key)
+		assert.Equal("v1", value, "Original value should be kept")
+
+		section.Success("Existing keys protected")
+	})
+
+	t.Run("SamePublisher", func(t *testing.T) {
+		section := logger.Section("Same Publisher")
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("publisher")
+
+		store(storage, map[string]string{"key": key, "value": "v1", "publisher": "alice"})
+
+		rr := store(storage, map[string]string{"key": key, "value": "v2", "publisher": "bob", "policy": "same_publisher"})
+		assert.Equal(http.StatusConflict, rr.Code, "Other publisher should be rejected")
+		assert.Contains(rr.Body.String(), "publisher_mismatch", "Conflict should be typed")
+
+		rr = store(storage, map[string]string{"key": key, "value": "v3", "publisher": "alice", "policy": "same_publisher"})
+		assert.Equal(http.StatusCreated, rr.Code, "Original publisher may overwrite")
+
+		value, _ := storage.Get(key)
+		assert.Equal("v3", value, "Value should be updated by its publisher")
+
+		section.Success("Publisher ownership enforced")
+	})
+
+	t.Run("IdempotentRetry", func(t *testing.T) {
+		section := logger.Section("Idempotent Retry")
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("idempotent")
+
+		section.Step(1, "Initial write and a competing write")
+		store(storage, map[string]string{"key": key, "value": "v1", "idempotency_key": "req-1"})
+		store(storage, map[string]string{"key": key, "value": "v2"})
+
+		section.Step(2, "Retrying the first write does not clobber state")
+		rr := store(storage, map[string]string{"key": key, "value": "v1", "idempotency_key": "req-1"})
+		assert.Equal(http.StatusCreated, rr.Code, "Retry should report success")
+		assert.Equal("true", rr.Header().Get("Idempotent-Replayed"), "Retry should be flagged as replayed")
+		value, _ := storage.Get(key)
+		assert.Equal("v2", value, "Retry should not overwrite the newer value")
+
+		section.Step(3, "Reusing the key for a different write conflicts")
+		rr = store(storage, map[string]string{"key": key, "value": "v9", "idempotency_key": "req-1"})
+		assert.Equal(http.StatusConflict, rr.Code, "Reused idempotency key should conflict")
+		assert.Contains(rr.Body.String(), "idempotency_key_reused", "Conflict should be typed")
+
+		section.Success("Idempotency keys honoured")
+	})
+
+	t.Run("IdempotencyWindow", func(t *testing.T) {
+		section := logger.Section("Idempotency Window")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		storage := kademlia.NewKeyValueStore()
+		key := fixtures.GenerateValidHexID("window")
+		storage.Put(key, "v1", "", models.OverwriteAlways, "req-1")
+		fake.Advance(time.Hour)
+		storage.Put(key, "v2", "", models.OverwriteAlways, "req-2")
+
+		section.Step(1, "Keys are forgotten once their window ends")
+		fake.Advance(models.IdempotencyWindow - 30*time.Minute)
+		assert.Equal(1, storage.PruneIdempotencyKeys(clock.Now()), "Only the older key should be pruned")
+		assert.Equal(1, storage.IdempotencyKeys(), "The newer key should be remembered")
+
+		section.Step(2, "A forgotten key can identify another write")
+		_, err := storage.Put(key, "v3", "", models.OverwriteAlways, "req-1")
+		assert.NoError(err, "Expired key should be reusable")
+		_, err = storage.Put(key, "v4", "", models.OverwriteAlways, "req-2")
+		assert.HasError(err, "Remembered key should still conflict")
+
+		section.Step(3, "The garbage collector prunes them")
+		fake.Advance(models.IdempotencyWindow + time.Minute)
+		kademlia.NewGarbageCollector(storage, node.ID, kademlia.GCConfig{}).Collect()
+		assert.Equal(0, storage.IdempotencyKeys(), "Every key should be pruned")
+
+		section.Success("Idempotency window working correctly")
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		section := logger.Section("Invalid Policy")
+
+		storage := kademlia.NewKeyValueStore()
+		rr := store(storage, map[string]string{"key": fixtures.GenerateValidHexID("bad"), "value": "v", "policy": "sometimes"})
+		assert.Equal(http.StatusBadRequest, rr.Code, "Unknown policy should be rejected")
+
+		section.Success("Unknown policies rejected")
+	})
+
+	t.Run("RejectDistant", func(t *testing.T) {
+		section := logger.Section("Reject Distant")
+
+		local := &models.Node{ID: "0000000000000000000000000000000000000000"}
+		table := kademlia.NewRoutingTableWithK(local.ID, 2)
+		kademlia.AddNodeToRoutingTable(table, local, local.ID)
+		storeTo := func(storage *models.KeyValueStore, key string) *httptest.ResponseRecorder {
+			jsonData, _ := json.Marshal(map[string]string{"key": key, "value": "v"})
+			rr := httptest.NewRecorder()
+			kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", bytes.NewBuffer(jsonData)), local, storage, table)
+			return rr
+		}
+		far := "8000000000000000000000000000000000000000"
+		near := "0000000000000000000000000000000000000002"
+
+		storage := kademlia.NewKeyValueStore()
+		storage.RejectDistant = true
+
+		section.Step(1, "Every key is accepted with fewer than k contacts")
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000001"}, local.ID)
+		assert.True(kademlia.InResponsibility(table, local.ID, far), "Radius should be unbounded below k contacts")
+		assert.Equal(http.StatusCreated, storeTo(storage, far).Code, "Far key should be stored")
+
+		section.Step(2, "Keys beyond the k-th closest contact are rejected")
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000003"}, local.ID)
+		assert.Equal(http.StatusCreated, storeTo(storage, near).Code, "Key within the radius should be stored")
+		rr := storeTo(storage, "c000000000000000000000000000000000000000")
+		assert.Equal(http.StatusForbidden, rr.Code, "Key beyond the radius should be rejected")
+		assert.Contains(rr.Body.String(), "outside_responsibility", "Rejection should be typed")
+
+		section.Step(3, "The policy is off by default")
+		assert.Equal(http.StatusCreated, storeTo(kademlia.NewKeyValueStore(), far).Code, "Far key should be stored without the policy")
+
+		section.Success("Distant keys rejected")
+	})
+
+	t.Run("PlacementStats", func(t *testing.T) {
+		section := logger.Section("Placement Stats")
+
+		local := &models.Node{ID: "4000000000000000000000000000000000000000"}
+		table := kademlia.NewRoutingTableWithK(local.ID, 2)
+		kademlia.AddNodeToRoutingTable(table, local, local.ID)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000001"}, local.ID)
+		kademlia.AddNodeToRoutingTable(table, &models.Node{ID: "0000000000000000000000000000000000000003"}, local.ID)
+		storage := kademlia.NewKeyValueStore()
+		storage.LogPlacement = true
+		storeTo := func(key string) int {
+			jsonData, _ := json.Marshal(map[string]string{"key": key, "value": "v"})
+			rr := httptest.NewRecorder()
+			kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", bytes.NewBuffer(jsonData)), local, storage, table)
+			return rr.Code
+		}
+
+		section.Step(1, "Count accepted and misplaced STOREs")
+		assert.Equal(http.StatusCreated, storeTo("4000000000000000000000000000000000000001"), "Near key should be stored")
+		assert.Equal(http.StatusOK, storeTo("0000000000000000000000000000000000000002"), "Far key should be answered with closer contacts")
+
+		section.Step(2, "Serve the counts and distance gap")
+		rr := httptest.NewRecorder()
+		kademlia.StoreStatsHandler(rr, httptest.NewRequest("GET", "/store_stats", nil), storage)
+		var stats models.PlacementSnapshot
+		assert.NoError(json.NewDecoder(rr.Body).Decode(&stats), "Stats should be JSON")
+		assert.Equal(int64(1), stats.Accepted, "One STORE should be accepted")
+		assert.Equal(int64(1), stats.NotClosest, "One STORE should be misplaced")
+		assert.Equal(0.5, stats.AcceptRate, "Half the STOREs should be accepted")
+		assert.Equal(157.0, stats.MeanGapBits, "Gap should be the node's log2 distance minus the k-th closest's")
+
+		section.Success("Store placement counted")
+	})
+
+	t.Run("WriteTokens", func(t *testing.T) {
+		section := logger.Section("Write Tokens")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		section.Step(1, "Serve a node that requires write tokens")
+		storage := kademlia.NewKeyValueStore()
+		storage.RequireWriteToken = true
+		peer := &models.Node{ID: fixtures.GenerateValidHexID("token-peer"), IP: "127.0.0.1"}
+		peerTable := kademlia.NewRoutingTable(peer.ID)
+		kademlia.AddNodeToRoutingTable(peerTable, peer, peer.ID)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/find_node", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, peer, peerTable)
+		})
+		mux.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, peer, storage, peerTable)
+		})
+		mux.HandleFunc("/delete", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.DeleteHandler(w, r, peer, storage, peerTable)
+		})
+		mux.HandleFunc("/lease", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.LeaseHandler(w, r, peer, storage, peerTable)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		addr := strings.TrimPrefix(server.URL, "http://")
+		peer.Port, _ = strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+
+		section.Step(2, "A STORE without a token is refused")
+		key := fixtures.GenerateValidHexID("token-key")
+		body, _ := json.Marshal(map[string]string{"key": key, "value": "v"})
+		resp, err := http.Post(server.URL+"/store", "application/json", bytes.NewReader(body))
+		assert.NoError(err, "Store should be answered")
+		var typed map[string]string
+		json.NewDecoder(resp.Body).Decode(&typed)
+		resp.Body.Close()
+		assert.Equal(http.StatusForbidden, resp.StatusCode, "Store without a token should be refused")
+		assert.Equal("invalid_write_token", typed["error"], "Refusal should be typed")
+
+		section.Step(3, "Tokens are bound to the address and expire")
+		token := kademlia.IssueWriteToken(peer.ID, "127.0.0.1")
+		assert.True(kademlia.ValidWriteToken(peer.ID, "127.0.0.1", token), "Fresh token should be valid")
+		assert.False(kademlia.ValidWriteToken(peer.ID, "10.0.0.1", token), "Token should not be valid for another address")
+		fake.Advance(kademlia.WriteTokenWindow)
+		assert.True(kademlia.ValidWriteToken(peer.ID, "127.0.0.1", token), "Token should be valid in the next window")
+		fake.Advance(kademlia.WriteTokenWindow)
+		assert.False(kademlia.ValidWriteToken(peer.ID, "127.0.0.1", token), "Token should expire after two windows")
+
+		section.Step(4, "Senders fetch and present a token")
+		tokens := kademlia.NewWriteTokens()
+		ctx := kademlia.WithWriteTokens(context.Background(), tokens)
+		err = kademlia.SendStore(ctx, peer, key, "v")
+		assert.NoError(err, "Store should succeed with a fetched token")
+		value, _ := storage.Get(key)
+		assert.Equal("v", value, "Value should be stored")
+		assert.NotEqual("", tokens.Get(addr), "Fetched token should be cached")
+
+		section.Step(5, "Deletes and leases need a token too")
+		for _, path := range []string{"/delete", "/lease"} {
+			body, _ := json.Marshal(map[string]string{"key": key, "holder": "me"})
+			resp, err := http.Post(server.URL+path, "application/json", bytes.NewReader(body))
+			assert.NoError(err, "%s should be answered", path)
+			resp.Body.Close()
+			assert.Equal(http.StatusForbidden, resp.StatusCode, "%s without a token should be refused", path)
+		}
+		_, err = kademlia.SendLease(ctx, peer, kademlia.LeaseRequest{Key: key, Holder: "me"})
+		var status *kademlia.StatusError
+		assert.True(errors.As(err, &status) && status.Code == http.StatusConflict, "Lease with the cached token should only be refused for the value in the way")
+		assert.NoError(kademlia.SendDelete(ctx, peer, kademlia.DeleteRequest{Key: key}), "Delete should succeed with the cached token")
+		_, exists := storage.Get(key)
+		assert.False(exists, "Value should be deleted")
+
+		section.Step(6, "Cached tokens expire and are bounded")
+		fake.Advance(2 * kademlia.WriteTokenWindow)
+		assert.Equal("", tokens.Get(addr), "Token older than two windows should be forgotten")
+		for i := 0; i < kademlia.MaxWriteTokens+10; i++ {
+			tokens.Remember(fmt.Sprintf("10.0.%d.%d:8080", i/256, i%256), http.Header{kademlia.WriteTokenHeader: {"t"}})
+		}
+		assert.Equal(kademlia.MaxWriteTokens, tokens.Len(), "Cache should stay bounded")
+
+		section.Success("Writes need a recent token")
+	})
+}
@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestSubnetDiversity tests capping the contacts from one subnet
+func TestSubnetDiversity(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "SUBNET")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting subnet diversity tests")
+
+	t.Run("Subnet", func(t *testing.T) {
+		section := logger.Section("Subnets")
+
+		section.Step(1, "Addresses are grouped by /24 and /64")
+		assert.Equal("10.1.2.0/24", models.Subnet("10.1.2.3"), "IPv4 address should map to its /24")
+		assert.Equal(models.Subnet("10.1.2.200"), models.Subnet("10.1.2.3"), "Addresses of one /24 should share it")
+		assert.Equal("2001:db8:1:2::/64", models.Subnet("2001:db8:1:2::99"), "IPv6 address should map to its /64")
+		assert.Equal("", models.Subnet("example.com"), "Host names should have no subnet")
+
+		section.Success("Subnets computed correctly")
+	})
+
+	t.Run("Bucket", func(t *testing.T) {
+		section := logger.Section("Per-Bucket Limit")
+
+		localID := "00000000"
+		table := kademlia.NewRoutingTableWithK(localID, 20)
+		table.SubnetLimit = models.SubnetLimit{PerBucket: 2}
+
+		// IDs starting with f all fall in the farthest bucket
+		contact := func(i int, ip string) *models.Node {
+			return &models.Node{ID: fmt.Sprintf("f%07x", i), IP: ip, Port: 9000}
+		}
+
+		section.Step(1, "A bucket holds up to the limit from one subnet")
+		for i := 0; i < 4; i++ {
+			kademlia.AddNodeToRoutingTable(table, contact(i, fmt.Sprintf("10.0.0.%d", i+1)), localID)
+		}
+		stats := kademlia.BucketStats(table)
+		assert.Equal(1, len(stats), "One bucket should be used")
+		if len(stats) == 1 {
+			assert.Equal(2, stats[0].Contacts, "Two contacts of the subnet should be kept")
+			assert.Equal(uint64(2), stats[0].SubnetRefused, "Refusals should be reported")
+		}
+
+		section.Step(2, "Other subnets, known contacts and pinned peers are admitted")
+		kademlia.AddNodeToRoutingTable(table, contact(10, "10.0.1.1"), localID)
+		kademlia.AddNodeToRoutingTable(table, contact(0, "10.0.0.1"), localID)
+		pinned := contact(11, "10.0.0.50")
+		table.Pinned = map[string]bool{pinned.ID: true}
+		kademlia.AddNodeToRoutingTable(table, pinned, localID)
+		stats = kademlia.BucketStats(table)
+		if len(stats) == 1 {
+			assert.Equal(4, stats[0].Contacts, "Another subnet's contact and the pinned peer should be added")
+			assert.Equal(uint64(2), stats[0].SubnetRefused, "Nothing more should be refused")
+		}
+
+		section.Success("Per-bucket limit enforced correctly")
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		section := logger.Section("Per-Table Limit")
+
+		node := fixtures.CreateTestNode(8080, "local")
+		table := kademlia.NewRoutingTable(node.ID)
+		table.SubnetLimit = models.SubnetLimit{PerTable: 3}
+
+		section.Step(1, "The table holds up to the limit from one subnet")
+		added := 0
+		for i := 0; i < 10; i++ {
+			n := &models.Node{ID: fixtures.GenerateValidHexID(fmt.Sprintf("attacker-%d", i)), IP: "192.0.2.7", Port: 9000 + i}
+			kademlia.AddNodeToRoutingTable(table, n, node.ID)
+		}
+		var refused uint64
+		for _, b := range kademlia.BucketStats(table) {
+			added += b.Contacts
+			refused += b.SubnetRefused
+		}
+		assert.Equal(3, added, "Only three contacts of the subnet should be kept")
+		assert.Equal(uint64(7), refused, "The rest should be reported refused")
+
+		section.Step(2, "Relayed nodes count against their relay's subnet")
+		relayed := &models.Node{ID: fixtures.GenerateValidHexID("relayed"), Relay: "192.0.2.200:8080"}
+		kademlia.AddNodeToRoutingTable(table, relayed, node.ID)
+		for _, n := range kademlia.FindClosestNodes(table, relayed.ID, node.ID, 0) {
+			assert.False(n.ID == relayed.ID, "Relayed node should be refused")
+		}
+
+		section.Success("Per-table limit enforced correctly")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		section := logger.Section("Subnet Limit Configuration")
+
+		section.Step(1, "Limits are validated")
+		cfg := config.Default()
+		cfg.MaxPerSubnetBucket = -1
+		assert.HasError(cfg.Validate(), "Negative limit should be refused")
+		cfg.MaxPerSubnetBucket, cfg.MaxPerSubnetTable = 2, 10
+		assert.NoError(cfg.Validate(), "Positive limits should be accepted")
+
+		section.Step(2, "Nodes take the configured limits")
+		n := kademlia.NewNode(cfg)
+		defer n.Storage.Close()
+		assert.Equal(models.SubnetLimit{PerBucket: 2, PerTable: 10}, n.RoutingTable.SubnetLimit, "Node should apply the configured limits")
+
+		section.Success("Subnet limit configuration working correctly")
+	})
+}
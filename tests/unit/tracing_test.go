@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/internals/tracing"
+	"github.com/Aradhya2708/kademlia/pkg/config"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestTracing tests span creation and traceparent propagation across RPCs
+func TestTracing(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "TRACING")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting tracing tests")
+
+	t.Run("TraceparentPropagation", func(t *testing.T) {
+		section := logger.Section("Traceparent Propagation")
+
+		section.Step(1, "Install in-memory tracer provider")
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer provider.Shutdown(context.Background())
+
+		section.Step(2, "Start traced node")
+		node := fixtures.CreateTestNode(8080, "traced")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		server := httptest.NewServer(tracing.Middleware("find_node", node.ID, func(w http.ResponseWriter, r *http.Request) {
+			kademlia.FindNodeHandler(w, r, node, routingTable)
+		}))
+		defer server.Close()
+
+		section.Step(3, "Send traced RPC")
+		client := &http.Client{Transport: tracing.NewTransport(nil)}
+		resp, err := client.Get(server.URL + "/find_node?id=" + node.ID)
+		assert.NoError(err, "RPC should succeed")
+		resp.Body.Close()
+
+		section.Step(4, "Verify client and server spans share a trace")
+		spans := exporter.GetSpans()
+		assert.Equal(2, len(spans), "Should record a client and a server span")
+
+		var serverSpan, clientSpan tracetest.SpanStub
+		for _, s := range spans {
+			if s.Name == "find_node" {
+				serverSpan = s
+			} else {
+				clientSpan = s
+			}
+		}
+		assert.Equal(clientSpan.SpanContext.TraceID(), serverSpan.SpanContext.TraceID(), "Spans should share a trace ID")
+		assert.Equal(clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID(), "Server span should be a child of the client span")
+
+		section.Success("Trace context propagated across the RPC")
+	})
+
+	t.Run("RequestIDs", func(t *testing.T) {
+		section := logger.Section("Request IDs")
+
+		section.Step(1, "Handler sees and echoes the caller's request ID")
+		var seen string
+		server := httptest.NewServer(tracing.Middleware("ping", "node", func(w http.ResponseWriter, r *http.Request) {
+			seen = tracing.RequestID(r.Context())
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: tracing.NewTransport(nil)}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set(tracing.RequestIDHeader, "abc-123")
+		resp, err := client.Do(req)
+		assert.NoError(err, "RPC should succeed")
+		resp.Body.Close()
+		assert.Equal("abc-123", seen, "Handler should see the caller's ID")
+		assert.Equal("abc-123", resp.Header.Get(tracing.RequestIDHeader), "Response should echo the ID")
+
+		section.Step(2, "Each outbound RPC gets its own ID")
+		resp1, _ := client.Get(server.URL)
+		resp1.Body.Close()
+		resp2, _ := client.Get(server.URL)
+		resp2.Body.Close()
+		first := resp1.Header.Get(tracing.RequestIDHeader)
+		assert.True(first != "", "Transport should generate an ID")
+		assert.NotEqual(first, resp2.Header.Get(tracing.RequestIDHeader), "IDs should differ per RPC")
+
+		section.Step(3, "Unsafe IDs are replaced")
+		req, _ = http.NewRequest("GET", server.URL, nil)
+		req.Header.Set(tracing.RequestIDHeader, "bad id\twith spaces")
+		resp, err = http.DefaultClient.Do(req)
+		assert.NoError(err, "RPC should succeed")
+		resp.Body.Close()
+		assert.NotEqual("bad id\twith spaces", resp.Header.Get(tracing.RequestIDHeader), "Unsafe ID should not be echoed")
+
+		section.Step(4, "Error responses carry the ID")
+		node := fixtures.CreateTestNode(8080, "errors")
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		kademlia.AddNodeToRoutingTable(routingTable, node, node.ID)
+		storage := kademlia.NewKeyValueStore()
+		store := tracing.Middleware("store", node.ID, func(w http.ResponseWriter, r *http.Request) {
+			kademlia.StoreHandler(w, r, node, storage, routingTable)
+		})
+		key := fixtures.GenerateValidHexID("conflict")
+		for i := 0; i < 2; i++ {
+			r := httptest.NewRequest("POST", "/store", strings.NewReader(`{"key":"`+key+`","value":"v`+strconv.Itoa(i)+`","policy":"reject_existing"}`))
+			r.Header.Set(tracing.RequestIDHeader, "store-"+strconv.Itoa(i))
+			rr := httptest.NewRecorder()
+			store(rr, r)
+			if i == 1 {
+				var body map[string]string
+				json.NewDecoder(rr.Body).Decode(&body)
+				assert.Equal(http.StatusConflict, rr.Code, "Second store should conflict")
+				assert.Equal("store-1", body["request_id"], "Error body should name the request")
+			}
+		}
+
+		section.Success("Request IDs correlate both sides of an RPC")
+	})
+
+	t.Run("ExporterConfiguration", func(t *testing.T) {
+		section := logger.Section("Exporter Configuration")
+
+		section.Step(1, "Defaults disable tracing")
+		cfg := config.Default()
+		assert.Equal("none", cfg.Tracing.Exporter, "Tracing should be disabled by default")
+
+		section.Step(2, "Environment overrides")
+		os.Setenv("KADEMLIA_TRACING_EXPORTER", "otlp")
+		os.Setenv("KADEMLIA_TRACING_ENDPOINT", "jaeger:4318")
+		defer os.Unsetenv("KADEMLIA_TRACING_EXPORTER")
+		defer os.Unsetenv("KADEMLIA_TRACING_ENDPOINT")
+
+		cfg, err := config.FromEnv()
+		assert.NoError(err, "Valid environment should load")
+		assert.Equal("otlp", cfg.Tracing.Exporter, "Exporter should be overridden")
+		assert.Equal("jaeger:4318", cfg.Tracing.Endpoint, "Endpoint should be overridden")
+
+		section.Step(3, "Unknown exporter is rejected")
+		os.Setenv("KADEMLIA_TRACING_EXPORTER", "zipkin")
+		_, err = config.FromEnv()
+		assert.HasError(err, "Unknown exporter should be rejected")
+
+		section.Success("Exporter configuration loaded correctly")
+	})
+}
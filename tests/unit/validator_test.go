@@ -1,13 +1,19 @@
 package unit
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Aradhya2708/kademlia/internals/kademlia"
 	validators "github.com/Aradhya2708/kademlia/internals/validator"
+	"github.com/Aradhya2708/kademlia/pkg/models"
 	"github.com/Aradhya2708/kademlia/tests/testutils"
 )
 
@@ -154,6 +160,48 @@ func TestValidators(t *testing.T) {
 
 		section.Success("Validator performance acceptable")
 	})
+
+	t.Run("RequestSchema", func(t *testing.T) {
+		section := logger.Section("Request Schema")
+
+		type request struct {
+			ID    string `param:"id" validate:"required,id"`
+			Port  int    `param:"port" validate:"port,required_with=id"`
+			Count int    `param:"count" validate:"min=1,max=5"`
+			Name  string `json:"name" validate:"max=3"`
+			Debug bool   `param:"debug"`
+		}
+		validID := fixtures.GenerateValidHexID("schema")
+
+		section.Step(1, "Query parameters fill the fields they name")
+		req := request{Count: 2}
+		err := validators.DecodeQuery(url.Values{"id": {validID}, "port": {"9000"}, "debug": {"true"}}, &req)
+		assert.NoError(err, "Decoding should succeed")
+		assert.Equal(request{ID: validID, Port: 9000, Count: 2, Debug: true}, req, "Absent parameters should keep their defaults")
+		assert.NoError(validators.Struct(req), "Valid request should pass")
+
+		section.Step(2, "Unparseable parameters are refused")
+		err = validators.DecodeQuery(url.Values{"count": {"two"}}, &req)
+		assert.Equal("Invalid 'count': not an integer", fmt.Sprint(err), "Non-numeric count should be refused")
+
+		section.Step(3, "Each rule names the field that broke it")
+		for _, c := range []struct {
+			req  request
+			want string
+		}{
+			{request{Count: 1}, "Missing 'id'"},
+			{request{ID: "abc", Port: 1, Count: 1}, "Invalid 'id': invalid length"},
+			{request{ID: validID, Count: 1}, "Missing 'port'"},
+			{request{ID: validID, Port: 70000, Count: 1}, "Invalid 'port': out of range"},
+			{request{ID: validID, Port: 1, Count: 0}, "Invalid 'count': value below 1"},
+			{request{ID: validID, Port: 1, Count: 6}, "Invalid 'count': value above 5"},
+			{request{ID: validID, Port: 1, Count: 1, Name: "long"}, "Invalid 'name': length above 3"},
+		} {
+			assert.Equal(c.want, fmt.Sprint(validators.Struct(c.req)), "Unexpected error for %+v", c.req)
+		}
+
+		section.Success("Requests decoded and validated from their tags")
+	})
 }
 
 // TestValidatorIntegration tests validator integration with other components
@@ -209,4 +257,67 @@ func TestValidatorIntegration(t *testing.T) {
 
 		section.Success("Validator error messages are descriptive")
 	})
+
+	t.Run("SameRulesEveryTransport", func(t *testing.T) {
+		section := logger.Section("Same Rules Every Transport")
+
+		node := fixtures.CreateTestNode(8080, "valid")
+		node.IP = "127.0.0.1"
+		routingTable := kademlia.NewRoutingTable(node.ID)
+		storage := kademlia.NewKeyValueStore()
+		sender := fixtures.CreateTestNode(9090, "sender")
+		sender.IP = "127.0.0.1"
+
+		viaQuery := func(path string) int {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", path, nil)
+			switch {
+			case strings.HasPrefix(path, "/find_node"):
+				kademlia.FindNodeHandler(rr, req, node, routingTable)
+			default:
+				kademlia.FindValueHandler(rr, req, node, storage, routingTable)
+			}
+			return rr.Code
+		}
+		viaEnvelope := func(msg models.Message) (int, string) {
+			msg.Sender, msg.Version, msg.Nonce = *sender, models.MessageVersion, "n"
+			body, _ := json.Marshal(msg)
+			rr := httptest.NewRecorder()
+			kademlia.MessageHandler(rr, httptest.NewRequest("POST", "/rpc", bytes.NewReader(body)), node, storage, kademlia.NewProviderStore(), routingTable)
+			var reply models.Message
+			json.Unmarshal(rr.Body.Bytes(), &reply)
+			return rr.Code, reply.Error
+		}
+
+		section.Step(1, "A malformed target is refused by both transports")
+		assert.Equal(http.StatusBadRequest, viaQuery("/find_node?id=abc"), "Query should be refused")
+		code, reason := viaEnvelope(models.Message{Type: models.FindNode, Target: "abc"})
+		assert.Equal(http.StatusBadRequest, code, "Envelope should be refused")
+		assert.Equal("Invalid 'id': invalid length", reason, "Envelope should report the same rule")
+
+		section.Step(2, "So is a negative count")
+		validID := fixtures.GenerateValidHexID("transport")
+		assert.Equal(http.StatusBadRequest, viaQuery("/find_value?key="+validID+"&count=-1"), "Query should be refused")
+		code, _ = viaEnvelope(models.Message{Type: models.FindValue, Key: validID, Count: -1})
+		assert.Equal(http.StatusBadRequest, code, "Envelope should be refused")
+
+		section.Step(3, "Store values are required and bounded by MaxValueSize")
+		code, _ = viaEnvelope(models.Message{Type: models.Store, Key: validID})
+		assert.Equal(http.StatusBadRequest, code, "Envelope without a value should be refused")
+		rr := httptest.NewRecorder()
+		body, _ := json.Marshal(kademlia.StoreRequest{Key: validID, Value: strings.Repeat("v", kademlia.MaxValueSize+1)})
+		kademlia.StoreHandler(rr, httptest.NewRequest("POST", "/store", bytes.NewReader(body)), node, storage, routingTable)
+		assert.Equal(http.StatusBadRequest, rr.Code, "Oversized store should be refused")
+
+		section.Step(4, "Tags agree with the limits they stand for")
+		iterate := func(limit int) int {
+			rr := httptest.NewRecorder()
+			kademlia.IterateKeysHandler(rr, httptest.NewRequest("GET", fmt.Sprintf("/iterate_keys?limit=%d", limit), nil), node, storage)
+			return rr.Code
+		}
+		assert.Equal(http.StatusOK, iterate(kademlia.MaxIterateLimit), "MaxIterateLimit should be accepted")
+		assert.Equal(http.StatusBadRequest, iterate(kademlia.MaxIterateLimit+1), "Limits above MaxIterateLimit should be refused")
+
+		section.Success("Both transports apply the same rules")
+	})
 }
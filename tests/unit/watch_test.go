@@ -0,0 +1,286 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aradhya2708/kademlia/internals/kademlia"
+	"github.com/Aradhya2708/kademlia/pkg/client"
+	"github.com/Aradhya2708/kademlia/pkg/clock"
+	"github.com/Aradhya2708/kademlia/pkg/models"
+	"github.com/Aradhya2708/kademlia/tests/testutils"
+)
+
+// TestWatch tests watching stored keys for new versions
+func TestWatch(t *testing.T) {
+	logger := testutils.NewTestLogger(t, "WATCH")
+	assert := testutils.NewAssert(logger)
+	fixtures := testutils.NewTestFixtures(logger)
+
+	logger.Info("Starting key watch tests")
+
+	t.Run("Versions", func(t *testing.T) {
+		section := logger.Section("Key Versions")
+
+		storage := kademlia.NewKeyValueStore()
+		watches := kademlia.NewWatches(storage)
+		key := fixtures.GenerateValidHexID("versions")
+		storage.Set(key, "v1")
+
+		section.Step(1, "A watch starts at the current value")
+		state, expires, err := watches.Watch(key, "alice", "")
+		assert.NoError(err, "Watch should succeed")
+		assert.Equal(uint64(0), state.Seq, "First state should be seq 0")
+		assert.Equal("v1", state.Value, "Current value should be returned")
+		assert.True(expires.After(clock.Now()), "Watch should expire in the future")
+
+		section.Step(2, "A different value is a new version")
+		storage.Set(key, "v2")
+		change, changed, err := watches.Poll(context.Background(), key, "alice", 0, 0)
+		assert.NoError(err, "Poll should succeed")
+		assert.True(changed, "Key should have changed")
+		assert.Equal(uint64(1), change.Seq, "Seq should grow by one")
+		assert.Equal("v2", change.Value, "New value should be returned")
+
+		section.Step(3, "A republish of the same value is not")
+		storage.Set(key, "v2")
+		_, changed, _ = watches.Poll(context.Background(), key, "alice", 1, 0)
+		assert.False(changed, "Republish should not be a change")
+
+		section.Step(4, "A deletion is a new version")
+		storage.Tombstone(key, clock.Now())
+		change, changed, _ = watches.Poll(context.Background(), key, "alice", 1, 0)
+		assert.True(changed, "Delete should be a change")
+		assert.True(change.Deleted, "Change should be marked deleted")
+		assert.Equal(uint64(2), change.Seq, "Seq should grow by one")
+
+		section.Step(5, "Binary values are carried as base64")
+		binKey := fixtures.GenerateValidHexID("binary")
+		watches.Watch(binKey, "alice", "")
+		storage.Set(binKey, binaryValue)
+		change, _, _ = watches.Poll(context.Background(), binKey, "alice", 0, 0)
+		decoded, err := change.Decoded()
+		assert.NoError(err, "Value should decode")
+		assert.Equal(binaryValue, decoded, "Bytes should survive")
+
+		section.Success("Key versions tracked correctly")
+	})
+
+	t.Run("LongPoll", func(t *testing.T) {
+		section := logger.Section("Long Poll")
+
+		storage := kademlia.NewKeyValueStore()
+		watches := kademlia.NewWatches(storage)
+		key := fixtures.GenerateValidHexID("longpoll")
+		watches.Watch(key, "alice", "")
+
+		section.Step(1, "A poll wakes on the next store")
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			storage.Set(key, "fresh")
+		}()
+		start := time.Now()
+		change, changed, err := watches.Poll(context.Background(), key, "alice", 0, 5*time.Second)
+		assert.NoError(err, "Poll should succeed")
+		assert.True(changed, "Poll should see the store")
+		assert.Equal("fresh", change.Value, "Stored value should be returned")
+		assert.True(time.Since(start) < 2*time.Second, "Poll should return promptly")
+
+		section.Step(2, "A poll without changes times out")
+		_, changed, err = watches.Poll(context.Background(), key, "alice", change.Seq, 20*time.Millisecond)
+		assert.NoError(err, "Poll should succeed")
+		assert.False(changed, "Nothing should have changed")
+
+		section.Step(3, "Unknown watchers are refused")
+		_, _, err = watches.Poll(context.Background(), key, "bob", 0, 0)
+		assert.Equal(kademlia.ErrNotWatching, err, "Bob should not be watching")
+
+		section.Success("Long poll working correctly")
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		section := logger.Section("Webhook")
+
+		changes := make(chan kademlia.KeyChange, 1)
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var change kademlia.KeyChange
+			json.NewDecoder(r.Body).Decode(&change)
+			changes <- change
+		}))
+		defer hook.Close()
+
+		storage := kademlia.NewKeyValueStore()
+		watches := kademlia.NewWatches(storage)
+		key := fixtures.GenerateValidHexID("webhook")
+
+		section.Step(1, "Webhooks on private addresses are refused by default")
+		_, _, err := watches.Watch(key, "alice", hook.URL)
+		assert.True(errors.Is(err, kademlia.ErrWebhookTarget), "Loopback webhook should be refused")
+
+		section.Step(2, "Changes are POSTed to the webhook")
+		watches.Webhooks = kademlia.NewWebhooks(true)
+		_, _, err = watches.Watch(key, "alice", hook.URL)
+		assert.NoError(err, "Webhook should be accepted once private targets are allowed")
+		storage.Set(key, "hooked")
+		select {
+		case change := <-changes:
+			assert.Equal("hooked", change.Value, "Webhook should get the new value")
+			assert.Equal(uint64(1), change.Seq, "Webhook should get the new seq")
+		case <-time.After(2 * time.Second):
+			assert.True(false, "Webhook should be notified")
+		}
+
+		section.Success("Webhook notified correctly")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		section := logger.Section("Watch Expiry")
+
+		fake := clock.NewFake(time.Unix(1700000000, 0))
+		defer clock.Set(clock.Set(fake))
+
+		storage := kademlia.NewKeyValueStore()
+		watches := kademlia.NewWatches(storage)
+		key := fixtures.GenerateValidHexID("expiry")
+
+		section.Step(1, "Renewed watches are kept")
+		watches.Watch(key, "alice", "")
+		fake.Advance(kademlia.WatchTTL / 2)
+		watches.Watch(key, "alice", "")
+		fake.Advance(kademlia.WatchTTL / 2)
+		storage.Set(key, "v1")
+		assert.Equal(1, watches.Watchers(), "Renewed watch should be kept")
+
+		section.Step(2, "Lapsed watches are dropped")
+		fake.Advance(kademlia.WatchTTL)
+		storage.Set(key, "v2")
+		assert.Equal(0, watches.Watchers(), "Lapsed watch should be dropped")
+		_, _, err := watches.Poll(context.Background(), key, "alice", 0, 0)
+		assert.Equal(kademlia.ErrNotWatching, err, "Lapsed watcher should have to watch again")
+
+		section.Step(3, "Cancelled watches are dropped")
+		watches.Watch(key, "bob", "")
+		watches.Unwatch(key, "bob")
+		assert.Equal(0, watches.Watchers(), "Cancelled watch should be dropped")
+
+		section.Success("Watch expiry working correctly")
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		section := logger.Section("Watcher Limit")
+
+		watches := kademlia.NewWatches(kademlia.NewKeyValueStore())
+		key := fixtures.GenerateValidHexID("limit")
+
+		section.Step(1, "A node keeps at most MaxWatchers")
+		for i := 0; i < kademlia.MaxWatchers; i++ {
+			watches.Watch(key, strconv.Itoa(i), "")
+		}
+		assert.Equal(kademlia.MaxWatchers, watches.Watchers(), "Watches up to the limit should be kept")
+		_, _, err := watches.Watch(key, "one-too-many", "")
+		assert.Equal(kademlia.ErrTooManyWatchers, err, "Watches past the limit should be refused")
+
+		section.Success("Watcher limit enforced")
+	})
+
+	t.Run("Handlers", func(t *testing.T) {
+		section := logger.Section("Watch Handlers")
+
+		node := fixtures.CreateTestNode(8080, "holder")
+		storage := kademlia.NewKeyValueStore()
+		watches := kademlia.NewWatches(storage)
+		storage.Set(kademlia.KeyFromString("user:42"), "alice")
+
+		watch := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/watch?hash=true", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			kademlia.WatchHandler(rr, req, node, storage, watches)
+			return rr
+		}
+		poll := func(query string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/watch_poll?"+query, nil)
+			rr := httptest.NewRecorder()
+			kademlia.WatchPollHandler(rr, req, storage, watches)
+			return rr
+		}
+		key := kademlia.KeyFromString("user:42")
+
+		section.Step(1, "A watch answers 201 with the current state")
+		rr := watch(`{"key": "user:42", "watcher_id": "app"}`)
+		assert.Equal(http.StatusCreated, rr.Code, "Watch should succeed: %s", rr.Body.String())
+		var reply kademlia.WatchReply
+		assert.NoError(json.Unmarshal(rr.Body.Bytes(), &reply), "Reply should decode")
+		assert.Equal("alice", reply.State.Value, "Current value should be returned")
+		assert.Equal(node.ID, reply.NodeID, "Holder should identify itself")
+
+		section.Step(2, "Polls answer 204 without changes and 200 with")
+		assert.Equal(http.StatusNoContent, poll("key="+key+"&watcher_id=app&timeout=0").Code, "Unchanged key should answer 204")
+		storage.Set(key, "bob")
+		rr = poll("key=" + key + "&watcher_id=app&timeout=0")
+		assert.Equal(http.StatusOK, rr.Code, "Changed key should answer 200")
+		assert.Contains(rr.Body.String(), `"seq":1`, "Change should carry its seq")
+
+		section.Step(3, "Unknown watchers get 404")
+		assert.Equal(http.StatusNotFound, poll("key="+key+"&watcher_id=other&timeout=0").Code, "Unknown watcher should answer 404")
+
+		section.Step(4, "Cancelling drops the watch")
+		assert.Equal(http.StatusOK, watch(`{"key": "user:42", "watcher_id": "app", "cancel": true}`).Code, "Cancel should succeed")
+		assert.Equal(0, watches.Watchers(), "Watch should be dropped")
+
+		section.Step(5, "Bad requests are refused")
+		assert.Equal(http.StatusBadRequest, watch(`{"key": "user:42"}`).Code, "Missing watcher should be refused")
+		assert.Equal(http.StatusBadRequest, poll("key=nothex&watcher_id=app").Code, "Invalid key should be refused")
+		assert.Equal(http.StatusBadRequest, poll("key="+key+"&watcher_id=app&timeout=301").Code, "Long timeouts should be refused")
+		relayOnly := &models.Node{ID: fixtures.GenerateValidHexID("relay"), Flags: models.FlagRelay}
+		req := httptest.NewRequest("POST", "/watch", strings.NewReader(`{"key": "`+key+`", "watcher_id": "app"}`))
+		rr = httptest.NewRecorder()
+		kademlia.WatchHandler(rr, req, relayOnly, storage, watches)
+		assert.Equal(http.StatusForbidden, rr.Code, "Nodes without storage should refuse watches")
+
+		section.Success("Watch handlers working correctly")
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		section := logger.Section("Client Watch")
+
+		node := fixtures.CreateTestNode(8080, "holder")
+		storage := kademlia.NewKeyValueStore()
+		watches := kademlia.NewWatches(storage)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.WatchHandler(w, r, node, storage, watches)
+		})
+		mux.HandleFunc("/watch_poll", func(w http.ResponseWriter, r *http.Request) {
+			kademlia.WatchPollHandler(w, r, storage, watches)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		c := client.NewClient(strings.TrimPrefix(server.URL, "http://"))
+
+		section.Step(1, "Watch registers on the holder")
+		reply, err := c.Watch(context.Background(), "user:42", "app", "", true)
+		assert.NoError(err, "Watch should succeed")
+		assert.True(reply.State.Deleted, "Missing key should be reported deleted")
+
+		section.Step(2, "WatchPoll reports no change in time")
+		_, changed, err := c.WatchPoll(context.Background(), "user:42", "app", reply.State.Seq, 0, true)
+		assert.NoError(err, "Poll should succeed")
+		assert.False(changed, "Nothing should have changed")
+
+		section.Step(3, "WatchPoll returns decoded changes")
+		storage.Set(kademlia.KeyFromString("user:42"), binaryValue)
+		change, changed, err := c.WatchPoll(context.Background(), "user:42", "app", reply.State.Seq, 0, true)
+		assert.NoError(err, "Poll should succeed")
+		assert.True(changed, "Store should be seen")
+		assert.Equal(binaryValue, change.Value, "Bytes should be decoded")
+
+		section.Success("Client watch working correctly")
+	})
+}